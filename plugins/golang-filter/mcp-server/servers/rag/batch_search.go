@@ -0,0 +1,135 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/embedding"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+const (
+	// MAX_BATCH_QUERIES is the most queries a single BatchSearchChunks call accepts
+	MAX_BATCH_QUERIES = 50
+	// BATCH_SEARCH_CONCURRENCY bounds the vector store searches of a batch running at once
+	BATCH_SEARCH_CONCURRENCY = 8
+)
+
+// BatchSearchError reports the queries of a batch that failed. Errors has one slot per
+// query in input order, nil for the queries that succeeded.
+type BatchSearchError struct {
+	Errors []error
+}
+
+func (e *BatchSearchError) Error() string {
+	failed := e.Unwrap()
+	if len(failed) == 0 {
+		return "batch search failed"
+	}
+	return fmt.Sprintf("%d of %d queries failed, first error: %v", len(failed), len(e.Errors), failed[0])
+}
+
+// Unwrap returns the errors of the failed queries
+func (e *BatchSearchError) Unwrap() []error {
+	failed := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+// BatchSearchChunks searches like SearchChunks for every query, returning the results in
+// input order. The queries are embedded in batches and searched concurrently. A query that
+// fails leaves its results nil and does not fail the others: the error is then a
+// *BatchSearchError holding the error of each query.
+func (r *RAGClient) BatchSearchChunks(queries []string, topK int, threshold float64) ([][]schema.SearchResult, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("at least one query is required")
+	}
+	if len(queries) > MAX_BATCH_QUERIES {
+		return nil, fmt.Errorf("at most %d queries can be searched in one batch, got %d", MAX_BATCH_QUERIES, len(queries))
+	}
+	r, done := r.acquire()
+	defer done()
+	ctx := context.Background()
+
+	results := make([][]schema.SearchResult, len(queries))
+	errs := make([]error, len(queries))
+	vectors := r.embedQueries(ctx, queries, errs)
+	options := &schema.SearchOptions{
+		TopK:      topK,
+		Threshold: threshold,
+		Filters:   r.namespaceFilters(),
+	}
+
+	slots := make(chan struct{}, BATCH_SEARCH_CONCURRENCY)
+	var wg sync.WaitGroup
+	for i, vector := range vectors {
+		if errs[i] != nil {
+			continue
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, vector []float32) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			docs, err := r.vectordbProvider.SearchDocs(ctx, vector, options)
+			if err != nil {
+				errs[i] = fmt.Errorf("search chunks failed, err: %w", err)
+				return
+			}
+			results[i] = docs
+		}(i, vector)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return results, &BatchSearchError{Errors: errs}
+		}
+	}
+	return results, nil
+}
+
+// embedQueries returns the normalized vectors of queries in input order, recording in errs
+// the queries that could not be embedded. The queries are embedded in one batch; when the
+// batch fails, they are embedded one by one so a single bad query only fails itself.
+func (r *RAGClient) embedQueries(ctx context.Context, queries []string, errs []error) [][]float32 {
+	vectors := make([][]float32, len(queries))
+	texts := make([]string, 0, len(queries))
+	indexes := make([]int, 0, len(queries))
+	for i, query := range queries {
+		if strings.TrimSpace(query) == "" {
+			errs[i] = fmt.Errorf("query must not be empty")
+			continue
+		}
+		texts = append(texts, query)
+		indexes = append(indexes, i)
+	}
+	if len(texts) == 0 {
+		return vectors
+	}
+
+	// An open circuit rejects every query, which embedding one by one reports per query
+	if r.embeddingBreaker == nil || !r.embeddingBreaker.Open() {
+		if batch, err := embedding.GetEmbeddings(ctx, r.embeddingProvider, texts); err == nil {
+			for j, i := range indexes {
+				vectors[i] = r.normalizeEmbedding(batch[j])
+			}
+			return vectors
+		}
+	}
+	for _, i := range indexes {
+		vector, err := r.queryEmbedder().GetEmbedding(ctx, queries[i])
+		if err != nil {
+			errs[i] = fmt.Errorf("create embedding failed, err: %w", err)
+			continue
+		}
+		vectors[i] = r.normalizeEmbedding(vector)
+	}
+	return vectors
+}
@@ -0,0 +1,108 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// batchEmbedder embeds in batches, failing every text equal to fail
+type batchEmbedder struct {
+	MockEmbeddingProvider
+	fail    string
+	batches int
+}
+
+func (e *batchEmbedder) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	if text == e.fail {
+		return nil, fmt.Errorf("cannot embed %q", text)
+	}
+	return e.MockEmbeddingProvider.GetEmbedding(ctx, text)
+}
+
+func (e *batchEmbedder) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	e.batches++
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector, err := e.GetEmbedding(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func (e *batchEmbedder) MaxBatchSize() int { return 16 }
+
+func TestRAGClient_BatchSearchChunks(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}}, nil)
+	embedder := &batchEmbedder{MockEmbeddingProvider: MockEmbeddingProvider{Dim: 64}, fail: "broken query"}
+	client.embeddingProvider = embedder
+	for _, text := range []string{"higress gateway routing", "wasm plugin development"} {
+		if _, err := client.CreateChunkFromText(text, "guide"); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	embedder.batches = 0
+	results, err := client.BatchSearchChunks([]string{"wasm plugin", "higress gateway"}, 1, 0)
+	if err != nil {
+		t.Fatalf("BatchSearchChunks() error = %v", err)
+	}
+	if len(results) != 2 || results[0][0].Document.Content != "wasm plugin development" ||
+		results[1][0].Document.Content != "higress gateway routing" || embedder.batches != 1 {
+		t.Errorf("BatchSearchChunks() = %+v after %d batches, want the results in input order from one batch", results, embedder.batches)
+	}
+
+	// A failed query leaves its slot empty and reports its error only
+	results, err = client.BatchSearchChunks([]string{"higress gateway", "", "broken query"}, 1, 0)
+	var batchErr *BatchSearchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("BatchSearchChunks() error = %v, want a *BatchSearchError", err)
+	}
+	if len(results[0]) != 1 || results[1] != nil || results[2] != nil ||
+		batchErr.Errors[0] != nil || batchErr.Errors[1] == nil || batchErr.Errors[2] == nil {
+		t.Errorf("BatchSearchChunks() = %+v, errors %v, want only the first query answered", results, batchErr.Errors)
+	}
+
+	if _, err := client.BatchSearchChunks(nil, 1, 0); err == nil {
+		t.Error("BatchSearchChunks() without queries should fail")
+	}
+	if _, err := client.BatchSearchChunks(make([]string, MAX_BATCH_QUERIES+1), 1, 0); err == nil {
+		t.Error("BatchSearchChunks() over MAX_BATCH_QUERIES should fail")
+	}
+}
+
+func TestHandleBatchSearch(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}}, nil)
+	if _, err := client.CreateChunkFromText("higress gateway routing", "guide"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"queries": []interface{}{"higress gateway", " "}, "top_k": float64(3)}
+	result, err := HandleBatchSearch(client)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("HandleBatchSearch() error = %v", err)
+	}
+	var decoded []batchSearchResult
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if len(decoded) != 2 || decoded[0].Query != "higress gateway" || len(decoded[0].Results) != 1 || decoded[0].Error != "" {
+		t.Errorf("HandleBatchSearch() = %+v, want the first query answered", decoded)
+	}
+	if decoded[1].Error == "" || decoded[1].Results == nil {
+		t.Errorf("HandleBatchSearch() = %+v, want an error and no results for the blank query", decoded[1])
+	}
+
+	request.Params.Arguments = map[string]interface{}{"queries": []interface{}{"ok", 1}}
+	if _, err := HandleBatchSearch(client)(context.Background(), request); err == nil {
+		t.Errorf("HandleBatchSearch() error = %v, want an invalid queries error", err)
+	}
+}
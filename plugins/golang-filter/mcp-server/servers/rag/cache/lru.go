@@ -11,6 +11,11 @@ type Cache interface {
 	Get(key string) (any, bool)
 	Set(key string, value any, ttl time.Duration)
 	Purge()
+	// RemoveIf deletes every entry for which match returns true and reports how many were removed.
+	RemoveIf(match func(key string, value any) bool) int
+	// Range calls fn for every unexpired entry until fn returns false. fn must not call
+	// back into the cache.
+	Range(fn func(key string, value any) bool)
 }
 
 type entry struct {
@@ -90,6 +95,35 @@ func (c *lruCache) Purge() {
 	c.order.Init()
 }
 
+func (c *lruCache) RemoveIf(match func(key string, value any) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, ent := range c.items {
+		if match(key, ent.value) {
+			c.removeEntry(ent)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (c *lruCache) Range(fn func(key string, value any) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, ent := range c.items {
+		if !ent.expires.IsZero() && !now.Before(ent.expires) {
+			continue
+		}
+		if !fn(key, ent.value) {
+			return
+		}
+	}
+}
+
 func (c *lruCache) computeExpiry(ttl time.Duration) time.Time {
 	if ttl <= 0 {
 		ttl = c.ttl
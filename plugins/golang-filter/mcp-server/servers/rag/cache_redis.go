@@ -0,0 +1,303 @@
+package rag
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/embedding"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-session/common"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// REDIS_CACHE_PREFIX prefixes every key of the L2 retrieval cache
+const REDIS_CACHE_PREFIX = "rag:cache:"
+
+// Entries are stored under prefix+generation+":"+key, so Purge only has to bump the
+// generation; entries of earlier generations become unreachable and expire by TTL.
+const (
+	redisCacheGetScript = `
+local gen = redis.call('GET', KEYS[1]) or '0'
+local value = redis.call('GET', ARGV[1] .. gen .. ':' .. ARGV[2])
+if not value then return '' end
+return value`
+	redisCacheSetScript = `
+local gen = redis.call('GET', KEYS[1]) or '0'
+redis.call('SET', ARGV[1] .. gen .. ':' .. ARGV[2], ARGV[3], 'PX', tonumber(ARGV[4]))
+return 1`
+	redisCachePurgeScript = `return redis.call('INCR', KEYS[1])`
+	// The semantic index of a scope is a list of {key, embedding} members, newest first,
+	// capped at ARGV[4] members and expiring with the last entry added
+	redisCacheIndexScript = `
+local gen = redis.call('GET', KEYS[1]) or '0'
+local index = ARGV[1] .. gen .. ':semantic:' .. ARGV[2]
+redis.call('LPUSH', index, ARGV[3])
+redis.call('LTRIM', index, 0, tonumber(ARGV[4]) - 1)
+redis.call('PEXPIRE', index, tonumber(ARGV[5]))
+return 1`
+	redisCacheSimilarScript = `
+local gen = redis.call('GET', KEYS[1]) or '0'
+return redis.call('LRANGE', ARGV[1] .. gen .. ':semantic:' .. ARGV[2], 0, -1)`
+)
+
+const (
+	// DEFAULT_SEMANTIC_CACHE_THRESHOLD is the default cosine similarity a cached query needs
+	// to serve a query missing the cache
+	DEFAULT_SEMANTIC_CACHE_THRESHOLD = 0.95
+	// DEFAULT_SEMANTIC_CACHE_ENTRIES is the default number of cached queries per scope the
+	// L2 semantic index keeps
+	DEFAULT_SEMANTIC_CACHE_ENTRIES = 100
+)
+
+// cacheEntry is a cached retrieval: the results and, with the semantic cache, the embedding
+// of the query they were retrieved for and the chat answer generated from them
+type cacheEntry struct {
+	Results   []schema.SearchResult `json:"results"`
+	Embedding []float32             `json:"embedding,omitempty"`
+	Answer    string                `json:"answer,omitempty"`
+}
+
+// similarKey is the cache key of a query found similar by the semantic cache
+type similarKey struct {
+	Key        string
+	Similarity float64
+}
+
+// resultCache is a cache of retrieval results shared across gateway instances
+type resultCache interface {
+	Get(key string) (cacheEntry, bool)
+	Set(key string, entry cacheEntry, ttl time.Duration)
+	// Index records that key holds the entry of a query of scope embedded as embedding
+	Index(scope, key string, embedding []float32, ttl time.Duration)
+	// Similar returns the indexed keys of scope whose query is at least threshold similar
+	// to embedding, most similar first
+	Similar(scope string, embedding []float32, threshold float64) []similarKey
+	Purge()
+}
+
+// RedisResultCache is the L2 retrieval cache behind the in-process L1 LRU. Results are
+// stored as JSON with a TTL. Redis failures are logged and treated as cache misses so
+// they never fail a query.
+type RedisResultCache struct {
+	pool   *redisPool
+	prefix string
+	ttl    time.Duration
+	// maxIndexed caps the members of a semantic index
+	maxIndexed int
+}
+
+// NewRedisResultCache creates the L2 cache from cache.l2; connections are opened lazily
+func NewRedisResultCache(cfg *config.CacheLayerConfig) (*RedisResultCache, error) {
+	rcfg, err := common.ParseRedisConfig(cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache.l2.redis config: %w", err)
+	}
+	return newRedisResultCache(cfg, redisDialer(rcfg, 0, 0)), nil
+}
+
+func newRedisResultCache(cfg *config.CacheLayerConfig, dial func() (redisConn, error)) *RedisResultCache {
+	ttl := time.Duration(cfg.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = 2 * time.Minute
+	}
+	return &RedisResultCache{pool: newRedisPool(dial), prefix: REDIS_CACHE_PREFIX, ttl: ttl, maxIndexed: DEFAULT_SEMANTIC_CACHE_ENTRIES}
+}
+
+func (c *RedisResultCache) genKey() string { return c.prefix + "gen" }
+
+// Get returns the entry cached under key
+func (c *RedisResultCache) Get(key string) (cacheEntry, bool) {
+	var reply interface{}
+	err := c.pool.do(func(conn redisConn) error {
+		v, err := conn.Eval(redisCacheGetScript, 1, []string{c.genKey()}, []interface{}{c.prefix, key})
+		reply = v
+		return err
+	})
+	if err != nil {
+		api.LogWarnf("rag: L2 cache get failed: %v", err)
+		return cacheEntry{}, false
+	}
+	value, _ := reply.(string)
+	if value == "" {
+		return cacheEntry{}, false
+	}
+	var entry cacheEntry
+	if strings.HasPrefix(value, "[") {
+		// Entries written before the semantic cache hold the bare results
+		err = json.Unmarshal([]byte(value), &entry.Results)
+	} else {
+		err = json.Unmarshal([]byte(value), &entry)
+	}
+	if err != nil {
+		api.LogWarnf("rag: L2 cache entry %s is corrupt: %v", key, err)
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// Set caches entry under key for ttl, or the configured TTL when ttl <= 0
+func (c *RedisResultCache) Set(key string, entry cacheEntry, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	if entry.Results == nil {
+		entry.Results = []schema.SearchResult{}
+	}
+	value, err := json.Marshal(entry)
+	if err != nil {
+		api.LogWarnf("rag: L2 cache encode failed: %v", err)
+		return
+	}
+	err = c.pool.do(func(conn redisConn) error {
+		_, err := conn.Eval(redisCacheSetScript, 1, []string{c.genKey()},
+			[]interface{}{c.prefix, key, string(value), strconv.FormatInt(ttl.Milliseconds(), 10)})
+		return err
+	})
+	if err != nil {
+		api.LogWarnf("rag: L2 cache set failed: %v", err)
+	}
+}
+
+// semanticMember is a member of a semantic index
+type semanticMember struct {
+	Key       string    `json:"key"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// Index adds key to the semantic index of scope, dropping its oldest member when full
+func (c *RedisResultCache) Index(scope, key string, vector []float32, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	member, err := json.Marshal(semanticMember{Key: key, Embedding: vector})
+	if err != nil {
+		api.LogWarnf("rag: L2 cache encode failed: %v", err)
+		return
+	}
+	err = c.pool.do(func(conn redisConn) error {
+		_, err := conn.Eval(redisCacheIndexScript, 1, []string{c.genKey()},
+			[]interface{}{c.prefix, scope, string(member), strconv.Itoa(c.maxIndexed), strconv.FormatInt(ttl.Milliseconds(), 10)})
+		return err
+	})
+	if err != nil {
+		api.LogWarnf("rag: L2 cache index failed: %v", err)
+	}
+}
+
+// Similar compares vector with the indexed queries of scope. A key indexed more than once
+// is returned once, with its latest embedding.
+func (c *RedisResultCache) Similar(scope string, vector []float32, threshold float64) []similarKey {
+	var reply interface{}
+	err := c.pool.do(func(conn redisConn) error {
+		v, err := conn.Eval(redisCacheSimilarScript, 1, []string{c.genKey()}, []interface{}{c.prefix, scope})
+		reply = v
+		return err
+	})
+	if err != nil {
+		api.LogWarnf("rag: L2 cache similar lookup failed: %v", err)
+		return nil
+	}
+	members, _ := reply.([]interface{})
+	seen := make(map[string]bool, len(members))
+	similar := make([]similarKey, 0)
+	for _, raw := range members {
+		value, _ := raw.(string)
+		var member semanticMember
+		if err := json.Unmarshal([]byte(value), &member); err != nil || seen[member.Key] {
+			continue
+		}
+		seen[member.Key] = true
+		if sim := embedding.CosineSimilarity(vector, member.Embedding); sim >= threshold {
+			similar = append(similar, similarKey{Key: member.Key, Similarity: sim})
+		}
+	}
+	sort.SliceStable(similar, func(i, j int) bool { return similar[i].Similarity > similar[j].Similarity })
+	return similar
+}
+
+// Purge makes every cached entry unreachable
+func (c *RedisResultCache) Purge() {
+	err := c.pool.do(func(conn redisConn) error {
+		_, err := conn.Eval(redisCachePurgeScript, 1, []string{c.genKey()}, nil)
+		return err
+	})
+	if err != nil {
+		api.LogWarnf("rag: L2 cache purge failed: %v", err)
+	}
+}
+
+// Close releases the pooled Redis connections
+func (c *RedisResultCache) Close() error { return c.pool.Close() }
+
+// REDIS_EMBEDDING_PREFIX prefixes every key of the Redis embedding cache
+const REDIS_EMBEDDING_PREFIX = "rag:embedding:"
+
+const redisEmbeddingGetScript = `return redis.call('GET', KEYS[1]) or ''`
+
+// RedisVectorCache shares the embedding cache across gateway instances. Vectors are stored
+// as JSON with a TTL; Redis failures are logged and treated as misses.
+type RedisVectorCache struct {
+	pool   *redisPool
+	prefix string
+}
+
+// NewRedisVectorCache creates the Redis embedding cache from embedding.cache; connections
+// are opened lazily
+func NewRedisVectorCache(cfg *config.CacheLayerConfig) (*RedisVectorCache, error) {
+	rcfg, err := common.ParseRedisConfig(cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedding.cache.redis config: %w", err)
+	}
+	return newRedisVectorCache(redisDialer(rcfg, 0, 0)), nil
+}
+
+func newRedisVectorCache(dial func() (redisConn, error)) *RedisVectorCache {
+	return &RedisVectorCache{pool: newRedisPool(dial), prefix: REDIS_EMBEDDING_PREFIX}
+}
+
+// Close releases the pooled Redis connections
+func (c *RedisVectorCache) Close() error { return c.pool.Close() }
+
+// Get returns the vector cached under key
+func (c *RedisVectorCache) Get(key string) ([]float32, bool) {
+	var reply interface{}
+	err := c.pool.do(func(conn redisConn) error {
+		v, err := conn.Eval(redisEmbeddingGetScript, 1, []string{c.prefix + key}, nil)
+		reply = v
+		return err
+	})
+	if err != nil {
+		api.LogWarnf("rag: embedding cache get failed: %v", err)
+		return nil, false
+	}
+	value, _ := reply.(string)
+	if value == "" {
+		return nil, false
+	}
+	var vector []float32
+	if err := json.Unmarshal([]byte(value), &vector); err != nil {
+		api.LogWarnf("rag: embedding cache entry %s is corrupt: %v", key, err)
+		return nil, false
+	}
+	return vector, true
+}
+
+// Set caches vector under key for ttl
+func (c *RedisVectorCache) Set(key string, vector []float32, ttl time.Duration) {
+	value, err := json.Marshal(vector)
+	if err != nil {
+		api.LogWarnf("rag: embedding cache encode failed: %v", err)
+		return
+	}
+	err = c.pool.do(func(conn redisConn) error {
+		return conn.Set(c.prefix+key, string(value), ttl)
+	})
+	if err != nil {
+		api.LogWarnf("rag: embedding cache set failed: %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retriever"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// fakeRedisCache is an in-memory Redis that runs the L2 cache scripts
+type fakeRedisCache struct {
+	mu     sync.Mutex
+	values map[string]string
+	lists  map[string][]string
+	ttls   map[string]int64
+	down   bool
+}
+
+func newFakeRedisCache() *fakeRedisCache {
+	return &fakeRedisCache{values: map[string]string{}, lists: map[string][]string{}, ttls: map[string]int64{}}
+}
+
+func (f *fakeRedisCache) dial() (redisConn, error) { return &fakeRedisCacheConn{f}, nil }
+
+type fakeRedisCacheConn struct{ store *fakeRedisCache }
+
+func (c *fakeRedisCacheConn) Eval(script string, numKeys int, keys []string, args []interface{}) (interface{}, error) {
+	f := c.store
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	gen := f.values[keys[0]]
+	if gen == "" {
+		gen = "0"
+	}
+	switch script {
+	case redisCacheGetScript:
+		return f.values[args[0].(string)+gen+":"+args[1].(string)], nil
+	case redisCacheSetScript:
+		key := args[0].(string) + gen + ":" + args[1].(string)
+		f.values[key] = args[2].(string)
+		f.ttls[key], _ = strconv.ParseInt(args[3].(string), 10, 64)
+		return int64(1), nil
+	case redisCacheIndexScript:
+		index := args[0].(string) + gen + ":semantic:" + args[1].(string)
+		limit, _ := strconv.Atoi(args[3].(string))
+		f.lists[index] = append([]string{args[2].(string)}, f.lists[index]...)
+		if len(f.lists[index]) > limit {
+			f.lists[index] = f.lists[index][:limit]
+		}
+		f.ttls[index], _ = strconv.ParseInt(args[4].(string), 10, 64)
+		return int64(1), nil
+	case redisCacheSimilarScript:
+		members := make([]interface{}, 0)
+		for _, member := range f.lists[args[0].(string)+gen+":semantic:"+args[1].(string)] {
+			members = append(members, member)
+		}
+		return members, nil
+	case redisEmbeddingGetScript:
+		return f.values[keys[0]], nil
+	case redisCachePurgeScript:
+		n, _ := strconv.ParseInt(gen, 10, 64)
+		f.values[keys[0]] = strconv.FormatInt(n+1, 10)
+		return n + 1, nil
+	}
+	return nil, errors.New("unexpected script")
+}
+
+func (c *fakeRedisCacheConn) Set(key string, value string, expiration time.Duration) error {
+	f := c.store
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("dial tcp: connection refused")
+	}
+	f.values[key] = value
+	f.ttls[key] = expiration.Milliseconds()
+	return nil
+}
+
+func (c *fakeRedisCacheConn) Close() error { return nil }
+
+func TestRedisResultCache(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	store := newFakeRedisCache()
+	c := newRedisResultCache(&config.CacheLayerConfig{TTLSeconds: 60}, store.dial)
+	results := []schema.SearchResult{{Document: schema.Document{ID: "doc", Content: "higress"}, Score: 0.9}}
+
+	if _, ok := c.Get("q"); ok {
+		t.Fatal("Get() on an empty cache hit")
+	}
+	c.Set("q", cacheEntry{Results: results}, 0)
+	c.Set("empty", cacheEntry{}, 5*time.Second)
+	got, ok := c.Get("q")
+	if !ok || len(got.Results) != 1 || got.Results[0].Document.ID != "doc" || got.Results[0].Score != 0.9 {
+		t.Fatalf("Get() = %+v, %v, want the cached results", got, ok)
+	}
+	if got, ok := c.Get("empty"); !ok || len(got.Results) != 0 {
+		t.Errorf("Get() = %+v, %v, want the cached empty result", got, ok)
+	}
+	if ttl := store.ttls[REDIS_CACHE_PREFIX+"0:q"]; ttl != 60000 {
+		t.Errorf("TTL = %dms, want the configured 60s", ttl)
+	}
+	if ttl := store.ttls[REDIS_CACHE_PREFIX+"0:empty"]; ttl != 5000 {
+		t.Errorf("TTL = %dms, want the per-entry 5s", ttl)
+	}
+
+	c.Purge()
+	if _, ok := c.Get("q"); ok {
+		t.Error("Get() after Purge() hit")
+	}
+
+	// Entries written before the semantic cache hold the bare results
+	store.values[REDIS_CACHE_PREFIX+"1:legacy"] = `[{"document":{"id":"doc"},"score":0.5}]`
+	if got, ok := c.Get("legacy"); !ok || len(got.Results) != 1 || got.Results[0].Score != 0.5 {
+		t.Errorf("Get() = %+v, %v, want the legacy results", got, ok)
+	}
+
+	store.down = true
+	c.Set("q", cacheEntry{Results: results}, 0)
+	if _, ok := c.Get("q"); ok {
+		t.Error("Get() with Redis down hit, want a miss")
+	}
+}
+
+func TestRAGClient_L2CacheSharedAcrossClients(t *testing.T) {
+	stub := &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "doc", Content: "higress gateway"}, Score: 1},
+	}}
+	retriever.Register("l2_cache_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	newClient := func(redis *fakeRedisCache) *RAGClient {
+		pipeline := config.DefaultPipeline()
+		pipeline.Retrievers = []config.RetrieverConfig{{Type: "l2_cache_bm25", Params: map[string]string{"name": "bm25"}}}
+		pipeline.RetrievalProfiles = []config.RetrievalProfile{
+			{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, Threshold: 0.001},
+		}
+		pipeline.DefaultProfile = "default"
+		pipeline.Cache = &config.CacheConfig{L1: &config.CacheLayerConfig{Enable: true}}
+		client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, VectorDB: config.VectorDBConfig{Collection: "docs"}, Pipeline: pipeline}, nil)
+		client.l2Cache = newRedisResultCache(&config.CacheLayerConfig{}, redis.dial)
+		return client
+	}
+	redis := newFakeRedisCache()
+	first, second := newClient(redis), newClient(redis)
+	search := func(c *RAGClient) bool {
+		t.Helper()
+		_, _, m, err := c.runEnhancedPipeline(context.Background(), "higress", RequestOptions{}, nil)
+		if err != nil {
+			t.Fatalf("runEnhancedPipeline() error = %v", err)
+		}
+		return m.CacheHit
+	}
+
+	if search(first) {
+		t.Fatal("first query hit the cache")
+	}
+	// An earlier write on one instance must not split the instances' L2 keys
+	second.indexGenerations.bump(second.namespace)
+	if !search(second) {
+		t.Error("second instance missed, want the shared L2 hit")
+	}
+	if calls := atomic.LoadInt32(&stub.calls); calls != 1 {
+		t.Fatalf("retriever called %d times, want the second instance served from L2", calls)
+	}
+
+	// The L2 hit was copied into L1, so it is served with Redis down
+	redis.down = true
+	if !search(second) {
+		t.Error("repeated query missed, want the L1 hit")
+	}
+	redis.down = false
+
+	// A write on one instance purges the shared L2 cache for every instance
+	if _, err := first.CreateChunkFromText("Higress supports wasm plugins.", "plugins"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	if search(newClient(redis)) {
+		t.Error("query after ingest hit the cache, want L2 purged")
+	}
+	if calls := atomic.LoadInt32(&stub.calls); calls != 2 {
+		t.Errorf("after ingest retriever called %d times, want the query retrieved again", calls)
+	}
+}
+
+func TestRedisResultCache_Similar(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	store := newFakeRedisCache()
+	c := newRedisResultCache(&config.CacheLayerConfig{TTLSeconds: 60}, store.dial)
+	c.maxIndexed = 2
+
+	c.Index("scope", "a", []float32{1, 0}, 0)
+	c.Index("scope", "b", []float32{1, 1}, 0)
+	c.Index("other", "c", []float32{1, 0}, 0)
+	got := c.Similar("scope", []float32{1, 0.1}, 0.5)
+	if len(got) != 2 || got[0].Key != "a" || got[1].Key != "b" || got[0].Similarity < got[1].Similarity {
+		t.Fatalf("Similar() = %+v, want a then b", got)
+	}
+	if got := c.Similar("scope", []float32{0, 1}, 0.9); len(got) != 0 {
+		t.Errorf("Similar() = %+v, want no key above the threshold", got)
+	}
+
+	// The index keeps the newest maxIndexed members
+	c.Index("scope", "d", []float32{0, 1}, 0)
+	if got := c.Similar("scope", []float32{1, 0}, 0.99); len(got) != 0 {
+		t.Errorf("Similar() = %+v, want the oldest member dropped", got)
+	}
+	c.Purge()
+	if got := c.Similar("scope", []float32{0, 1}, 0); len(got) != 0 {
+		t.Errorf("Similar() after Purge() = %+v, want none", got)
+	}
+}
+
+func newSemanticCacheClient(t *testing.T, name string, layer *config.CacheLayerConfig, llmProvider llm.Provider) (*RAGClient, *stubRetriever) {
+	t.Helper()
+	stub := &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "install", Content: "Install Higress with helm."}, Score: 1},
+	}}
+	retriever.Register(name, func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: name, Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, Threshold: 0.001},
+	}
+	pipeline.DefaultProfile = "default"
+	pipeline.Cache = &config.CacheConfig{
+		L1:       layer,
+		Semantic: &config.SemanticCacheConfig{Enable: true, Threshold: 0.9, CacheAnswer: true},
+	}
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, VectorDB: config.VectorDBConfig{Collection: "docs"}, Pipeline: pipeline}, llmProvider)
+	return client, stub
+}
+
+func TestRAGClient_SemanticCache(t *testing.T) {
+	client, stub := newSemanticCacheClient(t, "semantic_cache_bm25", &config.CacheLayerConfig{Enable: true}, nil)
+	search := func(query string) *metrics.RetrievalMetrics {
+		t.Helper()
+		_, _, m, err := client.runEnhancedPipeline(context.Background(), query, RequestOptions{}, nil)
+		if err != nil {
+			t.Fatalf("runEnhancedPipeline() error = %v", err)
+		}
+		return m
+	}
+
+	if m := search("how do i install the higress gateway"); m.CacheHit {
+		t.Fatal("first query hit the cache")
+	}
+	m := search("how do i install the higress gateway please")
+	if !m.CacheHit || m.CacheHitType != metrics.CACHE_HIT_SEMANTIC || m.CacheSimilarity < 0.9 {
+		t.Errorf("similar query metrics = hit %v, type %q, similarity %.2f, want a semantic hit", m.CacheHit, m.CacheHitType, m.CacheSimilarity)
+	}
+	if m := search("which wasm plugins does higress support"); m.CacheHit {
+		t.Error("dissimilar query hit the cache")
+	}
+	if m := search("how do i install the higress gateway"); !m.CacheHit || m.CacheHitType != metrics.CACHE_HIT_EXACT {
+		t.Errorf("repeated query hit type = %q, want exact", m.CacheHitType)
+	}
+	if calls := atomic.LoadInt32(&stub.calls); calls != 2 {
+		t.Errorf("retriever called %d times, want only the first and the dissimilar query retrieved", calls)
+	}
+	if s := client.metricsAggregator.Snapshot(); s.CacheHits != 2 || s.CacheSemanticHits != 1 {
+		t.Errorf("snapshot cache hits = %d, semantic %d, want 2 and 1", s.CacheHits, s.CacheSemanticHits)
+	}
+}
+
+func TestRAGClient_SemanticCacheL2AndAnswers(t *testing.T) {
+	mockLLM := &MockLLMProvider{Respond: func(prompt string) (string, error) { return "Run helm install.", nil }}
+	redis := newFakeRedisCache()
+	newClient := func() (*RAGClient, *stubRetriever) {
+		client, stub := newSemanticCacheClient(t, "semantic_l2_bm25", nil, mockLLM)
+		client.l2Cache = newRedisResultCache(&config.CacheLayerConfig{}, redis.dial)
+		client.cacheMode = "post"
+		return client, stub
+	}
+	first, _ := newClient()
+	resp, err := first.ChatWithSources("how do i install the higress gateway", RequestOptions{})
+	if err != nil || resp.Answer != "Run helm install." {
+		t.Fatalf("ChatWithSources() = %+v, %v", resp, err)
+	}
+
+	// Another instance serves a similar query from L2, answer included
+	second, stub := newClient()
+	resp, m, err := second.chat("how do i install the higress gateway please", RequestOptions{})
+	if err != nil || resp.Answer != "Run helm install." || len(resp.Sources) != 1 {
+		t.Fatalf("chat() = %+v, %v, want the cached answer", resp, err)
+	}
+	if !m.CacheHit || m.CacheHitType != metrics.CACHE_HIT_SEMANTIC || m.LLMCalls != 0 {
+		t.Errorf("chat() metrics = hit %v, type %q, llm calls %d, want a semantic hit without LLM call", m.CacheHit, m.CacheHitType, m.LLMCalls)
+	}
+	if calls := atomic.LoadInt32(&stub.calls); calls != 0 || len(mockLLM.Prompts) != 1 {
+		t.Errorf("retriever called %d times and LLM %d times, want the similar query fully served from L2", calls, len(mockLLM.Prompts))
+	}
+
+	chunks, err := second.ChatStream(context.Background(), "how do i install the higress gateway please")
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	var streamed strings.Builder
+	for chunk := range chunks {
+		streamed.WriteString(chunk.Text)
+	}
+	if streamed.String() != "Run helm install." || len(mockLLM.Prompts) != 1 {
+		t.Errorf("ChatStream() = %q after %d LLM calls, want the cached answer", streamed.String(), len(mockLLM.Prompts))
+	}
+}
+
+func TestRedisVectorCache(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	store := newFakeRedisCache()
+	c := newRedisVectorCache(store.dial)
+
+	c.Set("abc", []float32{0.5, 1}, time.Minute)
+	if vector, ok := c.Get("abc"); !ok || len(vector) != 2 || vector[0] != 0.5 {
+		t.Errorf("Get() = %v, %v, want the stored vector", vector, ok)
+	}
+	if store.ttls[REDIS_EMBEDDING_PREFIX+"abc"] != time.Minute.Milliseconds() {
+		t.Errorf("ttl = %d, want one minute", store.ttls[REDIS_EMBEDDING_PREFIX+"abc"])
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Error("Get() of a missing key should miss")
+	}
+
+	// Redis failures are misses
+	store.down = true
+	if _, ok := c.Get("abc"); ok {
+		t.Error("Get() with Redis down should miss")
+	}
+}
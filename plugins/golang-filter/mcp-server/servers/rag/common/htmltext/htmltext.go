@@ -0,0 +1,234 @@
+// Package htmltext converts HTML pages into readable plain text for ingestion.
+package htmltext
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skipped elements never contribute visible text
+var skipped = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+}
+
+// blocks start a new paragraph so that text from adjacent blocks is not glued together
+var blocks = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Br: true, atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true, atom.Footer: true,
+	atom.Form: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true, atom.Li: true,
+	atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true,
+	atom.Section: true, atom.Table: true, atom.Td: true, atom.Th: true, atom.Tr: true,
+	atom.Ul: true,
+}
+
+// Extract parses an HTML document and returns its <title> and visible text.
+// Paragraphs are separated by blank lines and runs of whitespace are collapsed.
+func Extract(r io.Reader) (title string, text string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	var paragraphs []string
+	var current strings.Builder
+	flush := func() {
+		if p := strings.Join(strings.Fields(current.String()), " "); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		current.Reset()
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if skipped[n.DataAtom] {
+				return
+			}
+			if blocks[n.DataAtom] {
+				flush()
+				defer flush()
+			}
+		}
+		if n.Type == html.TextNode {
+			current.WriteString(n.Data)
+			current.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	flush()
+	return findTitle(doc), strings.Join(paragraphs, "\n\n"), nil
+}
+
+// findTitle returns the whitespace-collapsed text of the first <title> element
+func findTitle(n *html.Node) string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Title {
+		var b strings.Builder
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				b.WriteString(c.Data)
+			}
+		}
+		return strings.Join(strings.Fields(b.String()), " ")
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if title := findTitle(c); title != "" {
+			return title
+		}
+	}
+	return ""
+}
+
+// Link is a hyperlink found in a page
+type Link struct {
+	Text string
+	Href string
+}
+
+// Paragraph is the whitespace-collapsed text of one block together with the links inside it
+type Paragraph struct {
+	Text  string
+	Links []Link
+}
+
+// Section is the content below one heading. HeadingPath lists the enclosing headings from
+// the outermost one; it is empty for content before the first heading. The heading itself
+// is the first paragraph of its section, and sections holding nothing but their heading
+// are dropped.
+type Section struct {
+	HeadingPath []string
+	Paragraphs  []Paragraph
+}
+
+// headingLevels maps heading elements to their level
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// ExtractSections parses an HTML document like Extract and groups its paragraphs into
+// sections by heading. Unlike Extract, adjacent inline text is joined as rendered, so no
+// space is inserted before punctuation that follows a link. Links with a fragment-only or javascript: target are ignored.
+func ExtractSections(r io.Reader) (title string, sections []Section, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	type heading struct {
+		level int
+		text  string
+	}
+	var (
+		stack   []heading
+		section Section
+		current strings.Builder
+		links   []Link
+	)
+	flush := func() {
+		if p := strings.Join(strings.Fields(current.String()), " "); p != "" {
+			section.Paragraphs = append(section.Paragraphs, Paragraph{Text: p, Links: links})
+		}
+		current.Reset()
+		links = nil
+	}
+	closeSection := func() {
+		body := len(section.Paragraphs)
+		if len(section.HeadingPath) > 0 {
+			body--
+		}
+		if body > 0 {
+			sections = append(sections, section)
+		}
+	}
+	startSection := func(level int, text string) {
+		closeSection()
+		for len(stack) > 0 && stack[len(stack)-1].level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, heading{level: level, text: text})
+		path := make([]string, len(stack))
+		for i, h := range stack {
+			path[i] = h.text
+		}
+		section = Section{HeadingPath: path}
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if skipped[n.DataAtom] {
+				return
+			}
+			if level, ok := headingLevels[n.DataAtom]; ok {
+				flush()
+				if text := nodeText(n); text != "" {
+					startSection(level, text)
+				}
+				defer flush()
+			} else if blocks[n.DataAtom] {
+				flush()
+				defer flush()
+			}
+			if n.DataAtom == atom.A {
+				if href := strings.TrimSpace(attr(n, "href")); isFollowable(href) {
+					links = append(links, Link{Text: nodeText(n), Href: href})
+				}
+			}
+		}
+		if n.Type == html.TextNode {
+			current.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	flush()
+	closeSection()
+	return findTitle(doc), sections, nil
+}
+
+// nodeText returns the whitespace-collapsed visible text below n
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && skipped[n.DataAtom] {
+			return
+		}
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isFollowable(href string) bool {
+	return href != "" && !strings.HasPrefix(href, "#") && !strings.HasPrefix(strings.ToLower(href), "javascript:")
+}
@@ -0,0 +1,81 @@
+package htmltext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+<head><title>  Higress   Docs </title><style>body { color: red; }</style></head>
+<body>
+<nav><a href="/">Home</a></nav>
+<h1>Overview</h1>
+<p>Higress is a <b>cloud native</b>
+   API gateway.</p>
+<script>console.log("ignored")</script>
+<ul><li>Wasm plugins</li><li>Envoy based</li></ul>
+</body>
+</html>`
+
+	title, text, err := Extract(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if title != "Higress Docs" {
+		t.Errorf("Extract() title = %q, want %q", title, "Higress Docs")
+	}
+	want := "Home\n\nOverview\n\nHigress is a cloud native API gateway.\n\nWasm plugins\n\nEnvoy based"
+	if text != want {
+		t.Errorf("Extract() text = %q, want %q", text, want)
+	}
+}
+
+func TestExtractSections(t *testing.T) {
+	page := `<html><head><title>Guide</title></head>
+<body>
+<p>Intro with <a href="https://higress.io">the site</a>.</p>
+<h1>Install</h1>
+<h2>Linux</h2>
+<p>Run the <a href="/install.sh">script</a>, or see <a href="#top">top</a>.</p>
+<h2>Docker</h2>
+<p>Pull the image.</p>
+<h1>Configure</h1>
+<p>Edit <a href="javascript:void(0)">config</a>.</p>
+</body></html>`
+
+	title, sections, err := ExtractSections(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("ExtractSections() error = %v", err)
+	}
+	if title != "Guide" {
+		t.Errorf("ExtractSections() title = %q, want %q", title, "Guide")
+	}
+	want := []struct {
+		path  string
+		text  string
+		links string
+	}{
+		{"", "Intro with the site.", "the site=https://higress.io"},
+		{"Install/Linux", "Linux|Run the script, or see top.", "script=/install.sh"},
+		{"Install/Docker", "Docker|Pull the image.", ""},
+		{"Configure", "Configure|Edit config.", ""},
+	}
+	if len(sections) != len(want) {
+		t.Fatalf("ExtractSections() returned %d sections %+v, want %d", len(sections), sections, len(want))
+	}
+	for i, w := range want {
+		var texts, links []string
+		for _, p := range sections[i].Paragraphs {
+			texts = append(texts, p.Text)
+			for _, l := range p.Links {
+				links = append(links, l.Text+"="+l.Href)
+			}
+		}
+		got := []string{strings.Join(sections[i].HeadingPath, "/"), strings.Join(texts, "|"), strings.Join(links, ",")}
+		if got[0] != w.path || got[1] != w.text || got[2] != w.links {
+			t.Errorf("section %d = %q, want %q", i, got, []string{w.path, w.text, w.links})
+		}
+	}
+}
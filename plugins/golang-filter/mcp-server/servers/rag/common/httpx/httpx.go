@@ -54,7 +54,7 @@ func NewFromConfig(cfg *config.HTTPClientConfig) *Client {
         MaxIdleConns: 100,
         IdleConnTimeout: 30 * time.Second,
     }
-    return &Client{
+    c := &Client{
         hc: &http.Client{Timeout: to, Transport: transport},
         opt: Options{
             Timeout: to, Retry: retry, BackoffMin: bmin, BackoffMax: bmax,
@@ -62,6 +62,24 @@ func NewFromConfig(cfg *config.HTTPClientConfig) *Client {
             MaxConsecutiveFail: mcf, CircuitOpen: cop,
         },
     }
+    c.hc.CheckRedirect = c.checkRedirect
+    return c
+}
+
+// maxRedirects matches the limit of the default http.Client redirect policy
+const maxRedirects = 10
+
+// checkRedirect applies the host allowlist to every redirect, so an allowed host cannot
+// send a request on to a disallowed one
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+    if len(via) >= maxRedirects {
+        return errors.New("stopped after 10 redirects")
+    }
+    if !c.allowed(req.URL.String()) {
+        api.LogWarnf("httpx: blocked redirect to outbound host: %s", redactURL(req.URL))
+        return ErrHostNotAllowed
+    }
+    return nil
 }
 
 func (c *Client) allowed(u string) bool {
@@ -88,9 +106,32 @@ func matchHost(pattern, host string) bool {
 var ErrCircuitOpen = errors.New("circuit open")
 var ErrHostNotAllowed = errors.New("host not allowed")
 
+// secretQueryParams are query parameters that carry credentials, e.g. SerpAPI's api_key
+var secretQueryParams = []string{"key", "api_key", "apikey", "access_token", "token"}
+
+// redactURL returns u for logging, with the values of credential query parameters masked
+func redactURL(u *url.URL) string {
+    q := u.Query()
+    redacted := false
+    for k := range q {
+        for _, secret := range secretQueryParams {
+            if strings.EqualFold(k, secret) {
+                q.Set(k, "REDACTED")
+                redacted = true
+            }
+        }
+    }
+    if !redacted {
+        return u.String()
+    }
+    masked := *u
+    masked.RawQuery = q.Encode()
+    return masked.String()
+}
+
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
     if !c.allowed(req.URL.String()) {
-        api.LogWarnf("httpx: blocked outbound host: %s", req.URL.String())
+        api.LogWarnf("httpx: blocked outbound host: %s", redactURL(req.URL))
         return nil, ErrHostNotAllowed
     }
     now := time.Now().UnixNano()
@@ -101,13 +142,21 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
     var err error
     for i := 0; i <= c.opt.Retry; i++ {
         resp, err = c.hc.Do(req)
+        // The error embeds the request URL and is logged by callers
+        if ue, ok := err.(*url.Error); ok {
+            ue.URL = redactURL(req.URL)
+        }
         if err == nil && resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 500 {
             atomic.StoreInt32(&c.fail, 0)
             return resp, nil
         }
+        // A blocked redirect is a policy decision, not a failure of the host
+        if errors.Is(err, ErrHostNotAllowed) {
+            return nil, err
+        }
         // close body on failure to reuse connection
         if resp != nil && resp.Body != nil { _ = resp.Body.Close() }
-        api.LogWarnf("httpx: request failed (try %d/%d) to %s: %v", i+1, c.opt.Retry+1, req.URL.String(), err)
+        api.LogWarnf("httpx: request failed (try %d/%d) to %s: %v", i+1, c.opt.Retry+1, redactURL(req.URL), err)
         // backoff
         if i < c.opt.Retry {
             d := backoffJitter(c.opt.BackoffMin, c.opt.BackoffMax)
@@ -0,0 +1,26 @@
+package httpx
+
+import (
+    "net/url"
+    "testing"
+)
+
+func TestRedactURL(t *testing.T) {
+    tests := []struct {
+        raw  string
+        want string
+    }{
+        {"https://serpapi.com/search.json?api_key=secret&q=higress", "https://serpapi.com/search.json?api_key=REDACTED&q=higress"},
+        {"https://www.googleapis.com/customsearch/v1?Key=secret&cx=1", "https://www.googleapis.com/customsearch/v1?Key=REDACTED&cx=1"},
+        {"https://api.duckduckgo.com/?q=higress&format=json", "https://api.duckduckgo.com/?q=higress&format=json"},
+    }
+    for _, tt := range tests {
+        u, err := url.Parse(tt.raw)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := redactURL(u); got != tt.want {
+            t.Errorf("redactURL(%s) = %s, want %s", tt.raw, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,249 @@
+// Package keywords extracts the keywords of a query for lexical matching: stopwords are
+// dropped and English words are optionally reduced to a light stem.
+package keywords
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+// englishStopwords are function words that match nearly every English document
+var englishStopwords = []string{
+	"a", "about", "after", "all", "also", "am", "an", "and", "any", "are", "as", "at",
+	"be", "been", "before", "being", "between", "both", "but", "by", "can", "could",
+	"did", "do", "does", "doing", "each", "for", "from", "had", "has", "have", "having",
+	"he", "her", "here", "his", "how", "i", "if", "in", "into", "is", "it", "its", "just",
+	"may", "me", "more", "most", "my", "no", "not", "of", "on", "only", "or", "other",
+	"our", "over", "should", "so", "some", "such", "than", "that", "the", "their", "them",
+	"then", "there", "these", "they", "this", "those", "through", "to", "too", "under",
+	"up", "very", "via", "was", "we", "were", "what", "when", "where", "which", "while",
+	"who", "whom", "why", "will", "with", "would", "you", "your",
+}
+
+// chineseStopwords are particles and question words. Single characters that often start
+// or end a content word (e.g. 会 in 会话) are left out, since Chinese text is split at
+// stopwords rather than segmented into words.
+var chineseStopwords = []string{
+	"的", "了", "吗", "呢", "吧", "啊", "呀", "么",
+	"什么", "怎么", "怎样", "怎么样", "如何", "为什么", "哪些", "哪个", "是否",
+	"这个", "那个", "这些", "那些", "一个", "一些", "我们", "你们", "他们", "请问",
+	"可以", "以及", "或者", "还是", "并且", "如果", "因为", "所以", "但是", "然后",
+}
+
+// Normalizer extracts keywords from queries and normalizes documents the same way, so
+// keywords can be matched against them. The zero value keeps every word.
+type Normalizer struct {
+	// Stopwords are the lowercased words that are never keywords
+	Stopwords map[string]bool
+	// Stem reduces English words to a light stem
+	Stem bool
+
+	// hanStopwordRunes is the rune length of the longest Han stopword
+	hanStopwordRunes int
+}
+
+// defaultNormalizer drops the built-in stopwords without stemming
+var defaultNormalizer = NewNormalizer(DefaultStopwords(), false)
+
+// Default returns the normalizer dropping the built-in stopwords without stemming.
+func Default() *Normalizer {
+	return defaultNormalizer
+}
+
+// DefaultStopwords returns the built-in English and Chinese stopwords.
+func DefaultStopwords() []string {
+	return append(append([]string{}, englishStopwords...), chineseStopwords...)
+}
+
+// New creates the normalizer configured by cfg. Without a stopwords file it uses the
+// built-in stopwords; a nil cfg returns Default.
+func New(cfg *config.KeywordConfig) (*Normalizer, error) {
+	if cfg == nil {
+		return Default(), nil
+	}
+	words := DefaultStopwords()
+	if cfg.StopwordsFile != "" {
+		loaded, err := LoadStopwords(cfg.StopwordsFile)
+		if err != nil {
+			return nil, err
+		}
+		words = loaded
+	}
+	return NewNormalizer(append(words, cfg.Stopwords...), cfg.Stem), nil
+}
+
+// NewNormalizer creates a normalizer dropping words
+func NewNormalizer(words []string, stem bool) *Normalizer {
+	n := &Normalizer{Stopwords: make(map[string]bool, len(words)), Stem: stem}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" {
+			continue
+		}
+		n.Stopwords[word] = true
+		if isHan(word) {
+			n.hanStopwordRunes = max(n.hanStopwordRunes, utf8.RuneCountInString(word))
+		}
+	}
+	return n
+}
+
+// LoadStopwords reads a stopword file: one word per line, blank lines and lines starting
+// with '#' are skipped.
+func LoadStopwords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open stopwords file: %w", err)
+	}
+	defer f.Close()
+	words := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stopwords file: %w", err)
+	}
+	return words, nil
+}
+
+// Keywords returns the distinct keywords of text in order of appearance. Words are runs of
+// letters and digits; runs of Han characters are split at Han stopwords instead, as Chinese
+// is not written with spaces. Other words shorter than minLength runes are dropped, while
+// Han terms are kept at any length since a single character can carry a whole word.
+func (n *Normalizer) Keywords(text string, minLength int) []string {
+	keywords := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(term string) {
+		if term != "" && !seen[term] {
+			seen[term] = true
+			keywords = append(keywords, term)
+		}
+	}
+	for _, word := range words(strings.ToLower(text)) {
+		if isHan(word) {
+			for _, term := range n.splitHan(word) {
+				add(term)
+			}
+			continue
+		}
+		if n.Stopwords[word] || utf8.RuneCountInString(word) < minLength {
+			continue
+		}
+		if n.Stem {
+			word = Stem(word)
+		}
+		add(word)
+	}
+	return keywords
+}
+
+// englishWord matches the words Stem applies to in lowercased text
+var englishWord = regexp.MustCompile(`[a-z]+`)
+
+// Normalize lowercases text and, with stemming, stems its English words in place, so
+// that a keyword is found in the normalized text whichever inflection the text uses.
+func (n *Normalizer) Normalize(text string) string {
+	text = strings.ToLower(text)
+	if !n.Stem {
+		return text
+	}
+	return englishWord.ReplaceAllStringFunc(text, Stem)
+}
+
+// splitHan splits a run of Han characters at its stopwords, longest stopword first
+func (n *Normalizer) splitHan(run string) []string {
+	runes := []rune(run)
+	terms := make([]string, 0, 1)
+	start := 0
+	for i := 0; i < len(runes); {
+		size := 0
+		for l := min(n.hanStopwordRunes, len(runes)-i); l > 0; l-- {
+			if n.Stopwords[string(runes[i:i+l])] {
+				size = l
+				break
+			}
+		}
+		if size == 0 {
+			i++
+			continue
+		}
+		if i > start {
+			terms = append(terms, string(runes[start:i]))
+		}
+		i += size
+		start = i
+	}
+	if start < len(runes) {
+		terms = append(terms, string(runes[start:]))
+	}
+	return terms
+}
+
+// words splits text into runs of Han characters and runs of other letters and digits
+func words(text string) []string {
+	out := make([]string, 0)
+	start := -1
+	han := false
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if start >= 0 && (!word || unicode.Is(unicode.Han, r) != han) {
+			out = append(out, text[start:i])
+			start = -1
+		}
+		if word && start < 0 {
+			start = i
+			han = unicode.Is(unicode.Han, r)
+		}
+	}
+	if start >= 0 {
+		out = append(out, text[start:])
+	}
+	return out
+}
+
+// isHan reports whether word is written in Han characters
+func isHan(word string) bool {
+	r, _ := utf8.DecodeRuneInString(word)
+	return unicode.Is(unicode.Han, r)
+}
+
+// Stem reduces a lowercased English word to a light stem by stripping common plural and
+// verb suffixes, so that e.g. "routes", "routed" and "routing" all become "rout". Stems
+// are only meant to be compared with each other.
+func Stem(word string) string {
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "xes") || strings.HasSuffix(word, "ches") || strings.HasSuffix(word, "shes"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && len(word) > 3:
+		word = word[:len(word)-1]
+	}
+	for _, suffix := range []string{"ing", "ed"} {
+		if stem := strings.TrimSuffix(word, suffix); stem != word && len(stem) >= 3 && strings.ContainsAny(stem, "aeiouy") {
+			word = stem
+			break
+		}
+	}
+	if strings.HasSuffix(word, "y") && len(word) > 3 {
+		word = word[:len(word)-1] + "i"
+	}
+	if strings.HasSuffix(word, "e") && len(word) > 4 {
+		word = word[:len(word)-1]
+	}
+	return word
+}
@@ -0,0 +1,76 @@
+package keywords
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+func TestKeywords(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		minLength int
+		stem      bool
+		want      []string
+	}{
+		{"english stopwords", "What is the Higress gateway and how does it route?", 1, false, []string{"higress", "gateway", "route"}},
+		{"min length in runes", "use a wasm plugin in v2", 3, false, []string{"use", "wasm", "plugin"}},
+		{"han kept at any length", "网关的路由", 4, false, []string{"网关", "路由"}},
+		{"han split at stopwords", "如何配置Higress网关吗", 3, false, []string{"配置", "higress", "网关"}},
+		{"duplicates dropped", "routes routing routed", 1, true, []string{"rout"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewNormalizer(DefaultStopwords(), tt.stem).Keywords(tt.text, tt.minLength)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Keywords(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStem(t *testing.T) {
+	for word, want := range map[string]string{
+		"policies": "polici", "policy": "polici", "routes": "rout", "routing": "rout",
+		"boxes": "box", "classes": "class", "status": "status", "gateways": "gatewai",
+		"configured": "configur", "configure": "configur", "need": "need",
+	} {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	n := NewNormalizer(nil, true)
+	if got := n.Normalize("Routing Policies 路由"); got != "rout polici 路由" {
+		t.Errorf("Normalize() = %q", got)
+	}
+	if got := Default().Normalize("Routing"); got != "routing" {
+		t.Errorf("Normalize() without stemming = %q", got)
+	}
+}
+
+func TestNew_StopwordsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stopwords.txt")
+	if err := os.WriteFile(path, []byte("# custom\nhigress\n\n网关\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	n, err := New(&config.KeywordConfig{StopwordsFile: path, Stopwords: []string{"Gateway"}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	// The file replaces the built-in stopwords, so "the" is a keyword again
+	want := []string{"the", "route", "配置"}
+	if got := n.Keywords("the Higress gateway route 网关配置", 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("Keywords() = %v, want %v", got, want)
+	}
+
+	if _, err := New(&config.KeywordConfig{StopwordsFile: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("New() with a missing stopwords file should fail")
+	}
+}
@@ -17,37 +17,94 @@ type RAGConfig struct {
 	Splitter  SplitterConfig `json:"splitter" yaml:"splitter"`
 	Threshold float64        `json:"threshold,omitempty" yaml:"threshold,omitempty"`
 	TopK      int            `json:"top_k,omitempty" yaml:"top_k,omitempty"`
+	// NamespaceHeader names the request header, e.g. set by the gateway after authentication,
+	// that scopes tool calls to a knowledge-base namespace; it takes precedence over the
+	// namespace tool argument
+	NamespaceHeader string `json:"namespace_header,omitempty" yaml:"namespace_header,omitempty"`
+	// NoContextMode decides what chat does when retrieval finds no document above the
+	// threshold: "answer" (default) lets the LLM answer anyway, "fail_closed" returns a
+	// NoContextError instead of an ungrounded answer
+	NoContextMode string `json:"no_context_mode,omitempty" yaml:"no_context_mode,omitempty"`
 }
 
+// Values of RAGConfig.NoContextMode
+const (
+	NO_CONTEXT_MODE_ANSWER      = "answer"
+	NO_CONTEXT_MODE_FAIL_CLOSED = "fail_closed"
+)
+
 // SplitterConfig defines document splitter configuration
 type SplitterConfig struct {
-	Provider     string `json:"provider" yaml:"provider"` // Available options: recursive, character, token
+	Provider     string `json:"provider" yaml:"provider"` // Available options: recursive, code, html, sentence, nosplitter
 	ChunkSize    int    `json:"chunk_size,omitempty" yaml:"chunk_size,omitempty"`
 	ChunkOverlap int    `json:"chunk_overlap,omitempty" yaml:"chunk_overlap,omitempty"`
+	// Language of the source code for the code splitter: go, python, java, javascript, typescript
+	Language string `json:"language,omitempty" yaml:"language,omitempty"`
+	// MetadataExtractor adds location metadata to every chunk; "markdown" records the nearest
+	// heading, the section path and the character offset. Empty disables it.
+	MetadataExtractor string `json:"metadata_extractor,omitempty" yaml:"metadata_extractor,omitempty"`
+	// ParentChunkSize enables hierarchical chunking: text is first cut into parent sections
+	// of this size, which are then split into the chunks that are embedded and searched.
+	// Every chunk keeps its section in the parent_id and parent_content metadata so
+	// retrieval can expand a hit to it. Must exceed ChunkSize (0 => disabled)
+	ParentChunkSize int `json:"parent_chunk_size,omitempty" yaml:"parent_chunk_size,omitempty"`
 }
 
 // LLMConfig defines configuration for Large Language Models
 type LLMConfig struct {
-	Provider    string  `json:"provider" yaml:"provider"` // Available options: openai, dashscope, qwen
+	Provider    string  `json:"provider" yaml:"provider"` // Available options: openai, ollama, dashscope, qwen
 	APIKey      string  `json:"api_key,omitempty" yaml:"api_key"`
 	BaseURL     string  `json:"base_url,omitempty" yaml:"base_url,omitempty"`
 	Model       string  `json:"model" yaml:"model"`
 	Temperature float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
 	MaxTokens   int     `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	// PromptTemplate is a Go text/template for the answer prompt, executed with
+	// .Query and .Contexts (each with .Index, .Title, .Content). Empty uses the built-in template.
+	PromptTemplate string `json:"prompt_template,omitempty" yaml:"prompt_template,omitempty"`
+	// Headers are added to every request of the openai provider, e.g. X-Api-Key for an
+	// OpenAI-compatible gateway; Authorization and Content-Type cannot be replaced
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// MaxRetries retries calls failing with a 429, a 5xx or a timeout, with exponential
+	// backoff and jitter, within the caller's deadline (0 => no retry)
+	MaxRetries int `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
+	// TimeoutMs bounds every non-streaming call attempt (0 => bounded by the caller only)
+	TimeoutMs int `json:"timeout_ms,omitempty" yaml:"timeout_ms,omitempty"`
+	// MaxContextTokens caps the estimated tokens of the answer prompt (0 => the model's known
+	// context window less MaxTokens; no limit for unknown models)
+	MaxContextTokens int `json:"max_context_tokens,omitempty" yaml:"max_context_tokens,omitempty"`
+	// ContextOverflow handles a prompt over the limit: "drop" (default) drops the
+	// lowest-scored contexts, "compress" trims the contexts to the budget first
+	ContextOverflow string `json:"context_overflow,omitempty" yaml:"context_overflow,omitempty"`
 }
 
+// Context overflow strategies of LLMConfig.ContextOverflow
+const (
+	CONTEXT_OVERFLOW_DROP     = "drop"
+	CONTEXT_OVERFLOW_COMPRESS = "compress"
+)
+
 // EmbeddingConfig defines configuration for embedding models
 type EmbeddingConfig struct {
-	Provider   string `json:"provider" yaml:"provider"` // Available options: openai, dashscope
+	Provider   string `json:"provider" yaml:"provider"` // Available options: openai, dashscope, cohere, http
 	APIKey     string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
-	BaseURL    string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	BaseURL    string `json:"base_url,omitempty" yaml:"base_url,omitempty"` // Full endpoint URL for the http provider
 	Model      string `json:"model,omitempty" yaml:"model,omitempty"`
 	Dimensions int    `json:"dimensions,omitempty" yaml:"dimension,omitempty"`
+	InputType  string `json:"input_type,omitempty" yaml:"input_type,omitempty"` // Cohere input_type, default search_document
+	Kind       string `json:"kind,omitempty" yaml:"kind,omitempty"`             // dense (default) or sparse
+	// MaxBatchSize caps the texts sent in one embedding request; 0 uses the provider default
+	MaxBatchSize int `json:"max_batch_size,omitempty" yaml:"max_batch_size,omitempty"`
+	// Headers are added to every request of the openai provider, e.g. X-Api-Key for an
+	// OpenAI-compatible gateway; Authorization and Content-Type cannot be replaced
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Cache 缓存已向量化文本的向量，避免重复调用 embedding 服务：进程内 LRU（max_entries、
+	// ttl_seconds），store 为 redis 时同时写入 Redis 供多个实例共享
+	Cache *CacheLayerConfig `json:"cache,omitempty" yaml:"cache,omitempty"`
 }
 
 // VectorDBConfig defines configuration for vector databases
 type VectorDBConfig struct {
-	Provider   string        `json:"provider" yaml:"provider"` // Available options: milvus, qdrant, chroma
+	Provider   string        `json:"provider" yaml:"provider"` // Available options: milvus, weaviate, qdrant, chroma
 	Host       string        `json:"host,omitempty" yaml:"host,omitempty"`
 	Port       int           `json:"port,omitempty" yaml:"port,omitempty"`
 	Database   string        `json:"database,omitempty" yaml:"database,omitempty"`
@@ -55,6 +112,15 @@ type VectorDBConfig struct {
 	Username   string        `json:"username,omitempty" yaml:"username,omitempty"`
 	Password   string        `json:"password,omitempty" yaml:"password,omitempty"`
 	Mapping    MappingConfig `json:"mapping,omitempty" yaml:"mapping,omitempty"`
+	// EnableSparse adds a sparse vector field to the collection so a sparse retriever can
+	// search it next to the dense vectors (milvus only)
+	EnableSparse bool `json:"enable_sparse,omitempty" yaml:"enable_sparse,omitempty"`
+	// PingOnStart checks that the store is reachable and the collection exists while the
+	// server starts, failing startup otherwise
+	PingOnStart bool `json:"ping_on_start,omitempty" yaml:"ping_on_start,omitempty"`
+	// GRPCPort switches batch inserts to the gRPC API on that port, e.g. 50051 (weaviate
+	// only); other requests keep using REST
+	GRPCPort int `json:"grpc_port,omitempty" yaml:"grpc_port,omitempty"`
 }
 
 // MappingConfig defines field mapping configuration for vector databases
@@ -78,23 +144,29 @@ type FieldMapping struct {
 
 
 type PreRetrieveConfig struct {
-	Provider  string                 `json:"provider" yaml:"provider"`
-	TimeOutMS int                    `json:"time_out_ms" yaml:"time_out_ms"`
-	LLM       LLMConfig              `json:"llm" yaml:"llm"` // LLM 配置用于查询改写
-	Memory    MemoryConfig           `json:"memory" yaml:"memory"`
-	Alignment ContextAlignmentConfig `json:"alignment" yaml:"alignment"`
-	Planning  PreQRAGPlanningConfig  `json:"planning" yaml:"planning"`
-	Expansion ExpansionConfig        `json:"expansion" yaml:"expansion"`
-	HyDE      HyDEConfig             `json:"hyde" yaml:"hyde"`
+	Provider    string                 `json:"provider" yaml:"provider"`
+	TimeOutMS   int                    `json:"time_out_ms" yaml:"time_out_ms"`
+	LLM         LLMConfig              `json:"llm" yaml:"llm"` // LLM 配置用于查询改写
+	Memory      MemoryConfig           `json:"memory" yaml:"memory"`
+	Alignment   ContextAlignmentConfig `json:"alignment" yaml:"alignment"`
+	Planning    PreQRAGPlanningConfig  `json:"planning" yaml:"planning"`
+	Expansion   ExpansionConfig        `json:"expansion" yaml:"expansion"`
+	HyDE        HyDEConfig             `json:"hyde" yaml:"hyde"`
+	Translation TranslationConfig      `json:"translation" yaml:"translation"`
+	// Prompts 来自 pipeline.prompts，覆盖各阶段的提示词模板
+	Prompts *PromptsConfig `json:"-" yaml:"-"`
+	// Embedding 来自全局 embedding 配置，HyDE 用其向量化假设文档，以便在同一向量空间中检索
+	Embedding EmbeddingConfig `json:"-" yaml:"-"`
 }
 
 // MemoryConfig 定义记忆采集配置
 type MemoryConfig struct {
-	Enabled        bool `json:"enabled" yaml:"enabled"`
-	LastNRounds    int  `json:"last_n_rounds" yaml:"last_n_rounds"`     // 最近 N 轮对话
-	EnableDocIDs   bool `json:"enable_doc_ids" yaml:"enable_doc_ids"`   // 是否启用文档 ID
-	EnableSession  bool `json:"enable_session" yaml:"enable_session"`   // 是否启用会话记忆
-	EnableExternal bool `json:"enable_external" yaml:"enable_external"` // 是否启用外部记忆
+	Enabled           bool `json:"enabled" yaml:"enabled"`
+	LastNRounds       int  `json:"last_n_rounds" yaml:"last_n_rounds"`                                 // 最近 N 轮对话
+	EnableDocIDs      bool `json:"enable_doc_ids" yaml:"enable_doc_ids"`                               // 是否启用文档 ID
+	EnableSession     bool `json:"enable_session" yaml:"enable_session"`                               // 是否启用会话记忆
+	EnableExternal    bool `json:"enable_external" yaml:"enable_external"`                             // 是否启用外部记忆
+	SessionTTLSeconds int  `json:"session_ttl_seconds,omitempty" yaml:"session_ttl_seconds,omitempty"` // 会话多久未读写后过期，0 表示不过期
 }
 
 // ContextAlignmentConfig 定义上下文对齐配置
@@ -105,6 +177,7 @@ type ContextAlignmentConfig struct {
 	EnableAnchor         bool    `json:"enable_anchor" yaml:"enable_anchor"`                   // 锚点裁决
 	AnchorScoreThreshold float64 `json:"anchor_score_threshold" yaml:"anchor_score_threshold"` // 锚点分数阈值
 	MaxAnchors           int     `json:"max_anchors" yaml:"max_anchors"`                       // 最大锚点数
+	EnableEntityLLM      bool    `json:"enable_entity_llm" yaml:"enable_entity_llm"`           // LLM 辅助实体抽取
 }
 
 // PreQRAGPlanningConfig 定义 PreQRAG 规划器配置
@@ -115,24 +188,42 @@ type PreQRAGPlanningConfig struct {
 	EnableChannelRewrite   bool `json:"enable_channel_rewrite" yaml:"enable_channel_rewrite"`     // 通道感知重写
 	MaxSubQueries          int  `json:"max_sub_queries" yaml:"max_sub_queries"`                   // 最大子查询数
 	EnableCardinalityPrior bool `json:"enable_cardinality_prior" yaml:"enable_cardinality_prior"` // 单/多文档先验判定
+	CacheTTLSeconds        int  `json:"cache_ttl_seconds" yaml:"cache_ttl_seconds"`               // 先验判定与分解结果缓存时长，0 表示不缓存
+	CacheMaxEntries        int  `json:"cache_max_entries" yaml:"cache_max_entries"`               // 缓存条目上限，默认 512
+	// Keywords 来自 pipeline.keywords，设置后稀疏改写只保留其中提取的关键词
+	Keywords *KeywordConfig `json:"-" yaml:"-"`
 }
 
 // ExpansionConfig 定义扩写配置
 type ExpansionConfig struct {
-	Enabled          bool `json:"enabled" yaml:"enabled"`
-	MaxTerms         int  `json:"max_terms" yaml:"max_terms"`                 // 最大扩展词数
-	EnableTaxonomy   bool `json:"enable_taxonomy" yaml:"enable_taxonomy"`     // 域内分类
-	EnableSynonyms   bool `json:"enable_synonyms" yaml:"enable_synonyms"`     // 同义词
-	EnableAttributes bool `json:"enable_attributes" yaml:"enable_attributes"` // 属性对
+	Enabled          bool    `json:"enabled" yaml:"enabled"`
+	MaxTerms         int     `json:"max_terms" yaml:"max_terms"`                 // 最大扩展词数
+	EnableTaxonomy   bool    `json:"enable_taxonomy" yaml:"enable_taxonomy"`     // 域内分类
+	EnableSynonyms   bool    `json:"enable_synonyms" yaml:"enable_synonyms"`     // 同义词
+	EnableAttributes bool    `json:"enable_attributes" yaml:"enable_attributes"` // 属性对
+	MinWeight        float64 `json:"min_weight" yaml:"min_weight"`               // 归一化后最小权重，默认 0.1
+	MaxWeight        float64 `json:"max_weight" yaml:"max_weight"`               // 归一化后最大权重，默认 1.0
+	MergeStrategy    string  `json:"merge_strategy" yaml:"merge_strategy"`       // 重复词项合并方式：max（默认）或 sum
 }
 
 // HyDEConfig 定义 HyDE (Hypothetical Document Embeddings) 配置
 type HyDEConfig struct {
-	Enabled               bool `json:"enabled" yaml:"enabled"`
-	MinQueryLength        int  `json:"min_query_length" yaml:"min_query_length"`               // 最小查询长度
-	GeneratedDocLength    int  `json:"generated_doc_length" yaml:"generated_doc_length"`       // 生成文档长度
-	EnablePerplexityCheck bool `json:"enable_perplexity_check" yaml:"enable_perplexity_check"` // 困惑度检查
-	EnableNLIGuardrail    bool `json:"enable_nli_guardrail" yaml:"enable_nli_guardrail"`       // NLI 护栏
+	Enabled               bool    `json:"enabled" yaml:"enabled"`
+	MinQueryLength        int     `json:"min_query_length" yaml:"min_query_length"`                           // 最小查询长度
+	GeneratedDocLength    int     `json:"generated_doc_length" yaml:"generated_doc_length"`                   // 生成文档长度
+	EnablePerplexityCheck bool    `json:"enable_perplexity_check" yaml:"enable_perplexity_check"`             // 困惑度检查
+	EnableNLIGuardrail    bool    `json:"enable_nli_guardrail" yaml:"enable_nli_guardrail"`                   // NLI 护栏
+	PerplexityEndpoint    string  `json:"perplexity_endpoint,omitempty" yaml:"perplexity_endpoint,omitempty"` // 困惑度打分服务，未配置时使用启发式规则
+	MaxPerplexity         float64 `json:"max_perplexity,omitempty" yaml:"max_perplexity,omitempty"`           // 困惑度上限，默认 50
+	NLIEndpoint           string  `json:"nli_endpoint,omitempty" yaml:"nli_endpoint,omitempty"`               // NLI 打分服务，未配置时使用启发式规则
+	MinEntailment         float64 `json:"min_entailment,omitempty" yaml:"min_entailment,omitempty"`           // 蕴含概率下限，默认 0.5
+	MinQualityScore       float64 `json:"min_quality_score,omitempty" yaml:"min_quality_score,omitempty"`     // 假设文档质量分数下限（0-1），0 表示不限制
+}
+
+// TranslationConfig 定义查询语言检测与翻译配置
+type TranslationConfig struct {
+	Enabled        bool   `json:"enabled" yaml:"enabled"`
+	TargetLanguage string `json:"target_language" yaml:"target_language"` // 语料语言（如 en、zh），查询语言不同时翻译为该语言
 }
 
 func (f FieldMapping) IsPrimaryKey() bool {
@@ -209,8 +300,11 @@ func (i IndexConfig) ParamsBool(key string) (bool, error) {
 
 // SearchConfig defines configuration for search parameters
 type SearchConfig struct {
-	// Metric type, e.g., L2, IP, etc.
+	// Metric type, e.g., L2, IP, COSINE, etc.
 	MetricType string `json:"metric_type,omitempty" yaml:"metric_type,omitempty"`
+	// Normalize L2-normalizes embeddings before they are stored or searched when MetricType
+	// is COSINE, for models that produce unnormalized vectors
+	Normalize bool `json:"normalize,omitempty" yaml:"normalize,omitempty"`
 	// Search parameter configuration
 	Params map[string]interface{} `json:"params" yaml:"params"`
 }
@@ -37,6 +37,71 @@ type PipelineConfig struct {
 	Feedback *FeedbackConfig `json:"feedback,omitempty" yaml:"feedback,omitempty"`
 	// Cache controls L1 caching of retrieval results.
 	Cache *CacheConfig `json:"cache,omitempty" yaml:"cache,omitempty"`
+	// Metrics exposes aggregated retrieval metrics over HTTP.
+	Metrics *MetricsConfig `json:"metrics,omitempty" yaml:"metrics,omitempty"`
+	// Keywords controls keyword extraction for the keyword reranker and sparse rewrites.
+	Keywords *KeywordConfig `json:"keywords,omitempty" yaml:"keywords,omitempty"`
+	// Prompts overrides the prompt templates of the LLM stages.
+	Prompts *PromptsConfig `json:"prompts,omitempty" yaml:"prompts,omitempty"`
+	// Language detects the query language and routes to the profiles serving it.
+	Language *LanguageConfig `json:"language,omitempty" yaml:"language,omitempty"`
+	// Timeouts bounds the time of the retrieval, rerank, compress and CRAG stages.
+	Timeouts *StageTimeoutsConfig `json:"timeouts,omitempty" yaml:"timeouts,omitempty"`
+}
+
+// StageTimeoutsConfig sets a deadline per pipeline stage in milliseconds (0 => none). When
+// a stage's deadline passes, the pipeline logs the timeout and continues with the best
+// results it has: retrieval fuses the retrievers that answered in time, while rerank,
+// compress and CRAG are skipped, keeping the results they were given.
+type StageTimeoutsConfig struct {
+	RetrievalMs int `json:"retrieval_ms,omitempty" yaml:"retrieval_ms,omitempty"`
+	RerankMs    int `json:"rerank_ms,omitempty" yaml:"rerank_ms,omitempty"`
+	CompressMs  int `json:"compress_ms,omitempty" yaml:"compress_ms,omitempty"`
+	CRAGMs      int `json:"crag_ms,omitempty" yaml:"crag_ms,omitempty"`
+}
+
+// LanguageConfig enables language routing: the language of each query is detected from its
+// script (en, zh, ja, ko or ru), a query whose language the selected profile does not list
+// in Languages switches to the first profile that does, and router rules can match on it.
+// A profile chosen this way also brings its embedding override.
+type LanguageConfig struct {
+	Enable bool `json:"enable,omitempty" yaml:"enable,omitempty"`
+	// Fallback is the language of queries without recognizable letters, e.g. only numbers
+	// or symbols (empty => such queries are not routed by language)
+	Fallback string `json:"fallback,omitempty" yaml:"fallback,omitempty"`
+}
+
+// PromptsConfig overrides the built-in prompts of the LLM stages (query rewriting, HyDE,
+// rerank, compression, CRAG). Templates are Go text/templates looked up by stage name;
+// stages without an override keep their built-in prompt.
+type PromptsConfig struct {
+	// Dir holds one "<name>.tmpl" file per overridden template
+	Dir string `json:"dir,omitempty" yaml:"dir,omitempty"`
+	// Templates maps template names to templates, taking precedence over Dir
+	Templates map[string]string `json:"templates,omitempty" yaml:"templates,omitempty"`
+}
+
+// KeywordConfig controls how keywords are extracted from queries by the keyword reranker
+// and for the sparse rewrites of the pre-retrieve planner. Without it the keyword reranker
+// drops the built-in English and Chinese stopwords and the planner extracts no keywords.
+type KeywordConfig struct {
+	// StopwordsFile replaces the built-in stopwords with the words of the file, one per
+	// line; lines starting with '#' are comments
+	StopwordsFile string `json:"stopwords_file,omitempty" yaml:"stopwords_file,omitempty"`
+	// Stopwords are added to the built-in or file stopwords
+	Stopwords []string `json:"stopwords,omitempty" yaml:"stopwords,omitempty"`
+	// Stem reduces English words to a light stem, e.g. "routes" and "routing" to "rout"
+	Stem bool `json:"stem,omitempty" yaml:"stem,omitempty"`
+}
+
+// MetricsConfig configures the aggregated retrieval metrics endpoint. When Addr is set,
+// the Prometheus exposition format is served at /metrics and JSON at /metrics/json.
+// Rebuilding the server on a config change replaces the endpoint of the previous build.
+type MetricsConfig struct {
+	// Addr is the listen address, e.g. ":9091"; empty disables the endpoint
+	Addr string `json:"addr,omitempty" yaml:"addr,omitempty"`
+	// LatencyWindow is the number of most recent queries latency percentiles cover (default 1000)
+	LatencyWindow int `json:"latency_window,omitempty" yaml:"latency_window,omitempty"`
 }
 
 type PreConfig struct {
@@ -58,6 +123,8 @@ type PreConfig struct {
 		Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
 		// Endpoint: host:port for grpc, or full URL for http
 		Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+		// TimeoutMs bounds each call; 0 uses pipeline.http.timeout_ms
+		TimeoutMs int `json:"timeout_ms,omitempty" yaml:"timeout_ms,omitempty"`
 	} `json:"service" yaml:"service"`
 }
 
@@ -68,6 +135,8 @@ type RetrieverConfig struct {
 	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
 	// Arbitrary key/values for the provider implementation, e.g., endpoints/index/collection.
 	Params map[string]string `json:"params,omitempty" yaml:"params,omitempty"`
+	// Embedding configures a retriever-specific embedding model, e.g. kind "sparse" for the sparse retriever.
+	Embedding *EmbeddingConfig `json:"embedding,omitempty" yaml:"embedding,omitempty"`
 }
 
 // RetrievalProfile describes a strategy for a specific intent or query class.
@@ -79,8 +148,12 @@ type RetrievalProfile struct {
 	Threshold       float64  `json:"threshold,omitempty" yaml:"threshold,omitempty"`
 	UseWeb          bool     `json:"use_web,omitempty" yaml:"use_web,omitempty"`
 	LatencyBudgetMs int      `json:"latency_budget_ms,omitempty" yaml:"latency_budget_ms,omitempty"`
-	// MaxFanout caps concurrent retriever fan-out for this profile (0 => no cap)
+	// MaxFanout caps the searches (queries x retrievers) of a request by dropping the
+	// trailing queries (0 => no cap)
 	MaxFanout int `json:"max_fanout,omitempty" yaml:"max_fanout,omitempty"`
+	// MaxConcurrency caps the searches running at the same time; the others wait for a free
+	// slot instead of being dropped (0 => no cap)
+	MaxConcurrency int `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty"`
 	// VectorGate: if vector Top1 score >= this threshold, skip web retriever
 	VectorGate float64 `json:"vector_gate,omitempty" yaml:"vector_gate,omitempty"`
 	// VectorLowGate: if vector Top1 score < this threshold, force-enable web retriever (if available)
@@ -92,6 +165,59 @@ type RetrievalProfile struct {
 	Cascade          CascadeConfig  `json:"cascade,omitempty" yaml:"cascade,omitempty"`
 	HYDE             HYDEConfig     `json:"hyde,omitempty" yaml:"hyde,omitempty"`
 	VariantBudgets   map[string]int `json:"variant_budgets,omitempty" yaml:"variant_budgets,omitempty"`
+	// MinSuccessfulRetrievers: fewer retrievers succeeding fails retrieval instead of
+	// returning partial results; 0 => tolerate any number of failures
+	MinSuccessfulRetrievers int `json:"min_successful_retrievers,omitempty" yaml:"min_successful_retrievers,omitempty"`
+	// Embedding overrides the model the vector retriever embeds queries with under this
+	// profile, e.g. a multilingual model for one intent. Without a provider, or with the
+	// global one, unset fields are taken from the global embedding. The model must output
+	// vectors of the collection's dimensions (nil => global embedding)
+	Embedding *EmbeddingConfig `json:"embedding,omitempty" yaml:"embedding,omitempty"`
+	// AutoTopK truncates the fused results at the largest score drop-off instead of TopK
+	AutoTopK AutoTopKConfig `json:"auto_top_k,omitempty" yaml:"auto_top_k,omitempty"`
+	// MinResults is the fewest results a query should return: when fewer pass the threshold,
+	// retrieval is relaxed as configured by Backfill to top them up (0 => disabled)
+	MinResults int `json:"min_results,omitempty" yaml:"min_results,omitempty"`
+	// Backfill configures how retrieval is relaxed to reach MinResults
+	Backfill BackfillConfig `json:"backfill,omitempty" yaml:"backfill,omitempty"`
+	// Languages are the query languages the profile serves, e.g. ["zh"] for a profile over a
+	// Chinese collection; used when pipeline.language is enabled
+	Languages []string `json:"languages,omitempty" yaml:"languages,omitempty"`
+	// RetrieverThresholds drops the results of a retriever type scoring below its threshold
+	// before fusion, e.g. {"vector": 0.7, "web": 0}, as the score scales of the retrievers
+	// differ. Threshold still gates the fused results (nil => no per-retriever filter)
+	RetrieverThresholds map[string]float64 `json:"retriever_thresholds,omitempty" yaml:"retriever_thresholds,omitempty"`
+	// ExpandParents replaces the hits of hierarchical chunks with their parent sections after
+	// reranking, keeping one result per parent at the rank of its best hit. TopK then counts
+	// parents: more chunks are retrieved so that enough distinct parents remain
+	ExpandParents bool `json:"expand_parents,omitempty" yaml:"expand_parents,omitempty"`
+}
+
+// BackfillConfig relaxes retrieval when fewer than MinResults results pass the threshold.
+// The threshold is lowered step by step first; when that is not enough, the extra
+// Retrievers are searched as well. Results found this way are appended after the ones
+// that passed the original threshold, up to MinResults.
+type BackfillConfig struct {
+	// ThresholdStep is how much the threshold is lowered per step (0 => to 0 at once)
+	ThresholdStep float64 `json:"threshold_step,omitempty" yaml:"threshold_step,omitempty"`
+	// MaxSteps caps the threshold steps (0 => until the threshold reaches 0)
+	MaxSteps int `json:"max_steps,omitempty" yaml:"max_steps,omitempty"`
+	// Retrievers are searched in addition when lowering the threshold is not enough,
+	// e.g. ["web"] or ["bm25"]; retrievers the profile already uses are skipped
+	Retrievers []string `json:"retrievers,omitempty" yaml:"retrievers,omitempty"`
+}
+
+// AutoTopKConfig picks the number of results per query: after fusion, the results are cut
+// at the largest relative score drop between neighbours among ranks MinK to MaxK. A drop
+// smaller than GapThreshold (relative to the higher score) is no elbow, and TopK is used.
+type AutoTopKConfig struct {
+	Enable bool `json:"enable,omitempty" yaml:"enable,omitempty"`
+	// MinK is the fewest results kept (0 => 1)
+	MinK int `json:"min_k,omitempty" yaml:"min_k,omitempty"`
+	// MaxK is the most results kept (0 => the profile's TopK)
+	MaxK int `json:"max_k,omitempty" yaml:"max_k,omitempty"`
+	// GapThreshold is the relative drop, in (0, 1], that marks an elbow (0 => 0.2)
+	GapThreshold float64 `json:"gap_threshold,omitempty" yaml:"gap_threshold,omitempty"`
 }
 
 type CascadeConfig struct {
@@ -104,7 +230,10 @@ type CascadeConfig struct {
 type CascadeStageConfig struct {
 	Retriever string `json:"retriever,omitempty" yaml:"retriever,omitempty"`
 	TopK      int    `json:"top_k,omitempty" yaml:"top_k,omitempty"`
-	Mode      string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// Mode of stage2: rescore (default) keeps stage2 hits also found by stage1, refine keeps
+	// all stage2 hits, rerank reorders the stage1 hits with the post.rerank provider instead
+	// of running a retriever
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
 }
 
 type HYDEConfig struct {
@@ -136,6 +265,26 @@ type FeedbackAdjustments struct {
 
 type CacheConfig struct {
 	L1 *CacheLayerConfig `json:"l1,omitempty" yaml:"l1,omitempty"`
+	// L2 is a Redis cache shared by gateway instances and consulted on an L1 miss.
+	// Store must be "redis"; TTLSeconds defaults to 120.
+	L2 *CacheLayerConfig `json:"l2,omitempty" yaml:"l2,omitempty"`
+	// Gating caches vector preflight decisions per normalized query so repeated queries
+	// skip the preflight search. TTLSeconds defaults to 30 and MaxEntries to 1000.
+	Gating *CacheLayerConfig `json:"gating,omitempty" yaml:"gating,omitempty"`
+	// Semantic lets an L1 or L2 miss be served by the entry of a similar earlier query
+	Semantic *SemanticCacheConfig `json:"semantic,omitempty" yaml:"semantic,omitempty"`
+}
+
+// SemanticCacheConfig looks a query missing the retrieval cache up by meaning: the query is
+// embedded and the cached query of the same profile and options with the highest cosine
+// similarity, if at least Threshold (default 0.95), serves the request. MaxEntries caps the
+// cached queries of one profile compared in L2 (default 100). With CacheAnswer, chat also
+// caches the generated answer with the results and a hit skips the LLM.
+type SemanticCacheConfig struct {
+	Enable      bool    `json:"enable,omitempty" yaml:"enable,omitempty"`
+	Threshold   float64 `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+	MaxEntries  int     `json:"max_entries,omitempty" yaml:"max_entries,omitempty"`
+	CacheAnswer bool    `json:"cache_answer,omitempty" yaml:"cache_answer,omitempty"`
 }
 
 type CacheLayerConfig struct {
@@ -144,21 +293,39 @@ type CacheLayerConfig struct {
 	TTLSeconds int    `json:"ttl_seconds,omitempty" yaml:"ttl_seconds,omitempty"`
 	Store      string `json:"store,omitempty" yaml:"store,omitempty"`
 	Mode       string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// CacheEmpty enables negative caching of queries that return no results.
+	// Empty results expire after EmptyTTLSeconds (default 10s, capped at TTLSeconds)
+	// so newly ingested documents become visible quickly.
+	CacheEmpty      bool `json:"cache_empty,omitempty" yaml:"cache_empty,omitempty"`
+	EmptyTTLSeconds int  `json:"empty_ttl_seconds,omitempty" yaml:"empty_ttl_seconds,omitempty"`
+	// Redis is the connection config of the L2 store, in the format of session.redis
+	Redis map[string]interface{} `json:"redis,omitempty" yaml:"redis,omitempty"`
 }
 
 type PostConfig struct {
 	Rerank struct {
 		Enable   bool   `json:"enable,omitempty" yaml:"enable,omitempty"`
-		Provider string `json:"provider,omitempty" yaml:"provider,omitempty"` // "http", "llm", "keyword", "model"
+		Provider string `json:"provider,omitempty" yaml:"provider,omitempty"` // "http", "llm", "keyword", "model", "dashscope"
 		Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
 		TopN     int    `json:"top_n,omitempty" yaml:"top_n,omitempty"`
 		Model    string `json:"model,omitempty" yaml:"model,omitempty"`     // For model-based reranker
 		APIKey   string `json:"api_key,omitempty" yaml:"api_key,omitempty"` // For model-based reranker
 	} `json:"rerank" yaml:"rerank"`
+	// MMR diversifies the reranked results before compression. Lambda weighs relevance
+	// against diversity (0 uses 0.5) and TopN caps the results kept (0 keeps all).
+	MMR struct {
+		Enable bool    `json:"enable,omitempty" yaml:"enable,omitempty"`
+		Lambda float64 `json:"lambda,omitempty" yaml:"lambda,omitempty"`
+		TopN   int     `json:"top_n,omitempty" yaml:"top_n,omitempty"`
+	} `json:"mmr" yaml:"mmr"`
 	Compress struct {
 		Enable      bool    `json:"enable,omitempty" yaml:"enable,omitempty"`
 		Method      string  `json:"method,omitempty" yaml:"method,omitempty"`
 		TargetRatio float64 `json:"target_ratio,omitempty" yaml:"target_ratio,omitempty"`
+		Mode        string  `json:"mode,omitempty" yaml:"mode,omitempty"`               // truncate only: head (default), tail, head_tail
+		Concurrency int     `json:"concurrency,omitempty" yaml:"concurrency,omitempty"` // LLM methods: parallel documents (default 4)
+		TimeoutMs   int     `json:"timeout_ms,omitempty" yaml:"timeout_ms,omitempty"`   // LLM methods: per-document timeout; originals kept on expiry
+		MaxTokens   int     `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`   // token budget of all compressed results together; 0 disables
 	} `json:"compress" yaml:"compress"`
 }
 
@@ -168,21 +335,44 @@ type CRAGConfig struct {
 		Endpoint  string  `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
 		Correct   float64 `json:"correct,omitempty" yaml:"correct,omitempty"`
 		Incorrect float64 `json:"incorrect,omitempty" yaml:"incorrect,omitempty"`
+		// ThresholdsByIntent overrides correct/incorrect per intent, keyed on the router
+		// query type (e.g. "factoid") or, without a router decision, the profile name.
+		ThresholdsByIntent map[string]CRAGThresholds `json:"thresholds_by_intent,omitempty" yaml:"thresholds_by_intent,omitempty"`
 	} `json:"evaluator" yaml:"evaluator"`
 	// Strict mode: if true, external evaluator is required and no heuristic fallback is allowed.
 	Strict bool `json:"strict,omitempty" yaml:"strict,omitempty"`
 	// FailMode controls behavior when evaluator fails: "open" (default) keeps fused results, "closed" returns error.
 	FailMode string `json:"fail_mode,omitempty" yaml:"fail_mode,omitempty"`
 	MaxIters int    `json:"max_iters,omitempty" yaml:"max_iters,omitempty"`
+	// RewriteVariants is the number of diverse query rewrites searched on the web (max 5, default 1).
+	RewriteVariants int `json:"rewrite_variants,omitempty" yaml:"rewrite_variants,omitempty"`
+	// MaxWebCalls caps the web searches issued per corrective action (default 3).
+	MaxWebCalls int `json:"max_web_calls,omitempty" yaml:"max_web_calls,omitempty"`
+	// MaxCombinedResults caps the de-duplicated internal+web results of an ambiguous verdict (default 10).
+	MaxCombinedResults int `json:"max_combined_results,omitempty" yaml:"max_combined_results,omitempty"`
+}
+
+// CRAGThresholds are per-intent CRAG verdict thresholds; zero values inherit the global ones.
+type CRAGThresholds struct {
+	Correct   float64 `json:"correct,omitempty" yaml:"correct,omitempty"`
+	Incorrect float64 `json:"incorrect,omitempty" yaml:"incorrect,omitempty"`
 }
 
 // SessionConfig controls session persistence.
 // Store: "inmemory" (default) or "redis".
 // Redis: map with keys {address,username,password,db,secret}
+// PoolSize bounds the connections of the Redis client's pool (default 4);
+// ReconnectBackoffMs is the initial delay before retrying a command that failed with a
+// network error, doubled on each retry (default 100).
+// MaxTokens caps the estimated tokens of a session's history; the oldest messages are
+// dropped before persisting once it is exceeded (0 keeps everything).
 type SessionConfig struct {
-	Store      string                 `json:"store,omitempty" yaml:"store,omitempty"`
-	TTLSeconds int                    `json:"ttl_seconds,omitempty" yaml:"ttl_seconds,omitempty"`
-	Redis      map[string]interface{} `json:"redis,omitempty" yaml:"redis,omitempty"`
+	Store              string                 `json:"store,omitempty" yaml:"store,omitempty"`
+	TTLSeconds         int                    `json:"ttl_seconds,omitempty" yaml:"ttl_seconds,omitempty"`
+	Redis              map[string]interface{} `json:"redis,omitempty" yaml:"redis,omitempty"`
+	PoolSize           int                    `json:"pool_size,omitempty" yaml:"pool_size,omitempty"`
+	ReconnectBackoffMs int                    `json:"reconnect_backoff_ms,omitempty" yaml:"reconnect_backoff_ms,omitempty"`
+	MaxTokens          int                    `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
 }
 
 // HTTPClientConfig defines common options for outbound HTTP calls.
@@ -198,7 +388,7 @@ type HTTPClientConfig struct {
 
 // FusionConfig defines the fusion strategy configuration
 type FusionConfig struct {
-	// Strategy: "rrf" (default), "weighted", "linear", "distribution"
+	// Strategy: "rrf" (default), "weighted_rrf", "weighted", "linear", "normalized_linear", "distribution"
 	Strategy string `json:"strategy,omitempty" yaml:"strategy,omitempty"`
 	// Params: strategy-specific parameters (e.g., weights, k value)
 	Params map[string]interface{} `json:"params,omitempty" yaml:"params,omitempty"`
@@ -212,8 +402,28 @@ type FusionConfig struct {
 	TimeoutMs int `json:"timeout_ms,omitempty" yaml:"timeout_ms,omitempty"`
 	// TrafficPercent controls the traffic percentage for learned fusion rollout.
 	TrafficPercent int `json:"traffic_percent,omitempty" yaml:"traffic_percent,omitempty"`
+	// TrafficSalt is hashed with the query to assign rollout arms; change it to reshuffle the population.
+	TrafficSalt string `json:"traffic_salt,omitempty" yaml:"traffic_salt,omitempty"`
 	// RefreshSeconds overrides the default weight cache TTL.
 	RefreshSeconds int `json:"refresh_seconds,omitempty" yaml:"refresh_seconds,omitempty"`
+	// Dedup collapses near-duplicate results across retrievers before fusion.
+	Dedup *DedupConfig `json:"dedup,omitempty" yaml:"dedup,omitempty"`
+}
+
+// Values of DedupConfig.Method
+const (
+	DEDUP_METHOD_HASH      = "hash"
+	DEDUP_METHOD_EMBEDDING = "embedding"
+)
+
+// DedupConfig collapses results whose content is near-identical into the best-scored one
+// before fusion. Method "hash" (default) compares content with case, whitespace and
+// punctuation stripped; "embedding" compares the cosine similarity of the result vectors,
+// embedding results that carry none, against Threshold (default 0.95).
+type DedupConfig struct {
+	Enable    bool    `json:"enable,omitempty" yaml:"enable,omitempty"`
+	Method    string  `json:"method,omitempty" yaml:"method,omitempty"`
+	Threshold float64 `json:"threshold,omitempty" yaml:"threshold,omitempty"`
 }
 
 // RouterConfig defines the query routing configuration
@@ -233,6 +443,9 @@ type RouterRule struct {
 	Profile string         `json:"profile,omitempty" yaml:"profile,omitempty"`
 	Enable  []string       `json:"enable,omitempty" yaml:"enable,omitempty"`
 	Budgets map[string]int `json:"budgets,omitempty" yaml:"budgets,omitempty"`
+
+	// Language limits the rule to queries detected in that language (requires pipeline.language)
+	Language string `json:"language,omitempty" yaml:"language,omitempty"`
 }
 
 // DefaultPipeline returns a safe default pipeline configuration.
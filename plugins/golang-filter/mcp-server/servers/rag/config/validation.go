@@ -2,9 +2,15 @@ package config
 
 import (
 	"fmt"
+	"math"
+	"net"
+	"slices"
 	"strings"
 )
 
+// MAX_TOP_K is the largest top_k accepted in configuration and per-request overrides
+const MAX_TOP_K = 100
+
 // ValidationError represents a configuration validation error
 type ValidationError struct {
 	Field   string
@@ -30,6 +36,22 @@ func (errs ValidationErrors) Error() string {
 	return b.String()
 }
 
+// webProviderRequiredParams lists the params each known web search provider needs;
+// providers not listed (e.g. duckduckgo) have no requirements.
+var webProviderRequiredParams = map[string][]string{
+	"bing":    {"api_key", "endpoint"},
+	"tavily":  {"api_key"},
+	"google":  {"api_key", "cx"},
+	"serpapi": {"api_key"},
+}
+
+// vectorDBMetricTypes lists the metric types each vector store supports for float vectors;
+// providers not listed are not checked
+var vectorDBMetricTypes = map[string][]string{
+	"milvus":   {"L2", "IP", "COSINE"},
+	"weaviate": {"COSINE", "L2", "IP", "HAMMING"},
+}
+
 // Validate validates the complete configuration
 func (c *Config) Validate() error {
 	var errs ValidationErrors
@@ -39,6 +61,11 @@ func (c *Config) Validate() error {
 		errs = append(errs, err...)
 	}
 
+	// Validate LLM configuration
+	if err := c.validateLLM(); err != nil {
+		errs = append(errs, err...)
+	}
+
 	// Validate VectorDB configuration
 	if err := c.validateVectorDB(); err != nil {
 		errs = append(errs, err...)
@@ -80,10 +107,18 @@ func (c *Config) validateEmbedding() ValidationErrors {
 		})
 	}
 
-	if c.Embedding.Dimensions <= 0 {
+	// Zero dimensions means auto-detect from the provider at startup
+	if c.Embedding.Dimensions < 0 {
 		errs = append(errs, ValidationError{
 			Field:   "embedding.dimensions",
-			Message: fmt.Sprintf("embedding dimensions must be positive, got %d", c.Embedding.Dimensions),
+			Message: fmt.Sprintf("embedding dimensions must not be negative, got %d", c.Embedding.Dimensions),
+		})
+	}
+
+	if c.Embedding.MaxBatchSize < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "embedding.max_batch_size",
+			Message: fmt.Sprintf("embedding max_batch_size must not be negative, got %d", c.Embedding.MaxBatchSize),
 		})
 	}
 
@@ -95,6 +130,233 @@ func (c *Config) validateEmbedding() ValidationErrors {
 		})
 	}
 
+	if cache := c.Embedding.Cache; cache != nil && cache.Enable {
+		if cache.MaxEntries < 0 {
+			errs = append(errs, ValidationError{
+				Field:   "embedding.cache.max_entries",
+				Message: fmt.Sprintf("max_entries must be non-negative, got %d", cache.MaxEntries),
+			})
+		}
+		if cache.TTLSeconds < 0 {
+			errs = append(errs, ValidationError{
+				Field:   "embedding.cache.ttl_seconds",
+				Message: fmt.Sprintf("ttl_seconds must be non-negative, got %d", cache.TTLSeconds),
+			})
+		}
+		if store := strings.ToLower(strings.TrimSpace(cache.Store)); store != "" && store != "memory" && store != "redis" {
+			errs = append(errs, ValidationError{
+				Field:   "embedding.cache.store",
+				Message: fmt.Sprintf("store must be memory or redis, got %q", cache.Store),
+			})
+		}
+	}
+
+	return errs
+}
+
+// validateLLM validates the retry settings of the LLM configuration
+func (c *Config) validateLLM() ValidationErrors {
+	var errs ValidationErrors
+
+	if c.LLM.MaxRetries < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "llm.max_retries",
+			Message: fmt.Sprintf("llm max_retries must not be negative, got %d", c.LLM.MaxRetries),
+		})
+	}
+
+	if c.LLM.TimeoutMs < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "llm.timeout_ms",
+			Message: fmt.Sprintf("llm timeout_ms must not be negative, got %d", c.LLM.TimeoutMs),
+		})
+	}
+
+	if c.LLM.MaxContextTokens < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "llm.max_context_tokens",
+			Message: fmt.Sprintf("llm max_context_tokens must not be negative, got %d", c.LLM.MaxContextTokens),
+		})
+	}
+
+	switch c.LLM.ContextOverflow {
+	case "", CONTEXT_OVERFLOW_DROP, CONTEXT_OVERFLOW_COMPRESS:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "llm.context_overflow",
+			Message: fmt.Sprintf("llm context_overflow must be %s or %s, got %q", CONTEXT_OVERFLOW_DROP, CONTEXT_OVERFLOW_COMPRESS, c.LLM.ContextOverflow),
+		})
+	}
+
+	return errs
+}
+
+// validateProfileEmbedding validates the embedding override of the i-th retrieval profile.
+// Dimensions left unset are checked against the collection when the client starts.
+func (c *Config) validateProfileEmbedding(i int, emb *EmbeddingConfig) ValidationErrors {
+	var errs ValidationErrors
+	field := fmt.Sprintf("pipeline.retrieval_profiles[%d].embedding", i)
+
+	if emb.Kind != "" && emb.Kind != "dense" {
+		errs = append(errs, ValidationError{
+			Field:   field + ".kind",
+			Message: fmt.Sprintf("profile embedding must be dense, got kind %q", emb.Kind),
+		})
+	}
+	if emb.Provider != "" && emb.Provider != c.Embedding.Provider && emb.Model == "" {
+		errs = append(errs, ValidationError{
+			Field:   field + ".model",
+			Message: "profile embedding model is required when it uses another provider",
+		})
+	}
+	if emb.Dimensions < 0 {
+		errs = append(errs, ValidationError{
+			Field:   field + ".dimensions",
+			Message: fmt.Sprintf("profile embedding dimensions must not be negative, got %d", emb.Dimensions),
+		})
+	} else if emb.Dimensions > 0 && c.Embedding.Dimensions > 0 && emb.Dimensions != c.Embedding.Dimensions {
+		errs = append(errs, ValidationError{
+			Field:   field + ".dimensions",
+			Message: fmt.Sprintf("profile embedding dimensions %d do not match the collection's %d", emb.Dimensions, c.Embedding.Dimensions),
+		})
+	}
+	return errs
+}
+
+// validateAutoTopK validates the auto TopK bounds of the i-th retrieval profile.
+func validateAutoTopK(i int, auto AutoTopKConfig) ValidationErrors {
+	var errs ValidationErrors
+	field := fmt.Sprintf("pipeline.retrieval_profiles[%d].auto_top_k", i)
+
+	if auto.MinK < 0 || auto.MaxK < 0 {
+		errs = append(errs, ValidationError{
+			Field:   field,
+			Message: fmt.Sprintf("min_k and max_k must be non-negative, got %d and %d", auto.MinK, auto.MaxK),
+		})
+	} else if auto.MaxK > 0 && auto.MinK > auto.MaxK {
+		errs = append(errs, ValidationError{
+			Field:   field,
+			Message: fmt.Sprintf("min_k (%d) must not exceed max_k (%d)", auto.MinK, auto.MaxK),
+		})
+	}
+	if auto.GapThreshold < 0 || auto.GapThreshold > 1 {
+		errs = append(errs, ValidationError{
+			Field:   field + ".gap_threshold",
+			Message: fmt.Sprintf("gap_threshold must be in [0, 1], got %.2f", auto.GapThreshold),
+		})
+	}
+	return errs
+}
+
+// QUERY_LANGUAGES are the languages language routing detects
+var QUERY_LANGUAGES = []string{"en", "zh", "ja", "ko", "ru"}
+
+// validateLanguage validates the languages of pipeline.language, the profiles and the
+// router rules. A language may be served by one profile only.
+func (c *Config) validateLanguage() ValidationErrors {
+	var errs ValidationErrors
+	check := func(field, lang string) {
+		if !slices.Contains(QUERY_LANGUAGES, lang) {
+			errs = append(errs, ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("language must be one of %s, got %q", strings.Join(QUERY_LANGUAGES, ", "), lang),
+			})
+		}
+	}
+	if fallback := c.Pipeline.Language.Fallback; fallback != "" {
+		check("pipeline.language.fallback", fallback)
+	}
+	served := make(map[string]string)
+	for i, prof := range c.Pipeline.RetrievalProfiles {
+		for j, lang := range prof.Languages {
+			field := fmt.Sprintf("pipeline.retrieval_profiles[%d].languages[%d]", i, j)
+			check(field, lang)
+			if other, ok := served[lang]; ok && other != prof.Name {
+				errs = append(errs, ValidationError{
+					Field:   field,
+					Message: fmt.Sprintf("language %s is already served by profile %s", lang, other),
+				})
+			}
+			served[lang] = prof.Name
+		}
+	}
+	if c.Pipeline.Router != nil {
+		for i, rule := range c.Pipeline.Router.Rules {
+			if rule.Language != "" {
+				check(fmt.Sprintf("pipeline.router.rules[%d].language", i), rule.Language)
+			}
+		}
+	}
+	return errs
+}
+
+// validateBackfill validates the min_results floor and backfill of the i-th retrieval profile.
+func validateBackfill(i int, prof RetrievalProfile) ValidationErrors {
+	var errs ValidationErrors
+	field := fmt.Sprintf("pipeline.retrieval_profiles[%d]", i)
+
+	if prof.MinResults < 0 {
+		errs = append(errs, ValidationError{
+			Field:   field + ".min_results",
+			Message: fmt.Sprintf("min_results must be non-negative, got %d", prof.MinResults),
+		})
+	} else if prof.TopK > 0 && prof.MinResults > prof.TopK {
+		errs = append(errs, ValidationError{
+			Field:   field + ".min_results",
+			Message: fmt.Sprintf("min_results (%d) must not exceed top_k (%d)", prof.MinResults, prof.TopK),
+		})
+	}
+	if prof.Backfill.ThresholdStep < 0 || prof.Backfill.ThresholdStep > 1 {
+		errs = append(errs, ValidationError{
+			Field:   field + ".backfill.threshold_step",
+			Message: fmt.Sprintf("threshold_step must be in [0, 1], got %.2f", prof.Backfill.ThresholdStep),
+		})
+	}
+	if prof.Backfill.MaxSteps < 0 {
+		errs = append(errs, ValidationError{
+			Field:   field + ".backfill.max_steps",
+			Message: fmt.Sprintf("max_steps must be non-negative, got %d", prof.Backfill.MaxSteps),
+		})
+	}
+	if len(prof.Backfill.Retrievers) > 0 && prof.MinResults == 0 {
+		errs = append(errs, ValidationError{
+			Field:   field + ".backfill.retrievers",
+			Message: "backfill retrievers require min_results",
+		})
+	}
+	return errs
+}
+
+// BOUNDED_SCORE_RETRIEVERS are the retriever types scoring in [0, 1]; the others, such as
+// bm25 and sparse, score on an open scale
+var BOUNDED_SCORE_RETRIEVERS = []string{"vector", "hyde", "web"}
+
+// validateRetrieverThresholds validates the per-retriever thresholds of the i-th profile
+func validateRetrieverThresholds(i int, thresholds map[string]float64) ValidationErrors {
+	var errs ValidationErrors
+	types := make([]string, 0, len(thresholds))
+	for typ := range thresholds {
+		types = append(types, typ)
+	}
+	slices.Sort(types)
+	for _, typ := range types {
+		field := fmt.Sprintf("pipeline.retrieval_profiles[%d].retriever_thresholds.%s", i, typ)
+		threshold := thresholds[typ]
+		switch {
+		case strings.TrimSpace(typ) == "":
+			errs = append(errs, ValidationError{Field: field, Message: "retriever type is required"})
+		case math.IsNaN(threshold) || math.IsInf(threshold, 0) || threshold < 0:
+			errs = append(errs, ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("threshold must be a non-negative number, got %v", threshold),
+			})
+		case slices.Contains(BOUNDED_SCORE_RETRIEVERS, strings.ToLower(typ)) && threshold > 1:
+			errs = append(errs, ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("%s scores are in [0, 1], got threshold %.2f", typ, threshold),
+			})
+		}
+	}
 	return errs
 }
 
@@ -111,7 +373,7 @@ func (c *Config) validateVectorDB() ValidationErrors {
 
 	// Provider-specific validations
 	switch strings.ToLower(c.VectorDB.Provider) {
-	case "chroma", "milvus", "qdrant":
+	case "chroma", "milvus", "qdrant", "weaviate":
 		if c.VectorDB.Host == "" {
 			errs = append(errs, ValidationError{
 				Field:   "vectordb.host",
@@ -133,9 +395,55 @@ func (c *Config) validateVectorDB() ValidationErrors {
 		}
 	}
 
+	if metricType := c.VectorDB.Mapping.Search.MetricType; metricType != "" {
+		if supported, ok := vectorDBMetricTypes[strings.ToLower(c.VectorDB.Provider)]; ok && !containsFold(supported, metricType) {
+			errs = append(errs, ValidationError{
+				Field:   "vectordb.mapping.search.metric_type",
+				Message: fmt.Sprintf("metric_type %q is not supported by %s provider, supported: %s", metricType, c.VectorDB.Provider, strings.Join(supported, ", ")),
+			})
+		}
+	}
+
+	if c.VectorDB.EnableSparse {
+		if !strings.EqualFold(c.VectorDB.Provider, "milvus") {
+			errs = append(errs, ValidationError{
+				Field:   "vectordb.enable_sparse",
+				Message: fmt.Sprintf("sparse vectors are not supported by %s provider, supported: milvus", c.VectorDB.Provider),
+			})
+		} else if !c.hasSparseRetriever() {
+			errs = append(errs, ValidationError{
+				Field:   "vectordb.enable_sparse",
+				Message: "enable_sparse requires a pipeline retriever of type sparse to produce the sparse vectors",
+			})
+		}
+	}
+
 	return errs
 }
 
+// hasSparseRetriever reports whether the pipeline configures a sparse retriever
+func (c *Config) hasSparseRetriever() bool {
+	if c.Pipeline == nil {
+		return false
+	}
+	for _, ret := range c.Pipeline.Retrievers {
+		if ret.Type == "sparse" {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
 // validateRAG validates RAG configuration
 func (c *Config) validateRAG() ValidationErrors {
 	var errs ValidationErrors
@@ -147,10 +455,10 @@ func (c *Config) validateRAG() ValidationErrors {
 		})
 	}
 
-	if c.RAG.TopK > 100 {
+	if c.RAG.TopK > MAX_TOP_K {
 		errs = append(errs, ValidationError{
 			Field:   "rag.top_k",
-			Message: fmt.Sprintf("rag.top_k %d is too large (max recommended: 100)", c.RAG.TopK),
+			Message: fmt.Sprintf("rag.top_k %d is too large (max recommended: %d)", c.RAG.TopK, MAX_TOP_K),
 		})
 	}
 
@@ -161,6 +469,22 @@ func (c *Config) validateRAG() ValidationErrors {
 		})
 	}
 
+	if parent := c.RAG.Splitter.ParentChunkSize; parent < 0 || parent > 0 && parent <= c.RAG.Splitter.ChunkSize {
+		errs = append(errs, ValidationError{
+			Field:   "rag.splitter.parent_chunk_size",
+			Message: fmt.Sprintf("rag.splitter.parent_chunk_size must be 0 or larger than chunk_size %d, got %d", c.RAG.Splitter.ChunkSize, parent),
+		})
+	}
+
+	switch c.RAG.NoContextMode {
+	case "", NO_CONTEXT_MODE_ANSWER, NO_CONTEXT_MODE_FAIL_CLOSED:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "rag.no_context_mode",
+			Message: fmt.Sprintf("rag.no_context_mode must be %s or %s, got %q", NO_CONTEXT_MODE_ANSWER, NO_CONTEXT_MODE_FAIL_CLOSED, c.RAG.NoContextMode),
+		})
+	}
+
 	return errs
 }
 
@@ -192,6 +516,13 @@ func (c *Config) validatePipeline() ValidationErrors {
 			})
 		}
 
+		if prof.MaxConcurrency < 0 {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("pipeline.retrieval_profiles[%d].max_concurrency", i),
+				Message: fmt.Sprintf("max_concurrency must be non-negative, got %d", prof.MaxConcurrency),
+			})
+		}
+
 		if prof.Threshold < 0 || prof.Threshold > 1 {
 			errs = append(errs, ValidationError{
 				Field:   fmt.Sprintf("pipeline.retrieval_profiles[%d].threshold", i),
@@ -221,12 +552,36 @@ func (c *Config) validatePipeline() ValidationErrors {
 				Message: fmt.Sprintf("vector_low_gate (%.2f) must be less than vector_gate (%.2f)", prof.VectorLowGate, prof.VectorGate),
 			})
 		}
+
+		if prof.Embedding != nil {
+			errs = append(errs, c.validateProfileEmbedding(i, prof.Embedding)...)
+		}
+
+		if prof.AutoTopK.Enable {
+			errs = append(errs, validateAutoTopK(i, prof.AutoTopK)...)
+		}
+
+		if prof.MinResults != 0 || len(prof.Backfill.Retrievers) > 0 {
+			errs = append(errs, validateBackfill(i, prof)...)
+		}
+
+		if len(prof.RetrieverThresholds) > 0 {
+			errs = append(errs, validateRetrieverThresholds(i, prof.RetrieverThresholds)...)
+		}
 	}
 
 	// Validate Post configuration
 	if c.Pipeline.Post != nil {
 		if c.Pipeline.Post.Rerank.Enable {
-			if c.Pipeline.Post.Rerank.Endpoint == "" {
+			// The dashscope reranker defaults to the DashScope endpoint but needs an API key
+			if strings.EqualFold(c.Pipeline.Post.Rerank.Provider, "dashscope") {
+				if c.Pipeline.Post.Rerank.APIKey == "" {
+					errs = append(errs, ValidationError{
+						Field:   "pipeline.post.rerank.api_key",
+						Message: "rerank api_key is required when provider is dashscope",
+					})
+				}
+			} else if c.Pipeline.Post.Rerank.Endpoint == "" {
 				errs = append(errs, ValidationError{
 					Field:   "pipeline.post.rerank.endpoint",
 					Message: "rerank endpoint is required when rerank is enabled",
@@ -247,6 +602,39 @@ func (c *Config) validatePipeline() ValidationErrors {
 					Message: fmt.Sprintf("compress.target_ratio must be in [0, 1], got %.2f", c.Pipeline.Post.Compress.TargetRatio),
 				})
 			}
+			switch c.Pipeline.Post.Compress.Mode {
+			case "", "head", "tail", "head_tail":
+			default:
+				errs = append(errs, ValidationError{
+					Field:   "pipeline.post.compress.mode",
+					Message: fmt.Sprintf("compress.mode must be one of head, tail, head_tail, got %q", c.Pipeline.Post.Compress.Mode),
+				})
+			}
+			if c.Pipeline.Post.Compress.MaxTokens < 0 {
+				errs = append(errs, ValidationError{
+					Field:   "pipeline.post.compress.max_tokens",
+					Message: fmt.Sprintf("compress.max_tokens must be non-negative, got %d", c.Pipeline.Post.Compress.MaxTokens),
+				})
+			} else if c.Pipeline.Post.Compress.MaxTokens == 0 && strings.EqualFold(strings.TrimSpace(c.Pipeline.Post.Compress.Method), "budget") {
+				errs = append(errs, ValidationError{
+					Field:   "pipeline.post.compress.max_tokens",
+					Message: "compress.max_tokens is required when compress.method is budget",
+				})
+			}
+		}
+		if c.Pipeline.Post.MMR.Enable {
+			if c.Pipeline.Post.MMR.Lambda < 0 || c.Pipeline.Post.MMR.Lambda > 1 {
+				errs = append(errs, ValidationError{
+					Field:   "pipeline.post.mmr.lambda",
+					Message: fmt.Sprintf("mmr.lambda must be in [0, 1], got %.2f", c.Pipeline.Post.MMR.Lambda),
+				})
+			}
+			if c.Pipeline.Post.MMR.TopN < 0 {
+				errs = append(errs, ValidationError{
+					Field:   "pipeline.post.mmr.top_n",
+					Message: fmt.Sprintf("mmr.top_n must be non-negative, got %d", c.Pipeline.Post.MMR.TopN),
+				})
+			}
 		}
 	}
 
@@ -272,6 +660,113 @@ func (c *Config) validatePipeline() ValidationErrors {
 				Message: fmt.Sprintf("CRAG incorrect threshold must be in [0, 1], got %.2f", c.Pipeline.CRAG.Evaluator.Incorrect),
 			})
 		}
+
+		for intent, th := range c.Pipeline.CRAG.Evaluator.ThresholdsByIntent {
+			if th.Correct < 0 || th.Correct > 1 || th.Incorrect < 0 || th.Incorrect > 1 {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("pipeline.crag.evaluator.thresholds_by_intent.%s", intent),
+					Message: fmt.Sprintf("CRAG thresholds must be in [0, 1], got correct=%.2f incorrect=%.2f", th.Correct, th.Incorrect),
+				})
+			}
+		}
+	}
+
+	// Validate metrics endpoint
+	if m := c.Pipeline.Metrics; m != nil {
+		if m.Addr != "" {
+			if _, _, err := net.SplitHostPort(m.Addr); err != nil {
+				errs = append(errs, ValidationError{
+					Field:   "pipeline.metrics.addr",
+					Message: fmt.Sprintf("metrics.addr must be host:port, got %q", m.Addr),
+				})
+			}
+		}
+		if m.LatencyWindow < 0 {
+			errs = append(errs, ValidationError{
+				Field:   "pipeline.metrics.latency_window",
+				Message: fmt.Sprintf("metrics.latency_window must be non-negative, got %d", m.LatencyWindow),
+			})
+		}
+	}
+
+	if c.Pipeline.Fusion != nil && c.Pipeline.Fusion.Dedup != nil && c.Pipeline.Fusion.Dedup.Enable {
+		dedup := c.Pipeline.Fusion.Dedup
+		switch dedup.Method {
+		case "", DEDUP_METHOD_HASH, DEDUP_METHOD_EMBEDDING:
+		default:
+			errs = append(errs, ValidationError{
+				Field:   "pipeline.fusion.dedup.method",
+				Message: fmt.Sprintf("fusion.dedup.method must be %s or %s, got %q", DEDUP_METHOD_HASH, DEDUP_METHOD_EMBEDDING, dedup.Method),
+			})
+		}
+		if dedup.Threshold < 0 || dedup.Threshold > 1 {
+			errs = append(errs, ValidationError{
+				Field:   "pipeline.fusion.dedup.threshold",
+				Message: fmt.Sprintf("fusion.dedup.threshold must be in [0, 1], got %.2f", dedup.Threshold),
+			})
+		}
+	}
+
+	if c.Pipeline.Cache != nil && c.Pipeline.Cache.Semantic != nil && c.Pipeline.Cache.Semantic.Enable {
+		sem := c.Pipeline.Cache.Semantic
+		if sem.Threshold < 0 || sem.Threshold > 1 {
+			errs = append(errs, ValidationError{
+				Field:   "pipeline.cache.semantic.threshold",
+				Message: fmt.Sprintf("cache.semantic.threshold must be in [0, 1], got %.2f", sem.Threshold),
+			})
+		}
+		if sem.MaxEntries < 0 {
+			errs = append(errs, ValidationError{
+				Field:   "pipeline.cache.semantic.max_entries",
+				Message: fmt.Sprintf("cache.semantic.max_entries must be non-negative, got %d", sem.MaxEntries),
+			})
+		}
+		l1, l2 := c.Pipeline.Cache.L1, c.Pipeline.Cache.L2
+		if (l1 == nil || !l1.Enable) && (l2 == nil || !l2.Enable) {
+			errs = append(errs, ValidationError{
+				Field:   "pipeline.cache.semantic.enable",
+				Message: "cache.semantic requires cache.l1 or cache.l2 to be enabled",
+			})
+		}
+	}
+
+	if c.Pipeline.Language != nil && c.Pipeline.Language.Enable {
+		errs = append(errs, c.validateLanguage()...)
+	}
+
+	if t := c.Pipeline.Timeouts; t != nil {
+		for _, timeout := range []struct {
+			field string
+			ms    int
+		}{
+			{"retrieval_ms", t.RetrievalMs},
+			{"rerank_ms", t.RerankMs},
+			{"compress_ms", t.CompressMs},
+			{"crag_ms", t.CRAGMs},
+		} {
+			if timeout.ms < 0 {
+				errs = append(errs, ValidationError{
+					Field:   "pipeline.timeouts." + timeout.field,
+					Message: fmt.Sprintf("%s must be non-negative, got %d", timeout.field, timeout.ms),
+				})
+			}
+		}
+	}
+
+	if pre := c.Pipeline.Pre; pre != nil && pre.Service.TimeoutMs < 0 {
+		errs = append(errs, ValidationError{
+			Field:   "pipeline.pre.service.timeout_ms",
+			Message: fmt.Sprintf("timeout_ms must be non-negative, got %d", pre.Service.TimeoutMs),
+		})
+	}
+
+	if pre := c.Pipeline.PreRetrieve; pre != nil && pre.HyDE.Enabled {
+		if score := pre.HyDE.MinQualityScore; score < 0 || score > 1 {
+			errs = append(errs, ValidationError{
+				Field:   "pipeline.pre_retrieve.hyde.min_quality_score",
+				Message: fmt.Sprintf("min_quality_score must be in [0, 1], got %v", score),
+			})
+		}
 	}
 
 	// Validate Retrievers
@@ -299,6 +794,27 @@ func (c *Config) validatePipeline() ValidationErrors {
 					Message: "Web retriever requires either endpoint or provider",
 				})
 			}
+			for _, param := range webProviderRequiredParams[strings.ToLower(ret.Provider)] {
+				if ret.Params[param] == "" {
+					errs = append(errs, ValidationError{
+						Field:   fmt.Sprintf("pipeline.retrievers[%d].params.%s", i, param),
+						Message: fmt.Sprintf("Web retriever provider %q requires %s parameter", ret.Provider, param),
+					})
+				}
+			}
+		case "sparse":
+			if ret.Embedding == nil || ret.Embedding.Kind != "sparse" {
+				errs = append(errs, ValidationError{
+					Field:   fmt.Sprintf("pipeline.retrievers[%d].embedding.kind", i),
+					Message: "Sparse retriever requires an embedding of kind sparse",
+				})
+			}
+			if !c.VectorDB.EnableSparse {
+				errs = append(errs, ValidationError{
+					Field:   "vectordb.enable_sparse",
+					Message: "Sparse retriever requires vectordb.enable_sparse",
+				})
+			}
 		}
 	}
 
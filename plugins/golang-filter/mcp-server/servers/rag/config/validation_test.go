@@ -0,0 +1,391 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidatePipeline_WebProviderCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		params   map[string]string
+		want     []string
+	}{
+		{"bing without credentials", "bing", nil, []string{"pipeline.retrievers[0].params.api_key", "pipeline.retrievers[0].params.endpoint"}},
+		{"bing without api key", "bing", map[string]string{"endpoint": "https://api.bing.microsoft.com/v7.0/search"}, []string{"pipeline.retrievers[0].params.api_key"}},
+		{"bing without endpoint", "Bing", map[string]string{"api_key": "k"}, []string{"pipeline.retrievers[0].params.endpoint"}},
+		{"bing complete", "bing", map[string]string{"api_key": "k", "endpoint": "https://api.bing.microsoft.com/v7.0/search"}, nil},
+		{"tavily without api key", "tavily", map[string]string{"endpoint": "https://api.tavily.com/search"}, []string{"pipeline.retrievers[0].params.api_key"}},
+		{"tavily complete", "tavily", map[string]string{"api_key": "k"}, nil},
+		{"google without engine id", "google", map[string]string{"api_key": "k"}, []string{"pipeline.retrievers[0].params.cx"}},
+		{"google complete", "google", map[string]string{"api_key": "k", "cx": "engine"}, nil},
+		{"serpapi without api key", "serpapi", map[string]string{"engine": "bing"}, []string{"pipeline.retrievers[0].params.api_key"}},
+		{"duckduckgo", "duckduckgo", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Pipeline: &PipelineConfig{
+				Retrievers: []RetrieverConfig{{Type: "web", Provider: tt.provider, Params: tt.params}},
+			}}
+			errs := c.validatePipeline()
+			if len(errs) != len(tt.want) {
+				t.Fatalf("validatePipeline() = %v, want errors for %v", errs, tt.want)
+			}
+			for i, field := range tt.want {
+				if errs[i].Field != field {
+					t.Errorf("error %d field = %q, want %q", i, errs[i].Field, field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatePipeline_Metrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics *MetricsConfig
+		want    []string
+	}{
+		{"disabled", &MetricsConfig{}, nil},
+		{"port only", &MetricsConfig{Addr: ":9091"}, nil},
+		{"host and port", &MetricsConfig{Addr: "127.0.0.1:9091", LatencyWindow: 500}, nil},
+		{"missing port", &MetricsConfig{Addr: "localhost"}, []string{"pipeline.metrics.addr"}},
+		{"negative window", &MetricsConfig{LatencyWindow: -1}, []string{"pipeline.metrics.latency_window"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Pipeline: &PipelineConfig{Metrics: tt.metrics}}
+			errs := c.validatePipeline()
+			if len(errs) != len(tt.want) {
+				t.Fatalf("validatePipeline() = %v, want errors for %v", errs, tt.want)
+			}
+			for i, field := range tt.want {
+				if errs[i].Field != field {
+					t.Errorf("error %d field = %q, want %q", i, errs[i].Field, field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatePipeline_MMR(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{Post: &PostConfig{}}}
+	c.Pipeline.Post.MMR.Enable = true
+	c.Pipeline.Post.MMR.Lambda = 0.7
+	if errs := c.validatePipeline(); len(errs) != 0 {
+		t.Errorf("validatePipeline() = %v, want no errors", errs)
+	}
+	c.Pipeline.Post.MMR.Lambda = 1.5
+	c.Pipeline.Post.MMR.TopN = -1
+	errs := c.validatePipeline()
+	if len(errs) != 2 || errs[0].Field != "pipeline.post.mmr.lambda" || errs[1].Field != "pipeline.post.mmr.top_n" {
+		t.Errorf("validatePipeline() = %v, want lambda and top_n errors", errs)
+	}
+}
+
+func TestValidatePipeline_CompressMaxTokens(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{Post: &PostConfig{}}}
+	c.Pipeline.Post.Compress.Enable = true
+	c.Pipeline.Post.Compress.Method = "budget"
+	errs := c.validatePipeline()
+	if len(errs) != 1 || errs[0].Field != "pipeline.post.compress.max_tokens" {
+		t.Errorf("validatePipeline() = %v, want the missing max_tokens", errs)
+	}
+	c.Pipeline.Post.Compress.MaxTokens = -1
+	if errs := c.validatePipeline(); len(errs) != 1 || errs[0].Field != "pipeline.post.compress.max_tokens" {
+		t.Errorf("validatePipeline() = %v, want the negative max_tokens", errs)
+	}
+	c.Pipeline.Post.Compress.MaxTokens = 2000
+	if errs := c.validatePipeline(); len(errs) != 0 {
+		t.Errorf("validatePipeline() = %v, want no errors", errs)
+	}
+}
+
+func TestValidatePipeline_DashScopeRerank(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{Post: &PostConfig{}}}
+	c.Pipeline.Post.Rerank.Enable = true
+	c.Pipeline.Post.Rerank.Provider = "dashscope"
+	errs := c.validatePipeline()
+	if len(errs) != 1 || errs[0].Field != "pipeline.post.rerank.api_key" {
+		t.Errorf("validatePipeline() = %v, want only the missing api_key", errs)
+	}
+	c.Pipeline.Post.Rerank.APIKey = "sk-test"
+	if errs := c.validatePipeline(); len(errs) != 0 {
+		t.Errorf("validatePipeline() = %v, want the default endpoint accepted", errs)
+	}
+}
+
+func TestValidateVectorDB_MetricType(t *testing.T) {
+	tests := []struct {
+		provider   string
+		metricType string
+		wantErr    bool
+	}{
+		{"milvus", "", false},
+		{"milvus", "COSINE", false},
+		{"milvus", "ip", false},
+		{"milvus", "HAMMING", true},
+		{"weaviate", "cosine", false},
+		{"weaviate", "JACCARD", true},
+		{"chroma", "ANYTHING", false},
+	}
+	for _, tt := range tests {
+		c := &Config{VectorDB: VectorDBConfig{
+			Provider:   tt.provider,
+			Host:       "localhost",
+			Collection: "c",
+			Mapping:    MappingConfig{Search: SearchConfig{MetricType: tt.metricType}},
+		}}
+		errs := c.validateVectorDB()
+		if tt.wantErr != (len(errs) == 1 && errs[0].Field == "vectordb.mapping.search.metric_type") || (!tt.wantErr && len(errs) > 0) {
+			t.Errorf("validateVectorDB(%s, %q) = %v, want error %v", tt.provider, tt.metricType, errs, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateRAG_NoContextMode(t *testing.T) {
+	for mode, wantErr := range map[string]bool{"": false, "answer": false, "fail_closed": false, "closed": true} {
+		c := &Config{RAG: RAGConfig{TopK: 5, NoContextMode: mode}}
+		errs := c.validateRAG()
+		if wantErr != (len(errs) == 1 && errs[0].Field == "rag.no_context_mode") || (!wantErr && len(errs) > 0) {
+			t.Errorf("validateRAG(%q) = %v, want error %v", mode, errs, wantErr)
+		}
+	}
+}
+
+func TestValidatePipeline_ProfileEmbedding(t *testing.T) {
+	tests := []struct {
+		name      string
+		embedding EmbeddingConfig
+		wantField string
+	}{
+		{"model of the global provider", EmbeddingConfig{Model: "multilingual"}, ""},
+		{"matching dimensions", EmbeddingConfig{Provider: "cohere", Model: "embed-multilingual-v3.0", Dimensions: 1024}, ""},
+		{"dimension mismatch", EmbeddingConfig{Model: "large", Dimensions: 3072}, "pipeline.retrieval_profiles[0].embedding.dimensions"},
+		{"other provider without model", EmbeddingConfig{Provider: "cohere"}, "pipeline.retrieval_profiles[0].embedding.model"},
+		{"sparse kind", EmbeddingConfig{Kind: "sparse"}, "pipeline.retrieval_profiles[0].embedding.kind"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedding := tt.embedding
+			c := &Config{
+				Embedding: EmbeddingConfig{Provider: "openai", Model: "small", Dimensions: 1024},
+				Pipeline:  &PipelineConfig{RetrievalProfiles: []RetrievalProfile{{Name: "multilingual", Embedding: &embedding}}},
+			}
+			errs := c.validatePipeline()
+			if tt.wantField == "" && len(errs) > 0 || tt.wantField != "" && (len(errs) != 1 || errs[0].Field != tt.wantField) {
+				t.Errorf("validatePipeline() = %v, want field %q", errs, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidatePipeline_FusionDedup(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{Fusion: &FusionConfig{Dedup: &DedupConfig{Enable: true, Method: DEDUP_METHOD_EMBEDDING, Threshold: 0.9}}}}
+	if errs := c.validatePipeline(); len(errs) > 0 {
+		t.Errorf("validatePipeline() = %v, want no errors", errs)
+	}
+	c.Pipeline.Fusion.Dedup = &DedupConfig{Enable: true, Method: "simhash", Threshold: 1.5}
+	errs := c.validatePipeline()
+	if len(errs) != 2 || errs[0].Field != "pipeline.fusion.dedup.method" || errs[1].Field != "pipeline.fusion.dedup.threshold" {
+		t.Errorf("validatePipeline() = %v, want method and threshold errors", errs)
+	}
+}
+
+func TestValidatePipeline_AutoTopK(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{RetrievalProfiles: []RetrievalProfile{{Name: "default", TopK: 5, AutoTopK: AutoTopKConfig{Enable: true, MinK: 2, MaxK: 8, GapThreshold: 0.3}}}}}
+	if errs := c.validatePipeline(); len(errs) > 0 {
+		t.Errorf("validatePipeline() = %v, want no errors", errs)
+	}
+	c.Pipeline.RetrievalProfiles[0].AutoTopK = AutoTopKConfig{Enable: true, MinK: 9, MaxK: 8, GapThreshold: 1.5}
+	errs := c.validatePipeline()
+	if len(errs) != 2 || errs[0].Field != "pipeline.retrieval_profiles[0].auto_top_k" || errs[1].Field != "pipeline.retrieval_profiles[0].auto_top_k.gap_threshold" {
+		t.Errorf("validatePipeline() = %v, want bounds and gap_threshold errors", errs)
+	}
+}
+
+func TestValidatePipeline_MinResults(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{RetrievalProfiles: []RetrievalProfile{{Name: "default", TopK: 5, MinResults: 3,
+		Backfill: BackfillConfig{ThresholdStep: 0.1, MaxSteps: 2, Retrievers: []string{"web"}}}}}}
+	if errs := c.validatePipeline(); len(errs) > 0 {
+		t.Errorf("validatePipeline() = %v, want no errors", errs)
+	}
+	c.Pipeline.RetrievalProfiles[0].MinResults = 6
+	c.Pipeline.RetrievalProfiles[0].Backfill = BackfillConfig{ThresholdStep: -0.1, MaxSteps: -1}
+	errs := c.validatePipeline()
+	if len(errs) != 3 || errs[0].Field != "pipeline.retrieval_profiles[0].min_results" ||
+		errs[1].Field != "pipeline.retrieval_profiles[0].backfill.threshold_step" || errs[2].Field != "pipeline.retrieval_profiles[0].backfill.max_steps" {
+		t.Errorf("validatePipeline() = %v, want min_results, threshold_step and max_steps errors", errs)
+	}
+	c.Pipeline.RetrievalProfiles[0] = RetrievalProfile{Name: "default", Backfill: BackfillConfig{Retrievers: []string{"web"}}}
+	if errs := c.validatePipeline(); len(errs) != 1 || errs[0].Field != "pipeline.retrieval_profiles[0].backfill.retrievers" {
+		t.Errorf("validatePipeline() = %v, want the retrievers without min_results rejected", errs)
+	}
+}
+
+func TestValidatePipeline_Language(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{
+		Language: &LanguageConfig{Enable: true, Fallback: "en"},
+		RetrievalProfiles: []RetrievalProfile{
+			{Name: "default", Languages: []string{"en"}},
+			{Name: "chinese", Languages: []string{"zh"}},
+		},
+		Router: &RouterConfig{Rules: []RouterRule{{Language: "zh", Profile: "chinese"}}},
+	}}
+	if errs := c.validatePipeline(); len(errs) > 0 {
+		t.Errorf("validatePipeline() = %v, want no errors", errs)
+	}
+	c.Pipeline.Language.Fallback = "english"
+	c.Pipeline.RetrievalProfiles[1].Languages = []string{"zh", "en"}
+	c.Pipeline.Router.Rules[0].Language = "cn"
+	errs := c.validatePipeline()
+	if len(errs) != 3 || errs[0].Field != "pipeline.language.fallback" ||
+		errs[1].Field != "pipeline.retrieval_profiles[1].languages[1]" || errs[2].Field != "pipeline.router.rules[0].language" {
+		t.Errorf("validatePipeline() = %v, want fallback, duplicate language and rule language errors", errs)
+	}
+}
+
+func TestValidatePipeline_HyDEQualityScore(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{PreRetrieve: &PreRetrieveConfig{HyDE: HyDEConfig{Enabled: true, MinQualityScore: 0.6}}}}
+	if errs := c.validatePipeline(); len(errs) > 0 {
+		t.Errorf("validatePipeline() = %v, want no errors", errs)
+	}
+	c.Pipeline.PreRetrieve.HyDE.MinQualityScore = 1.5
+	if errs := c.validatePipeline(); len(errs) != 1 || errs[0].Field != "pipeline.pre_retrieve.hyde.min_quality_score" {
+		t.Errorf("validatePipeline() = %v, want a min_quality_score error", errs)
+	}
+}
+
+func TestValidatePipeline_RetrieverThresholds(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{RetrievalProfiles: []RetrievalProfile{
+		{Name: "default", RetrieverThresholds: map[string]float64{"vector": 0.7, "web": 0, "bm25": 8}},
+	}}}
+	if errs := c.validatePipeline(); len(errs) > 0 {
+		t.Errorf("validatePipeline() = %v, want no errors", errs)
+	}
+	c.Pipeline.RetrievalProfiles[0].RetrieverThresholds = map[string]float64{"vector": 1.2, "bm25": -1}
+	errs := c.validatePipeline()
+	if len(errs) != 2 || errs[0].Field != "pipeline.retrieval_profiles[0].retriever_thresholds.bm25" ||
+		errs[1].Field != "pipeline.retrieval_profiles[0].retriever_thresholds.vector" {
+		t.Errorf("validatePipeline() = %v, want bm25 and vector threshold errors", errs)
+	}
+}
+
+func TestValidateLLM_Retry(t *testing.T) {
+	c := &Config{LLM: LLMConfig{MaxRetries: 3, TimeoutMs: 5000}}
+	if errs := c.validateLLM(); len(errs) > 0 {
+		t.Errorf("validateLLM() = %v, want no errors", errs)
+	}
+	c.LLM = LLMConfig{MaxRetries: -1, TimeoutMs: -1}
+	errs := c.validateLLM()
+	if len(errs) != 2 || errs[0].Field != "llm.max_retries" || errs[1].Field != "llm.timeout_ms" {
+		t.Errorf("validateLLM() = %v, want max_retries and timeout_ms errors", errs)
+	}
+}
+
+func TestValidateLLM_ContextLimit(t *testing.T) {
+	c := &Config{LLM: LLMConfig{MaxContextTokens: 8000, ContextOverflow: CONTEXT_OVERFLOW_COMPRESS}}
+	if errs := c.validateLLM(); len(errs) > 0 {
+		t.Errorf("validateLLM() = %v, want no errors", errs)
+	}
+	c.LLM = LLMConfig{MaxContextTokens: -1, ContextOverflow: "truncate"}
+	errs := c.validateLLM()
+	if len(errs) != 2 || errs[0].Field != "llm.max_context_tokens" || errs[1].Field != "llm.context_overflow" {
+		t.Errorf("validateLLM() = %v, want max_context_tokens and context_overflow errors", errs)
+	}
+}
+
+func TestValidate_Sparse(t *testing.T) {
+	sparseRetriever := RetrieverConfig{Type: "sparse", Embedding: &EmbeddingConfig{Kind: "sparse"}}
+	tests := []struct {
+		name       string
+		provider   string
+		enable     bool
+		retriever  *RetrieverConfig
+		wantFields []string
+	}{
+		{"milvus with sparse retriever", "milvus", true, &sparseRetriever, nil},
+		{"dense only", "milvus", false, nil, nil},
+		{"unsupported backend", "weaviate", true, &sparseRetriever, []string{"vectordb.enable_sparse"}},
+		{"no sparse retriever", "milvus", true, nil, []string{"vectordb.enable_sparse"}},
+		{"retriever without enable_sparse", "milvus", false, &sparseRetriever, []string{"vectordb.enable_sparse"}},
+		{"retriever without sparse embedding", "milvus", true, &RetrieverConfig{Type: "sparse"}, []string{"pipeline.retrievers[0].embedding.kind"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				VectorDB: VectorDBConfig{Provider: tt.provider, Host: "localhost", Collection: "c", EnableSparse: tt.enable},
+				Pipeline: &PipelineConfig{},
+			}
+			if tt.retriever != nil {
+				c.Pipeline.Retrievers = []RetrieverConfig{*tt.retriever}
+			}
+			errs := append(c.validateVectorDB(), c.validatePipeline()...)
+			var fields []string
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("validation errors = %v, want fields %v", errs, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidateEmbedding_Cache(t *testing.T) {
+	tests := []struct {
+		cache     CacheLayerConfig
+		wantField string
+	}{
+		{CacheLayerConfig{Enable: true}, ""},
+		{CacheLayerConfig{Enable: true, Store: "redis", MaxEntries: 100, TTLSeconds: 60}, ""},
+		{CacheLayerConfig{Enable: true, MaxEntries: -1}, "embedding.cache.max_entries"},
+		{CacheLayerConfig{Enable: true, TTLSeconds: -1}, "embedding.cache.ttl_seconds"},
+		{CacheLayerConfig{Enable: true, Store: "memcached"}, "embedding.cache.store"},
+		{CacheLayerConfig{Store: "memcached"}, ""},
+	}
+	for _, tt := range tests {
+		cache := tt.cache
+		c := &Config{Embedding: EmbeddingConfig{Provider: "openai", Model: "m", Cache: &cache}}
+		errs := c.validateEmbedding()
+		if tt.wantField == "" && len(errs) > 0 || tt.wantField != "" && (len(errs) != 1 || errs[0].Field != tt.wantField) {
+			t.Errorf("validateEmbedding(%+v) = %v, want error on %q", tt.cache, errs, tt.wantField)
+		}
+	}
+}
+
+func TestValidateRAG_ParentChunkSize(t *testing.T) {
+	for parent, wantErr := range map[int]bool{0: false, 2000: false, 500: true, 300: true, -1: true} {
+		c := &Config{RAG: RAGConfig{TopK: 5, Splitter: SplitterConfig{Provider: "recursive", ChunkSize: 500, ParentChunkSize: parent}}}
+		errs := c.validateRAG()
+		if wantErr != (len(errs) == 1 && errs[0].Field == "rag.splitter.parent_chunk_size") || (!wantErr && len(errs) > 0) {
+			t.Errorf("validateRAG(parent_chunk_size %d) = %v, want error %v", parent, errs, wantErr)
+		}
+	}
+}
+
+func TestValidatePipeline_Timeouts(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{Timeouts: &StageTimeoutsConfig{RetrievalMs: 800, RerankMs: 300}}}
+	if errs := c.validatePipeline(); len(errs) > 0 {
+		t.Errorf("validatePipeline() = %v, want no errors", errs)
+	}
+	c.Pipeline.Timeouts = &StageTimeoutsConfig{CompressMs: -1, CRAGMs: -5}
+	errs := c.validatePipeline()
+	if len(errs) != 2 || errs[0].Field != "pipeline.timeouts.compress_ms" || errs[1].Field != "pipeline.timeouts.crag_ms" {
+		t.Errorf("validatePipeline() = %v, want compress_ms and crag_ms errors", errs)
+	}
+}
+
+func TestValidatePipeline_PreServiceTimeout(t *testing.T) {
+	c := &Config{Pipeline: &PipelineConfig{Pre: &PreConfig{}}}
+	c.Pipeline.Pre.Service.Provider = "grpc"
+	c.Pipeline.Pre.Service.TimeoutMs = 500
+	if errs := c.validatePipeline(); len(errs) > 0 {
+		t.Errorf("validatePipeline() = %v, want no errors", errs)
+	}
+	c.Pipeline.Pre.Service.TimeoutMs = -1
+	if errs := c.validatePipeline(); len(errs) != 1 || errs[0].Field != "pipeline.pre.service.timeout_ms" {
+		t.Errorf("validatePipeline() = %v, want a timeout_ms error", errs)
+	}
+}
@@ -0,0 +1,108 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/post"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// fitPrompt renders the answer prompt of docs followed by suffix and keeps its estimated
+// tokens within llm.PromptTokenLimit. Over the limit, the contexts are trimmed to the budget
+// left by the rest of the prompt when context_overflow is "compress", then the
+// lowest-scored contexts are dropped until the prompt fits; a last context that still does
+// not fit is trimmed. It returns the contexts the prompt was rendered from.
+func (r *RAGClient) fitPrompt(ctx context.Context, query string, docs []schema.SearchResult, suffix string, m *metrics.RetrievalMetrics) ([]schema.SearchResult, string, error) {
+	prompt, tokens, err := r.renderPrompt(query, docs, suffix)
+	if err != nil {
+		return nil, "", err
+	}
+	limit := llm.PromptTokenLimit(r.config.LLM)
+	if limit <= 0 || tokens <= limit || len(docs) == 0 {
+		return docs, prompt, nil
+	}
+	budget := limit - (tokens - contextTokens(docs))
+	if budget <= 0 {
+		return nil, "", fmt.Errorf("answer prompt needs %d tokens without contexts, over the limit of %d", tokens-contextTokens(docs), limit)
+	}
+
+	original, originalTokens := len(docs), tokens
+	if r.config.LLM.ContextOverflow == config.CONTEXT_OVERFLOW_COMPRESS {
+		if docs, err = trimContexts(ctx, query, docs, budget); err != nil {
+			return nil, "", err
+		}
+		if prompt, tokens, err = r.renderPrompt(query, docs, suffix); err != nil {
+			return nil, "", err
+		}
+	}
+	for tokens > limit && len(docs) > 1 {
+		docs = dropLowestScored(docs)
+		if prompt, tokens, err = r.renderPrompt(query, docs, suffix); err != nil {
+			return nil, "", err
+		}
+	}
+	if tokens > limit {
+		if docs, err = trimContexts(ctx, query, docs, limit-(tokens-contextTokens(docs))); err != nil {
+			return nil, "", err
+		}
+		if prompt, tokens, err = r.renderPrompt(query, docs, suffix); err != nil {
+			return nil, "", err
+		}
+	}
+
+	api.LogWarnf("rag: answer prompt of %d tokens exceeds the limit of %d, dropped %d of %d contexts (%d tokens left)",
+		originalTokens, limit, original-len(docs), original, tokens)
+	if m != nil {
+		m.ContextDropped = original - len(docs)
+		m.ContextPromptTokens = tokens
+	}
+	return docs, prompt, nil
+}
+
+// renderPrompt renders the answer prompt of docs followed by suffix and estimates its tokens
+func (r *RAGClient) renderPrompt(query string, docs []schema.SearchResult, suffix string) (string, int, error) {
+	prompt, err := r.buildPrompt(query, docs)
+	if err != nil {
+		return "", 0, err
+	}
+	prompt += suffix
+	return prompt, llm.EstimateTokens(prompt), nil
+}
+
+// contextTokens returns the estimated tokens of the contents of docs
+func contextTokens(docs []schema.SearchResult) int {
+	total := 0
+	for _, doc := range docs {
+		total += llm.EstimateTokens(doc.Document.Content)
+	}
+	return total
+}
+
+// trimContexts trims the contents of docs to budget tokens in total, splitting it by score
+func trimContexts(ctx context.Context, query string, docs []schema.SearchResult, budget int) ([]schema.SearchResult, error) {
+	budgeter := &post.BudgetCompressor{MaxTokens: max(budget, 1)}
+	trimmed, err := budgeter.BatchCompress(ctx, docs, query)
+	if err != nil {
+		return nil, fmt.Errorf("trim contexts failed, err: %w", err)
+	}
+	return trimmed, nil
+}
+
+// dropLowestScored removes the lowest-scored document, the last one among equal scores,
+// keeping the order of the others
+func dropLowestScored(docs []schema.SearchResult) []schema.SearchResult {
+	lowest := len(docs) - 1
+	for i := len(docs) - 2; i >= 0; i-- {
+		if docs[i].Score < docs[lowest].Score {
+			lowest = i
+		}
+	}
+	out := make([]schema.SearchResult, 0, len(docs)-1)
+	out = append(out, docs[:lowest]...)
+	return append(out, docs[lowest+1:]...)
+}
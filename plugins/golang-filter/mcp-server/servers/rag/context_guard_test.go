@@ -0,0 +1,97 @@
+package rag
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+func guardDocs() []schema.SearchResult {
+	return []schema.SearchResult{
+		{Document: schema.Document{ID: "a", Content: strings.Repeat("alpha gateway ", 100)}, Score: 0.9},
+		{Document: schema.Document{ID: "b", Content: strings.Repeat("beta plugin ", 100)}, Score: 0.3},
+		{Document: schema.Document{ID: "c", Content: strings.Repeat("gamma route ", 100)}, Score: 0.6},
+	}
+}
+
+func TestRAGClient_FitPrompt(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}}, &MockLLMProvider{})
+	docs := guardDocs()
+	_, full, err := client.renderPrompt("q", docs, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a limit or a known model the prompt is unchanged
+	kept, prompt, err := client.fitPrompt(context.Background(), "q", docs, "", nil)
+	if err != nil || len(kept) != 3 || llm.EstimateTokens(prompt) != full {
+		t.Fatalf("fitPrompt() = %d contexts, %v, want all contexts", len(kept), err)
+	}
+
+	// Drop: the lowest-scored context goes first and the order of the others is kept
+	client.config.LLM.MaxContextTokens = full - 100
+	m := &metrics.RetrievalMetrics{}
+	kept, prompt, err = client.fitPrompt(context.Background(), "q", docs, "", m)
+	if err != nil {
+		t.Fatalf("fitPrompt() error = %v", err)
+	}
+	if len(kept) != 2 || kept[0].Document.ID != "a" || kept[1].Document.ID != "c" {
+		t.Errorf("kept = %v, want a and c", kept)
+	}
+	if tokens := llm.EstimateTokens(prompt); tokens > client.config.LLM.MaxContextTokens || m.ContextDropped != 1 || m.ContextPromptTokens != tokens {
+		t.Errorf("prompt tokens = %d, metrics = %d dropped %d tokens", tokens, m.ContextDropped, m.ContextPromptTokens)
+	}
+
+	// Compress: every context is kept, trimmed by score
+	client.config.LLM.ContextOverflow = config.CONTEXT_OVERFLOW_COMPRESS
+	kept, prompt, err = client.fitPrompt(context.Background(), "q", docs, "", nil)
+	if err != nil || len(kept) != 3 || llm.EstimateTokens(prompt) > client.config.LLM.MaxContextTokens {
+		t.Errorf("fitPrompt(compress) = %d contexts, %d tokens, %v", len(kept), llm.EstimateTokens(prompt), err)
+	}
+	if len(kept) == 3 && len(kept[1].Document.Content) >= len(kept[0].Document.Content) {
+		t.Errorf("lower-scored context kept %d bytes, higher-scored %d", len(kept[1].Document.Content), len(kept[0].Document.Content))
+	}
+
+	// A single context over the limit is trimmed rather than dropped
+	client.config.LLM.ContextOverflow = ""
+	client.config.LLM.MaxContextTokens = full - 600
+	kept, prompt, err = client.fitPrompt(context.Background(), "q", docs, llm.CitationInstruction, nil)
+	if err != nil || len(kept) != 1 || kept[0].Document.ID != "a" || llm.EstimateTokens(prompt) > client.config.LLM.MaxContextTokens {
+		t.Errorf("fitPrompt() = %v, %d tokens, %v, want a trimmed", kept, llm.EstimateTokens(prompt), err)
+	}
+	if !strings.HasSuffix(prompt, llm.CitationInstruction) {
+		t.Error("prompt lost its suffix")
+	}
+
+	client.config.LLM.MaxContextTokens = 5
+	if _, _, err := client.fitPrompt(context.Background(), "q", docs, "", nil); err == nil {
+		t.Error("fitPrompt() should fail when the prompt does not fit without contexts")
+	}
+}
+
+func TestRAGClient_ChatDropsContextsOverLimit(t *testing.T) {
+	llmProvider := &MockLLMProvider{}
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3, Threshold: 0.001}}, llmProvider)
+	for _, text := range []string{strings.Repeat("higress gateway ", 200), strings.Repeat("higress plugin ", 200)} {
+		if _, err := client.CreateChunkFromText(text, "doc"); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+	client.config.LLM.MaxContextTokens = 1000
+
+	resp, err := client.ChatWithSources("higress gateway", RequestOptions{})
+	if err != nil {
+		t.Fatalf("ChatWithSources() error = %v", err)
+	}
+	if len(resp.Sources) != 1 {
+		t.Errorf("sources = %d, want the context that fits", len(resp.Sources))
+	}
+	if tokens := llm.EstimateTokens(llmProvider.Prompts[0]); tokens > 1000 {
+		t.Errorf("prompt tokens = %d, want at most 1000", tokens)
+	}
+}
@@ -2,6 +2,8 @@ package crag
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
 )
@@ -13,6 +15,75 @@ type ActionContext struct {
 	QueryRewriter *QueryRewriter
 	Query         string
 	Context       context.Context
+	// MaxWebCalls caps the web searches issued for query variants; defaults to DefaultMaxWebCalls.
+	MaxWebCalls int
+	// MaxCombined caps the de-duplicated results AmbiguousAction returns; defaults to DefaultMaxCombinedResults.
+	MaxCombined int
+}
+
+// DefaultMaxCombinedResults is the default number of results AmbiguousAction keeps.
+const DefaultMaxCombinedResults = 10
+
+// DefaultMaxWebCalls is the default number of web searches a corrective action may issue.
+const DefaultMaxWebCalls = 3
+
+// webResultsPerQuery is the number of web results requested per query variant.
+const webResultsPerQuery = 3
+
+// webSearch rewrites the query into the configured number of variants, searches the web
+// for each (up to MaxWebCalls, concurrently) and merges the results in variant order,
+// dropping duplicate URLs. It fails only when every search fails.
+func webSearch(ctx *ActionContext) ([]schema.SearchResult, error) {
+	queries := []string{ctx.Query}
+	if ctx.QueryRewriter != nil && ctx.QueryRewriter.Provider != nil {
+		rewritten, err := ctx.QueryRewriter.RewriteN(ctx.Context, ctx.Query, ctx.QueryRewriter.Variants)
+		if err == nil && len(rewritten) > 0 && rewritten[0] != "" {
+			queries = rewritten
+		}
+	}
+	maxCalls := ctx.MaxWebCalls
+	if maxCalls <= 0 {
+		maxCalls = DefaultMaxWebCalls
+	}
+	if len(queries) > maxCalls {
+		queries = queries[:maxCalls]
+	}
+
+	results := make([][]schema.SearchResult, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		wg.Add(1)
+		go func(i int, query string) {
+			defer wg.Done()
+			results[i], errs[i] = ctx.WebSearcher.Search(ctx.Context, query, webResultsPerQuery)
+		}(i, query)
+	}
+	wg.Wait()
+
+	merged := make([]schema.SearchResult, 0, len(queries)*webResultsPerQuery)
+	seen := make(map[string]bool)
+	failed := 0
+	for i := range queries {
+		if errs[i] != nil {
+			failed++
+			logWarnf("CRAG web search for %q failed: %v", queries[i], errs[i])
+			continue
+		}
+		for _, result := range results[i] {
+			if id := result.Document.ID; id != "" {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+			}
+			merged = append(merged, result)
+		}
+	}
+	if failed == len(queries) {
+		return nil, fmt.Errorf("all %d web searches failed: %w", failed, errs[0])
+	}
+	return merged, nil
 }
 
 // CorrectAction handles high-relevance scenario: documents are relevant, use them directly.
@@ -22,20 +93,37 @@ func CorrectAction(ctx *ActionContext, cands []schema.SearchResult) []schema.Sea
 
 	// Optionally refine documents for better quality
 	if ctx != nil && ctx.Refiner != nil && ctx.Refiner.Provider != nil && ctx.Context != nil {
-		refined := make([]schema.SearchResult, 0, len(cands))
-		for _, result := range cands {
-			refinedContent, err := ctx.Refiner.Refine(ctx.Context, result.Document.Content)
-			if err == nil && refinedContent != "" {
-				result.Document.Content = refinedContent
-			}
-			refined = append(refined, result)
-		}
-		return refined
+		return refineResults(ctx.Context, ctx.Refiner, cands, false)
 	}
 
 	return cands
 }
 
+// refineResults refines the contents of results in batches and returns updated copies in the
+// same order. When mark is set, documents whose content changed get metadata "refined" = true.
+func refineResults(ctx context.Context, refiner *KnowledgeRefiner, results []schema.SearchResult, mark bool) []schema.SearchResult {
+	texts := make([]string, len(results))
+	for i, result := range results {
+		texts[i] = result.Document.Content
+	}
+	refinedTexts, _ := refiner.RefineBatch(ctx, texts)
+
+	refined := make([]schema.SearchResult, 0, len(results))
+	for i, result := range results {
+		if content := refinedTexts[i]; content != "" && content != result.Document.Content {
+			result.Document.Content = content
+			if mark {
+				if result.Document.Metadata == nil {
+					result.Document.Metadata = make(map[string]interface{})
+				}
+				result.Document.Metadata["refined"] = true
+			}
+		}
+		refined = append(refined, result)
+	}
+	return refined
+}
+
 // IncorrectAction handles low-relevance scenario: documents are not relevant, perform web search.
 // Returns empty slice if web search is not available or configured.
 func IncorrectAction(ctx *ActionContext) []schema.SearchResult {
@@ -46,17 +134,8 @@ func IncorrectAction(ctx *ActionContext) []schema.SearchResult {
 		return []schema.SearchResult{}
 	}
 
-	// Rewrite query for better web search results
-	searchQuery := ctx.Query
-	if ctx.QueryRewriter != nil && ctx.QueryRewriter.Provider != nil {
-		rewritten, err := ctx.QueryRewriter.Rewrite(ctx.Context, ctx.Query)
-		if err == nil && rewritten != "" {
-			searchQuery = rewritten
-		}
-	}
-
-	// Perform web search
-	webResults, err := ctx.WebSearcher.Search(ctx.Context, searchQuery, 3)
+	// Rewrite query into variants and search the web for each
+	webResults, err := webSearch(ctx)
 	if err != nil {
 		logWarnf("CRAG IncorrectAction: web search failed: %v", err)
 		return []schema.SearchResult{}
@@ -64,15 +143,7 @@ func IncorrectAction(ctx *ActionContext) []schema.SearchResult {
 
 	// Optionally refine web search results
 	if ctx.Refiner != nil && ctx.Refiner.Provider != nil {
-		refined := make([]schema.SearchResult, 0, len(webResults))
-		for _, result := range webResults {
-			refinedContent, err := ctx.Refiner.Refine(ctx.Context, result.Document.Content)
-			if err == nil && refinedContent != "" {
-				result.Document.Content = refinedContent
-			}
-			refined = append(refined, result)
-		}
-		return refined
+		return refineResults(ctx.Context, ctx.Refiner, webResults, false)
 	}
 
 	logInfof("CRAG IncorrectAction: returning %d web search results", len(webResults))
@@ -86,17 +157,8 @@ func AmbiguousAction(ctx *ActionContext, internal []schema.SearchResult, externa
 
 	// If no external results provided and we have web search capability, fetch them
 	if len(external) == 0 && ctx != nil && ctx.WebSearcher != nil && ctx.Query != "" && ctx.Context != nil {
-		// Rewrite query for better web search results
-		searchQuery := ctx.Query
-		if ctx.QueryRewriter != nil && ctx.QueryRewriter.Provider != nil {
-			rewritten, err := ctx.QueryRewriter.Rewrite(ctx.Context, ctx.Query)
-			if err == nil && rewritten != "" {
-				searchQuery = rewritten
-			}
-		}
-
-		// Perform web search
-		webResults, err := ctx.WebSearcher.Search(ctx.Context, searchQuery, 3)
+		// Rewrite query into variants and search the web for each
+		webResults, err := webSearch(ctx)
 		if err == nil {
 			external = webResults
 		} else {
@@ -112,40 +174,24 @@ func AmbiguousAction(ctx *ActionContext, internal []schema.SearchResult, externa
 		return internal
 	}
 
-	// Combine internal and external results
+	// Combine internal and external results, dropping overlaps before spending refinement calls
 	combined := make([]schema.SearchResult, 0, len(internal)+len(external))
+	combined = append(combined, internal...)
+	combined = append(combined, external...)
+	combined = dedupeResults(combined)
 
-	// Refine internal results if refiner available
+	// Refine internal and external results together, marking refined documents;
+	// refinement can make distinct passages identical, so de-duplicate again
 	if ctx != nil && ctx.Refiner != nil && ctx.Refiner.Provider != nil && ctx.Context != nil {
-		for _, result := range internal {
-			refinedContent, err := ctx.Refiner.Refine(ctx.Context, result.Document.Content)
-			if err == nil && refinedContent != "" {
-				result.Document.Content = refinedContent
-				// Mark as refined
-				if result.Document.Metadata == nil {
-					result.Document.Metadata = make(map[string]interface{})
-				}
-				result.Document.Metadata["refined"] = true
-			}
-			combined = append(combined, result)
-		}
+		combined = dedupeResults(refineResults(ctx.Context, ctx.Refiner, combined, true))
+	}
 
-		// Also refine external results
-		for _, result := range external {
-			refinedContent, err := ctx.Refiner.Refine(ctx.Context, result.Document.Content)
-			if err == nil && refinedContent != "" {
-				result.Document.Content = refinedContent
-				if result.Document.Metadata == nil {
-					result.Document.Metadata = make(map[string]interface{})
-				}
-				result.Document.Metadata["refined"] = true
-			}
-			combined = append(combined, result)
-		}
-	} else {
-		// No refiner, just combine as is
-		combined = append(combined, internal...)
-		combined = append(combined, external...)
+	maxCombined := DefaultMaxCombinedResults
+	if ctx != nil && ctx.MaxCombined > 0 {
+		maxCombined = ctx.MaxCombined
+	}
+	if len(combined) > maxCombined {
+		combined = combined[:maxCombined]
 	}
 
 	logInfof("CRAG AmbiguousAction: returning %d combined results", len(combined))
@@ -0,0 +1,140 @@
+package crag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// newWebServer serves DuckDuckGo-shaped answers whose related topics depend on the query.
+func newWebServer(t *testing.T, topics map[string][]string) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		mu.Lock()
+		queries = append(queries, query)
+		mu.Unlock()
+		related := []map[string]string{}
+		for _, u := range topics[query] {
+			related = append(related, map[string]string{"Text": "about " + u, "FirstURL": u})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"RelatedTopics": related})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		out := append([]string(nil), queries...)
+		sort.Strings(out)
+		return out
+	}
+}
+
+func TestIncorrectAction_MergesRewriteVariants(t *testing.T) {
+	server, seen := newWebServer(t, map[string][]string{
+		"higress overview": {"https://a", "https://b"},
+		"higress gateway":  {"https://b", "https://c"},
+		"higress envoy":    {"https://c", "https://d"},
+	})
+	provider := &batchLLMProvider{responses: []string{"higress overview\nhigress gateway\nhigress envoy"}}
+	ctx := &ActionContext{
+		Query:         "what is higress",
+		Context:       context.Background(),
+		WebSearcher:   &WebSearcher{Provider: "duckduckgo", Endpoint: server.URL},
+		QueryRewriter: &QueryRewriter{Provider: provider, Variants: 3},
+	}
+
+	results := IncorrectAction(ctx)
+
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Document.ID)
+	}
+	if want := "[https://a https://b https://c https://d]"; fmt.Sprint(got) != want {
+		t.Errorf("web results = %v, want %s merged without duplicates", got, want)
+	}
+	if q := seen(); len(q) != 3 {
+		t.Errorf("web queries = %v, want one search per variant", q)
+	}
+}
+
+func TestAmbiguousAction_CapsWebCalls(t *testing.T) {
+	server, seen := newWebServer(t, map[string][]string{
+		"v1": {"https://a"},
+		"v2": {"https://a"},
+		"v3": {"https://b"},
+	})
+	provider := &batchLLMProvider{responses: []string{"v1\nv2\nv3"}}
+	ctx := &ActionContext{
+		Query:         "what is higress",
+		Context:       context.Background(),
+		WebSearcher:   &WebSearcher{Provider: "duckduckgo", Endpoint: server.URL},
+		QueryRewriter: &QueryRewriter{Provider: provider, Variants: 3},
+		MaxWebCalls:   2,
+	}
+
+	results := AmbiguousAction(ctx, nil, nil)
+
+	if q := seen(); fmt.Sprint(q) != "[v1 v2]" {
+		t.Errorf("web queries = %v, want only the first two variants", q)
+	}
+	if len(results) != 1 || results[0].Document.ID != "https://a" {
+		t.Errorf("results = %+v, want one de-duplicated web result", results)
+	}
+}
+
+func TestAmbiguousAction_DeduplicatesOverlap(t *testing.T) {
+	internal := []schema.SearchResult{
+		{Document: schema.Document{ID: "doc-1", Content: "Higress is a cloud native API gateway.", Metadata: map[string]interface{}{"chunk_title": "intro"}}, Score: 0.4},
+		{Document: schema.Document{ID: "doc-2", Content: "Higress supports wasm plugins."}, Score: 0.3},
+	}
+	external := []schema.SearchResult{
+		{Document: schema.Document{ID: "https://higress.io", Content: "Higress is a  cloud native API gateway.", Metadata: map[string]interface{}{"url": "https://higress.io"}}, Score: 0.9},
+		{Document: schema.Document{ID: "https://higress.io#dup", Content: "Another snippet of the same page", Metadata: map[string]interface{}{"url": "https://higress.io"}}, Score: 0.1},
+		{Document: schema.Document{ID: "https://envoyproxy.io", Content: "Envoy is an edge and service proxy.", Metadata: map[string]interface{}{"url": "https://envoyproxy.io"}}},
+	}
+
+	results := AmbiguousAction(&ActionContext{Context: context.Background()}, internal, external)
+
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.Document.ID)
+	}
+	if want := "[https://higress.io doc-2 https://envoyproxy.io]"; fmt.Sprint(ids) != want {
+		t.Errorf("results = %v, want %s with the higher-scored duplicate kept in place", ids, want)
+	}
+}
+
+func TestAmbiguousAction_PrefersRefinedAndCaps(t *testing.T) {
+	provider := &batchLLMProvider{responses: []string{"[1]\n• higress gateway\n[2]\n• higress gateway\n[3]\n• envoy proxy\n[4]\n• unrelated"}}
+	internal := []schema.SearchResult{
+		{Document: schema.Document{ID: "doc-1", Content: "Higress is a gateway."}, Score: 0.9},
+		{Document: schema.Document{ID: "doc-2", Content: "Higress, the gateway."}, Score: 0.2},
+	}
+	external := []schema.SearchResult{
+		{Document: schema.Document{ID: "https://envoyproxy.io", Content: "Envoy proxy", Metadata: map[string]interface{}{"url": "https://envoyproxy.io"}}},
+		{Document: schema.Document{ID: "https://example.com", Content: "unrelated", Metadata: map[string]interface{}{"url": "https://example.com"}}},
+	}
+	ctx := &ActionContext{Context: context.Background(), Refiner: &KnowledgeRefiner{Provider: provider, BatchSize: 4}, MaxCombined: 2}
+
+	results := AmbiguousAction(ctx, internal, external)
+
+	if len(results) != 2 {
+		t.Fatalf("results = %+v, want 2 after merging refined duplicates and capping", results)
+	}
+	if results[0].Document.ID != "doc-1" || results[0].Document.Content != "• higress gateway" {
+		t.Errorf("first result = %+v, want the higher-scored refined duplicate", results[0])
+	}
+	if refined, _ := results[0].Document.Metadata["refined"].(bool); !refined {
+		t.Errorf("first result metadata = %v, want refined marker kept", results[0].Document.Metadata)
+	}
+}
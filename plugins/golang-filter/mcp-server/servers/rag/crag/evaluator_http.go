@@ -17,6 +17,9 @@ type HTTPEvaluator struct {
     Client     *httpx.Client
     CorrectTh  float64
     IncorrectTh float64
+    // ThresholdsByIntent re-derives the verdict from the returned score when the
+    // intent carried by WithIntent has an override; otherwise the service verdict is used.
+    ThresholdsByIntent map[string]Thresholds
 }
 
 type evalReq struct {
@@ -42,6 +45,12 @@ func (h *HTTPEvaluator) Evaluate(ctx context.Context, query string, contextText
     if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
         return 0, VerdictAmbiguous, err
     }
+    global := Thresholds{Correct: h.CorrectTh, Incorrect: h.IncorrectTh}
+    if global.Correct == 0 { global.Correct = 0.7 }
+    if global.Incorrect == 0 { global.Incorrect = 0.3 }
+    if th, ok := resolveThresholds(ctx, global, h.ThresholdsByIntent); ok {
+        return er.Score, verdictForScore(er.Score, th), nil
+    }
     v := VerdictAmbiguous
     switch er.Verdict {
     case "correct":
@@ -24,3 +24,25 @@ func TestHTTPEvaluator_Evaluate(t *testing.T) {
     if err != nil { t.Fatalf("eval error: %v", err) }
     if verdict != VerdictCorrect || score <= 0.9 { t.Fatalf("unexpected: score=%v verdict=%v", score, verdict) }
 }
+
+func TestHTTPEvaluator_ThresholdsByIntent(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _ = json.NewEncoder(w).Encode(map[string]interface{}{"score": 0.75, "verdict": "correct"})
+    }))
+    defer srv.Close()
+
+    ev := &HTTPEvaluator{Endpoint: srv.URL, ThresholdsByIntent: map[string]Thresholds{"factoid": {Correct: 0.9}}}
+    tests := []struct {
+        intent string
+        want   Verdict
+    }{
+        {intent: "", want: VerdictCorrect},           // service verdict
+        {intent: "open-ended", want: VerdictCorrect}, // no override
+        {intent: "factoid", want: VerdictAmbiguous},  // 0.75 < 0.9
+    }
+    for _, tt := range tests {
+        _, verdict, err := ev.Evaluate(WithIntent(context.Background(), tt.intent), "q", "ctx")
+        if err != nil { t.Fatalf("eval error: %v", err) }
+        if verdict != tt.want { t.Errorf("intent %q: verdict=%v, want %v", tt.intent, verdict, tt.want) }
+    }
+}
@@ -2,7 +2,6 @@ package crag
 
 import (
 	"context"
-	"fmt"
 	"regexp"
 	"strconv"
 
@@ -15,6 +14,10 @@ type LLMEvaluator struct {
 	Provider    llm.Provider
 	CorrectTh   float64 // threshold for "correct" verdict (default 0.7)
 	IncorrectTh float64 // threshold for "incorrect" verdict (default 0.3)
+	// ThresholdsByIntent overrides the thresholds for the intent carried by WithIntent
+	ThresholdsByIntent map[string]Thresholds
+	// Prompts overrides the evaluation prompts; nil uses the built-in prompts
+	Prompts *llm.PromptRegistry
 }
 
 // systemPrompt guides the LLM on how to evaluate relevance
@@ -23,6 +26,11 @@ Rate how relevant the given document is to the query on a scale from 0 to 1.
 0 means completely irrelevant, 1 means perfectly relevant.
 Provide ONLY the score as a float between 0 and 1.`
 
+// evaluationUserPrompt carries the (query, document) pair to evaluate
+const evaluationUserPrompt = `Query: {{.Query}}
+
+Document: {{.Document}}`
+
 // Evaluate implements the Evaluator interface using LLM-based relevance scoring
 func (e *LLMEvaluator) Evaluate(ctx context.Context, query string, contextText string) (float64, Verdict, error) {
 	// Set default thresholds if not configured
@@ -36,11 +44,13 @@ func (e *LLMEvaluator) Evaluate(ctx context.Context, query string, contextText s
 	}
 
 	// Build the prompt
-	userPrompt := fmt.Sprintf("Query: %s\n\nDocument: %s", query, contextText)
-	fullPrompt := fmt.Sprintf("%s\n\n%s", systemPrompt, userPrompt)
+	messages, err := e.Prompts.Messages(PROMPT_EVALUATION_SYSTEM, PROMPT_EVALUATION, map[string]any{"Query": query, "Document": contextText})
+	if err != nil {
+		return 0.5, VerdictAmbiguous, err
+	}
 
 	// Call LLM
-	response, err := e.Provider.GenerateCompletion(ctx, fullPrompt)
+	response, err := e.Provider.GenerateChat(ctx, messages)
 	if err != nil {
 		logWarnf("LLMEvaluator: failed to call LLM: %v", err)
 		return 0.5, VerdictAmbiguous, err
@@ -62,15 +72,9 @@ func (e *LLMEvaluator) Evaluate(ctx context.Context, query string, contextText s
 		logWarnf("LLMEvaluator: failed to parse score from response: %s", response)
 	}
 
-	// Determine verdict based on thresholds
-	var verdict Verdict
-	if score >= correctTh {
-		verdict = VerdictCorrect
-	} else if score < incorrectTh {
-		verdict = VerdictIncorrect
-	} else {
-		verdict = VerdictAmbiguous
-	}
+	// Determine verdict based on thresholds, preferring the intent-specific ones
+	th, _ := resolveThresholds(ctx, Thresholds{Correct: correctTh, Incorrect: incorrectTh}, e.ThresholdsByIntent)
+	verdict := verdictForScore(score, th)
 
 	logInfof("LLMEvaluator: score=%.2f, verdict=%v", score, verdict)
 	return score, verdict, nil
@@ -3,12 +3,15 @@ package crag
 import (
 	"context"
 	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
 )
 
 // MockLLMProvider is a mock implementation of llm.Provider for testing
 type MockLLMProvider struct {
 	response string
 	err      error
+	messages []llm.ChatMessage
 }
 
 func (m *MockLLMProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
@@ -18,6 +21,11 @@ func (m *MockLLMProvider) GenerateCompletion(ctx context.Context, prompt string)
 	return m.response, nil
 }
 
+func (m *MockLLMProvider) GenerateChat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	m.messages = messages
+	return m.GenerateCompletion(ctx, llm.ConcatMessages(messages))
+}
+
 func (m *MockLLMProvider) GetProviderType() string {
 	return "mock"
 }
@@ -128,5 +136,39 @@ func TestLLMEvaluator_DefaultThresholds(t *testing.T) {
 	if verdict != VerdictCorrect {
 		t.Errorf("Expected verdict Correct, got %v", verdict)
 	}
+
+	if len(mockProvider.messages) != 2 || mockProvider.messages[0].Role != llm.ROLE_SYSTEM || mockProvider.messages[1].Role != llm.ROLE_USER {
+		t.Errorf("Expected system and user messages, got %+v", mockProvider.messages)
+	}
 }
 
+
+func TestLLMEvaluator_ThresholdsByIntent(t *testing.T) {
+	evaluator := &LLMEvaluator{
+		Provider:  &MockLLMProvider{response: "0.6"},
+		CorrectTh: 0.7,
+		ThresholdsByIntent: map[string]Thresholds{
+			"factoid":    {Correct: 0.8, Incorrect: 0.65},
+			"open-ended": {Correct: 0.5},
+		},
+	}
+
+	tests := []struct {
+		intent string
+		want   Verdict
+	}{
+		{intent: "", want: VerdictAmbiguous},
+		{intent: "factoid", want: VerdictIncorrect},
+		{intent: "open-ended", want: VerdictCorrect},
+		{intent: "unknown", want: VerdictAmbiguous},
+	}
+	for _, tt := range tests {
+		score, verdict, err := evaluator.Evaluate(WithIntent(context.Background(), tt.intent), "q", "doc")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if score != 0.6 || verdict != tt.want {
+			t.Errorf("intent %q: score=%v verdict=%v, want 0.6 and %v", tt.intent, score, verdict, tt.want)
+		}
+	}
+}
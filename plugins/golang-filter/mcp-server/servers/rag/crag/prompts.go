@@ -0,0 +1,28 @@
+package crag
+
+import "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+
+// Prompt template names of the CRAG LLM stages, overridable via pipeline.prompts. The system
+// template of a stage takes the same placeholders as its user template.
+const (
+	PROMPT_EVALUATION_SYSTEM = "crag_evaluation_system"
+	PROMPT_EVALUATION        = "crag_evaluation"
+	PROMPT_REWRITE_SYSTEM    = "crag_rewrite_system"
+	PROMPT_REWRITE           = "crag_rewrite"
+	PROMPT_REWRITE_N_SYSTEM  = "crag_rewrite_n_system"
+	PROMPT_REWRITE_N         = "crag_rewrite_n"
+	PROMPT_REFINE_SYSTEM     = "crag_refine_system"
+	PROMPT_REFINE            = "crag_refine"
+)
+
+func init() {
+	llm.RegisterPrompt(PROMPT_EVALUATION_SYSTEM, llm.PromptSpec{Default: systemPrompt, Fields: []string{"Query", "Document"}})
+	llm.RegisterPrompt(PROMPT_EVALUATION, llm.PromptSpec{Default: evaluationUserPrompt, Fields: []string{"Query", "Document"}, Required: []string{"Query", "Document"}})
+	llm.RegisterPrompt(PROMPT_REWRITE_SYSTEM, llm.PromptSpec{Default: rewriteSystemPrompt, Fields: []string{"Query"}})
+	llm.RegisterPrompt(PROMPT_REWRITE, llm.PromptSpec{Default: rewriteUserPrompt, Fields: []string{"Query"}, Required: []string{"Query"}})
+	// Count is the number of rewrites requested, so the system prompt must state it
+	llm.RegisterPrompt(PROMPT_REWRITE_N_SYSTEM, llm.PromptSpec{Default: rewriteNSystemPrompt, Fields: []string{"Count", "Query"}, Required: []string{"Count"}})
+	llm.RegisterPrompt(PROMPT_REWRITE_N, llm.PromptSpec{Default: rewriteNUserPrompt, Fields: []string{"Query", "Count"}, Required: []string{"Query"}})
+	llm.RegisterPrompt(PROMPT_REFINE_SYSTEM, llm.PromptSpec{Default: refineSystemPrompt, Fields: []string{"Text"}})
+	llm.RegisterPrompt(PROMPT_REFINE, llm.PromptSpec{Default: refineUserPrompt, Fields: []string{"Text"}, Required: []string{"Text"}})
+}
@@ -29,3 +29,58 @@ func (v Verdict) String() string {
 type Evaluator interface {
 	Evaluate(ctx context.Context, query string, contextText string) (score float64, verdict Verdict, err error)
 }
+
+// Thresholds are the score cut-offs for the correct and incorrect verdicts.
+type Thresholds struct {
+	Correct   float64
+	Incorrect float64
+}
+
+type intentKey struct{}
+
+// WithIntent returns a context carrying the query intent (router query type or
+// profile name) used to select per-intent thresholds.
+func WithIntent(ctx context.Context, intent string) context.Context {
+	if intent == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, intentKey{}, intent)
+}
+
+// IntentFromContext returns the intent set by WithIntent, or "".
+func IntentFromContext(ctx context.Context) string {
+	intent, _ := ctx.Value(intentKey{}).(string)
+	return intent
+}
+
+// resolveThresholds returns the thresholds for the intent in ctx, falling back to the
+// global thresholds. Zero values in an override inherit the global value. The bool
+// reports whether an intent override was applied.
+func resolveThresholds(ctx context.Context, global Thresholds, byIntent map[string]Thresholds) (Thresholds, bool) {
+	intent := IntentFromContext(ctx)
+	if intent == "" || len(byIntent) == 0 {
+		return global, false
+	}
+	override, ok := byIntent[intent]
+	if !ok {
+		return global, false
+	}
+	if override.Correct == 0 {
+		override.Correct = global.Correct
+	}
+	if override.Incorrect == 0 {
+		override.Incorrect = global.Incorrect
+	}
+	return override, true
+}
+
+// verdictForScore maps a score to a verdict using th.
+func verdictForScore(score float64, th Thresholds) Verdict {
+	if score >= th.Correct {
+		return VerdictCorrect
+	}
+	if score < th.Incorrect {
+		return VerdictIncorrect
+	}
+	return VerdictAmbiguous
+}
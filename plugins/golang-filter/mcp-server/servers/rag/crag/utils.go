@@ -3,6 +3,8 @@ package crag
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
@@ -12,12 +14,23 @@ import (
 // QueryRewriter rewrites queries to be more suitable for web search engines.
 type QueryRewriter struct {
 	Provider llm.Provider
+	// Variants is the number of rewrites the corrective actions search with; 0 or 1 keeps a single rewrite.
+	Variants int
+	// Prompts overrides the rewrite prompts; nil uses the built-in prompts.
+	Prompts *llm.PromptRegistry
 }
 
+// MaxRewriteVariants caps the number of rewrites RewriteN returns.
+const MaxRewriteVariants = 5
+
 const rewriteSystemPrompt = `You are an expert at creating effective search queries.
 Rewrite the given query to make it more suitable for a web search engine.
 Focus on keywords and facts, remove unnecessary words, and make it concise.`
 
+const rewriteUserPrompt = `Original query: {{.Query}}
+
+Rewritten query:`
+
 // Rewrite takes an original query and rewrites it for better search results.
 func (r *QueryRewriter) Rewrite(ctx context.Context, originalQuery string) (string, error) {
 	if r.Provider == nil {
@@ -25,10 +38,12 @@ func (r *QueryRewriter) Rewrite(ctx context.Context, originalQuery string) (stri
 		return originalQuery, nil
 	}
 
-	userPrompt := fmt.Sprintf("Original query: %s\n\nRewritten query:", originalQuery)
-	fullPrompt := fmt.Sprintf("%s\n\n%s", rewriteSystemPrompt, userPrompt)
+	messages, err := r.Prompts.Messages(PROMPT_REWRITE_SYSTEM, PROMPT_REWRITE, map[string]any{"Query": originalQuery})
+	if err != nil {
+		return originalQuery, err
+	}
 
-	response, err := r.Provider.GenerateCompletion(ctx, fullPrompt)
+	response, err := r.Provider.GenerateChat(ctx, messages)
 	if err != nil {
 		logWarnf("QueryRewriter: failed to rewrite query: %v, using original", err)
 		return originalQuery, err
@@ -43,15 +58,86 @@ func (r *QueryRewriter) Rewrite(ctx context.Context, originalQuery string) (stri
 	return rewritten, nil
 }
 
+const rewriteNSystemPrompt = `You are an expert at creating effective search queries.
+Rewrite the given query into {{.Count}} diverse web search queries that approach it from different angles,
+e.g. different keywords, synonyms or a more specific or more general phrasing.
+Output one query per line, with no numbering or explanations.`
+
+const rewriteNUserPrompt = `Original query: {{.Query}}
+
+Rewritten queries:`
+
+// rewriteVariantPrefix strips list numbering and bullets an LLM may add despite instructions.
+var rewriteVariantPrefix = regexp.MustCompile(`^\s*(?:\d+[.)]|[-*•])\s*`)
+
+// RewriteN returns up to n distinct rewrites of the query, capped at MaxRewriteVariants.
+// Duplicates are dropped case-insensitively; on failure the original query is returned alone.
+func (r *QueryRewriter) RewriteN(ctx context.Context, originalQuery string, n int) ([]string, error) {
+	if n > MaxRewriteVariants {
+		n = MaxRewriteVariants
+	}
+	if n <= 1 {
+		rewritten, err := r.Rewrite(ctx, originalQuery)
+		return []string{rewritten}, err
+	}
+	if r.Provider == nil {
+		logWarnf("QueryRewriter: no LLM provider, returning original query")
+		return []string{originalQuery}, nil
+	}
+
+	messages, err := r.Prompts.Messages(PROMPT_REWRITE_N_SYSTEM, PROMPT_REWRITE_N, map[string]any{"Query": originalQuery, "Count": n})
+	if err != nil {
+		return []string{originalQuery}, err
+	}
+
+	response, err := r.Provider.GenerateChat(ctx, messages)
+	if err != nil {
+		logWarnf("QueryRewriter: failed to rewrite query: %v, using original", err)
+		return []string{originalQuery}, err
+	}
+
+	variants := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for _, line := range strings.Split(response, "\n") {
+		variant := strings.Trim(strings.TrimSpace(rewriteVariantPrefix.ReplaceAllString(line, "")), `"`)
+		key := strings.ToLower(variant)
+		if variant == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		variants = append(variants, variant)
+		if len(variants) == n {
+			break
+		}
+	}
+	if len(variants) == 0 {
+		return []string{originalQuery}, nil
+	}
+
+	logInfof("QueryRewriter: '%s' -> %d variants", originalQuery, len(variants))
+	return variants, nil
+}
+
 // KnowledgeRefiner extracts and refines key information from text.
 type KnowledgeRefiner struct {
 	Provider llm.Provider
+	// BatchSize caps how many documents RefineBatch sends in one prompt; defaults to DefaultRefineBatchSize.
+	BatchSize int
+	// Prompts overrides the prompts of Refine; nil uses the built-in prompts.
+	Prompts *llm.PromptRegistry
 }
 
+// DefaultRefineBatchSize is the number of documents refined per LLM call by RefineBatch.
+const DefaultRefineBatchSize = 8
+
 const refineSystemPrompt = `Extract the key information from the following text as a set of clear, concise bullet points.
 Focus on the most relevant facts and important details.
 Format your response as a bulleted list with each point on a new line starting with "• ".`
 
+const refineUserPrompt = `Text to refine:
+
+{{.Text}}`
+
 // Refine extracts key information from input text and returns refined bullet points.
 func (kr *KnowledgeRefiner) Refine(ctx context.Context, text string) (string, error) {
 	if kr.Provider == nil {
@@ -59,10 +145,12 @@ func (kr *KnowledgeRefiner) Refine(ctx context.Context, text string) (string, er
 		return text, nil
 	}
 
-	userPrompt := fmt.Sprintf("Text to refine:\n\n%s", text)
-	fullPrompt := fmt.Sprintf("%s\n\n%s", refineSystemPrompt, userPrompt)
+	messages, err := kr.Prompts.Messages(PROMPT_REFINE_SYSTEM, PROMPT_REFINE, map[string]any{"Text": text})
+	if err != nil {
+		return text, err
+	}
 
-	response, err := kr.Provider.GenerateCompletion(ctx, fullPrompt)
+	response, err := kr.Provider.GenerateChat(ctx, messages)
 	if err != nil {
 		logWarnf("KnowledgeRefiner: failed to refine knowledge: %v, using original", err)
 		return text, err
@@ -77,19 +165,98 @@ func (kr *KnowledgeRefiner) Refine(ctx context.Context, text string) (string, er
 	return refined, nil
 }
 
+const refineBatchSystemPrompt = `Extract the key information from each of the numbered texts below as a set of clear, concise bullet points.
+Focus on the most relevant facts and important details.
+Answer every text in order. Start each answer with its number in brackets on its own line, e.g. "[1]",
+followed by a bulleted list with each point on a new line starting with "• ".`
+
+// refineBatchMarker matches the "[n]" line that opens each answer in a batch response.
+var refineBatchMarker = regexp.MustCompile(`(?m)^\s*\[(\d+)\]\s*$`)
+
+// RefineBatch refines several texts with one LLM call per BatchSize texts and returns the
+// refined texts in input order. Items the response does not answer keep their original
+// content; if a call fails, its texts are returned unchanged together with the error.
+func (kr *KnowledgeRefiner) RefineBatch(ctx context.Context, texts []string) ([]string, error) {
+	refined := append([]string(nil), texts...)
+	if kr.Provider == nil || len(texts) == 0 {
+		return refined, nil
+	}
+	size := kr.BatchSize
+	if size <= 0 {
+		size = DefaultRefineBatchSize
+	}
+
+	var firstErr error
+	for start := 0; start < len(texts); start += size {
+		end := start + size
+		if end > len(texts) {
+			end = len(texts)
+		}
+		if end-start == 1 {
+			out, err := kr.Refine(ctx, texts[start])
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			refined[start] = out
+			continue
+		}
+
+		var b strings.Builder
+		for i, text := range texts[start:end] {
+			fmt.Fprintf(&b, "[%d]\n%s\n\n", i+1, text)
+		}
+		messages := llm.SystemUserMessages(refineBatchSystemPrompt, "Texts to refine:\n\n"+b.String())
+		response, err := kr.Provider.GenerateChat(ctx, messages)
+		if err != nil {
+			logWarnf("KnowledgeRefiner: failed to refine batch of %d: %v, using originals", end-start, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		answers := parseRefineBatch(response, end-start)
+		missing := 0
+		for i, answer := range answers {
+			if answer == "" {
+				missing++
+				continue
+			}
+			refined[start+i] = answer
+		}
+		if missing > 0 {
+			logWarnf("KnowledgeRefiner: batch response missing %d of %d items, keeping their original content", missing, end-start)
+		}
+	}
+	logInfof("KnowledgeRefiner: refined %d documents in batches of %d", len(texts), size)
+	return refined, firstErr
+}
+
+// parseRefineBatch splits a numbered batch response into n answers; unanswered or
+// out-of-range numbers yield empty strings.
+func parseRefineBatch(response string, n int) []string {
+	answers := make([]string, n)
+	locs := refineBatchMarker.FindAllStringSubmatchIndex(response, -1)
+	for i, loc := range locs {
+		num, err := strconv.Atoi(response[loc[2]:loc[3]])
+		if err != nil || num < 1 || num > n || answers[num-1] != "" {
+			continue
+		}
+		end := len(response)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		answers[num-1] = strings.TrimSpace(response[loc[1]:end])
+	}
+	return answers
+}
+
 // CombineKnowledge combines internal (retrieved) and external (web search) knowledge.
 func CombineKnowledge(internalResults []schema.SearchResult, externalResults []schema.SearchResult, refiner *KnowledgeRefiner, ctx context.Context) ([]schema.SearchResult, error) {
 	combined := make([]schema.SearchResult, 0, len(internalResults)+len(externalResults))
 
 	// Refine internal results if refiner is available
 	if refiner != nil && refiner.Provider != nil {
-		for _, result := range internalResults {
-			refined, err := refiner.Refine(ctx, result.Document.Content)
-			if err == nil && refined != "" {
-				result.Document.Content = refined
-			}
-			combined = append(combined, result)
-		}
+		combined = append(combined, refineResults(ctx, refiner, internalResults, false)...)
 	} else {
 		combined = append(combined, internalResults...)
 	}
@@ -100,6 +267,55 @@ func CombineKnowledge(internalResults []schema.SearchResult, externalResults []s
 	return combined, nil
 }
 
+// dedupeResults drops results that share a URL or (whitespace- and case-normalized)
+// content with an earlier one. Of two duplicates the refined one wins, then the higher
+// score; the survivor takes the position of the first occurrence.
+func dedupeResults(results []schema.SearchResult) []schema.SearchResult {
+	out := make([]schema.SearchResult, 0, len(results))
+	index := make(map[string]int, len(results)*2)
+	for _, result := range results {
+		keys := dedupeKeys(result.Document)
+		pos, dup := -1, false
+		for _, key := range keys {
+			if pos, dup = index[key]; dup {
+				break
+			}
+		}
+		if !dup {
+			pos = len(out)
+			out = append(out, result)
+		} else if preferResult(result, out[pos]) {
+			out[pos] = result
+		}
+		for _, key := range keys {
+			if _, ok := index[key]; !ok {
+				index[key] = pos
+			}
+		}
+	}
+	return out
+}
+
+func dedupeKeys(doc schema.Document) []string {
+	var keys []string
+	if url, ok := doc.Metadata["url"].(string); ok && url != "" {
+		keys = append(keys, "url:"+url)
+	}
+	if content := strings.ToLower(strings.Join(strings.Fields(doc.Content), " ")); content != "" {
+		keys = append(keys, "content:"+content)
+	}
+	return keys
+}
+
+func preferResult(candidate, current schema.SearchResult) bool {
+	candidateRefined, _ := candidate.Document.Metadata["refined"].(bool)
+	currentRefined, _ := current.Document.Metadata["refined"].(bool)
+	if candidateRefined != currentRefined {
+		return candidateRefined
+	}
+	return candidate.Score > current.Score
+}
+
 // ExtractContent extracts text content from search results for evaluation or refinement.
 func ExtractContent(results []schema.SearchResult, limit int) string {
 	if limit <= 0 || limit > len(results) {
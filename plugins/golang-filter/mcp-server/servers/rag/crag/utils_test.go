@@ -0,0 +1,121 @@
+package crag
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// batchLLMProvider answers each GenerateChat call with the next scripted response.
+type batchLLMProvider struct {
+	responses []string
+	err       error
+	prompts   []string
+}
+
+func (m *batchLLMProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	m.prompts = append(m.prompts, prompt)
+	if m.err != nil {
+		return "", m.err
+	}
+	resp := m.responses[0]
+	m.responses = m.responses[1:]
+	return resp, nil
+}
+
+func (m *batchLLMProvider) GenerateChat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	return m.GenerateCompletion(ctx, llm.ConcatMessages(messages))
+}
+
+func (m *batchLLMProvider) GetProviderType() string {
+	return "mock"
+}
+
+func TestKnowledgeRefiner_RefineBatch(t *testing.T) {
+	provider := &batchLLMProvider{responses: []string{"[2]\n• second fact\n\n[1]\n• first fact\n[3]\n• third fact"}}
+	refiner := &KnowledgeRefiner{Provider: provider}
+
+	got, err := refiner.RefineBatch(context.Background(), []string{"doc one", "doc two", "doc three"})
+	if err != nil {
+		t.Fatalf("RefineBatch() error = %v", err)
+	}
+	want := []string{"• first fact", "• second fact", "• third fact"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("RefineBatch() = %q, want %q", got, want)
+	}
+	if len(provider.prompts) != 1 || !strings.Contains(provider.prompts[0], "[3]\ndoc three") {
+		t.Errorf("prompts = %q, want one numbered batch prompt", provider.prompts)
+	}
+}
+
+func TestKnowledgeRefiner_RefineBatchFallsBackPerItem(t *testing.T) {
+	provider := &batchLLMProvider{responses: []string{"[1]\n• first fact\n[7]\n• out of range", "• refined alone"}}
+	refiner := &KnowledgeRefiner{Provider: provider, BatchSize: 2}
+
+	got, err := refiner.RefineBatch(context.Background(), []string{"doc one", "doc two", "doc three"})
+	if err != nil {
+		t.Fatalf("RefineBatch() error = %v", err)
+	}
+	want := []string{"• first fact", "doc two", "• refined alone"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("RefineBatch() = %q, want %q", got, want)
+	}
+	if len(provider.prompts) != 2 {
+		t.Errorf("made %d LLM calls, want 2 for batch size 2", len(provider.prompts))
+	}
+}
+
+func TestKnowledgeRefiner_RefineBatchError(t *testing.T) {
+	refiner := &KnowledgeRefiner{Provider: &batchLLMProvider{err: errors.New("llm down")}}
+	got, err := refiner.RefineBatch(context.Background(), []string{"doc one", "doc two"})
+	if err == nil || strings.Join(got, "|") != "doc one|doc two" {
+		t.Errorf("RefineBatch() = %q, %v, want originals and the error", got, err)
+	}
+}
+
+func TestAmbiguousAction_RefinesInOneCall(t *testing.T) {
+	provider := &batchLLMProvider{responses: []string{"[1]\n• internal fact\n[2]\n• web fact"}}
+	actionCtx := &ActionContext{Refiner: &KnowledgeRefiner{Provider: provider}, Context: context.Background()}
+	internal := []schema.SearchResult{{Document: schema.Document{ID: "kb", Content: "internal doc"}}}
+	external := []schema.SearchResult{{Document: schema.Document{ID: "web", Content: "web doc"}}}
+
+	got := AmbiguousAction(actionCtx, internal, external)
+
+	if len(provider.prompts) != 1 {
+		t.Errorf("made %d LLM calls, want 1", len(provider.prompts))
+	}
+	if len(got) != 2 || got[0].Document.ID != "kb" || got[0].Document.Content != "• internal fact" || got[1].Document.Content != "• web fact" {
+		t.Fatalf("AmbiguousAction() = %+v, want refined internal then external", got)
+	}
+	if got[1].Document.Metadata["refined"] != true {
+		t.Errorf("metadata = %v, want refined marked", got[1].Document.Metadata)
+	}
+	if internal[0].Document.Content != "internal doc" {
+		t.Error("AmbiguousAction() must not modify the input slice")
+	}
+}
+
+func TestQueryRewriter_RewriteN(t *testing.T) {
+	provider := &batchLLMProvider{responses: []string{"1. higress gateway overview\n- Higress Gateway Overview\n\n\"higress envoy api gateway\"\n* higress wasm plugins\nextra variant"}}
+	rewriter := &QueryRewriter{Provider: provider}
+
+	got, err := rewriter.RewriteN(context.Background(), "what is higress", 3)
+	if err != nil {
+		t.Fatalf("RewriteN() error = %v", err)
+	}
+	want := []string{"higress gateway overview", "higress envoy api gateway", "higress wasm plugins"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("RewriteN() = %q, want %q", got, want)
+	}
+
+	provider = &batchLLMProvider{err: errors.New("llm down")}
+	rewriter = &QueryRewriter{Provider: provider}
+	got, err = rewriter.RewriteN(context.Background(), "what is higress", 3)
+	if err == nil || len(got) != 1 || got[0] != "what is higress" {
+		t.Errorf("RewriteN() = %q, %v, want the original query and an error", got, err)
+	}
+}
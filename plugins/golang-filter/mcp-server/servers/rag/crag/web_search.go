@@ -11,11 +11,25 @@ import (
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
 )
 
+// WEB_SEARCH_USER_AGENT is sent with every web search request
+const WEB_SEARCH_USER_AGENT = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+
+// Default endpoints of the web search providers that need an API key
+const (
+	GOOGLE_SEARCH_ENDPOINT  = "https://www.googleapis.com/customsearch/v1"
+	SERPAPI_SEARCH_ENDPOINT = "https://serpapi.com/search.json"
+)
+
+// googleMaxResults is the most results the Programmable Search JSON API returns per request
+const googleMaxResults = 10
+
 // WebSearcher performs web searches to retrieve external knowledge.
 type WebSearcher struct {
-	Provider string // e.g., "duckduckgo", "bing", "google"
+	Provider string // "duckduckgo" (default), "bing", "google" or "serpapi"
 	Endpoint string
 	APIKey   string
+	EngineID string // Programmable Search Engine ID (cx), required for google
+	Engine   string // search engine used by serpapi, "google" by default
 	Client   *httpx.Client
 }
 
@@ -40,6 +54,10 @@ func (w *WebSearcher) Search(ctx context.Context, query string, numResults int)
 		results, err = w.searchDuckDuckGo(ctx, query, numResults)
 	case "bing":
 		results, err = w.searchBing(ctx, query, numResults)
+	case "google":
+		results, err = w.searchGoogle(ctx, query, numResults)
+	case "serpapi":
+		results, err = w.searchSerpAPI(ctx, query, numResults)
 	default:
 		// Fallback to DuckDuckGo
 		logWarnf("WebSearcher: unknown provider %s, using DuckDuckGo", w.Provider)
@@ -49,6 +67,9 @@ func (w *WebSearcher) Search(ctx context.Context, query string, numResults int)
 	if err != nil {
 		return nil, fmt.Errorf("web search failed: %w", err)
 	}
+	if len(results) > numResults {
+		results = results[:numResults]
+	}
 
 	// Convert to schema.SearchResult
 	out := make([]schema.SearchResult, 0, len(results))
@@ -86,26 +107,6 @@ func (w *WebSearcher) searchDuckDuckGo(ctx context.Context, query string, numRes
 	q.Set("format", "json")
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-
-	if w.Client == nil {
-		w.Client = httpx.NewFromConfig(nil)
-	}
-
-	resp, err := w.Client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("duckduckgo api returned status %d", resp.StatusCode)
-	}
-
 	var ddgResp struct {
 		AbstractText   string `json:"AbstractText"`
 		AbstractSource string `json:"AbstractSource"`
@@ -116,7 +117,7 @@ func (w *WebSearcher) searchDuckDuckGo(ctx context.Context, query string, numRes
 		} `json:"RelatedTopics"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&ddgResp); err != nil {
+	if err := w.getJSON(ctx, "duckduckgo", u, nil, &ddgResp); err != nil {
 		return nil, err
 	}
 
@@ -173,26 +174,6 @@ func (w *WebSearcher) searchBing(ctx context.Context, query string, numResults i
 	q.Set("count", fmt.Sprintf("%d", numResults))
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Ocp-Apim-Subscription-Key", w.APIKey)
-
-	if w.Client == nil {
-		w.Client = httpx.NewFromConfig(nil)
-	}
-
-	resp, err := w.Client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("bing api returned status %d", resp.StatusCode)
-	}
-
 	var bingResp struct {
 		WebPages struct {
 			Value []struct {
@@ -203,7 +184,7 @@ func (w *WebSearcher) searchBing(ctx context.Context, query string, numResults i
 		} `json:"webPages"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&bingResp); err != nil {
+	if err := w.getJSON(ctx, "bing", u, map[string]string{"Ocp-Apim-Subscription-Key": w.APIKey}, &bingResp); err != nil {
 		return nil, err
 	}
 
@@ -219,3 +200,137 @@ func (w *WebSearcher) searchBing(ctx context.Context, query string, numResults i
 	logInfof("WebSearcher: Bing returned %d results for query: %s", len(results), query)
 	return results, nil
 }
+
+// searchGoogle performs a search with the Google Programmable Search Engine JSON API
+func (w *WebSearcher) searchGoogle(ctx context.Context, query string, numResults int) ([]WebSearchResult, error) {
+	if w.APIKey == "" || w.EngineID == "" {
+		return nil, fmt.Errorf("google search requires api key and engine id (cx)")
+	}
+	endpoint := GOOGLE_SEARCH_ENDPOINT
+	if w.Endpoint != "" {
+		endpoint = w.Endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set("q", query)
+	q.Set("cx", w.EngineID)
+	q.Set("num", fmt.Sprintf("%d", min(numResults, googleMaxResults)))
+	u.RawQuery = q.Encode()
+
+	var googleResp struct {
+		Items []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+		} `json:"items"`
+	}
+
+	// The key goes in a header, not the URL, so that logged URLs never contain it
+	if err := w.getJSON(ctx, "google", u, map[string]string{"X-Goog-Api-Key": w.APIKey}, &googleResp); err != nil {
+		return nil, err
+	}
+
+	results := make([]WebSearchResult, 0, len(googleResp.Items))
+	for _, item := range googleResp.Items {
+		results = append(results, WebSearchResult{
+			Title:   item.Title,
+			URL:     item.Link,
+			Snippet: item.Snippet,
+		})
+	}
+
+	logInfof("WebSearcher: Google returned %d results for query: %s", len(results), query)
+	return results, nil
+}
+
+// searchSerpAPI performs a search through SerpAPI
+func (w *WebSearcher) searchSerpAPI(ctx context.Context, query string, numResults int) ([]WebSearchResult, error) {
+	if w.APIKey == "" {
+		return nil, fmt.Errorf("serpapi search requires api key")
+	}
+	endpoint := SERPAPI_SEARCH_ENDPOINT
+	if w.Endpoint != "" {
+		endpoint = w.Endpoint
+	}
+	engine := w.Engine
+	if engine == "" {
+		engine = "google"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// SerpAPI only accepts the key as a query parameter; httpx redacts it from logged URLs
+	q := u.Query()
+	q.Set("q", query)
+	q.Set("engine", engine)
+	q.Set("num", fmt.Sprintf("%d", numResults))
+	q.Set("api_key", w.APIKey)
+	u.RawQuery = q.Encode()
+
+	var serpResp struct {
+		Error          string `json:"error"`
+		OrganicResults []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+		} `json:"organic_results"`
+	}
+
+	if err := w.getJSON(ctx, "serpapi", u, nil, &serpResp); err != nil {
+		return nil, err
+	}
+	if serpResp.Error != "" {
+		return nil, fmt.Errorf("serpapi returned error: %s", serpResp.Error)
+	}
+
+	results := make([]WebSearchResult, 0, len(serpResp.OrganicResults))
+	for _, r := range serpResp.OrganicResults {
+		results = append(results, WebSearchResult{
+			Title:   r.Title,
+			URL:     r.Link,
+			Snippet: r.Snippet,
+		})
+	}
+
+	logInfof("WebSearcher: SerpAPI returned %d results for query: %s", len(results), query)
+	return results, nil
+}
+
+// getJSON sends a GET request for u with WEB_SEARCH_USER_AGENT and headers through the
+// shared httpx client, so the pipeline's timeout and retry settings apply, and decodes
+// the JSON response into out.
+func (w *WebSearcher) getJSON(ctx context.Context, provider string, u *url.URL, headers map[string]string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", WEB_SEARCH_USER_AGENT)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	// Searches run concurrently, so a missing client is not stored on the searcher
+	client := w.Client
+	if client == nil {
+		client = httpx.NewFromConfig(nil)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s api returned status %d", provider, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
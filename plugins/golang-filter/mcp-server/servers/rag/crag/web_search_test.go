@@ -0,0 +1,98 @@
+package crag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newSearchAPIServer serves resultKey with n results and hands every request to inspect.
+func newSearchAPIServer(t *testing.T, resultKey string, n int, inspect func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inspect(r)
+		items := make([]map[string]string, n)
+		for i := range items {
+			items[i] = map[string]string{"title": fmt.Sprintf("title %d", i), "link": fmt.Sprintf("https://example.com/%d", i), "snippet": fmt.Sprintf("snippet %d", i)}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{resultKey: items})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebSearcher_Google(t *testing.T) {
+	var got *http.Request
+	server := newSearchAPIServer(t, "items", 4, func(r *http.Request) { got = r })
+	searcher := &WebSearcher{Provider: "google", Endpoint: server.URL, APIKey: "secret", EngineID: "engine-1"}
+
+	results, err := searcher.Search(context.Background(), "higress", 3)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results) != 3 || results[0].Document.ID != "https://example.com/0" || results[0].Document.Content != "snippet 0" ||
+		results[0].Document.Metadata["title"] != "title 0" {
+		t.Errorf("Search() = %+v, want the first 3 items", results)
+	}
+	q := got.URL.Query()
+	if q.Get("q") != "higress" || q.Get("cx") != "engine-1" || q.Get("num") != "3" {
+		t.Errorf("query = %v, want q, cx and num", q)
+	}
+	if got.Header.Get("X-Goog-Api-Key") != "secret" || strings.Contains(got.URL.RawQuery, "secret") {
+		t.Errorf("api key must be sent in the header only, url = %s", got.URL)
+	}
+	if got.Header.Get("User-Agent") != WEB_SEARCH_USER_AGENT {
+		t.Errorf("User-Agent = %q, want the default", got.Header.Get("User-Agent"))
+	}
+
+	if _, err := (&WebSearcher{Provider: "google", Endpoint: server.URL, APIKey: "secret"}).Search(context.Background(), "higress", 3); err == nil {
+		t.Error("Search() without an engine id should fail")
+	}
+}
+
+func TestWebSearcher_GoogleCapsNum(t *testing.T) {
+	var num string
+	server := newSearchAPIServer(t, "items", 10, func(r *http.Request) { num = r.URL.Query().Get("num") })
+	searcher := &WebSearcher{Provider: "google", Endpoint: server.URL, APIKey: "secret", EngineID: "engine-1"}
+
+	if _, err := searcher.Search(context.Background(), "higress", 20); err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if num != "10" {
+		t.Errorf("num = %s, want the API maximum of 10", num)
+	}
+}
+
+func TestWebSearcher_SerpAPI(t *testing.T) {
+	var got *http.Request
+	server := newSearchAPIServer(t, "organic_results", 5, func(r *http.Request) { got = r })
+	searcher := &WebSearcher{Provider: "serpapi", Endpoint: server.URL, APIKey: "secret"}
+
+	results, err := searcher.Search(context.Background(), "higress", 2)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results) != 2 || results[1].Document.ID != "https://example.com/1" || results[1].Document.Metadata["source"] != "web_search" {
+		t.Errorf("Search() = %+v, want the first 2 organic results", results)
+	}
+	q := got.URL.Query()
+	if q.Get("q") != "higress" || q.Get("engine") != "google" || q.Get("api_key") != "secret" || q.Get("num") != "2" {
+		t.Errorf("query = %v, want q, the default engine, api_key and num", q)
+	}
+}
+
+func TestWebSearcher_SerpAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": "Invalid API key."})
+	}))
+	defer server.Close()
+	searcher := &WebSearcher{Provider: "serpapi", Endpoint: server.URL, APIKey: "wrong", Engine: "bing"}
+
+	if _, err := searcher.Search(context.Background(), "higress", 3); err == nil || !strings.Contains(err.Error(), "Invalid API key") {
+		t.Errorf("Search() error = %v, want the serpapi error", err)
+	}
+}
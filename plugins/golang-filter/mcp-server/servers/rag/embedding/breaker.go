@@ -0,0 +1,73 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/logger"
+)
+
+const (
+	// DEFAULT_BREAKER_MAX_FAILURES is the number of consecutive failures that opens the breaker
+	DEFAULT_BREAKER_MAX_FAILURES = 5
+	// DEFAULT_BREAKER_COOLDOWN is how long the breaker stays open before a request is let through
+	DEFAULT_BREAKER_COOLDOWN = 5 * time.Second
+)
+
+// ErrCircuitOpen is returned without calling the provider while the breaker is open
+var ErrCircuitOpen = errors.New("embedding circuit open")
+
+// CircuitBreaker wraps a Provider and fails fast after MaxFailures consecutive
+// GetEmbedding errors. Once Cooldown has passed, requests reach the provider again: the
+// first success closes the breaker and the first failure opens it for another Cooldown.
+type CircuitBreaker struct {
+	Provider
+	maxFailures int32
+	cooldown    time.Duration
+	failures    atomic.Int32
+	openUntil   atomic.Int64 // unix nanos
+}
+
+// NewCircuitBreaker wraps p; non-positive maxFailures and cooldown use the defaults
+func NewCircuitBreaker(p Provider, maxFailures int, cooldown time.Duration) *CircuitBreaker {
+	if maxFailures <= 0 {
+		maxFailures = DEFAULT_BREAKER_MAX_FAILURES
+	}
+	if cooldown <= 0 {
+		cooldown = DEFAULT_BREAKER_COOLDOWN
+	}
+	return &CircuitBreaker{Provider: p, maxFailures: int32(maxFailures), cooldown: cooldown}
+}
+
+// Open reports whether requests are currently rejected
+func (b *CircuitBreaker) Open() bool {
+	return b.openUntil.Load() > time.Now().UnixNano()
+}
+
+func (b *CircuitBreaker) GetEmbedding(ctx context.Context, queryString string) ([]float32, error) {
+	if b.Open() {
+		return nil, ErrCircuitOpen
+	}
+	vector, err := b.Provider.GetEmbedding(ctx, queryString)
+	if err != nil {
+		// A canceled request says nothing about the provider's health
+		if ctx.Err() == nil {
+			b.recordFailure()
+		}
+		return nil, err
+	}
+	if b.failures.Swap(0) >= b.maxFailures {
+		logger.Infof("embedding: %s provider recovered, circuit closed", b.GetProviderType())
+	}
+	return vector, nil
+}
+
+func (b *CircuitBreaker) recordFailure() {
+	if failures := b.failures.Add(1); failures >= b.maxFailures {
+		b.openUntil.Store(time.Now().Add(b.cooldown).UnixNano())
+		logger.Warnf("embedding: %s provider failed %d consecutive times, circuit open for %v",
+			b.GetProviderType(), failures, b.cooldown)
+	}
+}
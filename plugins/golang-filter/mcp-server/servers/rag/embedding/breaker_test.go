@@ -0,0 +1,56 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	p := &sequentialProvider{}
+	b := NewCircuitBreaker(p, 2, 20*time.Millisecond)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := b.GetEmbedding(ctx, "fail"); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("GetEmbedding() #%d error = %v, want the provider error", i, err)
+		}
+	}
+	if !b.Open() {
+		t.Fatal("breaker should open after 2 consecutive failures")
+	}
+	if _, err := b.GetEmbedding(ctx, "ok"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("GetEmbedding() while open error = %v, want ErrCircuitOpen", err)
+	}
+
+	// After the cooldown a single failure opens the breaker again
+	time.Sleep(30 * time.Millisecond)
+	if _, err := b.GetEmbedding(ctx, "fail"); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("GetEmbedding() after cooldown error = %v, want the provider error", err)
+	}
+	if !b.Open() {
+		t.Fatal("breaker should reopen on the first failure after the cooldown")
+	}
+
+	// A success after the cooldown closes it
+	time.Sleep(30 * time.Millisecond)
+	if _, err := b.GetEmbedding(ctx, "ok"); err != nil {
+		t.Fatalf("GetEmbedding() after recovery error = %v", err)
+	}
+	if _, err := b.GetEmbedding(ctx, "fail"); err == nil || b.Open() {
+		t.Error("breaker should need 2 new failures to open once closed")
+	}
+}
+
+func TestCircuitBreaker_IgnoresCanceledRequests(t *testing.T) {
+	b := NewCircuitBreaker(&sequentialProvider{}, 1, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.GetEmbedding(ctx, "fail"); err == nil {
+		t.Fatal("GetEmbedding() expected the provider error")
+	}
+	if b.Open() {
+		t.Error("a canceled request must not open the breaker")
+	}
+}
@@ -0,0 +1,155 @@
+package embedding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/cache"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+const (
+	// DEFAULT_CACHE_MAX_ENTRIES is the number of vectors the in-memory cache keeps by default
+	DEFAULT_CACHE_MAX_ENTRIES = 10000
+	// DEFAULT_CACHE_TTL is how long cached vectors are kept by default
+	DEFAULT_CACHE_TTL = time.Hour
+)
+
+// VectorCache is a shared store of embedding vectors, such as Redis, consulted when the
+// in-memory cache misses. Implementations must be safe for concurrent use and treat
+// their failures as misses.
+type VectorCache interface {
+	Get(key string) ([]float32, bool)
+	Set(key string, vector []float32, ttl time.Duration)
+}
+
+// CachedProvider wraps a Provider and serves the vectors of texts embedded before from a
+// size- and TTL-bounded LRU, backed by an optional shared VectorCache. Vectors are keyed by
+// a hash of the provider, model, dimensions, input type and text, so a model change never
+// serves stale vectors. It is safe for concurrent use.
+type CachedProvider struct {
+	Provider
+	model  string
+	l1     cache.Cache
+	l2     VectorCache
+	ttl    time.Duration
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// Close closes the shared VectorCache when it holds connections
+func (c *CachedProvider) Close() error {
+	if closer, ok := c.l2.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// NewCachedProvider wraps p with the cache configured by cfg.Cache; l2 may be nil
+func NewCachedProvider(p Provider, cfg config.EmbeddingConfig, l2 VectorCache) *CachedProvider {
+	maxEntries, ttl := DEFAULT_CACHE_MAX_ENTRIES, DEFAULT_CACHE_TTL
+	if cfg.Cache != nil {
+		if cfg.Cache.MaxEntries > 0 {
+			maxEntries = cfg.Cache.MaxEntries
+		}
+		if cfg.Cache.TTLSeconds > 0 {
+			ttl = time.Duration(cfg.Cache.TTLSeconds) * time.Second
+		}
+	}
+	model := cfg.Provider + "\x00" + cfg.Model + "\x00" + strconv.Itoa(cfg.Dimensions) + "\x00" + cfg.InputType
+	return &CachedProvider{Provider: p, model: model, l1: cache.NewLRU(maxEntries, ttl), l2: l2, ttl: ttl}
+}
+
+// key returns the cache key of text
+func (c *CachedProvider) key(text string) string {
+	sum := sha256.Sum256([]byte(c.model + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookup returns the cached vector of key, promoting vectors found in l2 to l1
+func (c *CachedProvider) lookup(key string) ([]float32, bool) {
+	if v, ok := c.l1.Get(key); ok {
+		c.hits.Add(1)
+		return append([]float32(nil), v.([]float32)...), true
+	}
+	if c.l2 != nil {
+		if vector, ok := c.l2.Get(key); ok {
+			c.hits.Add(1)
+			c.l1.Set(key, vector, c.ttl)
+			return append([]float32(nil), vector...), true
+		}
+	}
+	c.misses.Add(1)
+	return nil, false
+}
+
+// store caches a copy of vector under key
+func (c *CachedProvider) store(key string, vector []float32) {
+	vector = append([]float32(nil), vector...)
+	c.l1.Set(key, vector, c.ttl)
+	if c.l2 != nil {
+		c.l2.Set(key, vector, c.ttl)
+	}
+}
+
+func (c *CachedProvider) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	key := c.key(text)
+	if vector, ok := c.lookup(key); ok {
+		return vector, nil
+	}
+	vector, err := c.Provider.GetEmbedding(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, vector)
+	return vector, nil
+}
+
+// GetEmbeddings serves the cached texts and embeds the others with the wrapped provider,
+// batched when it supports batching
+func (c *CachedProvider) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	var missed []string
+	var missedIndexes []int
+	for i, text := range texts {
+		keys[i] = c.key(text)
+		if vector, ok := c.lookup(keys[i]); ok {
+			vectors[i] = vector
+			continue
+		}
+		missed = append(missed, text)
+		missedIndexes = append(missedIndexes, i)
+	}
+	if len(missed) == 0 {
+		return vectors, nil
+	}
+	embedded, err := GetEmbeddings(ctx, c.Provider, missed)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range missedIndexes {
+		vectors[i] = embedded[j]
+		c.store(keys[i], embedded[j])
+	}
+	return vectors, nil
+}
+
+// MaxBatchSize returns the batch size of the wrapped provider, or DEFAULT_MAX_BATCH_SIZE
+// when it does not batch
+func (c *CachedProvider) MaxBatchSize() int {
+	if bp, ok := c.Provider.(BatchProvider); ok {
+		return bp.MaxBatchSize()
+	}
+	return DEFAULT_MAX_BATCH_SIZE
+}
+
+// Stats returns the number of cache hits and misses so far
+func (c *CachedProvider) Stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
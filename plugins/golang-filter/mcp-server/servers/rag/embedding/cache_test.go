@@ -0,0 +1,105 @@
+package embedding
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+// countingProvider embeds a text as its length and records the texts it embeds
+type countingProvider struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (p *countingProvider) GetProviderType() string { return "counting" }
+
+func (p *countingProvider) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	p.mu.Lock()
+	p.texts = append(p.texts, text)
+	p.mu.Unlock()
+	return []float32{float32(len(text)), 1}, nil
+}
+
+func (p *countingProvider) GetDimensions(ctx context.Context) (int, error) { return 2, nil }
+
+// mapVectorCache is an in-memory shared VectorCache
+type mapVectorCache struct {
+	mu      sync.Mutex
+	vectors map[string][]float32
+}
+
+func (c *mapVectorCache) Get(key string) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	vector, ok := c.vectors[key]
+	return vector, ok
+}
+
+func (c *mapVectorCache) Set(key string, vector []float32, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.vectors[key] = vector
+}
+
+func TestCachedProvider(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingProvider{}
+	cfg := config.EmbeddingConfig{Provider: "counting", Model: "m1", Cache: &config.CacheLayerConfig{Enable: true, MaxEntries: 2}}
+	p := NewCachedProvider(inner, cfg, nil)
+
+	first, _ := p.GetEmbedding(ctx, "higress")
+	first[0] = 0 // callers may modify the vectors they get
+	again, err := p.GetEmbedding(ctx, "higress")
+	if err != nil || again[0] != 7 || len(inner.texts) != 1 {
+		t.Fatalf("GetEmbedding() = %v, %v after embedding %q, want the cached vector", again, err, inner.texts)
+	}
+
+	// Batches embed only the texts missing the cache, in input order
+	vectors, err := GetEmbeddings(ctx, p, []string{"wasm", "higress", "gateway"})
+	if err != nil || len(vectors) != 3 || vectors[0][0] != 4 || vectors[1][0] != 7 || vectors[2][0] != 7 {
+		t.Fatalf("GetEmbeddings() = %v, %v", vectors, err)
+	}
+	if embedded := inner.texts[1:]; len(embedded) != 2 || !slices.Contains(embedded, "wasm") || !slices.Contains(embedded, "gateway") {
+		t.Errorf("embedded %q, want wasm and gateway only", embedded)
+	}
+	if hits, misses := p.Stats(); hits != 2 || misses != 3 {
+		t.Errorf("Stats() = %d hits, %d misses, want 2 and 3", hits, misses)
+	}
+
+	// The LRU holds two vectors, so the least recently used one was evicted
+	if _, err := p.GetEmbedding(ctx, "higress"); err != nil || len(inner.texts) != 4 {
+		t.Errorf("embedded %q, want higress embedded again after eviction", inner.texts)
+	}
+
+	// Another model does not share the vectors
+	other := NewCachedProvider(inner, config.EmbeddingConfig{Provider: "counting", Model: "m2"}, nil)
+	if p.key("wasm") == other.key("wasm") {
+		t.Error("cache keys of different models should differ")
+	}
+}
+
+func TestCachedProvider_SharedCache(t *testing.T) {
+	ctx := context.Background()
+	shared := &mapVectorCache{vectors: map[string][]float32{}}
+	cfg := config.EmbeddingConfig{Provider: "counting", Model: "m1"}
+	first := &countingProvider{}
+	if _, err := NewCachedProvider(first, cfg, shared).GetEmbedding(ctx, "higress"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance finds the vector in the shared cache
+	second := &countingProvider{}
+	p := NewCachedProvider(second, cfg, shared)
+	vector, err := p.GetEmbedding(ctx, "higress")
+	if err != nil || vector[0] != 7 || len(second.texts) != 0 {
+		t.Errorf("GetEmbedding() = %v, %v after embedding %q, want the shared vector", vector, err, second.texts)
+	}
+	if hits, _ := p.Stats(); hits != 1 {
+		t.Errorf("hits = %d, want 1", hits)
+	}
+}
@@ -0,0 +1,126 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+const (
+	COHERE_DEFAULT_BASE_URL   = "https://api.cohere.com"
+	COHERE_DEFAULT_MODEL_NAME = "embed-multilingual-v3.0"
+	COHERE_EMBED_PATH         = "/v1/embed"
+	// Cohere v3 models require an input_type; documents are the common case for a RAG store
+	COHERE_DEFAULT_INPUT_TYPE = "search_document"
+	// COHERE_MAX_BATCH_SIZE is the most texts the embed endpoint accepts per request
+	COHERE_MAX_BATCH_SIZE = 96
+)
+
+var cohereInputTypes = map[string]bool{
+	"search_document": true,
+	"search_query":    true,
+	"classification":  true,
+	"clustering":      true,
+}
+
+type cohereProviderInitializer struct {
+}
+
+func (c *cohereProviderInitializer) validateConfig(config *config.EmbeddingConfig) error {
+	if config.APIKey == "" {
+		return errors.New("[cohere embedding] apiKey is required")
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = COHERE_DEFAULT_BASE_URL
+	}
+	if config.Model == "" {
+		config.Model = COHERE_DEFAULT_MODEL_NAME
+	}
+	if config.InputType == "" {
+		config.InputType = COHERE_DEFAULT_INPUT_TYPE
+	}
+	if !cohereInputTypes[config.InputType] {
+		return fmt.Errorf("[cohere embedding] unsupported input_type %q", config.InputType)
+	}
+	if config.Dimensions < 0 {
+		return errors.New("[cohere embedding] dimensions must not be negative")
+	}
+	return nil
+}
+
+func (c *cohereProviderInitializer) CreateProvider(config config.EmbeddingConfig) (Provider, error) {
+	if err := c.validateConfig(&config); err != nil {
+		return nil, err
+	}
+	return &CohereProvider{
+		client:     &http.Client{Timeout: HTTP_EMBEDDING_DEFAULT_TIMEOUT},
+		endpoint:   strings.TrimRight(config.BaseURL, "/") + COHERE_EMBED_PATH,
+		apiKey:     config.APIKey,
+		model:      config.Model,
+		inputType:  config.InputType,
+		dimensions: config.Dimensions,
+		maxBatch:   maxBatchSize(config.MaxBatchSize, COHERE_MAX_BATCH_SIZE),
+	}, nil
+}
+
+// CohereProvider calls the Cohere embed endpoint
+type CohereProvider struct {
+	client     *http.Client
+	endpoint   string
+	apiKey     string
+	model      string
+	inputType  string
+	dimensions int
+	maxBatch   int
+}
+
+type cohereEmbedRequest struct {
+	Texts     []string `json:"texts"`
+	Model     string   `json:"model"`
+	InputType string   `json:"input_type"`
+}
+
+type cohereEmbedResponse struct {
+	ID         string      `json:"id"`
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+func (e *CohereProvider) GetProviderType() string {
+	return PROVIDER_TYPE_COHERE
+}
+
+// GetEmbedding generates vector embedding for the given text
+func (e *CohereProvider) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := e.GetEmbeddings(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// GetEmbeddings generates vector embeddings for all texts in one request
+func (e *CohereProvider) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	req := cohereEmbedRequest{Texts: texts, Model: e.model, InputType: e.inputType}
+	var resp cohereEmbedResponse
+	if err := postJSON(ctx, e.client, e.endpoint, e.apiKey, req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+	}
+	if err := checkBatch(resp.Embeddings, len(texts), e.dimensions); err != nil {
+		return nil, err
+	}
+	return resp.Embeddings, nil
+}
+
+// MaxBatchSize returns the most texts sent in one embed request
+func (e *CohereProvider) MaxBatchSize() int {
+	return e.maxBatch
+}
+
+// GetDimensions returns the actual output dimension by embedding a probe string
+func (e *CohereProvider) GetDimensions(ctx context.Context) (int, error) {
+	return probeDimensions(ctx, e)
+}
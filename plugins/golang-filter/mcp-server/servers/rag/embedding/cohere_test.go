@@ -0,0 +1,88 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+// Recorded from the Cohere v1 embed endpoint, vectors shortened to four dimensions
+const cohereRecordedResponse = `{
+  "id": "3a2a7d4c-6f0e-4c1b-9b7e-0c0f6c2c6b11",
+  "texts": ["higress gateway", "wasm plugins"],
+  "embeddings": [
+    [0.0213, -0.0471, 0.0125, 0.0532],
+    [-0.0108, 0.0327, 0.0419, -0.0066]
+  ],
+  "meta": {"api_version": {"version": "1"}, "billed_units": {"input_tokens": 5}},
+  "response_type": "embeddings_floats"
+}`
+
+func TestCohereProvider_GetEmbeddings(t *testing.T) {
+	var got cohereEmbedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != COHERE_EMBED_PATH {
+			t.Errorf("path = %s, want %s", r.URL.Path, COHERE_EMBED_PATH)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(cohereRecordedResponse))
+	}))
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{
+		Provider: PROVIDER_TYPE_COHERE,
+		APIKey:   "test-key",
+		BaseURL:  server.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+
+	vectors, err := GetEmbeddings(context.Background(), provider, []string{"higress gateway", "wasm plugins"})
+	if err != nil {
+		t.Fatalf("GetEmbeddings() error = %v", err)
+	}
+	if len(vectors) != 2 || len(vectors[0]) != 4 || vectors[1][2] != 0.0419 {
+		t.Errorf("GetEmbeddings() = %v, want the recorded vectors", vectors)
+	}
+	if got.InputType != COHERE_DEFAULT_INPUT_TYPE || got.Model != COHERE_DEFAULT_MODEL_NAME || len(got.Texts) != 2 {
+		t.Errorf("request = %+v, want defaults with both texts", got)
+	}
+}
+
+func TestCohereProvider_DimensionMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(cohereRecordedResponse))
+	}))
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{
+		Provider:   PROVIDER_TYPE_COHERE,
+		APIKey:     "test-key",
+		BaseURL:    server.URL,
+		Dimensions: 1024,
+	})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+	if _, err := provider.GetEmbedding(context.Background(), "higress gateway"); err == nil {
+		t.Error("GetEmbedding() expected error when the response does not match configured dimensions")
+	}
+}
+
+func TestCohereProvider_ValidateConfig(t *testing.T) {
+	if _, err := NewEmbeddingProvider(config.EmbeddingConfig{Provider: PROVIDER_TYPE_COHERE}); err == nil {
+		t.Error("expected error for missing api key")
+	}
+	if _, err := NewEmbeddingProvider(config.EmbeddingConfig{Provider: PROVIDER_TYPE_COHERE, APIKey: "k", InputType: "query"}); err == nil {
+		t.Error("expected error for unsupported input_type")
+	}
+}
@@ -0,0 +1,137 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+const (
+	HTTP_EMBEDDING_DEFAULT_TIMEOUT = 60 * time.Second
+)
+
+type httpProviderInitializer struct {
+}
+
+func (c *httpProviderInitializer) validateConfig(config *config.EmbeddingConfig) error {
+	if config.BaseURL == "" {
+		return errors.New("[http embedding] base_url is required")
+	}
+	if !strings.HasPrefix(config.BaseURL, "http://") && !strings.HasPrefix(config.BaseURL, "https://") {
+		return fmt.Errorf("[http embedding] base_url must be an http(s) URL, got %q", config.BaseURL)
+	}
+	if config.Dimensions < 0 {
+		return errors.New("[http embedding] dimensions must not be negative")
+	}
+	return nil
+}
+
+func (c *httpProviderInitializer) CreateProvider(config config.EmbeddingConfig) (Provider, error) {
+	if err := c.validateConfig(&config); err != nil {
+		return nil, err
+	}
+	return &HTTPProvider{
+		client:     &http.Client{Timeout: HTTP_EMBEDDING_DEFAULT_TIMEOUT},
+		endpoint:   config.BaseURL,
+		apiKey:     config.APIKey,
+		model:      config.Model,
+		dimensions: config.Dimensions,
+		maxBatch:   maxBatchSize(config.MaxBatchSize, DEFAULT_MAX_BATCH_SIZE),
+	}, nil
+}
+
+// HTTPProvider calls a self-hosted embedding service that accepts {"texts": [...]}
+// and answers with {"embeddings": [[...], ...]} in input order
+type HTTPProvider struct {
+	client     *http.Client
+	endpoint   string
+	apiKey     string
+	model      string
+	dimensions int
+	maxBatch   int
+}
+
+type httpEmbeddingRequest struct {
+	Texts []string `json:"texts"`
+	Model string   `json:"model,omitempty"`
+}
+
+type httpEmbeddingResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+func (e *HTTPProvider) GetProviderType() string {
+	return PROVIDER_TYPE_HTTP
+}
+
+// GetEmbedding generates vector embedding for the given text
+func (e *HTTPProvider) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := e.GetEmbeddings(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// GetEmbeddings generates vector embeddings for all texts in one request
+func (e *HTTPProvider) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	var resp httpEmbeddingResponse
+	if err := postJSON(ctx, e.client, e.endpoint, e.apiKey, httpEmbeddingRequest{Texts: texts, Model: e.model}, &resp); err != nil {
+		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+	}
+	if err := checkBatch(resp.Embeddings, len(texts), e.dimensions); err != nil {
+		return nil, err
+	}
+	return resp.Embeddings, nil
+}
+
+// MaxBatchSize returns the most texts sent in one request
+func (e *HTTPProvider) MaxBatchSize() int {
+	return e.maxBatch
+}
+
+// GetDimensions returns the actual output dimension by embedding a probe string
+func (e *HTTPProvider) GetDimensions(ctx context.Context) (int, error) {
+	return probeDimensions(ctx, e)
+}
+
+// postJSON sends body as JSON to url with an optional bearer token and decodes the JSON reply into out
+func postJSON(ctx context.Context, client *http.Client, url, apiKey string, body any, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
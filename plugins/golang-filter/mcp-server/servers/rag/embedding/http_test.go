@@ -0,0 +1,111 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+// Recorded from a self-hosted sentence-transformers service, vectors shortened to three dimensions
+const httpRecordedResponse = `{"embeddings": [[0.12, -0.34, 0.56], [0.78, 0.9, -0.11], [-0.21, 0.43, 0.65]]}`
+
+func TestHTTPProvider_GetEmbeddings(t *testing.T) {
+	var got httpEmbeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embed" {
+			t.Errorf("path = %s, want /embed", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(httpRecordedResponse))
+	}))
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{
+		Provider:   PROVIDER_TYPE_HTTP,
+		BaseURL:    server.URL + "/embed",
+		Dimensions: 3,
+	})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+
+	texts := []string{"a", "b", "c"}
+	vectors, err := GetEmbeddings(context.Background(), provider, texts)
+	if err != nil {
+		t.Fatalf("GetEmbeddings() error = %v", err)
+	}
+	if len(vectors) != 3 || vectors[1][0] != 0.78 {
+		t.Errorf("GetEmbeddings() = %v, want the recorded vectors", vectors)
+	}
+	if len(got.Texts) != 3 {
+		t.Errorf("request texts = %v, want all inputs in one request", got.Texts)
+	}
+}
+
+func TestHTTPProvider_InconsistentDimensions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"embeddings": [[0.1, 0.2, 0.3], [0.4, 0.5]]}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{Provider: PROVIDER_TYPE_HTTP, BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+	if _, err := GetEmbeddings(context.Background(), provider, []string{"a", "b"}); err == nil {
+		t.Error("GetEmbeddings() expected error for vectors of different dimensions")
+	}
+}
+
+func TestHTTPProvider_GetDimensions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"embeddings": [[0.1, 0.2, 0.3, 0.4, 0.5]]}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{Provider: PROVIDER_TYPE_HTTP, BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+	dim, err := provider.GetDimensions(context.Background())
+	if err != nil || dim != 5 {
+		t.Errorf("GetDimensions() = %d, %v, want 5", dim, err)
+	}
+}
+
+func TestHTTPProvider_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{Provider: PROVIDER_TYPE_HTTP, BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+	if _, err := provider.GetEmbedding(context.Background(), "a"); err == nil {
+		t.Error("GetEmbedding() expected error for non-200 status")
+	}
+	if _, err := NewEmbeddingProvider(config.EmbeddingConfig{Provider: PROVIDER_TYPE_HTTP}); err == nil {
+		t.Error("expected error for missing base_url")
+	}
+}
+
+func TestGetEmbeddings_OpenAI(t *testing.T) {
+	server := newFakeEmbeddingServer(t, 8)
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{Provider: PROVIDER_TYPE_OPENAI, APIKey: "k", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+	vectors, err := GetEmbeddings(context.Background(), provider, []string{"a", "b"})
+	if err != nil || len(vectors) != 2 || len(vectors[1]) != 8 {
+		t.Errorf("GetEmbeddings() = %v, %v, want two 8-dim vectors", vectors, err)
+	}
+}
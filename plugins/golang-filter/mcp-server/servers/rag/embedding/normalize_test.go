@@ -0,0 +1,25 @@
+package embedding
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeL2(t *testing.T) {
+	got := NormalizeL2([]float32{3, 4})
+	if !reflect.DeepEqual(got, []float32{0.6, 0.8}) {
+		t.Errorf("NormalizeL2([3 4]) = %v, want [0.6 0.8]", got)
+	}
+	var norm float64
+	for _, v := range NormalizeL2([]float32{1, -2, 0.5, 7}) {
+		norm += float64(v) * float64(v)
+	}
+	if math.Abs(norm-1) > 1e-6 {
+		t.Errorf("squared norm = %v, want 1", norm)
+	}
+	zero := []float32{0, 0}
+	if got := NormalizeL2(zero); !reflect.DeepEqual(got, zero) {
+		t.Errorf("NormalizeL2(zero) = %v, want it unchanged", got)
+	}
+}
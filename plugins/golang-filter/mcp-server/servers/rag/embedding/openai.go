@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
 	"github.com/openai/openai-go/v2"
@@ -24,8 +25,8 @@ func (c *openAIProviderInitializer) validateConfig(config *config.EmbeddingConfi
 	if config.Model == "" {
 		config.Model = OPENAI_DEFAULT_MODEL_NAME
 	}
-	if config.Dimensions <= 0 {
-		config.Dimensions = 1536
+	if config.Dimensions < 0 {
+		return errors.New("[openai embbeding] dimensions must not be negative")
 	}
 
 	return nil
@@ -43,6 +44,7 @@ func (c *openAIProviderInitializer) CreateProvider(config config.EmbeddingConfig
 	if config.BaseURL != "" {
 		clientOptions = append(clientOptions, option.WithBaseURL(config.BaseURL))
 	}
+	clientOptions = append(clientOptions, headerOptions(config.Headers)...)
 	// 创建 OpenAI 客户端
 	client := openai.NewClient(clientOptions...)
 
@@ -50,14 +52,29 @@ func (c *openAIProviderInitializer) CreateProvider(config config.EmbeddingConfig
 		client:     &client,
 		model:      config.Model,
 		dimensions: config.Dimensions,
+		maxBatch:   maxBatchSize(config.MaxBatchSize, DEFAULT_MAX_BATCH_SIZE),
 	}, nil
 }
 
+// headerOptions adds the configured extra headers to every request. Authorization and
+// Content-Type are left to the client, so the headers merge with them instead of replacing them.
+func headerOptions(headers map[string]string) []option.RequestOption {
+	opts := make([]option.RequestOption, 0, len(headers))
+	for k, v := range headers {
+		if strings.EqualFold(k, "Authorization") || strings.EqualFold(k, "Content-Type") {
+			continue
+		}
+		opts = append(opts, option.WithHeader(k, v))
+	}
+	return opts
+}
+
 // EmbeddingClient handles vector embedding generation using OpenAI-compatible APIs
 type OpenAIProvider struct {
 	client     *openai.Client
 	model      string
 	dimensions int
+	maxBatch   int
 }
 
 func (e *OpenAIProvider) GetProviderType() string {
@@ -66,14 +83,44 @@ func (e *OpenAIProvider) GetProviderType() string {
 
 // GetEmbedding generates vector embedding for the given text
 func (e *OpenAIProvider) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := e.embed(ctx, openai.EmbeddingNewParamsInputUnion{OfString: openai.String(text)}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors[0]) == 0 {
+		return nil, fmt.Errorf("empty embedding response")
+	}
+	return vectors[0], nil
+}
+
+// GetEmbeddings generates vector embeddings for all texts in one request
+func (e *OpenAIProvider) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, err := e.embed(ctx, openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts}, len(texts))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBatch(vectors, len(texts), 0); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// MaxBatchSize returns the most texts sent in one embeddings request
+func (e *OpenAIProvider) MaxBatchSize() int {
+	return e.maxBatch
+}
+
+// embed calls the embeddings endpoint and returns the vectors ordered by their input index
+func (e *OpenAIProvider) embed(ctx context.Context, input openai.EmbeddingNewParamsInputUnion, inputs int) ([][]float32, error) {
 	params := openai.EmbeddingNewParams{
-		Model: e.model,
-		Input: openai.EmbeddingNewParamsInputUnion{
-			OfString: openai.String(text),
-		},
-		Dimensions:     openai.Int(int64(e.dimensions)),
+		Model:          e.model,
+		Input:          input,
 		EncodingFormat: openai.EmbeddingNewParamsEncodingFormatFloat,
 	}
+	// Only request a specific size when configured; otherwise use the model's native dimension
+	if e.dimensions > 0 {
+		params.Dimensions = openai.Int(int64(e.dimensions))
+	}
 
 	embeddingResp, err := e.client.Embeddings.New(ctx, params)
 	if err != nil {
@@ -84,11 +131,23 @@ func (e *OpenAIProvider) GetEmbedding(ctx context.Context, text string) ([]float
 		return nil, fmt.Errorf("empty embedding response")
 	}
 
-	// Convert []float64 to []float32
-	embedding := make([]float32, len(embeddingResp.Data[0].Embedding))
-	for i, v := range embeddingResp.Data[0].Embedding {
-		embedding[i] = float32(v)
+	// The API reports the input index of every vector; convert []float64 to []float32
+	vectors := make([][]float32, inputs)
+	for _, data := range embeddingResp.Data {
+		if data.Index < 0 || int(data.Index) >= inputs {
+			return nil, fmt.Errorf("embedding response index %d out of range for %d inputs", data.Index, inputs)
+		}
+		embedding := make([]float32, len(data.Embedding))
+		for i, v := range data.Embedding {
+			embedding[i] = float32(v)
+		}
+		vectors[data.Index] = embedding
 	}
 
-	return embedding, nil
+	return vectors, nil
+}
+
+// GetDimensions returns the actual output dimension by embedding a probe string
+func (e *OpenAIProvider) GetDimensions(ctx context.Context) (int, error) {
+	return probeDimensions(ctx, e)
 }
@@ -0,0 +1,160 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+// newFakeEmbeddingServer returns an OpenAI-compatible embeddings endpoint producing one
+// vector of dim length per input
+func newFakeEmbeddingServer(t *testing.T, dim int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input json.RawMessage `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		inputs := 1
+		var texts []string
+		if json.Unmarshal(req.Input, &texts) == nil {
+			inputs = len(texts)
+		}
+		vec := make([]float64, dim)
+		for i := range vec {
+			vec[i] = float64(i) / float64(dim)
+		}
+		data := make([]map[string]any, inputs)
+		for i := range data {
+			data[i] = map[string]any{"object": "embedding", "index": i, "embedding": vec}
+		}
+		resp := map[string]any{
+			"object": "list",
+			"model":  "fake-embedding",
+			"data":   data,
+			"usage":  map[string]any{"prompt_tokens": 1, "total_tokens": 1},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestOpenAIProvider_GetDimensions(t *testing.T) {
+	server := newFakeEmbeddingServer(t, 384)
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{
+		Provider: PROVIDER_TYPE_OPENAI,
+		APIKey:   "test-key",
+		BaseURL:  server.URL,
+		Model:    "fake-embedding",
+	})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+
+	dim, err := provider.GetDimensions(context.Background())
+	if err != nil {
+		t.Fatalf("GetDimensions() error = %v", err)
+	}
+	if dim != 384 {
+		t.Errorf("GetDimensions() = %d, want 384", dim)
+	}
+}
+
+func TestOpenAIProvider_GetDimensionsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{
+		Provider: PROVIDER_TYPE_OPENAI,
+		APIKey:   "test-key",
+		BaseURL:  server.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+	if _, err := provider.GetDimensions(context.Background()); err == nil {
+		t.Error("GetDimensions() expected error for failing endpoint")
+	}
+}
+
+func TestOpenAIProvider_GetEmbeddings(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		batches = append(batches, req.Input)
+		// Answer in reverse order; the index field carries the input position
+		data := make([]map[string]any, 0, len(req.Input))
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, map[string]any{"object": "embedding", "index": i, "embedding": []float64{float64(len(req.Input[i])), 1}})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"object": "list", "model": "fake-embedding", "data": data})
+	}))
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{
+		Provider:     PROVIDER_TYPE_OPENAI,
+		APIKey:       "test-key",
+		BaseURL:      server.URL,
+		MaxBatchSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+	texts := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	vectors, err := GetEmbeddings(context.Background(), provider, texts)
+	if err != nil {
+		t.Fatalf("GetEmbeddings() error = %v", err)
+	}
+	for i, vec := range vectors {
+		if len(vec) != 2 || int(vec[0]) != len(texts[i]) {
+			t.Errorf("vector %d = %v, want the embedding of %q", i, vec, texts[i])
+		}
+	}
+	if len(batches) != 3 || len(batches[0]) != 2 || len(batches[2]) != 1 || batches[2][0] != "eeeee" {
+		t.Errorf("requests = %v, want batches of at most 2 texts in input order", batches)
+	}
+}
+
+func TestOpenAIProvider_CustomHeaders(t *testing.T) {
+	var got http.Header
+	fake := newFakeEmbeddingServer(t, 8)
+	defer fake.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		fake.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(config.EmbeddingConfig{
+		Provider: PROVIDER_TYPE_OPENAI,
+		APIKey:   "test-key",
+		BaseURL:  server.URL,
+		Headers:  map[string]string{"X-Api-Key": "gateway-key", "OpenAI-Organization": "org-1", "authorization": "Bearer other", "Content-Type": "text/plain"},
+	})
+	if err != nil {
+		t.Fatalf("NewEmbeddingProvider() error = %v", err)
+	}
+	if _, err := provider.GetEmbedding(context.Background(), "hello"); err != nil {
+		t.Fatalf("GetEmbedding() error = %v", err)
+	}
+	if got.Get("X-Api-Key") != "gateway-key" || got.Get("OpenAI-Organization") != "org-1" {
+		t.Errorf("headers = %v, want the configured headers", got)
+	}
+	if got.Get("Authorization") != "Bearer test-key" || got.Get("Content-Type") != "application/json" {
+		t.Errorf("Authorization = %q, Content-Type = %q, want the client's own", got.Get("Authorization"), got.Get("Content-Type"))
+	}
+}
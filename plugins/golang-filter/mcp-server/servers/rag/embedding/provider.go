@@ -3,6 +3,7 @@ package embedding
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
 )
@@ -25,6 +26,8 @@ const (
 	PROVIDER_TYPE_XFYUN = "xfyun"
 	// Azure embedding service
 	PROVIDER_TYPE_AZURE = "azure"
+	// Generic self-hosted HTTP embedding service
+	PROVIDER_TYPE_HTTP = "http"
 )
 
 // Factory interface for creating Provider instances
@@ -37,6 +40,8 @@ type providerInitializer interface {
 var (
 	providerInitializers = map[string]providerInitializer{
 		PROVIDER_TYPE_OPENAI: &openAIProviderInitializer{},
+		PROVIDER_TYPE_COHERE: &cohereProviderInitializer{},
+		PROVIDER_TYPE_HTTP:   &httpProviderInitializer{},
 	}
 )
 
@@ -47,11 +52,132 @@ type Provider interface {
 	// Generates embedding vector for the input text
 	// Returns a float32 array representing the embedding vector
 	GetEmbedding(ctx context.Context, queryString string) ([]float32, error)
+	// Returns the dimension of the vectors produced by this provider
+	GetDimensions(ctx context.Context) (int, error)
+}
+
+// BatchProvider is implemented by providers that can embed several texts in one request
+type BatchProvider interface {
+	Provider
+	// Generates embedding vectors for the input texts in one request, in input order
+	GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error)
+	// Returns the most texts a single GetEmbeddings request may carry
+	MaxBatchSize() int
+}
+
+const (
+	// DEFAULT_MAX_BATCH_SIZE is the batch size used when embedding.max_batch_size is not set
+	DEFAULT_MAX_BATCH_SIZE = 64
+	// FALLBACK_CONCURRENCY bounds the parallel requests made for providers without batching
+	FALLBACK_CONCURRENCY = 8
+)
+
+// GetEmbeddings embeds texts and returns the vectors in input order. Providers that support
+// batching receive the texts in requests of at most MaxBatchSize texts; other providers get
+// one request per text, FALLBACK_CONCURRENCY at a time. Errors name the failed input index.
+func GetEmbeddings(ctx context.Context, p Provider, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+	if bp, ok := p.(BatchProvider); ok {
+		return getEmbeddingsInBatches(ctx, bp, texts)
+	}
+
+	vectors := make([][]float32, len(texts))
+	errs := make([]error, len(texts))
+	sem := make(chan struct{}, FALLBACK_CONCURRENCY)
+	var wg sync.WaitGroup
+	for i, text := range texts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, text string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			vectors[i], errs[i] = p.GetEmbedding(ctx, text)
+		}(i, text)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("embed text %d failed: %w", i, err)
+		}
+	}
+	return vectors, nil
+}
+
+// getEmbeddingsInBatches sends texts to bp in consecutive batches of at most MaxBatchSize
+func getEmbeddingsInBatches(ctx context.Context, bp BatchProvider, texts []string) ([][]float32, error) {
+	size := bp.MaxBatchSize()
+	if size <= 0 {
+		size = DEFAULT_MAX_BATCH_SIZE
+	}
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		end := start + size
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := bp.GetEmbeddings(ctx, texts[start:end])
+		if err == nil && len(batch) != end-start {
+			err = fmt.Errorf("embedding response has %d vectors for %d inputs", len(batch), end-start)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("embed texts %d-%d failed: %w", start, end-1, err)
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// maxBatchSize returns the configured batch size, or def when it is not set
+func maxBatchSize(configured, def int) int {
+	if configured > 0 {
+		return configured
+	}
+	return def
+}
+
+// checkBatch verifies that a batch response has one vector per input and that all vectors
+// share the same dimension, matching the configured one when set
+func checkBatch(vectors [][]float32, inputs int, dimensions int) error {
+	if len(vectors) != inputs {
+		return fmt.Errorf("embedding response has %d vectors for %d inputs", len(vectors), inputs)
+	}
+	for i, vec := range vectors {
+		if len(vec) == 0 {
+			return fmt.Errorf("embedding %d is empty", i)
+		}
+		if len(vec) != len(vectors[0]) {
+			return fmt.Errorf("embedding %d has dimension %d, expected %d", i, len(vec), len(vectors[0]))
+		}
+	}
+	if dimensions > 0 && len(vectors[0]) != dimensions {
+		return fmt.Errorf("embedding dimension %d does not match configured dimensions %d", len(vectors[0]), dimensions)
+	}
+	return nil
+}
+
+// Text embedded when probing a provider for its output dimension
+const DIMENSION_PROBE_TEXT = "dimension probe"
+
+// probeDimensions embeds a probe string and returns the resulting vector length
+func probeDimensions(ctx context.Context, p Provider) (int, error) {
+	vec, err := p.GetEmbedding(ctx, DIMENSION_PROBE_TEXT)
+	if err != nil {
+		return 0, fmt.Errorf("probe embedding dimensions failed: %w", err)
+	}
+	if len(vec) == 0 {
+		return 0, fmt.Errorf("probe embedding returned empty vector")
+	}
+	return len(vec), nil
 }
 
 // Creates a new embedding Provider based on the configuration
 // Returns error if provider type is not supported
 func NewEmbeddingProvider(config config.EmbeddingConfig) (Provider, error) {
+	if config.Kind != "" && config.Kind != EMBEDDING_KIND_DENSE {
+		return nil, fmt.Errorf("embedding kind %q is not a dense embedding", config.Kind)
+	}
 	initializer, ok := providerInitializers[config.Provider]
 	if !ok {
 		return nil, fmt.Errorf("no initializer found for provider type: %s", config.Provider)
@@ -0,0 +1,60 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sequentialProvider embeds one text per call and fails on texts containing "fail"
+type sequentialProvider struct {
+	inFlight, maxInFlight int32
+}
+
+func (p *sequentialProvider) GetProviderType() string { return "sequential" }
+
+func (p *sequentialProvider) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	n := atomic.AddInt32(&p.inFlight, 1)
+	defer atomic.AddInt32(&p.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&p.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt32(&p.maxInFlight, max, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	if strings.Contains(text, "fail") {
+		return nil, errors.New("upstream error")
+	}
+	return []float32{float32(len(text))}, nil
+}
+
+func (p *sequentialProvider) GetDimensions(ctx context.Context) (int, error) { return 1, nil }
+
+func TestGetEmbeddings_ConcurrentFallback(t *testing.T) {
+	provider := &sequentialProvider{}
+	texts := make([]string, 20)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+	vectors, err := GetEmbeddings(context.Background(), provider, texts)
+	if err != nil {
+		t.Fatalf("GetEmbeddings() error = %v", err)
+	}
+	for i, vec := range vectors {
+		if int(vec[0]) != i+1 {
+			t.Errorf("vector %d = %v, want the embedding of text %d", i, vec, i)
+		}
+	}
+	if max := atomic.LoadInt32(&provider.maxInFlight); max < 2 || max > FALLBACK_CONCURRENCY {
+		t.Errorf("max concurrent requests = %d, want between 2 and %d", max, FALLBACK_CONCURRENCY)
+	}
+
+	texts[13] = "fail"
+	if _, err := GetEmbeddings(context.Background(), provider, texts); err == nil || !strings.Contains(err.Error(), "embed text 13 failed") {
+		t.Errorf("GetEmbeddings() error = %v, want it to name the failed index", err)
+	}
+}
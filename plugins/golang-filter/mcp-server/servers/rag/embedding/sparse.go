@@ -0,0 +1,121 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// Embedding kinds selectable through EmbeddingConfig.Kind
+const (
+	// Dense float vectors, the default
+	EMBEDDING_KIND_DENSE = "dense"
+	// Learned sparse (SPLADE-style) vectors
+	EMBEDDING_KIND_SPARSE = "sparse"
+)
+
+// SparseProvider defines the interface for learned sparse embedding services
+type SparseProvider interface {
+	// Returns the provider type identifier
+	GetProviderType() string
+	// Generates a sparse vector mapping vocabulary ids to weights for the input text
+	GetSparseEmbedding(ctx context.Context, text string) (schema.SparseVector, error)
+}
+
+// Factory interface for creating SparseProvider instances
+type sparseProviderInitializer interface {
+	// Creates a new SparseProvider with the given configuration
+	CreateSparseProvider(config.EmbeddingConfig) (SparseProvider, error)
+}
+
+// Maps provider types to their sparse initializers
+var (
+	sparseProviderInitializers = map[string]sparseProviderInitializer{
+		PROVIDER_TYPE_HTTP: &httpSparseProviderInitializer{},
+	}
+)
+
+// Creates a new sparse embedding provider based on the configuration
+// Returns error if the config is not of kind sparse or the provider type is not supported
+func NewSparseEmbeddingProvider(config config.EmbeddingConfig) (SparseProvider, error) {
+	if config.Kind != EMBEDDING_KIND_SPARSE {
+		return nil, fmt.Errorf("embedding kind must be %q for a sparse provider, got %q", EMBEDDING_KIND_SPARSE, config.Kind)
+	}
+	initializer, ok := sparseProviderInitializers[config.Provider]
+	if !ok {
+		return nil, fmt.Errorf("no sparse initializer found for provider type: %s", config.Provider)
+	}
+	return initializer.CreateSparseProvider(config)
+}
+
+type httpSparseProviderInitializer struct {
+}
+
+func (c *httpSparseProviderInitializer) CreateSparseProvider(config config.EmbeddingConfig) (SparseProvider, error) {
+	if config.BaseURL == "" {
+		return nil, errors.New("[http sparse embedding] base_url is required")
+	}
+	if !strings.HasPrefix(config.BaseURL, "http://") && !strings.HasPrefix(config.BaseURL, "https://") {
+		return nil, fmt.Errorf("[http sparse embedding] base_url must be an http(s) URL, got %q", config.BaseURL)
+	}
+	return &HTTPSparseProvider{
+		client:   &http.Client{Timeout: HTTP_EMBEDDING_DEFAULT_TIMEOUT},
+		endpoint: config.BaseURL,
+		apiKey:   config.APIKey,
+		model:    config.Model,
+	}, nil
+}
+
+// HTTPSparseProvider calls a self-hosted SPLADE service that accepts {"text": ...}
+// and answers with parallel {"indices": [...], "values": [...]} arrays
+type HTTPSparseProvider struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+	model    string
+}
+
+type httpSparseRequest struct {
+	Text  string `json:"text"`
+	Model string `json:"model,omitempty"`
+}
+
+type httpSparseResponse struct {
+	Indices []uint32  `json:"indices"`
+	Values  []float32 `json:"values"`
+}
+
+func (e *HTTPSparseProvider) GetProviderType() string {
+	return PROVIDER_TYPE_HTTP
+}
+
+// GetSparseEmbedding generates a sparse vector for the given text, dropping zero weights
+func (e *HTTPSparseProvider) GetSparseEmbedding(ctx context.Context, text string) (schema.SparseVector, error) {
+	var resp httpSparseResponse
+	if err := postJSON(ctx, e.client, e.endpoint, e.apiKey, httpSparseRequest{Text: text, Model: e.model}, &resp); err != nil {
+		return nil, fmt.Errorf("failed to generate sparse embedding: %w", err)
+	}
+	return newSparseVector(resp.Indices, resp.Values)
+}
+
+// newSparseVector builds a SparseVector from parallel index/value arrays
+func newSparseVector(indices []uint32, values []float32) (schema.SparseVector, error) {
+	if len(indices) != len(values) {
+		return nil, fmt.Errorf("sparse embedding has %d indices but %d values", len(indices), len(values))
+	}
+	vec := make(schema.SparseVector, len(indices))
+	for i, idx := range indices {
+		if values[i] != 0 {
+			vec[idx] += values[i]
+		}
+	}
+	if len(vec) == 0 {
+		return nil, errors.New("sparse embedding is empty")
+	}
+	return vec, nil
+}
@@ -0,0 +1,64 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+func TestHTTPSparseProvider_GetSparseEmbedding(t *testing.T) {
+	var got httpSparseRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"indices": [2003, 7592, 11, 2003], "values": [0.5, 1.25, 0, 0.25]}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewSparseEmbeddingProvider(config.EmbeddingConfig{
+		Kind:     EMBEDDING_KIND_SPARSE,
+		Provider: PROVIDER_TYPE_HTTP,
+		BaseURL:  server.URL,
+		Model:    "splade-v3",
+	})
+	if err != nil {
+		t.Fatalf("NewSparseEmbeddingProvider() error = %v", err)
+	}
+	vec, err := provider.GetSparseEmbedding(context.Background(), "higress gateway")
+	if err != nil {
+		t.Fatalf("GetSparseEmbedding() error = %v", err)
+	}
+	if len(vec) != 2 || vec[2003] != 0.75 || vec[7592] != 1.25 {
+		t.Errorf("GetSparseEmbedding() = %v, want zero weights dropped and duplicates summed", vec)
+	}
+	if got.Text != "higress gateway" || got.Model != "splade-v3" {
+		t.Errorf("request = %+v, want text and model forwarded", got)
+	}
+}
+
+func TestHTTPSparseProvider_MismatchedArrays(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"indices": [1, 2], "values": [0.5]}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewSparseEmbeddingProvider(config.EmbeddingConfig{Kind: EMBEDDING_KIND_SPARSE, Provider: PROVIDER_TYPE_HTTP, BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewSparseEmbeddingProvider() error = %v", err)
+	}
+	if _, err := provider.GetSparseEmbedding(context.Background(), "q"); err == nil {
+		t.Error("GetSparseEmbedding() expected error for mismatched indices and values")
+	}
+}
+
+func TestEmbeddingKindValidation(t *testing.T) {
+	if _, err := NewSparseEmbeddingProvider(config.EmbeddingConfig{Provider: PROVIDER_TYPE_HTTP, BaseURL: "http://localhost"}); err == nil {
+		t.Error("NewSparseEmbeddingProvider() expected error without kind sparse")
+	}
+	if _, err := NewEmbeddingProvider(config.EmbeddingConfig{Kind: EMBEDDING_KIND_SPARSE, Provider: PROVIDER_TYPE_HTTP, BaseURL: "http://localhost"}); err == nil {
+		t.Error("NewEmbeddingProvider() expected error for a sparse kind")
+	}
+}
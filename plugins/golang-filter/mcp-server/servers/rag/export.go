@@ -0,0 +1,168 @@
+package rag
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+const (
+	// EXPORT_PAGE_SIZE is the number of chunks read from the store per export query
+	EXPORT_PAGE_SIZE = 500
+	// IMPORT_BATCH_SIZE is the number of chunks written to the store per AddDoc call
+	IMPORT_BATCH_SIZE = 100
+	// MAX_IMPORT_LINE_BYTES bounds a single JSONL record, vectors included
+	MAX_IMPORT_LINE_BYTES = 16 << 20
+)
+
+// ChunkRecord is one line of the JSONL format written by ExportChunks and read by ImportChunks
+type ChunkRecord struct {
+	ID           string                 `json:"id"`
+	Content      string                 `json:"content"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	Vector       []float32              `json:"vector,omitempty"`
+	SparseVector schema.SparseVector    `json:"sparse_vector,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// ExportChunks streams every chunk visible to the client to w as JSONL, one ChunkRecord
+// per line, paging through the store EXPORT_PAGE_SIZE chunks at a time.
+func (r *RAGClient) ExportChunks(w io.Writer) error {
+	r, done := r.acquire()
+	defer done()
+	ctx := context.Background()
+	enc := json.NewEncoder(w)
+	exported := 0
+	for {
+		docs, err := r.vectordbProvider.QueryDocs(ctx, &schema.QueryOptions{
+			Filters: r.namespaceFilters(),
+			Limit:   EXPORT_PAGE_SIZE,
+			Offset:  exported,
+		})
+		if err != nil {
+			return fmt.Errorf("export chunks failed, err: %w", err)
+		}
+		for _, doc := range docs {
+			record := ChunkRecord{
+				ID:           doc.ID,
+				Content:      doc.Content,
+				Metadata:     doc.Metadata,
+				Vector:       doc.Vector,
+				SparseVector: doc.SparseVector,
+				CreatedAt:    doc.CreatedAt,
+			}
+			if err := enc.Encode(record); err != nil {
+				return fmt.Errorf("write chunk %s failed, err: %w", doc.ID, err)
+			}
+			exported++
+		}
+		if len(docs) < EXPORT_PAGE_SIZE {
+			return nil
+		}
+	}
+}
+
+// ImportChunks loads JSONL written by ExportChunks and returns the number of chunks stored.
+// With reembed set, or when a record has no vector of the configured dimension, chunks
+// are embedded again with the current embedding provider; records are re-scoped to the
+// client's namespace. Chunks stored before an error are not rolled back.
+func (r *RAGClient) ImportChunks(rd io.Reader, reembed bool) (int, error) {
+	r, done := r.acquire()
+	defer done()
+	release, err := r.reindexGuard.beginIngestion()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	ctx := context.Background()
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 0, 64*1024), MAX_IMPORT_LINE_BYTES)
+
+	imported := 0
+	defer func() {
+		if imported > 0 {
+			r.InvalidateCache()
+		}
+	}()
+	batch := make([]schema.Document, 0, IMPORT_BATCH_SIZE)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := r.vectordbProvider.AddDoc(ctx, batch); err != nil {
+			return fmt.Errorf("add documents failed, err: %w", err)
+		}
+		imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record ChunkRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return imported, fmt.Errorf("parse line %d failed, err: %w", line, err)
+		}
+		if record.ID == "" || record.Content == "" {
+			return imported, fmt.Errorf("line %d: chunk id and content are required", line)
+		}
+		doc, err := r.importDocument(ctx, record, reembed)
+		if err != nil {
+			return imported, fmt.Errorf("line %d: %w", line, err)
+		}
+		batch = append(batch, doc)
+		if len(batch) >= IMPORT_BATCH_SIZE {
+			if err := flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, fmt.Errorf("read chunks failed, err: %w", err)
+	}
+	return imported, flush()
+}
+
+// importDocument converts a record into a document ready to store, embedding it when needed
+func (r *RAGClient) importDocument(ctx context.Context, record ChunkRecord, reembed bool) (schema.Document, error) {
+	doc := schema.Document{
+		ID:           record.ID,
+		Content:      record.Content,
+		Metadata:     record.Metadata,
+		Vector:       record.Vector,
+		SparseVector: record.SparseVector,
+		CreatedAt:    record.CreatedAt,
+	}
+	if doc.Metadata == nil {
+		doc.Metadata = make(map[string]interface{})
+	}
+	if r.namespace != "" {
+		doc.Metadata[schema.METADATA_NAMESPACE] = r.namespace
+	}
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now()
+	}
+	dim := r.config.Embedding.Dimensions
+	if reembed || len(doc.Vector) == 0 || (dim > 0 && len(doc.Vector) != dim) {
+		embedding, err := r.embeddingProvider.GetEmbedding(ctx, doc.Content)
+		if err != nil {
+			return doc, fmt.Errorf("create embedding failed, err: %w", err)
+		}
+		doc.Vector = embedding
+	}
+	if r.sparseEmbeddingProvider != nil && (reembed || len(doc.SparseVector) == 0) {
+		sparse, err := r.sparseEmbeddingProvider.GetSparseEmbedding(ctx, doc.Content)
+		if err != nil {
+			return doc, fmt.Errorf("create sparse embedding failed, err: %w", err)
+		}
+		doc.SparseVector = sparse
+	}
+	return doc, nil
+}
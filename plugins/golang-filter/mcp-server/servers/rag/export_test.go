@@ -0,0 +1,132 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestRAGClient_ExportImportRoundTrip(t *testing.T) {
+	source, sourceStore := newTestRAGClient(t, &config.Config{}, nil)
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var docs []schema.Document
+	for i := 0; i < EXPORT_PAGE_SIZE+20; i++ {
+		docs = append(docs, schema.Document{
+			ID:        fmt.Sprintf("chunk-%d", i),
+			Content:   fmt.Sprintf("higress gateway chunk %d", i),
+			Vector:    []float32{float32(i), 1, 0.5},
+			Metadata:  map[string]interface{}{"chunk_title": fmt.Sprintf("Section %d", i), "chunk_index": float64(i)},
+			CreatedAt: created,
+		})
+	}
+	if err := sourceStore.AddDoc(context.Background(), docs); err != nil {
+		t.Fatalf("AddDoc() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := source.ExportChunks(&buf); err != nil {
+		t.Fatalf("ExportChunks() error = %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(docs) {
+		t.Fatalf("exported %d lines, want %d across pages", lines, len(docs))
+	}
+
+	target, targetStore := newTestRAGClient(t, &config.Config{Embedding: config.EmbeddingConfig{Dimensions: 3}}, nil)
+	n, err := target.ImportChunks(&buf, false)
+	if err != nil || n != len(docs) {
+		t.Fatalf("ImportChunks() = %d, %v, want %d", n, err, len(docs))
+	}
+	if calls := target.embeddingProvider.(*MockEmbeddingProvider).Calls; calls != 0 {
+		t.Errorf("embedding calls = %d, want exported vectors reused", calls)
+	}
+	for i, got := range targetStore.docs {
+		want := docs[i]
+		if got.ID != want.ID || got.Content != want.Content || !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Fatalf("doc %d = %+v, want %+v", i, got, want)
+		}
+		if got.Metadata["chunk_title"] != want.Metadata["chunk_title"] || got.Metadata["chunk_index"] != want.Metadata["chunk_index"] {
+			t.Fatalf("doc %d metadata = %v, want %v", i, got.Metadata, want.Metadata)
+		}
+		if len(got.Vector) != 3 || got.Vector[0] != want.Vector[0] {
+			t.Fatalf("doc %d vector = %v, want %v", i, got.Vector, want.Vector)
+		}
+	}
+}
+
+func TestRAGClient_ImportChunksReembed(t *testing.T) {
+	client, store := newTestRAGClient(t, &config.Config{}, nil)
+	data := `{"id":"a","content":"higress gateway","vector":[1,2,3]}
+{"id":"b","content":"wasm plugin"}
+`
+	n, err := client.WithNamespace("team-a").ImportChunks(strings.NewReader(data), false)
+	if err != nil || n != 2 {
+		t.Fatalf("ImportChunks() = %d, %v, want 2", n, err)
+	}
+	for _, doc := range store.docs {
+		if len(doc.Vector) != client.config.Embedding.Dimensions {
+			t.Errorf("doc %s vector dim = %d, want re-embedded to %d", doc.ID, len(doc.Vector), client.config.Embedding.Dimensions)
+		}
+		if doc.Metadata[schema.METADATA_NAMESPACE] != "team-a" || doc.CreatedAt.IsZero() {
+			t.Errorf("doc %s metadata = %v created_at = %v, want namespace and timestamp set", doc.ID, doc.Metadata, doc.CreatedAt)
+		}
+	}
+	if calls := client.embeddingProvider.(*MockEmbeddingProvider).Calls; calls != 2 {
+		t.Errorf("embedding calls = %d, want 2 for mismatched and missing vectors", calls)
+	}
+}
+
+func TestRAGClient_ImportChunksInvalidLine(t *testing.T) {
+	client, store := newTestRAGClient(t, &config.Config{}, nil)
+	data := `{"id":"a","content":"higress gateway"}
+not json
+`
+	n, err := client.ImportChunks(strings.NewReader(data), false)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("ImportChunks() error = %v, want a line 2 parse error", err)
+	}
+	if n != 0 || len(store.docs) != 0 {
+		t.Errorf("imported %d chunks, stored %d, want nothing before the failing batch", n, len(store.docs))
+	}
+	if _, err := client.ImportChunks(strings.NewReader(`{"id":"","content":"x"}`), false); err == nil {
+		t.Error("ImportChunks() accepted a chunk without id")
+	}
+}
+
+func TestHandleExportImportChunks(t *testing.T) {
+	source, _ := newTestRAGClient(t, &config.Config{}, nil)
+	if _, err := source.CreateChunkFromText("Higress is a cloud native gateway.", "intro"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	request := mcp.CallToolRequest{}
+	result, err := HandleExportChunks(source)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("HandleExportChunks() error = %v", err)
+	}
+	var exported struct {
+		Count int    `json:"count"`
+		Data  string `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &exported); err != nil {
+		t.Fatalf("export output is not JSON: %v", err)
+	}
+	if exported.Count != 1 {
+		t.Fatalf("exported count = %d, want 1", exported.Count)
+	}
+
+	target, store := newTestRAGClient(t, &config.Config{}, nil)
+	request.Params.Arguments = map[string]interface{}{"data": exported.Data, "reembed": true}
+	if _, err := HandleImportChunks(target)(context.Background(), request); err != nil {
+		t.Fatalf("HandleImportChunks() error = %v", err)
+	}
+	if len(store.docs) != 1 || store.docs[0].Title() != "intro" {
+		t.Errorf("imported docs = %+v, want the exported chunk", store.docs)
+	}
+}
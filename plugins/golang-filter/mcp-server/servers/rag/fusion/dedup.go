@@ -0,0 +1,122 @@
+package fusion
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+const (
+	// MetadataContentHash is the metadata key holding the normalized content hash.
+	MetadataContentHash = "content_hash"
+	// MetadataMergedSources lists the retrievers that returned the same content.
+	MetadataMergedSources = "merged_sources"
+	// MetadataMergedIDs lists the document IDs collapsed into the canonical document.
+	MetadataMergedIDs = "merged_ids"
+)
+
+// ContentHash returns the sha1 of content after lowercasing and collapsing whitespace.
+func ContentHash(content string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(content)), " ")
+	sum := sha1.Sum([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// DedupByContent collapses documents with identical normalized content across
+// retrievers so that ID-based fusion treats them as one document. For each
+// content hash the highest-scoring occurrence becomes the canonical document;
+// every occurrence is rewritten to it, and it records the merged retrievers and
+// IDs in its metadata. Metadata keys only other occurrences carry (such as the
+// chunk_title of a lower-scored vector hit) are kept. Within a single retriever
+// list only the best-ranked occurrence is kept. Documents with empty content are
+// left untouched.
+func DedupByContent(inputs []RetrieverResult) []RetrieverResult {
+	type canonical struct {
+		doc      schema.Document
+		score    float64
+		sources  []string
+		ids      []string
+		metadata []map[string]interface{} // metadata of every occurrence, in order seen
+	}
+	byHash := make(map[string]*canonical)
+	hashes := make([][]string, len(inputs))
+
+	for i, in := range inputs {
+		hashes[i] = make([]string, len(in.Results))
+		for j, res := range in.Results {
+			if strings.TrimSpace(res.Document.Content) == "" {
+				continue
+			}
+			hash := ContentHash(res.Document.Content)
+			hashes[i][j] = hash
+			c, ok := byHash[hash]
+			if !ok {
+				c = &canonical{doc: res.Document, score: res.Score}
+				byHash[hash] = c
+			} else if res.Score > c.score {
+				c.doc = res.Document
+				c.score = res.Score
+			}
+			c.sources = appendUnique(c.sources, in.Retriever)
+			c.ids = appendUnique(c.ids, res.Document.ID)
+			c.metadata = append(c.metadata, res.Document.Metadata)
+		}
+	}
+
+	canonicalDocs := make(map[string]schema.Document, len(byHash))
+	for hash, c := range byHash {
+		doc := c.doc
+		metadata := make(map[string]interface{}, len(doc.Metadata)+3)
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		// Keep keys only other occurrences carry (e.g. chunk_title of the vector hit
+		// when a keyword hit of the same chunk scored higher)
+		for _, other := range c.metadata {
+			for k, v := range other {
+				if _, ok := metadata[k]; !ok {
+					metadata[k] = v
+				}
+			}
+		}
+		metadata[MetadataContentHash] = hash
+		if len(c.ids) > 1 || len(c.sources) > 1 {
+			metadata[MetadataMergedSources] = c.sources
+			metadata[MetadataMergedIDs] = c.ids
+		}
+		doc.Metadata = metadata
+		canonicalDocs[hash] = doc
+	}
+
+	out := make([]RetrieverResult, len(inputs))
+	for i, in := range inputs {
+		out[i] = in
+		out[i].Results = make([]schema.SearchResult, 0, len(in.Results))
+		seen := make(map[string]bool, len(in.Results))
+		for j, res := range in.Results {
+			hash := hashes[i][j]
+			if hash == "" {
+				out[i].Results = append(out[i].Results, res)
+				continue
+			}
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			res.Document = canonicalDocs[hash]
+			out[i].Results = append(out[i].Results, res)
+		}
+	}
+	return out
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,146 @@
+package fusion
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+func TestDedupByContent_CollapsesAcrossRetrievers(t *testing.T) {
+	inputs := []RetrieverResult{
+		{Retriever: "vector", Results: []schema.SearchResult{
+			{Document: schema.Document{ID: "vec-1", Content: "Higress is a cloud native gateway", Metadata: map[string]interface{}{"chunk_title": "intro"}}, Score: 0.8},
+			{Document: schema.Document{ID: "vec-2", Content: "Envoy is a proxy"}, Score: 0.5},
+		}},
+		{Retriever: "web", Results: []schema.SearchResult{
+			{Document: schema.Document{ID: "web-9", Content: "  higress is a   Cloud Native gateway ", Metadata: map[string]interface{}{"url": "https://example.com"}}, Score: 0.9},
+		}},
+	}
+
+	fused, err := NewRRFStrategy(60).Fuse(context.Background(), DedupByContent(inputs), nil)
+	if err != nil {
+		t.Fatalf("Fuse() error = %v", err)
+	}
+	if len(fused) != 2 {
+		t.Fatalf("Fuse() returned %d results, want 2: %+v", len(fused), fused)
+	}
+
+	top := fused[0].Document
+	if top.ID != "web-9" || top.Metadata["url"] != "https://example.com" {
+		t.Errorf("canonical document = %+v, want highest-scoring web-9", top)
+	}
+	if top.Metadata["chunk_title"] != "intro" {
+		t.Errorf("canonical metadata = %v, want chunk_title inherited from vec-1", top.Metadata)
+	}
+	if got := top.Metadata[MetadataMergedSources]; !reflect.DeepEqual(got, []string{"vector", "web"}) {
+		t.Errorf("merged_sources = %v, want [vector web]", got)
+	}
+	if got := top.Metadata[MetadataMergedIDs]; !reflect.DeepEqual(got, []string{"vec-1", "web-9"}) {
+		t.Errorf("merged_ids = %v, want [vec-1 web-9]", got)
+	}
+	if _, ok := fused[1].Document.Metadata[MetadataMergedSources]; ok {
+		t.Errorf("unique document should not carry merged_sources: %+v", fused[1].Document)
+	}
+	// Collapsed documents accumulate RRF score from both lists
+	if fused[0].Score <= fused[1].Score {
+		t.Errorf("merged score %.4f should exceed single-list score %.4f", fused[0].Score, fused[1].Score)
+	}
+}
+
+func TestDedupByContent_WithinList(t *testing.T) {
+	inputs := []RetrieverResult{{Retriever: "bm25", Results: []schema.SearchResult{
+		{Document: schema.Document{ID: "a", Content: "same text"}, Score: 2},
+		{Document: schema.Document{ID: "b", Content: "Same  text"}, Score: 1},
+		{Document: schema.Document{ID: "c"}, Score: 0.5},
+	}}}
+
+	out := DedupByContent(inputs)
+	if len(out[0].Results) != 2 || out[0].Results[0].Document.ID != "a" || out[0].Results[1].Document.ID != "c" {
+		t.Errorf("DedupByContent() = %+v, want best-ranked duplicate and the empty-content document", out[0].Results)
+	}
+	if inputs[0].Results[1].Document.ID != "b" {
+		t.Error("DedupByContent() must not modify its input")
+	}
+}
+
+func TestNearDuplicates_Hash(t *testing.T) {
+	inputs := []RetrieverResult{
+		{Retriever: "vector", Results: []schema.SearchResult{
+			{Document: schema.Document{ID: "a#1", Content: "Higress supports Wasm plugins."}, Score: 0.7},
+			{Document: schema.Document{ID: "a#2", Content: "Routes use Ingress"}, Score: 0.6},
+			{Document: schema.Document{ID: "b#4", Content: "higress supports wasm plugins"}, Score: 0.5},
+		}},
+		{Retriever: "bm25", Results: []schema.SearchResult{
+			{Document: schema.Document{ID: "c#3", Content: "Higress supports WASM-plugins!"}, Score: 0.9},
+		}},
+	}
+	out, collapsed, err := NewNearDuplicates(&config.DedupConfig{Enable: true}, nil).Collapse(context.Background(), inputs)
+	if err != nil || collapsed != 2 {
+		t.Fatalf("Collapse() collapsed %d, error = %v, want 2", collapsed, err)
+	}
+	if len(out[0].Results) != 2 || out[0].Results[0].Document.ID != "c#3" || out[0].Results[1].Document.ID != "a#2" {
+		t.Fatalf("vector list = %+v, want the representative once and the distinct result", out[0].Results)
+	}
+	if out[0].Results[0].Score != 0.7 {
+		t.Errorf("score = %v, want the list's own score kept", out[0].Results[0].Score)
+	}
+	rep := out[1].Results[0].Document
+	if rep.Metadata[MetadataCollapsedCount] != 2 || !reflect.DeepEqual(rep.Metadata[MetadataMergedIDs], []string{"c#3", "a#1", "b#4"}) {
+		t.Errorf("representative metadata = %v, want 2 collapsed documents", rep.Metadata)
+	}
+	if inputs[0].Results[0].Document.ID != "a#1" {
+		t.Error("Collapse() must not modify its input")
+	}
+}
+
+// fixedEmbedder embeds the texts it knows with fixed vectors
+type fixedEmbedder map[string][]float32
+
+func (e fixedEmbedder) GetProviderType() string { return "fixed" }
+
+func (e fixedEmbedder) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return e[text], nil
+}
+
+func (e fixedEmbedder) GetDimensions(ctx context.Context) (int, error) { return 2, nil }
+
+func TestNearDuplicates_Embedding(t *testing.T) {
+	embedder := fixedEmbedder{
+		"install with helm":         {1, 0.1},
+		"use helm to install":       {1, 0.15},
+		"configure a wasm plugin":   {0, 1},
+		"higress is an api gateway": {0.7, 0.7},
+	}
+	inputs := []RetrieverResult{
+		{Retriever: "vector", Results: []schema.SearchResult{
+			{Document: schema.Document{ID: "1", Content: "install with helm"}, Score: 0.9},
+			{Document: schema.Document{ID: "2", Content: "configure a wasm plugin"}, Score: 0.8},
+		}},
+		{Retriever: "bm25", Results: []schema.SearchResult{
+			{Document: schema.Document{ID: "3", Content: "use helm to install"}, Score: 3},
+			{Document: schema.Document{ID: "4", Content: "higress is an api gateway"}, Score: 2},
+		}},
+	}
+	dedup := NewNearDuplicates(&config.DedupConfig{Enable: true, Method: config.DEDUP_METHOD_EMBEDDING, Threshold: 0.99}, embedder)
+	out, collapsed, err := dedup.Collapse(context.Background(), inputs)
+	if err != nil || collapsed != 1 {
+		t.Fatalf("Collapse() collapsed %d, error = %v, want 1", collapsed, err)
+	}
+	if got := out[0].Results[0].Document; got.ID != "3" || got.Metadata[MetadataCollapsedCount] != 1 {
+		t.Errorf("vector top = %+v, want the best-scored near-duplicate 3", got)
+	}
+	if len(out[0].Results) != 2 || len(out[1].Results) != 2 {
+		t.Errorf("Collapse() = %+v, want the dissimilar results kept", out)
+	}
+
+	if NewNearDuplicates(&config.DedupConfig{Method: config.DEDUP_METHOD_EMBEDDING}, embedder) != nil {
+		t.Error("NewNearDuplicates() of a disabled config should be nil")
+	}
+	dedup.Embedder = nil
+	if _, _, err := dedup.Collapse(context.Background(), inputs); err == nil {
+		t.Error("Collapse() without vectors or embedder should fail")
+	}
+}
@@ -3,9 +3,38 @@ package fusion
 import (
 	"errors"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Factory builds a custom strategy from params. It returns the strategy and a sanitized param map.
+type Factory func(params map[string]any) (Strategy, map[string]any, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a custom fusion strategy selectable by name. Built-in names
+// (rrf, weighted_rrf, weighted, linear, normalized_linear, distribution, learned) always
+// resolve to the built-ins.
+func Register(name string, factory Factory) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" || factory == nil {
+		return
+	}
+	registryMu.Lock()
+	registry[key] = factory
+	registryMu.Unlock()
+}
+
+func lookupFactory(name string) (Factory, bool) {
+	registryMu.RLock()
+	f, ok := registry[name]
+	registryMu.RUnlock()
+	return f, ok
+}
+
 // NewStrategy constructs a strategy by name. It returns the strategy and a sanitized param map.
 func NewStrategy(name string, params map[string]any) (Strategy, map[string]any, error) {
 	normalized := strings.ToLower(strings.TrimSpace(name))
@@ -23,12 +52,20 @@ func NewStrategy(name string, params map[string]any) (Strategy, map[string]any,
 			k = 60
 		}
 		return NewRRFStrategy(k), map[string]any{"k": k}, nil
+	case "weighted_rrf":
+		weights, _ := parseStringFloatMap(params["weights"])
+		strategy := NewWeightedRRFStrategy(lookupInt(params, "k"), weights)
+		return strategy, map[string]any{"k": strategy.K, "weights": copyStringFloatMap(strategy.Weights)}, nil
 	case "weighted":
 		weights, _ := parseStringFloatMap(params["weights"])
 		return NewWeightedStrategy(weights), map[string]any{"weights": weights}, nil
 	case "linear":
 		weights, _ := parseFloatSlice(params["weights"])
 		return NewLinearCombinationStrategy(weights), map[string]any{"weights": weights}, nil
+	case "normalized_linear":
+		weights, _ := parseStringFloatMap(params["weights"])
+		strategy := NewNormalizedLinearStrategy(toString(params["normalization"]), weights)
+		return strategy, map[string]any{"normalization": strategy.Normalization, "weights": copyStringFloatMap(strategy.Weights)}, nil
 	case "distribution":
 		baseName := "rrf"
 		if v, ok := params["base"].(string); ok && v != "" {
@@ -77,9 +114,26 @@ func NewStrategy(name string, params map[string]any) (Strategy, map[string]any,
 		if percent := lookupInt(params, "traffic_percent"); percent > 0 {
 			sanitized["traffic_percent"] = percent
 		}
+		if salt := toString(params["traffic_salt"]); salt != "" {
+			sanitized["traffic_salt"] = salt
+		}
 		return strategy, sanitized, nil
 	default:
-		return nil, nil, errors.New("unsupported fusion strategy: " + normalized)
+		factory, ok := lookupFactory(normalized)
+		if !ok {
+			return nil, nil, errors.New("unsupported fusion strategy: " + normalized)
+		}
+		strategy, sanitized, err := factory(params)
+		if err != nil {
+			return nil, nil, err
+		}
+		if strategy == nil {
+			return nil, nil, errors.New("fusion strategy factory returned nil: " + normalized)
+		}
+		if sanitized == nil {
+			sanitized = copyParams(params)
+		}
+		return strategy, sanitized, nil
 	}
 }
 
@@ -93,3 +147,11 @@ func toString(v interface{}) string {
 		return ""
 	}
 }
+
+func copyParams(params map[string]any) map[string]any {
+	out := make(map[string]any, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+	return out
+}
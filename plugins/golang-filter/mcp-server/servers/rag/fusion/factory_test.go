@@ -0,0 +1,94 @@
+package fusion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+type firstOnlyStrategy struct{}
+
+func (s *firstOnlyStrategy) Name() string { return "first_only" }
+
+func (s *firstOnlyStrategy) Fuse(ctx context.Context, inputs []RetrieverResult, params map[string]any) ([]schema.SearchResult, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	return inputs[0].Results, nil
+}
+
+func TestNewStrategyBuiltins(t *testing.T) {
+	for _, name := range []string{"", "rrf", "weighted_rrf", "weighted", "linear", "normalized_linear", "distribution"} {
+		s, _, err := NewStrategy(name, nil)
+		if err != nil {
+			t.Fatalf("NewStrategy(%q) error = %v", name, err)
+		}
+		if s == nil {
+			t.Fatalf("NewStrategy(%q) returned nil strategy", name)
+		}
+	}
+}
+
+func TestNewStrategyUnknown(t *testing.T) {
+	if _, _, err := NewStrategy("does_not_exist", nil); err == nil {
+		t.Error("NewStrategy() expected error for unknown strategy")
+	}
+}
+
+func TestRegisterCustomStrategy(t *testing.T) {
+	Register("First_Only", func(params map[string]any) (Strategy, map[string]any, error) {
+		return &firstOnlyStrategy{}, map[string]any{"mode": params["mode"]}, nil
+	})
+
+	s, sanitized, err := NewStrategy("first_only", map[string]any{"mode": "strict", "ignored": true})
+	if err != nil {
+		t.Fatalf("NewStrategy() error = %v", err)
+	}
+	if s.Name() != "first_only" {
+		t.Errorf("Name() = %q, want first_only", s.Name())
+	}
+	if sanitized["mode"] != "strict" {
+		t.Errorf("sanitized params = %v, want mode=strict", sanitized)
+	}
+	if _, ok := sanitized["ignored"]; ok {
+		t.Errorf("sanitized params should come from the factory, got %v", sanitized)
+	}
+
+	results, err := s.Fuse(context.Background(), []RetrieverResult{
+		{Retriever: "vector", Results: []schema.SearchResult{{Document: schema.Document{ID: "a"}, Score: 1}}},
+		{Retriever: "bm25", Results: []schema.SearchResult{{Document: schema.Document{ID: "b"}, Score: 1}}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Fuse() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID != "a" {
+		t.Errorf("Fuse() = %+v, want only document a", results)
+	}
+}
+
+func TestRegisterCannotShadowBuiltin(t *testing.T) {
+	Register("rrf", func(params map[string]any) (Strategy, map[string]any, error) {
+		return &firstOnlyStrategy{}, nil, nil
+	})
+	s, _, err := NewStrategy("rrf", nil)
+	if err != nil {
+		t.Fatalf("NewStrategy() error = %v", err)
+	}
+	if s.Name() == "first_only" {
+		t.Error("built-in rrf strategy should not be replaced by the registry")
+	}
+}
+
+func TestRegisteredStrategyNilSanitized(t *testing.T) {
+	Register("passthrough_params", func(params map[string]any) (Strategy, map[string]any, error) {
+		return &firstOnlyStrategy{}, nil, nil
+	})
+	_, sanitized, err := NewStrategy("passthrough_params", map[string]any{"alpha": 0.3})
+	if err != nil {
+		t.Fatalf("NewStrategy() error = %v", err)
+	}
+	if sanitized["alpha"] != 0.3 {
+		t.Errorf("sanitized params = %v, want alpha=0.3", sanitized)
+	}
+}
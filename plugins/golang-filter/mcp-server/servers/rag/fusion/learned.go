@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"hash/fnv"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,6 +12,12 @@ import (
 	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
 )
 
+// Rollout arms reported for learned fusion traffic control.
+const (
+	ArmLearned = "learned"
+	ArmControl = "control"
+)
+
 // LearnedOptions configures the learned fusion strategy.
 type LearnedOptions struct {
 	WeightsURI      string
@@ -70,7 +77,7 @@ func (s *LearnedStrategy) Fuse(ctx context.Context, inputs []RetrieverResult, pa
 		params = map[string]any{}
 	}
 
-	if !s.shouldActivate(params, inputs) {
+	if s.arm(params, inputs) != ArmLearned {
 		api.LogInfof("fusion: learned strategy skipped by traffic control")
 		return s.opts.Fallback.Fuse(ctx, inputs, params)
 	}
@@ -138,31 +145,45 @@ func (s *LearnedStrategy) storeMetadata(snapshot *WeightSnapshot) {
 	}
 }
 
-func (s *LearnedStrategy) shouldActivate(params map[string]any, inputs []RetrieverResult) bool {
-	percent := lookupInt(params, "traffic_percent")
-	if percent <= 0 || percent >= 100 {
-		return true
-	}
+// Arm implements RolloutStrategy.
+func (s *LearnedStrategy) Arm(params map[string]any) string {
+	return s.arm(params, nil)
+}
 
-	hashSeed := ""
-	if seed, ok := params["query_id"].(string); ok && seed != "" {
-		hashSeed = seed
-	} else if seed, ok := params["query"].(string); ok && seed != "" {
-		hashSeed = seed
-	} else if len(inputs) > 0 {
-		hashSeed = inputs[0].Query
+// arm hashes the request query, falling back to the query of the first input list.
+func (s *LearnedStrategy) arm(params map[string]any, inputs []RetrieverResult) string {
+	key := ""
+	for _, name := range []string{"query", "query_id"} {
+		if v, ok := params[name].(string); ok && v != "" {
+			key = v
+			break
+		}
 	}
-	if hashSeed == "" {
-		return true
+	if key == "" && len(inputs) > 0 {
+		key = inputs[0].Query
 	}
+	return AssignArm(key, toString(params["traffic_salt"]), lookupInt(params, "traffic_percent"))
+}
 
-	h := fnv.New32a()
-	if _, err := h.Write([]byte(hashSeed)); err != nil {
-		return true
-	}
-	value := h.Sum32() % 100
-	return int(value) < percent
+// AssignArm deterministically assigns query to the learned arm for percent of queries.
+// The query is case- and whitespace-normalized and hashed together with salt, so a query
+// keeps its arm across retries, and changing the salt reshuffles the rollout population.
+// A percent outside (0, 100) or an empty query disables traffic control.
+func AssignArm(query, salt string, percent int) string {
+	key := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if percent <= 0 || percent >= 100 || key == "" {
+		return ArmLearned
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(salt))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	if h.Sum64()%10000 < uint64(percent)*100 {
+		return ArmLearned
+	}
+	return ArmControl
 }
 
 var _ Strategy = (*LearnedStrategy)(nil)
 var _ MetadataProvider = (*LearnedStrategy)(nil)
+var _ RolloutStrategy = (*LearnedStrategy)(nil)
@@ -0,0 +1,57 @@
+package fusion
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAssignArm_StableAcrossCalls(t *testing.T) {
+	first := AssignArm("What is Higress?", "exp-1", 50)
+	for i := 0; i < 20; i++ {
+		if got := AssignArm("  what is   higress? ", "exp-1", 50); got != first {
+			t.Fatalf("AssignArm() = %q on call %d, want %q", got, i, first)
+		}
+	}
+
+	strategy := &LearnedStrategy{}
+	params := map[string]any{"query": "What is Higress?", "query_id": "q-1", "traffic_percent": 50, "traffic_salt": "exp-1"}
+	if got := strategy.Arm(params); got != first {
+		t.Errorf("Arm() = %q, want %q", got, first)
+	}
+	params["query_id"] = "q-2"
+	if got := strategy.Arm(params); got != first {
+		t.Errorf("Arm() = %q after query_id changed, want the arm keyed on the query", got)
+	}
+}
+
+func TestAssignArm_EvenSplit(t *testing.T) {
+	const total = 10000
+	for _, percent := range []int{10, 50} {
+		learned := 0
+		for i := 0; i < total; i++ {
+			if AssignArm(fmt.Sprintf("query number %d", i), "", percent) == ArmLearned {
+				learned++
+			}
+		}
+		share := float64(learned) / total * 100
+		if share < float64(percent)-2 || share > float64(percent)+2 {
+			t.Errorf("percent=%d: learned share = %.1f%%, want within 2 points", percent, share)
+		}
+	}
+}
+
+func TestAssignArm_SaltReshuffles(t *testing.T) {
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		q := fmt.Sprintf("query %d", i)
+		if AssignArm(q, "a", 50) != AssignArm(q, "b", 50) {
+			moved++
+		}
+	}
+	if moved < 300 || moved > 700 {
+		t.Errorf("%d of 1000 queries changed arm with a new salt, want roughly half", moved)
+	}
+	if AssignArm("", "a", 50) != ArmLearned || AssignArm("q", "a", 0) != ArmLearned || AssignArm("q", "a", 100) != ArmLearned {
+		t.Error("AssignArm() should disable traffic control for empty queries and percent outside (0, 100)")
+	}
+}
@@ -0,0 +1,209 @@
+package fusion
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/embedding"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// DEFAULT_DEDUP_THRESHOLD is the cosine similarity above which the embedding method treats
+// two results as near-duplicates
+const DEFAULT_DEDUP_THRESHOLD = 0.95
+
+// MetadataCollapsedCount is the metadata key holding the number of near-duplicate
+// documents collapsed into the representative document.
+const MetadataCollapsedCount = "collapsed_count"
+
+// NearDuplicates collapses near-identical results across retrievers before fusion. Unlike
+// DedupByContent, it also merges different chunks that only differ in formatting or, with
+// the embedding method, in wording.
+type NearDuplicates struct {
+	Method    string
+	Threshold float64
+	// Embedder embeds the results without a vector for the embedding method
+	Embedder embedding.Provider
+}
+
+// NewNearDuplicates creates the dedup step configured by cfg, or returns nil when it is
+// disabled
+func NewNearDuplicates(cfg *config.DedupConfig, embedder embedding.Provider) *NearDuplicates {
+	if cfg == nil || !cfg.Enable {
+		return nil
+	}
+	d := &NearDuplicates{Method: cfg.Method, Threshold: cfg.Threshold, Embedder: embedder}
+	if d.Method == "" {
+		d.Method = config.DEDUP_METHOD_HASH
+	}
+	if d.Threshold <= 0 {
+		d.Threshold = DEFAULT_DEDUP_THRESHOLD
+	}
+	return d
+}
+
+// resultRef locates a result in the retriever lists
+type resultRef struct{ list, pos int }
+
+// Collapse groups near-identical results and rewrites every member of a group to its
+// best-scored representative, so fusion treats the group as one document; within a
+// single retriever list only the best-ranked member is kept. The representative records
+// in its metadata how many documents were collapsed into it and their IDs. It returns the
+// rewritten lists and the number of documents collapsed; inputs is left unmodified.
+func (d *NearDuplicates) Collapse(ctx context.Context, inputs []RetrieverResult) ([]RetrieverResult, int, error) {
+	refs := make([]resultRef, 0)
+	for i, in := range inputs {
+		for j, res := range in.Results {
+			if strings.TrimSpace(res.Document.Content) != "" {
+				refs = append(refs, resultRef{i, j})
+			}
+		}
+	}
+	result := func(ref resultRef) schema.SearchResult { return inputs[ref.list].Results[ref.pos] }
+	// Representatives are picked first: the best score wins, ties keep the retrieval order
+	sort.SliceStable(refs, func(a, b int) bool { return result(refs[a]).Score > result(refs[b]).Score })
+
+	groups, err := d.group(ctx, refs, result)
+	if err != nil {
+		return inputs, 0, err
+	}
+
+	// representative[ref] is the document every member of ref's group is rewritten to
+	representative := make(map[resultRef]schema.Document)
+	collapsed := 0
+	for _, members := range groups {
+		ids := make([]string, 0, len(members))
+		for _, ref := range members {
+			ids = appendUnique(ids, result(ref).Document.ID)
+		}
+		if len(ids) < 2 {
+			continue
+		}
+		doc := result(members[0]).Document
+		metadata := make(map[string]interface{}, len(doc.Metadata)+2)
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		merged, _ := metadata[MetadataMergedIDs].([]string)
+		for _, id := range ids {
+			merged = appendUnique(merged, id)
+		}
+		metadata[MetadataMergedIDs] = merged
+		metadata[MetadataCollapsedCount] = len(ids) - 1
+		doc.Metadata = metadata
+		for _, ref := range members {
+			representative[ref] = doc
+		}
+		collapsed += len(ids) - 1
+	}
+	if collapsed == 0 {
+		return inputs, 0, nil
+	}
+
+	out := make([]RetrieverResult, len(inputs))
+	for i, in := range inputs {
+		out[i] = in
+		out[i].Results = make([]schema.SearchResult, 0, len(in.Results))
+		seen := make(map[string]bool, len(in.Results))
+		for j, res := range in.Results {
+			if doc, ok := representative[resultRef{i, j}]; ok {
+				if seen[doc.ID] {
+					continue
+				}
+				seen[doc.ID] = true
+				res.Document = doc
+			}
+			out[i].Results = append(out[i].Results, res)
+		}
+	}
+	return out, collapsed, nil
+}
+
+// group partitions refs, ordered best first, into groups of near-duplicates; the first
+// member of every group is its representative
+func (d *NearDuplicates) group(ctx context.Context, refs []resultRef, result func(resultRef) schema.SearchResult) ([][]resultRef, error) {
+	groups := make([][]resultRef, 0, len(refs))
+	if d.Method != config.DEDUP_METHOD_EMBEDDING {
+		byKey := make(map[string]int, len(refs))
+		for _, ref := range refs {
+			key := nearDuplicateKey(result(ref).Document.Content)
+			if g, ok := byKey[key]; ok {
+				groups[g] = append(groups[g], ref)
+				continue
+			}
+			byKey[key] = len(groups)
+			groups = append(groups, []resultRef{ref})
+		}
+		return groups, nil
+	}
+
+	vectors, err := d.vectors(ctx, refs, result)
+	if err != nil {
+		return nil, err
+	}
+	// Greedy clustering: a result joins the first group whose representative it is close to
+	heads := make([]int, 0, len(refs))
+	for i, ref := range refs {
+		joined := false
+		for g, head := range heads {
+			if embedding.CosineSimilarity(vectors[i], vectors[head]) >= d.Threshold {
+				groups[g] = append(groups[g], ref)
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			heads = append(heads, i)
+			groups = append(groups, []resultRef{ref})
+		}
+	}
+	return groups, nil
+}
+
+// vectors returns the vector of every result, embedding in one request only the
+// documents that have none
+func (d *NearDuplicates) vectors(ctx context.Context, refs []resultRef, result func(resultRef) schema.SearchResult) ([][]float32, error) {
+	vectors := make([][]float32, len(refs))
+	var missing []int
+	var texts []string
+	for i, ref := range refs {
+		if doc := result(ref).Document; len(doc.Vector) > 0 {
+			vectors[i] = doc.Vector
+		} else {
+			missing = append(missing, i)
+			texts = append(texts, doc.Content)
+		}
+	}
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+	if d.Embedder == nil {
+		return nil, fmt.Errorf("dedup: %d results have no vector and no embedding provider is configured", len(missing))
+	}
+	embedded, err := embedding.GetEmbeddings(ctx, d.Embedder, texts)
+	if err != nil {
+		return nil, fmt.Errorf("dedup: embed results failed: %w", err)
+	}
+	if len(embedded) != len(missing) {
+		return nil, fmt.Errorf("dedup: got %d vectors for %d results", len(embedded), len(missing))
+	}
+	for j, i := range missing {
+		vectors[i] = embedded[j]
+	}
+	return vectors, nil
+}
+
+// nearDuplicateKey reduces content to its lowercased letters and digits, so texts that
+// only differ in case, whitespace or punctuation share a key
+func nearDuplicateKey(content string) string {
+	return ContentHash(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, content))
+}
@@ -1,8 +1,6 @@
 package fusion
 
 import (
-    "sort"
-
     "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
 )
 
@@ -33,6 +31,6 @@ func RRFScore(lists [][]schema.SearchResult, k int) []schema.SearchResult {
     for _, v := range scores {
         out = append(out, schema.SearchResult{Document: v.doc, Score: v.score})
     }
-    sort.Slice(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+    SortByScore(out)
     return out
 }
@@ -3,6 +3,7 @@ package fusion
 import (
 	"context"
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -44,6 +45,96 @@ func (s *RRFStrategy) Fuse(ctx context.Context, inputs []RetrieverResult, params
 // Name implements Strategy.
 func (s *RRFStrategy) Name() string { return "rrf" }
 
+// WeightedRRFStrategy implements Reciprocal Rank Fusion where each retriever list
+// contributes weight / (k + rank). Weights are keyed by retriever type; retrievers without
+// a weight count 1, so no weights behaves like RRFStrategy. A zero weight drops the list.
+type WeightedRRFStrategy struct {
+	K       int
+	Weights map[string]float64
+}
+
+// NewWeightedRRFStrategy creates a weighted RRF strategy. Negative and non-finite weights
+// are dropped.
+func NewWeightedRRFStrategy(k int, weights map[string]float64) *WeightedRRFStrategy {
+	if k <= 0 {
+		k = 60
+	}
+	return &WeightedRRFStrategy{K: k, Weights: sanitizeWeights(weights)}
+}
+
+// Fuse merges retriever results using weighted reciprocal rank fusion.
+func (s *WeightedRRFStrategy) Fuse(ctx context.Context, inputs []RetrieverResult, params map[string]any) ([]schema.SearchResult, error) {
+	k := s.K
+	if v := lookupInt(params, "k"); v > 0 {
+		k = v
+	}
+	weights := s.Weights
+	if paramWeights, ok := parseStringFloatMap(params["weights"]); ok {
+		weights = sanitizeWeights(paramWeights)
+	}
+
+	type agg struct {
+		doc   schema.Document
+		score float64
+	}
+	scores := make(map[string]*agg, len(inputs)*8)
+	for _, in := range inputs {
+		weight := 1.0
+		if w, ok := weights[in.Retriever]; ok {
+			weight = w
+		}
+		if weight == 0 {
+			continue
+		}
+		for idx, item := range in.Results {
+			id := item.Document.ID
+			if id == "" {
+				continue
+			}
+			entry, ok := scores[id]
+			if !ok {
+				entry = &agg{doc: item.Document}
+				scores[id] = entry
+			}
+			entry.score += weight / (float64(k) + float64(idx+1))
+		}
+	}
+
+	out := make([]schema.SearchResult, 0, len(scores))
+	for _, v := range scores {
+		out = append(out, schema.SearchResult{Document: v.doc, Score: v.score})
+	}
+	SortByScore(out)
+	return out, nil
+}
+
+// Name implements Strategy.
+func (s *WeightedRRFStrategy) Name() string { return "weighted_rrf" }
+
+// SortByScore orders results by descending score. Ties are broken by document ID, so fusing
+// the same inputs always yields the same order even though scores are aggregated in maps.
+func SortByScore(results []schema.SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Document.ID < results[j].Document.ID
+	})
+}
+
+// sanitizeWeights returns the finite, non-negative weights of weights
+func sanitizeWeights(weights map[string]float64) map[string]float64 {
+	out := make(map[string]float64, len(weights))
+	for key, w := range weights {
+		key = strings.TrimSpace(key)
+		if key == "" || w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			continue
+		}
+		out[key] = w
+	}
+	return out
+}
+
 // WeightedStrategy implements weighted score fusion.
 type WeightedStrategy struct {
 	Weights map[string]float64 // weight keyed by retriever identifier
@@ -131,7 +222,7 @@ func (s *WeightedStrategy) Fuse(ctx context.Context, inputs []RetrieverResult, p
 		})
 	}
 
-	sort.Slice(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	SortByScore(out)
 	return out, nil
 }
 
@@ -209,7 +300,7 @@ func (s *LinearCombinationStrategy) Fuse(ctx context.Context, inputs []Retriever
 	for _, v := range scores {
 		out = append(out, schema.SearchResult{Document: v.doc, Score: v.score})
 	}
-	sort.Slice(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	SortByScore(out)
 	return out, nil
 }
 
@@ -240,32 +331,11 @@ func (s *DistributionBasedStrategy) Fuse(ctx context.Context, inputs []Retriever
 		if len(in.Results) == 0 {
 			continue
 		}
-		minScore := in.Results[0].Score
-		maxScore := in.Results[0].Score
-		for _, item := range in.Results {
-			if item.Score < minScore {
-				minScore = item.Score
-			}
-			if item.Score > maxScore {
-				maxScore = item.Score
-			}
-		}
-		rng := maxScore - minScore
-		norm := make([]schema.SearchResult, len(in.Results))
-		for idx, item := range in.Results {
-			copyItem := item
-			if rng > 0 {
-				copyItem.Score = (item.Score - minScore) / rng
-			} else {
-				copyItem.Score = 1.0
-			}
-			norm[idx] = copyItem
-		}
 		normalized = append(normalized, RetrieverResult{
 			Query:      in.Query,
 			Retriever:  in.Retriever,
 			Provider:   in.Provider,
-			Results:    norm,
+			Results:    minMaxNormalize(in.Results),
 			Attributes: in.Attributes,
 		})
 	}
@@ -285,6 +355,147 @@ func (s *DistributionBasedStrategy) Name() string {
 	return "distribution_" + s.Base.Name()
 }
 
+// Score normalizations of NormalizedLinearStrategy
+const (
+	NORMALIZATION_MINMAX = "minmax"
+	NORMALIZATION_ZSCORE = "zscore"
+)
+
+// NormalizedLinearStrategy puts every retriever list on a common scale before combining
+// scores linearly, so a retriever with unbounded scores (e.g. BM25) does not drown out one
+// scoring in [0, 1] (e.g. cosine similarity). Lists are normalized with min-max (default)
+// or z-score. Weights are keyed by retriever type, by "type:provider" or by "list_<index>";
+// retrievers without a weight count 1 and a zero weight drops the list. A document scores
+// the weighted sum of its normalized scores, so missing from a list contributes nothing.
+type NormalizedLinearStrategy struct {
+	Normalization string
+	Weights       map[string]float64
+}
+
+// NewNormalizedLinearStrategy creates a normalized linear strategy. An unknown normalization
+// uses min-max; negative and non-finite weights are dropped.
+func NewNormalizedLinearStrategy(normalization string, weights map[string]float64) *NormalizedLinearStrategy {
+	normalization = strings.ToLower(strings.TrimSpace(normalization))
+	if normalization != NORMALIZATION_ZSCORE {
+		normalization = NORMALIZATION_MINMAX
+	}
+	return &NormalizedLinearStrategy{Normalization: normalization, Weights: sanitizeWeights(weights)}
+}
+
+// Fuse normalizes every list and merges them using the weighted sum of normalized scores.
+func (s *NormalizedLinearStrategy) Fuse(ctx context.Context, inputs []RetrieverResult, params map[string]any) ([]schema.SearchResult, error) {
+	weights := s.Weights
+	if paramWeights, ok := parseStringFloatMap(params["weights"]); ok {
+		weights = sanitizeWeights(paramWeights)
+	}
+	normalize := minMaxNormalize
+	if s.Normalization == NORMALIZATION_ZSCORE {
+		normalize = zScoreNormalize
+	}
+
+	type agg struct {
+		doc   schema.Document
+		score float64
+	}
+	scores := make(map[string]*agg, len(inputs)*8)
+	for idx, in := range inputs {
+		if len(in.Results) == 0 {
+			continue
+		}
+		weight := 1.0
+		if w, ok := weights[in.Retriever]; ok {
+			weight = w
+		} else if w, ok := weights[compoundKey(in.Retriever, in.Provider)]; ok {
+			weight = w
+		} else if w, ok := weights[indexKey(idx)]; ok {
+			weight = w
+		}
+		if weight == 0 {
+			continue
+		}
+		for _, item := range normalize(in.Results) {
+			id := item.Document.ID
+			if id == "" {
+				continue
+			}
+			entry, ok := scores[id]
+			if !ok {
+				entry = &agg{doc: item.Document}
+				scores[id] = entry
+			}
+			entry.score += weight * item.Score
+		}
+	}
+
+	out := make([]schema.SearchResult, 0, len(scores))
+	for _, v := range scores {
+		out = append(out, schema.SearchResult{Document: v.doc, Score: v.score})
+	}
+	SortByScore(out)
+	return out, nil
+}
+
+// Name implements Strategy.
+func (s *NormalizedLinearStrategy) Name() string { return "normalized_linear" }
+
+// minMaxNormalize returns results with scores rescaled to [0, 1]; equal scores all become 1
+func minMaxNormalize(results []schema.SearchResult) []schema.SearchResult {
+	norm := make([]schema.SearchResult, len(results))
+	if len(results) == 0 {
+		return norm
+	}
+	minScore := results[0].Score
+	maxScore := results[0].Score
+	for _, item := range results {
+		if item.Score < minScore {
+			minScore = item.Score
+		}
+		if item.Score > maxScore {
+			maxScore = item.Score
+		}
+	}
+	rng := maxScore - minScore
+	for idx, item := range results {
+		copyItem := item
+		if rng > 0 {
+			copyItem.Score = (item.Score - minScore) / rng
+		} else {
+			copyItem.Score = 1.0
+		}
+		norm[idx] = copyItem
+	}
+	return norm
+}
+
+// zScoreNormalize returns results with scores expressed in standard deviations from the
+// list mean; equal scores all become 0
+func zScoreNormalize(results []schema.SearchResult) []schema.SearchResult {
+	norm := make([]schema.SearchResult, len(results))
+	if len(results) == 0 {
+		return norm
+	}
+	mean := 0.0
+	for _, item := range results {
+		mean += item.Score
+	}
+	mean /= float64(len(results))
+	variance := 0.0
+	for _, item := range results {
+		variance += (item.Score - mean) * (item.Score - mean)
+	}
+	std := math.Sqrt(variance / float64(len(results)))
+	for idx, item := range results {
+		copyItem := item
+		if std > 0 {
+			copyItem.Score = (item.Score - mean) / std
+		} else {
+			copyItem.Score = 0
+		}
+		norm[idx] = copyItem
+	}
+	return norm
+}
+
 // Helper functions -----------------------------------------------------------
 
 func lookupInt(params map[string]any, key string) int {
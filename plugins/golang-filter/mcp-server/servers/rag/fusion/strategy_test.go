@@ -0,0 +1,214 @@
+package fusion
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+func rankedList(retriever string, ids ...string) RetrieverResult {
+	results := make([]schema.SearchResult, len(ids))
+	for i, id := range ids {
+		results[i] = schema.SearchResult{Document: schema.Document{ID: id}, Score: 1}
+	}
+	return RetrieverResult{Retriever: retriever, Results: results}
+}
+
+func fusedIDs(results []schema.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, res := range results {
+		ids[i] = res.Document.ID
+	}
+	return ids
+}
+
+func TestWeightedRRF(t *testing.T) {
+	inputs := []RetrieverResult{
+		rankedList("vector", "a", "b"),
+		rankedList("web", "c", "d"),
+	}
+	tests := []struct {
+		name    string
+		weights map[string]any
+		want    []string
+	}{
+		{"no weights breaks ties by ID", nil, []string{"a", "c", "b", "d"}},
+		{"vector weighted higher", map[string]any{"vector": 3.0}, []string{"a", "b", "c", "d"}},
+		{"web weighted higher", map[string]any{"web": 3.0}, []string{"c", "d", "a", "b"}},
+		{"zero weight drops the list", map[string]any{"web": 0.0}, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, sanitized, err := NewStrategy("weighted_rrf", map[string]any{"k": 10, "weights": tt.weights})
+			if err != nil {
+				t.Fatalf("NewStrategy() error = %v", err)
+			}
+			got, err := s.Fuse(context.Background(), inputs, sanitized)
+			if err != nil {
+				t.Fatalf("Fuse() error = %v", err)
+			}
+			if ids := fusedIDs(got); !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("Fuse() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedRRF_DefaultsMatchRRF(t *testing.T) {
+	inputs := []RetrieverResult{
+		rankedList("vector", "a", "b", "c"),
+		rankedList("bm25", "c", "d"),
+	}
+	s, sanitized, err := NewStrategy("weighted_rrf", nil)
+	if err != nil {
+		t.Fatalf("NewStrategy() error = %v", err)
+	}
+	if sanitized["k"] != 60 {
+		t.Errorf("sanitized k = %v, want the default 60", sanitized["k"])
+	}
+	got, _ := s.Fuse(context.Background(), inputs, sanitized)
+	want, _ := NewRRFStrategy(60).Fuse(context.Background(), inputs, nil)
+	if len(got) != len(want) {
+		t.Fatalf("Fuse() returned %d results, want %d", len(got), len(want))
+	}
+	scores := make(map[string]float64, len(want))
+	for _, res := range want {
+		scores[res.Document.ID] = res.Score
+	}
+	for _, res := range got {
+		if math.Abs(res.Score-scores[res.Document.ID]) > 1e-12 {
+			t.Errorf("score of %s = %v, want the RRF score %v", res.Document.ID, res.Score, scores[res.Document.ID])
+		}
+	}
+}
+
+func TestWeightedRRF_SanitizesWeights(t *testing.T) {
+	_, sanitized, err := NewStrategy("weighted_rrf", map[string]any{
+		"k":       "20",
+		"weights": map[string]any{"vector": 2, "web": "0.5", "bm25": -1.0, "sparse": math.NaN(), " ": 1.0, "graph": 0.0},
+	})
+	if err != nil {
+		t.Fatalf("NewStrategy() error = %v", err)
+	}
+	want := map[string]any{"k": 20, "weights": map[string]float64{"vector": 2, "web": 0.5, "graph": 0}}
+	if !reflect.DeepEqual(sanitized, want) {
+		t.Errorf("sanitized params = %v, want %v", sanitized, want)
+	}
+}
+
+func scoredList(retriever string, scores map[string]float64, ids ...string) RetrieverResult {
+	results := make([]schema.SearchResult, len(ids))
+	for i, id := range ids {
+		results[i] = schema.SearchResult{Document: schema.Document{ID: id}, Score: scores[id]}
+	}
+	return RetrieverResult{Retriever: retriever, Results: results}
+}
+
+func TestNormalizedLinear_ComparedToLinear(t *testing.T) {
+	// BM25 scores are an order of magnitude larger than cosine similarities
+	inputs := []RetrieverResult{
+		scoredList("vector", map[string]float64{"a": 0.92, "b": 0.90, "c": 0.40}, "a", "b", "c"),
+		scoredList("bm25", map[string]float64{"c": 15, "b": 12, "a": 2}, "c", "b", "a"),
+	}
+
+	linear, _ := NewLinearCombinationStrategy([]float64{0.8, 0.2}).Fuse(context.Background(), inputs, nil)
+	// Raw BM25 scores dominate: c ranks first although vector, weighted 4x, ranks it last
+	if ids := fusedIDs(linear); !reflect.DeepEqual(ids, []string{"c", "b", "a"}) {
+		t.Fatalf("linear Fuse() = %v", ids)
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]any
+		want   []string
+	}{
+		{"minmax", map[string]any{"weights": map[string]any{"vector": 0.8, "bm25": 0.2}}, []string{"b", "a", "c"}},
+		{"zscore", map[string]any{"normalization": "zscore", "weights": map[string]any{"vector": 0.8, "bm25": 0.2}}, []string{"b", "a", "c"}},
+		{"bm25 weighted higher", map[string]any{"weights": map[string]any{"vector": 0.2, "bm25": 0.8}}, []string{"b", "c", "a"}},
+		{"zero weight drops the list", map[string]any{"weights": map[string]any{"bm25": 0}}, []string{"a", "b", "c"}},
+		{"positional weights", map[string]any{"weights": map[string]any{"list_0": 0.8, "list_1": 0.2}}, []string{"b", "a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, sanitized, err := NewStrategy("normalized_linear", tt.params)
+			if err != nil {
+				t.Fatalf("NewStrategy() error = %v", err)
+			}
+			got, err := s.Fuse(context.Background(), inputs, sanitized)
+			if err != nil {
+				t.Fatalf("Fuse() error = %v", err)
+			}
+			if ids := fusedIDs(got); !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("Fuse() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizedLinear_Scores(t *testing.T) {
+	inputs := []RetrieverResult{
+		scoredList("vector", map[string]float64{"a": 0.9, "b": 0.5, "c": 0.1}, "a", "b", "c"),
+		scoredList("bm25", map[string]float64{"a": 7, "d": 7}, "a", "d"),
+	}
+	s := NewNormalizedLinearStrategy("", nil)
+	if s.Normalization != NORMALIZATION_MINMAX {
+		t.Errorf("Normalization = %q, want the default minmax", s.Normalization)
+	}
+	got, _ := s.Fuse(context.Background(), inputs, nil)
+	want := map[string]float64{"a": 2, "d": 1, "b": 0.5, "c": 0}
+	for _, res := range got {
+		if math.Abs(res.Score-want[res.Document.ID]) > 1e-9 {
+			t.Errorf("minmax score of %s = %v, want %v", res.Document.ID, res.Score, want[res.Document.ID])
+		}
+	}
+
+	got, _ = NewNormalizedLinearStrategy("zscore", nil).Fuse(context.Background(), inputs, nil)
+	std := math.Sqrt(0.32 / 3)
+	want = map[string]float64{"a": 0.4 / std, "d": 0, "b": 0, "c": -0.4 / std}
+	for _, res := range got {
+		if math.Abs(res.Score-want[res.Document.ID]) > 1e-9 {
+			t.Errorf("zscore score of %s = %v, want %v", res.Document.ID, res.Score, want[res.Document.ID])
+		}
+	}
+}
+
+func TestStrategies_DeterministicTies(t *testing.T) {
+	// Every document scores 1 and each rank is shared by one document of every list, so
+	// nearly all fused scores tie
+	var vector, bm25, web []string
+	for i := 0; i < 30; i++ {
+		vector = append(vector, fmt.Sprintf("v%02d", i))
+		bm25 = append(bm25, fmt.Sprintf("b%02d", i))
+		web = append(web, fmt.Sprintf("w%02d", i))
+	}
+	inputs := []RetrieverResult{rankedList("vector", vector...), rankedList("bm25", bm25...), rankedList("web", web...)}
+
+	for _, name := range []string{"rrf", "weighted_rrf", "weighted", "linear", "normalized_linear", "distribution"} {
+		t.Run(name, func(t *testing.T) {
+			s, sanitized, err := NewStrategy(name, nil)
+			if err != nil {
+				t.Fatalf("NewStrategy() error = %v", err)
+			}
+			first, _ := s.Fuse(context.Background(), inputs, sanitized)
+			if len(first) != 90 {
+				t.Fatalf("Fuse() returned %d results, want 90", len(first))
+			}
+			for i := 1; i < len(first); i++ {
+				if first[i-1].Score == first[i].Score && first[i-1].Document.ID > first[i].Document.ID {
+					t.Fatalf("tied results %s and %s are not ordered by ID", first[i-1].Document.ID, first[i].Document.ID)
+				}
+			}
+			want := fusedIDs(first)
+			for run := 0; run < 20; run++ {
+				got, _ := s.Fuse(context.Background(), inputs, sanitized)
+				if ids := fusedIDs(got); !reflect.DeepEqual(ids, want) {
+					t.Fatalf("run %d = %v, want %v", run, ids, want)
+				}
+			}
+		})
+	}
+}
@@ -32,3 +32,9 @@ type Strategy interface {
 type MetadataProvider interface {
 	Metadata() map[string]any
 }
+
+// RolloutStrategy is implemented by strategies that serve only part of the traffic.
+type RolloutStrategy interface {
+	// Arm returns the rollout arm the request described by params is assigned to.
+	Arm(params map[string]any) string
+}
@@ -2,10 +2,13 @@ package gating
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/cache"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/feedback"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
@@ -18,6 +21,7 @@ type Provider interface {
 	Evaluate(ctx context.Context, query string, profile config.RetrievalProfile, m *metrics.RetrievalMetrics) Decision
 	ApplyDecision(decision Decision, profile config.RetrievalProfile) config.RetrievalProfile
 	WithFeedback(manager *feedback.Manager, cfg *config.FeedbackConfig)
+	WithDecisionCache(capacity int, ttl time.Duration)
 }
 
 // defaultProvider is the default implementation
@@ -25,6 +29,7 @@ type defaultProvider struct {
 	vectorRetriever retriever.Retriever
 	feedbackMgr     *feedback.Manager
 	feedbackCfg     config.FeedbackConfig
+	decisions       cache.Cache
 }
 
 // NewProvider creates a new gating provider
@@ -42,12 +47,35 @@ func (p *defaultProvider) WithFeedback(manager *feedback.Manager, cfg *config.Fe
 	}
 }
 
+// WithDecisionCache caches successful decisions for ttl, keyed on the normalized query,
+// the request's metadata filters and the profile's gate settings. A threshold change
+// therefore misses the cache instead of reusing a decision made under the old gates.
+func (p *defaultProvider) WithDecisionCache(capacity int, ttl time.Duration) {
+	if ttl <= 0 {
+		p.decisions = nil
+		return
+	}
+	p.decisions = cache.NewLRU(capacity, ttl)
+}
+
+// decisionKey identifies everything a preflight decision depends on
+func decisionKey(ctx context.Context, query string, profile config.RetrievalProfile) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	retrievers := append([]string(nil), profile.Retrievers...)
+	sort.Strings(retrievers)
+	return fmt.Sprintf("%s|%.6f|%.6f|%t|%t|%s|%v", normalized, profile.VectorGate, profile.VectorLowGate,
+		profile.UseWeb, profile.ForceWebOnLow, strings.Join(retrievers, ","), retriever.FiltersFromContext(ctx))
+}
+
 // Decision represents a gating decision
 type Decision struct {
 	ShouldSuppressWeb bool
 	ShouldForceWeb    bool
-	TopScore          float64
-	Reason            string
+	// EmbeddingFailed is set when the preflight could not embed the query; vector
+	// retrieval and HyDE are then skipped for this request.
+	EmbeddingFailed bool
+	TopScore        float64
+	Reason          string
 }
 
 // Evaluate performs vector-based gating and returns decision
@@ -61,11 +89,33 @@ func (p *defaultProvider) Evaluate(ctx context.Context, query string, profile co
 		return Decision{Reason: "gating_disabled"}
 	}
 
+	var key string
+	if p.decisions != nil {
+		key = decisionKey(ctx, query, profile)
+		if cached, ok := p.decisions.Get(key); ok {
+			decision := cached.(Decision)
+			if m != nil {
+				m.AddGatingDecision("preflight_cached", decision.TopScore)
+				m.AddGatingDecision(decision.Reason, decision.TopScore)
+			}
+			api.LogInfof("gating: %s (cached)", decision.Reason)
+			return decision
+		}
+	}
+
 	// Perform vector preflight
 	preflightStart := time.Now()
 	preflightResults, err := p.vectorRetriever.Search(ctx, query, 5)
 	preflightLatency := time.Since(preflightStart).Milliseconds()
 
+	if errors.Is(err, retriever.ErrEmbeddingFailed) {
+		api.LogWarnf("gating: embedding unavailable, degrading to non-vector retrievers: %v", err)
+		if m != nil {
+			m.RecordEmbeddingError(err)
+			m.AddGatingDecision("embedding_failed", 0)
+		}
+		return Decision{EmbeddingFailed: true, Reason: "embedding_failed"}
+	}
 	if err != nil || len(preflightResults) == 0 {
 		api.LogWarnf("gating: vector preflight failed: %v", err)
 		return Decision{Reason: "preflight_failed"}
@@ -115,15 +165,22 @@ func (p *defaultProvider) Evaluate(ctx context.Context, query string, profile co
 	}
 
 	if m != nil {
-		m.AddGatingDecision(decision.Reason)
+		m.AddGatingDecision(decision.Reason, topScore)
 	}
 
 	api.LogInfof("gating: %s", decision.Reason)
+	if p.decisions != nil {
+		p.decisions.Set(key, decision, 0)
+	}
 	return decision
 }
 
 // ApplyDecision applies gating decision to profile
 func (p *defaultProvider) ApplyDecision(decision Decision, profile config.RetrievalProfile) config.RetrievalProfile {
+	if decision.EmbeddingFailed {
+		profile = SkipVector(profile)
+	}
+
 	if decision.ShouldSuppressWeb {
 		profile.UseWeb = false
 		profile.Retrievers = filterRetrievers(profile.Retrievers, "web")
@@ -210,6 +267,17 @@ func (p *defaultProvider) applyFeedbackAdjustments(profile config.RetrievalProfi
 	return profile
 }
 
+// SkipVector removes vector retrieval and HyDE from a profile, for use while the embedding
+// provider is failing. Cascades whose stages depend on vector retrieval are disabled.
+func SkipVector(profile config.RetrievalProfile) config.RetrievalProfile {
+	profile.Retrievers = filterRetrievers(profile.Retrievers, "vector")
+	profile.HYDE.Enable = false
+	if containsRetriever([]string{profile.Cascade.Stage1.Retriever, profile.Cascade.Stage2.Retriever}, "vector") {
+		profile.Cascade.Enable = false
+	}
+	return profile
+}
+
 // containsRetriever checks if retriever list contains a type
 func containsRetriever(retrievers []string, typ string) bool {
 	typLower := strings.ToLower(typ)
@@ -0,0 +1,93 @@
+package gating
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// mockCommonCAPI swallows envoy logs so provider code can run outside envoy
+type mockCommonCAPI struct{}
+
+func (m *mockCommonCAPI) Log(level api.LogType, message string) {}
+
+func (m *mockCommonCAPI) LogLevel() api.LogType { return api.Error }
+
+func TestMain(m *testing.M) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	m.Run()
+}
+
+// countingRetriever returns a fixed preflight score and counts searches.
+type countingRetriever struct {
+	score    float64
+	searches atomic.Int32
+}
+
+func (r *countingRetriever) Type() string { return "vector" }
+
+func (r *countingRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+	r.searches.Add(1)
+	return []schema.SearchResult{{Document: schema.Document{ID: "doc-1"}, Score: r.score}}, nil
+}
+
+func TestEvaluate_CachesDecisionWithinTTL(t *testing.T) {
+	vector := &countingRetriever{score: 0.9}
+	provider := NewProvider(vector)
+	provider.WithDecisionCache(16, time.Minute)
+	profile := config.RetrievalProfile{Retrievers: []string{"vector", "web"}, VectorGate: 0.8, VectorLowGate: 0.2}
+
+	first := provider.Evaluate(context.Background(), "What is Higress?", profile, nil)
+	m := metrics.NewRetrievalMetrics()
+	second := provider.Evaluate(context.Background(), "  what is   higress? ", profile, m)
+
+	if n := vector.searches.Load(); n != 1 {
+		t.Errorf("preflight ran %d times, want 1", n)
+	}
+	if !second.ShouldSuppressWeb || second != first {
+		t.Errorf("cached decision = %+v, want %+v", second, first)
+	}
+	if len(m.GatingDecisions) == 0 || m.GatingDecisions[0] != "preflight_cached" {
+		t.Errorf("gating decisions = %v, want preflight_cached recorded", m.GatingDecisions)
+	}
+	if m.GatingTopScore != 0.9 {
+		t.Errorf("gating top score = %v, want the cached preflight score 0.9", m.GatingTopScore)
+	}
+}
+
+func TestEvaluate_ThresholdChangeMissesCache(t *testing.T) {
+	vector := &countingRetriever{score: 0.9}
+	provider := NewProvider(vector)
+	provider.WithDecisionCache(16, time.Minute)
+	profile := config.RetrievalProfile{Retrievers: []string{"vector", "web"}, VectorGate: 0.8}
+
+	provider.Evaluate(context.Background(), "what is higress?", profile, nil)
+	profile.VectorGate = 0.95
+	decision := provider.Evaluate(context.Background(), "what is higress?", profile, nil)
+
+	if n := vector.searches.Load(); n != 2 {
+		t.Errorf("preflight ran %d times, want 2 after the gate changed", n)
+	}
+	if decision.ShouldSuppressWeb {
+		t.Errorf("decision = %+v, want the new gate applied", decision)
+	}
+}
+
+func TestEvaluate_NoCacheByDefault(t *testing.T) {
+	vector := &countingRetriever{score: 0.5}
+	provider := NewProvider(vector)
+	profile := config.RetrievalProfile{VectorGate: 0.8}
+
+	provider.Evaluate(context.Background(), "q", profile, nil)
+	provider.Evaluate(context.Background(), "q", profile, nil)
+
+	if n := vector.searches.Load(); n != 2 {
+		t.Errorf("preflight ran %d times, want 2 without a cache", n)
+	}
+}
@@ -0,0 +1,85 @@
+package rag
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HEALTH_CHECK_TIMEOUT bounds every dependency probe, the startup vector store ping included
+const HEALTH_CHECK_TIMEOUT = 10 * time.Second
+
+// Status values of ComponentHealth
+const (
+	HEALTH_STATUS_OK       = "ok"
+	HEALTH_STATUS_ERROR    = "error"
+	HEALTH_STATUS_DISABLED = "disabled"
+)
+
+// healthProbeText is embedded and sent to the LLM by the probes; short to keep them cheap
+const healthProbeText = "ping"
+
+// ComponentHealth is the outcome of probing one dependency
+type ComponentHealth struct {
+	Provider  string `json:"provider,omitempty"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HealthReport is the outcome of probing the vector store, embedding and LLM providers.
+// Healthy is false when any configured dependency fails its probe.
+type HealthReport struct {
+	Healthy   bool            `json:"healthy"`
+	VectorDB  ComponentHealth `json:"vector_db"`
+	Embedding ComponentHealth `json:"embedding"`
+	LLM       ComponentHealth `json:"llm"`
+}
+
+// Health probes the dependencies concurrently: the vector store is pinged, a short text is
+// embedded and, when an LLM is configured, a one-word completion is requested. Each probe
+// is bounded by HEALTH_CHECK_TIMEOUT.
+func (r *RAGClient) Health(ctx context.Context) HealthReport {
+	r, done := r.acquire()
+	defer done()
+	report := HealthReport{
+		VectorDB:  ComponentHealth{Provider: r.vectordbProvider.GetProviderType()},
+		Embedding: ComponentHealth{Provider: r.embeddingProvider.GetProviderType()},
+		LLM:       ComponentHealth{Provider: r.config.LLM.Provider, Status: HEALTH_STATUS_DISABLED},
+	}
+	var wg sync.WaitGroup
+	probe := func(component *ComponentHealth, check func(ctx context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, HEALTH_CHECK_TIMEOUT)
+			defer cancel()
+			start := time.Now()
+			err := check(ctx)
+			component.LatencyMs = time.Since(start).Milliseconds()
+			component.Status = HEALTH_STATUS_OK
+			if err != nil {
+				component.Status = HEALTH_STATUS_ERROR
+				component.Error = err.Error()
+			}
+		}()
+	}
+	probe(&report.VectorDB, r.vectordbProvider.Ping)
+	probe(&report.Embedding, func(ctx context.Context) error {
+		_, err := r.embeddingProvider.GetEmbedding(ctx, healthProbeText)
+		return err
+	})
+	if r.llmProvider != nil {
+		report.LLM.Provider = r.llmProvider.GetProviderType()
+		probe(&report.LLM, func(ctx context.Context) error {
+			_, err := r.llmProvider.GenerateCompletion(ctx, healthProbeText)
+			return err
+		})
+	}
+	wg.Wait()
+
+	report.Healthy = report.VectorDB.Status != HEALTH_STATUS_ERROR &&
+		report.Embedding.Status != HEALTH_STATUS_ERROR &&
+		report.LLM.Status != HEALTH_STATUS_ERROR
+	return report
+}
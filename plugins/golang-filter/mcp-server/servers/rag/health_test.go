@@ -0,0 +1,50 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+func TestRAGClient_Health(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}}, &MockLLMProvider{})
+	report := client.Health(context.Background())
+	if !report.Healthy {
+		t.Fatalf("Health() = %+v, want healthy", report)
+	}
+	for name, component := range map[string]ComponentHealth{"vector_db": report.VectorDB, "embedding": report.Embedding, "llm": report.LLM} {
+		if component.Status != HEALTH_STATUS_OK || component.Provider == "" {
+			t.Errorf("%s = %+v, want ok with its provider", name, component)
+		}
+	}
+
+	client.embeddingProvider = &failingEmbeddingProvider{}
+	client.llmProvider = nil
+	report = client.Health(context.Background())
+	if report.Healthy || report.Embedding.Status != HEALTH_STATUS_ERROR || report.Embedding.Error == "" {
+		t.Errorf("Health() embedding = %+v, want the failure reported", report.Embedding)
+	}
+	if report.VectorDB.Status != HEALTH_STATUS_OK || report.LLM.Status != HEALTH_STATUS_DISABLED {
+		t.Errorf("Health() = %+v, want vector_db ok and llm disabled", report)
+	}
+}
+
+// unreachableVectorStore fails every ping
+type unreachableVectorStore struct {
+	*memoryVectorStore
+}
+
+func (s *unreachableVectorStore) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestRAGClient_HealthVectorDBDown(t *testing.T) {
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}}, nil)
+	client.vectordbProvider = &unreachableVectorStore{store}
+	report := client.Health(context.Background())
+	if report.Healthy || report.VectorDB.Status != HEALTH_STATUS_ERROR || report.VectorDB.Error != "connection refused" {
+		t.Errorf("Health() vector_db = %+v, want the ping error", report.VectorDB)
+	}
+}
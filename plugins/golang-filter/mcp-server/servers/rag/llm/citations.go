@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CitationInstruction is appended to the prompt of a chat that returns citations
+const CitationInstruction = `
+Citation rules:
+- After every statement taken from the context, add the markers of the segments supporting it, e.g. [1] or [1][3].
+- Only use markers of the segments above; never invent a marker.
+`
+
+// citationMarker matches a citation marker such as [1] or [1, 3] with the spaces before it
+var citationMarker = regexp.MustCompile(`[ \t]*\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// ExtractCitations validates the citation markers of answer against contexts numbered 1 to
+// contexts. It returns answer with the indices that match no context removed from their
+// markers, and markers left empty removed altogether, along with the cited indices in
+// order of first citation.
+func ExtractCitations(answer string, contexts int) (string, []int) {
+	cited := make([]int, 0)
+	seen := make(map[int]bool)
+	cleaned := citationMarker.ReplaceAllStringFunc(answer, func(marker string) string {
+		leading := marker[:strings.Index(marker, "[")]
+		valid := make([]string, 0, 1)
+		for _, field := range strings.Split(citationMarker.FindStringSubmatch(marker)[1], ",") {
+			index, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || index < 1 || index > contexts {
+				continue
+			}
+			valid = append(valid, strconv.Itoa(index))
+			if !seen[index] {
+				seen[index] = true
+				cited = append(cited, index)
+			}
+		}
+		if len(valid) == 0 {
+			return ""
+		}
+		return leading + "[" + strings.Join(valid, ", ") + "]"
+	})
+	return cleaned, cited
+}
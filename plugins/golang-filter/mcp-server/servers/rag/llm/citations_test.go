@@ -0,0 +1,30 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractCitations(t *testing.T) {
+	tests := []struct {
+		name      string
+		answer    string
+		contexts  int
+		want      string
+		wantCited []int
+	}{
+		{"valid markers", "Higress is a gateway [2]. It runs Wasm plugins [1][2].", 2, "Higress is a gateway [2]. It runs Wasm plugins [1][2].", []int{2, 1}},
+		{"out of range", "Higress is a gateway [3]. It is fast [0].", 2, "Higress is a gateway. It is fast.", []int{}},
+		{"list marker", "Use helm [1, 4,2].", 3, "Use helm [1, 2].", []int{1, 2}},
+		{"no markers", "Use helm.", 3, "Use helm.", []int{}},
+		{"not a marker", "Set replicas[a] to 3 [1].", 1, "Set replicas[a] to 3 [1].", []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cited := ExtractCitations(tt.answer, tt.contexts)
+			if got != tt.want || !reflect.DeepEqual(cited, tt.wantCited) {
+				t.Errorf("ExtractCitations() = %q, %v, want %q, %v", got, cited, tt.want, tt.wantCited)
+			}
+		})
+	}
+}
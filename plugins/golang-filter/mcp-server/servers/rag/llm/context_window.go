@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+// modelContextWindows are the context windows in tokens of well-known models, matched by
+// the longest prefix of the model name
+var modelContextWindows = map[string]int{
+	"gpt-3.5-turbo":  16385,
+	"gpt-4":          8192,
+	"gpt-4-32k":      32768,
+	"gpt-4-turbo":    128000,
+	"gpt-4o":         128000,
+	"gpt-4.1":        1047576,
+	"o1":             200000,
+	"o3":             200000,
+	"o4-mini":        200000,
+	"qwen-turbo":     131072,
+	"qwen-plus":      131072,
+	"qwen-max":       32768,
+	"qwen-long":      10000000,
+	"qwen2.5":        32768,
+	"qwen3":          131072,
+	"deepseek-chat":  65536,
+	"deepseek-r1":    65536,
+	"llama3":         8192,
+	"llama3.1":       131072,
+	"llama3.2":       131072,
+	"mistral":        32768,
+	"moonshot-v1-8k": 8192,
+	"glm-4":          131072,
+}
+
+// ModelContextWindow returns the context window of model in tokens, or 0 when the model is
+// not known.
+func ModelContextWindow(model string) int {
+	model = strings.ToLower(model)
+	window, matched := 0, 0
+	for prefix, w := range modelContextWindows {
+		if len(prefix) > matched && strings.HasPrefix(model, prefix) {
+			window, matched = w, len(prefix)
+		}
+	}
+	return window
+}
+
+// PromptTokenLimit returns the number of tokens the prompt of an answer may use:
+// cfg.MaxContextTokens when set, otherwise the window of cfg.Model less the MaxTokens
+// reserved for the completion. It returns 0, i.e. no limit, for unknown models.
+func PromptTokenLimit(cfg config.LLMConfig) int {
+	if cfg.MaxContextTokens > 0 {
+		return cfg.MaxContextTokens
+	}
+	window := ModelContextWindow(cfg.Model)
+	if window <= cfg.MaxTokens {
+		return 0
+	}
+	return window - cfg.MaxTokens
+}
@@ -0,0 +1,26 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+func TestPromptTokenLimit(t *testing.T) {
+	tests := []struct {
+		cfg  config.LLMConfig
+		want int
+	}{
+		{config.LLMConfig{Model: "gpt-4o-mini", MaxTokens: 2000}, 126000},
+		{config.LLMConfig{Model: "gpt-4-32k-0613"}, 32768},
+		{config.LLMConfig{Model: "Qwen-Max", MaxTokens: 768}, 32000},
+		{config.LLMConfig{Model: "gpt-4o", MaxContextTokens: 4000}, 4000},
+		{config.LLMConfig{Model: "my-finetune"}, 0},
+		{config.LLMConfig{Model: "gpt-4", MaxTokens: 9000}, 0},
+	}
+	for _, tt := range tests {
+		if got := PromptTokenLimit(tt.cfg); got != tt.want {
+			t.Errorf("PromptTokenLimit(%+v) = %d, want %d", tt.cfg, got, tt.want)
+		}
+	}
+}
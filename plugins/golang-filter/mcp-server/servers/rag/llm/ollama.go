@@ -0,0 +1,192 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+const (
+	OLLAMA_DEFAULT_BASE_URL = "http://localhost:11434"
+	OLLAMA_DEFAULT_MODEL    = "llama3"
+	OLLAMA_CHAT_PATH        = "/api/chat"
+	OLLAMA_DEFAULT_TIMEOUT  = 120 * time.Second
+)
+
+// OllamaProvider calls a local Ollama server through its /api/chat endpoint.
+type OllamaProvider struct {
+	client      *http.Client
+	baseURL     string
+	model       string
+	temperature float64
+	maxTokens   int
+}
+
+type ollamaProviderInitializer struct{}
+
+func (i *ollamaProviderInitializer) validateConfig(cfg *config.LLMConfig) error {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = OLLAMA_DEFAULT_BASE_URL
+	}
+	if !strings.HasPrefix(cfg.BaseURL, "http://") && !strings.HasPrefix(cfg.BaseURL, "https://") {
+		return fmt.Errorf("[ollama llm] invalid base url: %s", cfg.BaseURL)
+	}
+	if cfg.Model == "" {
+		cfg.Model = OLLAMA_DEFAULT_MODEL
+	}
+	if cfg.Temperature < 0 || cfg.Temperature > 2 {
+		cfg.Temperature = 0.5
+	}
+	return nil
+}
+
+func (i *ollamaProviderInitializer) CreateProvider(cfg config.LLMConfig) (Provider, error) {
+	if err := i.validateConfig(&cfg); err != nil {
+		return nil, err
+	}
+	return &OllamaProvider{
+		client:      &http.Client{Timeout: OLLAMA_DEFAULT_TIMEOUT},
+		baseURL:     strings.TrimRight(cfg.BaseURL, "/"),
+		model:       cfg.Model,
+		temperature: cfg.Temperature,
+		maxTokens:   cfg.MaxTokens,
+	}, nil
+}
+
+type ollamaChatRequest struct {
+	Model    string         `json:"model"`
+	Messages []ChatMessage  `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  map[string]any `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message ChatMessage `json:"message"`
+	Done    bool        `json:"done"`
+	Error   string      `json:"error,omitempty"`
+	// Token counts, reported on the final response
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+	EvalCount       int `json:"eval_count,omitempty"`
+}
+
+// GenerateCompletion implements Provider interface.
+func (o *OllamaProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return o.GenerateChat(ctx, []ChatMessage{{Role: ROLE_USER, Content: prompt}})
+}
+
+// GenerateChat implements Provider interface.
+func (o *OllamaProvider) GenerateChat(ctx context.Context, messages []ChatMessage) (string, error) {
+	return o.chat(ctx, messages, nil)
+}
+
+// StreamChat generates a response with streaming enabled, calling onChunk for every
+// partial message as it arrives. It returns the full response.
+func (o *OllamaProvider) StreamChat(ctx context.Context, messages []ChatMessage, onChunk func(string)) (string, error) {
+	if onChunk == nil {
+		onChunk = func(string) {}
+	}
+	return o.chat(ctx, messages, onChunk)
+}
+
+func (o *OllamaProvider) GetProviderType() string {
+	return PROVIDER_TYPE_OLLAMA
+}
+
+func (o *OllamaProvider) chat(ctx context.Context, messages []ChatMessage, onChunk func(string)) (string, error) {
+	body, err := json.Marshal(ollamaChatRequest{
+		Model:    o.model,
+		Messages: messages,
+		Stream:   onChunk != nil,
+		Options:  o.options(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("ollama llm: marshal request failed: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+OLLAMA_CHAT_PATH, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("ollama llm: create request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ollama llm error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var errResp ollamaChatResponse
+		statusErr := &StatusError{Provider: PROVIDER_TYPE_OLLAMA, StatusCode: resp.StatusCode}
+		if json.Unmarshal(data, &errResp) == nil {
+			statusErr.Message = errResp.Error
+		}
+		return "", statusErr
+	}
+
+	if onChunk == nil {
+		var out ollamaChatResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return "", fmt.Errorf("ollama llm: decode response failed: %w", err)
+		}
+		if out.Error != "" {
+			return "", fmt.Errorf("ollama llm error: %s", out.Error)
+		}
+		RecordUsage(ctx, messages, out.Message.Content, out.PromptEvalCount, out.EvalCount)
+		return out.Message.Content, nil
+	}
+
+	// Streaming responses are newline-delimited JSON objects
+	var sb strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return sb.String(), fmt.Errorf("ollama llm: decode stream chunk failed: %w", err)
+		}
+		if chunk.Error != "" {
+			return sb.String(), fmt.Errorf("ollama llm error: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			sb.WriteString(chunk.Message.Content)
+			onChunk(chunk.Message.Content)
+		}
+		if chunk.Done {
+			RecordUsage(ctx, messages, sb.String(), chunk.PromptEvalCount, chunk.EvalCount)
+			return sb.String(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return sb.String(), fmt.Errorf("ollama llm: read stream failed: %w", err)
+	}
+	return sb.String(), errors.New("ollama llm: stream ended before done")
+}
+
+// options maps the generic LLM settings onto Ollama model options.
+func (o *OllamaProvider) options() map[string]any {
+	opts := map[string]any{}
+	if o.temperature > 0 {
+		opts["temperature"] = o.temperature
+	}
+	if o.maxTokens > 0 {
+		opts["num_predict"] = o.maxTokens
+	}
+	if len(opts) == 0 {
+		return nil
+	}
+	return opts
+}
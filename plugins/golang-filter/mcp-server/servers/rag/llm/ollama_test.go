@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+// recordedOllamaChat is a non-streaming /api/chat response captured from Ollama
+const recordedOllamaChat = `{"model":"llama3","created_at":"2024-05-01T10:00:00.000Z","message":{"role":"assistant","content":"Higress is a cloud native API gateway."},"done_reason":"stop","done":true,"total_duration":512000000,"eval_count":12}`
+
+func newOllamaTestProvider(t *testing.T, baseURL string) *OllamaProvider {
+	t.Helper()
+	provider, err := NewLLMProvider(config.LLMConfig{Provider: PROVIDER_TYPE_OLLAMA, BaseURL: baseURL, Model: "llama3", Temperature: 0.2, MaxTokens: 256})
+	if err != nil {
+		t.Fatalf("NewLLMProvider() error = %v", err)
+	}
+	return provider.(*OllamaProvider)
+}
+
+func TestOllamaProvider_GenerateChat(t *testing.T) {
+	var got ollamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != OLLAMA_CHAT_PATH {
+			t.Errorf("request path = %s, want %s", r.URL.Path, OLLAMA_CHAT_PATH)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(recordedOllamaChat))
+	}))
+	defer server.Close()
+
+	provider := newOllamaTestProvider(t, server.URL+"/")
+	answer, err := provider.GenerateChat(context.Background(), SystemUserMessages("be brief", "what is higress?"))
+	if err != nil {
+		t.Fatalf("GenerateChat() error = %v", err)
+	}
+	if answer != "Higress is a cloud native API gateway." {
+		t.Errorf("GenerateChat() = %q", answer)
+	}
+	if got.Model != "llama3" || got.Stream || len(got.Messages) != 2 || got.Messages[0].Role != ROLE_SYSTEM {
+		t.Errorf("request = %+v, want non-streaming llama3 chat with system and user messages", got)
+	}
+	if got.Options["temperature"] != 0.2 || got.Options["num_predict"] != float64(256) {
+		t.Errorf("options = %v, want temperature 0.2 and num_predict 256", got.Options)
+	}
+
+	if _, err := provider.GenerateCompletion(context.Background(), "hi"); err != nil {
+		t.Fatalf("GenerateCompletion() error = %v", err)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Role != ROLE_USER || got.Messages[0].Content != "hi" {
+		t.Errorf("completion request messages = %+v, want a single user message", got.Messages)
+	}
+}
+
+func TestOllamaProvider_StreamChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, chunk := range []string{
+			`{"message":{"role":"assistant","content":"Hig"},"done":false}`,
+			`{"message":{"role":"assistant","content":"ress"},"done":false}`,
+			`{"message":{"role":"assistant","content":""},"done":true}`,
+		} {
+			_, _ = w.Write([]byte(chunk + "\n"))
+		}
+	}))
+	defer server.Close()
+
+	var chunks []string
+	answer, err := newOllamaTestProvider(t, server.URL).StreamChat(context.Background(), []ChatMessage{{Role: ROLE_USER, Content: "name?"}}, func(s string) {
+		chunks = append(chunks, s)
+	})
+	if err != nil {
+		t.Fatalf("StreamChat() error = %v", err)
+	}
+	if answer != "Higress" || strings.Join(chunks, "|") != "Hig|ress" {
+		t.Errorf("StreamChat() = %q chunks %v", answer, chunks)
+	}
+}
+
+func TestOllamaProvider_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"model 'llama3' not found, try pulling it first"}`))
+	}))
+	defer server.Close()
+
+	if _, err := newOllamaTestProvider(t, server.URL).GenerateCompletion(context.Background(), "hi"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("GenerateCompletion() error = %v, want model not found", err)
+	}
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	url := unreachable.URL
+	unreachable.Close()
+	if _, err := newOllamaTestProvider(t, url).GenerateCompletion(context.Background(), "hi"); err == nil {
+		t.Error("GenerateCompletion() expected error for unreachable endpoint")
+	}
+
+	if _, err := NewLLMProvider(config.LLMConfig{Provider: PROVIDER_TYPE_OLLAMA, BaseURL: "localhost:11434"}); err == nil {
+		t.Error("NewLLMProvider() expected error for base url without scheme")
+	}
+}
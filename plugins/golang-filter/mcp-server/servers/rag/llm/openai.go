@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
 	"github.com/openai/openai-go/v2"
@@ -54,6 +55,11 @@ func (i *openAIProviderInitializer) CreateProvider(cfg config.LLMConfig) (Provid
 	if cfg.BaseURL != "" {
 		clientOptions = append(clientOptions, option.WithBaseURL(cfg.BaseURL))
 	}
+	clientOptions = append(clientOptions, headerOptions(cfg.Headers)...)
+	// With max_retries, NewLLMProvider retries calls itself; the client must not retry them again
+	if cfg.MaxRetries > 0 {
+		clientOptions = append(clientOptions, option.WithMaxRetries(0))
+	}
 
 	// Create OpenAI client
 	client := openai.NewClient(clientOptions...)
@@ -66,29 +72,28 @@ func (i *openAIProviderInitializer) CreateProvider(cfg config.LLMConfig) (Provid
 	}, nil
 }
 
-// GenerateCompletion implements Provider interface.
-func (o *OpenAIProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
-	// Create chat request
-	params := openai.ChatCompletionNewParams{
-		Model: o.model,
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(prompt),
-		},
-	}
-
-	// Set optional parameters
-	if o.temperature > 0 {
-		temperature := float64(o.temperature)
-		params.Temperature = param.Opt[float64]{Value: temperature}
+// headerOptions adds the configured extra headers to every request. Authorization and
+// Content-Type are left to the client, so the headers merge with them instead of replacing them.
+func headerOptions(headers map[string]string) []option.RequestOption {
+	opts := make([]option.RequestOption, 0, len(headers))
+	for k, v := range headers {
+		if strings.EqualFold(k, "Authorization") || strings.EqualFold(k, "Content-Type") {
+			continue
+		}
+		opts = append(opts, option.WithHeader(k, v))
 	}
+	return opts
+}
 
-	if o.maxTokens > 0 {
-		maxTokens := int64(o.maxTokens)
-		params.MaxTokens = param.Opt[int64]{Value: maxTokens}
-	}
+// GenerateCompletion implements Provider interface.
+func (o *OpenAIProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return o.GenerateChat(ctx, []ChatMessage{{Role: ROLE_USER, Content: prompt}})
+}
 
+// GenerateChat implements Provider interface.
+func (o *OpenAIProvider) GenerateChat(ctx context.Context, messages []ChatMessage) (string, error) {
 	// Send request
-	response, err := o.client.Chat.Completions.New(ctx, params)
+	response, err := o.client.Chat.Completions.New(ctx, o.chatParams(messages))
 	if err != nil {
 		// Handle error
 		return "", fmt.Errorf("openai llm error: %w", err)
@@ -100,7 +105,71 @@ func (o *OpenAIProvider) GenerateCompletion(ctx context.Context, prompt string)
 	}
 
 	// Return generated content
-	return response.Choices[0].Message.Content, nil
+	content := response.Choices[0].Message.Content
+	RecordUsage(ctx, messages, content, int(response.Usage.PromptTokens), int(response.Usage.CompletionTokens))
+	return content, nil
+}
+
+// StreamChat generates a response over server-sent events, calling onChunk for every
+// content delta as it arrives. It returns the full response.
+func (o *OpenAIProvider) StreamChat(ctx context.Context, messages []ChatMessage, onChunk func(string)) (string, error) {
+	if onChunk == nil {
+		onChunk = func(string) {}
+	}
+	params := o.chatParams(messages)
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	stream := o.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	var content strings.Builder
+	var usage openai.CompletionUsage
+	for stream.Next() {
+		chunk := stream.Current()
+		if chunk.Usage.TotalTokens > 0 {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		content.WriteString(delta)
+		onChunk(delta)
+	}
+	if err := stream.Err(); err != nil {
+		return "", fmt.Errorf("openai llm error: %w", err)
+	}
+	RecordUsage(ctx, messages, content.String(), int(usage.PromptTokens), int(usage.CompletionTokens))
+	return content.String(), nil
+}
+
+// chatParams builds the chat completion request for messages
+func (o *OpenAIProvider) chatParams(messages []ChatMessage) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Model:    o.model,
+		Messages: make([]openai.ChatCompletionMessageParamUnion, 0, len(messages)),
+	}
+	for _, msg := range messages {
+		switch msg.Role {
+		case ROLE_SYSTEM:
+			params.Messages = append(params.Messages, openai.SystemMessage(msg.Content))
+		case ROLE_ASSISTANT:
+			params.Messages = append(params.Messages, openai.AssistantMessage(msg.Content))
+		default:
+			params.Messages = append(params.Messages, openai.UserMessage(msg.Content))
+		}
+	}
+
+	// Set optional parameters
+	if o.temperature > 0 {
+		temperature := float64(o.temperature)
+		params.Temperature = param.Opt[float64]{Value: temperature}
+	}
+
+	if o.maxTokens > 0 {
+		maxTokens := int64(o.maxTokens)
+		params.MaxTokens = param.Opt[int64]{Value: maxTokens}
+	}
+	return params
 }
 
 func (o *OpenAIProvider) GetProviderType() string {
@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+func TestOpenAIProvider_CustomHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "1",
+			"object":  "chat.completion",
+			"model":   "gpt-4o",
+			"choices": []map[string]any{{"index": 0, "message": map[string]any{"role": "assistant", "content": "Higress"}, "finish_reason": "stop"}},
+		})
+	}))
+	defer server.Close()
+
+	provider, err := NewLLMProvider(config.LLMConfig{
+		Provider: PROVIDER_TYPE_OPENAI,
+		APIKey:   "k",
+		BaseURL:  server.URL,
+		Headers:  map[string]string{"X-Api-Key": "gateway-key", "Authorization": "Bearer other"},
+	})
+	if err != nil {
+		t.Fatalf("NewLLMProvider() error = %v", err)
+	}
+	if answer, err := provider.GenerateCompletion(context.Background(), "name?"); err != nil || answer != "Higress" {
+		t.Fatalf("GenerateCompletion() = %q, %v", answer, err)
+	}
+	if got.Get("X-Api-Key") != "gateway-key" || got.Get("Authorization") != "Bearer k" {
+		t.Errorf("X-Api-Key = %q, Authorization = %q, want the configured header next to the api key", got.Get("X-Api-Key"), got.Get("Authorization"))
+	}
+}
@@ -1,7 +1,9 @@
 package llm
 
 import (
+	"fmt"
 	"strings"
+	"text/template"
 )
 
 const RAGPromptTemplate = `You are a professional knowledge Q&A assistant. Your task is to provide direct and concise answers based on the user's question and retrieved context.
@@ -19,8 +21,82 @@ Requirements:
 4. Do not include any phrases like "The answer is", "Based on the context", etc. Just output the answer directly.
 `
 
+// DefaultPromptTemplate is the Go template used when LLMConfig.PromptTemplate is empty.
+// It keeps the wording of RAGPromptTemplate and prefixes each context with a [n]
+// marker so the answer can cite its sources.
+const DefaultPromptTemplate = `You are a professional knowledge Q&A assistant. Your task is to provide direct and concise answers based on the user's question and retrieved context.
+
+Retrieved relevant context (may be empty, each segment starts with its source marker such as [1]):
+{{range $i, $ctx := .Contexts}}{{if $i}}
+
+{{end}}[{{$ctx.Index}}]{{if $ctx.Title}} {{$ctx.Title}}:{{end}} {{$ctx.Content}}{{end}}
+
+User question:
+{{.Query}}
+
+Requirements:
+1. Provide ONLY the direct answer without any explanation, reasoning, or additional context.
+2. If the context provides sufficient information, output the answer in the most concise form possible.
+3. If the context is insufficient or unrelated to the question, respond with: "I am unable to answer this question."
+4. Do not include any phrases like "The answer is", "Based on the context", etc. Just output the answer directly.
+5. Cite the segments you used with their markers, e.g. [1] or [1][3].
+`
+
 func BuildPrompt(query string, contexts []string, join string) string {
 	rendered := strings.ReplaceAll(RAGPromptTemplate, "{query}", query)
 	rendered = strings.ReplaceAll(rendered, "{contexts}", strings.Join(contexts, join))
 	return rendered
 }
+
+// PromptContext is a retrieved segment passed to a prompt template.
+// Index is 1-based and matches the [n] citation marker.
+type PromptContext struct {
+	Index   int
+	Title   string
+	Content string
+}
+
+// PromptData is the data a prompt template is executed with.
+type PromptData struct {
+	Query    string
+	Contexts []PromptContext
+}
+
+// PromptTemplate renders RAG prompts from a Go text/template.
+type PromptTemplate struct {
+	tmpl *template.Template
+}
+
+// NewPromptTemplate parses text as a Go template. An empty text selects DefaultPromptTemplate.
+func NewPromptTemplate(text string) (*PromptTemplate, error) {
+	if strings.TrimSpace(text) == "" {
+		text = DefaultPromptTemplate
+	}
+	tmpl, err := template.New("rag_prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse prompt template failed, err: %w", err)
+	}
+	return &PromptTemplate{tmpl: tmpl}, nil
+}
+
+// NewPromptContexts numbers contents and titles starting from 1. titles may be
+// shorter than contents; missing titles are left empty.
+func NewPromptContexts(contents, titles []string) []PromptContext {
+	contexts := make([]PromptContext, len(contents))
+	for i, content := range contents {
+		contexts[i] = PromptContext{Index: i + 1, Content: content}
+		if i < len(titles) {
+			contexts[i].Title = titles[i]
+		}
+	}
+	return contexts
+}
+
+// Render executes the template for query and contexts.
+func (p *PromptTemplate) Render(query string, contexts []PromptContext) (string, error) {
+	var sb strings.Builder
+	if err := p.tmpl.Execute(&sb, PromptData{Query: query, Contexts: contexts}); err != nil {
+		return "", fmt.Errorf("render prompt template failed, err: %w", err)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+// PROMPT_FILE_EXT is the extension of the template files read from PromptsConfig.Dir
+const PROMPT_FILE_EXT = ".tmpl"
+
+// PromptSpec describes the prompt of an LLM stage that can be overridden by name.
+type PromptSpec struct {
+	// Default is the built-in template
+	Default string
+	// Fields are the placeholders the stage provides, e.g. "Query" for {{.Query}}
+	Fields []string
+	// Required are the fields an override must use
+	Required []string
+}
+
+type registeredPrompt struct {
+	spec PromptSpec
+	tmpl *template.Template
+}
+
+var (
+	promptsMu sync.RWMutex
+	prompts   = map[string]registeredPrompt{}
+)
+
+// promptFuncs are the functions available to prompt templates
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// RegisterPrompt makes the prompt of a stage overridable through pipeline.prompts. Stages
+// register their built-in prompt from init; it panics when the default does not parse or
+// does not satisfy spec.
+func RegisterPrompt(name string, spec PromptSpec) {
+	tmpl, err := parsePrompt(name, spec.Default, spec)
+	if err != nil {
+		panic(err)
+	}
+	promptsMu.Lock()
+	prompts[name] = registeredPrompt{spec: spec, tmpl: tmpl}
+	promptsMu.Unlock()
+}
+
+// RegisteredPrompts returns the names of the registered prompts in sorted order.
+func RegisteredPrompts() []string {
+	promptsMu.RLock()
+	defer promptsMu.RUnlock()
+	names := make([]string, 0, len(prompts))
+	for name := range prompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupPrompt(name string) (registeredPrompt, bool) {
+	promptsMu.RLock()
+	p, ok := prompts[name]
+	promptsMu.RUnlock()
+	return p, ok
+}
+
+// PromptRegistry renders the prompts of the LLM stages, using the configured overrides and
+// the registered built-in prompts otherwise. A nil registry renders the built-in prompts.
+type PromptRegistry struct {
+	overrides map[string]*template.Template
+}
+
+// NewPromptRegistry loads the overrides of cfg. Templates are checked when loaded: the name
+// must be registered, the template must parse, use every required placeholder and no
+// placeholder the stage does not provide. A nil cfg returns a nil registry.
+func NewPromptRegistry(cfg *config.PromptsConfig) (*PromptRegistry, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	texts := make(map[string]string)
+	if cfg.Dir != "" {
+		files, err := filepath.Glob(filepath.Join(cfg.Dir, "*"+PROMPT_FILE_EXT))
+		if err != nil {
+			return nil, fmt.Errorf("list prompt templates: %w", err)
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("read prompt template: %w", err)
+			}
+			texts[strings.TrimSuffix(filepath.Base(file), PROMPT_FILE_EXT)] = string(data)
+		}
+	}
+	for name, text := range cfg.Templates {
+		texts[name] = text
+	}
+	registry := &PromptRegistry{overrides: make(map[string]*template.Template, len(texts))}
+	for name, text := range texts {
+		p, ok := lookupPrompt(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown prompt template %q, available: %s", name, strings.Join(RegisteredPrompts(), ", "))
+		}
+		tmpl, err := parsePrompt(name, text, p.spec)
+		if err != nil {
+			return nil, err
+		}
+		registry.overrides[name] = tmpl
+	}
+	return registry, nil
+}
+
+// Render executes the template registered as name with data, whose keys are the fields of
+// the stage.
+func (r *PromptRegistry) Render(name string, data map[string]any) (string, error) {
+	var tmpl *template.Template
+	if r != nil {
+		tmpl = r.overrides[name]
+	}
+	if tmpl == nil {
+		p, registered := lookupPrompt(name)
+		if !registered {
+			return "", fmt.Errorf("unknown prompt template %q", name)
+		}
+		tmpl = p.tmpl
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("render prompt template %q failed, err: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// Messages renders a system and a user template with the same data as chat messages.
+func (r *PromptRegistry) Messages(system, user string, data map[string]any) ([]ChatMessage, error) {
+	systemPrompt, err := r.Render(system, data)
+	if err != nil {
+		return nil, err
+	}
+	userPrompt, err := r.Render(user, data)
+	if err != nil {
+		return nil, err
+	}
+	return SystemUserMessages(systemPrompt, userPrompt), nil
+}
+
+// parsePrompt parses text and checks its placeholders against spec
+func parsePrompt(name, text string, spec PromptSpec) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse prompt template %q failed, err: %w", name, err)
+	}
+	used := make(map[string]bool)
+	if tmpl.Tree != nil {
+		collectPromptFields(tmpl.Tree.Root, used, true)
+	}
+	allowed := make(map[string]bool, len(spec.Fields))
+	for _, field := range spec.Fields {
+		allowed[field] = true
+	}
+	for field := range used {
+		if !allowed[field] {
+			return nil, fmt.Errorf("prompt template %q uses unknown placeholder {{.%s}}, available: %s", name, field, strings.Join(spec.Fields, ", "))
+		}
+	}
+	for _, field := range spec.Required {
+		if !used[field] {
+			return nil, fmt.Errorf("prompt template %q must use placeholder {{.%s}}", name, field)
+		}
+	}
+	return tmpl, nil
+}
+
+// collectPromptFields records the top-level fields referenced by node, i.e. {{.Query}} or
+// {{$.Query}}. Fields inside range and with blocks refer to the current element instead,
+// so only $-rooted fields are collected there.
+func collectPromptFields(node parse.Node, used map[string]bool, topLevel bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectPromptFields(child, used, topLevel)
+		}
+	case *parse.ActionNode:
+		collectPromptFields(n.Pipe, used, topLevel)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectPromptFields(cmd, used, topLevel)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectPromptFields(arg, used, topLevel)
+		}
+	case *parse.FieldNode:
+		if topLevel && len(n.Ident) > 0 {
+			used[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			used[n.Ident[1]] = true
+		}
+	case *parse.ChainNode:
+		collectPromptFields(n.Node, used, topLevel)
+	case *parse.IfNode:
+		collectPromptFields(n.Pipe, used, topLevel)
+		collectPromptFields(n.List, used, topLevel)
+		collectPromptFields(n.ElseList, used, topLevel)
+	case *parse.RangeNode:
+		collectPromptFields(n.Pipe, used, topLevel)
+		collectPromptFields(n.List, used, false)
+		collectPromptFields(n.ElseList, used, topLevel)
+	case *parse.WithNode:
+		collectPromptFields(n.Pipe, used, topLevel)
+		collectPromptFields(n.List, used, false)
+		collectPromptFields(n.ElseList, used, topLevel)
+	case *parse.TemplateNode:
+		collectPromptFields(n.Pipe, used, topLevel)
+	}
+}
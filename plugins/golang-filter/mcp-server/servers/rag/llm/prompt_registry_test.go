@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+func init() {
+	RegisterPrompt("test_rewrite", PromptSpec{
+		Default:  "Rewrite: {{.Query}}",
+		Fields:   []string{"Query", "History", "Terms"},
+		Required: []string{"Query"},
+	})
+	RegisterPrompt("test_rewrite_system", PromptSpec{Default: "You rewrite queries.", Fields: []string{"Query"}})
+}
+
+func TestPromptRegistry_Defaults(t *testing.T) {
+	var registry *PromptRegistry
+	prompt, err := registry.Render("test_rewrite", map[string]any{"Query": "q"})
+	if err != nil || prompt != "Rewrite: q" {
+		t.Errorf("Render() = %q, %v", prompt, err)
+	}
+	if registry, err = NewPromptRegistry(nil); err != nil || registry != nil {
+		t.Errorf("NewPromptRegistry(nil) = %v, %v", registry, err)
+	}
+	if _, err := registry.Render("missing", nil); err == nil {
+		t.Error("Render() of an unregistered template should fail")
+	}
+}
+
+func TestPromptRegistry_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test_rewrite.tmpl"), []byte("file {{.Query}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test_rewrite_system.tmpl"), []byte("Rewrite {{$.Query}} in German."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := NewPromptRegistry(&config.PromptsConfig{
+		Dir: dir,
+		Templates: map[string]string{
+			"test_rewrite": `{{.History}}查询：{{.Query}}{{if .Terms}}（保留 {{join .Terms "、"}}）{{end}}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPromptRegistry() error = %v", err)
+	}
+	messages, err := registry.Messages("test_rewrite_system", "test_rewrite", map[string]any{
+		"Query": "网关", "History": "Q1: 插件\n", "Terms": []string{"Higress", "Envoy"},
+	})
+	if err != nil {
+		t.Fatalf("Messages() error = %v", err)
+	}
+	if messages[0].Content != "Rewrite 网关 in German." {
+		t.Errorf("system = %q, want the template of the directory", messages[0].Content)
+	}
+	if want := "Q1: 插件\n查询：网关（保留 Higress、Envoy）"; messages[1].Content != want {
+		t.Errorf("user = %q, want the inline template %q", messages[1].Content, want)
+	}
+}
+
+func TestPromptRegistry_Validation(t *testing.T) {
+	tests := map[string]struct {
+		templates map[string]string
+		want      string
+	}{
+		"unknown name":         {map[string]string{"test_rewirte": "{{.Query}}"}, "unknown prompt template"},
+		"parse error":          {map[string]string{"test_rewrite": "{{.Query"}, "parse prompt template"},
+		"missing required":     {map[string]string{"test_rewrite": "{{.History}}"}, "must use placeholder {{.Query}}"},
+		"unknown placeholder":  {map[string]string{"test_rewrite": "{{.Query}} {{.Qeury}}"}, "unknown placeholder {{.Qeury}}"},
+		"unknown in condition": {map[string]string{"test_rewrite_system": "{{if .Documents}}x{{end}}"}, "unknown placeholder {{.Documents}}"},
+	}
+	for name, tt := range tests {
+		_, err := NewPromptRegistry(&config.PromptsConfig{Templates: tt.templates})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: NewPromptRegistry() error = %v, want %q", name, err, tt.want)
+		}
+	}
+
+	// fields inside range refer to the elements, not to the stage placeholders
+	if _, err := NewPromptRegistry(&config.PromptsConfig{Templates: map[string]string{
+		"test_rewrite": "{{.Query}}{{range .Terms}} {{.Name}}{{end}}",
+	}}); err != nil {
+		t.Errorf("NewPromptRegistry() error = %v", err)
+	}
+}
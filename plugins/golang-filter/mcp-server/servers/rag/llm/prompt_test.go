@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptTemplate_Default(t *testing.T) {
+	tmpl, err := NewPromptTemplate("")
+	if err != nil {
+		t.Fatalf("NewPromptTemplate() error = %v", err)
+	}
+	prompt, err := tmpl.Render("what is higress?", NewPromptContexts(
+		[]string{"Higress is an API gateway", "Higress is based on Envoy"},
+		[]string{"intro"},
+	))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	for _, want := range []string{
+		"[1] intro: Higress is an API gateway\n\n[2] Higress is based on Envoy",
+		"User question:\nwhat is higress?",
+		"[1] or [1][3]",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestPromptTemplate_Custom(t *testing.T) {
+	tmpl, err := NewPromptTemplate(`Q={{.Query}}{{range .Contexts}}|{{.Index}}:{{.Title}}:{{.Content}}{{end}}`)
+	if err != nil {
+		t.Fatalf("NewPromptTemplate() error = %v", err)
+	}
+	prompt, err := tmpl.Render("q", NewPromptContexts([]string{"a", "b", "c"}, []string{"t1", "", "t3"}))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if want := "Q=q|1:t1:a|2::b|3:t3:c"; prompt != want {
+		t.Errorf("Render() = %q, want %q", prompt, want)
+	}
+}
+
+func TestPromptTemplate_Invalid(t *testing.T) {
+	if _, err := NewPromptTemplate("{{.Query"); err == nil {
+		t.Error("NewPromptTemplate() expected parse error")
+	}
+	tmpl, err := NewPromptTemplate("{{.Missing}}")
+	if err != nil {
+		t.Fatalf("NewPromptTemplate() error = %v", err)
+	}
+	if _, err := tmpl.Render("q", nil); err == nil {
+		t.Error("Render() expected error for unknown field")
+	}
+}
@@ -3,6 +3,8 @@ package llm
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
 )
@@ -10,9 +12,24 @@ import (
 const (
 	// OpenAI LLM provider
 	PROVIDER_TYPE_OPENAI = "openai"
+	// Ollama local LLM provider
+	PROVIDER_TYPE_OLLAMA = "ollama"
 	// More providers can be added (e.g., Qwen)
 )
 
+const (
+	// Chat message roles
+	ROLE_SYSTEM    = "system"
+	ROLE_USER      = "user"
+	ROLE_ASSISTANT = "assistant"
+)
+
+// ChatMessage is a single role-tagged message of a chat conversation
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
 // Provider defines interface for LLM providers with prompt-response pattern.
 // Extensible for future chat-style and streaming features.
 type Provider interface {
@@ -25,6 +42,36 @@ type Provider interface {
 	// prompt: Input text
 	// Returns: Generated response and error if any
 	GenerateCompletion(ctx context.Context, prompt string) (string, error)
+
+	// Generates text response for a conversation with system/user/assistant roles
+	//
+	// ctx: For cancellation and timeout
+	// messages: Ordered chat messages
+	// Returns: Generated response and error if any
+	//
+	// Providers without native chat support can implement it as
+	// GenerateCompletion(ctx, ConcatMessages(messages)).
+	GenerateChat(ctx context.Context, messages []ChatMessage) (string, error)
+}
+
+// SystemUserMessages builds the common system + user message pair
+func SystemUserMessages(system, user string) []ChatMessage {
+	return []ChatMessage{
+		{Role: ROLE_SYSTEM, Content: system},
+		{Role: ROLE_USER, Content: user},
+	}
+}
+
+// ConcatMessages joins message contents into a single prompt, which is the
+// default GenerateChat behavior for completion-only providers
+func ConcatMessages(messages []ChatMessage) string {
+	parts := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Content != "" {
+			parts = append(parts, msg.Content)
+		}
+	}
+	return strings.Join(parts, "\n\n")
 }
 
 // Factory interface for creating Provider instances
@@ -37,10 +84,12 @@ type providerInitializer interface {
 var (
 	providerInitializers = map[string]providerInitializer{
 		PROVIDER_TYPE_OPENAI: &openAIProviderInitializer{},
+		PROVIDER_TYPE_OLLAMA: &ollamaProviderInitializer{},
 	}
 )
 
-// Creates Provider instance based on config
+// Creates Provider instance based on config. With max_retries or timeout_ms, the provider
+// is wrapped in a RetryProvider.
 //
 // cfg: Provider config
 // Returns: Provider instance and error if any
@@ -49,5 +98,12 @@ func NewLLMProvider(cfg config.LLMConfig) (Provider, error) {
 	if !ok {
 		return nil, fmt.Errorf("no initializer found for llm provider type: %s", cfg.Provider)
 	}
-	return initializer.CreateProvider(cfg)
+	provider, err := initializer.CreateProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxRetries > 0 || cfg.TimeoutMs > 0 {
+		return NewRetryProvider(provider, cfg.MaxRetries, time.Duration(cfg.TimeoutMs)*time.Millisecond), nil
+	}
+	return provider, nil
 }
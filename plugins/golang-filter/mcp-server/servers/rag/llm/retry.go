@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/logger"
+	"github.com/openai/openai-go/v2"
+)
+
+const (
+	// RETRY_BACKOFF_MIN is the delay before the first retry, doubled on every further retry
+	RETRY_BACKOFF_MIN = 200 * time.Millisecond
+	// RETRY_BACKOFF_MAX caps the delay between two attempts
+	RETRY_BACKOFF_MAX = 5 * time.Second
+)
+
+// StatusError is returned by providers for a non-2xx HTTP response
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s llm error: status %d: %s", e.Provider, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s llm error: status %d", e.Provider, e.StatusCode)
+}
+
+// IsRetryable reports whether err is transient: a 429 or 5xx response, or a timeout.
+// Other 4xx responses, such as a bad request or an invalid key, fail the same way again.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.StatusCode)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// RetryProvider wraps a Provider and retries calls failing with a retryable error, up to
+// maxRetries times with exponential backoff and jitter. Every attempt is bounded by
+// timeout; a retry is only made when the caller's deadline leaves room for its delay.
+type RetryProvider struct {
+	Provider
+	maxRetries int
+	timeout    time.Duration
+	backoffMin time.Duration
+	backoffMax time.Duration
+}
+
+// NewRetryProvider wraps p; a non-positive timeout leaves attempts bounded by the caller's
+// context only
+func NewRetryProvider(p Provider, maxRetries int, timeout time.Duration) *RetryProvider {
+	return &RetryProvider{
+		Provider:   p,
+		maxRetries: max(maxRetries, 0),
+		timeout:    timeout,
+		backoffMin: RETRY_BACKOFF_MIN,
+		backoffMax: RETRY_BACKOFF_MAX,
+	}
+}
+
+// GenerateCompletion implements Provider interface.
+func (p *RetryProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return p.do(ctx, true, func(ctx context.Context) (string, error) {
+		return p.Provider.GenerateCompletion(ctx, prompt)
+	})
+}
+
+// GenerateChat implements Provider interface.
+func (p *RetryProvider) GenerateChat(ctx context.Context, messages []ChatMessage) (string, error) {
+	return p.do(ctx, true, func(ctx context.Context) (string, error) {
+		return p.Provider.GenerateChat(ctx, messages)
+	})
+}
+
+// StreamChat implements StreamProvider interface. A stream is retried only while none of
+// it has reached onChunk, and is not bounded by the per-call timeout since the length of
+// the answer is open-ended.
+func (p *RetryProvider) StreamChat(ctx context.Context, messages []ChatMessage, onChunk func(string)) (string, error) {
+	streamed := false
+	return p.do(ctx, false, func(ctx context.Context) (string, error) {
+		resp, err := StreamChat(ctx, p.Provider, messages, func(chunk string) {
+			streamed = true
+			if onChunk != nil {
+				onChunk(chunk)
+			}
+		})
+		if err != nil && streamed {
+			return resp, &permanentError{err}
+		}
+		return resp, err
+	})
+}
+
+// permanentError stops the retries of an attempt that cannot be repeated
+type permanentError struct{ error }
+
+func (e *permanentError) Unwrap() error { return e.error }
+
+func (p *RetryProvider) do(ctx context.Context, bounded bool, call func(ctx context.Context) (string, error)) (string, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := p.attempt(ctx, bounded, call)
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return resp, permanent.error
+		}
+		if err == nil || attempt >= p.maxRetries || ctx.Err() != nil || !IsRetryable(err) {
+			return resp, err
+		}
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			return resp, err
+		}
+		logger.Warnf("llm: %s call failed (try %d/%d), retrying in %v: %v", p.GetProviderType(), attempt+1, p.maxRetries+1, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (p *RetryProvider) attempt(ctx context.Context, bounded bool, call func(ctx context.Context) (string, error)) (string, error) {
+	if !bounded || p.timeout <= 0 {
+		return call(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	return call(ctx)
+}
+
+// backoff returns the delay before retry attempt+1: backoffMin doubled attempt times,
+// capped at backoffMax, with full jitter over its upper half
+func (p *RetryProvider) backoff(attempt int) time.Duration {
+	d := p.backoffMax
+	if attempt < 30 {
+		d = min(p.backoffMin<<attempt, p.backoffMax)
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+// newStatusServer serves OpenAI chat completions, answering the n-th request with
+// statuses[n] until they run out and with 200 afterwards
+func newStatusServer(t *testing.T, requests *atomic.Int32, statuses ...int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1)) - 1
+		if n < len(statuses) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(statuses[n])
+			_, _ = w.Write([]byte(`{"error":{"message":"try later","type":"rate_limit"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "1",
+			"object":  "chat.completion",
+			"model":   "gpt-4o",
+			"choices": []map[string]any{{"index": 0, "message": map[string]any{"role": "assistant", "content": "Higress"}, "finish_reason": "stop"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newRetryTestProvider(t *testing.T, baseURL string, maxRetries, timeoutMs int) *RetryProvider {
+	t.Helper()
+	provider, err := NewLLMProvider(config.LLMConfig{
+		Provider:   PROVIDER_TYPE_OPENAI,
+		APIKey:     "k",
+		BaseURL:    baseURL,
+		MaxRetries: maxRetries,
+		TimeoutMs:  timeoutMs,
+	})
+	if err != nil {
+		t.Fatalf("NewLLMProvider() error = %v", err)
+	}
+	retry, ok := provider.(*RetryProvider)
+	if !ok {
+		t.Fatalf("NewLLMProvider() = %T, want a RetryProvider", provider)
+	}
+	retry.backoffMin, retry.backoffMax = time.Millisecond, 10*time.Millisecond
+	return retry
+}
+
+func TestRetryProvider_RetriesRateLimit(t *testing.T) {
+	var requests atomic.Int32
+	server := newStatusServer(t, &requests, http.StatusTooManyRequests, http.StatusServiceUnavailable)
+	provider := newRetryTestProvider(t, server.URL, 2, 0)
+
+	answer, err := provider.GenerateCompletion(context.Background(), "name?")
+	if err != nil || answer != "Higress" {
+		t.Fatalf("GenerateCompletion() = %q, %v", answer, err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("server got %d requests, want 3", n)
+	}
+}
+
+func TestRetryProvider_NonRetryableAndExhausted(t *testing.T) {
+	var requests atomic.Int32
+	server := newStatusServer(t, &requests, http.StatusBadRequest)
+	if _, err := newRetryTestProvider(t, server.URL, 3, 0).GenerateCompletion(context.Background(), "q"); err == nil || requests.Load() != 1 {
+		t.Errorf("GenerateCompletion() error = %v after %d requests, want a failure without retry", err, requests.Load())
+	}
+
+	requests.Store(0)
+	server = newStatusServer(t, &requests, 502, 502, 502, 502)
+	if _, err := newRetryTestProvider(t, server.URL, 2, 0).GenerateCompletion(context.Background(), "q"); !IsRetryable(err) || requests.Load() != 3 {
+		t.Errorf("GenerateCompletion() error = %v after %d requests, want the last 502 after 3 requests", err, requests.Load())
+	}
+}
+
+func TestRetryProvider_Timeouts(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(300 * time.Millisecond):
+			}
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// The first attempt times out and is retried; the caller's deadline stops the 503 retries
+	provider := newRetryTestProvider(t, server.URL, 100, 50)
+	provider.backoffMin, provider.backoffMax = 40*time.Millisecond, 40*time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := provider.GenerateCompletion(ctx, "q")
+	if err == nil {
+		t.Fatal("GenerateCompletion() error = nil, want the last failure")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("GenerateCompletion() took %v, want it to stop at the caller's deadline", elapsed)
+	}
+	if n := requests.Load(); n < 2 {
+		t.Errorf("server got %d requests, want the timed out attempt retried within the deadline", n)
+	}
+}
+
+// failingStreamProvider streams chunks and then fails with err
+type failingStreamProvider struct {
+	chunks []string
+	err    error
+	calls  int
+}
+
+func (p *failingStreamProvider) GetProviderType() string { return "stream" }
+
+func (p *failingStreamProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return "", p.err
+}
+
+func (p *failingStreamProvider) GenerateChat(ctx context.Context, messages []ChatMessage) (string, error) {
+	return "", p.err
+}
+
+func (p *failingStreamProvider) StreamChat(ctx context.Context, messages []ChatMessage, onChunk func(string)) (string, error) {
+	p.calls++
+	for _, chunk := range p.chunks {
+		onChunk(chunk)
+	}
+	return "", p.err
+}
+
+func TestRetryProvider_StreamNotRetriedAfterChunks(t *testing.T) {
+	unavailable := &StatusError{Provider: PROVIDER_TYPE_OLLAMA, StatusCode: http.StatusServiceUnavailable}
+	stream := &failingStreamProvider{chunks: []string{"Hig"}, err: unavailable}
+	provider := NewRetryProvider(stream, 3, 0)
+	provider.backoffMin = time.Millisecond
+
+	if _, err := StreamChat(context.Background(), provider, nil, nil); !errors.Is(err, unavailable) || stream.calls != 1 {
+		t.Errorf("StreamChat() error = %v after %d calls, want no retry once a chunk was streamed", err, stream.calls)
+	}
+	stream.chunks, stream.calls = nil, 0
+	if _, err := StreamChat(context.Background(), provider, nil, nil); !errors.Is(err, unavailable) || stream.calls != 4 {
+		t.Errorf("StreamChat() error = %v after %d calls, want 3 retries before any chunk", err, stream.calls)
+	}
+}
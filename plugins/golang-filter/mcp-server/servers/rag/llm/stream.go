@@ -0,0 +1,32 @@
+package llm
+
+import "context"
+
+// StreamProvider is implemented by providers that can stream a response as it is generated
+type StreamProvider interface {
+	Provider
+	// Generates a response for the messages, calling onChunk for every partial message as
+	// it arrives. Returns the full response.
+	StreamChat(ctx context.Context, messages []ChatMessage, onChunk func(string)) (string, error)
+}
+
+// StreamChat streams the response of p for messages to onChunk. Providers without
+// streaming support deliver the full response as a single chunk.
+func StreamChat(ctx context.Context, p Provider, messages []ChatMessage, onChunk func(string)) (string, error) {
+	if sp, ok := p.(StreamProvider); ok {
+		return sp.StreamChat(ctx, messages, onChunk)
+	}
+	resp, err := p.GenerateChat(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	if resp != "" && onChunk != nil {
+		onChunk(resp)
+	}
+	return resp, nil
+}
+
+// GenerateCompletionStream streams the completion of prompt like StreamChat
+func GenerateCompletionStream(ctx context.Context, p Provider, prompt string, onChunk func(string)) (string, error) {
+	return StreamChat(ctx, p, []ChatMessage{{Role: ROLE_USER, Content: prompt}}, onChunk)
+}
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+func TestOpenAIProvider_StreamChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`,
+			`{"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"Hig"}}]}`,
+			`{"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"ress"},"finish_reason":"stop"}]}`,
+			`{"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewLLMProvider(config.LLMConfig{Provider: PROVIDER_TYPE_OPENAI, APIKey: "k", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewLLMProvider() error = %v", err)
+	}
+	tracker := &UsageTracker{}
+	var chunks []string
+	answer, err := GenerateCompletionStream(WithUsageTracker(context.Background(), tracker), provider, "name?", func(s string) {
+		chunks = append(chunks, s)
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream() error = %v", err)
+	}
+	if answer != "Higress" || strings.Join(chunks, "|") != "Hig|ress" {
+		t.Errorf("GenerateCompletionStream() = %q chunks %v", answer, chunks)
+	}
+	if got := tracker.Usage(); got != (Usage{Calls: 1, PromptTokens: 9, CompletionTokens: 2}) {
+		t.Errorf("usage = %+v, want the counts of the final chunk", got)
+	}
+}
+
+// completionOnlyProvider has no streaming support
+type completionOnlyProvider struct {
+	answer string
+	err    error
+}
+
+func (p *completionOnlyProvider) GetProviderType() string { return "completion-only" }
+
+func (p *completionOnlyProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return p.answer, p.err
+}
+
+func (p *completionOnlyProvider) GenerateChat(ctx context.Context, messages []ChatMessage) (string, error) {
+	return p.GenerateCompletion(ctx, ConcatMessages(messages))
+}
+
+func TestStreamChat_FallsBackToSingleChunk(t *testing.T) {
+	var chunks []string
+	answer, err := GenerateCompletionStream(context.Background(), &completionOnlyProvider{answer: "full answer"}, "q", func(s string) {
+		chunks = append(chunks, s)
+	})
+	if err != nil || answer != "full answer" || len(chunks) != 1 || chunks[0] != "full answer" {
+		t.Errorf("GenerateCompletionStream() = %q, %v with chunks %v, want the full answer as one chunk", answer, err, chunks)
+	}
+
+	chunks = nil
+	if _, err := GenerateCompletionStream(context.Background(), &completionOnlyProvider{err: errors.New("down")}, "q", func(s string) {
+		chunks = append(chunks, s)
+	}); err == nil || len(chunks) != 0 {
+		t.Errorf("GenerateCompletionStream() = %v with chunks %v, want the error and no chunks", err, chunks)
+	}
+}
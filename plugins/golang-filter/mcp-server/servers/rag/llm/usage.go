@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"unicode"
+)
+
+// Usage is the token spend of one or more LLM calls
+type Usage struct {
+	Calls            int `json:"llm_calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Add returns the sum of two usages
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		Calls:            u.Calls + other.Calls,
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+	}
+}
+
+// UsageTracker accumulates the usage of every LLM call made with a context carrying it.
+// It is safe for concurrent use, since pipeline stages call the LLM in parallel.
+type UsageTracker struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+// Add records the usage of a call
+func (t *UsageTracker) Add(usage Usage) {
+	t.mu.Lock()
+	t.usage = t.usage.Add(usage)
+	t.mu.Unlock()
+}
+
+// Usage returns the usage recorded so far
+func (t *UsageTracker) Usage() Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+type usageTrackerKey struct{}
+
+// WithUsageTracker returns a context whose LLM calls are recorded into tracker
+func WithUsageTracker(ctx context.Context, tracker *UsageTracker) context.Context {
+	return context.WithValue(ctx, usageTrackerKey{}, tracker)
+}
+
+// UsageTrackerFromContext returns the tracker attached to ctx, or nil
+func UsageTrackerFromContext(ctx context.Context) *UsageTracker {
+	tracker, _ := ctx.Value(usageTrackerKey{}).(*UsageTracker)
+	return tracker
+}
+
+// RecordUsage adds one call to the tracker of ctx; providers call it after every successful
+// generation. Token counts the API did not report (zero) are estimated from the messages
+// and the response.
+func RecordUsage(ctx context.Context, messages []ChatMessage, response string, promptTokens, completionTokens int) {
+	tracker := UsageTrackerFromContext(ctx)
+	if tracker == nil {
+		return
+	}
+	if promptTokens <= 0 {
+		for _, msg := range messages {
+			promptTokens += EstimateTokens(msg.Content) + messageTokenOverhead
+		}
+	}
+	if completionTokens <= 0 {
+		completionTokens = EstimateTokens(response)
+	}
+	tracker.Add(Usage{Calls: 1, PromptTokens: promptTokens, CompletionTokens: completionTokens})
+}
+
+// messageTokenOverhead approximates the per-message tokens spent on role and framing
+const messageTokenOverhead = 4
+
+// EstimateTokens approximates the token count of text without a tokenizer: CJK
+// characters count one token each, other characters four per token.
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
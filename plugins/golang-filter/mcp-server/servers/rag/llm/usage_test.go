@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	cases := map[string]int{
+		"":              0,
+		"abcd":          1,
+		"abcde":         2,
+		"网关":            2,
+		"Higress 是一个网关": 7,
+	}
+	for text, want := range cases {
+		if got := EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestRecordUsage(t *testing.T) {
+	// Without a tracker recording is a no-op
+	RecordUsage(context.Background(), nil, "ignored", 1, 1)
+
+	tracker := &UsageTracker{}
+	ctx := WithUsageTracker(context.Background(), tracker)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			RecordUsage(ctx, nil, "", 100, 20)
+		}()
+	}
+	wg.Wait()
+	// Unreported counts are estimated: 4 overhead + 1 for "abcd", and 2 for "abcdefgh"
+	RecordUsage(ctx, []ChatMessage{{Role: ROLE_USER, Content: "abcd"}}, "abcdefgh", 0, 0)
+
+	want := Usage{Calls: 11, PromptTokens: 1005, CompletionTokens: 202}
+	if got := tracker.Usage(); got != want {
+		t.Errorf("Usage() = %+v, want %+v", got, want)
+	}
+}
+
+func TestOllamaProvider_RecordsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"ok"},"done":true,"prompt_eval_count":42,"eval_count":7}`))
+	}))
+	defer server.Close()
+
+	tracker := &UsageTracker{}
+	ctx := WithUsageTracker(context.Background(), tracker)
+	provider := newOllamaTestProvider(t, server.URL)
+	for i := 0; i < 3; i++ {
+		if _, err := provider.GenerateCompletion(ctx, "hi"); err != nil {
+			t.Fatalf("GenerateCompletion() error = %v", err)
+		}
+	}
+	want := Usage{Calls: 3, PromptTokens: 126, CompletionTokens: 21}
+	if got := tracker.Usage(); got != want {
+		t.Errorf("Usage() = %+v, want %+v", got, want)
+	}
+}
@@ -36,22 +36,38 @@ type ConversationStore interface {
 
 // InMemoryConversationStore 内存对话存储实现
 // 适用于开发测试或单机部署
+// 设置 TTL 后，超过 TTL 未被读写的会话视为过期：读取时直接忽略，写入时顺带清扫
 type InMemoryConversationStore struct {
 	mu        sync.RWMutex
 	sessions  map[string][]ConversationRound
 	docIDs    map[string][]string
+	touched   map[string]time.Time
 	maxRounds int
+	ttl       time.Duration
+	lastSweep time.Time
+	now       func() time.Time
 }
 
-// NewInMemoryConversationStore 创建内存对话存储
+// NewInMemoryConversationStore 创建内存对话存储（会话不过期）
 func NewInMemoryConversationStore(maxRounds int) ConversationStore {
+	return NewInMemoryConversationStoreWithTTL(maxRounds, 0)
+}
+
+// NewInMemoryConversationStoreWithTTL 创建带会话 TTL 的内存对话存储，ttl <= 0 表示不过期
+func NewInMemoryConversationStoreWithTTL(maxRounds int, ttl time.Duration) *InMemoryConversationStore {
 	if maxRounds <= 0 {
 		maxRounds = 10
 	}
+	if ttl < 0 {
+		ttl = 0
+	}
 	return &InMemoryConversationStore{
 		sessions:  make(map[string][]ConversationRound),
 		docIDs:    make(map[string][]string),
+		touched:   make(map[string]time.Time),
 		maxRounds: maxRounds,
+		ttl:       ttl,
+		now:       time.Now,
 	}
 }
 
@@ -60,14 +76,87 @@ func NewInMemorySessionStore(maxRounds int) ConversationStore {
 	return NewInMemoryConversationStore(maxRounds)
 }
 
-func (s *InMemoryConversationStore) GetLastNRounds(ctx context.Context, sessionID string, n int) ([]ConversationRound, error) {
+// expired 判断会话是否已过期，调用方需持有锁
+func (s *InMemoryConversationStore) expired(sessionID string, now time.Time) bool {
+	if s.ttl <= 0 {
+		return false
+	}
+	last, ok := s.touched[sessionID]
+	return ok && now.Sub(last) > s.ttl
+}
+
+// touch 刷新会话访问时间，并按 TTL 间隔清扫过期会话，调用方需持有写锁
+func (s *InMemoryConversationStore) touch(sessionID string, now time.Time) {
+	if s.ttl <= 0 {
+		return
+	}
+	if now.Sub(s.lastSweep) >= s.ttl {
+		s.sweepLocked(now)
+	}
+	s.touched[sessionID] = now
+}
+
+// Sweep 立即移除所有过期会话，返回移除数量
+func (s *InMemoryConversationStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sweepLocked(s.now())
+}
+
+func (s *InMemoryConversationStore) sweepLocked(now time.Time) int {
+	s.lastSweep = now
+	if s.ttl <= 0 {
+		return 0
+	}
+	removed := 0
+	for id, last := range s.touched {
+		if now.Sub(last) > s.ttl {
+			delete(s.sessions, id)
+			delete(s.docIDs, id)
+			delete(s.touched, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len 返回当前保存的会话数量（含尚未清扫的过期会话）
+func (s *InMemoryConversationStore) Len() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	ids := make(map[string]struct{}, len(s.sessions)+len(s.docIDs))
+	for id := range s.sessions {
+		ids[id] = struct{}{}
+	}
+	for id := range s.docIDs {
+		ids[id] = struct{}{}
+	}
+	return len(ids)
+}
+
+// lockForRead 启用 TTL 时读取也会刷新访问时间，因此需要写锁
+func (s *InMemoryConversationStore) lockForRead() func() {
+	if s.ttl > 0 {
+		s.mu.Lock()
+		return s.mu.Unlock
+	}
+	s.mu.RLock()
+	return s.mu.RUnlock
+}
 
+func (s *InMemoryConversationStore) GetLastNRounds(ctx context.Context, sessionID string, n int) ([]ConversationRound, error) {
+	unlock := s.lockForRead()
+	defer unlock()
+
+	now := s.now()
+	if s.expired(sessionID, now) {
+		return []ConversationRound{}, nil
+	}
 	rounds := s.sessions[sessionID]
 	if len(rounds) == 0 {
 		return []ConversationRound{}, nil
 	}
+	s.touch(sessionID, now)
 
 	if n <= 0 || n >= len(rounds) {
 		// 返回所有轮次的副本
@@ -83,13 +172,18 @@ func (s *InMemoryConversationStore) GetLastNRounds(ctx context.Context, sessionI
 }
 
 func (s *InMemoryConversationStore) GetDocIDs(ctx context.Context, sessionID string) ([]string, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	unlock := s.lockForRead()
+	defer unlock()
 
+	now := s.now()
+	if s.expired(sessionID, now) {
+		return []string{}, nil
+	}
 	docIDs := s.docIDs[sessionID]
 	if len(docIDs) == 0 {
 		return []string{}, nil
 	}
+	s.touch(sessionID, now)
 
 	// 返回副本
 	result := make([]string, len(docIDs))
@@ -101,6 +195,13 @@ func (s *InMemoryConversationStore) SaveRound(ctx context.Context, sessionID str
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := s.now()
+	if s.expired(sessionID, now) {
+		delete(s.sessions, sessionID)
+		delete(s.docIDs, sessionID)
+	}
+	s.touch(sessionID, now)
+
 	rounds := s.sessions[sessionID]
 	rounds = append(rounds, round)
 
@@ -117,6 +218,12 @@ func (s *InMemoryConversationStore) SaveDocIDs(ctx context.Context, sessionID st
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := s.now()
+	if s.expired(sessionID, now) {
+		delete(s.sessions, sessionID)
+	}
+	s.touch(sessionID, now)
+
 	// 保存副本
 	newDocIDs := make([]string, len(docIDs))
 	copy(newDocIDs, docIDs)
@@ -130,6 +237,7 @@ func (s *InMemoryConversationStore) Clear(ctx context.Context, sessionID string)
 
 	delete(s.sessions, sessionID)
 	delete(s.docIDs, sessionID)
+	delete(s.touched, sessionID)
 	return nil
 }
 
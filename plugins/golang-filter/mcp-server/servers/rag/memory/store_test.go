@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTTLStore(ttl time.Duration) (*InMemoryConversationStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewInMemoryConversationStoreWithTTL(5, ttl)
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestInMemoryConversationStore_TTLExpiresIdleSessions(t *testing.T) {
+	ctx := context.Background()
+	store, clock := newTTLStore(time.Minute)
+
+	_ = store.SaveRound(ctx, "stale", ConversationRound{Question: "q1"})
+	_ = store.SaveDocIDs(ctx, "stale", []string{"doc-1"})
+	clock.Advance(40 * time.Second)
+	_ = store.SaveRound(ctx, "fresh", ConversationRound{Question: "q2"})
+	clock.Advance(30 * time.Second)
+
+	rounds, _ := store.GetLastNRounds(ctx, "stale", 0)
+	docIDs, _ := store.GetDocIDs(ctx, "stale")
+	if len(rounds) != 0 || len(docIDs) != 0 {
+		t.Errorf("stale session returned rounds=%v docIDs=%v, want expired", rounds, docIDs)
+	}
+	if rounds, _ := store.GetLastNRounds(ctx, "fresh", 0); len(rounds) != 1 {
+		t.Errorf("fresh session rounds = %v, want retained", rounds)
+	}
+
+	store.Sweep()
+	if n := store.Len(); n != 1 {
+		t.Errorf("Len() = %d, want only the fresh session", n)
+	}
+}
+
+func TestInMemoryConversationStore_SweepRemovesExpired(t *testing.T) {
+	ctx := context.Background()
+	store, clock := newTTLStore(time.Minute)
+
+	_ = store.SaveRound(ctx, "s1", ConversationRound{Question: "q1"})
+	_ = store.SaveDocIDs(ctx, "s2", []string{"doc-1"})
+	clock.Advance(2 * time.Minute)
+	if removed := store.Sweep(); removed != 2 {
+		t.Errorf("Sweep() removed %d, want 2", removed)
+	}
+	if n := store.Len(); n != 0 {
+		t.Errorf("Len() = %d, want 0", n)
+	}
+}
+
+func TestInMemoryConversationStore_AccessRefreshesTTL(t *testing.T) {
+	ctx := context.Background()
+	store, clock := newTTLStore(time.Minute)
+
+	_ = store.SaveRound(ctx, "s1", ConversationRound{Question: "q1"})
+	for i := 0; i < 3; i++ {
+		clock.Advance(45 * time.Second)
+		if rounds, _ := store.GetLastNRounds(ctx, "s1", 0); len(rounds) != 1 {
+			t.Fatalf("read %d: rounds = %v, want session kept alive by access", i, rounds)
+		}
+	}
+}
+
+func TestInMemoryConversationStore_WritesSweepExpired(t *testing.T) {
+	ctx := context.Background()
+	store, clock := newTTLStore(time.Minute)
+
+	for i := 0; i < 10; i++ {
+		_ = store.SaveRound(ctx, fmt.Sprintf("abandoned-%d", i), ConversationRound{Question: "q"})
+	}
+	clock.Advance(2 * time.Minute)
+	_ = store.SaveRound(ctx, "new", ConversationRound{Question: "q"})
+
+	if n := store.Len(); n != 1 {
+		t.Errorf("Len() = %d, want abandoned sessions swept on write", n)
+	}
+
+	_ = store.SaveRound(ctx, "new", ConversationRound{Question: "q2"})
+	clock.Advance(2 * time.Minute)
+	_ = store.SaveRound(ctx, "new", ConversationRound{Question: "q3"})
+	if rounds, _ := store.GetLastNRounds(ctx, "new", 0); len(rounds) != 1 || rounds[0].Question != "q3" {
+		t.Errorf("rounds = %v, want history reset after expiry", rounds)
+	}
+}
+
+func TestInMemoryConversationStore_NoTTL(t *testing.T) {
+	ctx := context.Background()
+	store, clock := newTTLStore(0)
+
+	_ = store.SaveRound(ctx, "s1", ConversationRound{Question: "q1"})
+	clock.Advance(365 * 24 * time.Hour)
+	if rounds, _ := store.GetLastNRounds(ctx, "s1", 0); len(rounds) != 1 {
+		t.Errorf("rounds = %v, want sessions kept without TTL", rounds)
+	}
+	if removed := store.Sweep(); removed != 0 {
+		t.Errorf("Sweep() removed %d, want 0", removed)
+	}
+}
+
+func TestInMemoryConversationStore_ConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	store, clock := newTTLStore(time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("s%d", i%3)
+			for j := 0; j < 100; j++ {
+				_ = store.SaveRound(ctx, id, ConversationRound{Question: "q"})
+				_, _ = store.GetLastNRounds(ctx, id, 2)
+				_, _ = store.GetDocIDs(ctx, id)
+				if j%10 == 0 {
+					clock.Advance(300 * time.Millisecond)
+					store.Sweep()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,351 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DEFAULT_LATENCY_WINDOW is the number of most recent queries latency percentiles are computed over
+const DEFAULT_LATENCY_WINDOW = 1000
+
+// Aggregator keeps rolling statistics over the RetrievalMetrics of every pipeline run.
+// Counters cover the process lifetime; latency percentiles cover the most recent queries.
+// It is safe for concurrent use, and a nil Aggregator ignores observations.
+type Aggregator struct {
+	mu           sync.Mutex
+	queries      int64
+	failures     int64
+	reranked     int64
+	cacheLookups int64
+	cacheHits    int64
+	semanticHits int64
+	cragVerdicts map[string]int64
+	latencies    []int64
+	next         int
+	// latencySum is the total latency of every query, for the latency summary
+	latencySum int64
+
+	// autoTopK counts the K chosen by auto TopK; autoTopKFallbacks the runs without an elbow
+	autoTopK          map[int]int64
+	autoTopKFallbacks int64
+
+	// backfills counts the min_results backfills by the stage that reached the floor
+	backfills map[string]int64
+
+	// stageTimeouts counts the pipeline stages that ran past their deadline
+	stageTimeouts map[string]int64
+
+	// embeddingCacheStats reports the hits and misses of the embedding cache, when enabled
+	embeddingCacheStats func() (hits, misses int64)
+}
+
+// AggregateSnapshot is a point-in-time view of an Aggregator
+type AggregateSnapshot struct {
+	QueryCount     int64            `json:"query_count"`
+	FailureCount   int64            `json:"failure_count"`
+	LatencyP50Ms   int64            `json:"latency_p50_ms"`
+	LatencyP95Ms   int64            `json:"latency_p95_ms"`
+	LatencySamples int              `json:"latency_samples"`
+	LatencySumMs   int64            `json:"latency_sum_ms"`
+	RerankRate     float64          `json:"rerank_rate"`
+	CRAGVerdicts   map[string]int64 `json:"crag_verdicts"`
+	CacheLookups   int64            `json:"cache_lookups"`
+	CacheHits      int64            `json:"cache_hits"`
+	CacheHitRate   float64          `json:"cache_hit_rate"`
+	// CacheSemanticHits is the part of CacheHits served by a similar query
+	CacheSemanticHits int64 `json:"cache_semantic_hits"`
+	// AutoTopK counts the runs by the number of results auto TopK kept, and
+	// AutoTopKFallbacks the runs that found no elbow and kept the profile's TopK
+	AutoTopK          map[int]int64 `json:"auto_top_k"`
+	AutoTopKFallbacks int64         `json:"auto_top_k_fallbacks"`
+	// Backfills counts the runs that backfilled results up to min_results by stage
+	Backfills map[string]int64 `json:"backfills"`
+	// StageTimeouts counts the pipeline stages that ran past their pipeline.timeouts deadline
+	StageTimeouts map[string]int64 `json:"stage_timeouts"`
+	// EmbeddingCache* count the lookups of the embedding cache, zero when it is disabled
+	EmbeddingCacheHits    int64   `json:"embedding_cache_hits"`
+	EmbeddingCacheMisses  int64   `json:"embedding_cache_misses"`
+	EmbeddingCacheHitRate float64 `json:"embedding_cache_hit_rate"`
+}
+
+// NewAggregator creates an aggregator computing latency percentiles over the last window
+// queries; window <= 0 uses DEFAULT_LATENCY_WINDOW
+func NewAggregator(window int) *Aggregator {
+	if window <= 0 {
+		window = DEFAULT_LATENCY_WINDOW
+	}
+	return &Aggregator{
+		cragVerdicts:  make(map[string]int64),
+		autoTopK:      make(map[int]int64),
+		backfills:     make(map[string]int64),
+		stageTimeouts: make(map[string]int64),
+		latencies:     make([]int64, 0, window),
+	}
+}
+
+// SetEmbeddingCacheStats makes snapshots report the embedding cache statistics of stats
+func (a *Aggregator) SetEmbeddingCacheStats(stats func() (hits, misses int64)) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.embeddingCacheStats = stats
+}
+
+// Observe records one pipeline run
+func (a *Aggregator) Observe(m *RetrievalMetrics) {
+	if a == nil || m == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.queries++
+	if !m.Success {
+		a.failures++
+	}
+	if m.RerankEnabled {
+		a.reranked++
+	}
+	if m.CacheEnabled {
+		a.cacheLookups++
+		if m.CacheHit {
+			a.cacheHits++
+			if m.CacheHitType == CACHE_HIT_SEMANTIC {
+				a.semanticHits++
+			}
+		}
+	}
+	if m.CRAGVerdict != "" {
+		a.cragVerdicts[m.CRAGVerdict]++
+	}
+	if m.AutoTopK > 0 {
+		a.autoTopK[m.AutoTopK]++
+		if !m.AutoTopKElbow {
+			a.autoTopKFallbacks++
+		}
+	}
+	if m.MinResultsBackfill != "" {
+		a.backfills[m.MinResultsBackfill]++
+	}
+	for _, stage := range m.StageTimeouts {
+		a.stageTimeouts[stage]++
+	}
+	for typ, stats := range m.RetrieverMetrics {
+		observeRetrieverStats(typ, stats.LatencyMs, stats.ResultCount)
+	}
+	a.latencySum += m.TotalLatencyMs
+	if len(a.latencies) < cap(a.latencies) {
+		a.latencies = append(a.latencies, m.TotalLatencyMs)
+	} else {
+		a.latencies[a.next] = m.TotalLatencyMs
+		a.next = (a.next + 1) % len(a.latencies)
+	}
+}
+
+// Snapshot returns the current statistics
+func (a *Aggregator) Snapshot() AggregateSnapshot {
+	if a == nil {
+		return AggregateSnapshot{CRAGVerdicts: map[string]int64{}, AutoTopK: map[int]int64{}, Backfills: map[string]int64{}, StageTimeouts: map[string]int64{}}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := AggregateSnapshot{
+		QueryCount:     a.queries,
+		FailureCount:   a.failures,
+		LatencySamples: len(a.latencies),
+		LatencySumMs:   a.latencySum,
+		CRAGVerdicts:   make(map[string]int64, len(a.cragVerdicts)),
+		CacheLookups:   a.cacheLookups,
+		CacheHits:      a.cacheHits,
+
+		CacheSemanticHits: a.semanticHits,
+		AutoTopK:          make(map[int]int64, len(a.autoTopK)),
+		AutoTopKFallbacks: a.autoTopKFallbacks,
+		Backfills:         make(map[string]int64, len(a.backfills)),
+		StageTimeouts:     make(map[string]int64, len(a.stageTimeouts)),
+	}
+	for verdict, count := range a.cragVerdicts {
+		s.CRAGVerdicts[verdict] = count
+	}
+	for k, count := range a.autoTopK {
+		s.AutoTopK[k] = count
+	}
+	for stage, count := range a.backfills {
+		s.Backfills[stage] = count
+	}
+	for stage, count := range a.stageTimeouts {
+		s.StageTimeouts[stage] = count
+	}
+	if a.queries > 0 {
+		s.RerankRate = float64(a.reranked) / float64(a.queries)
+	}
+	if a.cacheLookups > 0 {
+		s.CacheHitRate = float64(a.cacheHits) / float64(a.cacheLookups)
+	}
+	if a.embeddingCacheStats != nil {
+		s.EmbeddingCacheHits, s.EmbeddingCacheMisses = a.embeddingCacheStats()
+		if lookups := s.EmbeddingCacheHits + s.EmbeddingCacheMisses; lookups > 0 {
+			s.EmbeddingCacheHitRate = float64(s.EmbeddingCacheHits) / float64(lookups)
+		}
+	}
+	if len(a.latencies) > 0 {
+		sorted := append([]int64(nil), a.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		s.LatencyP50Ms = percentile(sorted, 0.5)
+		s.LatencyP95Ms = percentile(sorted, 0.95)
+	}
+	return s
+}
+
+// percentile returns the nearest-rank percentile p of sorted values
+func percentile(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// Handler serves the statistics and the rag_retriever_* collectors in the Prometheus
+// exposition format at /metrics, and the statistics as JSON at /metrics/json
+func (a *Aggregator) Handler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(a)
+	registry.MustRegister(Collectors()...)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/metrics/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(a.Snapshot())
+	})
+	return mux
+}
+
+// Endpoint is a metrics endpoint started by Serve
+type Endpoint struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+// Shutdown stops the endpoint, waiting for in-flight scrapes until ctx is done and closing
+// them afterwards. The address is free once it returns.
+func (e *Endpoint) Shutdown(ctx context.Context) error {
+	err := e.server.Shutdown(ctx)
+	if err != nil {
+		_ = e.server.Close()
+	}
+	// The server only closes the listener once its Serve goroutine has picked it up
+	if closeErr := e.listener.Close(); closeErr != nil && !errors.Is(closeErr, net.ErrClosed) && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Serve starts serving Handler on addr in the background. The listener is opened before
+// returning so an address already in use is reported to the caller.
+func (a *Aggregator) Serve(addr string) (*Endpoint, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s failed: %w", addr, err)
+	}
+	server := &http.Server{Handler: a.Handler()}
+	go func() { _ = server.Serve(listener) }()
+	return &Endpoint{server: server, listener: listener}, nil
+}
+
+var (
+	queriesDesc = prometheus.NewDesc("rag_pipeline_queries_total",
+		"Number of retrieval pipeline runs", nil, nil)
+	failuresDesc = prometheus.NewDesc("rag_pipeline_failures_total",
+		"Number of pipeline runs that failed or returned no results", nil, nil)
+	latencyDesc = prometheus.NewDesc("rag_pipeline_latency_ms",
+		"Pipeline latency in milliseconds; quantiles cover the most recent queries", nil, nil)
+	rerankRateDesc = prometheus.NewDesc("rag_pipeline_rerank_rate",
+		"Fraction of pipeline runs that reranked results", nil, nil)
+	cragVerdictsDesc = prometheus.NewDesc("rag_pipeline_crag_verdicts_total",
+		"CRAG verdicts of pipeline runs", []string{"verdict"}, nil)
+	cacheLookupsDesc = prometheus.NewDesc("rag_pipeline_cache_lookups_total",
+		"Number of retrieval cache lookups", nil, nil)
+	cacheHitsDesc = prometheus.NewDesc("rag_pipeline_cache_hits_total",
+		"Number of retrieval cache hits in either layer", nil, nil)
+	cacheSemanticHitsDesc = prometheus.NewDesc("rag_pipeline_cache_semantic_hits_total",
+		"Number of retrieval cache hits served by a similar query", nil, nil)
+	cacheHitRateDesc = prometheus.NewDesc("rag_pipeline_cache_hit_rate",
+		"Fraction of retrieval cache lookups that hit", nil, nil)
+	embeddingCacheHitsDesc = prometheus.NewDesc("rag_embedding_cache_hits_total",
+		"Number of embeddings served by the embedding cache", nil, nil)
+	embeddingCacheMissesDesc = prometheus.NewDesc("rag_embedding_cache_misses_total",
+		"Number of embeddings the embedding cache did not hold", nil, nil)
+	embeddingCacheHitRateDesc = prometheus.NewDesc("rag_embedding_cache_hit_rate",
+		"Fraction of embedding cache lookups that hit", nil, nil)
+	autoTopKDesc = prometheus.NewDesc("rag_pipeline_auto_top_k_total",
+		"Pipeline runs by the number of results auto TopK kept", []string{"k"}, nil)
+	autoTopKFallbacksDesc = prometheus.NewDesc("rag_pipeline_auto_top_k_fallbacks_total",
+		"Auto TopK runs without a score elbow that kept the profile's top_k", nil, nil)
+	backfillsDesc = prometheus.NewDesc("rag_pipeline_backfills_total",
+		"Pipeline runs that backfilled results up to min_results by stage", []string{"stage"}, nil)
+	stageTimeoutsDesc = prometheus.NewDesc("rag_pipeline_stage_timeouts_total",
+		"Pipeline stages that ran past their deadline by stage", []string{"stage"}, nil)
+)
+
+// Describe implements prometheus.Collector
+func (a *Aggregator) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		queriesDesc, failuresDesc, latencyDesc, rerankRateDesc, cragVerdictsDesc,
+		cacheLookupsDesc, cacheHitsDesc, cacheSemanticHitsDesc, cacheHitRateDesc,
+		embeddingCacheHitsDesc, embeddingCacheMissesDesc, embeddingCacheHitRateDesc,
+		autoTopKDesc, autoTopKFallbacksDesc, backfillsDesc, stageTimeoutsDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector, reporting a snapshot taken at scrape time
+func (a *Aggregator) Collect(ch chan<- prometheus.Metric) {
+	s := a.Snapshot()
+	counter := func(desc *prometheus.Desc, value int64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value), labels...)
+	}
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+	counter(queriesDesc, s.QueryCount)
+	counter(failuresDesc, s.FailureCount)
+	ch <- prometheus.MustNewConstSummary(latencyDesc, uint64(s.QueryCount), float64(s.LatencySumMs), map[float64]float64{
+		0.5:  float64(s.LatencyP50Ms),
+		0.95: float64(s.LatencyP95Ms),
+	})
+	gauge(rerankRateDesc, s.RerankRate)
+	for verdict, count := range s.CRAGVerdicts {
+		counter(cragVerdictsDesc, count, verdict)
+	}
+	counter(cacheLookupsDesc, s.CacheLookups)
+	counter(cacheHitsDesc, s.CacheHits)
+	counter(cacheSemanticHitsDesc, s.CacheSemanticHits)
+	gauge(cacheHitRateDesc, s.CacheHitRate)
+	counter(embeddingCacheHitsDesc, s.EmbeddingCacheHits)
+	counter(embeddingCacheMissesDesc, s.EmbeddingCacheMisses)
+	gauge(embeddingCacheHitRateDesc, s.EmbeddingCacheHitRate)
+	for k, count := range s.AutoTopK {
+		counter(autoTopKDesc, count, strconv.Itoa(k))
+	}
+	counter(autoTopKFallbacksDesc, s.AutoTopKFallbacks)
+	for stage, count := range s.Backfills {
+		counter(backfillsDesc, count, stage)
+	}
+	for stage, count := range s.StageTimeouts {
+		counter(stageTimeoutsDesc, count, stage)
+	}
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAggregator_Snapshot(t *testing.T) {
+	a := NewAggregator(10)
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m := NewRetrievalMetrics()
+			m.TotalLatencyMs = int64(i)
+			m.Success = i%5 != 0
+			m.RerankEnabled = i%2 == 0
+			m.CacheEnabled = true
+			m.CacheHit = i%4 == 0
+			if i <= 3 {
+				m.CRAGVerdict = "correct"
+			}
+			a.Observe(m)
+		}(i)
+	}
+	wg.Wait()
+
+	s := a.Snapshot()
+	if s.QueryCount != 20 || s.FailureCount != 4 || s.LatencySamples != 10 {
+		t.Errorf("counts = %d queries, %d failures, %d samples, want 20, 4, 10", s.QueryCount, s.FailureCount, s.LatencySamples)
+	}
+	if s.RerankRate != 0.5 || s.CacheLookups != 20 || s.CacheHits != 5 || s.CacheHitRate != 0.25 {
+		t.Errorf("rates = %+v", s)
+	}
+	if s.CRAGVerdicts["correct"] != 3 {
+		t.Errorf("CRAG verdicts = %v, want 3 correct", s.CRAGVerdicts)
+	}
+
+	// Once the window is full the oldest latencies are replaced
+	a = NewAggregator(4)
+	for _, latency := range []int64{1000, 1000, 10, 20, 30, 40} {
+		a.Observe(&RetrievalMetrics{TotalLatencyMs: latency})
+	}
+	if s := a.Snapshot(); s.LatencyP50Ms != 20 || s.LatencyP95Ms != 40 {
+		t.Errorf("percentiles = p50 %d, p95 %d, want 20 and 40", s.LatencyP50Ms, s.LatencyP95Ms)
+	}
+
+	// Auto TopK runs are counted by the K they kept
+	a = NewAggregator(4)
+	a.Observe(&RetrievalMetrics{AutoTopK: 3, AutoTopKElbow: true})
+	a.Observe(&RetrievalMetrics{AutoTopK: 3, AutoTopKElbow: true})
+	a.Observe(&RetrievalMetrics{AutoTopK: 10})
+	a.Observe(&RetrievalMetrics{})
+	if s := a.Snapshot(); len(s.AutoTopK) != 2 || s.AutoTopK[3] != 2 || s.AutoTopK[10] != 1 || s.AutoTopKFallbacks != 1 {
+		t.Errorf("auto TopK = %v with %d fallbacks, want {3: 2, 10: 1} with 1", s.AutoTopK, s.AutoTopKFallbacks)
+	}
+	if text := scrape(t, a); !strings.Contains(text, "rag_pipeline_auto_top_k_total{k=\"3\"} 2\n") {
+		t.Errorf("/metrics = %s, want the auto TopK distribution", text)
+	}
+
+	// Backfills are counted by the stage that reached min_results
+	a = NewAggregator(4)
+	a.Observe(&RetrievalMetrics{MinResultsBackfill: "threshold", BackfilledResults: 2})
+	a.Observe(&RetrievalMetrics{MinResultsBackfill: "retrievers", BackfilledResults: 1})
+	a.Observe(&RetrievalMetrics{MinResultsBackfill: "threshold", BackfilledResults: 1})
+	a.Observe(&RetrievalMetrics{})
+	if s := a.Snapshot(); len(s.Backfills) != 2 || s.Backfills["threshold"] != 2 || s.Backfills["retrievers"] != 1 {
+		t.Errorf("backfills = %v, want {threshold: 2, retrievers: 1}", s.Backfills)
+	}
+	if text := scrape(t, a); !strings.Contains(text, "rag_pipeline_backfills_total{stage=\"retrievers\"} 1\n") {
+		t.Errorf("/metrics = %s, want the backfills by stage", text)
+	}
+
+	// The embedding cache statistics are read when snapshotting
+	a = NewAggregator(4)
+	a.SetEmbeddingCacheStats(func() (int64, int64) { return 3, 1 })
+	if s := a.Snapshot(); s.EmbeddingCacheHits != 3 || s.EmbeddingCacheMisses != 1 || s.EmbeddingCacheHitRate != 0.75 {
+		t.Errorf("embedding cache = %d hits, %d misses, rate %g, want 3, 1, 0.75", s.EmbeddingCacheHits, s.EmbeddingCacheMisses, s.EmbeddingCacheHitRate)
+	}
+
+	var nilAggregator *Aggregator
+	nilAggregator.Observe(NewRetrievalMetrics())
+	if s := nilAggregator.Snapshot(); s.QueryCount != 0 {
+		t.Errorf("nil Snapshot() = %+v", s)
+	}
+}
+
+func TestAggregator_Handler(t *testing.T) {
+	a := NewAggregator(0)
+	a.Observe(&RetrievalMetrics{
+		TotalLatencyMs:   12,
+		Success:          true,
+		CRAGVerdict:      "ambiguous",
+		CacheEnabled:     true,
+		RetrieverMetrics: map[string]RetrieverStats{"handler_test": {Type: "handler_test", LatencyMs: 7, ResultCount: 4}},
+	})
+	server := httptest.NewServer(a.Handler())
+	defer server.Close()
+
+	text := get(t, server.URL+"/metrics")
+	for _, line := range []string{
+		"# TYPE rag_pipeline_queries_total counter",
+		"rag_pipeline_queries_total 1",
+		"# TYPE rag_pipeline_latency_ms summary",
+		`rag_pipeline_latency_ms{quantile="0.95"} 12`,
+		"rag_pipeline_latency_ms_sum 12",
+		"rag_pipeline_latency_ms_count 1",
+		`rag_pipeline_crag_verdicts_total{verdict="ambiguous"} 1`,
+		"rag_pipeline_cache_hit_rate 0",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("/metrics does not contain %q:\n%s", line, text)
+		}
+	}
+	// The retriever collectors are process-wide, so only their series are checked
+	for _, series := range []string{`rag_retriever_latency_ms_count{type="handler_test"} `, `rag_retriever_results_sum{type="handler_test"} `} {
+		if !strings.Contains(text, series) {
+			t.Errorf("/metrics does not contain %q:\n%s", series, text)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/metrics/json")
+	if err != nil {
+		t.Fatalf("GET /metrics/json error = %v", err)
+	}
+	defer resp.Body.Close()
+	var snapshot AggregateSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		t.Fatalf("decode /metrics/json: %v", err)
+	}
+	if snapshot.QueryCount != 1 || snapshot.LatencyP50Ms != 12 || snapshot.LatencySumMs != 12 || snapshot.CacheLookups != 1 {
+		t.Errorf("/metrics/json = %+v", snapshot)
+	}
+}
+
+// scrape returns the /metrics exposition of a
+func scrape(t *testing.T, a *Aggregator) string {
+	t.Helper()
+	server := httptest.NewServer(a.Handler())
+	defer server.Close()
+	return get(t, server.URL+"/metrics")
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read %s: %v", url, err)
+	}
+	return string(body)
+}
@@ -59,6 +59,13 @@ func ObserveRetriever(typ string, start time.Time, results int) {
     retrieverResults.WithLabelValues(typ).Observe(float64(results))
 }
 
+// observeRetrieverStats records the latency and result size of a retriever in a pipeline run.
+func observeRetrieverStats(typ string, latencyMs int64, results int) {
+    ensureRegistered()
+    retrieverLatency.WithLabelValues(typ).Observe(float64(latencyMs))
+    retrieverResults.WithLabelValues(typ).Observe(float64(results))
+}
+
 // ObserveFusion records how many lists were fused.
 func ObserveFusion(n int) {
     ensureRegistered()
@@ -7,6 +7,12 @@ import (
 	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
 )
 
+// CacheHitType 的取值
+const (
+	CACHE_HIT_EXACT    = "exact"
+	CACHE_HIT_SEMANTIC = "semantic"
+)
+
 // RetrievalMetrics 记录单次检索的完整指标
 type RetrievalMetrics struct {
 	// 查询信息
@@ -22,6 +28,10 @@ type RetrievalMetrics struct {
 	RetrieversUsed    []string `json:"retrievers_used"`
 	RetrieversSkipped []string `json:"retrievers_skipped,omitempty"` // 被 Gating 跳过的检索器
 
+	// 路由与 Gating 之后最终生效的 profile
+	AppliedRetrievers     []string       `json:"applied_retrievers,omitempty"`
+	AppliedVariantBudgets map[string]int `json:"applied_variant_budgets,omitempty"`
+
 	// Pre 阶段
 	PreEnabled      bool  `json:"pre_enabled"`
 	PreLatencyMs    int64 `json:"pre_latency_ms,omitempty"`
@@ -32,13 +42,31 @@ type RetrievalMetrics struct {
 	TotalRetrieved    int                       `json:"total_retrieved"`
 	RetrievalPhases   []string                  `json:"retrieval_phases,omitempty"` // ["vector_preflight", "parallel_retrieve", "fallback"]
 	FallbackTriggered bool                      `json:"fallback_triggered"`
+	RetrievalDegraded bool                      `json:"retrieval_degraded"` // 成功的检索器数量低于 profile 要求
+	// 并行检索中同时进行的最大检索数，用于调整 max_concurrency
+	MaxInFlightSearches int `json:"max_in_flight_searches,omitempty"`
+	// 查询改写变体实际发起的检索次数（不含原查询）
+	QueryVariantsExecuted int `json:"query_variants_executed,omitempty"`
+	// 以 HyDE 假设文档向量发起的检索次数，以及最终结果中由其召回的文档数（大于 0 即 HyDE 向量对结果有贡献）
+	HyDEVectorSearches int `json:"hyde_vector_searches,omitempty"`
+	HyDEContributed    int `json:"hyde_contributed,omitempty"`
 
 	// 融合阶段
-	FusionStrategy       string `json:"fusion_strategy"`
-	FusionResultCount    int    `json:"fusion_result_count"`
-	FusionLatencyMs      int64  `json:"fusion_latency_ms,omitempty"`
-	DeduplicationCount   int    `json:"deduplication_count,omitempty"` // 融合前去重的文档数
-	FusionWeightsVersion string `json:"fusion_weights_version,omitempty"`
+	FusionStrategy       string         `json:"fusion_strategy"`
+	FusionResultCount    int            `json:"fusion_result_count"`
+	FusionLatencyMs      int64          `json:"fusion_latency_ms,omitempty"`
+	DeduplicationCount   int            `json:"deduplication_count,omitempty"` // 融合前去重的文档数
+	FusionWeightsVersion string         `json:"fusion_weights_version,omitempty"`
+	FusionParams         map[string]any `json:"fusion_params,omitempty"` // 融合策略的规范化参数
+	FusionArm            string         `json:"fusion_arm,omitempty"`    // 灰度分流的实验组：learned 或 control
+	// 自动 TopK 选定的结果数，以及是否找到分数拐点（否则回退为 profile 的 top_k）
+	AutoTopK      int  `json:"auto_top_k,omitempty"`
+	AutoTopKElbow bool `json:"auto_top_k_elbow,omitempty"`
+	// 结果数低于 min_results 时补齐所用的阶段（threshold 或 retrievers）及补齐的结果数
+	MinResultsBackfill string `json:"min_results_backfill,omitempty"`
+	BackfilledResults  int    `json:"backfilled_results,omitempty"`
+	// 各检索器类型在融合前被 retriever_thresholds 过滤掉的结果数
+	RetrieverThresholdFiltered map[string]int `json:"retriever_threshold_filtered,omitempty"`
 
 	// Router 阶段
 	RouterEnabled  bool           `json:"router_enabled"`
@@ -46,23 +74,55 @@ type RetrievalMetrics struct {
 	RouterProfile  string         `json:"router_profile,omitempty"`
 	RouterVariants map[string]int `json:"router_variants,omitempty"`
 	RouterError    string         `json:"router_error,omitempty"`
+	// 语言路由识别出的查询语言（无法识别时为配置的 fallback）
+	QueryLanguage string `json:"query_language,omitempty"`
 
 	// Post 阶段
 	RerankEnabled     bool  `json:"rerank_enabled"`
 	RerankLatencyMs   int64 `json:"rerank_latency_ms,omitempty"`
 	RerankResultCount int   `json:"rerank_result_count,omitempty"`
+	MMREnabled        bool  `json:"mmr_enabled"`
+	MMRResultCount    int   `json:"mmr_result_count,omitempty"`
+	ParentsExpanded   int   `json:"parents_expanded,omitempty"` // expand_parents 替换为父段落的结果数
 	CompressEnabled   bool  `json:"compress_enabled"`
+	// 压缩前后所有结果内容的总长度（字节）与压缩率（百分比，0-100），用于调整 target_ratio 与选择压缩方法
+	CompressMethod           string  `json:"compress_method,omitempty"`
+	CompressOriginalLength   int     `json:"compress_original_length,omitempty"`
+	CompressCompressedLength int     `json:"compress_compressed_length,omitempty"`
+	CompressRatio            float64 `json:"compress_ratio,omitempty"`
 
 	// CRAG 阶段
 	CRAGEnabled bool    `json:"crag_enabled"`
 	CRAGVerdict string  `json:"crag_verdict,omitempty"`
 	CRAGScore   float64 `json:"crag_score,omitempty"`
 
+	// 超过 pipeline.timeouts 期限的阶段（retrieval、rerank、compress、crag），其后按已有结果继续
+	StageTimeouts []string `json:"stage_timeouts,omitempty"`
+
 	// Gating 决策（增强）
 	GatingEnabled   bool     `json:"gating_enabled"`
 	GatingDecisions []string `json:"gating_decisions,omitempty"`
+	GatingTopScore  float64  `json:"gating_top_score,omitempty"` // vector preflight 的 Top1 分数
 	GatingLatencyMs int64    `json:"gating_latency_ms,omitempty"`
 
+	// L1 缓存：是否查询了缓存以及是否命中
+	CacheEnabled bool `json:"cache_enabled"`
+	CacheHit     bool `json:"cache_hit"`
+	// 命中类型：exact 为同一查询，semantic 为相似查询（附相似度）
+	CacheHitType    string  `json:"cache_hit_type,omitempty"`
+	CacheSimilarity float64 `json:"cache_similarity,omitempty"`
+
+	// Embedding 失败时降级为非向量检索
+	EmbeddingError string `json:"embedding_error,omitempty"`
+
+	// 本次请求所有 LLM 调用（pre-retrieve、rerank、compress、CRAG、回答生成）的累计用量
+	LLMCalls         int `json:"llm_calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	// 回答提示词超出 LLM 上下文上限时丢弃的上下文数，以及处理后提示词的估算 token 数
+	ContextDropped      int `json:"context_dropped,omitempty"`
+	ContextPromptTokens int `json:"context_prompt_tokens,omitempty"`
+
 	// 总体
 	TotalLatencyMs int64  `json:"total_latency_ms"`
 	Success        bool   `json:"success"`
@@ -78,6 +138,23 @@ type RetrieverStats struct {
 	TopScore    float64 `json:"top_score"`
 }
 
+// Summary 是 RetrievalMetrics 的精简视图，用于随 chat 工具响应返回
+type Summary struct {
+	QueryID           string   `json:"query_id"`
+	ProfileName       string   `json:"profile_name"`
+	RetrieversUsed    []string `json:"retrievers_used"`
+	TotalRetrieved    int      `json:"total_retrieved"`
+	FallbackTriggered bool     `json:"fallback_triggered"`
+	RerankEnabled     bool     `json:"rerank_enabled"`
+	CompressEnabled   bool     `json:"compress_enabled"`
+	CRAGVerdict       string   `json:"crag_verdict,omitempty"`
+	LLMCalls          int      `json:"llm_calls"`
+	PromptTokens      int      `json:"prompt_tokens"`
+	CompletionTokens  int      `json:"completion_tokens"`
+	TotalLatencyMs    int64    `json:"total_latency_ms"`
+	Success           bool     `json:"success"`
+}
+
 // NewRetrievalMetrics 创建新的检索指标实例
 func NewRetrievalMetrics() *RetrievalMetrics {
 	return &RetrievalMetrics{
@@ -100,6 +177,25 @@ func (m *RetrievalMetrics) LogJSON() {
 	m.Log()
 }
 
+// Summary 返回精简指标
+func (m *RetrievalMetrics) Summary() *Summary {
+	return &Summary{
+		QueryID:           m.QueryID,
+		ProfileName:       m.ProfileName,
+		RetrieversUsed:    append([]string(nil), m.RetrieversUsed...),
+		TotalRetrieved:    m.TotalRetrieved,
+		FallbackTriggered: m.FallbackTriggered,
+		RerankEnabled:     m.RerankEnabled,
+		CompressEnabled:   m.CompressEnabled,
+		CRAGVerdict:       m.CRAGVerdict,
+		LLMCalls:          m.LLMCalls,
+		PromptTokens:      m.PromptTokens,
+		CompletionTokens:  m.CompletionTokens,
+		TotalLatencyMs:    m.TotalLatencyMs,
+		Success:           m.Success,
+	}
+}
+
 // AddRetrieverStats 添加或更新检索器统计
 func (m *RetrievalMetrics) AddRetrieverStats(stats RetrieverStats) {
 	if m.RetrieverMetrics == nil {
@@ -121,9 +217,22 @@ func (m *RetrievalMetrics) AddRetrieverStats(stats RetrieverStats) {
 	}
 }
 
-// AddGatingDecision 记录 gating 决策
-func (m *RetrievalMetrics) AddGatingDecision(decision string) {
+// AddGatingDecision 记录 gating 决策及其依据的 preflight Top1 分数
+func (m *RetrievalMetrics) AddGatingDecision(decision string, topScore float64) {
 	m.GatingDecisions = append(m.GatingDecisions, decision)
+	m.GatingTopScore = topScore
+}
+
+// RecordAppliedProfile 记录检索前最终生效的检索器列表与各变体预算
+func (m *RetrievalMetrics) RecordAppliedProfile(retrievers []string, budgets map[string]int) {
+	m.AppliedRetrievers = append([]string(nil), retrievers...)
+	m.AppliedVariantBudgets = nil
+	if len(budgets) > 0 {
+		m.AppliedVariantBudgets = make(map[string]int, len(budgets))
+		for k, v := range budgets {
+			m.AppliedVariantBudgets[k] = v
+		}
+	}
 }
 
 // RecordProfileSelection 记录 Profile 选择信息
@@ -148,6 +257,17 @@ func (m *RetrievalMetrics) AddSkippedRetriever(retriever string) {
 	m.RetrieversSkipped = append(m.RetrieversSkipped, retriever)
 }
 
+// RecordFusionParams 记录融合策略的规范化参数
+func (m *RetrievalMetrics) RecordFusionParams(params map[string]any) {
+	if len(params) == 0 {
+		return
+	}
+	m.FusionParams = make(map[string]any, len(params))
+	for k, v := range params {
+		m.FusionParams[k] = v
+	}
+}
+
 // RecordFusion 记录融合信息
 func (m *RetrievalMetrics) RecordFusion(strategy string, resultCount, deduplicationCount int, latencyMs int64, weightsVersion string) {
 	m.FusionStrategy = strategy
@@ -158,3 +278,52 @@ func (m *RetrievalMetrics) RecordFusion(strategy string, resultCount, deduplicat
 		m.FusionWeightsVersion = weightsVersion
 	}
 }
+
+// RecordAutoTopK 记录自动 TopK 选定的结果数
+func (m *RetrievalMetrics) RecordAutoTopK(k int, elbow bool) {
+	m.AutoTopK = k
+	m.AutoTopKElbow = elbow
+}
+
+// RecordBackfill 记录为达到 min_results 而补齐的阶段与结果数
+func (m *RetrievalMetrics) RecordBackfill(stage string, added int) {
+	m.MinResultsBackfill = stage
+	m.BackfilledResults = added
+}
+
+// RecordStageTimeout 记录超过期限的流水线阶段
+func (m *RetrievalMetrics) RecordStageTimeout(stage string) {
+	m.StageTimeouts = append(m.StageTimeouts, stage)
+}
+
+// RecordThresholdFiltered 记录检索器类型在融合前被其阈值过滤掉的结果数
+func (m *RetrievalMetrics) RecordThresholdFiltered(retrieverType string, filtered int) {
+	if m.RetrieverThresholdFiltered == nil {
+		m.RetrieverThresholdFiltered = make(map[string]int)
+	}
+	m.RetrieverThresholdFiltered[retrieverType] += filtered
+}
+
+// RecordCompression 记录压缩方法与压缩效果
+func (m *RetrievalMetrics) RecordCompression(method string, originalLength, compressedLength int, ratio float64) {
+	m.CompressEnabled = true
+	m.CompressMethod = method
+	m.CompressOriginalLength = originalLength
+	m.CompressCompressedLength = compressedLength
+	m.CompressRatio = ratio
+}
+
+// RecordLLMUsage 记录累计的 LLM 调用次数与 token 用量
+func (m *RetrievalMetrics) RecordLLMUsage(calls, promptTokens, completionTokens int) {
+	m.LLMCalls = calls
+	m.PromptTokens = promptTokens
+	m.CompletionTokens = completionTokens
+}
+
+// RecordEmbeddingError 记录 embedding 失败（仅保留第一次错误）
+func (m *RetrievalMetrics) RecordEmbeddingError(err error) {
+	if err == nil || m.EmbeddingError != "" {
+		return
+	}
+	m.EmbeddingError = err.Error()
+}
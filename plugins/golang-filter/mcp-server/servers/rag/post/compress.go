@@ -2,8 +2,9 @@ package post
 
 import (
 	"context"
-	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/logger"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
@@ -30,18 +31,47 @@ type CompressionStats struct {
 	CompressionRatio float64 // percentage (0-100)
 }
 
+// BatchCompressionStats returns the total content length, in bytes, of the results before
+// and after BatchCompress. Results the compressor dropped count as fully compressed.
+func BatchCompressionStats(before, after []schema.SearchResult) CompressionStats {
+	stats := CompressionStats{}
+	for _, result := range before {
+		stats.OriginalLength += len(result.Document.Content)
+	}
+	for _, result := range after {
+		stats.CompressedLength += len(result.Document.Content)
+	}
+	if stats.OriginalLength > 0 && stats.CompressedLength < stats.OriginalLength {
+		stats.CompressionRatio = float64(stats.OriginalLength-stats.CompressedLength) / float64(stats.OriginalLength) * 100
+	}
+	return stats
+}
+
 // ================================================================================
 // 1. Truncate Compressor (Original Simple Strategy)
 // ================================================================================
 
+const (
+	// TruncateModeHead keeps the first tokens (default)
+	TruncateModeHead = "head"
+	// TruncateModeTail keeps the last tokens
+	TruncateModeTail = "tail"
+	// TruncateModeHeadTail keeps tokens from both ends joined by TruncateEllipsis
+	TruncateModeHeadTail = "head_tail"
+
+	// TruncateEllipsis marks the text removed between head and tail
+	TruncateEllipsis = "..."
+)
+
 // TruncateCompressor is a simple, query-agnostic compressor.
-// It trims the text to a target ratio of its length, preserving the beginning.
+// It trims the text to a target ratio of its length; Mode selects which part is kept.
 type TruncateCompressor struct {
 	TargetRatio float64 // Target compression ratio (0-1)
+	Mode        string  // head (default), tail or head_tail
 }
 
 func (t *TruncateCompressor) Compress(ctx context.Context, text string, query string) (string, float64, error) {
-	compressed := CompressText(text, t.TargetRatio)
+	compressed := CompressTextWithMode(text, t.TargetRatio, t.Mode)
 	ratio := calculateCompressionRatio(text, compressed)
 	return compressed, ratio, nil
 }
@@ -61,10 +91,18 @@ func (t *TruncateCompressor) BatchCompress(ctx context.Context, results []schema
 
 // CompressText is the original simple compressor function (kept for backward compatibility).
 func CompressText(text string, targetRatio float64) string {
+	return CompressTextWithMode(text, targetRatio, TruncateModeHead)
+}
+
+// CompressTextWithMode keeps targetRatio of the whitespace-separated tokens of text.
+// head keeps the beginning, tail the end, and head_tail splits the budget between
+// both ends (the head gets the extra token) joined by TruncateEllipsis.
+// Unknown modes behave like head.
+func CompressTextWithMode(text string, targetRatio float64, mode string) string {
 	if targetRatio <= 0 || targetRatio >= 1 {
 		return text
 	}
-	// Simple token-ish split to reduce mid-text noise; keep first N tokens.
+	// Simple token-ish split to reduce mid-text noise
 	tokens := strings.Fields(text)
 	if len(tokens) == 0 {
 		return text
@@ -76,7 +114,19 @@ func CompressText(text string, targetRatio float64) string {
 	if keep >= len(tokens) {
 		return text
 	}
-	return strings.Join(tokens[:keep], " ")
+	switch mode {
+	case TruncateModeTail:
+		return strings.Join(tokens[len(tokens)-keep:], " ")
+	case TruncateModeHeadTail:
+		head := (keep + 1) / 2
+		tail := keep - head
+		if tail == 0 {
+			return strings.Join(tokens[:head], " ")
+		}
+		return strings.Join(tokens[:head], " ") + " " + TruncateEllipsis + " " + strings.Join(tokens[len(tokens)-tail:], " ")
+	default:
+		return strings.Join(tokens[:keep], " ")
+	}
 }
 
 // ================================================================================
@@ -85,8 +135,11 @@ func CompressText(text string, targetRatio float64) string {
 
 // SelectiveCompressor extracts ONLY the sentences or paragraphs directly relevant to the query.
 type SelectiveCompressor struct {
-	Provider llm.Provider
-	Model    string
+	Provider    llm.Provider
+	Model       string
+	Concurrency int                 // documents compressed in parallel; defaults to DefaultCompressConcurrency
+	Timeout     time.Duration       // per-document deadline; 0 leaves only the request context
+	Prompts     *llm.PromptRegistry // prompt overrides; nil uses the built-in prompts
 }
 
 const selectiveSystemPrompt = `You are an expert at information filtering. 
@@ -102,21 +155,24 @@ Your output should:
 
 Format your response as plain text with no additional comments.`
 
+const selectiveUserPrompt = `Query: {{.Query}}
+
+Document Chunk:
+{{.Document}}
+
+Extract only the content relevant to answering this query.`
+
 func (s *SelectiveCompressor) Compress(ctx context.Context, text string, query string) (string, float64, error) {
 	if s.Provider == nil {
 		return text, 0, nil
 	}
 
-	userPrompt := fmt.Sprintf(`Query: %s
-
-Document Chunk:
-%s
-
-Extract only the content relevant to answering this query.`, query, text)
-
-	fullPrompt := fmt.Sprintf("%s\n\n%s", selectiveSystemPrompt, userPrompt)
+	messages, err := s.Prompts.Messages(PROMPT_COMPRESS_SELECTIVE_SYSTEM, PROMPT_COMPRESS_SELECTIVE, map[string]any{"Query": query, "Document": text})
+	if err != nil {
+		return text, 0, err
+	}
 
-	compressed, err := s.Provider.GenerateCompletion(ctx, fullPrompt)
+	compressed, err := s.Provider.GenerateChat(ctx, messages)
 	if err != nil {
 		logger.Warnf("SelectiveCompressor: failed to compress: %v, using original", err)
 		return text, 0, err
@@ -133,44 +189,7 @@ Extract only the content relevant to answering this query.`, query, text)
 }
 
 func (s *SelectiveCompressor) BatchCompress(ctx context.Context, results []schema.SearchResult, query string) ([]schema.SearchResult, error) {
-	logger.Infof("SelectiveCompressor: compressing %d documents...", len(results))
-
-	totalOriginal := 0
-	totalCompressed := 0
-	compressed := make([]schema.SearchResult, 0, len(results))
-
-	for i, result := range results {
-		if i%5 == 0 {
-			logger.Infof("SelectiveCompressor: compressing chunk %d/%d...", i+1, len(results))
-		}
-
-		compressedText, ratio, err := s.Compress(ctx, result.Document.Content, query)
-		if err == nil && compressedText != "" {
-			result.Document.Content = compressedText
-			totalOriginal += len(result.Document.Content)
-			totalCompressed += len(compressedText)
-			compressed = append(compressed, result)
-		} else if compressedText != "" {
-			// Keep even if error, as long as we have content
-			result.Document.Content = compressedText
-			compressed = append(compressed, result)
-		}
-		_ = ratio
-	}
-
-	// Filter out empty results
-	if len(compressed) == 0 {
-		logger.Warnf("SelectiveCompressor: all chunks compressed to empty, using originals")
-		return results, nil
-	}
-
-	overallRatio := 0.0
-	if totalOriginal > 0 {
-		overallRatio = float64(totalOriginal-totalCompressed) / float64(totalOriginal) * 100
-	}
-	logger.Infof("SelectiveCompressor: overall compression ratio: %.2f%%", overallRatio)
-
-	return compressed, nil
+	return batchCompress(ctx, "SelectiveCompressor", results, query, s.Concurrency, s.Timeout, s.Compress), nil
 }
 
 // ================================================================================
@@ -179,8 +198,11 @@ func (s *SelectiveCompressor) BatchCompress(ctx context.Context, results []schem
 
 // SummaryCompressor creates a concise summary focusing on query-relevant information.
 type SummaryCompressor struct {
-	Provider llm.Provider
-	Model    string
+	Provider    llm.Provider
+	Model       string
+	Concurrency int                 // documents compressed in parallel; defaults to DefaultCompressConcurrency
+	Timeout     time.Duration       // per-document deadline; 0 leaves only the request context
+	Prompts     *llm.PromptRegistry // prompt overrides; nil uses the built-in prompts
 }
 
 const summarySystemPrompt = `You are an expert at summarization. 
@@ -195,21 +217,24 @@ Your output should:
 
 Format your response as plain text with no additional comments.`
 
+const summaryUserPrompt = `Query: {{.Query}}
+
+Document Chunk:
+{{.Document}}
+
+Create a concise summary focusing only on information relevant to the query.`
+
 func (s *SummaryCompressor) Compress(ctx context.Context, text string, query string) (string, float64, error) {
 	if s.Provider == nil {
 		return text, 0, nil
 	}
 
-	userPrompt := fmt.Sprintf(`Query: %s
-
-Document Chunk:
-%s
-
-Create a concise summary focusing only on information relevant to the query.`, query, text)
-
-	fullPrompt := fmt.Sprintf("%s\n\n%s", summarySystemPrompt, userPrompt)
+	messages, err := s.Prompts.Messages(PROMPT_COMPRESS_SUMMARY_SYSTEM, PROMPT_COMPRESS_SUMMARY, map[string]any{"Query": query, "Document": text})
+	if err != nil {
+		return text, 0, err
+	}
 
-	compressed, err := s.Provider.GenerateCompletion(ctx, fullPrompt)
+	compressed, err := s.Provider.GenerateChat(ctx, messages)
 	if err != nil {
 		logger.Warnf("SummaryCompressor: failed to compress: %v, using original", err)
 		return text, 0, err
@@ -226,42 +251,7 @@ Create a concise summary focusing only on information relevant to the query.`, q
 }
 
 func (s *SummaryCompressor) BatchCompress(ctx context.Context, results []schema.SearchResult, query string) ([]schema.SearchResult, error) {
-	logger.Infof("SummaryCompressor: compressing %d documents...", len(results))
-
-	totalOriginal := 0
-	totalCompressed := 0
-	compressed := make([]schema.SearchResult, 0, len(results))
-
-	for i, result := range results {
-		if i%5 == 0 {
-			logger.Infof("SummaryCompressor: compressing chunk %d/%d...", i+1, len(results))
-		}
-
-		compressedText, ratio, err := s.Compress(ctx, result.Document.Content, query)
-		if err == nil && compressedText != "" {
-			result.Document.Content = compressedText
-			totalOriginal += len(result.Document.Content)
-			totalCompressed += len(compressedText)
-			compressed = append(compressed, result)
-		} else if compressedText != "" {
-			result.Document.Content = compressedText
-			compressed = append(compressed, result)
-		}
-		_ = ratio
-	}
-
-	if len(compressed) == 0 {
-		logger.Warnf("SummaryCompressor: all chunks compressed to empty, using originals")
-		return results, nil
-	}
-
-	overallRatio := 0.0
-	if totalOriginal > 0 {
-		overallRatio = float64(totalOriginal-totalCompressed) / float64(totalOriginal) * 100
-	}
-	logger.Infof("SummaryCompressor: overall compression ratio: %.2f%%", overallRatio)
-
-	return compressed, nil
+	return batchCompress(ctx, "SummaryCompressor", results, query, s.Concurrency, s.Timeout, s.Compress), nil
 }
 
 // ================================================================================
@@ -270,8 +260,11 @@ func (s *SummaryCompressor) BatchCompress(ctx context.Context, results []schema.
 
 // ExtractionCompressor extracts ONLY exact sentences containing query-relevant information.
 type ExtractionCompressor struct {
-	Provider llm.Provider
-	Model    string
+	Provider    llm.Provider
+	Model       string
+	Concurrency int                 // documents compressed in parallel; defaults to DefaultCompressConcurrency
+	Timeout     time.Duration       // per-document deadline; 0 leaves only the request context
+	Prompts     *llm.PromptRegistry // prompt overrides; nil uses the built-in prompts
 }
 
 const extractionSystemPrompt = `You are an expert at information extraction.
@@ -287,21 +280,24 @@ Your output should:
 
 Format your response as plain text with no additional comments.`
 
+const extractionUserPrompt = `Query: {{.Query}}
+
+Document Chunk:
+{{.Document}}
+
+Extract only the exact sentences that are relevant to answering this query.`
+
 func (e *ExtractionCompressor) Compress(ctx context.Context, text string, query string) (string, float64, error) {
 	if e.Provider == nil {
 		return text, 0, nil
 	}
 
-	userPrompt := fmt.Sprintf(`Query: %s
-
-Document Chunk:
-%s
-
-Extract only the exact sentences that are relevant to answering this query.`, query, text)
-
-	fullPrompt := fmt.Sprintf("%s\n\n%s", extractionSystemPrompt, userPrompt)
+	messages, err := e.Prompts.Messages(PROMPT_COMPRESS_EXTRACTION_SYSTEM, PROMPT_COMPRESS_EXTRACTION, map[string]any{"Query": query, "Document": text})
+	if err != nil {
+		return text, 0, err
+	}
 
-	compressed, err := e.Provider.GenerateCompletion(ctx, fullPrompt)
+	compressed, err := e.Provider.GenerateChat(ctx, messages)
 	if err != nil {
 		logger.Warnf("ExtractionCompressor: failed to compress: %v, using original", err)
 		return text, 0, err
@@ -318,48 +314,72 @@ Extract only the exact sentences that are relevant to answering this query.`, qu
 }
 
 func (e *ExtractionCompressor) BatchCompress(ctx context.Context, results []schema.SearchResult, query string) ([]schema.SearchResult, error) {
-	logger.Infof("ExtractionCompressor: compressing %d documents...", len(results))
-
-	totalOriginal := 0
-	totalCompressed := 0
-	compressed := make([]schema.SearchResult, 0, len(results))
+	return batchCompress(ctx, "ExtractionCompressor", results, query, e.Concurrency, e.Timeout, e.Compress), nil
+}
 
-	for i, result := range results {
-		if i%5 == 0 {
-			logger.Infof("ExtractionCompressor: compressing chunk %d/%d...", i+1, len(results))
-		}
+// ================================================================================
+// Helper functions
+// ================================================================================
 
-		compressedText, ratio, err := e.Compress(ctx, result.Document.Content, query)
-		if err == nil && compressedText != "" {
-			result.Document.Content = compressedText
-			totalOriginal += len(result.Document.Content)
-			totalCompressed += len(compressedText)
-			compressed = append(compressed, result)
-		} else if compressedText != "" {
-			result.Document.Content = compressedText
-			compressed = append(compressed, result)
-		}
-		_ = ratio
+// DefaultCompressConcurrency is the number of documents the LLM compressors compress in parallel.
+const DefaultCompressConcurrency = 4
+
+// batchCompress runs compress over results with at most concurrency documents in flight,
+// each bounded by timeout and the request context. Documents that fail, time out or are
+// not started before ctx is done keep their original content; the order is preserved.
+func batchCompress(ctx context.Context, name string, results []schema.SearchResult, query string,
+	concurrency int, timeout time.Duration, compress func(context.Context, string, string) (string, float64, error)) []schema.SearchResult {
+	logger.Infof("%s: compressing %d documents...", name, len(results))
+	if concurrency <= 0 {
+		concurrency = DefaultCompressConcurrency
 	}
 
-	if len(compressed) == 0 {
-		logger.Warnf("ExtractionCompressor: all chunks compressed to empty, using originals")
-		return results, nil
+	compressed := make([]schema.SearchResult, len(results))
+	copy(compressed, results)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var timedOut int
+	var mu sync.Mutex
+	for i := range results {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			logger.Warnf("%s: request done, keeping %d uncompressed documents", name, len(results)-i)
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			docCtx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				docCtx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			text, _, err := compress(docCtx, results[i].Document.Content, query)
+			if docCtx.Err() != nil {
+				mu.Lock()
+				timedOut++
+				mu.Unlock()
+				return
+			}
+			if err == nil && text != "" {
+				compressed[i].Document.Content = text
+			}
+		}(i)
 	}
-
-	overallRatio := 0.0
-	if totalOriginal > 0 {
-		overallRatio = float64(totalOriginal-totalCompressed) / float64(totalOriginal) * 100
+	wg.Wait()
+	if timedOut > 0 {
+		logger.Warnf("%s: %d documents timed out, kept originals", name, timedOut)
 	}
-	logger.Infof("ExtractionCompressor: overall compression ratio: %.2f%%", overallRatio)
 
-	return compressed, nil
+	logger.Infof("%s: overall compression ratio: %.2f%%", name, BatchCompressionStats(results, compressed).CompressionRatio)
+	return compressed
 }
 
-// ================================================================================
-// Helper functions
-// ================================================================================
-
 // calculateCompressionRatio calculates the compression ratio as a percentage
 func calculateCompressionRatio(original, compressed string) float64 {
 	if len(original) == 0 {
@@ -376,36 +396,28 @@ func calculateCompressionRatio(original, compressed string) float64 {
 // Compressor Factory
 // ================================================================================
 
-// NewCompressor creates a Compressor based on method and configuration
+// NewCompressor creates a Compressor based on method and configuration.
+// Methods are resolved through the compressor registry; unknown methods and
+// factories that cannot be satisfied fall back to truncate.
 func NewCompressor(method string, targetRatio float64, llmProvider llm.Provider) Compressor {
-	switch strings.ToLower(method) {
-	case "selective":
-		if llmProvider == nil {
-			logger.Warnf("Selective compression requires LLM provider, falling back to truncate")
-			return &TruncateCompressor{TargetRatio: targetRatio}
-		}
-		return &SelectiveCompressor{Provider: llmProvider}
-
-	case "summary":
-		if llmProvider == nil {
-			logger.Warnf("Summary compression requires LLM provider, falling back to truncate")
-			return &TruncateCompressor{TargetRatio: targetRatio}
-		}
-		return &SummaryCompressor{Provider: llmProvider}
-
-	case "extraction":
-		if llmProvider == nil {
-			logger.Warnf("Extraction compression requires LLM provider, falling back to truncate")
-			return &TruncateCompressor{TargetRatio: targetRatio}
-		}
-		return &ExtractionCompressor{Provider: llmProvider}
-
-	case "truncate", "":
-		// Default to truncate
-		return &TruncateCompressor{TargetRatio: targetRatio}
+	return NewCompressorWithOptions(method, CompressorOptions{TargetRatio: targetRatio, LLM: llmProvider})
+}
 
-	default:
+// NewCompressorWithOptions is NewCompressor with the full set of compressor options.
+func NewCompressorWithOptions(method string, opts CompressorOptions) Compressor {
+	key := strings.ToLower(strings.TrimSpace(method))
+	if key == "" {
+		key = "truncate"
+	}
+	factory, ok := lookupCompressor(key)
+	if !ok {
 		logger.Warnf("Unknown compression method: %s, using truncate", method)
-		return &TruncateCompressor{TargetRatio: targetRatio}
+		return &TruncateCompressor{TargetRatio: opts.TargetRatio, Mode: opts.Mode}
+	}
+	compressor, err := factory(opts)
+	if err != nil || compressor == nil {
+		logger.Warnf("Compression method %s unavailable (%v), falling back to truncate", method, err)
+		return &TruncateCompressor{TargetRatio: opts.TargetRatio, Mode: opts.Mode}
 	}
+	return compressor
 }
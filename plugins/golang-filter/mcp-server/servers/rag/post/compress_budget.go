@@ -0,0 +1,212 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/logger"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// DEFAULT_BUDGET_MIN_TOKENS is the smallest share a trimmed result may keep; results whose
+// share of the budget is smaller are dropped instead
+const DEFAULT_BUDGET_MIN_TOKENS = 32
+
+var errMaxTokensRequired = errors.New("max_tokens must be positive")
+
+// TokenCounter returns the number of LLM tokens text occupies.
+type TokenCounter func(text string) int
+
+// BudgetCompressor trims results collectively so that their contents together fit in
+// MaxTokens. The budget is split across the results in proportion to their scores; a result
+// that needs less than its share keeps all of its content and the rest is redistributed.
+// Results whose share falls below MinTokens are dropped, lowest scores first. With a Base
+// compressor, the results are compressed by Base before the budget is applied.
+type BudgetCompressor struct {
+	MaxTokens int
+	MinTokens int          // <= 0 uses DEFAULT_BUDGET_MIN_TOKENS
+	Counter   TokenCounter // defaults to llm.EstimateTokens
+	Base      Compressor   // optional compressor run first
+}
+
+func (b *BudgetCompressor) Compress(ctx context.Context, text string, query string) (string, float64, error) {
+	compressed := text
+	if b.Base != nil {
+		var err error
+		if compressed, _, err = b.Base.Compress(ctx, text, query); err != nil {
+			return "", 0, err
+		}
+	}
+	if b.MaxTokens > 0 {
+		compressed = trimToTokens(compressed, b.MaxTokens, b.counter())
+	}
+	return compressed, calculateCompressionRatio(text, compressed), nil
+}
+
+func (b *BudgetCompressor) BatchCompress(ctx context.Context, results []schema.SearchResult, query string) ([]schema.SearchResult, error) {
+	if b.Base != nil {
+		compressed, err := b.Base.BatchCompress(ctx, results, query)
+		if err != nil {
+			return nil, err
+		}
+		results = compressed
+	}
+	if b.MaxTokens <= 0 || len(results) == 0 {
+		return results, nil
+	}
+
+	counter := b.counter()
+	counts := make([]int, len(results))
+	total := 0
+	for i, result := range results {
+		counts[i] = counter(result.Document.Content)
+		total += counts[i]
+	}
+	if total <= b.MaxTokens {
+		logger.Infof("BudgetCompressor: %d tokens fit max_tokens=%d, nothing dropped", total, b.MaxTokens)
+		return results, nil
+	}
+
+	alloc := b.allocate(results, counts)
+	out := make([]schema.SearchResult, 0, len(results))
+	kept := 0
+	for i, result := range results {
+		if alloc[i] == 0 {
+			continue
+		}
+		if alloc[i] < counts[i] {
+			result.Document.Content = trimToTokens(result.Document.Content, alloc[i], counter)
+		}
+		kept += counter(result.Document.Content)
+		out = append(out, result)
+	}
+	logger.Infof("BudgetCompressor: dropped %d of %d tokens to fit max_tokens=%d (%d of %d documents removed)",
+		total-kept, total, b.MaxTokens, len(results)-len(out), len(results))
+	return out, nil
+}
+
+func (b *BudgetCompressor) counter() TokenCounter {
+	if b.Counter != nil {
+		return b.Counter
+	}
+	return llm.EstimateTokens
+}
+
+// allocate returns the token share of every result. A zero share drops the result: while
+// any trimmed result gets less than MinTokens, the lowest-scored of them is dropped and the
+// budget is split again among the others.
+func (b *BudgetCompressor) allocate(results []schema.SearchResult, counts []int) []int {
+	minTokens := b.MinTokens
+	if minTokens <= 0 {
+		minTokens = DEFAULT_BUDGET_MIN_TOKENS
+	}
+	if minTokens > b.MaxTokens {
+		minTokens = b.MaxTokens
+	}
+	// Lowest scores first; ties drop the later result first
+	byScore := make([]int, len(results))
+	for i := range byScore {
+		byScore[i] = i
+	}
+	sort.SliceStable(byScore, func(i, j int) bool {
+		if results[byScore[i]].Score != results[byScore[j]].Score {
+			return results[byScore[i]].Score < results[byScore[j]].Score
+		}
+		return byScore[i] > byScore[j]
+	})
+
+	active := make([]bool, len(results))
+	for i := range results {
+		active[i] = counts[i] > 0
+	}
+	for {
+		alloc := splitBudget(results, counts, active, b.MaxTokens)
+		dropped := false
+		for _, i := range byScore {
+			if active[i] && alloc[i] < counts[i] && alloc[i] < minTokens {
+				active[i] = false
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			return alloc
+		}
+	}
+}
+
+// splitBudget splits budget across the active results in proportion to their scores.
+// Results that need no more than their share get exactly what they need and their unused
+// share goes to the others. Non-positive scores weigh nothing unless no active result has a
+// positive score, in which case the budget is split evenly.
+func splitBudget(results []schema.SearchResult, counts []int, active []bool, budget int) []int {
+	alloc := make([]int, len(results))
+	var pending []int
+	for i := range results {
+		if active[i] {
+			pending = append(pending, i)
+		}
+	}
+	remaining := float64(budget)
+	for len(pending) > 0 {
+		weights := make([]float64, len(pending))
+		sum := 0.0
+		for j, i := range pending {
+			weights[j] = results[i].Score
+			if weights[j] < 0 {
+				weights[j] = 0
+			}
+			sum += weights[j]
+		}
+		if sum == 0 {
+			for j := range weights {
+				weights[j] = 1
+			}
+			sum = float64(len(weights))
+		}
+
+		var next []int
+		used := 0
+		for j, i := range pending {
+			if float64(counts[i]) <= remaining*weights[j]/sum {
+				alloc[i] = counts[i]
+				used += counts[i]
+			} else {
+				next = append(next, i)
+			}
+		}
+		if len(next) == len(pending) {
+			for j, i := range pending {
+				alloc[i] = int(remaining * weights[j] / sum)
+			}
+			break
+		}
+		remaining -= float64(used)
+		pending = next
+	}
+	return alloc
+}
+
+// trimToTokens keeps the longest head of text that fits in limit tokens, cut back to a
+// word boundary when one is in the second half of the kept text.
+func trimToTokens(text string, limit int, counter TokenCounter) string {
+	if counter(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	keep := sort.Search(len(runes)+1, func(n int) bool {
+		return counter(string(runes[:n])) > limit
+	}) - 1
+	if keep <= 0 {
+		return ""
+	}
+	head := string(runes[:keep])
+	if cut := strings.LastIndexFunc(head, unicode.IsSpace); cut > len(head)/2 {
+		head = head[:cut]
+	}
+	return strings.TrimRightFunc(head, unicode.IsSpace)
+}
@@ -0,0 +1,132 @@
+package post
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+func countWords(text string) int { return len(strings.Fields(text)) }
+
+func budgetResult(id string, score float64, words int) schema.SearchResult {
+	tokens := make([]string, words)
+	for i := range tokens {
+		tokens[i] = id
+	}
+	return schema.SearchResult{Document: schema.Document{ID: id, Content: strings.Join(tokens, " ")}, Score: score}
+}
+
+func budgetWords(results []schema.SearchResult) map[string]int {
+	words := make(map[string]int, len(results))
+	for _, res := range results {
+		words[res.Document.ID] = countWords(res.Document.Content)
+	}
+	return words
+}
+
+func TestBudgetCompressor_BatchCompress(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []schema.SearchResult
+		want    map[string]int
+	}{
+		{
+			"split by score",
+			[]schema.SearchResult{budgetResult("a", 0.6, 10), budgetResult("b", 0.3, 10), budgetResult("c", 0.1, 1)},
+			map[string]int{"a": 6, "b": 3, "c": 1},
+		},
+		{
+			"unused share redistributed",
+			[]schema.SearchResult{budgetResult("a", 0.5, 20), budgetResult("b", 0.5, 2)},
+			map[string]int{"a": 8, "b": 2},
+		},
+		{
+			"share below min tokens dropped",
+			[]schema.SearchResult{budgetResult("a", 0.9, 20), budgetResult("b", 0.1, 20)},
+			map[string]int{"a": 10},
+		},
+		{
+			"fits unchanged",
+			[]schema.SearchResult{budgetResult("a", 0.9, 4), budgetResult("b", 0.1, 6)},
+			map[string]int{"a": 4, "b": 6},
+		},
+		{
+			"zero scores split evenly",
+			[]schema.SearchResult{budgetResult("a", 0, 10), budgetResult("b", 0, 10)},
+			map[string]int{"a": 5, "b": 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &BudgetCompressor{MaxTokens: 10, MinTokens: 2, Counter: countWords}
+			got, err := b.BatchCompress(context.Background(), tt.results, "q")
+			if err != nil {
+				t.Fatalf("BatchCompress() error = %v", err)
+			}
+			if words := budgetWords(got); !reflect.DeepEqual(words, tt.want) {
+				t.Errorf("BatchCompress() words = %v, want %v", words, tt.want)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i-1].Score < got[i].Score {
+					t.Errorf("BatchCompress() must keep the input order, got %v", mmrIDs(got))
+				}
+			}
+		})
+	}
+}
+
+func TestBudgetCompressor_Base(t *testing.T) {
+	b := &BudgetCompressor{
+		MaxTokens: 4,
+		MinTokens: 1,
+		Counter:   countWords,
+		Base:      &TruncateCompressor{TargetRatio: 0.5},
+	}
+	got, err := b.BatchCompress(context.Background(), []schema.SearchResult{budgetResult("a", 1, 6), budgetResult("b", 1, 6)}, "q")
+	if err != nil {
+		t.Fatalf("BatchCompress() error = %v", err)
+	}
+	if words := budgetWords(got); !reflect.DeepEqual(words, map[string]int{"a": 2, "b": 2}) {
+		t.Errorf("BatchCompress() words = %v, want the truncated halves trimmed to the budget", words)
+	}
+
+	text, _, err := b.Compress(context.Background(), "one two three four five six seven eight nine ten", "q")
+	if err != nil || text != "one two three four" {
+		t.Errorf("Compress() = %q, %v, want the truncated text within 4 tokens", text, err)
+	}
+}
+
+func TestTrimToTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  string
+	}{
+		{"fits", "higress gateway", 10, "higress gateway"},
+		{"word boundary", "higress routes traffic to upstream services", 5, "higress routes"},
+		{"cjk", "检索增强生成", 4, "检索增强"},
+		{"nothing fits", "检索", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &BudgetCompressor{}
+			if got := trimToTokens(tt.text, tt.limit, b.counter()); got != tt.want {
+				t.Errorf("trimToTokens(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCompressor_Budget(t *testing.T) {
+	c := NewCompressorWithOptions("budget", CompressorOptions{MaxTokens: 100})
+	if b, ok := c.(*BudgetCompressor); !ok || b.MaxTokens != 100 {
+		t.Errorf("NewCompressorWithOptions(budget) = %#v, want a BudgetCompressor with max_tokens 100", c)
+	}
+	if _, ok := NewCompressorWithOptions("budget", CompressorOptions{TargetRatio: 0.5}).(*TruncateCompressor); !ok {
+		t.Error("NewCompressorWithOptions(budget) without max_tokens should fall back to truncate")
+	}
+}
@@ -3,15 +3,21 @@ package post
 import (
 	"context"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
 )
 
 // MockLLMProvider for testing
 type MockCompressorLLMProvider struct {
+	mu       sync.Mutex
 	response string
 	err      error
+	messages []llm.ChatMessage
 }
 
 func (m *MockCompressorLLMProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
@@ -21,6 +27,13 @@ func (m *MockCompressorLLMProvider) GenerateCompletion(ctx context.Context, prom
 	return m.response, nil
 }
 
+func (m *MockCompressorLLMProvider) GenerateChat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	m.mu.Lock()
+	m.messages = messages
+	m.mu.Unlock()
+	return m.GenerateCompletion(ctx, llm.ConcatMessages(messages))
+}
+
 func (m *MockCompressorLLMProvider) GetProviderType() string {
 	return "mock"
 }
@@ -109,6 +122,57 @@ func TestSelectiveCompressor_Compress(t *testing.T) {
 	}
 }
 
+func TestLLMCompressors_SendSystemAndUserMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		system string
+		build  func(provider *MockCompressorLLMProvider) Compressor
+	}{
+		{name: "selective", system: selectiveSystemPrompt, build: func(p *MockCompressorLLMProvider) Compressor { return &SelectiveCompressor{Provider: p} }},
+		{name: "summary", system: summarySystemPrompt, build: func(p *MockCompressorLLMProvider) Compressor { return &SummaryCompressor{Provider: p} }},
+		{name: "extraction", system: extractionSystemPrompt, build: func(p *MockCompressorLLMProvider) Compressor { return &ExtractionCompressor{Provider: p} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProvider := &MockCompressorLLMProvider{response: "short"}
+			if _, _, err := tt.build(mockProvider).Compress(context.Background(), "some long document text", "test query"); err != nil {
+				t.Fatalf("Compress failed: %v", err)
+			}
+			if len(mockProvider.messages) != 2 {
+				t.Fatalf("Expected 2 messages, got %d", len(mockProvider.messages))
+			}
+			system, user := mockProvider.messages[0], mockProvider.messages[1]
+			if system.Role != llm.ROLE_SYSTEM || system.Content != tt.system {
+				t.Errorf("Expected system message with the compressor instructions, got %+v", system)
+			}
+			if user.Role != llm.ROLE_USER || !strings.Contains(user.Content, "test query") || strings.Contains(user.Content, tt.system) {
+				t.Errorf("Expected user message with only the query and document, got %+v", user)
+			}
+		})
+	}
+}
+
+func TestLLMCompressors_PromptOverrides(t *testing.T) {
+	prompts, err := llm.NewPromptRegistry(&config.PromptsConfig{Templates: map[string]string{
+		PROMPT_COMPRESS_SUMMARY_SYSTEM: "你是摘要助手。",
+		PROMPT_COMPRESS_SUMMARY:        "问题：{{.Query}}\n文档：{{.Document}}",
+	}})
+	if err != nil {
+		t.Fatalf("NewPromptRegistry() error = %v", err)
+	}
+	mockProvider := &MockCompressorLLMProvider{response: "short"}
+	compressor, _ := NewCompressorWithOptions("summary", CompressorOptions{LLM: mockProvider, Prompts: prompts}).(*SummaryCompressor)
+	if compressor == nil {
+		t.Fatal("expected a SummaryCompressor")
+	}
+	if _, _, err := compressor.Compress(context.Background(), "网关文档", "网关"); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	if mockProvider.messages[0].Content != "你是摘要助手。" || mockProvider.messages[1].Content != "问题：网关\n文档：网关文档" {
+		t.Errorf("messages = %+v", mockProvider.messages)
+	}
+}
+
 func TestSelectiveCompressor_EmptyResponse(t *testing.T) {
 	mockProvider := &MockCompressorLLMProvider{
 		response: "",
@@ -334,6 +398,23 @@ func TestCalculateCompressionRatio(t *testing.T) {
 	}
 }
 
+func TestBatchCompressionStats(t *testing.T) {
+	before := []schema.SearchResult{
+		{Document: schema.Document{ID: "a", Content: "0123456789"}},
+		{Document: schema.Document{ID: "b", Content: "0123456789"}},
+	}
+	// b was dropped, a trimmed to half
+	after := []schema.SearchResult{{Document: schema.Document{ID: "a", Content: "01234"}}}
+
+	stats := BatchCompressionStats(before, after)
+	if stats.OriginalLength != 20 || stats.CompressedLength != 5 || stats.CompressionRatio != 75 {
+		t.Errorf("BatchCompressionStats() = %+v, want 20 -> 5 at 75%%", stats)
+	}
+	if stats := BatchCompressionStats(nil, nil); stats != (CompressionStats{}) {
+		t.Errorf("BatchCompressionStats(nil) = %+v, want zero stats", stats)
+	}
+}
+
 func TestCompressText_BackwardCompatibility(t *testing.T) {
 	// Test that the original CompressText function still works
 	text := "one two three four five six seven eight nine ten"
@@ -348,3 +429,137 @@ func TestCompressText_BackwardCompatibility(t *testing.T) {
 		t.Errorf("Unexpected compressed text: %s", compressed)
 	}
 }
+
+func TestTruncateCompressor_Modes(t *testing.T) {
+	text := "one two three four five six seven eight nine ten"
+	tests := []struct {
+		name  string
+		mode  string
+		ratio float64
+		want  string
+	}{
+		{name: "default is head", mode: "", ratio: 0.5, want: "one two three four five"},
+		{name: "head", mode: TruncateModeHead, ratio: 0.3, want: "one two three"},
+		{name: "tail", mode: TruncateModeTail, ratio: 0.3, want: "eight nine ten"},
+		{name: "head_tail even", mode: TruncateModeHeadTail, ratio: 0.4, want: "one two ... nine ten"},
+		{name: "head_tail odd", mode: TruncateModeHeadTail, ratio: 0.5, want: "one two three ... nine ten"},
+		{name: "head_tail single token", mode: TruncateModeHeadTail, ratio: 0.1, want: "one"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressor := &TruncateCompressor{TargetRatio: tt.ratio, Mode: tt.mode}
+			compressed, _, err := compressor.Compress(context.Background(), text, "query")
+			if err != nil {
+				t.Fatalf("Compress failed: %v", err)
+			}
+			if compressed != tt.want {
+				t.Errorf("Compress() = %q, want %q", compressed, tt.want)
+			}
+
+			// The ellipsis marker does not count towards the token budget
+			kept := 0
+			for _, token := range strings.Fields(compressed) {
+				if token != TruncateEllipsis {
+					kept++
+				}
+			}
+			if budget := int(float64(len(strings.Fields(text))) * tt.ratio); kept > budget {
+				t.Errorf("Kept %d tokens, exceeds target_ratio budget %d", kept, budget)
+			}
+		})
+	}
+}
+
+func TestNewCompressorWithOptions_TruncateMode(t *testing.T) {
+	compressor := NewCompressorWithOptions("truncate", CompressorOptions{TargetRatio: 0.2, Mode: TruncateModeTail})
+	truncate, ok := compressor.(*TruncateCompressor)
+	if !ok || truncate.Mode != TruncateModeTail {
+		t.Fatalf("Expected tail TruncateCompressor, got %#v", compressor)
+	}
+
+	// Falling back from an unavailable LLM method keeps the mode
+	compressor = NewCompressorWithOptions("selective", CompressorOptions{TargetRatio: 0.2, Mode: TruncateModeHeadTail})
+	if truncate, ok := compressor.(*TruncateCompressor); !ok || truncate.Mode != TruncateModeHeadTail {
+		t.Errorf("Expected head_tail TruncateCompressor fallback, got %#v", compressor)
+	}
+}
+
+// sleepyCompressorProvider compresses to "short" after the delay configured for the chunk,
+// returning early with the context error when cancelled.
+type sleepyCompressorProvider struct {
+	delays map[string]time.Duration
+}
+
+func (m *sleepyCompressorProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	var delay time.Duration
+	for chunk, d := range m.delays {
+		if strings.Contains(prompt, chunk) {
+			delay = d
+		}
+	}
+	select {
+	case <-time.After(delay):
+		return "short", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (m *sleepyCompressorProvider) GenerateChat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	return m.GenerateCompletion(ctx, llm.ConcatMessages(messages))
+}
+
+func (m *sleepyCompressorProvider) GetProviderType() string {
+	return "mock"
+}
+
+func TestLLMCompressors_BatchCompressTimeout(t *testing.T) {
+	provider := &sleepyCompressorProvider{delays: map[string]time.Duration{"slow chunk": time.Second}}
+	input := []schema.SearchResult{
+		{Document: schema.Document{ID: "1", Content: "fast chunk one"}},
+		{Document: schema.Document{ID: "2", Content: "slow chunk"}},
+		{Document: schema.Document{ID: "3", Content: "fast chunk two"}},
+	}
+
+	for _, compressor := range []Compressor{
+		&SelectiveCompressor{Provider: provider, Concurrency: 2, Timeout: 50 * time.Millisecond},
+		&SummaryCompressor{Provider: provider, Concurrency: 2, Timeout: 50 * time.Millisecond},
+		&ExtractionCompressor{Provider: provider, Concurrency: 2, Timeout: 50 * time.Millisecond},
+	} {
+		start := time.Now()
+		result, err := compressor.BatchCompress(context.Background(), input, "test query")
+		if err != nil {
+			t.Fatalf("%T.BatchCompress() error = %v", compressor, err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("%T.BatchCompress() took %v, want the slow chunk cancelled", compressor, elapsed)
+		}
+		got := []string{result[0].Document.Content, result[1].Document.Content, result[2].Document.Content}
+		if want := []string{"short", "slow chunk", "short"}; strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("%T.BatchCompress() = %q, want %q", compressor, got, want)
+		}
+	}
+}
+
+func TestLLMCompressors_BatchCompressRespectsRequestContext(t *testing.T) {
+	provider := &sleepyCompressorProvider{delays: map[string]time.Duration{"chunk": time.Second}}
+	compressor := &SummaryCompressor{Provider: provider, Concurrency: 1}
+	input := []schema.SearchResult{
+		{Document: schema.Document{ID: "1", Content: "chunk one"}},
+		{Document: schema.Document{ID: "2", Content: "chunk two"}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result, err := compressor.BatchCompress(ctx, input, "test query")
+	if err != nil {
+		t.Fatalf("BatchCompress() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("BatchCompress() took %v, want it to stop at the request deadline", elapsed)
+	}
+	if result[0].Document.Content != "chunk one" || result[1].Document.Content != "chunk two" {
+		t.Errorf("BatchCompress() = %+v, want originals kept", result)
+	}
+}
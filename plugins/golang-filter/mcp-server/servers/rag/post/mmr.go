@@ -0,0 +1,128 @@
+package post
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/embedding"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// DEFAULT_MMR_LAMBDA weighs relevance and diversity equally
+const DEFAULT_MMR_LAMBDA = 0.5
+
+// MMR reorders ranked results by Maximal Marginal Relevance: each pick maximizes
+// Lambda*relevance - (1-Lambda)*max similarity to the results already picked. Relevance is
+// the result score min-max normalized over the input, and similarity is the cosine of the
+// document vectors. Documents without a vector are embedded with Embedder.
+type MMR struct {
+	Lambda   float64 // 1 keeps the input order, 0 maximizes diversity
+	TopN     int     // number of results kept; <= 0 keeps all
+	Embedder embedding.Provider
+}
+
+// NewMMR creates an MMR step; lambda outside (0, 1] uses DEFAULT_MMR_LAMBDA
+func NewMMR(lambda float64, topN int, embedder embedding.Provider) *MMR {
+	if lambda <= 0 || lambda > 1 {
+		lambda = DEFAULT_MMR_LAMBDA
+	}
+	return &MMR{Lambda: lambda, TopN: topN, Embedder: embedder}
+}
+
+// Diversify returns at most TopN of in, reordered by MMR. Scores are left unchanged.
+func (m *MMR) Diversify(ctx context.Context, in []schema.SearchResult) ([]schema.SearchResult, error) {
+	topN := m.TopN
+	if topN <= 0 || topN > len(in) {
+		topN = len(in)
+	}
+	if len(in) <= 1 {
+		return in, nil
+	}
+	vectors, err := m.vectors(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	relevance := normalizedScores(in)
+
+	out := make([]schema.SearchResult, 0, topN)
+	picked := make([]bool, len(in))
+	// maxSim[i] is the highest similarity of candidate i to any picked result
+	maxSim := make([]float64, len(in))
+	for len(out) < topN {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range in {
+			if picked[i] {
+				continue
+			}
+			score := m.Lambda * relevance[i]
+			if len(out) > 0 {
+				score -= (1 - m.Lambda) * maxSim[i]
+			}
+			// Ties keep the input order
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		picked[best] = true
+		out = append(out, in[best])
+		for i := range in {
+			if !picked[i] {
+				if sim := embedding.CosineSimilarity(vectors[i], vectors[best]); len(out) == 1 || sim > maxSim[i] {
+					maxSim[i] = sim
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// vectors returns the vector of every result, embedding in one request only the
+// documents that have none
+func (m *MMR) vectors(ctx context.Context, in []schema.SearchResult) ([][]float32, error) {
+	vectors := make([][]float32, len(in))
+	var missing []int
+	var texts []string
+	for i, res := range in {
+		if len(res.Document.Vector) > 0 {
+			vectors[i] = res.Document.Vector
+			continue
+		}
+		missing = append(missing, i)
+		texts = append(texts, res.Document.Content)
+	}
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+	if m.Embedder == nil {
+		return nil, fmt.Errorf("mmr: %d results have no vector and no embedding provider is configured", len(missing))
+	}
+	embedded, err := embedding.GetEmbeddings(ctx, m.Embedder, texts)
+	if err != nil {
+		return nil, fmt.Errorf("mmr: embed results failed: %w", err)
+	}
+	if len(embedded) != len(missing) {
+		return nil, fmt.Errorf("mmr: got %d vectors for %d results", len(embedded), len(missing))
+	}
+	for j, i := range missing {
+		vectors[i] = embedded[j]
+	}
+	return vectors, nil
+}
+
+// normalizedScores min-max normalizes the result scores to [0, 1]; equal scores are all 1
+func normalizedScores(in []schema.SearchResult) []float64 {
+	lo, hi := in[0].Score, in[0].Score
+	for _, res := range in {
+		lo = math.Min(lo, res.Score)
+		hi = math.Max(hi, res.Score)
+	}
+	scores := make([]float64, len(in))
+	for i, res := range in {
+		scores[i] = 1
+		if hi > lo {
+			scores[i] = (res.Score - lo) / (hi - lo)
+		}
+	}
+	return scores
+}
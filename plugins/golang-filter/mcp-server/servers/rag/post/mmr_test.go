@@ -0,0 +1,114 @@
+package post
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// fakeMMREmbedder embeds texts from a fixed table and records what it was asked for
+type fakeMMREmbedder struct {
+	mu      sync.Mutex
+	vectors map[string][]float32
+	texts   []string
+	err     error
+}
+
+func (f *fakeMMREmbedder) GetProviderType() string { return "fake" }
+
+func (f *fakeMMREmbedder) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.texts = append(f.texts, text)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.vectors[text], nil
+}
+
+func (f *fakeMMREmbedder) GetDimensions(ctx context.Context) (int, error) { return 2, nil }
+
+func mmrResult(id string, score float64, vector []float32) schema.SearchResult {
+	return schema.SearchResult{Document: schema.Document{ID: id, Content: id, Vector: vector}, Score: score}
+}
+
+func mmrIDs(results []schema.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, res := range results {
+		ids[i] = res.Document.ID
+	}
+	return ids
+}
+
+func TestMMR_Diversify(t *testing.T) {
+	ranked := []schema.SearchResult{
+		mmrResult("gateway", 1.0, []float32{1, 0}),
+		mmrResult("gateway-copy", 0.95, []float32{1, 0.01}),
+		mmrResult("plugins", 0.9, []float32{0, 1}),
+		mmrResult("routing", 0.85, []float32{0.7, 0.7}),
+		mmrResult("unrelated", 0.1, []float32{0, -1}),
+	}
+	tests := []struct {
+		name   string
+		lambda float64
+		topN   int
+		want   []string
+	}{
+		{"near duplicate moved down", 0.5, 0, []string{"gateway", "plugins", "routing", "unrelated", "gateway-copy"}},
+		{"top_n drops the duplicate", 0.5, 2, []string{"gateway", "plugins"}},
+		{"lambda 1 keeps the ranking", 1, 0, []string{"gateway", "gateway-copy", "plugins", "routing", "unrelated"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewMMR(tt.lambda, tt.topN, nil).Diversify(context.Background(), ranked)
+			if err != nil {
+				t.Fatalf("Diversify() error = %v", err)
+			}
+			if ids := mmrIDs(got); !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("Diversify() = %v, want %v", ids, tt.want)
+			}
+			if got[0].Score != 1.0 {
+				t.Errorf("score = %v, want scores left unchanged", got[0].Score)
+			}
+		})
+	}
+}
+
+func TestMMR_EmbedsOnlyMissingVectors(t *testing.T) {
+	embedder := &fakeMMREmbedder{vectors: map[string][]float32{
+		"gateway-copy": {1, 0.01},
+		"plugins":      {0, 1},
+	}}
+	ranked := []schema.SearchResult{
+		mmrResult("gateway", 1.0, []float32{1, 0}),
+		mmrResult("gateway-copy", 0.95, nil),
+		mmrResult("plugins", 0.9, nil),
+	}
+	got, err := NewMMR(0.5, 0, embedder).Diversify(context.Background(), ranked)
+	if err != nil {
+		t.Fatalf("Diversify() error = %v", err)
+	}
+	if ids := mmrIDs(got); !reflect.DeepEqual(ids, []string{"gateway", "plugins", "gateway-copy"}) {
+		t.Errorf("Diversify() = %v, want the embedded duplicate moved down", ids)
+	}
+	sort.Strings(embedder.texts)
+	if !reflect.DeepEqual(embedder.texts, []string{"gateway-copy", "plugins"}) {
+		t.Errorf("embedded %v, want only the results without a vector", embedder.texts)
+	}
+	if ranked[1].Document.Vector != nil {
+		t.Error("Diversify() must not modify the input documents")
+	}
+
+	embedder.err = errors.New("embedding service down")
+	if _, err := NewMMR(0.5, 0, embedder).Diversify(context.Background(), ranked); err == nil {
+		t.Error("Diversify() expected the embedding error")
+	}
+	if _, err := NewMMR(0.5, 0, nil).Diversify(context.Background(), ranked); err == nil {
+		t.Error("Diversify() without an embedder expected an error")
+	}
+}
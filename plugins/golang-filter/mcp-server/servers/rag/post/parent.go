@@ -0,0 +1,48 @@
+package post
+
+import (
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// PARENT_EXPANSION_FACTOR is how many chunks are retrieved per result when hits are expanded
+// to their parents, so that chunks sharing a parent still leave TopK distinct parents
+const PARENT_EXPANSION_FACTOR = 3
+
+// ExpandParents replaces each hit of a hierarchical chunk with its parent section. The hits
+// of one parent collapse into a single result at the rank and score of the best one, which
+// keeps its ID and metadata but carries the parent content. Results without a parent are
+// kept as they are. At most topN results are returned; topN <= 0 keeps all. The second
+// value is the number of results that were expanded.
+func ExpandParents(in []schema.SearchResult, topN int) ([]schema.SearchResult, int) {
+	out := make([]schema.SearchResult, 0, len(in))
+	seen := make(map[string]bool, len(in))
+	expanded := 0
+	for _, result := range in {
+		if topN > 0 && len(out) >= topN {
+			break
+		}
+		parentID, _ := result.Document.Metadata[schema.METADATA_PARENT_ID].(string)
+		content, _ := result.Document.Metadata[schema.METADATA_PARENT_CONTENT].(string)
+		if parentID == "" || content == "" {
+			out = append(out, result)
+			continue
+		}
+		if seen[parentID] {
+			continue
+		}
+		seen[parentID] = true
+
+		// The parent content moves out of the metadata so it is not returned twice
+		metadata := make(map[string]interface{}, len(result.Document.Metadata))
+		for k, v := range result.Document.Metadata {
+			if k != schema.METADATA_PARENT_CONTENT {
+				metadata[k] = v
+			}
+		}
+		result.Document.Metadata = metadata
+		result.Document.Content = content
+		out = append(out, result)
+		expanded++
+	}
+	return out, expanded
+}
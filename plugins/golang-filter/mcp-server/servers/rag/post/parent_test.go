@@ -0,0 +1,49 @@
+package post
+
+import (
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+func TestExpandParents(t *testing.T) {
+	child := func(id, parentID, parent string, score float64) schema.SearchResult {
+		return schema.SearchResult{Document: schema.Document{ID: id, Content: id, Metadata: map[string]interface{}{
+			schema.METADATA_PARENT_ID:      parentID,
+			schema.METADATA_PARENT_CONTENT: parent,
+			"chunk_title":                  "guide",
+		}}, Score: score}
+	}
+	in := []schema.SearchResult{
+		child("a1", "a", "section a", 0.9),
+		child("b1", "b", "section b", 0.8),
+		child("a2", "a", "section a", 0.7),
+		{Document: schema.Document{ID: "plain", Content: "plain"}, Score: 0.6},
+		child("c1", "c", "section c", 0.5),
+	}
+
+	out, expanded := ExpandParents(in, 0)
+	if len(out) != 4 || expanded != 3 {
+		t.Fatalf("ExpandParents() = %d results, %d expanded, want 4 and 3", len(out), expanded)
+	}
+	want := []struct{ id, content string }{{"a1", "section a"}, {"b1", "section b"}, {"plain", "plain"}, {"c1", "section c"}}
+	for i, w := range want {
+		if out[i].Document.ID != w.id || out[i].Document.Content != w.content {
+			t.Errorf("result %d = %s %q, want %s %q", i, out[i].Document.ID, out[i].Document.Content, w.id, w.content)
+		}
+	}
+	if out[0].Score != 0.9 || out[0].Document.Metadata["chunk_title"] != "guide" {
+		t.Errorf("result 0 = %+v, want the score and metadata of the best hit", out[0])
+	}
+	if _, ok := out[0].Document.Metadata[schema.METADATA_PARENT_CONTENT]; ok {
+		t.Error("expanded results should not repeat the parent content in their metadata")
+	}
+	if _, ok := in[0].Document.Metadata[schema.METADATA_PARENT_CONTENT]; !ok || in[0].Document.Content != "a1" {
+		t.Error("ExpandParents() modified its input")
+	}
+
+	// topN counts the results after collapsing
+	if out, _ := ExpandParents(in, 2); len(out) != 2 || out[1].Document.ID != "b1" {
+		t.Errorf("ExpandParents(2) = %+v, want two parents", out)
+	}
+}
@@ -0,0 +1,28 @@
+package post
+
+import "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+
+// Prompt template names of the LLM reranker and compressors, overridable via pipeline.prompts.
+// Both templates of a stage take {{.Query}} and {{.Document}}, which the user template must use.
+const (
+	PROMPT_RERANK_SYSTEM              = "rerank_system"
+	PROMPT_RERANK                     = "rerank"
+	PROMPT_COMPRESS_SELECTIVE_SYSTEM  = "compress_selective_system"
+	PROMPT_COMPRESS_SELECTIVE         = "compress_selective"
+	PROMPT_COMPRESS_SUMMARY_SYSTEM    = "compress_summary_system"
+	PROMPT_COMPRESS_SUMMARY           = "compress_summary"
+	PROMPT_COMPRESS_EXTRACTION_SYSTEM = "compress_extraction_system"
+	PROMPT_COMPRESS_EXTRACTION        = "compress_extraction"
+)
+
+func init() {
+	documentFields := []string{"Query", "Document"}
+	llm.RegisterPrompt(PROMPT_RERANK_SYSTEM, llm.PromptSpec{Default: llmRerankSystemPrompt, Fields: documentFields})
+	llm.RegisterPrompt(PROMPT_RERANK, llm.PromptSpec{Default: llmRerankUserPrompt, Fields: documentFields, Required: documentFields})
+	llm.RegisterPrompt(PROMPT_COMPRESS_SELECTIVE_SYSTEM, llm.PromptSpec{Default: selectiveSystemPrompt, Fields: documentFields})
+	llm.RegisterPrompt(PROMPT_COMPRESS_SELECTIVE, llm.PromptSpec{Default: selectiveUserPrompt, Fields: documentFields, Required: documentFields})
+	llm.RegisterPrompt(PROMPT_COMPRESS_SUMMARY_SYSTEM, llm.PromptSpec{Default: summarySystemPrompt, Fields: documentFields})
+	llm.RegisterPrompt(PROMPT_COMPRESS_SUMMARY, llm.PromptSpec{Default: summaryUserPrompt, Fields: documentFields, Required: documentFields})
+	llm.RegisterPrompt(PROMPT_COMPRESS_EXTRACTION_SYSTEM, llm.PromptSpec{Default: extractionSystemPrompt, Fields: documentFields})
+	llm.RegisterPrompt(PROMPT_COMPRESS_EXTRACTION, llm.PromptSpec{Default: extractionUserPrompt, Fields: documentFields, Required: documentFields})
+}
@@ -0,0 +1,155 @@
+package post
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/httpx"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/keywords"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/logger"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+)
+
+// CompressorOptions carries the configuration available to compressor factories.
+type CompressorOptions struct {
+	TargetRatio float64
+	Mode        string // truncate mode: head, tail or head_tail
+	LLM         llm.Provider
+	Concurrency int                 // LLM compressors: documents compressed in parallel
+	Timeout     time.Duration       // LLM compressors: per-document deadline
+	MaxTokens   int                 // budget compressor: token budget of all results together
+	Prompts     *llm.PromptRegistry // LLM compressors: prompt overrides
+}
+
+// CompressorFactory builds a Compressor for a compression method.
+type CompressorFactory func(opts CompressorOptions) (Compressor, error)
+
+// RerankerOptions carries the configuration available to reranker factories.
+type RerankerOptions struct {
+	Endpoint string
+	Model    string
+	APIKey   string
+	LLM      llm.Provider
+	HTTP     *config.HTTPClientConfig // timeouts, allowlist and circuit breaker for HTTP rerankers
+	Keywords *config.KeywordConfig    // stopwords and stemming of the keyword reranker
+	Prompts  *llm.PromptRegistry      // prompt overrides of the LLM reranker
+}
+
+// RerankerFactory builds a Reranker for a reranker provider.
+type RerankerFactory func(opts RerankerOptions) (Reranker, error)
+
+var errLLMRequired = errors.New("llm provider is required")
+
+var (
+	registryMu          sync.RWMutex
+	compressorFactories = map[string]CompressorFactory{}
+	rerankerFactories   = map[string]RerankerFactory{}
+)
+
+func init() {
+	RegisterCompressor("truncate", func(opts CompressorOptions) (Compressor, error) {
+		return &TruncateCompressor{TargetRatio: opts.TargetRatio, Mode: opts.Mode}, nil
+	})
+	RegisterCompressor("selective", func(opts CompressorOptions) (Compressor, error) {
+		if opts.LLM == nil {
+			return nil, errLLMRequired
+		}
+		return &SelectiveCompressor{Provider: opts.LLM, Concurrency: opts.Concurrency, Timeout: opts.Timeout, Prompts: opts.Prompts}, nil
+	})
+	RegisterCompressor("summary", func(opts CompressorOptions) (Compressor, error) {
+		if opts.LLM == nil {
+			return nil, errLLMRequired
+		}
+		return &SummaryCompressor{Provider: opts.LLM, Concurrency: opts.Concurrency, Timeout: opts.Timeout, Prompts: opts.Prompts}, nil
+	})
+	RegisterCompressor("extraction", func(opts CompressorOptions) (Compressor, error) {
+		if opts.LLM == nil {
+			return nil, errLLMRequired
+		}
+		return &ExtractionCompressor{Provider: opts.LLM, Concurrency: opts.Concurrency, Timeout: opts.Timeout, Prompts: opts.Prompts}, nil
+	})
+	RegisterCompressor("budget", func(opts CompressorOptions) (Compressor, error) {
+		if opts.MaxTokens <= 0 {
+			return nil, errMaxTokensRequired
+		}
+		return &BudgetCompressor{MaxTokens: opts.MaxTokens}, nil
+	})
+
+	RegisterReranker("http", func(opts RerankerOptions) (Reranker, error) {
+		return &HTTPReranker{Endpoint: opts.Endpoint, Client: httpx.NewFromConfig(opts.HTTP)}, nil
+	})
+	RegisterReranker("llm", func(opts RerankerOptions) (Reranker, error) {
+		if opts.LLM == nil {
+			return nil, errLLMRequired
+		}
+		return &LLMReranker{Provider: opts.LLM, Model: opts.Model, Prompts: opts.Prompts}, nil
+	})
+	RegisterReranker("keyword", func(opts RerankerOptions) (Reranker, error) {
+		normalizer, err := keywords.New(opts.Keywords)
+		if err != nil {
+			return nil, err
+		}
+		return &KeywordReranker{MinKeywordLength: 3, BaseScoreWeight: 0.5, Keywords: normalizer}, nil
+	})
+	RegisterReranker("model", func(opts RerankerOptions) (Reranker, error) {
+		return &ModelReranker{Endpoint: opts.Endpoint, Model: opts.Model, APIKey: opts.APIKey, Client: httpx.NewFromConfig(opts.HTTP)}, nil
+	})
+	RegisterReranker("dashscope", func(opts RerankerOptions) (Reranker, error) {
+		return &DashScopeReranker{Endpoint: opts.Endpoint, Model: opts.Model, APIKey: opts.APIKey, Client: httpx.NewFromConfig(opts.HTTP)}, nil
+	})
+}
+
+// RegisterCompressor makes a compression method selectable via post.compress.method.
+// Registering an existing method replaces it.
+func RegisterCompressor(method string, factory CompressorFactory) {
+	key := strings.ToLower(strings.TrimSpace(method))
+	if key == "" || factory == nil {
+		return
+	}
+	registryMu.Lock()
+	compressorFactories[key] = factory
+	registryMu.Unlock()
+}
+
+// RegisterReranker makes a reranker provider selectable via post.rerank.provider.
+// Registering an existing provider replaces it.
+func RegisterReranker(provider string, factory RerankerFactory) {
+	key := strings.ToLower(strings.TrimSpace(provider))
+	if key == "" || factory == nil {
+		return
+	}
+	registryMu.Lock()
+	rerankerFactories[key] = factory
+	registryMu.Unlock()
+}
+
+func lookupCompressor(method string) (CompressorFactory, bool) {
+	registryMu.RLock()
+	f, ok := compressorFactories[method]
+	registryMu.RUnlock()
+	return f, ok
+}
+
+func lookupReranker(provider string) (RerankerFactory, bool) {
+	registryMu.RLock()
+	f, ok := rerankerFactories[provider]
+	registryMu.RUnlock()
+	return f, ok
+}
+
+// NewReranker creates a Reranker for the given provider. Unknown or empty providers
+// fall back to the HTTP reranker for backward compatibility.
+func NewReranker(provider string, opts RerankerOptions) (Reranker, error) {
+	key := strings.ToLower(strings.TrimSpace(provider))
+	factory, ok := lookupReranker(key)
+	if !ok {
+		if key != "" {
+			logger.Warnf("Unknown rerank provider: %s, using http", provider)
+		}
+		return &HTTPReranker{Endpoint: opts.Endpoint, Client: httpx.NewFromConfig(opts.HTTP)}, nil
+	}
+	return factory(opts)
+}
@@ -0,0 +1,113 @@
+package post
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+type upperCompressor struct{}
+
+func (u *upperCompressor) Compress(ctx context.Context, text string, query string) (string, float64, error) {
+	return "compressed:" + text, 0, nil
+}
+
+func (u *upperCompressor) BatchCompress(ctx context.Context, results []schema.SearchResult, query string) ([]schema.SearchResult, error) {
+	return results, nil
+}
+
+type reverseReranker struct{ endpoint string }
+
+func (r *reverseReranker) Rerank(ctx context.Context, query string, in []schema.SearchResult, topN int) ([]schema.SearchResult, error) {
+	out := make([]schema.SearchResult, 0, len(in))
+	for i := len(in) - 1; i >= 0; i-- {
+		out = append(out, in[i])
+	}
+	return out, nil
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	RegisterCompressor("Custom_Upper", func(opts CompressorOptions) (Compressor, error) {
+		return &upperCompressor{}, nil
+	})
+
+	c := NewCompressor("custom_upper", 0.5, nil)
+	if _, ok := c.(*upperCompressor); !ok {
+		t.Fatalf("NewCompressor() = %T, want *upperCompressor", c)
+	}
+	out, _, _ := c.Compress(context.Background(), "text", "q")
+	if out != "compressed:text" {
+		t.Errorf("Compress() = %q", out)
+	}
+}
+
+func TestNewCompressorFallbacks(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+	}{
+		{name: "unknown method", method: "does_not_exist"},
+		{name: "empty method", method: ""},
+		{name: "llm method without provider", method: "summary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCompressor(tt.method, 0.5, nil)
+			tc, ok := c.(*TruncateCompressor)
+			if !ok {
+				t.Fatalf("NewCompressor(%q) = %T, want *TruncateCompressor", tt.method, c)
+			}
+			if tc.TargetRatio != 0.5 {
+				t.Errorf("TargetRatio = %v, want 0.5", tc.TargetRatio)
+			}
+		})
+	}
+}
+
+func TestNewCompressorBuiltinWithLLM(t *testing.T) {
+	c := NewCompressor("selective", 0.5, &MockCompressorLLMProvider{})
+	if _, ok := c.(*SelectiveCompressor); !ok {
+		t.Errorf("NewCompressor(selective) = %T, want *SelectiveCompressor", c)
+	}
+}
+
+func TestRegisterReranker(t *testing.T) {
+	RegisterReranker("reverse", func(opts RerankerOptions) (Reranker, error) {
+		return &reverseReranker{endpoint: opts.Endpoint}, nil
+	})
+
+	r, err := NewReranker("reverse", RerankerOptions{Endpoint: "http://rerank"})
+	if err != nil {
+		t.Fatalf("NewReranker() error = %v", err)
+	}
+	rr, ok := r.(*reverseReranker)
+	if !ok {
+		t.Fatalf("NewReranker() = %T, want *reverseReranker", r)
+	}
+	if rr.endpoint != "http://rerank" {
+		t.Errorf("endpoint = %q, want http://rerank", rr.endpoint)
+	}
+}
+
+func TestNewRerankerFallbacks(t *testing.T) {
+	for _, provider := range []string{"", "http", "does_not_exist"} {
+		r, err := NewReranker(provider, RerankerOptions{Endpoint: "http://rerank"})
+		if err != nil {
+			t.Fatalf("NewReranker(%q) error = %v", provider, err)
+		}
+		h, ok := r.(*HTTPReranker)
+		if !ok {
+			t.Fatalf("NewReranker(%q) = %T, want *HTTPReranker", provider, r)
+		}
+		if h.Endpoint != "http://rerank" {
+			t.Errorf("Endpoint = %q, want http://rerank", h.Endpoint)
+		}
+	}
+}
+
+func TestNewRerankerLLMRequiresProvider(t *testing.T) {
+	if _, err := NewReranker("llm", RerankerOptions{}); err == nil {
+		t.Error("NewReranker(llm) expected error without llm provider")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/httpx"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/keywords"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/logger"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
@@ -110,7 +111,8 @@ func NewHTTPReranker(endpoint string) *HTTPReranker { return &HTTPReranker{Endpo
 // LLMReranker uses an LLM to score and rerank documents based on relevance.
 type LLMReranker struct {
 	Provider llm.Provider
-	Model    string // optional: specific model to use for reranking
+	Model    string              // optional: specific model to use for reranking
+	Prompts  *llm.PromptRegistry // optional: prompt overrides, nil uses the built-in prompts
 }
 
 const llmRerankSystemPrompt = `You are an expert at evaluating document relevance for search queries.
@@ -124,6 +126,12 @@ Guidelines:
 
 You MUST respond with ONLY a single integer score between 0 and 10. Do not include ANY other text.`
 
+const llmRerankUserPrompt = `Query: {{.Query}}
+Document:
+{{.Document}}
+
+Rate this document's relevance to the query on a scale from 0 to 10:`
+
 func (l *LLMReranker) Rerank(ctx context.Context, query string, in []schema.SearchResult, topN int) ([]schema.SearchResult, error) {
 	if l.Provider == nil {
 		// Fallback: return top N by original scores
@@ -143,18 +151,14 @@ func (l *LLMReranker) Rerank(ctx context.Context, query string, in []schema.Sear
 			logger.Infof("LLMReranker: scoring document %d/%d...", i+1, len(in))
 		}
 
-		// Build user prompt
-		userPrompt := fmt.Sprintf(`Query: %s
-Document:
-%s
-
-Rate this document's relevance to the query on a scale from 0 to 10:`, query, result.Document.Content)
-
-		// Create full prompt with system message
-		fullPrompt := fmt.Sprintf("%s\n\n%s", llmRerankSystemPrompt, userPrompt)
+		// Send the instructions as a system message
+		messages, err := l.Prompts.Messages(PROMPT_RERANK_SYSTEM, PROMPT_RERANK, map[string]any{"Query": query, "Document": result.Document.Content})
+		if err != nil {
+			return nil, err
+		}
 
 		// Get LLM response
-		response, err := l.Provider.GenerateCompletion(ctx, fullPrompt)
+		response, err := l.Provider.GenerateChat(ctx, messages)
 		if err != nil {
 			logger.Warnf("LLMReranker: failed to score document %d: %v, using original score", i, err)
 			// Use original score scaled to 0-10
@@ -208,6 +212,9 @@ Rate this document's relevance to the query on a scale from 0 to 10:`, query, re
 type KeywordReranker struct {
 	MinKeywordLength int     // Minimum length for a word to be considered a keyword (default: 3)
 	BaseScoreWeight  float64 // Weight for original similarity score (default: 0.5)
+	// Keywords extracts the query keywords and normalizes documents; nil drops the
+	// built-in stopwords without stemming
+	Keywords *keywords.Normalizer
 }
 
 func (k *KeywordReranker) Rerank(ctx context.Context, query string, in []schema.SearchResult, topN int) ([]schema.SearchResult, error) {
@@ -223,20 +230,20 @@ func (k *KeywordReranker) Rerank(ctx context.Context, query string, in []schema.
 
 	logger.Infof("KeywordReranker: reranking %d documents based on keywords...", len(in))
 
-	// Extract keywords from query (words longer than minLen)
-	keywords := make([]string, 0)
-	for _, word := range strings.Fields(query) {
-		if len(word) > minLen {
-			keywords = append(keywords, strings.ToLower(word))
-		}
+	normalizer := k.Keywords
+	if normalizer == nil {
+		normalizer = keywords.Default()
 	}
 
-	logger.Infof("KeywordReranker: extracted %d keywords: %v", len(keywords), keywords)
+	// Extract keywords from query (non-stopwords longer than minLen; Han terms at any length)
+	queryKeywords := normalizer.Keywords(query, minLen+1)
+
+	logger.Infof("KeywordReranker: extracted %d keywords: %v", len(queryKeywords), queryKeywords)
 
 	scored := make([]schema.SearchResult, 0, len(in))
 
 	for _, result := range in {
-		documentText := strings.ToLower(result.Document.Content)
+		documentText := normalizer.Normalize(result.Document.Content)
 
 		// Base score from original similarity
 		baseScore := result.Score * baseWeight
@@ -244,7 +251,7 @@ func (k *KeywordReranker) Rerank(ctx context.Context, query string, in []schema.
 		// Keyword matching score
 		keywordScore := 0.0
 
-		for _, keyword := range keywords {
+		for _, keyword := range queryKeywords {
 			if strings.Contains(documentText, keyword) {
 				// Base keyword match: +0.1
 				keywordScore += 0.1
@@ -2,8 +2,12 @@ package post
 
 import (
 	"context"
+	"strings"
 	"testing"
 
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/keywords"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
 )
 
@@ -11,6 +15,7 @@ import (
 type MockLLMProvider struct {
 	responses []string
 	callCount int
+	messages  [][]llm.ChatMessage
 }
 
 func (m *MockLLMProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
@@ -22,6 +27,11 @@ func (m *MockLLMProvider) GenerateCompletion(ctx context.Context, prompt string)
 	return response, nil
 }
 
+func (m *MockLLMProvider) GenerateChat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	m.messages = append(m.messages, messages)
+	return m.GenerateCompletion(ctx, llm.ConcatMessages(messages))
+}
+
 func (m *MockLLMProvider) GetProviderType() string {
 	return "mock"
 }
@@ -60,6 +70,16 @@ func TestLLMReranker_Rerank(t *testing.T) {
 	if result[2].Document.ID != "2" || result[2].Score != 5 {
 		t.Errorf("Expected third result to be doc 2 with score 5, got %s with score %f", result[2].Document.ID, result[2].Score)
 	}
+
+	// Instructions go in the system role, the document in the user role
+	for i, messages := range mockProvider.messages {
+		if len(messages) != 2 || messages[0].Role != llm.ROLE_SYSTEM || messages[1].Role != llm.ROLE_USER {
+			t.Fatalf("Call %d: expected system and user messages, got %+v", i, messages)
+		}
+		if messages[0].Content != llmRerankSystemPrompt || !strings.Contains(messages[1].Content, input[i].Document.Content) {
+			t.Errorf("Call %d: unexpected message contents %+v", i, messages)
+		}
+	}
 }
 
 func TestLLMReranker_TopN(t *testing.T) {
@@ -138,6 +158,51 @@ func TestKeywordReranker_PositionBonus(t *testing.T) {
 	}
 }
 
+func TestKeywordReranker_IgnoresStopwords(t *testing.T) {
+	reranker := &KeywordReranker{MinKeywordLength: 3, BaseScoreWeight: 0.1}
+
+	input := []schema.SearchResult{
+		{Document: schema.Document{ID: "1", Content: "What does this do? What would that be? What about them?"}, Score: 0.5},
+		{Document: schema.Document{ID: "2", Content: "The gateway forwards traffic to upstream services"}, Score: 0.5},
+	}
+
+	result, err := reranker.Rerank(context.Background(), "what does the gateway do", input, 2)
+	if err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+	if result[0].Document.ID != "2" {
+		t.Errorf("Expected doc 2 to rank first, got %s", result[0].Document.ID)
+	}
+	// Only "gateway" is a keyword, so the stopword-heavy document gets no keyword score
+	if result[1].Score != 0.05 {
+		t.Errorf("Expected doc 1 to keep its base score 0.05, got %v", result[1].Score)
+	}
+}
+
+func TestKeywordReranker_ChineseAndStemming(t *testing.T) {
+	normalizer, err := keywords.New(&config.KeywordConfig{Stem: true})
+	if err != nil {
+		t.Fatalf("keywords.New failed: %v", err)
+	}
+	reranker := &KeywordReranker{MinKeywordLength: 3, BaseScoreWeight: 0.1, Keywords: normalizer}
+
+	input := []schema.SearchResult{
+		{Document: schema.Document{ID: "1", Content: "如何使用插件"}, Score: 0.5},
+		{Document: schema.Document{ID: "2", Content: "Higress 网关按路由转发请求，routed by policies"}, Score: 0.5},
+	}
+
+	result, err := reranker.Rerank(context.Background(), "如何配置网关的路由 routing policy", input, 2)
+	if err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+	if result[0].Document.ID != "2" {
+		t.Errorf("Expected doc 2 to rank first, got %s", result[0].Document.ID)
+	}
+	if result[1].Score != 0.05 {
+		t.Errorf("Expected the stopword 如何 not to score, got %v", result[1].Score)
+	}
+}
+
 func TestModelReranker_Fallback(t *testing.T) {
 	// Test fallback behavior when endpoint is not configured
 	reranker := &ModelReranker{
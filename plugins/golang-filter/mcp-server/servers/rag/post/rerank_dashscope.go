@@ -0,0 +1,131 @@
+package post
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/httpx"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/logger"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+const (
+	// DASHSCOPE_RERANK_ENDPOINT is the DashScope text rerank API
+	DASHSCOPE_RERANK_ENDPOINT = "https://dashscope.aliyuncs.com/api/v1/services/rerank/text-rerank/text-rerank"
+	// DASHSCOPE_RERANK_MODEL is the default DashScope rerank model
+	DASHSCOPE_RERANK_MODEL = "gte-rerank"
+)
+
+// DashScopeReranker reranks with the DashScope (Qwen) text rerank API, e.g. gte-rerank.
+// Endpoint and Model default to DASHSCOPE_RERANK_ENDPOINT and DASHSCOPE_RERANK_MODEL.
+// Without an API key, or on any request error, the input order is kept.
+type DashScopeReranker struct {
+	Endpoint string
+	Model    string
+	APIKey   string
+	Client   *httpx.Client
+}
+
+type dashScopeRerankReq struct {
+	Model string `json:"model"`
+	Input struct {
+		Query     string   `json:"query"`
+		Documents []string `json:"documents"`
+	} `json:"input"`
+	Parameters struct {
+		ReturnDocuments bool `json:"return_documents"`
+		TopN            int  `json:"top_n,omitempty"`
+	} `json:"parameters"`
+}
+
+type dashScopeRerankResp struct {
+	Output struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	} `json:"output"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func (d *DashScopeReranker) Rerank(ctx context.Context, query string, in []schema.SearchResult, topN int) ([]schema.SearchResult, error) {
+	passthrough := func() ([]schema.SearchResult, error) {
+		if topN > 0 && len(in) > topN {
+			return append([]schema.SearchResult(nil), in[:topN]...), nil
+		}
+		return in, nil
+	}
+	if d.APIKey == "" || len(in) == 0 {
+		return passthrough()
+	}
+
+	endpoint := d.Endpoint
+	if endpoint == "" {
+		endpoint = DASHSCOPE_RERANK_ENDPOINT
+	}
+	reqBody := dashScopeRerankReq{Model: d.Model}
+	if reqBody.Model == "" {
+		reqBody.Model = DASHSCOPE_RERANK_MODEL
+	}
+	reqBody.Input.Query = query
+	reqBody.Input.Documents = make([]string, len(in))
+	for i, result := range in {
+		reqBody.Input.Documents[i] = result.Document.Content
+	}
+	reqBody.Parameters.TopN = topN
+
+	bs, _ := json.Marshal(reqBody)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(bs))
+	if err != nil {
+		logger.Warnf("DashScopeReranker: failed to create request: %v", err)
+		return passthrough()
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", d.APIKey))
+
+	if d.Client == nil {
+		d.Client = httpx.NewFromConfig(nil)
+	}
+	resp, err := d.Client.Do(httpReq)
+	if err != nil {
+		logger.Warnf("DashScopeReranker: request failed: %v, using original order", err)
+		return passthrough()
+	}
+	defer resp.Body.Close()
+
+	var rerankResp dashScopeRerankResp
+	decodeErr := json.NewDecoder(resp.Body).Decode(&rerankResp)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		logger.Warnf("DashScopeReranker: server returned status %d (%s: %s), using original order", resp.StatusCode, rerankResp.Code, rerankResp.Message)
+		return passthrough()
+	}
+	if decodeErr != nil {
+		logger.Warnf("DashScopeReranker: failed to decode response: %v", decodeErr)
+		return passthrough()
+	}
+	if len(rerankResp.Output.Results) == 0 {
+		logger.Warnf("DashScopeReranker: empty results, using original order")
+		return passthrough()
+	}
+
+	out := make([]schema.SearchResult, 0, len(rerankResp.Output.Results))
+	for _, result := range rerankResp.Output.Results {
+		if result.Index >= 0 && result.Index < len(in) {
+			doc := in[result.Index]
+			doc.Score = result.RelevanceScore
+			out = append(out, doc)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	if topN > 0 && len(out) > topN {
+		out = out[:topN]
+	}
+	return out, nil
+}
@@ -0,0 +1,89 @@
+package post
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+func dashScopeInput() []schema.SearchResult {
+	return []schema.SearchResult{
+		{Document: schema.Document{ID: "a", Content: "higress routes traffic"}, Score: 0.9},
+		{Document: schema.Document{ID: "b", Content: "wasm plugins"}, Score: 0.8},
+		{Document: schema.Document{ID: "c", Content: "higress gateway"}, Score: 0.7},
+	}
+}
+
+func TestDashScopeReranker_Rerank(t *testing.T) {
+	var got dashScopeRerankReq
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer sk-test" {
+			t.Errorf("Authorization = %q, want the API key", auth)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"output":{"results":[{"index":2,"relevance_score":0.95},{"index":0,"relevance_score":0.6}]},"request_id":"r1"}`))
+	}))
+	defer srv.Close()
+
+	rr, err := NewReranker("dashscope", RerankerOptions{Endpoint: srv.URL, APIKey: "sk-test"})
+	if err != nil {
+		t.Fatalf("NewReranker() error = %v", err)
+	}
+	if _, ok := rr.(*DashScopeReranker); !ok {
+		t.Fatalf("NewReranker() = %T, want *DashScopeReranker", rr)
+	}
+	out, err := rr.Rerank(context.Background(), "higress", dashScopeInput(), 2)
+	if err != nil {
+		t.Fatalf("Rerank() error = %v", err)
+	}
+
+	if got.Model != DASHSCOPE_RERANK_MODEL || got.Input.Query != "higress" || got.Parameters.TopN != 2 {
+		t.Errorf("request = %+v, want the default model, query and top_n", got)
+	}
+	if want := []string{"higress routes traffic", "wasm plugins", "higress gateway"}; !reflect.DeepEqual(got.Input.Documents, want) {
+		t.Errorf("request documents = %v, want %v", got.Input.Documents, want)
+	}
+	if len(out) != 2 || out[0].Document.ID != "c" || out[0].Score != 0.95 || out[1].Document.ID != "a" {
+		t.Errorf("Rerank() = %+v, want c then a with relevance scores", out)
+	}
+}
+
+func TestDashScopeReranker_Passthrough(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"code":"InvalidApiKey","message":"Invalid API-key provided."}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		reranker *DashScopeReranker
+		calls    int32
+	}{
+		{"http error", &DashScopeReranker{Endpoint: srv.URL, APIKey: "sk-bad"}, 1},
+		{"no api key", &DashScopeReranker{Endpoint: srv.URL}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			out, err := tt.reranker.Rerank(context.Background(), "higress", dashScopeInput(), 2)
+			if err != nil {
+				t.Fatalf("Rerank() error = %v", err)
+			}
+			if len(out) != 2 || out[0].Document.ID != "a" || out[1].Document.ID != "b" {
+				t.Errorf("Rerank() = %+v, want the first 2 results in original order", out)
+			}
+			if calls.Load() != tt.calls {
+				t.Errorf("server called %d times, want %d", calls.Load(), tt.calls)
+			}
+		})
+	}
+}
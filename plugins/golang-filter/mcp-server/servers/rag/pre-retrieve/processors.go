@@ -1,10 +1,21 @@
 package pre_retrieve
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
 
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/cache"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/httpx"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/keywords"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/logger"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/embedding"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
@@ -86,13 +97,22 @@ type DefaultContextAlignmentProcessor struct {
 	config                   *config.ContextAlignmentConfig
 	llmProvider              llm.Provider
 	anchorCandidateRetriever AnchorCandidateRetriever
+	entityExtractor          EntityExtractor
+	// prompts 渲染代词消解与时间归一化提示词，nil 时使用内置提示词
+	prompts *llm.PromptRegistry
 }
 
-func NewContextAlignmentProcessor(cfg *config.ContextAlignmentConfig, llmProvider llm.Provider, anchorRetriever AnchorCandidateRetriever) ContextAlignmentProcessor {
+func NewContextAlignmentProcessor(cfg *config.ContextAlignmentConfig, llmProvider llm.Provider, anchorRetriever AnchorCandidateRetriever, prompts *llm.PromptRegistry) ContextAlignmentProcessor {
+	var entityLLM llm.Provider
+	if cfg.EnableEntityLLM {
+		entityLLM = llmProvider
+	}
 	return &DefaultContextAlignmentProcessor{
 		config:                   cfg,
 		llmProvider:              llmProvider,
 		anchorCandidateRetriever: anchorRetriever,
+		entityExtractor:          NewEntityExtractor(entityLLM),
+		prompts:                  prompts,
 	}
 }
 
@@ -112,7 +132,7 @@ func (p *DefaultContextAlignmentProcessor) Process(ctx context.Context, queryCtx
 	alignedQuery.AlignmentOps = append(alignedQuery.AlignmentOps, ops...)
 
 	// 锚点候选和裁决
-	if p.config.EnableAnchor && p.anchorCandidateRetriever != nil {
+	if p.config.EnableAnchor {
 		anchors, err := p.retrieveAndDecideAnchors(ctx, queryCtx, integratedQuery)
 		if err == nil {
 			alignedQuery.Anchors = anchors
@@ -155,16 +175,10 @@ func (p *DefaultContextAlignmentProcessor) resolvePronounsWithLLM(ctx context.Co
 		history.WriteString(fmt.Sprintf("Q%d: %s\nA%d: %s\n", i+1, round.Question, i+1, round.Answer))
 	}
 
-	prompt := fmt.Sprintf(`Based on the conversation history, resolve any pronouns or ambiguous references in the current query to make it self-contained.
-
-Conversation History:
-%s
-
-Current Query: %s
-
-Please rewrite the query to be self-contained without pronouns or unclear references. Only output the rewritten query, no explanations.
-
-Rewritten Query:`, history.String(), queryCtx.Query)
+	prompt, err := p.prompts.Render(PROMPT_PRONOUN_RESOLUTION, map[string]any{"History": history.String(), "Query": queryCtx.Query})
+	if err != nil {
+		return queryCtx.Query, err
+	}
 
 	resolved, err := p.llmProvider.GenerateCompletion(ctx, prompt)
 	if err != nil {
@@ -174,15 +188,10 @@ Rewritten Query:`, history.String(), queryCtx.Query)
 }
 
 func (p *DefaultContextAlignmentProcessor) normalizeTimeWithLLM(ctx context.Context, query string) (string, error) {
-	prompt := fmt.Sprintf(`Normalize any relative time expressions in the query to absolute or standardized forms.
-
-Query: %s
-
-If there are relative time expressions (like "yesterday", "last week", "recently"), convert them to more specific or absolute forms. If there are no time expressions, return the original query unchanged.
-
-Only output the normalized query, no explanations.
-
-Normalized Query:`, query)
+	prompt, err := p.prompts.Render(PROMPT_TIME_NORMALIZATION, map[string]any{"Query": query})
+	if err != nil {
+		return query, err
+	}
 
 	normalized, err := p.llmProvider.GenerateCompletion(ctx, prompt)
 	if err != nil {
@@ -192,9 +201,28 @@ Normalized Query:`, query)
 }
 
 func (p *DefaultContextAlignmentProcessor) retrieveAndDecideAnchors(ctx context.Context, queryCtx *memory.QueryContext, alignedQuery string) ([]Anchor, error) {
-	candidates, err := p.anchorCandidateRetriever.RetrieveCandidates(ctx, queryCtx)
-	if err != nil {
-		return []Anchor{}, err
+	candidates := []Anchor{}
+
+	// 实体锚点：从对齐后的查询中抽取必须保留的实体，排在最前以免被截断
+	if p.entityExtractor != nil {
+		entities, err := p.entityExtractor.Extract(ctx, alignedQuery)
+		if err == nil && len(entities) > 0 {
+			candidates = append(candidates, Anchor{
+				ID:       "entities",
+				Score:    1.0,
+				Type:     "entity",
+				Content:  alignedQuery,
+				MustKeep: entities,
+			})
+		}
+	}
+
+	if p.anchorCandidateRetriever != nil {
+		retrieved, err := p.anchorCandidateRetriever.RetrieveCandidates(ctx, queryCtx)
+		if err != nil {
+			return candidates, err
+		}
+		candidates = append(candidates, retrieved...)
 	}
 
 	filtered := []Anchor{}
@@ -236,6 +264,231 @@ func (r *DefaultAnchorCandidateRetriever) RetrieveCandidates(ctx context.Context
 	return anchors, nil
 }
 
+// =============================================================================
+// Entity Extractor - 实体抽取
+// =============================================================================
+
+// EntityExtractor 从查询中抽取必须保留的实体
+type EntityExtractor interface {
+	Extract(ctx context.Context, query string) ([]string, error)
+}
+
+// DefaultEntityExtractor 基于规则的实体抽取器，可选 LLM 辅助
+type DefaultEntityExtractor struct {
+	llmProvider llm.Provider
+}
+
+// NewEntityExtractor 创建实体抽取器；llmProvider 为 nil 时仅使用规则
+func NewEntityExtractor(llmProvider llm.Provider) EntityExtractor {
+	return &DefaultEntityExtractor{llmProvider: llmProvider}
+}
+
+var (
+	// 引号内的字符串
+	quotedEntityPattern = regexp.MustCompile(`"([^"]+)"|“([^”]+)”|'([^']+)'|「([^」]+)」`)
+	// 版本号，如 v1.2.3、1.20
+	versionEntityPattern = regexp.MustCompile(`\b[vV]?\d+(?:\.\d+)+\b`)
+	// 标识符，如 JIRA-123、req_42、a1b2c3
+	idEntityPattern = regexp.MustCompile(`\b[A-Za-z][A-Za-z0-9]*(?:[-_][A-Za-z0-9]+)+\b|\b(?:[A-Za-z]+\d+|\d+[A-Za-z]+)[A-Za-z0-9]*\b`)
+	// 连续的首字母大写词或全大写缩写，如 Higress Gateway、API
+	capitalizedEntityPattern = regexp.MustCompile(`\b[A-Z][A-Za-z0-9]*(?:\s+[A-Z][A-Za-z0-9]*)*\b`)
+)
+
+// entityStopwords 句首常见的首字母大写词，不视为实体
+var entityStopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "what": true, "which": true, "who": true, "whom": true,
+	"where": true, "when": true, "why": true, "how": true, "is": true, "are": true, "was": true,
+	"were": true, "do": true, "does": true, "did": true, "can": true, "could": true, "should": true,
+	"would": true, "will": true, "please": true, "tell": true, "show": true, "list": true,
+	"explain": true, "describe": true, "compare": true, "i": true, "my": true, "in": true,
+	"on": true, "for": true, "and": true, "or": true, "with": true, "to": true, "of": true,
+}
+
+func (e *DefaultEntityExtractor) Extract(ctx context.Context, query string) ([]string, error) {
+	entities := []string{}
+	seen := map[string]bool{}
+	add := func(entity string) {
+		entity = strings.TrimSpace(entity)
+		key := strings.ToLower(entity)
+		if entity == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		entities = append(entities, entity)
+	}
+
+	// 1. 引号内容整体保留，并从后续规则中剔除，避免重复拆分
+	rest := query
+	for _, m := range quotedEntityPattern.FindAllStringSubmatch(query, -1) {
+		for _, group := range m[1:] {
+			add(group)
+		}
+		rest = strings.Replace(rest, m[0], " ", 1)
+	}
+
+	// 2. 版本号与标识符，同样从后续规则中剔除
+	for _, pattern := range []*regexp.Regexp{versionEntityPattern, idEntityPattern} {
+		for _, m := range pattern.FindAllString(rest, -1) {
+			add(m)
+		}
+		rest = pattern.ReplaceAllString(rest, " ")
+	}
+
+	// 3. 首字母大写的专有名词，去掉首尾的停用词
+	for _, m := range capitalizedEntityPattern.FindAllString(rest, -1) {
+		words := strings.Fields(m)
+		for len(words) > 0 && entityStopwords[strings.ToLower(words[0])] {
+			words = words[1:]
+		}
+		for len(words) > 0 && entityStopwords[strings.ToLower(words[len(words)-1])] {
+			words = words[:len(words)-1]
+		}
+		if len(words) > 0 {
+			add(strings.Join(words, " "))
+		}
+	}
+
+	// 4. LLM 辅助抽取（可选）
+	if e.llmProvider != nil {
+		llmEntities, err := e.extractWithLLM(ctx, query)
+		if err == nil {
+			for _, entity := range llmEntities {
+				// 只保留查询中原样出现的实体，防止 LLM 臆造
+				if strings.Contains(strings.ToLower(query), strings.ToLower(entity)) {
+					add(entity)
+				}
+			}
+		}
+	}
+
+	return entities, nil
+}
+
+func (e *DefaultEntityExtractor) extractWithLLM(ctx context.Context, query string) ([]string, error) {
+	prompt := fmt.Sprintf(`Extract the named entities from the query that must be preserved exactly during rewriting, such as product names, people, organizations, identifiers and version numbers.
+
+Query: %s
+
+Output one entity per line exactly as it appears in the query, no explanations. Output nothing if there are no entities.
+
+Entities:`, query)
+
+	response, err := e.llmProvider.GenerateCompletion(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []string{}
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*"))
+		if line != "" {
+			entities = append(entities, line)
+		}
+	}
+	return entities, nil
+}
+
+// =============================================================================
+// Translation Processor - 语言检测与翻译
+// =============================================================================
+
+// TranslationProcessor 检测查询语言，并在与语料语言不一致时翻译查询
+type TranslationProcessor interface {
+	Process(ctx context.Context, alignedQuery *AlignedQuery) (*AlignedQuery, error)
+}
+
+// DefaultTranslationProcessor 默认翻译处理器，使用 LLM 翻译
+type DefaultTranslationProcessor struct {
+	config      *config.TranslationConfig
+	llmProvider llm.Provider
+}
+
+func NewTranslationProcessor(cfg *config.TranslationConfig, llmProvider llm.Provider) TranslationProcessor {
+	return &DefaultTranslationProcessor{
+		config:      cfg,
+		llmProvider: llmProvider,
+	}
+}
+
+// languageNames 翻译提示词中使用的语言名称
+var languageNames = map[string]string{
+	"en": "English",
+	"zh": "Chinese",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"ru": "Russian",
+}
+
+// DetectLanguage 按文字体系判断查询语言；假名优先判定为日文，其余取占比最高的文字，
+// 没有可识别的字母时返回空字符串。表意文字按字计数，拼音文字按词计数，
+// 使夹杂英文产品名的中文查询仍判定为中文
+func DetectLanguage(text string) string {
+	counts := map[string]int{}
+	inWord := false
+	for _, r := range text {
+		latin := unicode.Is(unicode.Latin, r)
+		cyrillic := unicode.Is(unicode.Cyrillic, r)
+		wordStart := !inWord
+		inWord = latin || cyrillic
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			counts["ja"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case unicode.Is(unicode.Han, r):
+			counts["zh"]++
+		case cyrillic && wordStart:
+			counts["ru"]++
+		case latin && wordStart:
+			counts["en"]++
+		}
+	}
+	if counts["ja"] > 0 {
+		return "ja"
+	}
+	best, bestCount := "", 0
+	for _, lang := range []string{"zh", "ko", "ru", "en"} {
+		if counts[lang] > bestCount {
+			best, bestCount = lang, counts[lang]
+		}
+	}
+	return best
+}
+
+func (p *DefaultTranslationProcessor) Process(ctx context.Context, alignedQuery *AlignedQuery) (*AlignedQuery, error) {
+	alignedQuery.Language = DetectLanguage(alignedQuery.Query)
+	target := strings.ToLower(strings.TrimSpace(p.config.TargetLanguage))
+	if !p.config.Enabled || p.llmProvider == nil || target == "" || alignedQuery.Language == "" || alignedQuery.Language == target {
+		return alignedQuery, nil
+	}
+
+	targetName := languageNames[target]
+	if targetName == "" {
+		targetName = target
+	}
+	prompt := fmt.Sprintf(`Translate the following search query into %s. Keep product names, code identifiers and numbers unchanged.
+
+Query: %s
+
+Only output the translated query, no explanations.
+
+Translated Query:`, targetName, alignedQuery.Query)
+
+	translated, err := p.llmProvider.GenerateCompletion(ctx, prompt)
+	if err != nil {
+		return alignedQuery, fmt.Errorf("translate query failed: %w", err)
+	}
+	translated = strings.TrimSpace(translated)
+	if translated == "" {
+		return alignedQuery, fmt.Errorf("translate query failed: empty translation")
+	}
+
+	alignedQuery.OriginalQuery = alignedQuery.Query
+	alignedQuery.Query = translated
+	alignedQuery.AlignmentOps = append(alignedQuery.AlignmentOps, fmt.Sprintf("translate:%s->%s", alignedQuery.Language, target))
+	return alignedQuery, nil
+}
+
 // =============================================================================
 // PreQRAG Planner - 统一规划器
 // =============================================================================
@@ -249,13 +502,89 @@ type PreQRAGPlanner interface {
 type DefaultPreQRAGPlanner struct {
 	config      *config.PreQRAGPlanningConfig
 	llmProvider llm.Provider
+	// decisions 缓存先验判定与子问题分解结果，未配置 TTL 时为 nil
+	decisions cache.Cache
+	// keywords 从稀疏改写中提取关键词，未配置 pipeline.keywords 时为 nil
+	keywords *keywords.Normalizer
+	// prompts 渲染规范化、判定、分解与通道改写提示词，nil 时使用内置提示词
+	prompts *llm.PromptRegistry
 }
 
-func NewPreQRAGPlanner(cfg *config.PreQRAGPlanningConfig, llmProvider llm.Provider) PreQRAGPlanner {
-	return &DefaultPreQRAGPlanner{
+// plannerDecision 是一次先验判定与分解的结果，subQueries 为截断前的分解结果
+type plannerDecision struct {
+	cardinality CardinalityType
+	subQueries  []string
+}
+
+func NewPreQRAGPlanner(cfg *config.PreQRAGPlanningConfig, llmProvider llm.Provider, prompts *llm.PromptRegistry) PreQRAGPlanner {
+	planner := &DefaultPreQRAGPlanner{
 		config:      cfg,
 		llmProvider: llmProvider,
+		prompts:     prompts,
+	}
+	if cfg.CacheTTLSeconds > 0 {
+		planner.decisions = cache.NewLRU(cfg.CacheMaxEntries, time.Duration(cfg.CacheTTLSeconds)*time.Second)
+	}
+	if cfg.Keywords != nil {
+		normalizer, err := keywords.New(cfg.Keywords)
+		if err != nil {
+			logger.Warnf("pre-retrieve: %v, using built-in stopwords", err)
+			normalizer = keywords.NewNormalizer(keywords.DefaultStopwords(), cfg.Keywords.Stem)
+		}
+		planner.keywords = normalizer
+	}
+	return planner
+}
+
+// decisionKey 由规范化后的查询和必须保留的锚点词组成：锚点会改变规范化结果，
+// 相同查询在不同锚点下不能共用判定
+func decisionKey(normalizedQuery string, alignedQuery *AlignedQuery) string {
+	mustKeep := []string{}
+	for _, anchor := range alignedQuery.Anchors {
+		mustKeep = append(mustKeep, anchor.MustKeep...)
 	}
+	sort.Strings(mustKeep)
+	normalized := strings.Join(strings.Fields(strings.ToLower(normalizedQuery)), " ")
+	return normalized + "|" + strings.Join(mustKeep, ",")
+}
+
+// decide 执行先验判定与子问题分解；仅当全部 LLM 调用成功时缓存结果
+func (p *DefaultPreQRAGPlanner) decide(ctx context.Context, normalizedQuery string, alignedQuery *AlignedQuery) plannerDecision {
+	key := decisionKey(normalizedQuery, alignedQuery)
+	if p.decisions != nil {
+		if cached, ok := p.decisions.Get(key); ok {
+			decision := cached.(plannerDecision)
+			decision.subQueries = append([]string(nil), decision.subQueries...)
+			return decision
+		}
+	}
+
+	decision := plannerDecision{cardinality: CardinalityUnknown}
+	cacheable := true
+	if p.config.EnableCardinalityPrior && p.llmProvider != nil {
+		cardinality, err := p.determineCardinality(ctx, normalizedQuery, alignedQuery)
+		if err == nil {
+			decision.cardinality = cardinality
+		} else {
+			cacheable = false
+		}
+	}
+	if p.config.EnableDecomposition && decision.cardinality == CardinalityMulti && p.llmProvider != nil {
+		decomposed, err := p.decomposeQuery(ctx, normalizedQuery, alignedQuery)
+		if err == nil && len(decomposed) > 0 {
+			decision.subQueries = decomposed
+		} else if err != nil {
+			cacheable = false
+		}
+	}
+
+	if cacheable && p.decisions != nil {
+		p.decisions.Set(key, plannerDecision{
+			cardinality: decision.cardinality,
+			subQueries:  append([]string(nil), decision.subQueries...),
+		}, 0)
+	}
+	return decision
 }
 
 func (p *DefaultPreQRAGPlanner) Plan(ctx context.Context, alignedQuery *AlignedQuery) (*PreQRAGPlan, error) {
@@ -280,24 +609,12 @@ func (p *DefaultPreQRAGPlanner) Plan(ctx context.Context, alignedQuery *AlignedQ
 		}
 	}
 
-	// 2. 单/多文档先验判定
-	if p.config.EnableCardinalityPrior && p.llmProvider != nil {
-		cardinality, err := p.determineCardinality(ctx, normalizedQuery, alignedQuery)
-		if err == nil {
-			plan.CardinalityPrior = cardinality
-		}
-	}
-
-	// 3. 子问题分解
-	var subQueries []string
-	if p.config.EnableDecomposition && plan.CardinalityPrior == CardinalityMulti && p.llmProvider != nil {
-		decomposed, err := p.decomposeQuery(ctx, normalizedQuery, alignedQuery)
-		if err == nil && len(decomposed) > 0 {
-			subQueries = decomposed
-			if p.config.MaxSubQueries > 0 && len(subQueries) > p.config.MaxSubQueries {
-				subQueries = subQueries[:p.config.MaxSubQueries]
-			}
-		}
+	// 2. 单/多文档先验判定 与 3. 子问题分解（命中缓存时跳过 LLM 调用）
+	decision := p.decide(ctx, normalizedQuery, alignedQuery)
+	plan.CardinalityPrior = decision.cardinality
+	subQueries := decision.subQueries
+	if p.config.MaxSubQueries > 0 && len(subQueries) > p.config.MaxSubQueries {
+		subQueries = subQueries[:p.config.MaxSubQueries]
 	}
 
 	if len(subQueries) == 0 {
@@ -323,6 +640,7 @@ func (p *DefaultPreQRAGPlanner) Plan(ctx context.Context, alignedQuery *AlignedQ
 				node.DenseRewrite = dense
 			}
 		}
+		node.SparseRewrite = p.sparseKeywords(node.SparseRewrite)
 
 		plan.Nodes = append(plan.Nodes, node)
 	}
@@ -341,12 +659,28 @@ func (p *DefaultPreQRAGPlanner) Plan(ctx context.Context, alignedQuery *AlignedQ
 	return plan, nil
 }
 
+// sparseKeywordMinLength 是稀疏改写中非中文关键词的最小长度（字符数）
+const sparseKeywordMinLength = 2
+
+// sparseKeywords 将稀疏改写缩减为去除停用词（并可选词干化）后的关键词，便于 BM25 词法匹配；
+// 未配置关键词提取或未提取到关键词时原样返回
+func (p *DefaultPreQRAGPlanner) sparseKeywords(rewrite string) string {
+	if p.keywords == nil {
+		return rewrite
+	}
+	terms := p.keywords.Keywords(rewrite, sparseKeywordMinLength)
+	if len(terms) == 0 {
+		return rewrite
+	}
+	return strings.Join(terms, " ")
+}
+
 func (p *DefaultPreQRAGPlanner) createSimplePlan(alignedQuery *AlignedQuery) *PreQRAGPlan {
 	return &PreQRAGPlan{
 		Nodes: []QueryNode{{
 			ID:            "node_0",
 			Query:         alignedQuery.Query,
-			SparseRewrite: alignedQuery.Query,
+			SparseRewrite: p.sparseKeywords(alignedQuery.Query),
 			DenseRewrite:  alignedQuery.Query,
 		}},
 		JoinStrategy:     "union",
@@ -360,24 +694,11 @@ func (p *DefaultPreQRAGPlanner) normalize(ctx context.Context, alignedQuery *Ali
 		mustKeepTerms = append(mustKeepTerms, anchor.MustKeep...)
 	}
 
-	mustKeepStr := ""
-	if len(mustKeepTerms) > 0 {
-		mustKeepStr = fmt.Sprintf("\nIMPORTANT: Must preserve these terms exactly: %s", strings.Join(mustKeepTerms, ", "))
+	prompt, err := p.prompts.Render(PROMPT_QUERY_NORMALIZATION, map[string]any{"Query": alignedQuery.Query, "MustKeep": mustKeepTerms})
+	if err != nil {
+		return alignedQuery.Query, []string{}, err
 	}
 
-	prompt := fmt.Sprintf(`Normalize the query by:
-1. Standardizing terminology and units
-2. Normalizing time expressions
-3. Correcting negations
-4. Fixing typos and grammar
-%s
-
-Query: %s
-
-Output only the normalized query, no explanations.
-
-Normalized Query:`, mustKeepStr, alignedQuery.Query)
-
 	normalized, err := p.llmProvider.GenerateCompletion(ctx, prompt)
 	if err != nil {
 		return alignedQuery.Query, []string{}, err
@@ -387,18 +708,10 @@ Normalized Query:`, mustKeepStr, alignedQuery.Query)
 }
 
 func (p *DefaultPreQRAGPlanner) determineCardinality(ctx context.Context, query string, alignedQuery *AlignedQuery) (CardinalityType, error) {
-	prompt := fmt.Sprintf(`Analyze the query and determine if it requires information from a single document or multiple documents.
-
-Query: %s
-
-Consider:
-- Does it contain conjunctions like "and", "or", "compare"?
-- Does it ask for multiple entities or concepts?
-- Is it a comparison question?
-
-Answer with only one word: "single" or "multi"
-
-Answer:`, query)
+	prompt, err := p.prompts.Render(PROMPT_CARDINALITY, map[string]any{"Query": query})
+	if err != nil {
+		return CardinalityUnknown, err
+	}
 
 	response, err := p.llmProvider.GenerateCompletion(ctx, prompt)
 	if err != nil {
@@ -416,21 +729,10 @@ Answer:`, query)
 }
 
 func (p *DefaultPreQRAGPlanner) decomposeQuery(ctx context.Context, query string, alignedQuery *AlignedQuery) ([]string, error) {
-	prompt := fmt.Sprintf(`Decompose the complex query into 1-3 independent sub-queries that can be searched separately.
-
-Query: %s
-
-Requirements:
-- Each sub-query should be self-contained
-- Sub-queries should be independent and can be executed in parallel
-- If the query is simple and cannot be decomposed, return only the original query
-
-Output format (one sub-query per line):
-1. [first sub-query]
-2. [second sub-query]
-3. [third sub-query]
-
-Sub-queries:`, query)
+	prompt, err := p.prompts.Render(PROMPT_DECOMPOSITION, map[string]any{"Query": query})
+	if err != nil {
+		return []string{query}, err
+	}
 
 	response, err := p.llmProvider.GenerateCompletion(ctx, prompt)
 	if err != nil {
@@ -459,15 +761,10 @@ Sub-queries:`, query)
 }
 
 func (p *DefaultPreQRAGPlanner) channelRewrite(ctx context.Context, query string, alignedQuery *AlignedQuery) (string, string, error) {
-	sparsePrompt := fmt.Sprintf(`Rewrite the query for sparse retrieval (BM25/keyword search):
-- Use explicit keywords and terms
-- Expand abbreviations
-- Include synonyms where appropriate
-- Make it keyword-rich for lexical matching
-
-Original Query: %s
-
-Sparse Rewrite:`, query)
+	sparsePrompt, err := p.prompts.Render(PROMPT_SPARSE_REWRITE, map[string]any{"Query": query})
+	if err != nil {
+		return query, query, err
+	}
 
 	sparseRewrite, err := p.llmProvider.GenerateCompletion(ctx, sparsePrompt)
 	if err != nil {
@@ -476,15 +773,10 @@ Sparse Rewrite:`, query)
 		sparseRewrite = strings.TrimSpace(sparseRewrite)
 	}
 
-	densePrompt := fmt.Sprintf(`Rewrite the query for dense retrieval (semantic search):
-- Make it semantically clear and concise
-- Focus on the core intent
-- Remove redundant words
-- Optimize for semantic similarity
-
-Original Query: %s
-
-Dense Rewrite:`, query)
+	densePrompt, err := p.prompts.Render(PROMPT_DENSE_REWRITE, map[string]any{"Query": query})
+	if err != nil {
+		return sparseRewrite, query, err
+	}
 
 	denseRewrite, err := p.llmProvider.GenerateCompletion(ctx, densePrompt)
 	if err != nil {
@@ -572,7 +864,15 @@ func (p *DefaultExpansionProcessor) Expand(ctx context.Context, plan *PreQRAGPla
 			}
 		}
 
-		// 限制扩展词数量
+		// 5. 提取属性对
+		if p.config.EnableAttributes {
+			expansion.Terms = append(expansion.Terms, p.extractAttributes(ctx, node)...)
+		}
+
+		// 6. 合并重复词项并归一化权重，按权重降序排列
+		expansion.Terms = p.normalizeTerms(expansion.Terms)
+
+		// 限制扩展词数量，保留权重最高的词项
 		if p.config.MaxTerms > 0 && len(expansion.Terms) > p.config.MaxTerms {
 			expansion.Terms = expansion.Terms[:p.config.MaxTerms]
 		}
@@ -583,6 +883,74 @@ func (p *DefaultExpansionProcessor) Expand(ctx context.Context, plan *PreQRAGPla
 	return expansions, nil
 }
 
+const (
+	defaultExpansionMinWeight = 0.1
+	defaultExpansionMaxWeight = 1.0
+)
+
+// normalizeTerms 按词项（忽略大小写）合并重复项，再按最大权重等比缩放到
+// [MinWeight, MaxWeight] 区间，最后按权重降序稳定排序
+func (p *DefaultExpansionProcessor) normalizeTerms(terms []ExpansionTerm) []ExpansionTerm {
+	if len(terms) == 0 {
+		return terms
+	}
+
+	// 合并重复词项：max 保留权重最高的来源，sum 累加权重
+	merged := make([]ExpansionTerm, 0, len(terms))
+	index := make(map[string]int, len(terms))
+	for _, term := range terms {
+		key := strings.ToLower(strings.TrimSpace(term.Term))
+		if key == "" {
+			continue
+		}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, term)
+			continue
+		}
+		if p.config.MergeStrategy == "sum" {
+			merged[i].Weight += term.Weight
+		} else if term.Weight > merged[i].Weight {
+			merged[i] = term
+		}
+	}
+
+	minWeight := p.config.MinWeight
+	if minWeight <= 0 {
+		minWeight = defaultExpansionMinWeight
+	}
+	maxWeight := p.config.MaxWeight
+	if maxWeight <= 0 {
+		maxWeight = defaultExpansionMaxWeight
+	}
+	if minWeight > maxWeight {
+		minWeight = maxWeight
+	}
+
+	top := 0.0
+	for _, term := range merged {
+		if term.Weight > top {
+			top = term.Weight
+		}
+	}
+	for i := range merged {
+		weight := maxWeight
+		if top > 0 {
+			weight = merged[i].Weight / top * maxWeight
+		}
+		if weight < minWeight {
+			weight = minWeight
+		}
+		merged[i].Weight = weight
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Weight > merged[j].Weight
+	})
+	return merged
+}
+
 func (p *DefaultExpansionProcessor) generateExpansionWithLLM(ctx context.Context, node QueryNode) ([]ExpansionTerm, error) {
 	prompt := fmt.Sprintf(`Generate 3-6 expansion terms for sparse retrieval (BM25) of the following query.
 
@@ -644,6 +1012,92 @@ Expansion Terms:`, node.SparseRewrite)
 	return terms, nil
 }
 
+// 属性对匹配规则：显式的 key: value / key=value，以及版本号 (v1.2, version 1.2.3)
+var (
+	attributePairPattern = regexp.MustCompile(`(?i)\b([a-z][\w-]*)\s*[:=]\s*("[^"]+"|'[^']+'|[\w./-]+)`)
+	versionPattern       = regexp.MustCompile(`(?i)\b(?:version\s+|v)(\d+(?:\.\d+)+)\b`)
+)
+
+// extractAttributes 提取与查询相关的 key: value 属性对；规则提取的权重高于 LLM 提取
+func (p *DefaultExpansionProcessor) extractAttributes(ctx context.Context, node QueryNode) []ExpansionTerm {
+	terms := []ExpansionTerm{}
+	seen := make(map[string]bool)
+	add := func(key, value string, weight float64, source string) {
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		if key == "" || value == "" {
+			return
+		}
+		term := key + ": " + value
+		if seen[strings.ToLower(term)] {
+			return
+		}
+		seen[strings.ToLower(term)] = true
+		terms = append(terms, ExpansionTerm{Term: term, Weight: weight, Facet: "attribute", Source: source})
+	}
+
+	for _, m := range attributePairPattern.FindAllStringSubmatch(node.Query, -1) {
+		add(m[1], m[2], 0.9, "attribute")
+	}
+	for _, m := range versionPattern.FindAllStringSubmatch(node.Query, -1) {
+		add("version", m[1], 0.9, "attribute")
+	}
+
+	if p.llmProvider != nil {
+		llmTerms, err := p.generateAttributesWithLLM(ctx, node)
+		if err == nil {
+			for _, t := range llmTerms {
+				parts := strings.SplitN(t.Term, ":", 2)
+				if len(parts) == 2 {
+					add(parts[0], parts[1], t.Weight, "llm")
+				}
+			}
+		}
+	}
+	return terms
+}
+
+func (p *DefaultExpansionProcessor) generateAttributesWithLLM(ctx context.Context, node QueryNode) ([]ExpansionTerm, error) {
+	prompt := fmt.Sprintf(`Extract attribute key:value pairs that constrain the following query (e.g. product, version, color, region, os).
+Only output attributes that are stated or clearly implied by the query. Output nothing if there are none.
+
+Query: %s
+
+Output format (one attribute per line with weight 0.5-1.0):
+key: value | weight
+
+Example:
+version: 1.2 | 0.9
+color: red | 0.8
+
+Attributes:`, node.Query)
+
+	response, err := p.llmProvider.GenerateCompletion(ctx, prompt)
+	if err != nil {
+		return []ExpansionTerm{}, err
+	}
+
+	terms := []ExpansionTerm{}
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || !strings.Contains(line, ":") {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		weight := 0.7
+		if len(parts) >= 2 {
+			fmt.Sscanf(strings.TrimSpace(parts[1]), "%f", &weight)
+		}
+		terms = append(terms, ExpansionTerm{
+			Term:   strings.TrimSpace(parts[0]),
+			Weight: weight,
+			Facet:  "attribute",
+			Source: "llm",
+		})
+	}
+	return terms, nil
+}
+
 func (p *DefaultExpansionProcessor) getFromTaxonomy(ctx context.Context, query string) ([]ExpansionTerm, error) {
 	words := strings.Fields(query)
 	allTerms := []ExpansionTerm{}
@@ -736,14 +1190,30 @@ type DefaultHyDEProcessor struct {
 	config            *config.HyDEConfig
 	llmProvider       llm.Provider
 	embeddingProvider embedding.Provider
+	// HTTPClient 用于调用困惑度/NLI 打分服务
+	HTTPClient *httpx.Client
+	// prompts 渲染假设文档提示词，nil 时使用内置提示词
+	prompts *llm.PromptRegistry
 }
 
-func NewHyDEProcessor(cfg *config.HyDEConfig, llmProvider llm.Provider, embeddingProvider embedding.Provider) HyDEProcessor {
-	return &DefaultHyDEProcessor{
+const (
+	// defaultMaxPerplexity 困惑度打分服务的默认上限
+	defaultMaxPerplexity = 50.0
+	// defaultMinEntailment NLI 打分服务的默认蕴含概率下限
+	defaultMinEntailment = 0.5
+)
+
+func NewHyDEProcessor(cfg *config.HyDEConfig, llmProvider llm.Provider, embeddingProvider embedding.Provider, prompts *llm.PromptRegistry) HyDEProcessor {
+	processor := &DefaultHyDEProcessor{
 		config:            cfg,
 		llmProvider:       llmProvider,
 		embeddingProvider: embeddingProvider,
+		prompts:           prompts,
 	}
+	if cfg.PerplexityEndpoint != "" || cfg.NLIEndpoint != "" {
+		processor.HTTPClient = httpx.NewFromConfig(nil)
+	}
+	return processor
 }
 
 func (p *DefaultHyDEProcessor) Generate(ctx context.Context, plan *PreQRAGPlan, alignedQuery *AlignedQuery) (map[string]HyDEVector, error) {
@@ -763,13 +1233,17 @@ func (p *DefaultHyDEProcessor) Generate(ctx context.Context, plan *PreQRAGPlan,
 			continue
 		}
 
-		vector, err := p.embeddingProvider.GetEmbedding(ctx, hypotheticalDoc)
-		if err != nil {
+		// 先过护栏再向量化，未通过的假设文档不参与检索
+		qualityScore := p.calculateQualityScore(ctx, hypotheticalDoc, node.Query)
+		if !p.passGuardrails(ctx, hypotheticalDoc, node.Query, qualityScore) {
+			continue
+		}
+		if p.config.MinQualityScore > 0 && qualityScore < p.config.MinQualityScore {
 			continue
 		}
 
-		qualityScore := p.calculateQualityScore(ctx, hypotheticalDoc, node.Query)
-		if !p.passGuardrails(ctx, hypotheticalDoc, node.Query, qualityScore) {
+		vector, err := p.embeddingProvider.GetEmbedding(ctx, hypotheticalDoc)
+		if err != nil {
 			continue
 		}
 
@@ -798,18 +1272,10 @@ func (p *DefaultHyDEProcessor) generateHypotheticalDocument(ctx context.Context,
 		targetLength = 120
 	}
 
-	prompt := fmt.Sprintf(`Generate a hypothetical document passage that would be highly relevant to answering the following query.
-
-Query: %s
-
-Requirements:
-- The passage should be %d-150 words
-- Write as if it's an excerpt from a relevant document
-- Include specific details and terminology
-- Make it informative and directly relevant to the query
-- Do not include phrases like "This document discusses..." - write the content directly
-
-Hypothetical Document:`, node.DenseRewrite, targetLength)
+	prompt, err := p.prompts.Render(PROMPT_HYDE, map[string]any{"Query": node.DenseRewrite, "TargetLength": targetLength})
+	if err != nil {
+		return "", err
+	}
 
 	doc, err := p.llmProvider.GenerateCompletion(ctx, prompt)
 	if err != nil {
@@ -842,16 +1308,86 @@ func (p *DefaultHyDEProcessor) calculateQualityScore(ctx context.Context, hypoth
 }
 
 func (p *DefaultHyDEProcessor) passGuardrails(ctx context.Context, hypotheticalDoc string, originalQuery string, qualityScore float64) bool {
-	if p.config.EnablePerplexityCheck && qualityScore < 0.4 {
-		return false
+	if p.config.EnablePerplexityCheck {
+		if p.config.PerplexityEndpoint != "" {
+			if !p.passPerplexity(ctx, hypotheticalDoc) {
+				return false
+			}
+		} else if qualityScore < 0.4 {
+			return false
+		}
 	}
 
 	if p.config.EnableNLIGuardrail {
-		words := strings.Fields(hypotheticalDoc)
-		if len(words) < 30 || len(words) > 300 {
-			return false
+		if p.config.NLIEndpoint != "" {
+			if !p.passNLI(ctx, hypotheticalDoc, originalQuery) {
+				return false
+			}
+		} else {
+			words := strings.Fields(hypotheticalDoc)
+			if len(words) < 30 || len(words) > 300 {
+				return false
+			}
 		}
 	}
 
 	return true
 }
+
+// passPerplexity 调用困惑度服务：请求 {"text": doc}，响应 {"perplexity": 12.3}
+// 服务不可用时视为未通过，避免放行未经校验的假设文档
+func (p *DefaultHyDEProcessor) passPerplexity(ctx context.Context, hypotheticalDoc string) bool {
+	var resp struct {
+		Perplexity *float64 `json:"perplexity"`
+	}
+	if err := p.postScore(ctx, p.config.PerplexityEndpoint, map[string]string{"text": hypotheticalDoc}, &resp); err != nil || resp.Perplexity == nil {
+		return false
+	}
+	limit := p.config.MaxPerplexity
+	if limit <= 0 {
+		limit = defaultMaxPerplexity
+	}
+	return *resp.Perplexity <= limit
+}
+
+// passNLI 调用 NLI 服务：请求 {"premise": doc, "hypothesis": query}，响应 {"entailment": 0.87}
+func (p *DefaultHyDEProcessor) passNLI(ctx context.Context, hypotheticalDoc string, originalQuery string) bool {
+	var resp struct {
+		Entailment *float64 `json:"entailment"`
+	}
+	body := map[string]string{"premise": hypotheticalDoc, "hypothesis": originalQuery}
+	if err := p.postScore(ctx, p.config.NLIEndpoint, body, &resp); err != nil || resp.Entailment == nil {
+		return false
+	}
+	limit := p.config.MinEntailment
+	if limit <= 0 {
+		limit = defaultMinEntailment
+	}
+	return *resp.Entailment >= limit
+}
+
+// postScore 通过共享的 httpx.Client 以 JSON 调用打分服务
+func (p *DefaultHyDEProcessor) postScore(ctx context.Context, endpoint string, body any, out any) error {
+	client := p.HTTPClient
+	if client == nil {
+		client = httpx.NewFromConfig(nil)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("guardrail endpoint %s returned status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
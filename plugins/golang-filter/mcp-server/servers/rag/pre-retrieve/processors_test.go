@@ -0,0 +1,518 @@
+package pre_retrieve
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/memory"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// mockCommonCAPI 屏蔽 envoy 日志，使 httpx 可以在 envoy 之外运行
+type mockCommonCAPI struct{}
+
+func (m *mockCommonCAPI) Log(level api.LogType, message string) {}
+
+func (m *mockCommonCAPI) LogLevel() api.LogType { return api.Error }
+
+type mockLLMProvider struct {
+	respond func(prompt string) string
+}
+
+func (m *mockLLMProvider) GetProviderType() string { return "mock" }
+
+func (m *mockLLMProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	return m.respond(prompt), nil
+}
+
+func (m *mockLLMProvider) GenerateChat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	return m.respond(llm.ConcatMessages(messages)), nil
+}
+
+func attributeTerms(expansion QueryExpansion) []string {
+	var terms []string
+	for _, term := range expansion.Terms {
+		if term.Facet == "attribute" {
+			terms = append(terms, term.Term)
+		}
+	}
+	return terms
+}
+
+func TestExpand_AttributesFacet(t *testing.T) {
+	llmProvider := &mockLLMProvider{respond: func(prompt string) string {
+		if strings.Contains(prompt, "Extract attribute") {
+			return "region: cn-hangzhou | 0.8\nVersion: 1.2 | 0.9\nnot an attribute"
+		}
+		return "gateway | 0.9 | technology"
+	}}
+	plan := &PreQRAGPlan{Nodes: []QueryNode{{ID: "n1", Query: "install higress v1.2 with os=linux", SparseRewrite: "install higress"}}}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    []string
+	}{
+		{name: "disabled", enabled: false, want: nil},
+		{name: "enabled", enabled: true, want: []string{"os: linux", "version: 1.2", "region: cn-hangzhou"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewExpansionProcessor(&config.ExpansionConfig{Enabled: true, EnableAttributes: tt.enabled}, llmProvider, nil)
+			expansions, err := processor.Expand(context.Background(), plan, &AlignedQuery{})
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			got := attributeTerms(expansions["n1"])
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("attribute terms = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpand_AttributesRespectMaxTerms(t *testing.T) {
+	processor := NewExpansionProcessor(&config.ExpansionConfig{Enabled: true, EnableAttributes: true, MaxTerms: 2}, nil, nil)
+	plan := &PreQRAGPlan{Nodes: []QueryNode{{ID: "n1", Query: "color: red size=xl version 2.0.1"}}}
+	expansions, err := processor.Expand(context.Background(), plan, &AlignedQuery{Anchors: []Anchor{{MustKeep: []string{"shirt"}}}})
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	terms := expansions["n1"].Terms
+	if len(terms) != 2 {
+		t.Fatalf("Expand() returned %d terms, want MaxTerms=2", len(terms))
+	}
+	// weights are normalized against the anchor weight (1.5)
+	if terms[0].Facet != "anchor" || terms[1].Term != "color: red" || math.Abs(terms[1].Weight-0.6) > 1e-9 {
+		t.Errorf("terms = %+v, want anchor followed by the first attribute", terms)
+	}
+}
+
+type mockTaxonomyProvider struct {
+	related  map[string][]string
+	synonyms map[string][]string
+}
+
+func (m *mockTaxonomyProvider) GetRelatedTerms(ctx context.Context, term string) ([]string, error) {
+	return m.related[term], nil
+}
+
+func (m *mockTaxonomyProvider) GetSynonyms(ctx context.Context, term string) ([]string, error) {
+	return m.synonyms[term], nil
+}
+
+func TestExpand_NormalizesAndMergesTerms(t *testing.T) {
+	llmProvider := &mockLLMProvider{respond: func(prompt string) string {
+		return "Gateway | 0.9 | technology\nproxy | 0.5 | concept\ningress | 0.3 | concept"
+	}}
+	taxonomy := &mockTaxonomyProvider{
+		related:  map[string][]string{"gateway": {"gateway", "envoy"}},
+		synonyms: map[string][]string{"api": {"proxy"}},
+	}
+	plan := &PreQRAGPlan{Nodes: []QueryNode{{ID: "n1", Query: "api gateway", SparseRewrite: "api gateway"}}}
+	aligned := &AlignedQuery{Anchors: []Anchor{{MustKeep: []string{"Higress"}}}}
+
+	tests := []struct {
+		name     string
+		strategy string
+		maxTerms int
+		want     []string
+		weights  map[string]float64
+	}{
+		{
+			name:    "max merge",
+			want:    []string{"Higress", "Gateway", "proxy", "envoy", "ingress"},
+			weights: map[string]float64{"Higress": 1.0, "Gateway": 0.6, "proxy": 0.8 / 1.5, "envoy": 0.4, "ingress": 0.2},
+		},
+		{
+			name:     "sum merge",
+			strategy: "sum",
+			want:     []string{"Higress", "Gateway", "proxy", "envoy", "ingress"},
+			weights:  map[string]float64{"Gateway": 1.0, "Higress": 1.0, "proxy": 1.3 / 1.5, "envoy": 0.4, "ingress": 0.2},
+		},
+		{
+			name:     "keeps top weighted terms",
+			maxTerms: 2,
+			want:     []string{"Higress", "Gateway"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.ExpansionConfig{Enabled: true, EnableTaxonomy: true, EnableSynonyms: true, MaxTerms: tt.maxTerms, MergeStrategy: tt.strategy}
+			expansions, err := NewExpansionProcessor(cfg, llmProvider, taxonomy).Expand(context.Background(), plan, aligned)
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			var got []string
+			for _, term := range expansions["n1"].Terms {
+				got = append(got, term.Term)
+				if want, ok := tt.weights[term.Term]; ok && math.Abs(term.Weight-want) > 1e-9 {
+					t.Errorf("weight(%s) = %v, want %v", term.Term, term.Weight, want)
+				}
+				if term.Weight < 0.1 || term.Weight > 1.0 {
+					t.Errorf("weight(%s) = %v outside [0.1, 1.0]", term.Term, term.Weight)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("terms = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntityExtractor_Rules(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "quoted", query: `how to enable "request timeout" in plugins`, want: []string{"request timeout"}},
+		{name: "version", query: "upgrade from v1.2.3 to 2.0", want: []string{"v1.2.3", "2.0"}},
+		{name: "identifier", query: "status of ticket JIRA-123 and build b42", want: []string{"JIRA-123", "b42"}},
+		{name: "capitalized", query: "What is the difference between Higress Gateway and Nginx Ingress?", want: []string{"Higress Gateway", "Nginx Ingress"}},
+		{name: "plain", query: "how do i configure rate limiting", want: []string{}},
+	}
+	extractor := NewEntityExtractor(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractor.Extract(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Extract() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntityExtractor_LLMAssisted(t *testing.T) {
+	llmProvider := &mockLLMProvider{respond: func(prompt string) string {
+		return "- wasm plugin\ninvented entity"
+	}}
+	got, err := NewEntityExtractor(llmProvider).Extract(context.Background(), "build a wasm plugin for Higress")
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if want := "Higress,wasm plugin"; strings.Join(got, ",") != want {
+		t.Errorf("Extract() = %v, want %s", got, want)
+	}
+}
+
+func TestPlan_NormalizationPreservesEntities(t *testing.T) {
+	var normalizePrompt string
+	llmProvider := &mockLLMProvider{respond: func(prompt string) string {
+		if strings.Contains(prompt, "Normalize the query") {
+			normalizePrompt = prompt
+		}
+		return "normalized"
+	}}
+	alignment := NewContextAlignmentProcessor(&config.ContextAlignmentConfig{Enabled: true, EnableAnchor: true, MaxAnchors: 2}, llmProvider, NewDefaultAnchorCandidateRetriever(), nil)
+	aligned, err := alignment.Process(context.Background(), &memory.QueryContext{Query: `configure "key-auth" for Higress v2.1`, DocIDs: []string{"doc-1"}})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(aligned.Anchors) != 2 || aligned.Anchors[0].Type != "entity" {
+		t.Fatalf("anchors = %+v, want entity anchor followed by document anchor", aligned.Anchors)
+	}
+
+	planner := NewPreQRAGPlanner(&config.PreQRAGPlanningConfig{Enabled: true, EnableNormalization: true}, llmProvider, nil)
+	if _, err := planner.Plan(context.Background(), aligned); err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if !strings.Contains(normalizePrompt, "Must preserve these terms exactly: key-auth, v2.1, Higress") {
+		t.Errorf("normalization prompt does not list extracted entities:\n%s", normalizePrompt)
+	}
+}
+
+func TestPlan_PromptOverrides(t *testing.T) {
+	prompts, err := llm.NewPromptRegistry(&config.PromptsConfig{Templates: map[string]string{
+		PROMPT_QUERY_NORMALIZATION: "规范化查询：{{.Query}}{{if .MustKeep}}，保留：{{join .MustKeep \"、\"}}{{end}}",
+		PROMPT_DENSE_REWRITE:       "语义改写：{{.Query}}",
+	}})
+	if err != nil {
+		t.Fatalf("NewPromptRegistry() error = %v", err)
+	}
+	var seen []string
+	llmProvider := &mockLLMProvider{respond: func(prompt string) string {
+		seen = append(seen, prompt)
+		return "网关插件"
+	}}
+	planner := NewPreQRAGPlanner(&config.PreQRAGPlanningConfig{Enabled: true, EnableNormalization: true, EnableChannelRewrite: true}, llmProvider, prompts)
+	if _, err := planner.Plan(context.Background(), &AlignedQuery{Query: "怎么配置插件", Anchors: []Anchor{{MustKeep: []string{"key-auth"}}}}); err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(seen) != 3 {
+		t.Fatalf("prompts = %q, want normalization, sparse and dense rewrite", seen)
+	}
+	if seen[0] != "规范化查询：怎么配置插件，保留：key-auth" || seen[2] != "语义改写：网关插件" {
+		t.Errorf("overridden prompts = %q", seen)
+	}
+	if !strings.Contains(seen[1], "Rewrite the query for sparse retrieval") {
+		t.Errorf("sparse rewrite should keep the built-in prompt, got %q", seen[1])
+	}
+}
+
+func TestPlan_CachesCardinalityAndDecomposition(t *testing.T) {
+	var cardinalityCalls, decomposeCalls int
+	llmProvider := &mockLLMProvider{respond: func(prompt string) string {
+		switch {
+		case strings.Contains(prompt, "single document or multiple documents"):
+			cardinalityCalls++
+			return "multi"
+		case strings.Contains(prompt, "Decompose the complex query"):
+			decomposeCalls++
+			return "1. higress install\n2. higress upgrade"
+		}
+		return ""
+	}}
+	planner := NewPreQRAGPlanner(&config.PreQRAGPlanningConfig{
+		Enabled:                true,
+		EnableCardinalityPrior: true,
+		EnableDecomposition:    true,
+		MaxSubQueries:          2,
+		CacheTTLSeconds:        60,
+	}, llmProvider, nil)
+
+	plan := func(query string, mustKeep ...string) *PreQRAGPlan {
+		aligned := &AlignedQuery{Query: query}
+		if len(mustKeep) > 0 {
+			aligned.Anchors = []Anchor{{ID: "a1", MustKeep: mustKeep}}
+		}
+		result, err := planner.Plan(context.Background(), aligned)
+		if err != nil {
+			t.Fatalf("Plan() error = %v", err)
+		}
+		return result
+	}
+
+	first := plan("install and upgrade higress")
+	second := plan("Install and  upgrade Higress")
+	if cardinalityCalls != 1 || decomposeCalls != 1 {
+		t.Fatalf("cardinality calls = %d, decompose calls = %d, want 1 each", cardinalityCalls, decomposeCalls)
+	}
+	if second.CardinalityPrior != CardinalityMulti || len(second.Nodes) != len(first.Nodes) || second.Nodes[1].Query != "higress upgrade" {
+		t.Errorf("cached plan = %+v, want %+v", second, first)
+	}
+
+	plan("install and upgrade higress", "v2.1")
+	if cardinalityCalls != 2 {
+		t.Errorf("cardinality calls = %d, want a new decision when must-keep terms differ", cardinalityCalls)
+	}
+}
+
+// newScoreServer 返回固定打分的 httptest 服务，并记录收到的请求体
+func newScoreServer(t *testing.T, response string, got *map[string]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got != nil {
+			_ = json.NewDecoder(r.Body).Decode(got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+}
+
+func TestPlan_SparseRewriteKeywords(t *testing.T) {
+	query := &AlignedQuery{Query: "How do I configure the routes of 网关的路由?"}
+
+	planner := NewPreQRAGPlanner(&config.PreQRAGPlanningConfig{Enabled: true}, nil, nil)
+	plan, err := planner.Plan(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if plan.Nodes[0].SparseRewrite != query.Query {
+		t.Errorf("sparse rewrite without keywords config = %q, want the query", plan.Nodes[0].SparseRewrite)
+	}
+
+	planner = NewPreQRAGPlanner(&config.PreQRAGPlanningConfig{Enabled: true, Keywords: &config.KeywordConfig{Stem: true}}, nil, nil)
+	plan, err = planner.Plan(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if got, want := plan.Nodes[0].SparseRewrite, "configur rout 网关 路由"; got != want {
+		t.Errorf("sparse rewrite = %q, want %q", got, want)
+	}
+	if plan.Nodes[0].DenseRewrite != query.Query {
+		t.Errorf("dense rewrite = %q, want the query", plan.Nodes[0].DenseRewrite)
+	}
+}
+
+func TestHyDEGuardrails_PerplexityEndpoint(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	doc := "Higress is a cloud native gateway."
+
+	var got map[string]string
+	pass := newScoreServer(t, `{"perplexity": 12.5}`, &got)
+	defer pass.Close()
+	fail := newScoreServer(t, `{"perplexity": 180}`, nil)
+	defer fail.Close()
+
+	cfg := &config.HyDEConfig{EnablePerplexityCheck: true, PerplexityEndpoint: pass.URL, MaxPerplexity: 40}
+	p := NewHyDEProcessor(cfg, nil, nil, nil).(*DefaultHyDEProcessor)
+	// 质量分低于启发式阈值，但打分服务判定通过
+	if !p.passGuardrails(context.Background(), doc, "what is higress", 0.1) {
+		t.Error("passGuardrails() = false, want fluent document accepted by the endpoint")
+	}
+	if got["text"] != doc {
+		t.Errorf("request = %v, want the hypothetical document as text", got)
+	}
+
+	cfg.PerplexityEndpoint = fail.URL
+	if p.passGuardrails(context.Background(), doc, "what is higress", 0.9) {
+		t.Error("passGuardrails() = true, want rejection when perplexity exceeds the limit")
+	}
+}
+
+func TestHyDEGuardrails_NLIEndpoint(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	doc := "Higress supports wasm plugins."
+
+	var got map[string]string
+	pass := newScoreServer(t, `{"entailment": 0.91}`, &got)
+	defer pass.Close()
+	fail := newScoreServer(t, `{"entailment": 0.2}`, nil)
+	defer fail.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model unavailable", http.StatusBadRequest)
+	}))
+	defer broken.Close()
+
+	cfg := &config.HyDEConfig{EnableNLIGuardrail: true, NLIEndpoint: pass.URL}
+	p := NewHyDEProcessor(cfg, nil, nil, nil).(*DefaultHyDEProcessor)
+	// 文档过短会被启发式规则拒绝，配置服务后以服务打分为准
+	if !p.passGuardrails(context.Background(), doc, "higress plugins", 0.9) {
+		t.Error("passGuardrails() = false, want entailed document accepted")
+	}
+	if got["premise"] != doc || got["hypothesis"] != "higress plugins" {
+		t.Errorf("request = %v, want premise/hypothesis pair", got)
+	}
+
+	cfg.NLIEndpoint = fail.URL
+	if p.passGuardrails(context.Background(), doc, "higress plugins", 0.9) {
+		t.Error("passGuardrails() = true, want rejection below min entailment")
+	}
+
+	cfg.NLIEndpoint = broken.URL
+	if p.passGuardrails(context.Background(), doc, "higress plugins", 0.9) {
+		t.Error("passGuardrails() = true, want rejection when the endpoint fails")
+	}
+}
+
+func TestHyDEGuardrails_HeuristicFallback(t *testing.T) {
+	cfg := &config.HyDEConfig{EnablePerplexityCheck: true, EnableNLIGuardrail: true}
+	p := NewHyDEProcessor(cfg, nil, nil, nil).(*DefaultHyDEProcessor)
+	longDoc := strings.Repeat("gateway ", 40)
+
+	if p.passGuardrails(context.Background(), longDoc, "q", 0.3) {
+		t.Error("passGuardrails() = true, want low quality score rejected without endpoint")
+	}
+	if p.passGuardrails(context.Background(), "too short", "q", 0.9) {
+		t.Error("passGuardrails() = true, want short document rejected without endpoint")
+	}
+	if !p.passGuardrails(context.Background(), longDoc, "q", 0.9) {
+		t.Error("passGuardrails() = false, want heuristics to pass")
+	}
+}
+
+// countingEmbedder 记录向量化的文本
+type countingEmbedder struct{ texts []string }
+
+func (e *countingEmbedder) GetProviderType() string { return "counting" }
+
+func (e *countingEmbedder) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	e.texts = append(e.texts, text)
+	return []float32{1, 0}, nil
+}
+
+func (e *countingEmbedder) GetDimensions(ctx context.Context) (int, error) { return 2, nil }
+
+func TestHyDEGenerate_MinQualityScore(t *testing.T) {
+	llmProvider := &mockLLMProvider{respond: func(prompt string) string {
+		if strings.Contains(prompt, "higress plugin") {
+			return "Higress plugin development uses Wasm modules."
+		}
+		return "Unrelated text."
+	}}
+	embedder := &countingEmbedder{}
+	cfg := &config.HyDEConfig{Enabled: true, MinQueryLength: 100, MinQualityScore: 0.6}
+	p := NewHyDEProcessor(cfg, llmProvider, embedder, nil)
+	plan := &PreQRAGPlan{Nodes: []QueryNode{
+		{ID: "n1", Query: "higress plugin", DenseRewrite: "higress plugin"},
+		{ID: "n2", Query: "route timeout", DenseRewrite: "route timeout"},
+	}}
+
+	vectors, err := p.Generate(context.Background(), plan, &AlignedQuery{})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(vectors) != 1 || vectors["n1"].QualityScore < 0.6 || len(embedder.texts) != 1 {
+		t.Errorf("Generate() = %+v after embedding %q, want only n1 embedded", vectors, embedder.texts)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := map[string]string{
+		"how to configure higress plugins": "en",
+		"如何配置 Higress 插件":                  "zh",
+		"Higress のプラグイン設定":                 "ja",
+		"플러그인 설정 방법":                       "ko",
+		"как настроить плагин":             "ru",
+		"12345 ?!":                         "",
+	}
+	for text, want := range tests {
+		if got := DetectLanguage(text); got != want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestTranslation_OnlyOnLanguageMismatch(t *testing.T) {
+	calls := 0
+	llmProvider := &mockLLMProvider{respond: func(prompt string) string {
+		calls++
+		if !strings.Contains(prompt, "into English") {
+			t.Errorf("prompt = %q, want English as the target", prompt)
+		}
+		return "how to configure higress plugins\n"
+	}}
+	processor := NewTranslationProcessor(&config.TranslationConfig{Enabled: true, TargetLanguage: "en"}, llmProvider)
+
+	same, err := processor.Process(context.Background(), &AlignedQuery{Query: "how to configure higress plugins"})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if calls != 0 || same.OriginalQuery != "" || same.Language != "en" {
+		t.Errorf("Process() = %+v after %d LLM calls, want no translation for a matching language", same, calls)
+	}
+
+	translated, err := processor.Process(context.Background(), &AlignedQuery{Query: "如何配置 Higress 插件"})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if calls != 1 || translated.Query != "how to configure higress plugins" || translated.OriginalQuery != "如何配置 Higress 插件" {
+		t.Errorf("Process() = %+v after %d LLM calls, want the query translated and the original kept", translated, calls)
+	}
+	if translated.Language != "zh" || len(translated.AlignmentOps) != 1 || translated.AlignmentOps[0] != "translate:zh->en" {
+		t.Errorf("language = %q ops = %v, want zh and a translate op", translated.Language, translated.AlignmentOps)
+	}
+}
+
+func TestTranslation_Disabled(t *testing.T) {
+	llmProvider := &mockLLMProvider{respond: func(prompt string) string {
+		t.Error("LLM must not be called when translation is disabled")
+		return ""
+	}}
+	processor := NewTranslationProcessor(&config.TranslationConfig{TargetLanguage: "en"}, llmProvider)
+	got, err := processor.Process(context.Background(), &AlignedQuery{Query: "如何配置插件"})
+	if err != nil || got.Query != "如何配置插件" || got.Language != "zh" {
+		t.Errorf("Process() = %+v, %v, want the query untouched with its language tagged", got, err)
+	}
+}
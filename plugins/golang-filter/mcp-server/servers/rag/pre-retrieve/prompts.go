@@ -0,0 +1,125 @@
+package pre_retrieve
+
+import "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+
+// Pre-Retrieve 各阶段提示词模板名称，可通过 pipeline.prompts 覆盖
+const (
+	PROMPT_PRONOUN_RESOLUTION  = "pronoun_resolution"
+	PROMPT_TIME_NORMALIZATION  = "time_normalization"
+	PROMPT_QUERY_NORMALIZATION = "query_normalization"
+	PROMPT_CARDINALITY         = "cardinality"
+	PROMPT_DECOMPOSITION       = "decomposition"
+	PROMPT_SPARSE_REWRITE      = "sparse_rewrite"
+	PROMPT_DENSE_REWRITE       = "dense_rewrite"
+	PROMPT_HYDE                = "hyde"
+)
+
+const pronounResolutionPrompt = `Based on the conversation history, resolve any pronouns or ambiguous references in the current query to make it self-contained.
+
+Conversation History:
+{{.History}}
+
+Current Query: {{.Query}}
+
+Please rewrite the query to be self-contained without pronouns or unclear references. Only output the rewritten query, no explanations.
+
+Rewritten Query:`
+
+const timeNormalizationPrompt = `Normalize any relative time expressions in the query to absolute or standardized forms.
+
+Query: {{.Query}}
+
+If there are relative time expressions (like "yesterday", "last week", "recently"), convert them to more specific or absolute forms. If there are no time expressions, return the original query unchanged.
+
+Only output the normalized query, no explanations.
+
+Normalized Query:`
+
+const queryNormalizationPrompt = `Normalize the query by:
+1. Standardizing terminology and units
+2. Normalizing time expressions
+3. Correcting negations
+4. Fixing typos and grammar
+{{if .MustKeep}}
+IMPORTANT: Must preserve these terms exactly: {{join .MustKeep ", "}}{{end}}
+
+Query: {{.Query}}
+
+Output only the normalized query, no explanations.
+
+Normalized Query:`
+
+const cardinalityPrompt = `Analyze the query and determine if it requires information from a single document or multiple documents.
+
+Query: {{.Query}}
+
+Consider:
+- Does it contain conjunctions like "and", "or", "compare"?
+- Does it ask for multiple entities or concepts?
+- Is it a comparison question?
+
+Answer with only one word: "single" or "multi"
+
+Answer:`
+
+const decompositionPrompt = `Decompose the complex query into 1-3 independent sub-queries that can be searched separately.
+
+Query: {{.Query}}
+
+Requirements:
+- Each sub-query should be self-contained
+- Sub-queries should be independent and can be executed in parallel
+- If the query is simple and cannot be decomposed, return only the original query
+
+Output format (one sub-query per line):
+1. [first sub-query]
+2. [second sub-query]
+3. [third sub-query]
+
+Sub-queries:`
+
+const sparseRewritePrompt = `Rewrite the query for sparse retrieval (BM25/keyword search):
+- Use explicit keywords and terms
+- Expand abbreviations
+- Include synonyms where appropriate
+- Make it keyword-rich for lexical matching
+
+Original Query: {{.Query}}
+
+Sparse Rewrite:`
+
+const denseRewritePrompt = `Rewrite the query for dense retrieval (semantic search):
+- Make it semantically clear and concise
+- Focus on the core intent
+- Remove redundant words
+- Optimize for semantic similarity
+
+Original Query: {{.Query}}
+
+Dense Rewrite:`
+
+const hydePrompt = `Generate a hypothetical document passage that would be highly relevant to answering the following query.
+
+Query: {{.Query}}
+
+Requirements:
+- The passage should be {{.TargetLength}}-150 words
+- Write as if it's an excerpt from a relevant document
+- Include specific details and terminology
+- Make it informative and directly relevant to the query
+- Do not include phrases like "This document discusses..." - write the content directly
+
+Hypothetical Document:`
+
+func init() {
+	llm.RegisterPrompt(PROMPT_PRONOUN_RESOLUTION, llm.PromptSpec{Default: pronounResolutionPrompt, Fields: []string{"History", "Query"}, Required: []string{"History", "Query"}})
+	llm.RegisterPrompt(PROMPT_TIME_NORMALIZATION, llm.PromptSpec{Default: timeNormalizationPrompt, Fields: []string{"Query"}, Required: []string{"Query"}})
+	// MustKeep 为锚点中必须原样保留的词，可能为空
+	llm.RegisterPrompt(PROMPT_QUERY_NORMALIZATION, llm.PromptSpec{Default: queryNormalizationPrompt, Fields: []string{"Query", "MustKeep"}, Required: []string{"Query"}})
+	llm.RegisterPrompt(PROMPT_CARDINALITY, llm.PromptSpec{Default: cardinalityPrompt, Fields: []string{"Query"}, Required: []string{"Query"}})
+	llm.RegisterPrompt(PROMPT_DECOMPOSITION, llm.PromptSpec{Default: decompositionPrompt, Fields: []string{"Query"}, Required: []string{"Query"}})
+	llm.RegisterPrompt(PROMPT_SPARSE_REWRITE, llm.PromptSpec{Default: sparseRewritePrompt, Fields: []string{"Query"}, Required: []string{"Query"}})
+	llm.RegisterPrompt(PROMPT_DENSE_REWRITE, llm.PromptSpec{Default: denseRewritePrompt, Fields: []string{"Query"}, Required: []string{"Query"}})
+	// TargetLength 为配置的假设文档目标词数
+	llm.RegisterPrompt(PROMPT_HYDE, llm.PromptSpec{Default: hydePrompt, Fields: []string{"Query", "TargetLength"}, Required: []string{"Query"}})
+}
@@ -27,7 +27,7 @@ type Provider interface {
 }
 
 // DefaultPreRetrieveProvider 默认 Pre-Retrieve Provider 实现
-// 整合所有阶段：Memory Intake -> Context Alignment -> PreQRAG Planning -> Expansion -> HyDE
+// 整合所有阶段：Memory Intake -> Context Alignment -> Translation -> PreQRAG Planning -> Expansion -> HyDE
 type DefaultPreRetrieveProvider struct {
 	providerType string
 	config       *config.PreRetrieveConfig
@@ -35,6 +35,7 @@ type DefaultPreRetrieveProvider struct {
 	// 各个阶段的处理器
 	memoryProcessor    MemoryIntakeProcessor
 	alignmentProcessor ContextAlignmentProcessor
+	translator         TranslationProcessor
 	planner            PreQRAGPlanner
 	expansionProcessor ExpansionProcessor
 	hydeProcessor      HyDEProcessor
@@ -63,6 +64,14 @@ func (p *DefaultPreRetrieveProvider) Process(ctx context.Context, rawQuery strin
 	if err != nil {
 		return nil, fmt.Errorf("context alignment failed: %w", err)
 	}
+
+	// 阶段 2.5: Translation - 语言检测与翻译（可选），失败时保留原查询
+	if p.translator != nil {
+		translated, err := p.translator.Process(ctx, alignedQuery)
+		if err == nil {
+			alignedQuery = translated
+		}
+	}
 	result.AlignedQuery = *alignedQuery
 
 	// 阶段 3: PreQRAG Planning - 统一规划
@@ -107,9 +116,12 @@ func (i *PreRetrieveInitializer) ValidateConfig(cfg *config.PreRetrieveConfig) e
 		return fmt.Errorf("provider type is required")
 	}
 
-	needLLM := cfg.Alignment.Enabled || cfg.Planning.Enabled || cfg.Expansion.Enabled || cfg.HyDE.Enabled
+	needLLM := cfg.Alignment.Enabled || cfg.Planning.Enabled || cfg.Expansion.Enabled || cfg.HyDE.Enabled || cfg.Translation.Enabled
 	if needLLM && cfg.LLM.Provider == "" {
-		return fmt.Errorf("LLM provider is required when alignment/planning/expansion/hyde is enabled")
+		return fmt.Errorf("LLM provider is required when alignment/planning/expansion/hyde/translation is enabled")
+	}
+	if cfg.Translation.Enabled && cfg.Translation.TargetLanguage == "" {
+		return fmt.Errorf("translation target_language is required when translation is enabled")
 	}
 
 	if cfg.HyDE.Enabled {
@@ -145,23 +157,36 @@ func (i *PreRetrieveInitializer) CreateProvider(cfg *config.PreRetrieveConfig) (
 		}
 	}
 
-	// 创建 Embedding Provider（如果 HyDE 启用）
+	// 创建 Embedding Provider（如果 HyDE 启用），与检索使用同一全局 embedding 配置
 	var embeddingProvider embedding.Provider
-	if cfg.HyDE.Enabled {
-		// 注意：这里需要从外部传入或配置中获取 embedding config
-		// 暂时留空，实际使用时需要补充
+	if cfg.HyDE.Enabled && cfg.Embedding.Provider != "" {
+		embeddingProvider, err = embedding.NewEmbeddingProvider(cfg.Embedding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HyDE embedding provider: %w", err)
+		}
+	}
+
+	// 提示词模板覆盖
+	prompts, err := llm.NewPromptRegistry(cfg.Prompts)
+	if err != nil {
+		return nil, err
 	}
 
 	// 1. Memory Intake Processor
-	sessionStore := memory.NewInMemorySessionStore(cfg.Memory.LastNRounds)
+	sessionStore := memory.NewInMemoryConversationStoreWithTTL(cfg.Memory.LastNRounds, time.Duration(cfg.Memory.SessionTTLSeconds)*time.Second)
 	provider.memoryProcessor = NewMemoryIntakeProcessor(&cfg.Memory, sessionStore, nil)
 
 	// 2. Context Alignment Processor
 	anchorRetriever := NewDefaultAnchorCandidateRetriever()
-	provider.alignmentProcessor = NewContextAlignmentProcessor(&cfg.Alignment, llmProvider, anchorRetriever)
+	provider.alignmentProcessor = NewContextAlignmentProcessor(&cfg.Alignment, llmProvider, anchorRetriever, prompts)
+
+	// 2.5 Translation Processor（可选）
+	if cfg.Translation.Enabled {
+		provider.translator = NewTranslationProcessor(&cfg.Translation, llmProvider)
+	}
 
 	// 3. PreQRAG Planner
-	provider.planner = NewPreQRAGPlanner(&cfg.Planning, llmProvider)
+	provider.planner = NewPreQRAGPlanner(&cfg.Planning, llmProvider, prompts)
 
 	// 4. Expansion Processor（可选）
 	if cfg.Expansion.Enabled {
@@ -171,7 +196,7 @@ func (i *PreRetrieveInitializer) CreateProvider(cfg *config.PreRetrieveConfig) (
 
 	// 5. HyDE Processor（可选）
 	if cfg.HyDE.Enabled && embeddingProvider != nil {
-		provider.hydeProcessor = NewHyDEProcessor(&cfg.HyDE, llmProvider, embeddingProvider)
+		provider.hydeProcessor = NewHyDEProcessor(&cfg.HyDE, llmProvider, embeddingProvider, prompts)
 	}
 
 	return provider, nil
@@ -24,6 +24,10 @@ type AlignedQuery struct {
 	Anchors []Anchor `json:"anchors,omitempty"`
 	// 对齐操作记录
 	AlignmentOps []string `json:"alignment_ops,omitempty"`
+	// 检测到的查询语言（en、zh、ja、ko、ru，无法判断时为空）
+	Language string `json:"language,omitempty"`
+	// 翻译前的查询，仅在发生翻译时设置，供稀疏检索使用原文匹配
+	OriginalQuery string `json:"original_query,omitempty"`
 }
 
 // CardinalityType 文档数量先验类型
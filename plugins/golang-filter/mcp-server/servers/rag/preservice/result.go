@@ -0,0 +1,154 @@
+package preservice
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/memory"
+	pre_retrieve "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/pre-retrieve"
+	precontractv1 "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/proto/precontract/v1"
+)
+
+// ToPreRetrieveResult maps a preprocessor response onto the structures produced by the
+// built-in pre-retrieve, so the pipeline consumes both alike. Each decomposition task
+// becomes a plan node, with sequential edges from the tasks it depends on; without tasks
+// each query of SubQueries does. Entities become anchors of the aligned query, by descending salience.
+// It returns nil when the response yields no query.
+func ToPreRetrieveResult(req *precontractv1.PreprocessRequest, resp *precontractv1.PreprocessResponse) *pre_retrieve.PreRetrieveResult {
+	if resp == nil {
+		return nil
+	}
+	meta := resp.GetQueryMeta()
+	timestamp := time.Now()
+	if ms := meta.GetObservedAtMs(); ms > 0 {
+		timestamp = time.UnixMilli(ms)
+	}
+	query := req.GetQuery()
+	aligned := query
+	if normalized := strings.TrimSpace(meta.GetNormalized()); normalized != "" {
+		aligned = normalized
+	}
+
+	plan := pre_retrieve.PreQRAGPlan{JoinStrategy: "union", CardinalityPrior: pre_retrieve.CardinalitySingle}
+	// A task repeating an earlier query collapses into its node, dependencies included
+	nodeOf := make(map[string]string)
+	alias := make(map[string]string)
+	for i, task := range resp.GetDecomposition().GetTasks() {
+		text := strings.TrimSpace(task.GetQueryText())
+		id := task.GetTaskId()
+		if id == "" {
+			id = fmt.Sprintf("node_%d", i)
+		}
+		if text == "" {
+			continue
+		}
+		if existing, ok := nodeOf[text]; ok {
+			alias[id] = existing
+			continue
+		}
+		nodeOf[text] = id
+		plan.Nodes = append(plan.Nodes, pre_retrieve.QueryNode{
+			ID:            id,
+			Query:         text,
+			SparseRewrite: text,
+			DenseRewrite:  text,
+			Dependencies:  task.GetDependsOn(),
+		})
+	}
+	for i := range plan.Nodes {
+		node := &plan.Nodes[i]
+		var deps []string
+		for _, dep := range node.Dependencies {
+			if target, ok := alias[dep]; ok {
+				dep = target
+			}
+			if dep == node.ID || slices.Contains(deps, dep) {
+				continue
+			}
+			deps = append(deps, dep)
+			plan.Edges = append(plan.Edges, pre_retrieve.PlanEdge{From: dep, To: node.ID, Type: "sequential"})
+		}
+		node.Dependencies = deps
+	}
+	if len(plan.Nodes) == 0 {
+		sparse := sparseKeywords(resp)
+		for i, q := range SubQueries(resp) {
+			node := pre_retrieve.QueryNode{ID: fmt.Sprintf("node_%d", i), Query: q, SparseRewrite: q, DenseRewrite: q}
+			if sparse != "" {
+				node.SparseRewrite = sparse
+			}
+			plan.Nodes = append(plan.Nodes, node)
+		}
+	}
+	if len(plan.Nodes) == 0 {
+		return nil
+	}
+	if len(plan.Nodes) > 1 {
+		plan.CardinalityPrior = pre_retrieve.CardinalityMulti
+	}
+	for _, intent := range resp.GetIntents() {
+		if intent.GetRequiresMultiDoc() {
+			plan.CardinalityPrior = pre_retrieve.CardinalityMulti
+		}
+	}
+
+	return &pre_retrieve.PreRetrieveResult{
+		Context: memory.QueryContext{
+			Query:     query,
+			SessionID: req.GetSessionId(),
+			Timestamp: timestamp,
+		},
+		AlignedQuery: pre_retrieve.AlignedQuery{
+			Query:    aligned,
+			Anchors:  entityAnchors(resp.GetEntities()),
+			Language: meta.GetLanguage(),
+		},
+		Plan: plan,
+	}
+}
+
+// sparseKeywords returns the highest priority sparse keyword transformation, if any
+func sparseKeywords(resp *precontractv1.PreprocessResponse) string {
+	best := ""
+	bestPriority := int32(0)
+	for _, t := range resp.GetTransformations() {
+		text := strings.TrimSpace(t.GetText())
+		if t.GetType() != precontractv1.TransformationType_TRANSFORMATION_TYPE_SPARSE_KEYWORD || text == "" {
+			continue
+		}
+		if best == "" || t.GetPriority() > bestPriority {
+			best, bestPriority = text, t.GetPriority()
+		}
+	}
+	return best
+}
+
+// entityAnchors turns the recognized entities into anchors, most salient first
+func entityAnchors(entities []*precontractv1.Entity) []pre_retrieve.Anchor {
+	anchors := make([]pre_retrieve.Anchor, 0, len(entities))
+	for _, e := range entities {
+		content := e.GetCanonical()
+		if content == "" {
+			content = e.GetSurface()
+		}
+		if content == "" {
+			continue
+		}
+		id := e.GetKnowledgeId()
+		if id == "" {
+			id = content
+		}
+		anchor := pre_retrieve.Anchor{ID: id, Score: e.GetSalience(), Type: e.GetType(), Content: content}
+		if surface := e.GetSurface(); surface != "" {
+			anchor.MustKeep = []string{surface}
+		}
+		anchors = append(anchors, anchor)
+	}
+	sort.SliceStable(anchors, func(i, j int) bool {
+		return anchors[i].Score > anchors[j].Score
+	})
+	return anchors
+}
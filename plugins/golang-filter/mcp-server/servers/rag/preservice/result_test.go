@@ -0,0 +1,90 @@
+package preservice
+
+import (
+	"slices"
+	"testing"
+
+	pre_retrieve "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/pre-retrieve"
+	precontractv1 "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/proto/precontract/v1"
+)
+
+func TestToPreRetrieveResult_Decomposition(t *testing.T) {
+	req := &precontractv1.PreprocessRequest{Query: "Compare higress and envoy plugins", SessionId: "s1"}
+	resp := &precontractv1.PreprocessResponse{
+		QueryMeta: &precontractv1.QueryMeta{Normalized: "compare higress and envoy plugins", Language: "en", ObservedAtMs: 1700000000000},
+		Entities: []*precontractv1.Entity{
+			{Surface: "envoy", Canonical: "Envoy", Type: "product", Salience: 0.4},
+			{Surface: "higress", Canonical: "Higress", KnowledgeId: "kb:higress", Type: "product", Salience: 0.9},
+		},
+		Decomposition: &precontractv1.Decomposition{Tasks: []*precontractv1.Task{
+			{TaskId: "t1", QueryText: "higress wasm plugins"},
+			{TaskId: "t2", QueryText: "envoy lua filters"},
+			{TaskId: "t3", QueryText: "higress wasm plugins"},
+			{TaskId: "t4", QueryText: "compare both", DependsOn: []string{"t3", "t2"}},
+		}},
+	}
+
+	result := ToPreRetrieveResult(req, resp)
+	if result == nil {
+		t.Fatal("ToPreRetrieveResult() = nil")
+	}
+	if result.Context.Query != req.Query || result.Context.SessionID != "s1" || result.Context.Timestamp.UnixMilli() != 1700000000000 {
+		t.Errorf("context = %+v", result.Context)
+	}
+	if result.AlignedQuery.Query != "compare higress and envoy plugins" || result.AlignedQuery.Language != "en" {
+		t.Errorf("aligned query = %+v, want the normalized query", result.AlignedQuery)
+	}
+	if anchors := result.AlignedQuery.Anchors; len(anchors) != 2 || anchors[0].ID != "kb:higress" || anchors[1].ID != "Envoy" || !slices.Equal(anchors[0].MustKeep, []string{"higress"}) {
+		t.Errorf("anchors = %+v, want the entities by salience", anchors)
+	}
+
+	// The duplicate task t3 collapses into t1 and dependencies become sequential edges
+	plan := result.Plan
+	var ids []string
+	for _, node := range plan.Nodes {
+		ids = append(ids, node.ID)
+	}
+	if !slices.Equal(ids, []string{"t1", "t2", "t4"}) || !slices.Equal(plan.Nodes[2].Dependencies, []string{"t1", "t2"}) {
+		t.Errorf("plan nodes = %+v", plan.Nodes)
+	}
+	if len(plan.Edges) != 2 || plan.Edges[0] != (pre_retrieve.PlanEdge{From: "t1", To: "t4", Type: "sequential"}) {
+		t.Errorf("plan edges = %+v", plan.Edges)
+	}
+	if plan.CardinalityPrior != pre_retrieve.CardinalityMulti || plan.JoinStrategy != "union" {
+		t.Errorf("plan = %+v, want a multi-document union", plan)
+	}
+}
+
+func TestToPreRetrieveResult_Transformations(t *testing.T) {
+	req := &precontractv1.PreprocessRequest{Query: "higress plugins"}
+	resp := &precontractv1.PreprocessResponse{
+		Intents: []*precontractv1.IntentPrediction{{Intent: precontractv1.IntentClass_INTENT_CLASS_FACTOID}},
+		Transformations: []*precontractv1.QueryTransformation{
+			{Type: precontractv1.TransformationType_TRANSFORMATION_TYPE_SPARSE_KEYWORD, Priority: 1, Text: "higress"},
+			{Type: precontractv1.TransformationType_TRANSFORMATION_TYPE_SPARSE_KEYWORD, Priority: 3, Text: "higress plugin wasm"},
+			{Type: precontractv1.TransformationType_TRANSFORMATION_TYPE_DENSE_REWRITE, Priority: 2, Text: "which plugins does higress support"},
+		},
+	}
+
+	result := ToPreRetrieveResult(req, resp)
+	if result == nil || len(result.Plan.Nodes) != 1 {
+		t.Fatalf("ToPreRetrieveResult() = %+v, want a single node", result)
+	}
+	node := result.Plan.Nodes[0]
+	if node.ID != "node_0" || node.DenseRewrite != "which plugins does higress support" || node.SparseRewrite != "higress plugin wasm" {
+		t.Errorf("node = %+v, want the dense rewrite and the top sparse keywords", node)
+	}
+	if result.AlignedQuery.Query != "higress plugins" || result.Plan.CardinalityPrior != pre_retrieve.CardinalitySingle {
+		t.Errorf("result = %+v, want the raw query and a single-document prior", result)
+	}
+
+	// An intent requiring several documents sets the multi-document prior
+	resp.Intents[0].RequiresMultiDoc = true
+	if result := ToPreRetrieveResult(req, resp); result.Plan.CardinalityPrior != pre_retrieve.CardinalityMulti {
+		t.Errorf("cardinality = %s, want multi", result.Plan.CardinalityPrior)
+	}
+
+	if result := ToPreRetrieveResult(req, &precontractv1.PreprocessResponse{}); result != nil {
+		t.Errorf("ToPreRetrieveResult(empty) = %+v, want nil", result)
+	}
+}
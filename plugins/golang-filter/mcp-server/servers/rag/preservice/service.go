@@ -0,0 +1,187 @@
+// Package preservice calls an external query preprocessor (pipeline.pre.service) that
+// returns structured pre-retrieve outputs following the precontract.v1 contract.
+package preservice
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/httpx"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	precontractv1 "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/proto/precontract/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	PROVIDER_HTTP = "http"
+	PROVIDER_GRPC = "grpc"
+
+	// defaultGRPCTimeout bounds a gRPC call when neither pre.service nor pipeline.http sets a timeout
+	defaultGRPCTimeout = 1200 * time.Millisecond
+)
+
+// Service produces intents, entities, transformations and a decomposition for a query.
+type Service interface {
+	Generate(ctx context.Context, req *precontractv1.PreprocessRequest) (*precontractv1.PreprocessResponse, error)
+}
+
+// New creates the preprocessor client for the configured provider.
+// Calls are bounded by pre.service.timeout_ms, falling back to pipeline.http.timeout_ms.
+// It returns nil when no service is configured.
+func New(preCfg *config.PreConfig, httpCfg *config.HTTPClientConfig) (Service, error) {
+	if preCfg == nil || preCfg.Service.Provider == "" {
+		return nil, nil
+	}
+	if preCfg.Service.Endpoint == "" {
+		return nil, fmt.Errorf("pre.service.endpoint is required for provider %s", preCfg.Service.Provider)
+	}
+	var timeout time.Duration
+	if preCfg.Service.TimeoutMs > 0 {
+		timeout = time.Duration(preCfg.Service.TimeoutMs) * time.Millisecond
+	}
+	switch preCfg.Service.Provider {
+	case PROVIDER_HTTP:
+		svc := NewHTTPPreService(preCfg.Service.Endpoint, httpCfg)
+		svc.Timeout = timeout
+		return svc, nil
+	case PROVIDER_GRPC:
+		if timeout == 0 {
+			timeout = defaultGRPCTimeout
+			if httpCfg != nil && httpCfg.TimeoutMs > 0 {
+				timeout = time.Duration(httpCfg.TimeoutMs) * time.Millisecond
+			}
+		}
+		return NewGRPCPreService(preCfg.Service.Endpoint, timeout)
+	default:
+		return nil, fmt.Errorf("pre.service.provider must be http or grpc, got: %s", preCfg.Service.Provider)
+	}
+}
+
+// HTTPPreService posts the request as JSON and decodes a JSON PreprocessResponse.
+// Field names follow the proto JSON mapping; both snake_case and camelCase are accepted.
+type HTTPPreService struct {
+	Endpoint string
+	Client   *httpx.Client
+	// Timeout bounds each call on top of the client timeout; 0 leaves it to the client
+	Timeout time.Duration
+}
+
+// NewHTTPPreService creates an HTTP preprocessor client honoring the pipeline HTTP settings
+func NewHTTPPreService(endpoint string, httpCfg *config.HTTPClientConfig) *HTTPPreService {
+	return &HTTPPreService{
+		Endpoint: endpoint,
+		Client:   httpx.NewFromConfig(httpCfg),
+	}
+}
+
+func (s *HTTPPreService) Generate(ctx context.Context, req *precontractv1.PreprocessRequest) (*precontractv1.PreprocessResponse, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	body, err := protojson.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encode preprocess request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("preprocessor returned status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	out := &precontractv1.PreprocessResponse{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("decode preprocess response: %w", err)
+	}
+	return out, nil
+}
+
+// GRPCPreService calls Preprocessor.Generate over a plaintext gRPC connection.
+type GRPCPreService struct {
+	conn    *grpc.ClientConn
+	client  precontractv1.PreprocessorClient
+	timeout time.Duration
+}
+
+// NewGRPCPreService dials target (host:port) lazily; the connection is established on first use
+func NewGRPCPreService(target string, timeout time.Duration) (*GRPCPreService, error) {
+	conn, err := grpc.Dial(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("dial preprocessor %s: %w", target, err)
+	}
+	return &GRPCPreService{conn: conn, client: precontractv1.NewPreprocessorClient(conn), timeout: timeout}, nil
+}
+
+func (s *GRPCPreService) Generate(ctx context.Context, req *precontractv1.PreprocessRequest) (*precontractv1.PreprocessResponse, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return s.client.Generate(ctx, req)
+}
+
+// Close releases the underlying connection
+func (s *GRPCPreService) Close() error {
+	return s.conn.Close()
+}
+
+// SubQueries extracts the retrieval queries from a preprocessor response.
+// Decomposition tasks win, in task order; otherwise dense rewrites and query expansions
+// are used by descending priority. Empty and duplicate queries are dropped.
+func SubQueries(resp *precontractv1.PreprocessResponse) []string {
+	if resp == nil {
+		return nil
+	}
+	var queries []string
+	seen := make(map[string]bool)
+	add := func(q string) {
+		q = strings.TrimSpace(q)
+		if q == "" || seen[q] {
+			return
+		}
+		seen[q] = true
+		queries = append(queries, q)
+	}
+
+	for _, task := range resp.GetDecomposition().GetTasks() {
+		add(task.GetQueryText())
+	}
+	if len(queries) > 0 {
+		return queries
+	}
+
+	transformations := append([]*precontractv1.QueryTransformation(nil), resp.GetTransformations()...)
+	sort.SliceStable(transformations, func(i, j int) bool {
+		return transformations[i].GetPriority() > transformations[j].GetPriority()
+	})
+	for _, t := range transformations {
+		switch t.GetType() {
+		case precontractv1.TransformationType_TRANSFORMATION_TYPE_DENSE_REWRITE,
+			precontractv1.TransformationType_TRANSFORMATION_TYPE_QUERY_EXPANSION:
+			add(t.GetText())
+		}
+	}
+	return queries
+}
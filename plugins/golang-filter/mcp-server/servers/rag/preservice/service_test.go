@@ -0,0 +1,153 @@
+package preservice
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	precontractv1 "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/proto/precontract/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHTTPPreService_Decomposition(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		gotQuery, _ = req["query"].(string)
+		_, _ = w.Write([]byte(`{
+			"version": "v1",
+			"decomposition": {"tasks": [
+				{"task_id": "t1", "query_text": "higress wasm plugin support"},
+				{"task_id": "t2", "query_text": "envoy lua filter support"}
+			]},
+			"transformations": [{"type": "TRANSFORMATION_TYPE_DENSE_REWRITE", "text": "ignored rewrite"}],
+			"unknown_field": true
+		}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.PreConfig{}
+	cfg.Service.Provider = PROVIDER_HTTP
+	cfg.Service.Endpoint = server.URL
+	svc, err := New(cfg, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	resp, err := svc.Generate(context.Background(), &precontractv1.PreprocessRequest{Query: "compare higress and envoy plugins"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if gotQuery != "compare higress and envoy plugins" {
+		t.Errorf("service received query %q", gotQuery)
+	}
+	got := SubQueries(resp)
+	if want := "higress wasm plugin support|envoy lua filter support"; strings.Join(got, "|") != want {
+		t.Errorf("SubQueries() = %q, want %q", got, want)
+	}
+}
+
+func TestSubQueries_Transformations(t *testing.T) {
+	resp := &precontractv1.PreprocessResponse{Transformations: []*precontractv1.QueryTransformation{
+		{Type: precontractv1.TransformationType_TRANSFORMATION_TYPE_QUERY_EXPANSION, Priority: 1, Text: "higress gateway plugins"},
+		{Type: precontractv1.TransformationType_TRANSFORMATION_TYPE_SPARSE_KEYWORD, Priority: 9, Text: "higress plugin"},
+		{Type: precontractv1.TransformationType_TRANSFORMATION_TYPE_DENSE_REWRITE, Priority: 5, Text: "what plugins does higress support"},
+		{Type: precontractv1.TransformationType_TRANSFORMATION_TYPE_DENSE_REWRITE, Priority: 0, Text: "higress gateway plugins"},
+	}}
+	got := SubQueries(resp)
+	if want := "what plugins does higress support|higress gateway plugins"; strings.Join(got, "|") != want {
+		t.Errorf("SubQueries() = %q, want %q", got, want)
+	}
+	if got := SubQueries(&precontractv1.PreprocessResponse{}); len(got) != 0 {
+		t.Errorf("SubQueries(empty) = %q, want none", got)
+	}
+}
+
+// splitPreprocessor answers with a single task for the query, after waiting for delay
+type splitPreprocessor struct {
+	precontractv1.UnimplementedPreprocessorServer
+	delay time.Duration
+}
+
+func (p *splitPreprocessor) Generate(ctx context.Context, req *precontractv1.PreprocessRequest) (*precontractv1.PreprocessResponse, error) {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &precontractv1.PreprocessResponse{Decomposition: &precontractv1.Decomposition{Tasks: []*precontractv1.Task{
+		{TaskId: "t1", QueryText: req.GetQuery() + " part one"},
+	}}}, nil
+}
+
+// startPreprocessor serves srv on a local port and returns its address
+func startPreprocessor(t *testing.T, srv precontractv1.PreprocessorServer) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := grpc.NewServer()
+	precontractv1.RegisterPreprocessorServer(server, srv)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func TestGRPCPreService_Generate(t *testing.T) {
+	svc, err := NewGRPCPreService(startPreprocessor(t, &splitPreprocessor{}), 2*time.Second)
+	if err != nil {
+		t.Fatalf("NewGRPCPreService() error = %v", err)
+	}
+	defer svc.Close()
+	resp, err := svc.Generate(context.Background(), &precontractv1.PreprocessRequest{Query: "higress"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if got := SubQueries(resp); len(got) != 1 || got[0] != "higress part one" {
+		t.Errorf("SubQueries() = %q, want the task query", got)
+	}
+}
+
+func TestGRPCPreService_Timeout(t *testing.T) {
+	cfg := &config.PreConfig{}
+	cfg.Service.Provider = PROVIDER_GRPC
+	cfg.Service.Endpoint = startPreprocessor(t, &splitPreprocessor{delay: time.Second})
+	cfg.Service.TimeoutMs = 20
+	// pre.service.timeout_ms takes precedence over the pipeline HTTP timeout
+	svc, err := New(cfg, &config.HTTPClientConfig{TimeoutMs: 5000})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer svc.(*GRPCPreService).Close()
+	start := time.Now()
+	if _, err := svc.Generate(context.Background(), &precontractv1.PreprocessRequest{Query: "higress"}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Generate() error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Generate() took %v, want it bounded by timeout_ms", elapsed)
+	}
+}
+
+func TestNew_Validation(t *testing.T) {
+	if svc, err := New(nil, nil); svc != nil || err != nil {
+		t.Errorf("New(nil) = %v, %v, want no service", svc, err)
+	}
+	cfg := &config.PreConfig{}
+	cfg.Service.Provider = PROVIDER_HTTP
+	if _, err := New(cfg, nil); err == nil {
+		t.Error("New() without endpoint should fail")
+	}
+	cfg.Service.Provider = "thrift"
+	cfg.Service.Endpoint = "localhost:9000"
+	if _, err := New(cfg, nil); err == nil {
+		t.Error("New() with unknown provider should fail")
+	}
+}
@@ -11,6 +11,7 @@ type Provider interface {
 	SelectByQuery(query string) config.RetrievalProfile
 	SelectByIntent(intent string) config.RetrievalProfile
 	SelectByName(name string) config.RetrievalProfile
+	SelectByLanguage(language string) config.RetrievalProfile
 	SelectDefault() config.RetrievalProfile
 	Normalize(prof config.RetrievalProfile) config.RetrievalProfile
 	ApplyConstraints(prof config.RetrievalProfile, latencyBudgetMs int32, urgencyLevel string) config.RetrievalProfile
@@ -66,6 +67,21 @@ func (p *defaultProvider) SelectByName(name string) config.RetrievalProfile {
 	return config.RetrievalProfile{}
 }
 
+// SelectByLanguage selects the first profile listing language in its Languages
+func (p *defaultProvider) SelectByLanguage(language string) config.RetrievalProfile {
+	if language == "" {
+		return config.RetrievalProfile{}
+	}
+	for _, prof := range p.profiles {
+		for _, lang := range prof.Languages {
+			if strings.EqualFold(lang, language) {
+				return p.Normalize(prof)
+			}
+		}
+	}
+	return config.RetrievalProfile{}
+}
+
 // SelectDefault returns the first profile or a default one
 func (p *defaultProvider) SelectDefault() config.RetrievalProfile {
 	if len(p.profiles) > 0 {
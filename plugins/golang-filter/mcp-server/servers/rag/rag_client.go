@@ -1,16 +1,26 @@
 package rag
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/cache"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/htmltext"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/httpx"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/crag"
@@ -22,7 +32,9 @@ import (
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/post"
 	pre_retrieve "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/pre-retrieve"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/preservice"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/profile"
+	precontractv1 "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/proto/precontract/v1"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retrieval"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retriever"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/router"
@@ -36,15 +48,40 @@ import (
 const (
 	MAX_LIST_KNOWLEDGE_ROW_COUNT = 1000
 	MAX_LIST_DOCUMENT_ROW_COUNT  = 1000
+	MAX_URL_CONTENT_BYTES        = 10 << 20
 )
 
-// RAGClient represents the RAG (Retrieval-Augmented Generation) client
+// RAGClient represents the RAG (Retrieval-Augmented Generation) client.
+// Public methods work on a snapshot of the client's components taken under mu, so
+// Reload can swap them while requests are in flight.
 type RAGClient struct {
+	mu        *sync.RWMutex
+	namespace string
+	// root is the client a namespace-scoped copy takes its snapshots from, so the copy
+	// follows Reload; nil for the client itself and for snapshots
+	root *RAGClient
+	// metricsAggregator aggregates the metrics of every pipeline run; it is shared by
+	// snapshots and namespace-scoped copies and kept across Reload
+	metricsAggregator *metrics.Aggregator
+	// reindexGuard serializes ReindexCollection with ingestion; like metricsAggregator it
+	// is shared by snapshots and kept across Reload
+	reindexGuard *reindexGuard
+	ragComponents
+}
+
+// ragComponents holds the configuration and providers that Reload replaces as a unit
+type ragComponents struct {
+	// inflight counts the snapshots acquired on the components; Reload closes the
+	// components it replaces once it drops to zero
+	inflight *sync.WaitGroup
+
 	config             *config.Config
 	vectordbProvider   vectordb.VectorStoreProvider
 	embeddingProvider  embedding.Provider
 	textSplitter       textsplitter.TextSplitter
+	parentSplitter     textsplitter.TextSplitter
 	llmProvider        llm.Provider
+	promptTemplate     *llm.PromptTemplate
 	sessions           SessionStore
 	profileProvider    profile.Provider
 	retrievalProvider  retrieval.Provider
@@ -54,11 +91,28 @@ type RAGClient struct {
 	feedbackManager    *feedback.Manager
 	routerProvider     router.Router
 	l1Cache            cache.Cache
+	l2Cache            resultCache
 	cacheMode          string
 	indexVersion       string
-	cacheFusionVersion string
+	indexGenerations   *indexGenerations
+	cacheFusionVersion *cacheVersion
+	cacheEmptyTTL      time.Duration
+	httpClient         *httpx.Client
+
+	// semanticCache is set when pipeline.cache.semantic is enabled, with its defaults applied
+	semanticCache *config.SemanticCacheConfig
+
+	// sparseEmbeddingProvider is set when a sparse retriever is configured
+	sparseEmbeddingProvider embedding.SparseProvider
+	// embeddingBreaker embeds queries when the pipeline is configured; while it is open,
+	// retrieval skips the vector retriever
+	embeddingBreaker *embedding.CircuitBreaker
+	// profileEmbedders holds the query embedding of the profiles that override it, keyed by
+	// profile name; each has its own circuit breaker
+	profileEmbedders map[string]*embedding.CircuitBreaker
 
 	// Post-processing components
+	mmr        *post.MMR
 	compressor post.Compressor
 
 	// CRAG components
@@ -68,18 +122,28 @@ type RAGClient struct {
 
 	// Pre-retrieve component
 	preRetrieveProvider pre_retrieve.Provider
+	// preService is the external preprocessor configured via pipeline.pre.service
+	preService preservice.Service
 }
 
 // NewRAGClient creates a new RAG client instance
 func NewRAGClient(config *config.Config) (*RAGClient, error) {
 	ragclient := &RAGClient{
-		config: config,
+		mu:                &sync.RWMutex{},
+		metricsAggregator: metrics.NewAggregator(metricsLatencyWindow(config)),
+		reindexGuard:      &reindexGuard{},
+		ragComponents: ragComponents{
+			inflight:   &sync.WaitGroup{},
+			config:     config,
+			httpClient: newOutboundHTTPClient(config),
+		},
 	}
 	textSplitter, err := textsplitter.NewTextSplitter(&config.RAG.Splitter)
 	if err != nil {
 		return nil, fmt.Errorf("create text splitter failed, err: %w", err)
 	}
 	ragclient.textSplitter = textSplitter
+	ragclient.parentSplitter = textsplitter.NewParentSplitter(&config.RAG.Splitter)
 
 	embeddingProvider, err := embedding.NewEmbeddingProvider(ragclient.config.Embedding)
 	if err != nil {
@@ -96,282 +160,765 @@ func NewRAGClient(config *config.Config) (*RAGClient, error) {
 		}
 		ragclient.llmProvider = llmProvider
 	}
+	promptTemplate, err := llm.NewPromptTemplate(ragclient.config.LLM.PromptTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("create prompt template failed, err: %w", err)
+	}
+	ragclient.promptTemplate = promptTemplate
 
-	dim := ragclient.config.Embedding.Dimensions
+	dim, err := resolveEmbeddingDimensions(context.Background(), embeddingProvider, ragclient.config.Embedding.Dimensions)
+	if err != nil {
+		return nil, fmt.Errorf("resolve embedding dimensions failed, err: %w", err)
+	}
+	ragclient.config.Embedding.Dimensions = dim
+	if c := ragclient.config.Embedding.Cache; c != nil && c.Enable {
+		var l2 embedding.VectorCache
+		if strings.EqualFold(strings.TrimSpace(c.Store), "redis") {
+			if l2, err = NewRedisVectorCache(c); err != nil {
+				return nil, err
+			}
+		}
+		cached := embedding.NewCachedProvider(embeddingProvider, ragclient.config.Embedding, l2)
+		ragclient.embeddingProvider = cached
+		ragclient.metricsAggregator.SetEmbeddingCacheStats(cached.Stats)
+	}
 	provider, err := vectordb.NewVectorDBProvider(&ragclient.config.VectorDB, dim)
 	if err != nil {
 		return nil, fmt.Errorf("create vector store provider failed, err: %w", err)
 	}
+	if ragclient.config.VectorDB.PingOnStart {
+		ctx, cancel := context.WithTimeout(context.Background(), HEALTH_CHECK_TIMEOUT)
+		err := provider.Ping(ctx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("vector store %s at %s:%d is not ready, err: %w",
+				provider.GetProviderType(), ragclient.config.VectorDB.Host, ragclient.config.VectorDB.Port, err)
+		}
+	}
 	ragclient.vectordbProvider = provider
 	ragclient.indexVersion = ragclient.config.VectorDB.Collection
 
-	// Build enhanced pipeline providers if configured
-	if ragclient.config.Pipeline != nil {
-		retrievers := make([]retriever.Retriever, 0, len(ragclient.config.Pipeline.Retrievers)+1)
-		retrieverMap := make(map[string]retriever.Retriever)
-		register := func(r retriever.Retriever, typ, provider, name string) {
-			if r == nil {
-				return
-			}
-			key := strings.ToLower(strings.TrimSpace(typ))
-			if key != "" {
-				retrieverMap[key] = r
-			}
-			if provider != "" && key != "" {
-				retrieverMap[key+":"+strings.ToLower(strings.TrimSpace(provider))] = r
-			}
-			if name != "" {
-				retrieverMap[strings.ToLower(strings.TrimSpace(name))] = r
-			}
-		}
+	if err := ragclient.initPipeline(); err != nil {
+		return nil, err
+	}
+	return ragclient, nil
+}
 
-		vectorRet := &retriever.VectorRetriever{
-			Embed:     ragclient.embeddingProvider,
-			Store:     ragclient.vectordbProvider,
-			TopK:      ragclient.config.RAG.TopK,
-			Threshold: ragclient.config.RAG.Threshold,
+// metricsLatencyWindow returns the configured latency window of the metrics aggregator
+func metricsLatencyWindow(cfg *config.Config) int {
+	if cfg.Pipeline == nil || cfg.Pipeline.Metrics == nil {
+		return 0
+	}
+	return cfg.Pipeline.Metrics.LatencyWindow
+}
+
+// MetricsAggregator returns the aggregator every pipeline run is recorded into
+func (r *RAGClient) MetricsAggregator() *metrics.Aggregator {
+	return r.metricsAggregator
+}
+
+// initPipeline builds the enhanced pipeline providers if configured
+func (r *RAGClient) initPipeline() error {
+	r.cacheFusionVersion = &cacheVersion{}
+	r.indexGenerations = &indexGenerations{}
+	r.sparseEmbeddingProvider = nil
+	r.embeddingBreaker = nil
+	r.profileEmbedders = nil
+	if r.config.Pipeline == nil {
+		return nil
+	}
+	r.embeddingBreaker = newEmbeddingBreaker(r.embeddingProvider, r.config.Pipeline.HTTP)
+	if err := r.initProfileEmbedders(); err != nil {
+		return err
+	}
+	prompts, err := llm.NewPromptRegistry(r.config.Pipeline.Prompts)
+	if err != nil {
+		return fmt.Errorf("load prompt templates failed, err: %w", err)
+	}
+	retrievers := make([]retriever.Retriever, 0, len(r.config.Pipeline.Retrievers)+1)
+	retrieverMap := make(map[string]retriever.Retriever)
+	register := func(rt retriever.Retriever, typ, provider, name string) {
+		if rt == nil {
+			return
+		}
+		key := strings.ToLower(strings.TrimSpace(typ))
+		if key != "" {
+			retrieverMap[key] = rt
+		}
+		if provider != "" && key != "" {
+			retrieverMap[key+":"+strings.ToLower(strings.TrimSpace(provider))] = rt
+		}
+		if name != "" {
+			retrieverMap[strings.ToLower(strings.TrimSpace(name))] = rt
 		}
-		retrievers = append(retrievers, vectorRet)
-		register(vectorRet, "vector", ragclient.config.VectorDB.Provider, "vector")
+	}
 
-		// Optional: add BM25 / Web retrievers from config
-		for _, rc := range ragclient.config.Pipeline.Retrievers {
-			switch rc.Type {
-			case "bm25":
-				bm := &retriever.BM25Retriever{
-					Endpoint: rc.Params["endpoint"],
-					Index:    rc.Params["index"],
-					Client:   httpx.NewFromConfig(ragclient.config.Pipeline.HTTP),
-				}
-				if tk := rc.Params["top_k"]; tk != "" {
-					if n, err := strconv.Atoi(tk); err == nil {
-						bm.MaxTopK = n
-					}
-				}
-				retrievers = append(retrievers, bm)
-				register(bm, rc.Type, rc.Provider, rc.Params["name"])
-			case "web":
-				web := &retriever.WebSearchRetriever{
-					Provider: rc.Provider,
-					Endpoint: rc.Params["endpoint"],
-					APIKey:   rc.Params["api_key"],
-					Client:   httpx.NewFromConfig(ragclient.config.Pipeline.HTTP),
-				}
-				if tk := rc.Params["top_k"]; tk != "" {
-					if n, err := strconv.Atoi(tk); err == nil {
-						web.MaxTopK = n
-					}
-				}
-				retrievers = append(retrievers, web)
-				register(web, rc.Type, rc.Provider, rc.Params["name"])
-			case "vector":
-				// Allow registering additional vector retrievers with custom name/provider if needed.
-				register(vectorRet, rc.Type, rc.Provider, rc.Params["name"])
-			default:
-				// unknown type ignored for now
+	vectorRet := &retriever.VectorRetriever{
+		Embed:     r.embeddingBreaker,
+		Store:     r.vectordbProvider,
+		TopK:      r.config.RAG.TopK,
+		Threshold: r.config.RAG.Threshold,
+	}
+	// With pre.rewrite enabled, retrievers also search the rewrite variants of each query.
+	// Web search is left out since every variant is a billed request.
+	expand := func(rt retriever.Retriever) retriever.Retriever {
+		if r.config.Pipeline.Pre == nil || !r.config.Pipeline.Pre.Rewrite.Enable || rt.Type() == "web" {
+			return rt
+		}
+		return &retriever.QueryExpansionRetriever{Base: rt}
+	}
+	vectorSearch := expand(vectorRet)
+	retrievers = append(retrievers, vectorSearch)
+	register(vectorSearch, "vector", r.config.VectorDB.Provider, "vector")
+
+	// Optional: add BM25 / Web / third-party retrievers from config
+	for _, rc := range r.config.Pipeline.Retrievers {
+		if rc.Type == "vector" {
+			// Allow registering additional vector retrievers with custom name/provider if needed.
+			register(vectorSearch, rc.Type, rc.Provider, rc.Params["name"])
+			continue
+		}
+		if _, ok := retriever.Lookup(rc.Type); !ok {
+			api.LogWarnf("rag: unknown retriever type %q ignored", rc.Type)
+			continue
+		}
+		// Each retriever gets its own HTTP client so circuit state is not shared
+		deps := retriever.Deps{
+			Embed:      r.embeddingBreaker,
+			Store:      r.vectordbProvider,
+			HTTPClient: httpx.NewFromConfig(r.config.Pipeline.HTTP),
+		}
+		if rc.Embedding != nil && rc.Embedding.Kind == embedding.EMBEDDING_KIND_SPARSE {
+			sparse, err := embedding.NewSparseEmbeddingProvider(*rc.Embedding)
+			if err != nil {
+				return fmt.Errorf("create sparse embedding provider failed, err: %w", err)
 			}
+			deps.Sparse = sparse
+			// Ingestion writes sparse vectors with the same model the retriever queries with
+			r.sparseEmbeddingProvider = sparse
 		}
+		ret, err := retriever.New(rc, deps)
+		if err != nil {
+			return fmt.Errorf("create %s retriever failed, err: %w", rc.Type, err)
+		}
+		ret = expand(ret)
+		retrievers = append(retrievers, ret)
+		register(ret, rc.Type, rc.Provider, rc.Params["name"])
+	}
 
-		// Initialize providers
-		ragclient.profileProvider = profile.NewProvider(ragclient.config.Pipeline)
+	// Initialize providers
+	r.profileProvider = profile.NewProvider(r.config.Pipeline)
+
+	rrfK := r.config.Pipeline.RRFK
+	if rrfK <= 0 {
+		rrfK = 60
+	}
+	r.retrievalProvider = retrieval.NewProvider(retrievers, retrieverMap, rrfK)
 
-		rrfK := ragclient.config.Pipeline.RRFK
-		if rrfK <= 0 {
-			rrfK = 60
+	// Configure fusion strategy
+	fusionStrategy, fusionParams := buildFusionStrategy(r.config.Pipeline.Fusion, rrfK)
+	r.retrievalProvider.SetFusionStrategy(fusionStrategy, fusionParams)
+	if r.config.Pipeline.Fusion != nil {
+		r.retrievalProvider.SetNearDuplicates(fusion.NewNearDuplicates(r.config.Pipeline.Fusion.Dedup, r.embeddingBreaker))
+	}
+
+	if r.config.Pipeline.Feedback != nil {
+		r.feedbackManager = feedback.NewManager(r.config.Pipeline.Feedback)
+	}
+
+	r.gatingProvider = gating.NewProvider(vectorRet)
+	if r.config.Pipeline.Cache != nil && r.config.Pipeline.Cache.Gating != nil && r.config.Pipeline.Cache.Gating.Enable {
+		gatingCache := r.config.Pipeline.Cache.Gating
+		ttl := time.Duration(gatingCache.TTLSeconds) * time.Second
+		if ttl <= 0 {
+			ttl = 30 * time.Second
+		}
+		capacity := gatingCache.MaxEntries
+		if capacity <= 0 {
+			capacity = 1000
 		}
-		ragclient.retrievalProvider = retrieval.NewProvider(retrievers, retrieverMap, rrfK)
+		r.gatingProvider.WithDecisionCache(capacity, ttl)
+	}
+	if r.feedbackManager != nil {
+		r.gatingProvider.WithFeedback(r.feedbackManager, r.config.Pipeline.Feedback)
+	}
 
-		// Configure fusion strategy
-		var (
-			fusionStrategy fusion.Strategy = fusion.NewRRFStrategy(rrfK)
-			fusionParams                   = map[string]any{"k": rrfK}
-		)
-		if ragclient.config.Pipeline.Fusion != nil {
-			strategyName := ragclient.config.Pipeline.Fusion.Strategy
-			if strategyName == "" {
-				strategyName = "rrf"
-			}
-			if ragclient.config.Pipeline.Fusion.EnableLearned {
-				strategyName = "learned"
-			}
+	if r.config.Pipeline.Router != nil && r.config.Pipeline.Router.Enable {
+		r.routerProvider = router.NewRouter(r.config.Pipeline.Router, r.config.Pipeline.HTTP)
+	}
 
-			params := make(map[string]any)
-			for k, v := range ragclient.config.Pipeline.Fusion.Params {
-				params[k] = v
-			}
-			if ragclient.config.Pipeline.Fusion.WeightsURI != "" {
-				params["weights_uri"] = ragclient.config.Pipeline.Fusion.WeightsURI
-			}
-			if ragclient.config.Pipeline.Fusion.Fallback != "" {
-				params["fallback"] = ragclient.config.Pipeline.Fusion.Fallback
+	if r.config.Pipeline.Cache != nil && r.config.Pipeline.Cache.L1 != nil && r.config.Pipeline.Cache.L1.Enable {
+		l1 := r.config.Pipeline.Cache.L1
+		ttl := time.Duration(l1.TTLSeconds) * time.Second
+		if ttl <= 0 {
+			ttl = 2 * time.Minute
+		}
+		capacity := l1.MaxEntries
+		if capacity <= 0 {
+			capacity = 500
+		}
+		r.l1Cache = cache.NewLRU(capacity, ttl)
+		mode := strings.ToLower(strings.TrimSpace(l1.Mode))
+		if mode == "" {
+			mode = "post"
+		}
+		if mode != "post" {
+			api.LogInfof("rag: L1 cache mode %q not fully supported, defaulting to post", mode)
+			mode = "post"
+		}
+		r.cacheMode = mode
+		r.cacheEmptyTTL = 0
+		if l1.CacheEmpty {
+			r.cacheEmptyTTL = time.Duration(l1.EmptyTTLSeconds) * time.Second
+			if r.cacheEmptyTTL <= 0 {
+				r.cacheEmptyTTL = 10 * time.Second
 			}
-			if ragclient.config.Pipeline.Fusion.TimeoutMs > 0 {
-				params["timeout_ms"] = ragclient.config.Pipeline.Fusion.TimeoutMs
+			if r.cacheEmptyTTL > ttl {
+				r.cacheEmptyTTL = ttl
 			}
-			if ragclient.config.Pipeline.Fusion.RefreshSeconds > 0 {
-				params["refresh_seconds"] = ragclient.config.Pipeline.Fusion.RefreshSeconds
+		}
+	}
+	if r.config.Pipeline.Cache != nil && r.config.Pipeline.Cache.L2 != nil && r.config.Pipeline.Cache.L2.Enable {
+		l2 := r.config.Pipeline.Cache.L2
+		if store := strings.ToLower(strings.TrimSpace(l2.Store)); store != "" && store != "redis" {
+			return fmt.Errorf("unsupported cache.l2.store %q, only redis is supported", l2.Store)
+		}
+		l2Cache, err := NewRedisResultCache(l2)
+		if err != nil {
+			return err
+		}
+		r.l2Cache = l2Cache
+		r.cacheMode = "post"
+	}
+	if sem := r.config.Pipeline.Cache; sem != nil && sem.Semantic != nil && sem.Semantic.Enable {
+		semantic := *sem.Semantic
+		if semantic.Threshold <= 0 {
+			semantic.Threshold = DEFAULT_SEMANTIC_CACHE_THRESHOLD
+		}
+		if semantic.MaxEntries <= 0 {
+			semantic.MaxEntries = DEFAULT_SEMANTIC_CACHE_ENTRIES
+		}
+		if l2, ok := r.l2Cache.(*RedisResultCache); ok {
+			l2.maxIndexed = semantic.MaxEntries
+		}
+		r.semanticCache = &semantic
+	}
+
+	// Initialize reranker with support for multiple providers
+	r.reranker = buildReranker(r.config.Pipeline.Post, r.config.Pipeline.HTTP, r.config.Pipeline.Keywords, r.llmProvider, prompts)
+	r.retrievalProvider.SetReranker(buildCascadeReranker(r.config.Pipeline, r.reranker, r.llmProvider, prompts))
+
+	// Initialize CRAG components
+	if r.config.Pipeline.CRAG != nil {
+		cragCfg := r.config.Pipeline.CRAG
+
+		// Initialize evaluator (HTTP or LLM-based)
+		if cragCfg.Evaluator.Provider == "http" && cragCfg.Evaluator.Endpoint != "" {
+			r.evaluator = &crag.HTTPEvaluator{
+				Endpoint:           cragCfg.Evaluator.Endpoint,
+				CorrectTh:          cragCfg.Evaluator.Correct,
+				IncorrectTh:        cragCfg.Evaluator.Incorrect,
+				ThresholdsByIntent: cragThresholdsByIntent(cragCfg),
+				Client:             httpx.NewFromConfig(r.config.Pipeline.HTTP),
 			}
-			if ragclient.config.Pipeline.Fusion.TrafficPercent > 0 {
-				params["traffic_percent"] = ragclient.config.Pipeline.Fusion.TrafficPercent
+		} else if cragCfg.Evaluator.Provider == "llm" && r.llmProvider != nil {
+			r.evaluator = &crag.LLMEvaluator{
+				Provider:           r.llmProvider,
+				CorrectTh:          cragCfg.Evaluator.Correct,
+				IncorrectTh:        cragCfg.Evaluator.Incorrect,
+				ThresholdsByIntent: cragThresholdsByIntent(cragCfg),
+				Prompts:            prompts,
 			}
+		}
 
-			strategy, sanitized, err := fusion.NewStrategy(strategyName, params)
-			if err != nil {
-				api.LogWarnf("rag: fallback to RRF fusion due to strategy init error: %v", err)
-			} else {
-				fusionStrategy = strategy
-				if sanitized != nil {
-					fusionParams = sanitized
+		// Initialize web searcher from CRAG config or retriever config
+		for _, rc := range r.config.Pipeline.Retrievers {
+			if rc.Type == "web" {
+				r.webSearcher = &crag.WebSearcher{
+					Provider: rc.Provider,
+					Endpoint: rc.Params["endpoint"],
+					APIKey:   rc.Params["api_key"],
+					EngineID: rc.Params["cx"],
+					Engine:   rc.Params["engine"],
+					Client:   httpx.NewFromConfig(r.config.Pipeline.HTTP),
 				}
+				break
 			}
 		}
-		ragclient.retrievalProvider.SetFusionStrategy(fusionStrategy, fusionParams)
 
-		if ragclient.config.Pipeline.Feedback != nil {
-			ragclient.feedbackManager = feedback.NewManager(ragclient.config.Pipeline.Feedback)
+		// Initialize query rewriter and refiner if LLM available
+		if r.llmProvider != nil {
+			r.queryRewriter = &crag.QueryRewriter{
+				Provider: r.llmProvider,
+				Variants: r.config.Pipeline.CRAG.RewriteVariants,
+				Prompts:  prompts,
+			}
+			r.refiner = &crag.KnowledgeRefiner{
+				Provider: r.llmProvider,
+				Prompts:  prompts,
+			}
 		}
+	}
 
-		ragclient.gatingProvider = gating.NewProvider(vectorRet)
-		if ragclient.feedbackManager != nil {
-			ragclient.gatingProvider.WithFeedback(ragclient.feedbackManager, ragclient.config.Pipeline.Feedback)
-		}
+	if postCfg := r.config.Pipeline.Post; postCfg != nil && postCfg.MMR.Enable {
+		r.mmr = post.NewMMR(postCfg.MMR.Lambda, postCfg.MMR.TopN, r.embeddingBreaker)
+	}
+
+	// Initialize Compressor if enabled
+	r.compressor = buildCompressor(r.config.Pipeline.Post, r.llmProvider, prompts)
 
-		if ragclient.config.Pipeline.Router != nil && ragclient.config.Pipeline.Router.Enable {
-			ragclient.routerProvider = router.NewRouter(ragclient.config.Pipeline.Router, ragclient.config.Pipeline.HTTP)
+	// Initialize the external preprocessor if configured
+	r.preService = nil
+	if r.config.Pipeline.EnablePre && r.config.Pipeline.Pre != nil {
+		svc, err := preservice.New(r.config.Pipeline.Pre, r.config.Pipeline.HTTP)
+		if err != nil {
+			api.LogWarnf("rag: pre.service unavailable, using built-in pre-retrieve: %v", err)
+		} else {
+			r.preService = svc
 		}
+	}
 
-		if ragclient.config.Pipeline.Cache != nil && ragclient.config.Pipeline.Cache.L1 != nil && ragclient.config.Pipeline.Cache.L1.Enable {
-			l1 := ragclient.config.Pipeline.Cache.L1
-			ttl := time.Duration(l1.TTLSeconds) * time.Second
-			if ttl <= 0 {
-				ttl = 2 * time.Minute
-			}
-			capacity := l1.MaxEntries
-			if capacity <= 0 {
-				capacity = 500
-			}
-			ragclient.l1Cache = cache.NewLRU(capacity, ttl)
-			mode := strings.ToLower(strings.TrimSpace(l1.Mode))
-			if mode == "" {
-				mode = "post"
-			}
-			if mode != "post" {
-				api.LogInfof("rag: L1 cache mode %q not fully supported, defaulting to post", mode)
-				mode = "post"
-			}
-			ragclient.cacheMode = mode
+	// Initialize Pre-Retrieve Provider if enabled. The external preprocessor falls back
+	// to the default one when pipeline.pre_retrieve is not configured.
+	r.preRetrieveProvider = nil
+	preRetCfg := r.config.Pipeline.PreRetrieve
+	if preRetCfg == nil && r.preService != nil {
+		preRetCfg = &config.PreRetrieveConfig{Provider: pre_retrieve.PROVIDER_TYPE_DEFAULT}
+	}
+	if r.config.Pipeline.EnablePre && preRetCfg != nil {
+		// Set LLM config if available
+		if r.llmProvider != nil {
+			preRetCfg.LLM = r.config.LLM
 		}
+		preRetCfg.Planning.Keywords = r.config.Pipeline.Keywords
+		preRetCfg.Prompts = r.config.Pipeline.Prompts
+		// HyDE vectors are searched against the collection, so they use its embedding model
+		preRetCfg.Embedding = r.config.Embedding
 
-		// Initialize reranker with support for multiple providers
-		if ragclient.config.Pipeline.Post != nil && ragclient.config.Pipeline.Post.Rerank.Enable {
-			rerankCfg := ragclient.config.Pipeline.Post.Rerank
-			switch rerankCfg.Provider {
-			case "llm":
-				// Use LLM-based reranker
-				if ragclient.llmProvider != nil {
-					ragclient.reranker = &post.LLMReranker{
-						Provider: ragclient.llmProvider,
-						Model:    rerankCfg.Model,
-					}
-				}
-			case "keyword":
-				// Use keyword-based reranker
-				ragclient.reranker = &post.KeywordReranker{
-					MinKeywordLength: 3,
-					BaseScoreWeight:  0.5,
-				}
-			case "model":
-				// Use model-based reranker (BGE-reranker, Cohere rerank, etc.)
-				ragclient.reranker = &post.ModelReranker{
-					Endpoint: rerankCfg.Endpoint,
-					Model:    rerankCfg.Model,
-					APIKey:   rerankCfg.APIKey,
-				}
-			default:
-				// Default to HTTP reranker for backward compatibility
-				ragclient.reranker = post.NewHTTPReranker(rerankCfg.Endpoint)
-			}
+		provider, err := pre_retrieve.NewPreRetrieveProvider(preRetCfg)
+		if err != nil {
+			// Log warning but don't fail - pre-retrieve is optional
+			fmt.Printf("[WARN] Failed to initialize pre-retrieve provider: %v\n", err)
+		} else {
+			r.preRetrieveProvider = provider
 		}
+	}
+	return nil
+}
 
-		// Initialize CRAG components
-		if ragclient.config.Pipeline.CRAG != nil {
-			cragCfg := ragclient.config.Pipeline.CRAG
+// buildReranker creates the configured reranker through the post reranker registry.
+// HTTP rerankers use httpCfg, so they honor the pipeline's allowlist and circuit breaker,
+// the keyword reranker extracts keywords as configured by keywordCfg and the LLM reranker
+// renders its prompts with prompts. It returns nil when reranking is disabled or the
+// provider cannot be created.
+func buildReranker(postCfg *config.PostConfig, httpCfg *config.HTTPClientConfig, keywordCfg *config.KeywordConfig, llmProvider llm.Provider, prompts *llm.PromptRegistry) post.Reranker {
+	if postCfg == nil || !postCfg.Rerank.Enable {
+		return nil
+	}
+	rerankCfg := postCfg.Rerank
+	reranker, err := post.NewReranker(rerankCfg.Provider, post.RerankerOptions{
+		Endpoint: rerankCfg.Endpoint,
+		Model:    rerankCfg.Model,
+		APIKey:   rerankCfg.APIKey,
+		LLM:      llmProvider,
+		HTTP:     httpCfg,
+		Keywords: keywordCfg,
+		Prompts:  prompts,
+	})
+	if err != nil {
+		api.LogWarnf("rag: rerank provider %q unavailable: %v", rerankCfg.Provider, err)
+		return nil
+	}
+	return reranker
+}
 
-			// Initialize evaluator (HTTP or LLM-based)
-			if cragCfg.Evaluator.Provider == "http" && cragCfg.Evaluator.Endpoint != "" {
-				ragclient.evaluator = &crag.HTTPEvaluator{
-					Endpoint:    cragCfg.Evaluator.Endpoint,
-					CorrectTh:   cragCfg.Evaluator.Correct,
-					IncorrectTh: cragCfg.Evaluator.Incorrect,
-				}
-			} else if cragCfg.Evaluator.Provider == "llm" && ragclient.llmProvider != nil {
-				ragclient.evaluator = &crag.LLMEvaluator{
-					Provider:    ragclient.llmProvider,
-					CorrectTh:   cragCfg.Evaluator.Correct,
-					IncorrectTh: cragCfg.Evaluator.Incorrect,
-				}
-			}
+// buildCascadeReranker returns the reranker for cascade stage2 mode "rerank". It reuses
+// the post-retrieval reranker and otherwise builds the post.rerank provider for the cascade
+// alone, so a cascade can rerank without enabling the post-retrieval rerank step.
+func buildCascadeReranker(pipeline *config.PipelineConfig, reranker post.Reranker, llmProvider llm.Provider, prompts *llm.PromptRegistry) post.Reranker {
+	if reranker != nil || pipeline.Post == nil || pipeline.Post.Rerank.Provider == "" {
+		return reranker
+	}
+	for _, prof := range pipeline.RetrievalProfiles {
+		if prof.Cascade.Enable && strings.EqualFold(strings.TrimSpace(prof.Cascade.Stage2.Mode), "rerank") {
+			postCfg := *pipeline.Post
+			postCfg.Rerank.Enable = true
+			return buildReranker(&postCfg, pipeline.HTTP, pipeline.Keywords, llmProvider, prompts)
+		}
+	}
+	return nil
+}
 
-			// Initialize web searcher from CRAG config or retriever config
-			for _, rc := range ragclient.config.Pipeline.Retrievers {
-				if rc.Type == "web" {
-					ragclient.webSearcher = &crag.WebSearcher{
-						Provider: rc.Provider,
-						Endpoint: rc.Params["endpoint"],
-						APIKey:   rc.Params["api_key"],
-					}
-					break
-				}
-			}
+// cragThresholdsByIntent converts the configured per-intent thresholds for the evaluators.
+func cragThresholdsByIntent(cragCfg *config.CRAGConfig) map[string]crag.Thresholds {
+	if len(cragCfg.Evaluator.ThresholdsByIntent) == 0 {
+		return nil
+	}
+	out := make(map[string]crag.Thresholds, len(cragCfg.Evaluator.ThresholdsByIntent))
+	for intent, th := range cragCfg.Evaluator.ThresholdsByIntent {
+		out[intent] = crag.Thresholds{Correct: th.Correct, Incorrect: th.Incorrect}
+	}
+	return out
+}
 
-			// Initialize query rewriter and refiner if LLM available
-			if ragclient.llmProvider != nil {
-				ragclient.queryRewriter = &crag.QueryRewriter{
-					Provider: ragclient.llmProvider,
-				}
-				ragclient.refiner = &crag.KnowledgeRefiner{
-					Provider: ragclient.llmProvider,
-				}
-			}
-		}
+// newEmbeddingBreaker wraps the embedding provider in a circuit breaker that opens after
+// pipeline.http.max_consecutive_failures and stays open for circuit_open_seconds
+func newEmbeddingBreaker(p embedding.Provider, httpCfg *config.HTTPClientConfig) *embedding.CircuitBreaker {
+	maxFailures, cooldown := 0, time.Duration(0)
+	if httpCfg != nil {
+		maxFailures = httpCfg.MaxConsecutiveFailures
+		cooldown = time.Duration(httpCfg.CircuitOpenSeconds) * time.Second
+	}
+	return embedding.NewCircuitBreaker(p, maxFailures, cooldown)
+}
 
-		// Initialize Compressor if enabled
-		if ragclient.config.Pipeline.Post != nil && ragclient.config.Pipeline.Post.Compress.Enable {
-			compressCfg := ragclient.config.Pipeline.Post.Compress
-			method := compressCfg.Method
-			if method == "" {
-				method = "truncate" // Default method
-			}
-			targetRatio := compressCfg.TargetRatio
-			if targetRatio == 0 {
-				targetRatio = 0.7 // Default ratio
+// initProfileEmbedders creates the embedding provider of every retrieval profile that
+// overrides it. An override whose vectors do not have the collection's dimensions cannot
+// search it and fails with a config error.
+func (r *RAGClient) initProfileEmbedders() error {
+	for i, prof := range r.config.Pipeline.RetrievalProfiles {
+		if prof.Embedding == nil {
+			continue
+		}
+		provider, err := embedding.NewEmbeddingProvider(profileEmbeddingConfig(r.config.Embedding, *prof.Embedding))
+		if err != nil {
+			return fmt.Errorf("create embedding provider of profile %s failed, err: %w", prof.Name, err)
+		}
+		dim, err := resolveEmbeddingDimensions(context.Background(), provider, prof.Embedding.Dimensions)
+		if err != nil {
+			return fmt.Errorf("resolve embedding dimensions of profile %s failed, err: %w", prof.Name, err)
+		}
+		if dim != r.config.Embedding.Dimensions {
+			return &config.ValidationError{
+				Field:   fmt.Sprintf("pipeline.retrieval_profiles[%d].embedding.dimensions", i),
+				Message: fmt.Sprintf("profile %s embeds with %d dimensions, the collection has %d", prof.Name, dim, r.config.Embedding.Dimensions),
 			}
-			ragclient.compressor = post.NewCompressor(method, targetRatio, ragclient.llmProvider)
 		}
+		if r.profileEmbedders == nil {
+			r.profileEmbedders = make(map[string]*embedding.CircuitBreaker)
+		}
+		r.profileEmbedders[prof.Name] = newEmbeddingBreaker(provider, r.config.Pipeline.HTTP)
+	}
+	return nil
+}
 
-		// Initialize Pre-Retrieve Provider if enabled
-		if ragclient.config.Pipeline.EnablePre && ragclient.config.Pipeline.PreRetrieve != nil {
-			preRetCfg := ragclient.config.Pipeline.PreRetrieve
-			// Set LLM config if available
-			if ragclient.llmProvider != nil {
-				preRetCfg.LLM = ragclient.config.LLM
-			}
+// profileEmbeddingConfig completes a profile's embedding override from the global embedding.
+// An override of another provider is used as is; otherwise it usually only names a model
+// and inherits the credentials, endpoint and headers.
+func profileEmbeddingConfig(global, override config.EmbeddingConfig) config.EmbeddingConfig {
+	if override.Provider != "" && override.Provider != global.Provider {
+		return override
+	}
+	override.Provider = global.Provider
+	if override.APIKey == "" {
+		override.APIKey = global.APIKey
+	}
+	if override.BaseURL == "" {
+		override.BaseURL = global.BaseURL
+	}
+	if override.Model == "" {
+		override.Model = global.Model
+	}
+	if override.InputType == "" {
+		override.InputType = global.InputType
+	}
+	if override.Headers == nil {
+		override.Headers = global.Headers
+	}
+	return override
+}
 
-			provider, err := pre_retrieve.NewPreRetrieveProvider(preRetCfg)
-			if err != nil {
-				// Log warning but don't fail - pre-retrieve is optional
-				fmt.Printf("[WARN] Failed to initialize pre-retrieve provider: %v\n", err)
-			} else {
-				ragclient.preRetrieveProvider = provider
-			}
+// queryEmbedderFor returns the circuit breaker that embeds the queries of profile: its
+// override if it has one, otherwise the global embedding's
+func (r *RAGClient) queryEmbedderFor(profile string) *embedding.CircuitBreaker {
+	if p, ok := r.profileEmbedders[profile]; ok {
+		return p
+	}
+	return r.embeddingBreaker
+}
+
+// buildCompressor creates the configured compressor through the post compressor registry.
+// With max_tokens, any other method is wrapped so its output also fits the token budget.
+// It returns nil when compression is disabled.
+func buildCompressor(postCfg *config.PostConfig, llmProvider llm.Provider, prompts *llm.PromptRegistry) post.Compressor {
+	if postCfg == nil || !postCfg.Compress.Enable {
+		return nil
+	}
+	compressCfg := postCfg.Compress
+	method := compressCfg.Method
+	if method == "" {
+		method = "truncate" // Default method
+	}
+	targetRatio := compressCfg.TargetRatio
+	if targetRatio == 0 {
+		targetRatio = 0.7 // Default ratio
+	}
+	compressor := post.NewCompressorWithOptions(method, post.CompressorOptions{
+		TargetRatio: targetRatio,
+		Mode:        compressCfg.Mode,
+		LLM:         llmProvider,
+		Concurrency: compressCfg.Concurrency,
+		Timeout:     time.Duration(compressCfg.TimeoutMs) * time.Millisecond,
+		MaxTokens:   compressCfg.MaxTokens,
+		Prompts:     prompts,
+	})
+	if _, ok := compressor.(*post.BudgetCompressor); !ok && compressCfg.MaxTokens > 0 {
+		compressor = &post.BudgetCompressor{MaxTokens: compressCfg.MaxTokens, Base: compressor}
+	}
+	return compressor
+}
+
+// buildFusionStrategy resolves the configured fusion strategy, including custom
+// strategies registered via fusion.Register, and falls back to RRF on error.
+func buildFusionStrategy(fusionCfg *config.FusionConfig, rrfK int) (fusion.Strategy, map[string]any) {
+	var (
+		fusionStrategy fusion.Strategy = fusion.NewRRFStrategy(rrfK)
+		fusionParams                   = map[string]any{"k": rrfK}
+	)
+	if fusionCfg == nil {
+		return fusionStrategy, fusionParams
+	}
+	strategyName := fusionCfg.Strategy
+	if strategyName == "" {
+		strategyName = "rrf"
+	}
+	if fusionCfg.EnableLearned {
+		strategyName = "learned"
+	}
+
+	params := make(map[string]any)
+	for k, v := range fusionCfg.Params {
+		params[k] = v
+	}
+	if fusionCfg.WeightsURI != "" {
+		params["weights_uri"] = fusionCfg.WeightsURI
+	}
+	if fusionCfg.Fallback != "" {
+		params["fallback"] = fusionCfg.Fallback
+	}
+	if fusionCfg.TimeoutMs > 0 {
+		params["timeout_ms"] = fusionCfg.TimeoutMs
+	}
+	if fusionCfg.RefreshSeconds > 0 {
+		params["refresh_seconds"] = fusionCfg.RefreshSeconds
+	}
+	if fusionCfg.TrafficPercent > 0 {
+		params["traffic_percent"] = fusionCfg.TrafficPercent
+	}
+	if fusionCfg.TrafficSalt != "" {
+		params["traffic_salt"] = fusionCfg.TrafficSalt
+	}
+
+	strategy, sanitized, err := fusion.NewStrategy(strategyName, params)
+	if err != nil {
+		api.LogWarnf("rag: fallback to RRF fusion due to strategy init error: %v", err)
+		return fusionStrategy, fusionParams
+	}
+	if sanitized == nil {
+		sanitized = params
+	}
+	return strategy, sanitized
+}
+
+// resolveEmbeddingDimensions detects the embedding dimension when it is not configured,
+// and verifies a configured dimension against the provider's actual output otherwise.
+func resolveEmbeddingDimensions(ctx context.Context, provider embedding.Provider, configured int) (int, error) {
+	actual, err := provider.GetDimensions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if configured <= 0 {
+		api.LogInfof("rag: auto-detected embedding dimensions=%d from provider %s", actual, provider.GetProviderType())
+		return actual, nil
+	}
+	if configured != actual {
+		return 0, fmt.Errorf("configured embedding dimensions %d does not match provider output %d", configured, actual)
+	}
+	return configured, nil
+}
+
+// WithNamespace returns a client scoped to the given knowledge-base namespace. Chunks created
+// through the scoped client are tagged with the namespace, and search, list, delete and chat
+// only see chunks from it. An empty namespace returns r unchanged.
+func (r *RAGClient) WithNamespace(namespace string) *RAGClient {
+	namespace = strings.TrimSpace(namespace)
+	if namespace == "" || namespace == r.namespace {
+		return r
+	}
+	scoped := r.snapshot()
+	scoped.namespace = namespace
+	scoped.root = r.live()
+	return scoped
+}
+
+// buildRAGClient constructs the client used by Reload; tests replace it to avoid real backends
+var buildRAGClient = NewRAGClient
+
+// Reload rebuilds every provider from cfg and swaps them into r. Requests already in
+// flight finish on the providers they started with; later requests use the new ones.
+// The L1 cache is purged. Sessions and the namespace are kept. On error r is unchanged.
+// The connections held by the replaced providers are closed once the requests in flight
+// on them are done.
+func (r *RAGClient) Reload(cfg *config.Config) error {
+	next, err := buildRAGClient(cfg)
+	if err != nil {
+		return fmt.Errorf("reload rag client failed, err: %w", err)
+	}
+	r.swap(next)
+	return nil
+}
+
+// swap replaces the components of r with those of next under the write lock, and closes
+// the replaced ones in the background once their snapshots are released
+func (r *RAGClient) swap(next *RAGClient) {
+	r = r.live()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.l1Cache != nil {
+		r.l1Cache.Purge()
+	}
+	if r.l2Cache != nil {
+		r.l2Cache.Purge()
+	}
+	replaced := r.ragComponents
+	r.ragComponents = next.ragComponents
+	closeComponent("sessions", next.sessions)
+	r.sessions = replaced.sessions
+	replaced.sessions = nil
+	go func() {
+		replaced.inflight.Wait()
+		replaced.close()
+	}()
+}
+
+// close closes the providers holding connections
+func (c *ragComponents) close() {
+	closeComponent("vector store", c.vectordbProvider)
+	closeComponent("embedding cache", c.embeddingProvider)
+	closeComponent("result cache", c.l2Cache)
+	closeComponent("pre service", c.preService)
+	closeComponent("sessions", c.sessions)
+}
+
+// closeComponent closes component when it is an io.Closer, logging the failure
+func closeComponent(name string, component any) {
+	closer, ok := component.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		api.LogWarnf("rag: close %s failed, err: %v", name, err)
+	}
+}
+
+// live returns the client whose components r follows
+func (r *RAGClient) live() *RAGClient {
+	if r.root != nil {
+		return r.root
+	}
+	return r
+}
+
+// snapshot returns a shallow copy of r taken under the read lock, giving a request a
+// consistent set of providers for its whole duration. Requests using the providers
+// take the snapshot with acquire, so Reload does not close them underneath.
+func (r *RAGClient) snapshot() *RAGClient {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := *r
+	c.ragComponents = r.live().ragComponents
+	c.root = nil
+	return &c
+}
+
+// acquire returns a snapshot like snapshot and holds its components until release is
+// called
+func (r *RAGClient) acquire() (*RAGClient, func()) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := *r
+	c.ragComponents = r.live().ragComponents
+	c.root = nil
+	c.inflight.Add(1)
+	return &c, c.inflight.Done
+}
+
+// LLMProvider returns the LLM provider currently in use, or nil when none is configured
+func (r *RAGClient) LLMProvider() llm.Provider {
+	return r.snapshot().llmProvider
+}
+
+// Namespace returns the knowledge-base namespace the client is scoped to
+func (r *RAGClient) Namespace() string {
+	return r.namespace
+}
+
+// namespaceFilters returns the metadata filters enforcing the client's namespace
+func (r *RAGClient) namespaceFilters() map[string]interface{} {
+	if r.namespace == "" {
+		return nil
+	}
+	return map[string]interface{}{schema.METADATA_NAMESPACE: r.namespace}
+}
+
+// Stats summarizes the knowledge base visible to the client
+type Stats struct {
+	DocumentCount       int64    `json:"document_count"`
+	DistinctTitles      int      `json:"distinct_titles"`
+	Titles              []string `json:"titles"`
+	EmbeddingDimensions int      `json:"embedding_dimensions"`
+	VectorDBProvider    string   `json:"vectordb_provider"`
+	Collection          string   `json:"collection"`
+	Namespace           string   `json:"namespace,omitempty"`
+}
+
+// Stats returns the document count and basic store information. Titles are collected
+// from at most MAX_LIST_DOCUMENT_ROW_COUNT chunks.
+func (r *RAGClient) Stats() (Stats, error) {
+	r, done := r.acquire()
+	defer done()
+	ctx := context.Background()
+	count, err := r.vectordbProvider.Count(ctx, r.namespaceFilters())
+	if err != nil {
+		return Stats{}, fmt.Errorf("count chunks failed, err: %w", err)
+	}
+	docs, err := r.vectordbProvider.QueryDocs(ctx, &schema.QueryOptions{
+		Filters: r.namespaceFilters(),
+		Limit:   MAX_LIST_DOCUMENT_ROW_COUNT,
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("list chunks failed, err: %w", err)
+	}
+	seen := make(map[string]struct{})
+	titles := make([]string, 0)
+	for _, doc := range docs {
+		title, _ := doc.Metadata["chunk_title"].(string)
+		if _, ok := seen[title]; ok || title == "" {
+			continue
 		}
+		seen[title] = struct{}{}
+		titles = append(titles, title)
 	}
-	return ragclient, nil
+	sort.Strings(titles)
+	return Stats{
+		DocumentCount:       count,
+		DistinctTitles:      len(titles),
+		Titles:              titles,
+		EmbeddingDimensions: r.config.Embedding.Dimensions,
+		VectorDBProvider:    r.vectordbProvider.GetProviderType(),
+		Collection:          r.config.VectorDB.Collection,
+		Namespace:           r.namespace,
+	}, nil
 }
 
 // ListChunks lists document chunks by knowledge ID, returns in ascending order of DocumentIndex
 func (r *RAGClient) ListChunks() ([]schema.Document, error) {
-	docs, err := r.vectordbProvider.ListDocs(context.Background(), MAX_LIST_DOCUMENT_ROW_COUNT)
+	r, done := r.acquire()
+	defer done()
+	docs, err := r.vectordbProvider.QueryDocs(context.Background(), &schema.QueryOptions{
+		Filters: r.namespaceFilters(),
+		Limit:   MAX_LIST_DOCUMENT_ROW_COUNT,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("list chunks failed, err: %w", err)
 	}
@@ -380,19 +927,173 @@ func (r *RAGClient) ListChunks() ([]schema.Document, error) {
 
 // DeleteChunk deletes a specific document chunk
 func (r *RAGClient) DeleteChunk(id string) error {
+	r, done := r.acquire()
+	defer done()
+	release, err := r.reindexGuard.beginIngestion()
+	if err != nil {
+		return err
+	}
+	defer release()
+	if r.namespace != "" {
+		docs, err := r.vectordbProvider.QueryDocs(context.Background(), &schema.QueryOptions{
+			IDs:     []string{id},
+			Filters: r.namespaceFilters(),
+			Limit:   1,
+		})
+		if err != nil {
+			return fmt.Errorf("delete chunk failed, err: %w", err)
+		}
+		if len(docs) == 0 {
+			return fmt.Errorf("chunk %s not found in namespace %s", id, r.namespace)
+		}
+	}
 	if err := r.vectordbProvider.DeleteDocs(context.Background(), []string{id}); err != nil {
 		return fmt.Errorf("delete chunk failed, err: %w", err)
 	}
+	r.InvalidateCache()
 	return nil
 }
 
+// DeleteChunksByFilter deletes every chunk whose metadata matches all of filter and returns
+// the number of chunks deleted. The client's namespace is always enforced, and an empty
+// filter is rejected so a missing argument cannot empty the knowledge base.
+func (r *RAGClient) DeleteChunksByFilter(filter map[string]any) (int, error) {
+	r, done := r.acquire()
+	defer done()
+	if len(filter) == 0 {
+		return 0, fmt.Errorf("filter is required")
+	}
+	release, err := r.reindexGuard.beginIngestion()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	filters := make(map[string]interface{}, len(filter)+1)
+	for key, value := range filter {
+		filters[key] = value
+	}
+	for key, value := range r.namespaceFilters() {
+		filters[key] = value
+	}
+	deleted, err := r.vectordbProvider.DeleteByFilter(context.Background(), filters)
+	if deleted > 0 {
+		r.InvalidateCache()
+	}
+	if err != nil {
+		return int(deleted), fmt.Errorf("delete chunks failed, err: %w", err)
+	}
+	return int(deleted), nil
+}
+
+// newOutboundHTTPClient creates the HTTP client used for URL ingestion, honoring pipeline.http
+func newOutboundHTTPClient(cfg *config.Config) *httpx.Client {
+	if cfg.Pipeline != nil {
+		return httpx.NewFromConfig(cfg.Pipeline.HTTP)
+	}
+	return httpx.NewFromConfig(nil)
+}
+
 func (r *RAGClient) CreateChunkFromText(text string, title string) ([]schema.Document, error) {
+	r, done := r.acquire()
+	defer done()
+	release, err := r.reindexGuard.beginIngestion()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.createChunks(text, title, nil)
+}
 
-	docs, err := textsplitter.CreateDocuments(r.textSplitter, []string{text}, make([]map[string]any, 0))
+// CreateChunksFromURL fetches a web page, converts it to readable text and stores its chunks.
+// The page <title> is used when title is empty, and every chunk is tagged with source_url.
+func (r *RAGClient) CreateChunksFromURL(rawURL string, title string) ([]schema.Document, error) {
+	r, done := r.acquire()
+	defer done()
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url: %s", rawURL)
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed, err: %w", err)
+	}
+	req.Header.Set("Accept", "text/html,text/plain;q=0.9")
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch url failed, err: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("fetch url failed, status: %d", resp.StatusCode)
+	}
+
+	body := io.LimitReader(resp.Body, MAX_URL_CONTENT_BYTES)
+	var text, pageTitle string
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "", "text/html", "application/xhtml+xml":
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("read body failed, err: %w", err)
+		}
+		pageTitle, text, err = htmltext.Extract(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("parse html failed, err: %w", err)
+		}
+		// The html splitter needs the markup to keep headings and links
+		if _, ok := textsplitter.Unwrap(r.textSplitter).(textsplitter.HTMLSplitter); ok && strings.TrimSpace(text) != "" {
+			text = string(raw)
+		}
+	case "text/plain", "text/markdown":
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("read body failed, err: %w", err)
+		}
+		text = string(raw)
+	default:
+		return nil, fmt.Errorf("unsupported content type: %s", mediaType)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("no readable text found at %s", rawURL)
+	}
+	if title == "" {
+		title = pageTitle
+	}
+	if title == "" {
+		title = rawURL
+	}
+	release, err := r.reindexGuard.beginIngestion()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.createChunks(text, title, map[string]any{"source_url": rawURL})
+}
+
+// createChunks splits text, embeds each chunk and stores it with the given extra metadata
+func (r *RAGClient) createChunks(text string, title string, extra map[string]any) ([]schema.Document, error) {
+	texts, metadatas, err := r.parentSections(text)
+	if err != nil {
+		return nil, fmt.Errorf("create parent sections failed, err: %w", err)
+	}
+	docs, err := textsplitter.CreateDocuments(r.textSplitter, texts, metadatas)
 	if err != nil {
 		return nil, fmt.Errorf("create documents failed, err: %w", err)
 	}
 
+	// Embed all chunks up front so batching providers cover many chunks per request
+	contents := make([]string, len(docs))
+	for i, doc := range docs {
+		contents[i] = doc.Content
+	}
+	vectors, err := embedding.GetEmbeddings(context.Background(), r.embeddingProvider, contents)
+	if err != nil {
+		return nil, fmt.Errorf("create embedding failed, err: %w", err)
+	}
+	for i := range vectors {
+		vectors[i] = r.normalizeEmbedding(vectors[i])
+	}
+
 	results := make([]schema.Document, 0, len(docs))
 
 	for chunkIndex, doc := range docs {
@@ -400,86 +1101,670 @@ func (r *RAGClient) CreateChunkFromText(text string, title string) ([]schema.Doc
 		doc.Metadata["chunk_index"] = chunkIndex
 		doc.Metadata["chunk_title"] = title
 		doc.Metadata["chunk_size"] = len(doc.Content)
-		// Generate embedding for the document
-		embedding, err := r.embeddingProvider.GetEmbedding(context.Background(), doc.Content)
-		if err != nil {
-			return nil, fmt.Errorf("create embedding failed, err: %w", err)
+		for k, v := range extra {
+			doc.Metadata[k] = v
+		}
+		if r.namespace != "" {
+			doc.Metadata[schema.METADATA_NAMESPACE] = r.namespace
+		}
+		doc.Vector = vectors[chunkIndex]
+		if r.sparseEmbeddingProvider != nil {
+			sparse, err := r.sparseEmbeddingProvider.GetSparseEmbedding(context.Background(), doc.Content)
+			if err != nil {
+				return nil, fmt.Errorf("create sparse embedding failed, err: %w", err)
+			}
+			doc.SparseVector = sparse
 		}
-		doc.Vector = embedding
 		doc.CreatedAt = time.Now()
 		results = append(results, doc)
 	}
 
-	if err := r.vectordbProvider.AddDoc(context.Background(), results); err != nil {
-		return nil, fmt.Errorf("add documents failed, err: %w", err)
+	if err := r.vectordbProvider.AddDoc(context.Background(), results); err != nil {
+		return nil, fmt.Errorf("add documents failed, err: %w", err)
+	}
+	r.InvalidateCache()
+
+	return results, nil
+}
+
+// parentSections returns the texts createChunks splits into chunks with their metadata:
+// text itself without hierarchical chunking, otherwise its parent sections, each with the
+// parent metadata its chunks inherit
+func (r *RAGClient) parentSections(text string) ([]string, []map[string]any, error) {
+	if r.parentSplitter == nil {
+		return []string{text}, nil, nil
+	}
+	sections, err := r.parentSplitter.SplitText(text)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadatas := make([]map[string]any, len(sections))
+	for i, section := range sections {
+		metadatas[i] = map[string]any{
+			schema.METADATA_PARENT_ID:      uuid.New().String(),
+			schema.METADATA_PARENT_CONTENT: section,
+		}
+	}
+	return sections, metadatas, nil
+}
+
+// SearchChunks searches for document chunks
+func (r *RAGClient) SearchChunks(query string, topK int, threshold float64) ([]schema.SearchResult, error) {
+	return r.SearchChunksWithFilter(query, topK, threshold, nil)
+}
+
+// SearchChunksWithFilter searches like SearchChunks, restricted to chunks whose metadata
+// holds every filter entry. Filter values must be strings, numbers or booleans. The
+// filter cannot widen the search beyond the client's namespace.
+func (r *RAGClient) SearchChunksWithFilter(query string, topK int, threshold float64, filter map[string]any) ([]schema.SearchResult, error) {
+	r, done := r.acquire()
+	defer done()
+	return r.searchChunks(query, topK, threshold, filter, false)
+}
+
+// searchChunks runs SearchChunksWithFilter on a snapshot, returning the vectors of the
+// chunks when includeVectors is set
+func (r *RAGClient) searchChunks(query string, topK int, threshold float64, filter map[string]any, includeVectors bool) ([]schema.SearchResult, error) {
+	if err := validateMetadataFilter(filter); err != nil {
+		return nil, err
+	}
+	filters := make(map[string]interface{}, len(filter)+1)
+	for key, value := range filter {
+		filters[key] = value
+	}
+	for key, value := range r.namespaceFilters() {
+		filters[key] = value
+	}
+
+	vector, err := r.queryEmbedder().GetEmbedding(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("create embedding failed, err: %w", err)
+	}
+	vector = r.normalizeEmbedding(vector)
+	options := &schema.SearchOptions{
+		TopK:           topK,
+		Threshold:      threshold,
+		Filters:        filters,
+		IncludeVectors: includeVectors,
+	}
+	docs, err := r.vectordbProvider.SearchDocs(context.Background(), vector, options)
+	if err != nil {
+		return nil, fmt.Errorf("search chunks failed, err: %w", err)
+	}
+	return docs, nil
+}
+
+// queryEmbedder returns the provider that embeds search queries: the circuit breaker when
+// the pipeline is configured, the embedding provider otherwise
+func (r *RAGClient) queryEmbedder() embedding.Provider {
+	if r.embeddingBreaker != nil {
+		return r.embeddingBreaker
+	}
+	return r.embeddingProvider
+}
+
+// validateMetadataFilter checks that every filter value is a string, number or boolean
+func validateMetadataFilter(filter map[string]any) error {
+	for key, value := range filter {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("filter keys must not be empty")
+		}
+		switch value.(type) {
+		case string, bool, int, int32, int64, float32, float64:
+		default:
+			return fmt.Errorf("filter %s: unsupported value type %T, want a string, number or boolean", key, value)
+		}
+	}
+	return nil
+}
+
+// normalizeEmbedding L2-normalizes vector when the COSINE metric is configured with
+// search.normalize, and returns it unchanged otherwise
+func (r *RAGClient) normalizeEmbedding(vector []float32) []float32 {
+	search := r.config.VectorDB.Mapping.Search
+	if !search.Normalize || !strings.EqualFold(search.MetricType, "COSINE") {
+		return vector
+	}
+	return embedding.NormalizeL2(vector)
+}
+
+// hydeVectors returns the normalized HyDE vectors of result in node order
+func (r *RAGClient) hydeVectors(result *pre_retrieve.PreRetrieveResult) [][]float32 {
+	nodes := make([]string, 0, len(result.HyDEVectors))
+	for node, hyde := range result.HyDEVectors {
+		if len(hyde.Vector) > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+	vectors := make([][]float32, 0, len(nodes))
+	for _, node := range nodes {
+		vectors = append(vectors, r.normalizeEmbedding(result.HyDEVectors[node].Vector))
+	}
+	return vectors
+}
+
+// RequestOptions overrides retrieval settings for a single request. Zero values keep the
+// configured defaults; overrides are clamped to the configuration validation limits.
+type RequestOptions struct {
+	TopK      int
+	Threshold *float64
+	Profile   string
+	// Filter restricts results to chunks whose metadata holds every entry. Filtered
+	// requests search the vector store directly instead of running the pipeline.
+	Filter map[string]any
+	// Citations asks chat for inline citation markers and returns the cited chunks
+	Citations bool
+	// IncludeVectors returns the stored vector of every result that is a chunk
+	IncludeVectors bool
+	// MetadataFields keeps only these metadata keys in the returned results; the
+	// stored chunks and the prompt are unaffected (nil => all keys)
+	MetadataFields []string
+}
+
+// ChatResponse is a chat answer together with the documents used to generate it
+type ChatResponse struct {
+	Answer  string                `json:"answer"`
+	Profile string                `json:"profile,omitempty"`
+	Sources []schema.SearchResult `json:"sources"`
+	// Citations lists the sources the answer cites, when requested
+	Citations []Citation       `json:"citations,omitempty"`
+	Metrics   *metrics.Summary `json:"metrics,omitempty"`
+}
+
+// CITATION_SNIPPET_RUNES caps the snippet of a cited chunk
+const CITATION_SNIPPET_RUNES = 200
+
+// Citation is a source an answer cites with the marker [Index]
+type Citation struct {
+	Index   int     `json:"index"`
+	ChunkID string  `json:"chunk_id"`
+	Title   string  `json:"title,omitempty"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// cite validates the citation markers of answer against the numbered docs; it returns the
+// answer without the markers citing no document and the cited documents in citation order
+func cite(answer string, docs []schema.SearchResult) (string, []Citation) {
+	answer, indices := llm.ExtractCitations(answer, len(docs))
+	citations := make([]Citation, 0, len(indices))
+	for _, index := range indices {
+		doc := docs[index-1]
+		snippet := []rune(strings.Join(strings.Fields(doc.Document.Content), " "))
+		if len(snippet) > CITATION_SNIPPET_RUNES {
+			snippet = append(snippet[:CITATION_SNIPPET_RUNES], '…')
+		}
+		citations = append(citations, Citation{
+			Index:   index,
+			ChunkID: doc.Document.ID,
+			Title:   doc.Document.Title(),
+			Score:   doc.Score,
+			Snippet: string(snippet),
+		})
+	}
+	return answer, citations
+}
+
+// topK returns the overridden TopK clamped to [1, MAX_TOP_K], or fallback when unset
+func (o RequestOptions) topK(fallback int) int {
+	if o.TopK <= 0 {
+		return fallback
+	}
+	if o.TopK > config.MAX_TOP_K {
+		return config.MAX_TOP_K
+	}
+	return o.TopK
+}
+
+// threshold returns the overridden threshold clamped to [0, 1], or fallback when unset
+func (o RequestOptions) threshold(fallback float64) float64 {
+	if o.Threshold == nil {
+		return fallback
+	}
+	return math.Min(math.Max(*o.Threshold, 0), 1)
+}
+
+// validateRequestOptions checks that a requested profile can be honored
+func (r *RAGClient) validateRequestOptions(opts RequestOptions) error {
+	if err := validateMetadataFilter(opts.Filter); err != nil {
+		return err
+	}
+	if opts.Profile == "" {
+		return nil
+	}
+	if len(opts.Filter) > 0 {
+		return fmt.Errorf("profile override cannot be combined with a metadata filter")
+	}
+	if r.config.Pipeline == nil || r.retrievalProvider == nil {
+		return fmt.Errorf("profile override requires the enhanced pipeline")
+	}
+	if r.profileProvider.SelectByName(opts.Profile).Name == "" {
+		return fmt.Errorf("unknown retrieval profile: %s", opts.Profile)
+	}
+	return nil
+}
+
+// SearchChunksPipeline searches for document chunks through the enhanced pipeline when it is
+// configured, applying per-request overrides, and falls back to baseline vector search.
+// opts.MetadataFields projects the metadata of the returned results.
+func (r *RAGClient) SearchChunksPipeline(query string, opts RequestOptions) ([]schema.SearchResult, error) {
+	r, done := r.acquire()
+	defer done()
+	results, _, _, err := r.retrieveWithOptions(context.Background(), query, opts)
+	if err != nil {
+		return nil, err
+	}
+	return projectMetadata(results, opts.MetadataFields), nil
+}
+
+// projectMetadata returns results whose metadata holds only the keys in fields, leaving
+// results untouched as they may be shared with caches. It returns results as they are
+// when fields is nil.
+func projectMetadata(results []schema.SearchResult, fields []string) []schema.SearchResult {
+	if fields == nil {
+		return results
+	}
+	projected := make([]schema.SearchResult, len(results))
+	for i, result := range results {
+		metadata := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			if value, ok := result.Document.Metadata[field]; ok {
+				metadata[field] = value
+			}
+		}
+		projected[i] = result
+		projected[i].Document.Metadata = metadata
+	}
+	return projected
+}
+
+// retrieveWithOptions returns the retrieved documents, the name of the profile used and the
+// pipeline metrics record, which is nil when the baseline search served the request
+func (r *RAGClient) retrieveWithOptions(ctx context.Context, query string, opts RequestOptions) ([]schema.SearchResult, string, *metrics.RetrievalMetrics, error) {
+	if err := r.validateRequestOptions(opts); err != nil {
+		return nil, "", nil, err
+	}
+	var m *metrics.RetrievalMetrics
+	if r.config.Pipeline != nil && r.retrievalProvider != nil && len(opts.Filter) == 0 {
+		var results []schema.SearchResult
+		var profileName string
+		var err error
+		results, profileName, m, err = r.runEnhancedPipeline(ctx, query, opts, nil)
+		if err != nil {
+			return nil, profileName, m, fmt.Errorf("retrieve failed, err: %w", err)
+		}
+		if len(results) > 0 {
+			if opts.IncludeVectors {
+				results = r.attachVectors(ctx, results)
+			}
+			return results, profileName, m, nil
+		}
+	}
+	// fallback to baseline
+	docs, err := r.searchChunks(query, opts.topK(r.config.RAG.TopK), opts.threshold(r.config.RAG.Threshold), opts.Filter, opts.IncludeVectors)
+	if err != nil {
+		return nil, "", m, fmt.Errorf("search chunks failed, err: %w", err)
+	}
+	if m != nil {
+		m.FallbackTriggered = true
+	}
+	return docs, "", m, nil
+}
+
+// attachVectors returns results with the stored vectors of the chunks that lack one. The
+// pipeline results may come from cache or from retrievers that do not return vectors, so
+// they are looked up by ID; results that are not chunks, e.g. web results, keep no vector.
+func (r *RAGClient) attachVectors(ctx context.Context, results []schema.SearchResult) []schema.SearchResult {
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		if len(result.Document.Vector) == 0 && result.Document.ID != "" {
+			ids = append(ids, result.Document.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return results
+	}
+	docs, err := r.vectordbProvider.QueryDocs(ctx, &schema.QueryOptions{IDs: ids, Filters: r.namespaceFilters(), Limit: len(ids)})
+	if err != nil {
+		api.LogWarnf("rag: query chunk vectors failed, err: %v", err)
+		return results
+	}
+	vectors := make(map[string][]float32, len(docs))
+	for _, doc := range docs {
+		vectors[doc.ID] = doc.Vector
+	}
+	// Copy so cached results are not modified
+	out := make([]schema.SearchResult, len(results))
+	for i, result := range results {
+		if vector, ok := vectors[result.Document.ID]; ok && len(result.Document.Vector) == 0 {
+			result.Document.Vector = vector
+		}
+		out[i] = result
+	}
+	return out
+}
+
+// Chat generates a response using LLM
+func (r *RAGClient) Chat(query string) (string, error) {
+	resp, err := r.ChatWithSources(query, RequestOptions{})
+	if err != nil {
+		return "", err
+	}
+	return resp.Answer, nil
+}
+
+// ChatWithMetrics generates a response using LLM and returns the pipeline metrics record
+// alongside the answer. The record is nil when the enhanced pipeline is not configured.
+func (r *RAGClient) ChatWithMetrics(query string) (string, *metrics.RetrievalMetrics, error) {
+	r, done := r.acquire()
+	defer done()
+	resp, m, err := r.chat(query, RequestOptions{})
+	if err != nil {
+		return "", m, err
+	}
+	return resp.Answer, m, nil
+}
+
+// ChatWithSources generates a response using LLM and returns the retrieved sources,
+// applying per-request retrieval overrides.
+func (r *RAGClient) ChatWithSources(query string, opts RequestOptions) (*ChatResponse, error) {
+	r, done := r.acquire()
+	defer done()
+	resp, _, err := r.chat(query, opts)
+	return resp, err
+}
+
+// chat runs retrieval and answer generation on a snapshot
+func (r *RAGClient) chat(query string, opts RequestOptions) (*ChatResponse, *metrics.RetrievalMetrics, error) {
+	if r.llmProvider == nil {
+		return nil, nil, fmt.Errorf("llm provider not initialized")
+	}
+	ctx := newChatContext(context.Background())
+	resp, m, err := r.answer(ctx, query, opts)
+	r.logChatMetrics(ctx, m)
+	return resp, m, err
+}
+
+// ChatStreamChunk is one item of a ChatStream answer: a piece of the answer, or, as the
+// last item, the error that ended generation early.
+type ChatStreamChunk struct {
+	Text string
+	Err  error
+}
+
+// ChatStream runs retrieval like Chat, then streams the answer as the LLM generates it.
+// Retrieval, rerank and compression finish before the first chunk is sent; their errors
+// are returned directly. When generation fails, the chunks already produced are followed by
+// an item carrying the error. The channel is closed when the answer is complete, generation
+// fails or ctx is done; callers must drain it or cancel ctx.
+func (r *RAGClient) ChatStream(ctx context.Context, query string) (<-chan ChatStreamChunk, error) {
+	r, done := r.acquire()
+	defer done()
+	return r.chatStream(ctx, query, RequestOptions{})
+}
+
+// chatStream runs ChatStream on a snapshot, applying per-request retrieval overrides
+func (r *RAGClient) chatStream(parent context.Context, query string, opts RequestOptions) (<-chan ChatStreamChunk, error) {
+	if r.llmProvider == nil {
+		return nil, fmt.Errorf("llm provider not initialized")
+	}
+	ctx := r.withAnswerCache(newChatContext(parent))
+	docs, _, m, err := r.retrieveWithOptions(ctx, query, opts)
+	if err == nil {
+		err = r.requireContext(query, docs, m)
+	}
+	if err != nil {
+		r.logChatMetrics(ctx, m)
+		return nil, err
+	}
+	if answer, ok := answerCacheFromContext(ctx).cachedAnswer(); ok {
+		chunks := make(chan ChatStreamChunk, 1)
+		chunks <- ChatStreamChunk{Text: answer}
+		close(chunks)
+		r.logChatMetrics(ctx, m)
+		return chunks, nil
+	}
+	docs, prompt, err := r.fitPrompt(ctx, query, docs, "", m)
+	if err != nil {
+		r.logChatMetrics(ctx, m)
+		return nil, err
+	}
+
+	// Generation outlives the caller's snapshot, so it holds the components itself
+	r.inflight.Add(1)
+	chunks := make(chan ChatStreamChunk, CHAT_STREAM_BUFFER)
+	send := func(chunk ChatStreamChunk) {
+		select {
+		case chunks <- chunk:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer r.inflight.Done()
+		defer close(chunks)
+		answer, err := llm.GenerateCompletionStream(ctx, r.llmProvider, prompt, func(chunk string) {
+			send(ChatStreamChunk{Text: chunk})
+		})
+		if err != nil {
+			api.LogErrorf("rag: stream completion failed, err: %v", err)
+			if m != nil {
+				m.ErrorMsg = err.Error()
+			}
+			send(ChatStreamChunk{Err: err})
+		} else {
+			r.storeAnswer(ctx, docs, answer)
+		}
+		r.logChatMetrics(ctx, m)
+	}()
+	return chunks, nil
+}
+
+// CHAT_STREAM_BUFFER is the number of answer chunks ChatStream buffers ahead of the reader
+const CHAT_STREAM_BUFFER = 16
+
+// newChatContext returns the context of one chat request derived from parent: it tracks
+// LLM usage and leaves logging the metrics record to logChatMetrics, so the log includes
+// answer generation
+func newChatContext(parent context.Context) context.Context {
+	return llm.WithUsageTracker(withDeferredMetricsLog(parent), &llm.UsageTracker{})
+}
+
+// logChatMetrics records the LLM usage tracked on ctx into m, logs m and adds it to the
+// metrics aggregator
+func (r *RAGClient) logChatMetrics(ctx context.Context, m *metrics.RetrievalMetrics) {
+	if m == nil {
+		return
+	}
+	usage := llm.UsageTrackerFromContext(ctx).Usage()
+	m.RecordLLMUsage(usage.Calls, usage.PromptTokens, usage.CompletionTokens)
+	m.LogJSON()
+	r.metricsAggregator.Observe(m)
+}
+
+// NoContextError is returned by chat in rag.no_context_mode fail_closed when retrieval
+// found no document above the threshold, instead of an answer the LLM made up without
+// grounding
+type NoContextError struct {
+	Query string
+}
+
+func (e *NoContextError) Error() string {
+	return fmt.Sprintf("no grounded answer: no documents matched the query %q", e.Query)
+}
+
+// requireContext returns a NoContextError for a chat without retrieved documents when
+// rag.no_context_mode is fail_closed, and records it in m
+func (r *RAGClient) requireContext(query string, docs []schema.SearchResult, m *metrics.RetrievalMetrics) error {
+	if len(docs) > 0 || r.config.RAG.NoContextMode != config.NO_CONTEXT_MODE_FAIL_CLOSED {
+		return nil
+	}
+	err := &NoContextError{Query: query}
+	if m != nil {
+		m.ErrorMsg = err.Error()
+	}
+	return err
+}
+
+// answer retrieves documents for query and generates the answer from them
+func (r *RAGClient) answer(ctx context.Context, query string, opts RequestOptions) (*ChatResponse, *metrics.RetrievalMetrics, error) {
+	ctx = r.withAnswerCache(ctx)
+	// Prefer enhanced pipeline when configured; fallback to baseline search
+	docs, profileName, m, err := r.retrieveWithOptions(ctx, query, opts)
+	if err == nil {
+		err = r.requireContext(query, docs, m)
+	}
+	if err != nil {
+		return nil, m, err
+	}
+	resp, ok := answerCacheFromContext(ctx).cachedAnswer()
+	if !ok {
+		suffix := ""
+		if opts.Citations {
+			suffix = llm.CitationInstruction
+		}
+		var prompt string
+		if docs, prompt, err = r.fitPrompt(ctx, query, docs, suffix, m); err != nil {
+			return nil, m, err
+		}
+		if resp, err = r.llmProvider.GenerateCompletion(ctx, prompt); err != nil {
+			if m != nil {
+				m.ErrorMsg = err.Error()
+			}
+			return nil, m, fmt.Errorf("generate completion failed, err: %w", err)
+		}
+		r.storeAnswer(ctx, docs, resp)
+	}
+	reply := &ChatResponse{Answer: resp, Profile: profileName, Sources: docs}
+	if opts.Citations {
+		reply.Answer, reply.Citations = cite(resp, docs)
+	}
+	reply.Sources = projectMetadata(docs, opts.MetadataFields)
+	return reply, m, nil
+}
+
+type answerCacheKey struct{}
+
+// answerCache carries the cache entry of a chat request from the retrieval pipeline to
+// answer generation when cache.semantic.cache_answer is enabled
+type answerCache struct {
+	keys cacheKeys
+	// hit is the entry that served retrieval, if any
+	hit cacheEntry
+	// indexed is set once the pipeline added keys to the semantic index
+	indexed bool
+}
+
+// withAnswerCache gives ctx an answerCache slot when answers are cached
+func (r *RAGClient) withAnswerCache(ctx context.Context) context.Context {
+	if r.semanticCache == nil || !r.semanticCache.CacheAnswer {
+		return ctx
 	}
+	return context.WithValue(ctx, answerCacheKey{}, &answerCache{})
+}
 
-	return results, nil
+func answerCacheFromContext(ctx context.Context) *answerCache {
+	c, _ := ctx.Value(answerCacheKey{}).(*answerCache)
+	return c
 }
 
-// SearchChunks searches for document chunks
-func (r *RAGClient) SearchChunks(query string, topK int, threshold float64) ([]schema.SearchResult, error) {
+// cachedAnswer returns the answer cached with the entry that served retrieval
+func (c *answerCache) cachedAnswer() (string, bool) {
+	if c == nil || c.hit.Answer == "" {
+		return "", false
+	}
+	return c.hit.Answer, true
+}
 
-	vector, err := r.embeddingProvider.GetEmbedding(context.Background(), query)
-	if err != nil {
-		return nil, fmt.Errorf("create embedding failed, err: %w", err)
+// storeAnswer caches answer with the documents it was generated from under the query's
+// own key, so the next similar query skips the LLM
+func (r *RAGClient) storeAnswer(ctx context.Context, docs []schema.SearchResult, answer string) {
+	c := answerCacheFromContext(ctx)
+	if c == nil || c.keys.l1 == "" || answer == "" {
+		return
 	}
-	options := &schema.SearchOptions{
-		TopK:      topK,
-		Threshold: threshold,
+	r.cachePut(c.keys, cacheEntry{Results: docs, Embedding: c.keys.embedding, Answer: answer}, !c.indexed)
+}
+
+// ExplainChat runs the retrieval pipeline for query without calling the LLM and
+// returns a structured trace of what each stage did, along with the prompt the
+// answer would be generated from. The L1 cache is bypassed so every stage is executed.
+func (r *RAGClient) ExplainChat(query string) (*PipelineTrace, error) {
+	r, done := r.acquire()
+	defer done()
+	trace, results, err := r.explain(query)
+	if err != nil {
+		return nil, err
 	}
-	docs, err := r.vectordbProvider.SearchDocs(context.Background(), vector, options)
+	_, prompt, err := r.fitPrompt(context.Background(), query, results, "", nil)
 	if err != nil {
-		return nil, fmt.Errorf("search chunks failed, err: %w", err)
+		return nil, err
 	}
-	return docs, nil
+	trace.Prompt = prompt
+	return trace, nil
 }
 
-// Chat generates a response using LLM
-func (r *RAGClient) Chat(query string) (string, error) {
-	if r.llmProvider == nil {
-		return "", fmt.Errorf("llm provider not initialized")
-	}
+// Explain is the retrieval-only counterpart of ExplainChat: it returns the pipeline
+// trace of query without rendering the answer prompt.
+func (r *RAGClient) Explain(query string) (*PipelineTrace, error) {
+	r, done := r.acquire()
+	defer done()
+	trace, _, err := r.explain(query)
+	return trace, err
+}
 
-	var contexts []string
-	// Prefer enhanced pipeline when configured; fallback to baseline search
+func (r *RAGClient) explain(query string) (*PipelineTrace, []schema.SearchResult, error) {
+	trace := newPipelineTrace(query)
+	var results []schema.SearchResult
 	if r.config.Pipeline != nil && r.retrievalProvider != nil {
-		// Use provider-based pipeline
-		results := r.runEnhancedPipeline(context.Background(), query)
-		if len(results) == 0 {
-			// fallback to baseline
-			docs, err := r.SearchChunks(query, r.config.RAG.TopK, r.config.RAG.Threshold)
-			if err != nil {
-				return "", fmt.Errorf("search chunks failed, err: %w", err)
-			}
-			for _, doc := range docs {
-				contexts = append(contexts, strings.ReplaceAll(doc.Document.Content, "\n", " "))
-			}
-		} else {
-			for _, doc := range results {
-				contexts = append(contexts, strings.ReplaceAll(doc.Document.Content, "\n", " "))
-			}
+		var err error
+		// Explain runs are diagnostics: their metrics record feeds the trace only, and is
+		// neither logged nor aggregated
+		results, _, _, err = r.runEnhancedPipeline(withDeferredMetricsLog(context.Background()), query, RequestOptions{}, trace)
+		if err != nil {
+			return nil, nil, fmt.Errorf("retrieve failed, err: %w", err)
 		}
 	} else {
 		docs, err := r.SearchChunks(query, r.config.RAG.TopK, r.config.RAG.Threshold)
 		if err != nil {
-			return "", fmt.Errorf("search chunks failed, err: %w", err)
-		}
-		for _, doc := range docs {
-			contexts = append(contexts, strings.ReplaceAll(doc.Document.Content, "\n", " "))
+			return nil, nil, fmt.Errorf("search chunks failed, err: %w", err)
 		}
+		results = docs
+		trace.Profile = TraceProfile{Name: "baseline", Source: "baseline", TopK: r.config.RAG.TopK, Threshold: r.config.RAG.Threshold}
+		trace.finish(nil, results)
 	}
+	return trace, results, nil
+}
 
-	prompt := llm.BuildPrompt(query, contexts, "\n\n")
-	resp, err := r.llmProvider.GenerateCompletion(context.Background(), prompt)
-	if err != nil {
-		return "", fmt.Errorf("generate completion failed, err: %w", err)
+// buildPrompt renders the answer prompt with numbered contexts so the answer can
+// cite them as [1], [2], ...
+func (r *RAGClient) buildPrompt(query string, docs []schema.SearchResult) (string, error) {
+	tmpl := r.promptTemplate
+	if tmpl == nil {
+		defaultTmpl, err := llm.NewPromptTemplate("")
+		if err != nil {
+			return "", err
+		}
+		tmpl = defaultTmpl
 	}
-	return resp, nil
+	contents := make([]string, 0, len(docs))
+	titles := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		contents = append(contents, strings.ReplaceAll(doc.Document.Content, "\n", " "))
+		titles = append(titles, doc.Document.Title())
+	}
+	return tmpl.Render(query, llm.NewPromptContexts(contents, titles))
 }
 
-// runEnhancedPipeline executes the enhanced RAG pipeline using providers
-func (r *RAGClient) runEnhancedPipeline(ctx context.Context, query string) []schema.SearchResult {
+// runEnhancedPipeline executes the enhanced RAG pipeline using providers and returns the
+// results with the name of the profile used. Request options override the selected profile.
+// When trace is non-nil, stage details are recorded into it and the L1 cache is bypassed.
+// An error is returned only when retrieval is degraded below the profile's tolerance.
+func (r *RAGClient) runEnhancedPipeline(ctx context.Context, query string, opts RequestOptions, trace *PipelineTrace) ([]schema.SearchResult, string, *metrics.RetrievalMetrics, error) {
+	ctx = retriever.WithFilters(ctx, r.namespaceFilters())
+	if llm.UsageTrackerFromContext(ctx) == nil {
+		ctx = llm.WithUsageTracker(ctx, &llm.UsageTracker{})
+	}
 	var metricsRecord *metrics.RetrievalMetrics
 	if r.config.Pipeline != nil {
 		metricsRecord = metrics.NewRetrievalMetrics()
@@ -497,21 +1782,58 @@ func (r *RAGClient) runEnhancedPipeline(ctx context.Context, query string) []sch
 			profileSource = "default_profile"
 		}
 	}
+	if opts.Profile != "" {
+		if p := r.profileProvider.SelectByName(opts.Profile); p.Name != "" {
+			prof = p
+			profileSource = "request"
+		}
+	}
 	prof = r.profileProvider.Normalize(prof)
 
-	// Router decision
-	if r.routerProvider != nil {
+	// Language routing: tag the query language for the router rules and switch to the
+	// profile serving it, unless the profile was requested or already serves it
+	if lc := r.config.Pipeline.Language; lc != nil && lc.Enable {
+		language := pre_retrieve.DetectLanguage(query)
+		if language == "" {
+			language = strings.ToLower(lc.Fallback)
+		}
+		if language != "" {
+			ctx = router.WithQueryLanguage(ctx, language)
+			if metricsRecord != nil {
+				metricsRecord.QueryLanguage = language
+			}
+			if profileSource != "request" && !slices.ContainsFunc(prof.Languages, func(l string) bool { return strings.EqualFold(l, language) }) {
+				if p := r.profileProvider.SelectByLanguage(language); p.Name != "" {
+					prof = p
+					profileSource = "language"
+				}
+			}
+		}
+	}
+
+	// Router decision; an explicitly requested profile takes precedence
+	intent := ""
+	if r.routerProvider != nil && profileSource != "request" {
 		if metricsRecord != nil {
 			metricsRecord.RouterEnabled = true
 			if r.config.Pipeline.Router != nil {
 				metricsRecord.RouterProvider = r.config.Pipeline.Router.Provider
 			}
 		}
-		if decision, err := r.routerProvider.Route(ctx, query); err != nil {
+		decision, err := r.routerProvider.Route(ctx, query)
+		if trace != nil {
+			provider := ""
+			if r.config.Pipeline.Router != nil {
+				provider = r.config.Pipeline.Router.Provider
+			}
+			trace.recordRouter(provider, decision, err)
+		}
+		if err != nil {
 			if metricsRecord != nil {
 				metricsRecord.RouterError = err.Error()
 			}
 		} else if decision != nil {
+			intent = decision.QueryType
 			if metricsRecord != nil {
 				metricsRecord.RouterProfile = decision.ProfileName
 				resetMap(metricsRecord.RouterVariants)
@@ -531,15 +1853,52 @@ func (r *RAGClient) runEnhancedPipeline(ctx context.Context, query string) []sch
 		}
 	}
 
+	// The vector retriever and gating embed the query with the profile's model
+	queryEmbedder := r.queryEmbedderFor(prof.Name)
+	if queryEmbedder != r.embeddingBreaker {
+		ctx = retriever.WithEmbedder(ctx, queryEmbedder)
+	}
+
+	// Degraded mode: skip vector retrieval and its gating preflight while the embedding
+	// circuit is open; the breaker lets a request through again after its cooldown
+	embeddingDown := queryEmbedder != nil && queryEmbedder.Open()
+	if embeddingDown {
+		api.LogWarnf("rag: degraded mode, embedding provider unavailable; skipping vector retrieval for profile=%s", prof.Name)
+		prof = gating.SkipVector(prof)
+		if metricsRecord != nil {
+			metricsRecord.RecordEmbeddingError(embedding.ErrCircuitOpen)
+		}
+	}
+
 	// Gating decision
-	if r.gatingProvider != nil && (prof.VectorGate > 0 || prof.VectorLowGate > 0) {
+	if !embeddingDown && r.gatingProvider != nil && (prof.VectorGate > 0 || prof.VectorLowGate > 0) {
 		decision := r.gatingProvider.Evaluate(ctx, query, prof, metricsRecord)
+		if trace != nil {
+			trace.recordGating(decision, prof.VectorGate, prof.VectorLowGate)
+		}
 		prof = r.gatingProvider.ApplyDecision(decision, prof)
 		prof = r.profileProvider.Normalize(prof)
 	}
 
+	// Per-request overrides
+	prof.TopK = opts.topK(prof.TopK)
+	if prof.PerRetrieverTopK < prof.TopK {
+		prof.PerRetrieverTopK = prof.TopK
+	}
+	prof.Threshold = opts.threshold(prof.Threshold)
+
+	if trace != nil {
+		trace.Profile = TraceProfile{
+			Retrievers:     append([]string(nil), prof.Retrievers...),
+			VariantBudgets: copyBudgets(prof.VariantBudgets),
+			TopK:           prof.TopK,
+			Threshold:      prof.Threshold,
+		}
+	}
+
 	if metricsRecord != nil {
 		metricsRecord.RecordProfileSelection(prof.Name, profileSource)
+		metricsRecord.RecordAppliedProfile(prof.Retrievers, prof.VariantBudgets)
 		if len(prof.VariantBudgets) > 0 && len(metricsRecord.RouterVariants) == 0 {
 			resetMap(metricsRecord.RouterVariants)
 			for k, v := range prof.VariantBudgets {
@@ -548,30 +1907,79 @@ func (r *RAGClient) runEnhancedPipeline(ctx context.Context, query string) []sch
 		}
 	}
 
-	cacheKey := ""
-	if r.l1Cache != nil && r.cacheMode == "post" {
-		cacheKey = r.buildCacheKey(query, prof)
-		if cached, ok := r.l1Cache.Get(cacheKey); ok {
-			if docs, ok := cached.([]schema.SearchResult); ok {
-				api.LogInfof("rag: L1 cache hit for profile=%s", prof.Name)
-				if metricsRecord != nil {
-					metricsRecord.Success = true
-					metricsRecord.LogJSON()
-				}
-				return cloneResults(docs)
+	var keys cacheKeys
+	if r.cacheMode == "post" && trace == nil {
+		keys = r.cacheKeysFor(query, prof)
+		if metricsRecord != nil {
+			metricsRecord.CacheEnabled = true
+		}
+		entry, layer, ok := r.cacheGet(keys)
+		hitType, similarity := metrics.CACHE_HIT_EXACT, 0.0
+		// On a miss, the semantic cache embeds the query to look a similar one up
+		if !ok && r.semanticCache != nil && !embeddingDown && queryEmbedder != nil {
+			vector, err := queryEmbedder.GetEmbedding(ctx, query)
+			if err != nil {
+				api.LogWarnf("rag: semantic cache skipped, embed query failed: %v", err)
+			} else {
+				keys.embedding = vector
+				hitType = metrics.CACHE_HIT_SEMANTIC
+				entry, layer, similarity, ok = r.semanticCacheGet(keys)
+			}
+		}
+		if slot := answerCacheFromContext(ctx); slot != nil {
+			slot.keys = keys
+		}
+		if ok {
+			api.LogInfof("rag: %s %s cache hit for profile=%s (results=%d)", layer, hitType, prof.Name, len(entry.Results))
+			if slot := answerCacheFromContext(ctx); slot != nil {
+				slot.hit = entry
+			}
+			if metricsRecord != nil {
+				metricsRecord.CacheHit = true
+				metricsRecord.CacheHitType = hitType
+				metricsRecord.CacheSimilarity = similarity
+				metricsRecord.Success = len(entry.Results) > 0
+				metricsRecord.TotalLatencyMs = time.Since(metricsRecord.Timestamp).Milliseconds()
+				r.finishMetrics(ctx, metricsRecord)
 			}
+			return cloneResults(entry.Results), prof.Name, metricsRecord, nil
 		}
 	}
 
 	// Pre-retrieve processing
 	queries := []string{query}
 	originalQuery := query
-	if r.config.Pipeline != nil && r.config.Pipeline.EnablePre && r.preRetrieveProvider != nil {
+	preServiceUsed := false
+	var preResult *pre_retrieve.PreRetrieveResult
+	if r.preService != nil {
+		req := &precontractv1.PreprocessRequest{Query: query}
+		resp, err := r.preService.Generate(ctx, req)
+		if err != nil {
+			api.LogWarnf("rag: pre.service failed: %v, falling back to built-in pre-retrieve", err)
+		} else if result := preservice.ToPreRetrieveResult(req, resp); result != nil {
+			preResult = result
+			queries = make([]string, 0, len(result.Plan.Nodes))
+			for _, node := range result.Plan.Nodes {
+				queries = append(queries, node.DenseRewrite)
+			}
+			originalQuery = result.AlignedQuery.Query
+			preServiceUsed = true
+			if metricsRecord != nil {
+				metricsRecord.AddRetrievalPhase("pre_service")
+			}
+			api.LogInfof("rag: pre.service generated %d sub-queries", len(queries))
+		}
+	}
+	if !preServiceUsed && r.config.Pipeline != nil && r.config.Pipeline.EnablePre && r.preRetrieveProvider != nil {
 		sessionID := "" // TODO: Extract from context or request if available
 		result, err := r.preRetrieveProvider.Process(ctx, query, sessionID)
+		if trace != nil && err != nil {
+			trace.PreRetrieve = &TracePreRetrieve{Queries: []string{query}, Error: err.Error()}
+		}
 		if err != nil {
 			api.LogWarnf("rag: pre-retrieve processing failed: %v, using original query", err)
 		} else if result != nil {
+			preResult = result
 			// Extract queries from the plan nodes
 			if len(result.Plan.Nodes) > 0 {
 				queries = make([]string, 0, len(result.Plan.Nodes))
@@ -602,19 +2010,74 @@ func (r *RAGClient) runEnhancedPipeline(ctx context.Context, query string) []sch
 					queries = []string{originalQuery}
 				}
 			}
+			// A translated query keeps its source-language form so lexical and sparse
+			// retrievers can still match documents written in the query language
+			if source := result.AlignedQuery.OriginalQuery; source != "" && !slices.Contains(queries, source) {
+				queries = append(queries, source)
+			}
 		}
 	}
 
-	// Retrieval
-	results := r.retrievalProvider.Retrieve(ctx, queries, prof, metricsRecord)
+	if trace != nil && r.config.Pipeline.EnablePre && (r.preRetrieveProvider != nil || preServiceUsed) && trace.PreRetrieve == nil {
+		trace.PreRetrieve = &TracePreRetrieve{AlignedQuery: originalQuery, Queries: append([]string(nil), queries...)}
+	}
+
+	if pre := r.config.Pipeline.Pre; pre != nil && pre.Rewrite.Enable {
+		ctx = retriever.WithQueryVariants(ctx, rewriteVariants(pre.Rewrite.Variants, queries, preResult), prof.MaxFanout)
+	}
+
+	// HyDE vectors come from the global embedding model, so they are only searched when the
+	// profile embeds its queries with it too
+	if preResult != nil && len(preResult.HyDEVectors) > 0 && !embeddingDown && queryEmbedder == r.embeddingBreaker {
+		ctx = retriever.WithHyDEVectors(ctx, r.hydeVectors(preResult))
+	}
+
+	// Hits are expanded to their parents after reranking, so TopK counts parents and more
+	// chunks are retrieved to leave enough distinct ones
+	retrieveProf := prof
+	if prof.ExpandParents {
+		retrieveProf.TopK = prof.TopK * post.PARENT_EXPANSION_FACTOR
+		retrieveProf.PerRetrieverTopK = max(prof.PerRetrieverTopK, retrieveProf.TopK)
+	}
+
+	// Retrieval; at its deadline the searches still running fail and the retrievers that
+	// answered in time are fused
+	retrieveCtx := ctx
+	retrievalTimeout := r.stageTimeout(STAGE_RETRIEVAL)
+	if retrievalTimeout > 0 {
+		var cancel context.CancelFunc
+		retrieveCtx, cancel = context.WithTimeout(ctx, retrievalTimeout)
+		defer cancel()
+	}
+	if trace != nil {
+		retrieveCtx = retrieval.WithFusionTrace(retrieveCtx, &trace.lists)
+	}
+	results, err := r.retrievalProvider.Retrieve(retrieveCtx, queries, retrieveProf, metricsRecord)
+	if errors.Is(retrieveCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		api.LogWarnf("rag: %v, using the results of the retrievers that answered", stageTimeoutError(STAGE_RETRIEVAL, retrievalTimeout))
+		if metricsRecord != nil {
+			metricsRecord.RecordStageTimeout(STAGE_RETRIEVAL)
+		}
+	}
+	if metricsRecord != nil {
+		metricsRecord.QueryVariantsExecuted = retriever.ExecutedVariants(ctx)
+	}
+	if err != nil {
+		if metricsRecord != nil {
+			metricsRecord.ErrorMsg = err.Error()
+			metricsRecord.TotalLatencyMs = time.Since(metricsRecord.Timestamp).Milliseconds()
+			r.finishMetrics(ctx, metricsRecord)
+		}
+		if trace != nil {
+			trace.finish(metricsRecord, nil)
+		}
+		return nil, prof.Name, metricsRecord, err
+	}
 
 	if metricsRecord != nil {
 		metricsRecord.TotalRetrieved = len(results)
-		if version := metricsRecord.FusionWeightsVersion; version != "" && r.cacheFusionVersion != version {
-			if r.l1Cache != nil && r.cacheFusionVersion != "" {
-				r.l1Cache.Purge()
-			}
-			r.cacheFusionVersion = version
+		if version := metricsRecord.FusionWeightsVersion; version != "" {
+			r.cacheFusionVersion.observe(version, r.purgeCaches)
 		}
 	}
 
@@ -625,8 +2088,24 @@ func (r *RAGClient) runEnhancedPipeline(ctx context.Context, query string) []sch
 		if topN <= 0 || topN > len(results) {
 			topN = len(results)
 		}
-		if reranked, err := r.reranker.Rerank(ctx, originalQuery, results, topN); err == nil && len(reranked) > 0 {
-			results = reranked
+		before := results
+		timeout := r.stageTimeout(STAGE_RERANK)
+		reranked, ok := runStage(ctx, timeout, results, func(ctx context.Context, in []schema.SearchResult) stageResults {
+			out, err := r.reranker.Rerank(ctx, originalQuery, in, topN)
+			return stageResults{out, err}
+		})
+		err := reranked.err
+		if !ok {
+			err = stageTimeoutError(STAGE_RERANK, timeout)
+			api.LogWarnf("rag: %v, keeping the fused order", err)
+			if metricsRecord != nil {
+				metricsRecord.RecordStageTimeout(STAGE_RERANK)
+			}
+		} else if err == nil && len(reranked.results) > 0 {
+			results = reranked.results
+		}
+		if trace != nil {
+			trace.recordRerank(before, results, err)
 		}
 		if metricsRecord != nil {
 			metricsRecord.RerankEnabled = true
@@ -634,26 +2113,82 @@ func (r *RAGClient) runEnhancedPipeline(ctx context.Context, query string) []sch
 		}
 	}
 
+	// Parent expansion follows reranking, which scores the precise chunks
+	if len(results) > 0 && prof.ExpandParents {
+		var expanded int
+		results, expanded = post.ExpandParents(results, prof.TopK)
+		if metricsRecord != nil {
+			metricsRecord.ParentsExpanded = expanded
+		}
+	}
+
+	// MMR diversification drops near-duplicates before compression spends effort on them
+	if len(results) > 0 && r.config.Pipeline.EnablePost && r.mmr != nil {
+		before := results
+		diversified, err := r.mmr.Diversify(ctx, results)
+		if err != nil {
+			api.LogWarnf("rag: mmr failed: %v, keeping the ranked results", err)
+		} else {
+			results = diversified
+		}
+		if trace != nil {
+			trace.recordMMR(before, results, err)
+		}
+		if metricsRecord != nil {
+			metricsRecord.MMREnabled = true
+			metricsRecord.MMRResultCount = len(results)
+		}
+	}
+
 	// Compression with advanced compressor support
 	if len(results) > 0 && r.config.Pipeline.EnablePost && r.config.Pipeline.Post != nil &&
 		r.config.Pipeline.Post.Compress.Enable {
+		var before []schema.SearchResult
+		var compressErr error
+		if trace != nil {
+			before = cloneResults(results)
+		}
+		uncompressed := results
 		if r.compressor != nil {
 			// Use advanced compressor with query awareness
-			compressed, err := r.compressor.BatchCompress(ctx, results, originalQuery)
-			if err != nil {
-				api.LogWarnf("rag: compression failed: %v, using uncompressed results", err)
-			} else if len(compressed) > 0 {
-				results = compressed
+			timeout := r.stageTimeout(STAGE_COMPRESS)
+			compressed, ok := runStage(ctx, timeout, results, func(ctx context.Context, in []schema.SearchResult) stageResults {
+				out, err := r.compressor.BatchCompress(ctx, in, originalQuery)
+				return stageResults{out, err}
+			})
+			compressErr = compressed.err
+			if !ok {
+				compressErr = stageTimeoutError(STAGE_COMPRESS, timeout)
+				api.LogWarnf("rag: %v, using uncompressed results", compressErr)
+				if metricsRecord != nil {
+					metricsRecord.RecordStageTimeout(STAGE_COMPRESS)
+				}
+			} else if compressErr != nil {
+				api.LogWarnf("rag: compression failed: %v, using uncompressed results", compressErr)
+			} else if len(compressed.results) > 0 {
+				results = compressed.results
 			}
 		} else {
 			// Fallback to simple truncate compression
 			ratio := r.config.Pipeline.Post.Compress.TargetRatio
-			for i := range results {
-				results[i].Document.Content = post.CompressText(results[i].Document.Content, ratio)
+			mode := r.config.Pipeline.Post.Compress.Mode
+			compressed := make([]schema.SearchResult, len(results))
+			for i, result := range results {
+				result.Document.Content = post.CompressTextWithMode(result.Document.Content, ratio, mode)
+				compressed[i] = result
 			}
+			results = compressed
+		}
+		if trace != nil {
+			trace.recordCompression(before, results, compressErr)
 		}
 		if metricsRecord != nil {
-			metricsRecord.CompressEnabled = true
+			method := r.config.Pipeline.Post.Compress.Method
+			if method == "" {
+				method = "truncate"
+			}
+			stats := post.BatchCompressionStats(uncompressed, results)
+			metricsRecord.RecordCompression(method, stats.OriginalLength, stats.CompressedLength, stats.CompressionRatio)
 		}
 	}
 
@@ -668,10 +2203,21 @@ func (r *RAGClient) runEnhancedPipeline(ctx context.Context, query string) []sch
 			builder.WriteString(results[i].Document.Content)
 			builder.WriteString("\n\n")
 		}
-		_, verdict, err := r.evaluator.Evaluate(ctx, originalQuery, builder.String())
-		if err == nil {
-			if r.feedbackManager != nil {
-				r.feedbackManager.Record(prof.Name, verdict, 0)
+		// Per-intent thresholds use the router query type, or the profile name without one
+		if intent == "" {
+			intent = prof.Name
+		}
+		// The evaluation and its corrective action share the stage deadline
+		type cragOutcome struct {
+			score   float64
+			verdict crag.Verdict
+			stageResults
+		}
+		timeout := r.stageTimeout(STAGE_CRAG)
+		outcome, ok := runStage(ctx, timeout, results, func(ctx context.Context, in []schema.SearchResult) cragOutcome {
+			score, verdict, err := r.evaluator.Evaluate(crag.WithIntent(ctx, intent), originalQuery, builder.String())
+			if err != nil {
+				return cragOutcome{score, verdict, stageResults{in, err}}
 			}
 			// Build ActionContext for CRAG actions
 			actionCtx := &crag.ActionContext{
@@ -680,41 +2226,370 @@ func (r *RAGClient) runEnhancedPipeline(ctx context.Context, query string) []sch
 				WebSearcher:   r.webSearcher,
 				QueryRewriter: r.queryRewriter,
 				Refiner:       r.refiner,
+				MaxWebCalls:   r.config.Pipeline.CRAG.MaxWebCalls,
+				MaxCombined:   r.config.Pipeline.CRAG.MaxCombinedResults,
 			}
 			switch verdict {
 			case crag.VerdictCorrect:
-				results = crag.CorrectAction(actionCtx, results)
+				in = crag.CorrectAction(actionCtx, in)
 			case crag.VerdictIncorrect:
-				results = crag.IncorrectAction(actionCtx)
+				in = crag.IncorrectAction(actionCtx)
 			case crag.VerdictAmbiguous:
-				results = crag.AmbiguousAction(actionCtx, results, nil)
+				in = crag.AmbiguousAction(actionCtx, in, nil)
+			}
+			return cragOutcome{score, verdict, stageResults{in, nil}}
+		})
+		score, verdict, err := outcome.score, outcome.verdict, outcome.err
+		if !ok {
+			err = stageTimeoutError(STAGE_CRAG, timeout)
+			api.LogWarnf("rag: %v, keeping the results without correction", err)
+			if metricsRecord != nil {
+				metricsRecord.RecordStageTimeout(STAGE_CRAG)
+			}
+		}
+		if trace != nil {
+			trace.CRAG = &TraceCRAG{Score: score}
+			if ok {
+				trace.CRAG.Verdict = verdict.String()
+			}
+			if err != nil {
+				trace.CRAG.Error = err.Error()
+			}
+		}
+		if err == nil {
+			// Explain runs must not steer the gating feedback loop
+			if r.feedbackManager != nil && trace == nil {
+				r.feedbackManager.Record(prof.Name, verdict, 0)
 			}
+			results = outcome.results
 			if metricsRecord != nil {
 				metricsRecord.CRAGEnabled = true
 				metricsRecord.CRAGVerdict = verdict.String()
+				metricsRecord.CRAGScore = score
 			}
 		}
 	}
 
-	if r.l1Cache != nil && r.cacheMode == "post" && cacheKey != "" && len(results) > 0 {
-		r.l1Cache.Set(cacheKey, cloneResults(results), 0)
+	if keys.l1 != "" {
+		r.cachePut(keys, cacheEntry{Results: results, Embedding: keys.embedding}, true)
+		if slot := answerCacheFromContext(ctx); slot != nil {
+			slot.indexed = true
+		}
 	}
 
 	if metricsRecord != nil {
 		metricsRecord.Success = len(results) > 0
-		metricsRecord.LogJSON()
+		metricsRecord.TotalLatencyMs = time.Since(metricsRecord.Timestamp).Milliseconds()
+		r.finishMetrics(ctx, metricsRecord)
+	}
+	if trace != nil {
+		trace.finish(metricsRecord, results)
 	}
 
-	return results
+	return results, prof.Name, metricsRecord, nil
+}
+
+type deferredMetricsLogKey struct{}
+
+// withDeferredMetricsLog marks ctx so the pipeline leaves logging its metrics record to the caller
+func withDeferredMetricsLog(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deferredMetricsLogKey{}, true)
+}
+
+// finishMetrics copies the LLM usage tracked on ctx into m, then logs it and adds it to the
+// metrics aggregator, unless the caller does so itself
+func (r *RAGClient) finishMetrics(ctx context.Context, m *metrics.RetrievalMetrics) {
+	if tracker := llm.UsageTrackerFromContext(ctx); tracker != nil {
+		usage := tracker.Usage()
+		m.RecordLLMUsage(usage.Calls, usage.PromptTokens, usage.CompletionTokens)
+	}
+	if deferred, _ := ctx.Value(deferredMetricsLogKey{}).(bool); !deferred {
+		m.LogJSON()
+		r.metricsAggregator.Observe(m)
+	}
 }
 
 func (r *RAGClient) buildCacheKey(query string, profile config.RetrievalProfile) string {
+	return r.cacheKeyAt(query, profile, r.cacheIndexVersion(r.namespace))
+}
+
+// buildL2CacheKey keys on the collection only: write counts are local to each gateway
+// instance, so writes purge the shared L2 cache instead of changing its keys
+func (r *RAGClient) buildL2CacheKey(query string, profile config.RetrievalProfile) string {
+	return r.cacheKeyAt(query, profile, r.indexVersion)
+}
+
+func (r *RAGClient) cacheKeyAt(query string, profile config.RetrievalProfile, indexVersion string) string {
 	normalized := strings.ToLower(strings.TrimSpace(query))
-	base := fmt.Sprintf("%s|%s|%s|%d|%d|%s|%s", normalized, profile.Name, r.indexVersion, profile.TopK, r.rerankTopN(), budgetsSignature(profile.VariantBudgets), r.cacheFusionVersion)
+	hash := sha1.Sum([]byte(normalized + "|" + r.cacheScopeAt(profile, indexVersion)))
+	return hex.EncodeToString(hash[:])
+}
+
+// cacheScopeAt identifies everything but the query that cached results depend on: the
+// namespace, profile, options and index version. The semantic cache only serves a query
+// from an entry of the same scope.
+func (r *RAGClient) cacheScopeAt(profile config.RetrievalProfile, indexVersion string) string {
+	base := fmt.Sprintf("%s|%s|%s|%d|%.4f|%d|%s|%s", r.namespace, profile.Name, indexVersion, profile.TopK, profile.Threshold, r.rerankTopN(), budgetsSignature(profile.VariantBudgets), r.cacheFusionVersion.get())
 	hash := sha1.Sum([]byte(base))
 	return hex.EncodeToString(hash[:])
 }
 
+// cacheKeys locates the cache entry of one query in both layers. embedding is the query's
+// embedding, set once the semantic cache has computed it.
+type cacheKeys struct {
+	l1, l2           string
+	l1Scope, l2Scope string
+	embedding        []float32
+}
+
+func (r *RAGClient) cacheKeysFor(query string, profile config.RetrievalProfile) cacheKeys {
+	l1Version := r.cacheIndexVersion(r.namespace)
+	return cacheKeys{
+		l1:      r.cacheKeyAt(query, profile, l1Version),
+		l2:      r.cacheKeyAt(query, profile, r.indexVersion),
+		l1Scope: r.cacheScopeAt(profile, l1Version),
+		l2Scope: r.cacheScopeAt(profile, r.indexVersion),
+	}
+}
+
+// cacheGet looks keys up in the L1 cache, then in the L2 cache, and returns the entry with
+// the layer that held it. An L2 hit is copied into L1.
+func (r *RAGClient) cacheGet(keys cacheKeys) (cacheEntry, string, bool) {
+	if r.l1Cache != nil {
+		if cached, ok := r.l1Cache.Get(keys.l1); ok {
+			if entry, ok := cached.(l1Entry); ok {
+				return entry.cacheEntry, "L1", true
+			}
+		}
+	}
+	if r.l2Cache != nil {
+		if entry, ok := r.l2Cache.Get(keys.l2); ok {
+			if r.l1Cache != nil {
+				r.l1Cache.Set(keys.l1, r.newL1Entry(keys.l1Scope, entry), r.cacheTTL(entry.Results))
+			}
+			return entry, "L2", true
+		}
+	}
+	return cacheEntry{}, "", false
+}
+
+// semanticCacheGet returns the entry of the cached query of the same scope most similar to
+// keys.embedding, if at least the configured threshold, with the layer that held it and
+// the similarity. L1 is searched before L2.
+func (r *RAGClient) semanticCacheGet(keys cacheKeys) (cacheEntry, string, float64, bool) {
+	threshold := r.semanticCache.Threshold
+	if r.l1Cache != nil {
+		var best cacheEntry
+		bestSimilarity, found := 0.0, false
+		r.l1Cache.Range(func(_ string, value any) bool {
+			entry, ok := value.(l1Entry)
+			if !ok || entry.scope != keys.l1Scope || len(entry.Embedding) == 0 {
+				return true
+			}
+			if similarity := embedding.CosineSimilarity(keys.embedding, entry.Embedding); similarity >= threshold && (!found || similarity > bestSimilarity) {
+				best, bestSimilarity, found = entry.cacheEntry, similarity, true
+			}
+			return true
+		})
+		if found {
+			return best, "L1", bestSimilarity, true
+		}
+	}
+	if r.l2Cache != nil {
+		for _, similar := range r.l2Cache.Similar(keys.l2Scope, keys.embedding, threshold) {
+			if entry, ok := r.l2Cache.Get(similar.Key); ok {
+				return entry, "L2", similar.Similarity, true
+			}
+		}
+	}
+	return cacheEntry{}, "", 0, false
+}
+
+// cachePut stores entry in both cache layers, and with index adds it to the L2 semantic
+// index when it carries the query's embedding. Empty results are only cached when
+// negative caching is enabled, and then for its short TTL.
+func (r *RAGClient) cachePut(keys cacheKeys, entry cacheEntry, index bool) {
+	if len(entry.Results) == 0 && r.cacheEmptyTTL <= 0 {
+		return
+	}
+	ttl := r.cacheTTL(entry.Results)
+	if r.l1Cache != nil {
+		r.l1Cache.Set(keys.l1, r.newL1Entry(keys.l1Scope, entry), ttl)
+	}
+	if r.l2Cache != nil {
+		r.l2Cache.Set(keys.l2, entry, ttl)
+		if index && len(entry.Embedding) > 0 {
+			r.l2Cache.Index(keys.l2Scope, keys.l2, entry.Embedding, ttl)
+		}
+	}
+}
+
+// cacheTTL returns the TTL results are cached for; 0 means the layer's configured TTL
+func (r *RAGClient) cacheTTL(results []schema.SearchResult) time.Duration {
+	if len(results) == 0 {
+		return r.cacheEmptyTTL
+	}
+	return 0
+}
+
+// newL1Entry tags entry with the client's namespace, current index version and scope
+func (r *RAGClient) newL1Entry(scope string, entry cacheEntry) l1Entry {
+	cached := l1Entry{namespace: r.namespace, indexVersion: r.cacheIndexVersion(r.namespace), scope: scope, cacheEntry: entry}
+	cached.Results = []schema.SearchResult{}
+	if len(entry.Results) > 0 {
+		cached.Results = cloneResults(entry.Results)
+	}
+	return cached
+}
+
+// purgeCaches empties both cache layers
+func (r *RAGClient) purgeCaches() {
+	if r.l1Cache != nil {
+		r.l1Cache.Purge()
+	}
+	if r.l2Cache != nil {
+		r.l2Cache.Purge()
+	}
+}
+
+// l1Entry is the value stored in the L1 cache, tagged with the index version it was
+// retrieved against so InvalidateCache can evict stale entries
+type l1Entry struct {
+	namespace    string
+	indexVersion string
+	scope        string
+	cacheEntry
+}
+
+// indexGenerations counts writes per namespace. It is shared by snapshots and
+// namespace-scoped copies of a client so an ingest through any of them is seen by all.
+type indexGenerations struct {
+	mu   sync.Mutex
+	gens map[string]uint64
+}
+
+func (g *indexGenerations) get(namespace string) uint64 {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.gens[namespace]
+}
+
+func (g *indexGenerations) bump(namespace string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.gens == nil {
+		g.gens = make(map[string]uint64)
+	}
+	g.gens[namespace]++
+}
+
+// cacheIndexVersion is the index version L1 entries of namespace are keyed on: the
+// collection plus the number of writes made to the namespace
+func (r *RAGClient) cacheIndexVersion(namespace string) string {
+	return fmt.Sprintf("%s#%d", r.indexVersion, r.indexGenerations.get(namespace))
+}
+
+// InvalidateCache marks the client's namespace as changed and evicts L1 entries whose
+// index version no longer matches: this namespace's entries after an ingest or delete,
+// and any entry cached against a different collection. Other namespaces stay cached in
+// L1. The shared L2 cache is purged entirely, since other gateway instances cannot see
+// this instance's index version.
+func (r *RAGClient) InvalidateCache() {
+	r, done := r.acquire()
+	defer done()
+	r.indexGenerations.bump(r.namespace)
+	if r.l2Cache != nil {
+		r.l2Cache.Purge()
+	}
+	if r.l1Cache == nil {
+		return
+	}
+	removed := r.l1Cache.RemoveIf(func(key string, value any) bool {
+		entry, ok := value.(l1Entry)
+		return !ok || entry.indexVersion != r.cacheIndexVersion(entry.namespace)
+	})
+	if removed > 0 {
+		api.LogInfof("rag: invalidated %d L1 cache entries for namespace %q", removed, r.namespace)
+	}
+}
+
+// cacheVersion tracks the fusion weights version baked into L1 cache keys. It is shared
+// by snapshots and namespace-scoped copies of a client.
+type cacheVersion struct {
+	mu    sync.Mutex
+	value string
+}
+
+func (v *cacheVersion) get() string {
+	if v == nil {
+		return ""
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.value
+}
+
+// observe records version and calls purge when it replaces a previously seen version
+func (v *cacheVersion) observe(version string, purge func()) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.value == version {
+		return
+	}
+	if v.value != "" {
+		purge()
+	}
+	v.value = version
+}
+
+// rewriteVariants maps each retrieval query to its rewrite variants: the pre.rewrite.variants
+// templates applied to the query ("{query}" is substituted, a template without it is appended
+// to the query), then for each plan node its dense rewrite extended with one expansion term,
+// highest weight first.
+func rewriteVariants(templates []string, queries []string, result *pre_retrieve.PreRetrieveResult) map[string][]string {
+	variants := make(map[string][]string)
+	add := func(query, variant string) {
+		variant = strings.TrimSpace(variant)
+		if variant != "" && variant != query && !slices.Contains(variants[query], variant) {
+			variants[query] = append(variants[query], variant)
+		}
+	}
+	for _, query := range queries {
+		for _, tpl := range templates {
+			if strings.Contains(tpl, "{query}") {
+				add(query, strings.ReplaceAll(tpl, "{query}", query))
+			} else if strings.TrimSpace(tpl) != "" {
+				add(query, query+" "+tpl)
+			}
+		}
+	}
+	if result == nil {
+		return variants
+	}
+	for _, node := range result.Plan.Nodes {
+		if node.DenseRewrite == "" {
+			continue
+		}
+		terms := slices.Clone(result.Expansions[node.ID].Terms)
+		sort.SliceStable(terms, func(i, j int) bool { return terms[i].Weight > terms[j].Weight })
+		for _, term := range terms {
+			if !strings.Contains(node.DenseRewrite, term.Term) {
+				add(node.DenseRewrite, node.DenseRewrite+" "+term.Term)
+			}
+		}
+	}
+	return variants
+}
+
 func (r *RAGClient) rerankTopN() int {
 	if r.config.Pipeline != nil && r.config.Pipeline.Post != nil {
 		if r.config.Pipeline.Post.Rerank.TopN > 0 {
@@ -747,6 +2622,12 @@ func cloneDocument(doc schema.Document) schema.Document {
 	if doc.Vector != nil {
 		cloned.Vector = cloneVector(doc.Vector)
 	}
+	if doc.SparseVector != nil {
+		cloned.SparseVector = make(schema.SparseVector, len(doc.SparseVector))
+		for idx, w := range doc.SparseVector {
+			cloned.SparseVector[idx] = w
+		}
+	}
 	return cloned
 }
 
@@ -768,6 +2649,18 @@ func resetMap(m map[string]int) {
 	}
 }
 
+// copyBudgets returns a copy of budgets, or nil when there are none
+func copyBudgets(budgets map[string]int) map[string]int {
+	if len(budgets) == 0 {
+		return nil
+	}
+	out := make(map[string]int, len(budgets))
+	for k, v := range budgets {
+		out[k] = v
+	}
+	return out
+}
+
 func budgetsSignature(budgets map[string]int) string {
 	if len(budgets) == 0 {
 		return "-"
@@ -1,13 +1,367 @@
 package rag
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/httpx"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/embedding"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/fusion"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/post"
+	pre_retrieve "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/pre-retrieve"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retrieval"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retriever"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/textsplitter"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/vectordb"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-session/common"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// mockCommonCAPI swallows envoy logs so client code can run outside envoy
+type mockCommonCAPI struct{}
+
+func (m *mockCommonCAPI) Log(level api.LogType, message string) {}
+
+func (m *mockCommonCAPI) LogLevel() api.LogType { return api.Error }
+
+// MockEmbeddingProvider returns deterministic bag-of-words vectors of a fixed dimension
+type MockEmbeddingProvider struct {
+	mu    sync.Mutex
+	Dim   int
+	Err   error
+	Calls int
+}
+
+func (m *MockEmbeddingProvider) GetProviderType() string { return "mock" }
+
+func (m *MockEmbeddingProvider) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	m.mu.Lock()
+	m.Calls++
+	m.mu.Unlock()
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	vec := make([]float32, m.Dim)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.Trim(word, ".,;:!?\"'()")
+		if word == "" {
+			continue
+		}
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(word))
+		vec[int(h.Sum32())%m.Dim] += 1
+	}
+	return vec, nil
+}
+
+// stubRetriever returns a fixed result list regardless of query
+type stubRetriever struct {
+	typ     string
+	results []schema.SearchResult
+	err     error
+	calls   int32
+}
+
+func (s *stubRetriever) Type() string { return s.typ }
+
+func (s *stubRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+	atomic.AddInt32(&s.calls, 1)
+	if s.err != nil {
+		return nil, s.err
+	}
+	out := make([]schema.SearchResult, len(s.results))
+	copy(out, s.results)
+	if topK > 0 && len(out) > topK {
+		out = out[:topK]
+	}
+	return out, nil
+}
+
+// reverseStrategy ranks documents of the first retriever in reverse order
+type reverseStrategy struct{}
+
+func (s *reverseStrategy) Name() string { return "reverse" }
+
+func (s *reverseStrategy) Fuse(ctx context.Context, inputs []fusion.RetrieverResult, params map[string]any) ([]schema.SearchResult, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	in := inputs[0].Results
+	out := make([]schema.SearchResult, 0, len(in))
+	for i := len(in) - 1; i >= 0; i-- {
+		out = append(out, schema.SearchResult{Document: in[i].Document, Score: float64(len(in) - i)})
+	}
+	return out, nil
+}
+
+func (m *MockEmbeddingProvider) GetDimensions(ctx context.Context) (int, error) {
+	if m.Err != nil {
+		return 0, m.Err
+	}
+	return m.Dim, nil
+}
+
+// MockLLMProvider answers prompts through Respond, recording every prompt it receives.
+// Each successful call reports PromptTokens and CompletionTokens as its usage.
+type MockLLMProvider struct {
+	mu               sync.Mutex
+	Respond          func(prompt string) (string, error)
+	Prompts          []string
+	Messages         [][]llm.ChatMessage
+	PromptTokens     int
+	CompletionTokens int
+}
+
+func (m *MockLLMProvider) GetProviderType() string { return "mock" }
+
+func (m *MockLLMProvider) GenerateCompletion(ctx context.Context, prompt string) (string, error) {
+	m.mu.Lock()
+	m.Prompts = append(m.Prompts, prompt)
+	m.mu.Unlock()
+	resp := "mock answer"
+	if m.Respond != nil {
+		var err error
+		if resp, err = m.Respond(prompt); err != nil {
+			return "", err
+		}
+	}
+	llm.RecordUsage(ctx, []llm.ChatMessage{{Role: "user", Content: prompt}}, resp, m.PromptTokens, m.CompletionTokens)
+	return resp, nil
+}
+
+func (m *MockLLMProvider) GenerateChat(ctx context.Context, messages []llm.ChatMessage) (string, error) {
+	m.mu.Lock()
+	m.Messages = append(m.Messages, messages)
+	m.mu.Unlock()
+	return m.GenerateCompletion(ctx, llm.ConcatMessages(messages))
+}
+
+// memoryVectorStore is an in-memory VectorStoreProvider using cosine similarity
+type memoryVectorStore struct {
+	mu   sync.Mutex
+	docs []schema.Document
+}
+
+func (s *memoryVectorStore) CreateCollection(ctx context.Context, dim int) error { return nil }
+
+func (s *memoryVectorStore) DropCollection(ctx context.Context) error {
+	s.mu.Lock()
+	s.docs = nil
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryVectorStore) AddDoc(ctx context.Context, docs []schema.Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs = append(s.docs, docs...)
+	return nil
+}
+
+func (s *memoryVectorStore) DeleteDoc(ctx context.Context, id string) error {
+	return s.DeleteDocs(ctx, []string{id})
+}
+
+func (s *memoryVectorStore) UpdateDoc(ctx context.Context, docs []schema.Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, doc := range docs {
+		for i := range s.docs {
+			if s.docs[i].ID == doc.ID {
+				s.docs[i] = doc
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memoryVectorStore) SearchDocs(ctx context.Context, vector []float32, options *schema.SearchOptions) ([]schema.SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]schema.SearchResult, 0, len(s.docs))
+	for _, doc := range s.docs {
+		if !matchesFilters(doc, options.Filters) {
+			continue
+		}
+		score := cosine(vector, doc.Vector)
+		if options.Threshold > 0 && score < options.Threshold {
+			continue
+		}
+		results = append(results, schema.SearchResult{Document: cloneDocument(doc), Score: score})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if options.TopK > 0 && len(results) > options.TopK {
+		results = results[:options.TopK]
+	}
+	return results, nil
+}
+
+func (s *memoryVectorStore) DeleteDocs(ctx context.Context, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remove := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		remove[id] = struct{}{}
+	}
+	kept := s.docs[:0]
+	for _, doc := range s.docs {
+		if _, ok := remove[doc.ID]; !ok {
+			kept = append(kept, doc)
+		}
+	}
+	s.docs = kept
+	return nil
+}
+
+func (s *memoryVectorStore) ListDocs(ctx context.Context, limit int) ([]schema.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]schema.Document, 0, len(s.docs))
+	for _, doc := range s.docs {
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, doc)
+	}
+	return out, nil
+}
+
+func (s *memoryVectorStore) QueryDocs(ctx context.Context, options *schema.QueryOptions) ([]schema.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make(map[string]struct{}, len(options.IDs))
+	for _, id := range options.IDs {
+		ids[id] = struct{}{}
+	}
+	out := make([]schema.Document, 0, len(s.docs))
+	skip := options.Offset
+	for _, doc := range s.docs {
+		if options.Limit > 0 && len(out) >= options.Limit {
+			break
+		}
+		if _, ok := ids[doc.ID]; len(ids) > 0 && !ok {
+			continue
+		}
+		if !matchesFilters(doc, options.Filters) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		out = append(out, doc)
+	}
+	return out, nil
+}
+
+func (s *memoryVectorStore) Count(ctx context.Context, filters map[string]interface{}) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, doc := range s.docs {
+		if matchesFilters(doc, filters) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryVectorStore) DeleteByFilter(ctx context.Context, filters map[string]interface{}) (int64, error) {
+	return vectordb.DeleteMatching(ctx, s, filters)
+}
+
+func (s *memoryVectorStore) Ping(ctx context.Context) error { return nil }
+
+func (s *memoryVectorStore) GetProviderType() string { return "memory" }
+
+func matchesFilters(doc schema.Document, filters map[string]interface{}) bool {
+	for k, want := range filters {
+		if got, ok := doc.Metadata[k]; !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// newTestRAGClient builds a RAGClient over in-memory providers and initializes the configured pipeline
+func newTestRAGClient(t *testing.T, cfg *config.Config, llmProvider llm.Provider) (*RAGClient, *memoryVectorStore) {
+	t.Helper()
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	if cfg.RAG.Splitter.Provider == "" {
+		cfg.RAG.Splitter = config.SplitterConfig{Provider: "nosplitter"}
+	}
+	if cfg.Embedding.Dimensions == 0 {
+		cfg.Embedding.Dimensions = 64
+	}
+	splitter, err := textsplitter.NewTextSplitter(&cfg.RAG.Splitter)
+	if err != nil {
+		t.Fatalf("NewTextSplitter() error = %v", err)
+	}
+	store := &memoryVectorStore{}
+	client := &RAGClient{
+		mu:                &sync.RWMutex{},
+		metricsAggregator: metrics.NewAggregator(0),
+		reindexGuard:      &reindexGuard{},
+		ragComponents: ragComponents{
+			inflight:          &sync.WaitGroup{},
+			config:            cfg,
+			vectordbProvider:  store,
+			embeddingProvider: &MockEmbeddingProvider{Dim: cfg.Embedding.Dimensions},
+			textSplitter:      splitter,
+			parentSplitter:    textsplitter.NewParentSplitter(&cfg.RAG.Splitter),
+			indexVersion:      cfg.VectorDB.Collection,
+			httpClient:        newOutboundHTTPClient(cfg),
+		},
+	}
+	if llmProvider != nil {
+		client.llmProvider = llmProvider
+	}
+	promptTemplate, err := llm.NewPromptTemplate(cfg.LLM.PromptTemplate)
+	if err != nil {
+		t.Fatalf("NewPromptTemplate() error = %v", err)
+	}
+	client.promptTemplate = promptTemplate
+	if err := client.initPipeline(); err != nil {
+		t.Fatalf("initPipeline() error = %v", err)
+	}
+	return client, store
+}
+
 func getRAGClient() (*RAGClient, error) {
 	config := &config.Config{
 		RAG: config.RAGConfig{
@@ -238,3 +592,2028 @@ func TestRAGClient_LoadChunks(t *testing.T) {
 	}
 	t.Logf("TestRAGClient_LoadChunks done")
 }
+
+func TestResolveEmbeddingDimensions(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+
+	tests := []struct {
+		name       string
+		provider   *MockEmbeddingProvider
+		configured int
+		want       int
+		wantErr    string
+	}{
+		{name: "auto detect when unset", provider: &MockEmbeddingProvider{Dim: 768}, configured: 0, want: 768},
+		{name: "configured matches", provider: &MockEmbeddingProvider{Dim: 1024}, configured: 1024, want: 1024},
+		{name: "configured mismatch", provider: &MockEmbeddingProvider{Dim: 1024}, configured: 1536, wantErr: "does not match"},
+		{name: "provider error", provider: &MockEmbeddingProvider{Dim: 768, Err: errors.New("unreachable")}, configured: 0, wantErr: "unreachable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveEmbeddingDimensions(context.Background(), tt.provider, tt.configured)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveEmbeddingDimensions() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveEmbeddingDimensions() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveEmbeddingDimensions() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildFusionStrategy_CustomFromConfig(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	fusion.Register("reverse", func(params map[string]any) (fusion.Strategy, map[string]any, error) {
+		return &reverseStrategy{}, map[string]any{"order": params["order"]}, nil
+	})
+
+	ragConfig := &RAGConfig{config: &config.Config{}}
+	err := ragConfig.ParseConfig(map[string]any{
+		"pipeline": map[string]any{
+			"fusion": map[string]any{
+				"strategy": "reverse",
+				"params":   map[string]any{"order": "desc"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+
+	strategy, params := buildFusionStrategy(ragConfig.config.Pipeline.Fusion, 60)
+	if strategy.Name() != "reverse" {
+		t.Fatalf("strategy = %s, want reverse", strategy.Name())
+	}
+	if params["order"] != "desc" {
+		t.Fatalf("params = %v, want order=desc", params)
+	}
+
+	docs := []schema.SearchResult{
+		{Document: schema.Document{ID: "a"}, Score: 0.9},
+		{Document: schema.Document{ID: "b"}, Score: 0.8},
+	}
+	rets := []retriever.Retriever{&stubRetriever{typ: "vector", results: docs}}
+	provider := retrieval.NewProvider(rets, map[string]retriever.Retriever{"vector": rets[0]}, 60)
+	provider.SetFusionStrategy(strategy, params)
+
+	m := metrics.NewRetrievalMetrics()
+	results, err := provider.Retrieve(context.Background(), []string{"q"}, config.RetrievalProfile{Name: "default", TopK: 10}, m)
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Document.ID != "b" {
+		t.Fatalf("Retrieve() = %+v, want reversed order", results)
+	}
+	if m.FusionStrategy != "reverse" {
+		t.Errorf("metrics fusion strategy = %q, want reverse", m.FusionStrategy)
+	}
+	if m.FusionParams["order"] != "desc" {
+		t.Errorf("metrics fusion params = %v, want order=desc", m.FusionParams)
+	}
+}
+
+func TestBuildFusionStrategy_UnknownFallsBackToRRF(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	strategy, params := buildFusionStrategy(&config.FusionConfig{Strategy: "missing"}, 42)
+	if strategy.Name() != "rrf" {
+		t.Errorf("strategy = %s, want rrf", strategy.Name())
+	}
+	if params["k"] != 42 {
+		t.Errorf("params = %v, want k=42", params)
+	}
+}
+
+type noopCompressor struct{}
+
+func (n *noopCompressor) Compress(ctx context.Context, text string, query string) (string, float64, error) {
+	return text, 0, nil
+}
+
+func (n *noopCompressor) BatchCompress(ctx context.Context, results []schema.SearchResult, query string) ([]schema.SearchResult, error) {
+	return results, nil
+}
+
+type noopReranker struct{}
+
+func (n *noopReranker) Rerank(ctx context.Context, query string, in []schema.SearchResult, topN int) ([]schema.SearchResult, error) {
+	return in, nil
+}
+
+func TestParseConfig_ProviderHeaders(t *testing.T) {
+	ragConfig := &RAGConfig{config: &config.Config{}}
+	err := ragConfig.ParseConfig(map[string]any{
+		"embedding": map[string]any{"provider": "openai", "headers": map[string]any{"X-Api-Key": "embed-key", "X-Ignored": 1.0}},
+		"llm":       map[string]any{"provider": "openai", "headers": map[string]any{"OpenAI-Organization": "org-1"}},
+		"vectordb":  map[string]any{"provider": "milvus"},
+	})
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if got := ragConfig.config.Embedding.Headers; !reflect.DeepEqual(got, map[string]string{"X-Api-Key": "embed-key"}) {
+		t.Errorf("embedding headers = %v, want only the string header", got)
+	}
+	if got := ragConfig.config.LLM.Headers; !reflect.DeepEqual(got, map[string]string{"OpenAI-Organization": "org-1"}) {
+		t.Errorf("llm headers = %v", got)
+	}
+}
+
+func TestParseConfig_PreRetrieveTranslation(t *testing.T) {
+	ragConfig := &RAGConfig{config: &config.Config{}}
+	err := ragConfig.ParseConfig(map[string]any{
+		"pipeline": map[string]any{
+			"pre_retrieve": map[string]any{
+				"translation": map[string]any{"enabled": true, "target_language": "en"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	pre := ragConfig.config.Pipeline.PreRetrieve
+	if pre == nil || pre.Provider != "default" {
+		t.Fatalf("pre_retrieve = %+v, want the default provider", pre)
+	}
+	if want := (config.TranslationConfig{Enabled: true, TargetLanguage: "en"}); pre.Translation != want {
+		t.Errorf("translation = %+v, want %+v", pre.Translation, want)
+	}
+}
+
+func TestBuildPostProcessors_CustomFromConfig(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	post.RegisterCompressor("noop", func(opts post.CompressorOptions) (post.Compressor, error) {
+		return &noopCompressor{}, nil
+	})
+	post.RegisterReranker("noop", func(opts post.RerankerOptions) (post.Reranker, error) {
+		return &noopReranker{}, nil
+	})
+
+	ragConfig := &RAGConfig{config: &config.Config{}}
+	err := ragConfig.ParseConfig(map[string]any{
+		"pipeline": map[string]any{
+			"post": map[string]any{
+				"rerank":   map[string]any{"enable": true, "provider": "noop"},
+				"compress": map[string]any{"enable": true, "method": "noop"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+
+	postCfg := ragConfig.config.Pipeline.Post
+	if r := buildReranker(postCfg, nil, nil, nil, nil); r == nil {
+		t.Fatal("buildReranker() returned nil")
+	} else if _, ok := r.(*noopReranker); !ok {
+		t.Errorf("buildReranker() = %T, want *noopReranker", r)
+	}
+	if c := buildCompressor(postCfg, nil, nil); c == nil {
+		t.Fatal("buildCompressor() returned nil")
+	} else if _, ok := c.(*noopCompressor); !ok {
+		t.Errorf("buildCompressor() = %T, want *noopCompressor", c)
+	}
+
+	postCfg.Rerank.Provider = "unregistered"
+	if r, ok := buildReranker(postCfg, nil, nil, nil, nil).(*post.HTTPReranker); !ok || r == nil {
+		t.Errorf("buildReranker() for unknown provider should fall back to HTTP reranker")
+	}
+	postCfg.Rerank.Provider = "llm"
+	if r := buildReranker(postCfg, nil, nil, nil, nil); r != nil {
+		t.Errorf("buildReranker(llm) without llm provider = %T, want nil", r)
+	}
+}
+
+func TestBuildCompressor_MaxTokens(t *testing.T) {
+	postCfg := &config.PostConfig{}
+	postCfg.Compress.Enable = true
+	postCfg.Compress.MaxTokens = 500
+	c, ok := buildCompressor(postCfg, nil, nil).(*post.BudgetCompressor)
+	if !ok || c.MaxTokens != 500 {
+		t.Fatalf("buildCompressor() = %#v, want a BudgetCompressor with max_tokens 500", c)
+	}
+	if _, ok := c.Base.(*post.TruncateCompressor); !ok {
+		t.Errorf("budget base = %T, want the default truncate compressor", c.Base)
+	}
+
+	postCfg.Compress.Method = "budget"
+	if c, ok := buildCompressor(postCfg, nil, nil).(*post.BudgetCompressor); !ok || c.Base != nil {
+		t.Errorf("buildCompressor(budget) = %#v, want an unwrapped BudgetCompressor", c)
+	}
+}
+
+func TestRAGClient_WebAndRerankHonorCircuitBreaker(t *testing.T) {
+	var webHits, rerankHits atomic.Int32
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webHits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer web.Close()
+	rerank := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rerankHits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer rerank.Close()
+
+	pipeline := config.DefaultPipeline()
+	pipeline.EnablePost = true
+	pipeline.EnableCRAG = true
+	pipeline.HTTP = &config.HTTPClientConfig{Retry: 1, BackoffMinMs: 1, BackoffMaxMs: 1, MaxConsecutiveFailures: 2, CircuitOpenSeconds: 1}
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "web", Provider: "duckduckgo", Params: map[string]string{"endpoint": web.URL}}}
+	pipeline.Post = &config.PostConfig{}
+	pipeline.Post.Rerank.Enable = true
+	pipeline.Post.Rerank.Provider = "http"
+	pipeline.Post.Rerank.Endpoint = rerank.URL
+	pipeline.CRAG = &config.CRAGConfig{}
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}, Pipeline: pipeline}, nil)
+
+	ctx := context.Background()
+	in := []schema.SearchResult{{Document: schema.Document{ID: "a", Content: "x"}}}
+	call := func() {
+		_, _ = client.webSearcher.Search(ctx, "higress", 3)
+		_, _ = client.reranker.Rerank(ctx, "higress", in, 1)
+	}
+	// Each call is tried twice (retry: 1); two failed calls open the circuit.
+	call()
+	call()
+	call()
+	if webHits.Load() != 4 || rerankHits.Load() != 4 {
+		t.Fatalf("hits web=%d rerank=%d, want 4 each before the circuit opens and none after", webHits.Load(), rerankHits.Load())
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	call()
+	if webHits.Load() != 6 || rerankHits.Load() != 6 {
+		t.Errorf("hits web=%d rerank=%d, want requests resumed once the open window elapsed", webHits.Load(), rerankHits.Load())
+	}
+}
+
+func TestRAGClient_NamespaceIsolation(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 10}}, nil)
+	teamA := client.WithNamespace("team-a")
+	teamB := client.WithNamespace("team-b")
+
+	docsA, err := teamA.CreateChunkFromText("Higress gateway routing rules for team a", "a-doc")
+	if err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	docsB, err := teamB.CreateChunkFromText("Higress gateway routing rules for team b", "b-doc")
+	if err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	if got := docsA[0].Metadata[schema.METADATA_NAMESPACE]; got != "team-a" {
+		t.Errorf("chunk namespace = %v, want team-a", got)
+	}
+
+	results, err := teamA.SearchChunks("higress gateway routing rules", 10, 0)
+	if err != nil {
+		t.Fatalf("SearchChunks() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID != docsA[0].ID {
+		t.Errorf("SearchChunks() in team-a = %+v, want only team-a chunk", results)
+	}
+
+	listed, err := teamB.ListChunks()
+	if err != nil {
+		t.Fatalf("ListChunks() error = %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != docsB[0].ID {
+		t.Errorf("ListChunks() in team-b = %+v, want only team-b chunk", listed)
+	}
+	if all, _ := client.ListChunks(); len(all) != 2 {
+		t.Errorf("unscoped ListChunks() = %d chunks, want 2", len(all))
+	}
+
+	if err := teamA.DeleteChunk(docsB[0].ID); err == nil {
+		t.Error("DeleteChunk() of a team-b chunk from team-a should fail")
+	}
+	if err := teamB.DeleteChunk(docsB[0].ID); err != nil {
+		t.Errorf("DeleteChunk() error = %v", err)
+	}
+}
+
+func TestRAGClient_NormalizesEmbeddingsForCosine(t *testing.T) {
+	for _, tt := range []struct {
+		search config.SearchConfig
+		want   bool
+	}{
+		{config.SearchConfig{MetricType: "COSINE", Normalize: true}, true},
+		{config.SearchConfig{MetricType: "COSINE"}, false},
+		{config.SearchConfig{MetricType: "IP", Normalize: true}, false},
+	} {
+		client, store := newTestRAGClient(t, &config.Config{
+			RAG:      config.RAGConfig{TopK: 10},
+			VectorDB: config.VectorDBConfig{Mapping: config.MappingConfig{Search: tt.search}},
+		}, nil)
+		if _, err := client.CreateChunkFromText("higress gateway higress routing", "doc"); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+		var norm float64
+		for _, v := range store.docs[0].Vector {
+			norm += float64(v) * float64(v)
+		}
+		if normalized := math.Abs(norm-1) < 1e-6; normalized != tt.want {
+			t.Errorf("search %+v stored a vector with squared norm %v, want normalized %v", tt.search, norm, tt.want)
+		}
+		if results, err := client.SearchChunks("higress gateway", 1, 0); err != nil || len(results) != 1 {
+			t.Errorf("SearchChunks() = %v, %v, want the chunk", results, err)
+		}
+	}
+}
+
+func TestRAGClient_DeleteChunksByFilter(t *testing.T) {
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 10}}, nil)
+	inputs := []struct{ text, title, namespace string }{
+		{"Higress is an API gateway", "foo", ""},
+		{"Higress supports wasm plugins", "foo", ""},
+		{"Higress routes traffic with Envoy", "bar", ""},
+		{"Higress gateway for team a", "foo", "team-a"},
+	}
+	for _, in := range inputs {
+		if _, err := client.WithNamespace(in.namespace).CreateChunkFromText(in.text, in.title); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	if _, err := client.DeleteChunksByFilter(nil); err == nil {
+		t.Error("DeleteChunksByFilter(nil) expected error")
+	}
+	// The namespace is enforced even when the filter names another one
+	deleted, err := client.WithNamespace("team-a").DeleteChunksByFilter(map[string]any{"chunk_title": "foo", "namespace": ""})
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteChunksByFilter() in team-a = %d, %v, want 1", deleted, err)
+	}
+	deleted, err = client.DeleteChunksByFilter(map[string]any{"chunk_title": "foo"})
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteChunksByFilter() = %d, %v, want 2", deleted, err)
+	}
+	if len(store.docs) != 1 || store.docs[0].Metadata["chunk_title"] != "bar" {
+		t.Errorf("remaining chunks = %+v, want only the bar chunk", store.docs)
+	}
+	if deleted, err := client.DeleteChunksByFilter(map[string]any{"chunk_title": "foo"}); err != nil || deleted != 0 {
+		t.Errorf("DeleteChunksByFilter() without matches = %d, %v, want 0", deleted, err)
+	}
+}
+
+func TestHandleDeleteChunksByFilter(t *testing.T) {
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 10}}, nil)
+	for _, title := range []string{"foo", "bar"} {
+		if _, err := client.CreateChunkFromText("Higress gateway overview "+title, title); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"filter": map[string]interface{}{}}
+	if _, err := HandleDeleteChunksByFilter(client)(context.Background(), request); err == nil {
+		t.Error("HandleDeleteChunksByFilter() with an empty filter expected error")
+	}
+	request.Params.Arguments = map[string]interface{}{"filter": map[string]interface{}{"chunk_title": "bar", "chunk_index": float64(0)}}
+	result, err := HandleDeleteChunksByFilter(client)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("HandleDeleteChunksByFilter() error = %v", err)
+	}
+	var decoded struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if decoded.Deleted != 1 || len(store.docs) != 1 || store.docs[0].Metadata["chunk_title"] != "foo" {
+		t.Errorf("deleted = %d, remaining %+v, want the bar chunk removed", decoded.Deleted, store.docs)
+	}
+}
+
+func TestRAGClient_SearchChunksWithFilter(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 10}}, nil)
+	inputs := []struct{ text, title, namespace string }{
+		{"Higress gateway overview", "foo", ""},
+		{"Higress gateway plugins", "bar", ""},
+		{"Higress gateway for team a", "bar", "team-a"},
+	}
+	for _, in := range inputs {
+		if _, err := client.WithNamespace(in.namespace).CreateChunkFromText(in.text, in.title); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	results, err := client.SearchChunksWithFilter("higress gateway", 10, 0, map[string]any{"chunk_title": "foo"})
+	if err != nil {
+		t.Fatalf("SearchChunksWithFilter() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Document.Content != "Higress gateway overview" {
+		t.Errorf("SearchChunksWithFilter() = %+v, want only the foo chunk", results)
+	}
+	// The namespace is enforced even when the filter names another one
+	results, err = client.WithNamespace("team-a").SearchChunksWithFilter("higress gateway", 10, 0, map[string]any{"namespace": ""})
+	if err != nil || len(results) != 1 || results[0].Document.Content != "Higress gateway for team a" {
+		t.Errorf("SearchChunksWithFilter() in team-a = %+v, %v, want only the team-a chunk", results, err)
+	}
+	if _, err := client.SearchChunksWithFilter("higress", 10, 0, map[string]any{"chunk_title": []string{"bar"}}); err == nil {
+		t.Error("SearchChunksWithFilter() with a list value expected error")
+	}
+}
+
+func TestHandleSearch_Filter(t *testing.T) {
+	stub := &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "pipeline", Content: "higress gateway"}, Score: 1},
+	}}
+	retriever.Register("search_filter_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "search_filter_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, Threshold: 0.001},
+	}
+	pipeline.DefaultProfile = "default"
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 10}, Pipeline: pipeline}, nil)
+	for _, title := range []string{"foo", "bar"} {
+		if _, err := client.CreateChunkFromText("Higress gateway overview "+title, title); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"query": "higress gateway", "filter": map[string]interface{}{"chunk_title": "bar"}}
+	result, err := HandleSearch(client)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("HandleSearch() error = %v", err)
+	}
+	var decoded []schema.SearchResult
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Document.Metadata["chunk_title"] != "bar" {
+		t.Errorf("HandleSearch() = %+v, want only the bar chunk", decoded)
+	}
+	if calls := atomic.LoadInt32(&stub.calls); calls != 0 {
+		t.Errorf("pipeline retriever called %d times, want filtered searches to skip the pipeline", calls)
+	}
+
+	request.Params.Arguments["profile"] = "default"
+	if _, err := HandleSearch(client)(context.Background(), request); err == nil {
+		t.Error("HandleSearch() with a filter and a profile expected error")
+	}
+}
+
+func TestRAGClient_NamespaceIsolationPipeline(t *testing.T) {
+	pipeline := config.DefaultPipeline()
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"vector"}, TopK: 10, Threshold: 0.001},
+	}
+	pipeline.DefaultProfile = "default"
+	pipeline.Cache = &config.CacheConfig{L1: &config.CacheLayerConfig{Enable: true}}
+	client, _ := newTestRAGClient(t, &config.Config{
+		RAG:      config.RAGConfig{TopK: 10},
+		VectorDB: config.VectorDBConfig{Collection: "ns"},
+		Pipeline: pipeline,
+	}, nil)
+
+	for ns, text := range map[string]string{
+		"team-a": "Higress plugin configuration guide",
+		"team-b": "Higress plugin configuration secrets",
+	} {
+		if _, err := client.WithNamespace(ns).CreateChunkFromText(text, ns); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	query := "higress plugin configuration"
+	for _, ns := range []string{"team-a", "team-b", "team-a"} {
+		trace, err := client.WithNamespace(ns).ExplainChat(query)
+		if err != nil {
+			t.Fatalf("ExplainChat() error = %v", err)
+		}
+		if len(trace.Results) != 1 || trace.Results[0].Title != ns {
+			t.Errorf("namespace %s results = %+v, want only its own chunk", ns, trace.Results)
+		}
+	}
+
+	prof := config.RetrievalProfile{Name: "default", TopK: 10}
+	if client.WithNamespace("team-a").buildCacheKey(query, prof) == client.WithNamespace("team-b").buildCacheKey(query, prof) {
+		t.Error("buildCacheKey() must differ across namespaces")
+	}
+}
+
+func TestHandleSearch_IncludeVectors(t *testing.T) {
+	stub := &stubRetriever{typ: "bm25"}
+	retriever.Register("search_vectors_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "search_vectors_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, Threshold: 0.001}}
+	pipeline.DefaultProfile = "default"
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 10}, Pipeline: pipeline}, nil)
+	if _, err := client.CreateChunkFromText("Higress gateway overview", "intro"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	chunk := store.docs[0]
+	// The keyword retriever returns the chunk without its vector
+	stub.results = []schema.SearchResult{
+		{Document: schema.Document{ID: chunk.ID, Content: chunk.Content}, Score: 1},
+		{Document: schema.Document{ID: "https://example.com", Content: "web result"}, Score: 0.5},
+	}
+
+	search := func(arguments map[string]interface{}) []map[string]any {
+		t.Helper()
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = arguments
+		result, err := HandleSearch(client)(context.Background(), request)
+		if err != nil {
+			t.Fatalf("HandleSearch() error = %v", err)
+		}
+		var decoded []map[string]any
+		if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded); err != nil {
+			t.Fatalf("output is not JSON: %v", err)
+		}
+		return decoded
+	}
+
+	results := search(map[string]interface{}{"query": "higress gateway"})
+	if len(results) != 2 || results[0]["vector"] != nil {
+		t.Errorf("HandleSearch() = %v, want no vectors by default", results)
+	}
+	results = search(map[string]interface{}{"query": "higress gateway", "include_vectors": true})
+	if len(results) != 2 {
+		t.Fatalf("HandleSearch() = %v, want 2 results", results)
+	}
+	vector, _ := results[0]["vector"].([]any)
+	if len(vector) != len(chunk.Vector) || results[0]["document"] == nil {
+		t.Errorf("chunk vector has %d values, want %d", len(vector), len(chunk.Vector))
+	}
+	if results[1]["vector"] != nil {
+		t.Errorf("web result vector = %v, want none", results[1]["vector"])
+	}
+	if len(stub.results[0].Document.Vector) != 0 {
+		t.Error("attaching vectors modified the retrieved results")
+	}
+}
+
+func TestHandleSearchNamespace(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 10}}, nil)
+	for _, ns := range []string{"team-a", "team-b"} {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{"text": "Higress gateway overview", "title": ns, "namespace": ns}
+		if _, err := HandleCreateChunkFromText(client)(context.Background(), request); err != nil {
+			t.Fatalf("HandleCreateChunkFromText() error = %v", err)
+		}
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"query": "Higress gateway overview", "namespace": "team-b"}
+	result, err := HandleSearch(client)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("HandleSearch() error = %v", err)
+	}
+	var decoded []schema.SearchResult
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded); err != nil {
+		t.Fatalf("search output is not JSON: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0].Document.Metadata[schema.METADATA_NAMESPACE] != "team-b" {
+		t.Errorf("HandleSearch() = %+v, want only the team-b chunk", decoded)
+	}
+}
+
+func TestHandleSearchNamespaceHeader(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 10, NamespaceHeader: "X-Tenant"}}, nil)
+	for _, ns := range []string{"team-a", "team-b"} {
+		ctx := common.WithRequestHeader(context.Background(), http.Header{"X-Tenant": {ns}})
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{"text": "Higress gateway overview", "title": ns}
+		if _, err := HandleCreateChunkFromText(client)(ctx, request); err != nil {
+			t.Fatalf("HandleCreateChunkFromText() error = %v", err)
+		}
+	}
+
+	search := func(ctx context.Context, arguments map[string]interface{}) []schema.SearchResult {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = arguments
+		result, err := HandleSearch(client)(ctx, request)
+		if err != nil {
+			t.Fatalf("HandleSearch() error = %v", err)
+		}
+		var decoded []schema.SearchResult
+		if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded); err != nil {
+			t.Fatalf("search output is not JSON: %v", err)
+		}
+		return decoded
+	}
+
+	// The header wins over a namespace argument naming another tenant
+	ctx := common.WithRequestHeader(context.Background(), http.Header{"X-Tenant": {"team-b"}})
+	got := search(ctx, map[string]interface{}{"query": "Higress gateway overview", "namespace": "team-a"})
+	if len(got) != 1 || got[0].Document.Metadata[schema.METADATA_NAMESPACE] != "team-b" {
+		t.Errorf("HandleSearch() with header = %+v, want only the team-b chunk", got)
+	}
+
+	// Without the header the namespace argument applies
+	got = search(context.Background(), map[string]interface{}{"query": "Higress gateway overview", "namespace": "team-a"})
+	if len(got) != 1 || got[0].Document.Metadata[schema.METADATA_NAMESPACE] != "team-a" {
+		t.Errorf("HandleSearch() without header = %+v, want only the team-a chunk", got)
+	}
+}
+
+func TestRAGClient_Stats(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{
+		RAG:      config.RAGConfig{TopK: 10},
+		VectorDB: config.VectorDBConfig{Collection: "stats"},
+	}, nil)
+	inputs := []struct{ text, title, namespace string }{
+		{"Higress is an API gateway", "intro", ""},
+		{"Higress supports wasm plugins", "plugins", ""},
+		{"Higress routes traffic with Envoy", "intro", "team-a"},
+	}
+	for _, in := range inputs {
+		if _, err := client.WithNamespace(in.namespace).CreateChunkFromText(in.text, in.title); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	stats, err := client.Stats()
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if stats.DocumentCount != 3 || stats.DistinctTitles != 2 {
+		t.Errorf("Stats() count = %d titles = %d, want 3 and 2", stats.DocumentCount, stats.DistinctTitles)
+	}
+	if stats.EmbeddingDimensions != 64 || stats.VectorDBProvider != "memory" || stats.Collection != "stats" {
+		t.Errorf("Stats() = %+v, want dimension 64 on memory collection stats", stats)
+	}
+
+	scoped, err := client.WithNamespace("team-a").Stats()
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if scoped.DocumentCount != 1 || scoped.Namespace != "team-a" || len(scoped.Titles) != 1 || scoped.Titles[0] != "intro" {
+		t.Errorf("namespaced Stats() = %+v, want one intro chunk in team-a", scoped)
+	}
+}
+
+// batchingEmbeddingProvider embeds through MockEmbeddingProvider and records every batch
+type batchingEmbeddingProvider struct {
+	MockEmbeddingProvider
+	maxBatch int
+	batches  [][]string
+}
+
+func (b *batchingEmbeddingProvider) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	b.batches = append(b.batches, append([]string(nil), texts...))
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i], _ = b.MockEmbeddingProvider.GetEmbedding(ctx, text)
+	}
+	return vectors, nil
+}
+
+func (b *batchingEmbeddingProvider) MaxBatchSize() int { return b.maxBatch }
+
+func TestRAGClient_CreateChunkFromTextBatchesEmbeddings(t *testing.T) {
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{
+		TopK:     10,
+		Splitter: config.SplitterConfig{Provider: "recursive", ChunkSize: 20, ChunkOverlap: 0},
+	}}, nil)
+	embedder := &batchingEmbeddingProvider{MockEmbeddingProvider: MockEmbeddingProvider{Dim: 64}, maxBatch: 2}
+	client.embeddingProvider = embedder
+
+	docs, err := client.CreateChunkFromText("alpha routes.\n\nbeta plugins.\n\ngamma wasm.\n\ndelta envoy.\n\nepsilon mcp.", "intro")
+	if err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	if len(docs) != 5 || len(store.docs) != 5 {
+		t.Fatalf("CreateChunkFromText() created %d chunks, stored %d, want 5", len(docs), len(store.docs))
+	}
+	if len(embedder.batches) != 3 || embedder.Calls != 5 {
+		t.Errorf("embedding batches = %v, want 5 chunks in batches of at most 2", embedder.batches)
+	}
+	reference := &MockEmbeddingProvider{Dim: 64}
+	for _, doc := range docs {
+		want, _ := reference.GetEmbedding(context.Background(), doc.Content)
+		if !reflect.DeepEqual(doc.Vector, want) {
+			t.Errorf("chunk %q has the vector of another chunk", doc.Content)
+		}
+	}
+}
+
+func TestRAGClient_CreateChunksFromURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><title>Higress Intro</title><script>var x = 1;</script></head>
+<body><h1>Higress</h1><p>Higress is a cloud native API gateway.</p></body></html>`))
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0x00, 0x01})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 10}}, nil)
+	docs, err := client.CreateChunksFromURL(server.URL+"/page", "")
+	if err != nil {
+		t.Fatalf("CreateChunksFromURL() error = %v", err)
+	}
+	if len(docs) != 1 || len(store.docs) != 1 {
+		t.Fatalf("CreateChunksFromURL() created %d chunks, stored %d, want 1", len(docs), len(store.docs))
+	}
+	doc := store.docs[0]
+	if doc.Metadata["source_url"] != server.URL+"/page" || doc.Metadata["chunk_title"] != "Higress Intro" {
+		t.Errorf("chunk metadata = %v, want source_url and page title", doc.Metadata)
+	}
+	if strings.Contains(doc.Content, "var x") || !strings.Contains(doc.Content, "cloud native API gateway") {
+		t.Errorf("chunk content = %q, want readable text without scripts", doc.Content)
+	}
+
+	for _, path := range []string{"/binary", "/missing"} {
+		if _, err := client.CreateChunksFromURL(server.URL+path, "t"); err == nil {
+			t.Errorf("CreateChunksFromURL(%s) expected error", path)
+		}
+	}
+	if _, err := client.CreateChunksFromURL("file:///etc/passwd", "t"); err == nil {
+		t.Error("CreateChunksFromURL() expected error for non-http scheme")
+	}
+}
+
+func TestRAGClient_CreateChunksFromURLRedirectAllowlist(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	var fetched atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal":
+			fetched.Add(1)
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("internal secrets"))
+		case "/redirect":
+			// localhost is the same server under a host missing from the allowlist
+			target := strings.Replace(r.Host, "127.0.0.1", "localhost", 1)
+			http.Redirect(w, r, "http://"+target+"/internal", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 10}}, nil)
+	client.httpClient = httpx.NewFromConfig(&config.HTTPClientConfig{HostAllowlist: []string{"127.0.0.1"}})
+	_, err := client.CreateChunksFromURL(server.URL+"/redirect", "t")
+	if !errors.Is(err, httpx.ErrHostNotAllowed) {
+		t.Errorf("CreateChunksFromURL() error = %v, want the redirect blocked", err)
+	}
+	if fetched.Load() != 0 || len(store.docs) != 0 {
+		t.Errorf("disallowed host fetched %d times, %d chunks stored", fetched.Load(), len(store.docs))
+	}
+}
+
+func TestRAGClient_CreateChunksFromURLWithHTMLSplitter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Plugins</title></head><body>
+<h1>Plugins</h1><p>See the <a href="/docs/wasm">Wasm guide</a> for details.</p></body></html>`))
+	}))
+	defer server.Close()
+
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{
+		TopK:     10,
+		Splitter: config.SplitterConfig{Provider: "html", ChunkSize: 500},
+	}}, nil)
+	if _, err := client.CreateChunksFromURL(server.URL, ""); err != nil {
+		t.Fatalf("CreateChunksFromURL() error = %v", err)
+	}
+	if len(store.docs) != 1 {
+		t.Fatalf("stored %d chunks, want 1", len(store.docs))
+	}
+	doc := store.docs[0]
+	if doc.Content != "Plugins\n\nSee the Wasm guide for details." || doc.Metadata["chunk_title"] != "Plugins" {
+		t.Errorf("chunk = %q with metadata %v", doc.Content, doc.Metadata)
+	}
+	if doc.Metadata["heading_path"] != "Plugins" || fmt.Sprint(doc.Metadata["links"]) != "[/docs/wasm]" {
+		t.Errorf("chunk metadata = %v, want heading_path and links from the page", doc.Metadata)
+	}
+}
+
+func TestRAGClient_CreateChunksFromURLHostAllowlist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<p>blocked</p>"))
+	}))
+	defer server.Close()
+
+	pipeline := config.DefaultPipeline()
+	pipeline.HTTP = &config.HTTPClientConfig{HostAllowlist: []string{"docs.example.com"}}
+	client, store := newTestRAGClient(t, &config.Config{Pipeline: pipeline}, nil)
+	if _, err := client.CreateChunksFromURL(server.URL, "t"); !errors.Is(err, httpx.ErrHostNotAllowed) {
+		t.Errorf("CreateChunksFromURL() error = %v, want ErrHostNotAllowed", err)
+	}
+	if len(store.docs) != 0 {
+		t.Errorf("blocked fetch stored %d chunks, want 0", len(store.docs))
+	}
+}
+
+func TestRAGClient_RequestOverrides(t *testing.T) {
+	pipeline := config.DefaultPipeline()
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"vector"}, TopK: 2, Threshold: 0.001},
+		{Name: "wide", Retrievers: []string{"vector"}, TopK: 5, Threshold: 0.001},
+	}
+	pipeline.DefaultProfile = "default"
+	llmProvider := &MockLLMProvider{}
+	client, _ := newTestRAGClient(t, &config.Config{
+		RAG:      config.RAGConfig{TopK: 2, Threshold: 0.001},
+		Pipeline: pipeline,
+	}, llmProvider)
+	for i := 0; i < 6; i++ {
+		if _, err := client.CreateChunkFromText(fmt.Sprintf("Higress gateway plugin note %d", i), "notes"); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	query := "higress gateway plugin"
+	tests := []struct {
+		name        string
+		opts        RequestOptions
+		wantCount   int
+		wantProfile string
+	}{
+		{name: "defaults", opts: RequestOptions{}, wantCount: 2, wantProfile: "default"},
+		{name: "top_k override", opts: RequestOptions{TopK: 4}, wantCount: 4, wantProfile: "default"},
+		{name: "profile override", opts: RequestOptions{Profile: "wide"}, wantCount: 5, wantProfile: "wide"},
+		{name: "clamped top_k", opts: RequestOptions{TopK: 1000}, wantCount: 6, wantProfile: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := client.SearchChunksPipeline(query, tt.opts)
+			if err != nil {
+				t.Fatalf("SearchChunksPipeline() error = %v", err)
+			}
+			if len(results) != tt.wantCount {
+				t.Errorf("SearchChunksPipeline() returned %d results, want %d", len(results), tt.wantCount)
+			}
+			resp, err := client.ChatWithSources(query, tt.opts)
+			if err != nil {
+				t.Fatalf("ChatWithSources() error = %v", err)
+			}
+			if resp.Profile != tt.wantProfile || len(resp.Sources) != tt.wantCount {
+				t.Errorf("ChatWithSources() profile = %q sources = %d, want %q and %d", resp.Profile, len(resp.Sources), tt.wantProfile, tt.wantCount)
+			}
+		})
+	}
+
+	if _, err := client.SearchChunksPipeline(query, RequestOptions{Profile: "missing"}); err == nil {
+		t.Error("SearchChunksPipeline() expected error for unknown profile")
+	}
+	if results, _ := client.SearchChunksPipeline(query, RequestOptions{}); len(results) != 2 {
+		t.Errorf("overrides leaked into later requests: got %d results, want 2", len(results))
+	}
+}
+
+func TestHandleSearchOverrides(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 1, Threshold: 0.001}}, nil)
+	for i := 0; i < 4; i++ {
+		if _, err := client.CreateChunkFromText(fmt.Sprintf("Higress gateway note %d", i), "notes"); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	count := func(arguments map[string]interface{}) int {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = arguments
+		result, err := HandleSearch(client)(context.Background(), request)
+		if err != nil {
+			t.Fatalf("HandleSearch() error = %v", err)
+		}
+		var decoded []schema.SearchResult
+		if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded); err != nil {
+			t.Fatalf("search output is not JSON: %v", err)
+		}
+		return len(decoded)
+	}
+
+	if got := count(map[string]interface{}{"query": "higress gateway"}); got != 1 {
+		t.Errorf("default search returned %d results, want 1", got)
+	}
+	// JSON numbers arrive as float64
+	if got := count(map[string]interface{}{"query": "higress gateway", "top_k": float64(3)}); got != 3 {
+		t.Errorf("top_k=3 search returned %d results, want 3", got)
+	}
+	if got := count(map[string]interface{}{"query": "higress gateway", "top_k": float64(4), "threshold": 1.5}); got != 0 {
+		t.Errorf("threshold above 1 should clamp to 1 and filter everything, got %d results", got)
+	}
+}
+
+type failingEmbeddingProvider struct{}
+
+func (f *failingEmbeddingProvider) GetProviderType() string { return "failing" }
+
+func (f *failingEmbeddingProvider) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, errors.New("embedding service unavailable")
+}
+
+func (f *failingEmbeddingProvider) GetDimensions(ctx context.Context) (int, error) {
+	return 0, errors.New("embedding service unavailable")
+}
+
+func TestRAGClient_EmbeddingFailureDegradesToBM25(t *testing.T) {
+	retriever.Register("degrade_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+			{Document: schema.Document{ID: "kw-1", Content: "Higress keyword hit"}, Score: 4.2},
+		}}, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "degrade_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"vector", "bm25"}, TopK: 5, Threshold: 0.001, VectorGate: 0.9, VectorLowGate: 0.1},
+	}
+	pipeline.DefaultProfile = "default"
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, &MockLLMProvider{})
+	if _, err := client.CreateChunkFromText("Higress vector only document", "vec"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+
+	client.embeddingProvider = &failingEmbeddingProvider{}
+	if err := client.initPipeline(); err != nil {
+		t.Fatalf("initPipeline() error = %v", err)
+	}
+
+	resp, err := client.ChatWithSources("higress keyword", RequestOptions{})
+	if err != nil {
+		t.Fatalf("ChatWithSources() error = %v", err)
+	}
+	if len(resp.Sources) != 1 || resp.Sources[0].Document.ID != "kw-1" {
+		t.Errorf("ChatWithSources() sources = %+v, want the bm25 result", resp.Sources)
+	}
+
+	trace, err := client.ExplainChat("higress keyword")
+	if err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+	if !strings.Contains(trace.EmbeddingError, "embedding service unavailable") {
+		t.Errorf("trace embedding_error = %q, want the embedder failure", trace.EmbeddingError)
+	}
+	for _, ret := range trace.Retrievers {
+		if ret.Name == "vector" {
+			t.Errorf("vector retriever ran after embedding failure: %+v", trace.Retrievers)
+		}
+	}
+	if len(trace.Profile.Retrievers) != 1 || trace.Profile.Retrievers[0] != "bm25" {
+		t.Errorf("profile retrievers = %v, want only bm25", trace.Profile.Retrievers)
+	}
+}
+
+type countingEmbeddingProvider struct {
+	failingEmbeddingProvider
+	calls atomic.Int32
+}
+
+func (c *countingEmbeddingProvider) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	c.calls.Add(1)
+	return c.failingEmbeddingProvider.GetEmbedding(ctx, text)
+}
+
+func TestRAGClient_EmbeddingBreakerSkipsVector(t *testing.T) {
+	retriever.Register("breaker_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+			{Document: schema.Document{ID: "kw-1", Content: "Higress keyword hit"}, Score: 4.2},
+			{Document: schema.Document{ID: "kw-2", Content: "Higress keyword miss"}, Score: 1.3},
+		}}, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.HTTP = &config.HTTPClientConfig{MaxConsecutiveFailures: 2, CircuitOpenSeconds: 60}
+	// MMR and near-duplicate removal embed the results, so they go through the breaker too
+	pipeline.EnablePost = true
+	pipeline.Post = &config.PostConfig{}
+	pipeline.Post.MMR.Enable = true
+	pipeline.Fusion = &config.FusionConfig{Dedup: &config.DedupConfig{Enable: true, Method: "embedding"}}
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "breaker_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"vector", "bm25"}, TopK: 5, Threshold: 0.001},
+	}
+	pipeline.DefaultProfile = "default"
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, &MockLLMProvider{})
+	embedder := &countingEmbeddingProvider{}
+	client.embeddingProvider = embedder
+	if err := client.initPipeline(); err != nil {
+		t.Fatalf("initPipeline() error = %v", err)
+	}
+
+	for i := 0; i < 10 && !client.embeddingBreaker.Open(); i++ {
+		if _, err := client.ChatWithSources(fmt.Sprintf("higress keyword %d", i), RequestOptions{}); err != nil {
+			t.Fatalf("ChatWithSources() error = %v", err)
+		}
+	}
+	if !client.embeddingBreaker.Open() {
+		t.Fatal("embedding breaker did not open after consecutive failures")
+	}
+
+	calls := embedder.calls.Load()
+	resp, err := client.ChatWithSources("higress keyword degraded", RequestOptions{})
+	if err != nil {
+		t.Fatalf("ChatWithSources() error = %v", err)
+	}
+	if len(resp.Sources) != 2 || resp.Sources[0].Document.ID != "kw-1" {
+		t.Errorf("ChatWithSources() sources = %+v, want the bm25 results", resp.Sources)
+	}
+	if got := embedder.calls.Load(); got != calls {
+		t.Errorf("embedding provider called %d times while the breaker was open", got-calls)
+	}
+
+	trace, err := client.ExplainChat("higress keyword degraded")
+	if err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+	if trace.EmbeddingError != embedding.ErrCircuitOpen.Error() {
+		t.Errorf("trace embedding_error = %q, want the open circuit", trace.EmbeddingError)
+	}
+	if len(trace.Profile.Retrievers) != 1 || trace.Profile.Retrievers[0] != "bm25" {
+		t.Errorf("profile retrievers = %v, want only bm25", trace.Profile.Retrievers)
+	}
+}
+
+// newEmbeddingServer serves the http embedding provider with MockEmbeddingProvider vectors
+// of dim dimensions and counts the texts it embedded
+func newEmbeddingServer(t *testing.T, dim int, texts *atomic.Int64) *httptest.Server {
+	t.Helper()
+	embedder := &MockEmbeddingProvider{Dim: dim}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Texts []string `json:"texts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		texts.Add(int64(len(req.Texts)))
+		vectors := make([][]float32, len(req.Texts))
+		for i, text := range req.Texts {
+			vectors[i], _ = embedder.GetEmbedding(r.Context(), text)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": vectors})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRAGClient_ProfileEmbeddingOverride(t *testing.T) {
+	var texts atomic.Int64
+	server := newEmbeddingServer(t, 64, &texts)
+	pipeline := config.DefaultPipeline()
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"vector"}, TopK: 3, Threshold: 0.001},
+		{Name: "multilingual", Retrievers: []string{"vector"}, TopK: 3, Threshold: 0.001,
+			Embedding: &config.EmbeddingConfig{Provider: "http", BaseURL: server.URL, Model: "multilingual"}},
+	}
+	pipeline.DefaultProfile = "default"
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}, Pipeline: pipeline}, nil)
+	if _, err := client.CreateChunkFromText("Higress is an API gateway", "intro"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+
+	texts.Store(0)
+	if results, err := client.SearchChunksPipeline("higress gateway", RequestOptions{}); err != nil || len(results) != 1 {
+		t.Fatalf("SearchChunksPipeline() = %d results, %v", len(results), err)
+	}
+	if n := texts.Load(); n != 0 {
+		t.Errorf("default profile embedded %d texts with the override, want the global embedding", n)
+	}
+	results, err := client.SearchChunksPipeline("higress gateway", RequestOptions{Profile: "multilingual"})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("SearchChunksPipeline(multilingual) = %d results, %v", len(results), err)
+	}
+	if n := texts.Load(); n != 1 {
+		t.Errorf("multilingual profile embedded %d texts with the override, want 1", n)
+	}
+}
+
+func TestRAGClient_LanguageRouting(t *testing.T) {
+	var texts atomic.Int64
+	server := newEmbeddingServer(t, 64, &texts)
+	pipeline := config.DefaultPipeline()
+	pipeline.Language = &config.LanguageConfig{Enable: true, Fallback: "en"}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"vector"}, TopK: 3, Threshold: 0.001},
+		{Name: "chinese", Retrievers: []string{"vector"}, TopK: 3, Threshold: 0.001, Languages: []string{"zh"},
+			Embedding: &config.EmbeddingConfig{Provider: "http", BaseURL: server.URL, Model: "bge-zh"}},
+	}
+	pipeline.DefaultProfile = "default"
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}, Pipeline: pipeline}, nil)
+	if _, err := client.CreateChunkFromText("Higress 是一个云原生网关", "intro"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+
+	tests := []struct {
+		query, language, profile, source string
+		embedded                         int64
+	}{
+		{"如何配置 Higress 网关", "zh", "chinese", "language", 1},
+		{"how to configure the gateway", "en", "default", "default_profile", 0},
+		{"12345", "en", "default", "default_profile", 0},
+	}
+	for _, tt := range tests {
+		texts.Store(0)
+		_, name, m, err := client.runEnhancedPipeline(context.Background(), tt.query, RequestOptions{}, nil)
+		if err != nil {
+			t.Fatalf("runEnhancedPipeline(%q) error = %v", tt.query, err)
+		}
+		if m.QueryLanguage != tt.language || name != tt.profile || m.ProfileSource != tt.source {
+			t.Errorf("query %q: language = %q, profile = %q (%s), want %q, %q (%s)",
+				tt.query, m.QueryLanguage, name, m.ProfileSource, tt.language, tt.profile, tt.source)
+		}
+		if n := texts.Load(); n != tt.embedded {
+			t.Errorf("query %q embedded %d texts with the chinese model, want %d", tt.query, n, tt.embedded)
+		}
+	}
+
+	// An explicitly requested profile is kept
+	_, name, _, err := client.runEnhancedPipeline(context.Background(), "如何配置网关", RequestOptions{Profile: "default"}, nil)
+	if err != nil || name != "default" {
+		t.Errorf("runEnhancedPipeline() profile = %q, %v, want the requested default", name, err)
+	}
+}
+
+func TestRAGClient_LanguageRouterRule(t *testing.T) {
+	pipeline := config.DefaultPipeline()
+	pipeline.Language = &config.LanguageConfig{Enable: true}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"vector"}, TopK: 3},
+		{Name: "chinese", Retrievers: []string{"bm25"}, TopK: 3},
+	}
+	pipeline.Router = &config.RouterConfig{Enable: true, Provider: "rule", Rules: []config.RouterRule{{Language: "zh", Profile: "chinese"}}}
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}, Pipeline: pipeline}, nil)
+
+	for query, want := range map[string]string{"网关插件": "chinese", "gateway plugins": "default"} {
+		_, name, _, err := client.runEnhancedPipeline(context.Background(), query, RequestOptions{}, nil)
+		if err != nil || name != want {
+			t.Errorf("runEnhancedPipeline(%q) profile = %q, %v, want %q", query, name, err, want)
+		}
+	}
+}
+
+func TestRAGClient_ProfileEmbeddingDimensionMismatch(t *testing.T) {
+	var texts atomic.Int64
+	server := newEmbeddingServer(t, 32, &texts)
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}}, nil)
+	client.config.Pipeline = config.DefaultPipeline()
+	client.config.Pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "multilingual", Retrievers: []string{"vector"},
+			Embedding: &config.EmbeddingConfig{Provider: "http", BaseURL: server.URL}},
+	}
+
+	var validationErr *config.ValidationError
+	if err := client.initPipeline(); !errors.As(err, &validationErr) {
+		t.Fatalf("initPipeline() error = %v, want a config validation error", err)
+	}
+	if validationErr.Field != "pipeline.retrieval_profiles[0].embedding.dimensions" {
+		t.Errorf("validation error field = %q", validationErr.Field)
+	}
+}
+
+func TestProfileEmbeddingConfig(t *testing.T) {
+	global := config.EmbeddingConfig{Provider: "openai", APIKey: "sk", BaseURL: "https://gw", Model: "small", Headers: map[string]string{"X-Tenant": "a"}}
+
+	got := profileEmbeddingConfig(global, config.EmbeddingConfig{Model: "multilingual"})
+	want := global
+	want.Model = "multilingual"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("profileEmbeddingConfig() = %+v, want the global embedding with the model replaced", got)
+	}
+	other := config.EmbeddingConfig{Provider: "cohere", APIKey: "co", Model: "embed-multilingual-v3.0"}
+	if got := profileEmbeddingConfig(global, other); !reflect.DeepEqual(got, other) {
+		t.Errorf("profileEmbeddingConfig() = %+v, want another provider's override unchanged", got)
+	}
+}
+
+func TestRAGClient_EmbeddingFailureWithoutFallbackRetriever(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}}, &MockLLMProvider{})
+	client.embeddingProvider = &failingEmbeddingProvider{}
+	if _, err := client.ChatWithSources("higress", RequestOptions{}); err == nil {
+		t.Error("ChatWithSources() expected error when no retriever can run")
+	}
+}
+
+func TestRAGClient_ChatPromptTemplate(t *testing.T) {
+	llmProvider := &MockLLMProvider{}
+	client, _ := newTestRAGClient(t, &config.Config{
+		RAG: config.RAGConfig{TopK: 2, Threshold: 0.001},
+		LLM: config.LLMConfig{PromptTemplate: "{{.Query}}{{range .Contexts}}\n[{{.Index}}] {{.Title}}{{end}}"},
+	}, llmProvider)
+	for _, title := range []string{"install", "plugins"} {
+		if _, err := client.CreateChunkFromText("Higress gateway "+title+" guide", title); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	resp, err := client.ChatWithSources("higress gateway", RequestOptions{})
+	if err != nil {
+		t.Fatalf("ChatWithSources() error = %v", err)
+	}
+	if len(llmProvider.Prompts) != 1 {
+		t.Fatalf("llm called %d times, want 1", len(llmProvider.Prompts))
+	}
+	want := "higress gateway"
+	for i, src := range resp.Sources {
+		want += fmt.Sprintf("\n[%d] %s", i+1, src.Document.Metadata["chunk_title"])
+	}
+	if got := llmProvider.Prompts[0]; got != want {
+		t.Errorf("prompt = %q, want %q", got, want)
+	}
+}
+
+func TestRAGClient_ContentDedupAcrossRetrievers(t *testing.T) {
+	const content = "Higress routes traffic with Envoy filters"
+	retriever.Register("dedup_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+			{Document: schema.Document{ID: "kw-copy", Content: content}, Score: 9.5},
+		}}, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "dedup_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"vector", "bm25"}, TopK: 5, Threshold: 0.001},
+	}
+	pipeline.DefaultProfile = "default"
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, nil)
+	if _, err := client.CreateChunkFromText(content, "routing"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+
+	results, err := client.SearchChunksPipeline("higress envoy routing", RequestOptions{})
+	if err != nil {
+		t.Fatalf("SearchChunksPipeline() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("SearchChunksPipeline() returned %d results, want one fused entry: %+v", len(results), results)
+	}
+	sources, _ := results[0].Document.Metadata[fusion.MetadataMergedSources].([]string)
+	if results[0].Document.ID != "kw-copy" || len(sources) != 2 {
+		t.Errorf("fused document = %+v, want highest-scoring kw-copy merged from vector and bm25", results[0].Document)
+	}
+}
+
+func TestRAGClient_RetrievalDegradedIsNotEmpty(t *testing.T) {
+	stub := &stubRetriever{typ: "bm25", err: errors.New("connection refused")}
+	retriever.Register("degraded_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "degraded_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, MinSuccessfulRetrievers: 1},
+	}
+	pipeline.DefaultProfile = "default"
+	pipeline.Cache = &config.CacheConfig{L1: &config.CacheLayerConfig{Enable: true, CacheEmpty: true}}
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, nil)
+
+	for i := 0; i < 2; i++ {
+		_, _, m, err := client.runEnhancedPipeline(context.Background(), "higress", RequestOptions{}, nil)
+		if !errors.Is(err, retrieval.ErrRetrievalDegraded) {
+			t.Fatalf("runEnhancedPipeline() error = %v, want ErrRetrievalDegraded", err)
+		}
+		if m == nil || !m.RetrievalDegraded || m.ErrorMsg == "" {
+			t.Errorf("metrics = %+v, want retrieval_degraded recorded", m)
+		}
+	}
+	if calls := atomic.LoadInt32(&stub.calls); calls != 2 {
+		t.Errorf("retriever called %d times, want degraded retrievals kept out of the negative cache", calls)
+	}
+	if _, err := client.SearchChunksPipeline("higress", RequestOptions{}); !errors.Is(err, retrieval.ErrRetrievalDegraded) {
+		t.Errorf("SearchChunksPipeline() error = %v, want the outage surfaced instead of a baseline fallback", err)
+	}
+}
+
+func TestRAGClient_IngestInvalidatesCache(t *testing.T) {
+	stub := &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "old", Content: "higress gateway"}, Score: 1},
+	}}
+	retriever.Register("invalidate_cache_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "invalidate_cache_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, Threshold: 0.001},
+	}
+	pipeline.DefaultProfile = "default"
+	pipeline.Cache = &config.CacheConfig{L1: &config.CacheLayerConfig{Enable: true}}
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, nil)
+	teamA, teamB := client.WithNamespace("team-a"), client.WithNamespace("team-b")
+	search := func(c *RAGClient) {
+		t.Helper()
+		if _, _, _, err := c.runEnhancedPipeline(context.Background(), "higress", RequestOptions{}, nil); err != nil {
+			t.Fatalf("runEnhancedPipeline() error = %v", err)
+		}
+	}
+
+	search(teamA)
+	search(teamB)
+	search(teamA)
+	if calls := atomic.LoadInt32(&stub.calls); calls != 2 {
+		t.Fatalf("retriever called %d times, want the repeated query served from cache", calls)
+	}
+
+	if _, err := teamA.CreateChunkFromText("Higress supports wasm plugins.", "plugins"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	atomic.StoreInt32(&stub.calls, 0)
+	search(teamA)
+	if calls := atomic.LoadInt32(&stub.calls); calls != 1 {
+		t.Errorf("after ingest retriever called %d times, want the namespace's cached results invalidated", calls)
+	}
+	search(teamB)
+	if calls := atomic.LoadInt32(&stub.calls); calls != 1 {
+		t.Errorf("retriever called %d times, want other namespaces to stay cached", calls)
+	}
+
+	// Entries cached against a previous collection are evicted on the next invalidation
+	countEntries := func() (n int) {
+		client.l1Cache.RemoveIf(func(key string, value any) bool { n++; return false })
+		return n
+	}
+	if n := countEntries(); n != 2 {
+		t.Fatalf("cache holds %d entries, want one per namespace", n)
+	}
+	client.indexVersion = "reindexed"
+	client.InvalidateCache()
+	if n := countEntries(); n != 0 {
+		t.Errorf("cache holds %d entries after reindex, want entries of the previous collection evicted", n)
+	}
+}
+
+func TestRAGClient_MetricsAggregator(t *testing.T) {
+	stub := &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "doc", Content: "higress gateway"}, Score: 1},
+	}}
+	retriever.Register("aggregate_metrics_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "aggregate_metrics_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, Threshold: 0.001},
+	}
+	pipeline.DefaultProfile = "default"
+	pipeline.Cache = &config.CacheConfig{L1: &config.CacheLayerConfig{Enable: true}}
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, &MockLLMProvider{})
+
+	if _, err := client.SearchChunksPipeline("higress", RequestOptions{}); err != nil {
+		t.Fatalf("SearchChunksPipeline() error = %v", err)
+	}
+	// A chat records its run once, after answer generation
+	if _, err := client.WithNamespace("team-a").Chat("higress"); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if _, err := client.SearchChunksPipeline("higress", RequestOptions{}); err != nil {
+		t.Fatalf("SearchChunksPipeline() error = %v", err)
+	}
+
+	s := client.MetricsAggregator().Snapshot()
+	if s.QueryCount != 3 || s.FailureCount != 0 || s.CacheLookups != 3 || s.CacheHits != 1 {
+		t.Errorf("Snapshot() = %+v, want 3 queries with 1 cache hit in 3 lookups", s)
+	}
+	if s.LatencySamples != 3 {
+		t.Errorf("latency samples = %d, want 3", s.LatencySamples)
+	}
+}
+
+func TestRAGClient_MMRDropsNearDuplicates(t *testing.T) {
+	stub := &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "routes", Content: "higress gateway routes traffic"}, Score: 1},
+		{Document: schema.Document{ID: "routes-copy", Content: "Higress gateway routes traffic."}, Score: 0.9},
+		{Document: schema.Document{ID: "plugins", Content: "wasm plugins extend the proxy"}, Score: 0.8},
+	}}
+	retriever.Register("mmr_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "mmr_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, Threshold: 0.001},
+	}
+	pipeline.DefaultProfile = "default"
+	pipeline.EnablePost = true
+	pipeline.Post.MMR.Enable = true
+	pipeline.Post.MMR.TopN = 2
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, nil)
+
+	results, _, m, err := client.runEnhancedPipeline(context.Background(), "higress gateway", RequestOptions{}, nil)
+	if err != nil {
+		t.Fatalf("runEnhancedPipeline() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Document.ID != "routes" || results[1].Document.ID != "plugins" {
+		t.Errorf("results = %v, want the near-duplicate dropped", resultIDs(results))
+	}
+	if !m.MMREnabled || m.MMRResultCount != 2 {
+		t.Errorf("metrics = %+v, want the MMR step recorded", m)
+	}
+}
+
+func TestRAGClient_NegativeCache(t *testing.T) {
+	stub := &stubRetriever{typ: "bm25"}
+	retriever.Register("negative_cache_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	newClient := func(cacheEmpty bool) *RAGClient {
+		pipeline := config.DefaultPipeline()
+		pipeline.Retrievers = []config.RetrieverConfig{{Type: "negative_cache_bm25", Params: map[string]string{"name": "bm25"}}}
+		pipeline.RetrievalProfiles = []config.RetrievalProfile{
+			{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, Threshold: 0.001},
+		}
+		pipeline.DefaultProfile = "default"
+		pipeline.Cache = &config.CacheConfig{L1: &config.CacheLayerConfig{Enable: true, CacheEmpty: cacheEmpty}}
+		client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, nil)
+		return client
+	}
+	search := func(client *RAGClient) int {
+		results, _, _, _ := client.runEnhancedPipeline(context.Background(), "no such thing", RequestOptions{}, nil)
+		return len(results)
+	}
+
+	client := newClient(false)
+	atomic.StoreInt32(&stub.calls, 0)
+	search(client)
+	search(client)
+	if calls := atomic.LoadInt32(&stub.calls); calls != 2 {
+		t.Errorf("without cache_empty retriever called %d times, want 2", calls)
+	}
+
+	client = newClient(true)
+	if client.cacheEmptyTTL != 10*time.Second {
+		t.Errorf("default negative TTL = %v, want 10s", client.cacheEmptyTTL)
+	}
+	client.cacheEmptyTTL = 20 * time.Millisecond
+	atomic.StoreInt32(&stub.calls, 0)
+	search(client)
+	search(client)
+	if calls := atomic.LoadInt32(&stub.calls); calls != 1 {
+		t.Errorf("with cache_empty retriever called %d times, want 1", calls)
+	}
+
+	// Newly available documents show up once the negative entry expires
+	stub.results = []schema.SearchResult{{Document: schema.Document{ID: "new", Content: "fresh"}, Score: 1}}
+	time.Sleep(30 * time.Millisecond)
+	if got := search(client); got != 1 {
+		t.Errorf("after negative TTL search returned %d results, want 1", got)
+	}
+	if got := search(client); got != 1 || atomic.LoadInt32(&stub.calls) != 2 {
+		t.Errorf("positive result should be served from cache, got %d results after %d calls", got, atomic.LoadInt32(&stub.calls))
+	}
+}
+
+func newReloadTestClient(t *testing.T, answer string) *RAGClient {
+	t.Helper()
+	pipeline := config.DefaultPipeline()
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{{Name: "default", Retrievers: []string{"vector"}, TopK: 3, Threshold: 0.001}}
+	pipeline.DefaultProfile = "default"
+	pipeline.Cache = &config.CacheConfig{L1: &config.CacheLayerConfig{Enable: true}}
+	client, _ := newTestRAGClient(t, &config.Config{
+		RAG:      config.RAGConfig{TopK: 3},
+		VectorDB: config.VectorDBConfig{Collection: "reload-" + answer},
+		Pipeline: pipeline,
+	}, &MockLLMProvider{Respond: func(prompt string) (string, error) { return answer, nil }})
+	if _, err := client.CreateChunkFromText("Higress is a cloud native API gateway", "intro"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	return client
+}
+
+func TestRAGClient_ReloadDuringConcurrentChat(t *testing.T) {
+	client := newReloadTestClient(t, "v1")
+	// Every reload builds fresh components, as buildRAGClient does
+	nexts := make(chan *RAGClient, 5)
+	for i := 0; i < cap(nexts); i++ {
+		nexts <- newReloadTestClient(t, "v2")
+	}
+	original := buildRAGClient
+	buildRAGClient = func(cfg *config.Config) (*RAGClient, error) { return <-nexts, nil }
+	defer func() { buildRAGClient = original }()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				answer, err := client.Chat("what is higress?")
+				if err != nil {
+					errs <- err
+					return
+				}
+				if answer != "v1" && answer != "v2" {
+					errs <- fmt.Errorf("unexpected answer %q", answer)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if err := client.Reload(&config.Config{}); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Chat() during reload: %v", err)
+	}
+
+	if answer, err := client.Chat("what is higress?"); err != nil || answer != "v2" {
+		t.Errorf("Chat() after reload = %q, %v, want v2", answer, err)
+	}
+	if client.config.VectorDB.Collection != "reload-v2" {
+		t.Error("Reload() did not swap the config")
+	}
+}
+
+// closingVectorStore records when it is closed
+type closingVectorStore struct {
+	memoryVectorStore
+	closed chan struct{}
+}
+
+func (s *closingVectorStore) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func TestRAGClient_ReloadClosesReplacedComponents(t *testing.T) {
+	client := newReloadTestClient(t, "v1")
+	store := &closingVectorStore{closed: make(chan struct{})}
+	client.vectordbProvider = store
+	scoped := client.WithNamespace("team-a")
+	original := buildRAGClient
+	buildRAGClient = func(cfg *config.Config) (*RAGClient, error) { return newReloadTestClient(t, "v2"), nil }
+	defer func() { buildRAGClient = original }()
+
+	// A request in flight keeps the replaced store open
+	held, done := client.acquire()
+	if err := client.Reload(&config.Config{}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, err := held.vectordbProvider.QueryDocs(context.Background(), &schema.QueryOptions{Limit: 1}); err != nil {
+		t.Errorf("QueryDocs() on the held snapshot error = %v", err)
+	}
+	select {
+	case <-store.closed:
+		t.Fatal("replaced store closed while a request still uses it")
+	default:
+	}
+	done()
+	select {
+	case <-store.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("replaced store not closed after the request finished")
+	}
+
+	// Namespace-scoped clients follow the reload
+	if scoped.snapshot().config.VectorDB.Collection != "reload-v2" {
+		t.Error("scoped client still uses the replaced components")
+	}
+}
+
+func TestRAGClient_ReloadFailureKeepsProviders(t *testing.T) {
+	client := newReloadTestClient(t, "v1")
+	original := buildRAGClient
+	buildRAGClient = func(cfg *config.Config) (*RAGClient, error) { return nil, errors.New("bad config") }
+	defer func() { buildRAGClient = original }()
+
+	if err := client.Reload(&config.Config{}); err == nil {
+		t.Fatal("Reload() expected error")
+	}
+	if answer, err := client.Chat("what is higress?"); err != nil || answer != "v1" {
+		t.Errorf("Chat() = %q, %v, want the original provider", answer, err)
+	}
+}
+
+func TestRAGClient_ChatWithMetrics(t *testing.T) {
+	client, _ := newExplainTestClient(t)
+
+	answer, m, err := client.ChatWithMetrics("what is higress gateway plugins?")
+	if err != nil {
+		t.Fatalf("ChatWithMetrics() error = %v", err)
+	}
+	if answer != "refined" {
+		t.Errorf("answer = %q, want the LLM completion", answer)
+	}
+	if m == nil {
+		t.Fatal("ChatWithMetrics() returned no metrics")
+	}
+	used := strings.Join(m.RetrieversUsed, ",")
+	if !strings.Contains(used, "vector") || !strings.Contains(used, "bm25") {
+		t.Errorf("retrievers used = %v, want vector and bm25", m.RetrieversUsed)
+	}
+	if !m.RerankEnabled || !m.CompressEnabled || m.ProfileName != "default" || !m.Success {
+		t.Errorf("metrics = %+v, want rerank and compress recorded for a successful default profile run", m)
+	}
+	if m.CompressMethod != "truncate" || m.CompressOriginalLength == 0 ||
+		m.CompressCompressedLength >= m.CompressOriginalLength || m.CompressRatio <= 0 {
+		t.Errorf("compression stats = %s %d -> %d (%.2f%%), want truncate to shrink the results",
+			m.CompressMethod, m.CompressOriginalLength, m.CompressCompressedLength, m.CompressRatio)
+	}
+}
+
+func TestRAGClient_ChatWithMetricsSumsLLMUsage(t *testing.T) {
+	client, llmProvider := newExplainTestClient(t)
+	llmProvider.PromptTokens = 120
+	llmProvider.CompletionTokens = 30
+
+	_, m, err := client.ChatWithMetrics("what is higress gateway plugins?")
+	if err != nil {
+		t.Fatalf("ChatWithMetrics() error = %v", err)
+	}
+	calls := len(llmProvider.Prompts)
+	if calls < 2 {
+		t.Fatalf("LLM called %d times, want the CRAG evaluation and the answer", calls)
+	}
+	if m.LLMCalls != calls || m.PromptTokens != 120*calls || m.CompletionTokens != 30*calls {
+		t.Errorf("usage = %d calls, %d prompt, %d completion tokens, want %d calls, %d, %d",
+			m.LLMCalls, m.PromptTokens, m.CompletionTokens, calls, 120*calls, 30*calls)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	for _, field := range []string{`"llm_calls":`, `"prompt_tokens":`, `"completion_tokens":`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("metrics JSON %s is missing %s", data, field)
+		}
+	}
+}
+
+func TestRAGClient_ChatWithMetricsBaseline(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}}, &MockLLMProvider{})
+	if _, err := client.CreateChunkFromText("Higress is an API gateway", "intro"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	if _, m, err := client.ChatWithMetrics("higress gateway"); err != nil || m != nil {
+		t.Errorf("ChatWithMetrics() metrics = %+v, err = %v, want nil metrics without a pipeline", m, err)
+	}
+}
+
+func TestRAGClient_ChatNoContextMode(t *testing.T) {
+	for _, mode := range []string{"", config.NO_CONTEXT_MODE_FAIL_CLOSED} {
+		llmProvider := &MockLLMProvider{Respond: func(prompt string) (string, error) { return "ungrounded", nil }}
+		client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3, NoContextMode: mode}}, llmProvider)
+
+		answer, err := client.Chat("what is higress?")
+		var noContext *NoContextError
+		if mode == "" {
+			if err != nil || answer != "ungrounded" {
+				t.Errorf("Chat() = %q, %v, want the LLM answer by default", answer, err)
+			}
+			continue
+		}
+		if !errors.As(err, &noContext) || noContext.Query != "what is higress?" {
+			t.Errorf("Chat() error = %v, want a NoContextError", err)
+		}
+		if len(llmProvider.Prompts) != 0 {
+			t.Errorf("LLM called %d times, want none without context", len(llmProvider.Prompts))
+		}
+		if _, err := client.ChatStream(context.Background(), "what is higress?"); !errors.As(err, &noContext) {
+			t.Errorf("ChatStream() error = %v, want a NoContextError", err)
+		}
+
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{"query": "what is higress?"}
+		result, err := HandleChat(client)(context.Background(), request)
+		if err != nil || !result.IsError || !strings.Contains(result.Content[0].(mcp.TextContent).Text, "no grounded answer") {
+			t.Errorf("HandleChat() = %+v, %v, want a no grounded answer tool error", result, err)
+		}
+
+		// With context the chat is answered as usual
+		if _, err := client.CreateChunkFromText("Higress is a cloud native API gateway", "intro"); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+		if answer, err := client.Chat("what is higress?"); err != nil || answer != "ungrounded" {
+			t.Errorf("Chat() with context = %q, %v, want the LLM answer", answer, err)
+		}
+	}
+}
+
+func TestHandleChat_IncludeMetrics(t *testing.T) {
+	client, _ := newExplainTestClient(t)
+	for _, include := range []bool{false, true} {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{"query": "what is higress?", "include_metrics": include}
+		result, err := HandleChat(client)(context.Background(), request)
+		if err != nil {
+			t.Fatalf("HandleChat() error = %v", err)
+		}
+		var decoded ChatResponse
+		if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded); err != nil {
+			t.Fatalf("chat output is not JSON: %v", err)
+		}
+		if include && (decoded.Metrics == nil || !decoded.Metrics.RerankEnabled || len(decoded.Metrics.RetrieversUsed) == 0) {
+			t.Errorf("metrics = %+v, want a summary when include_metrics is true", decoded.Metrics)
+		}
+		if !include && decoded.Metrics != nil {
+			t.Errorf("metrics = %+v, want none by default", decoded.Metrics)
+		}
+	}
+}
+
+// streamingLLMProvider streams chunks and fails with err after sending them
+type streamingLLMProvider struct {
+	MockLLMProvider
+	chunks []string
+	err    error
+	// returned, when set, is closed once StreamChat returns
+	returned chan struct{}
+}
+
+func (p *streamingLLMProvider) StreamChat(ctx context.Context, messages []llm.ChatMessage, onChunk func(string)) (string, error) {
+	if p.returned != nil {
+		defer close(p.returned)
+	}
+	p.mu.Lock()
+	p.Prompts = append(p.Prompts, llm.ConcatMessages(messages))
+	p.mu.Unlock()
+	for _, chunk := range p.chunks {
+		onChunk(chunk)
+	}
+	if p.err != nil {
+		return "", p.err
+	}
+	return strings.Join(p.chunks, ""), nil
+}
+
+func newChatStreamTestClient(t *testing.T, provider llm.Provider) *RAGClient {
+	t.Helper()
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}}, provider)
+	if _, err := client.CreateChunkFromText("Higress is a cloud native API gateway", "intro"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	return client
+}
+
+// streamTexts drains chunks, returning the answer pieces and the error item, if any
+func streamTexts(chunks <-chan ChatStreamChunk) ([]string, error) {
+	var texts []string
+	var err error
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			err = chunk.Err
+			continue
+		}
+		texts = append(texts, chunk.Text)
+	}
+	return texts, err
+}
+
+func TestRAGClient_ChatStream(t *testing.T) {
+	provider := &streamingLLMProvider{chunks: []string{"Higress ", "is ", "a gateway"}}
+	chunks, err := newChatStreamTestClient(t, provider).ChatStream(context.Background(), "what is higress gateway")
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	got, err := streamTexts(chunks)
+	if err != nil || strings.Join(got, "|") != "Higress |is |a gateway" {
+		t.Errorf("ChatStream() chunks = %q, %v, want them in generation order", got, err)
+	}
+	if len(provider.Prompts) != 1 || !strings.Contains(provider.Prompts[0], "cloud native API gateway") {
+		t.Errorf("prompts = %q, want retrieval to complete before streaming", provider.Prompts)
+	}
+}
+
+func TestRAGClient_ChatStreamErrors(t *testing.T) {
+	// A failure while generating follows the chunks already produced with an error item
+	provider := &streamingLLMProvider{chunks: []string{"partial"}, err: errors.New("connection reset")}
+	chunks, err := newChatStreamTestClient(t, provider).ChatStream(context.Background(), "higress")
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	got, err := streamTexts(chunks)
+	if len(got) != 1 || got[0] != "partial" || err == nil || err.Error() != "connection reset" {
+		t.Errorf("ChatStream() chunks = %q, %v, want the chunks sent before the failure and its error", got, err)
+	}
+
+	// Providers without streaming deliver the answer as one chunk
+	chunks, err = newChatStreamTestClient(t, &MockLLMProvider{}).ChatStream(context.Background(), "higress")
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	got, err = streamTexts(chunks)
+	if err != nil || len(got) != 1 || got[0] != "mock answer" {
+		t.Errorf("ChatStream() chunks = %q, %v, want the full answer as one chunk", got, err)
+	}
+
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}}, nil)
+	if _, err := client.ChatStream(context.Background(), "higress"); err == nil {
+		t.Error("ChatStream() expected error without an llm provider")
+	}
+}
+
+func TestRAGClient_ChatStreamCanceled(t *testing.T) {
+	// More chunks than the buffer holds: generation blocks on the reader until ctx is done
+	pieces := make([]string, CHAT_STREAM_BUFFER*2)
+	for i := range pieces {
+		pieces[i] = "x"
+	}
+	provider := &streamingLLMProvider{chunks: pieces, returned: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, err := newChatStreamTestClient(t, provider).ChatStream(ctx, "higress")
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	<-chunks
+	cancel()
+
+	select {
+	case <-provider.returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ChatStream() generation still blocked after the context was canceled")
+	}
+}
+
+func TestHandleChatStream(t *testing.T) {
+	client := newChatStreamTestClient(t, &streamingLLMProvider{chunks: []string{"Higress ", "is a gateway"}})
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"query": "what is higress?"}
+	result, err := HandleChatStream(client)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("HandleChatStream() error = %v", err)
+	}
+	var decoded struct {
+		Answer string `json:"answer"`
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded); err != nil || decoded.Answer != "Higress is a gateway" {
+		t.Errorf("HandleChatStream() output = %s, %v, want the joined answer", result.Content[0].(mcp.TextContent).Text, err)
+	}
+
+	// A failure midway fails the call instead of returning the truncated answer
+	client = newChatStreamTestClient(t, &streamingLLMProvider{chunks: []string{"Higress "}, err: errors.New("connection reset")})
+	if result, err := HandleChatStream(client)(context.Background(), request); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("HandleChatStream() = %v, %v, want the generation error", result, err)
+	}
+}
+
+func TestRAGClient_RewriteVariants(t *testing.T) {
+	stub := &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "kw-1", Content: "Higress keyword hit"}, Score: 4.2},
+	}}
+	retriever.Register("rewrite_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Pre = &config.PreConfig{}
+	pipeline.Pre.Rewrite.Enable = true
+	pipeline.Pre.Rewrite.Variants = []string{"{query} tutorial", "guide", "{query} example"}
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "rewrite_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, Threshold: 0.001, MaxFanout: 3},
+	}
+	pipeline.DefaultProfile = "default"
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, nil)
+
+	results, _, m, err := client.runEnhancedPipeline(context.Background(), "higress", RequestOptions{}, nil)
+	if err != nil {
+		t.Fatalf("runEnhancedPipeline() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID != "kw-1" {
+		t.Errorf("results = %+v, want the variant hits deduplicated", results)
+	}
+	if calls := atomic.LoadInt32(&stub.calls); calls != 3 {
+		t.Errorf("retriever called %d times, want the query and 2 variants within max_fanout", calls)
+	}
+	if m == nil || m.QueryVariantsExecuted != 2 {
+		t.Errorf("metrics = %+v, want query_variants_executed 2", m)
+	}
+}
+
+func TestRewriteVariants(t *testing.T) {
+	result := &pre_retrieve.PreRetrieveResult{
+		Plan: pre_retrieve.PreQRAGPlan{Nodes: []pre_retrieve.QueryNode{{ID: "n1", DenseRewrite: "higress wasm"}}},
+		Expansions: map[string]pre_retrieve.QueryExpansion{"n1": {NodeID: "n1", Terms: []pre_retrieve.ExpansionTerm{
+			{Term: "plugin", Weight: 0.4},
+			{Term: "wasm", Weight: 0.9},
+			{Term: "filter", Weight: 0.7},
+		}}},
+	}
+	got := rewriteVariants([]string{"how to {query}", "guide"}, []string{"higress wasm", "higress"}, result)
+	want := map[string][]string{
+		"higress wasm": {"how to higress wasm", "higress wasm guide", "higress wasm filter", "higress wasm plugin"},
+		"higress":      {"how to higress", "higress guide"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rewriteVariants() = %v, want %v", got, want)
+	}
+}
+
+func TestHandleChat_Citations(t *testing.T) {
+	mockLLM := &MockLLMProvider{Respond: func(prompt string) (string, error) {
+		return "Higress is a cloud native API gateway [1]. It is fast [5].", nil
+	}}
+	client := newChatStreamTestClient(t, mockLLM)
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"query": "what is higress", "citations": true}
+	result, err := HandleChat(client)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("HandleChat() error = %v", err)
+	}
+	var decoded ChatResponse
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded); err != nil {
+		t.Fatalf("chat output is not JSON: %v", err)
+	}
+	if decoded.Answer != "Higress is a cloud native API gateway [1]. It is fast." {
+		t.Errorf("answer = %q, want the marker citing no source removed", decoded.Answer)
+	}
+	if len(decoded.Citations) != 1 {
+		t.Fatalf("citations = %+v, want the one valid citation", decoded.Citations)
+	}
+	c := decoded.Citations[0]
+	if c.Index != 1 || c.ChunkID != decoded.Sources[0].Document.ID || c.Title != "intro" || c.Snippet != "Higress is a cloud native API gateway" {
+		t.Errorf("citation = %+v, want source 1", c)
+	}
+	if !strings.Contains(mockLLM.Prompts[0], "[1] intro:") || !strings.Contains(mockLLM.Prompts[0], "Citation rules") {
+		t.Errorf("prompt = %q, want numbered contexts and the citation instruction", mockLLM.Prompts[0])
+	}
+
+	resp, err := client.ChatWithSources("what is higress", RequestOptions{})
+	if err != nil || resp.Citations != nil || !strings.Contains(resp.Answer, "[5]") {
+		t.Errorf("ChatWithSources() = %+v, %v, want the raw answer without citations by default", resp, err)
+	}
+}
+
+func TestRAGClient_ParentChunks(t *testing.T) {
+	pipeline := config.DefaultPipeline()
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"vector"}, TopK: 2, Threshold: 0.001, ExpandParents: true},
+	}
+	pipeline.DefaultProfile = "default"
+	client, _ := newTestRAGClient(t, &config.Config{
+		RAG: config.RAGConfig{TopK: 2, Splitter: config.SplitterConfig{
+			Provider: "recursive", ChunkSize: 30, ParentChunkSize: 80,
+		}},
+		Pipeline: pipeline,
+	}, nil)
+
+	routes := "Higress routes traffic. Higress matches hosts. Higress matches paths."
+	plugins := "Higress runs wasm plugins. Higress plugins use Go. Higress plugins use Rust."
+	docs, err := client.CreateChunkFromText(routes+"\n\n"+plugins, "guide")
+	if err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	parents := map[string]string{}
+	for _, doc := range docs {
+		id, _ := doc.Metadata[schema.METADATA_PARENT_ID].(string)
+		content, _ := doc.Metadata[schema.METADATA_PARENT_CONTENT].(string)
+		if id == "" || !strings.Contains(content, strings.TrimSpace(doc.Content)) {
+			t.Fatalf("chunk %q has parent %q %q, want the section it was cut from", doc.Content, id, content)
+		}
+		parents[id] = content
+	}
+	if len(docs) <= 2 || len(parents) != 2 {
+		t.Fatalf("got %d chunks of %d parents, want more chunks than the 2 parents", len(docs), len(parents))
+	}
+
+	// The best chunks all share the plugins parent, so TopK counts parents, not chunks
+	results, _, m, err := client.runEnhancedPipeline(context.Background(), "higress plugins", RequestOptions{}, nil)
+	if err != nil {
+		t.Fatalf("runEnhancedPipeline() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Document.Content != plugins || results[1].Document.Content != routes {
+		t.Errorf("results = %+v, want the plugins then the routes section", results)
+	}
+	if _, ok := results[0].Document.Metadata[schema.METADATA_PARENT_CONTENT]; ok || m.ParentsExpanded != 2 {
+		t.Errorf("metadata = %v with %d expanded, want the parent content moved out", results[0].Document.Metadata, m.ParentsExpanded)
+	}
+}
+
+func TestRAGClient_MetadataFields(t *testing.T) {
+	llmProvider := &MockLLMProvider{}
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}}, llmProvider)
+	if _, err := client.CreateChunkFromText("Higress gateway overview", "intro"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+
+	results, err := client.SearchChunksPipeline("higress gateway", RequestOptions{})
+	if err != nil || len(results) != 1 || len(results[0].Document.Metadata) != 3 {
+		t.Fatalf("SearchChunksPipeline() = %+v, %v, want all metadata by default", results, err)
+	}
+	results, err = client.SearchChunksPipeline("higress gateway", RequestOptions{MetadataFields: []string{"chunk_title", "url"}})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("SearchChunksPipeline() = %+v, %v", results, err)
+	}
+	if meta := results[0].Document.Metadata; len(meta) != 1 || meta["chunk_title"] != "intro" {
+		t.Errorf("metadata = %v, want only chunk_title", meta)
+	}
+	if len(store.docs[0].Metadata) != 3 {
+		t.Errorf("stored metadata = %v, want it unchanged", store.docs[0].Metadata)
+	}
+
+	reply, err := client.ChatWithSources("higress gateway", RequestOptions{MetadataFields: []string{}})
+	if err != nil {
+		t.Fatalf("ChatWithSources() error = %v", err)
+	}
+	if len(reply.Sources) != 1 || len(reply.Sources[0].Document.Metadata) != 0 {
+		t.Errorf("sources = %+v, want no metadata", reply.Sources)
+	}
+	if prompt := llmProvider.Prompts[0]; !strings.Contains(prompt, "Higress gateway overview") {
+		t.Errorf("prompt = %q, want the context", prompt)
+	}
+}
@@ -0,0 +1,456 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/embedding"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/vectordb"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// REINDEX_BATCH_SIZE is the default number of chunks re-embedded and written per batch
+const REINDEX_BATCH_SIZE = 100
+
+// REINDEX_STAGING_SUFFIX names the collection ReindexCollection stages chunks in while the
+// active collection is recreated for a new embedding dimension
+const REINDEX_STAGING_SUFFIX = "_reindex"
+
+// States of the background collection reindex
+const (
+	REINDEX_STATE_IDLE      = "idle"
+	REINDEX_STATE_RUNNING   = "running"
+	REINDEX_STATE_COMPLETED = "completed"
+	REINDEX_STATE_FAILED    = "failed"
+	REINDEX_STATE_CANCELLED = "cancelled"
+)
+
+var (
+	// ErrReindexRunning rejects ingestion while ReindexCollection rewrites the collection
+	ErrReindexRunning = errors.New("collection reindex is running")
+	// ErrIngestionRunning rejects ReindexCollection while chunks are being written or deleted
+	ErrIngestionRunning = errors.New("ingestion is running")
+)
+
+// ReindexProgress reports how far ReindexCollection got
+type ReindexProgress struct {
+	// Total is the number of chunks in the collection when the reindex started
+	Total int64 `json:"total"`
+	// Processed is the number of chunks re-embedded so far
+	Processed int `json:"processed"`
+	// Recreated is set when the collection is recreated for a new embedding dimension
+	Recreated bool `json:"recreated,omitempty"`
+}
+
+// ReindexStatus is the state of the background collection reindex
+type ReindexStatus struct {
+	State string `json:"state"`
+	ReindexProgress
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// reindexGuard keeps ReindexCollection from running concurrently with ingestion and
+// tracks the background reindex. It is shared by snapshots and kept across Reload.
+type reindexGuard struct {
+	// ingestion holds the read lock while writing chunks, ReindexCollection the write lock
+	ingestion sync.RWMutex
+
+	mu     sync.Mutex
+	status ReindexStatus
+	cancel context.CancelFunc
+}
+
+// beginIngestion takes the ingestion lock, failing fast while a reindex runs. A nil guard
+// allows everything.
+func (g *reindexGuard) beginIngestion() (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	if !g.ingestion.TryRLock() {
+		return nil, ErrReindexRunning
+	}
+	return g.ingestion.RUnlock, nil
+}
+
+// beginReindex takes the ingestion lock exclusively, failing fast while chunks are being
+// written or another reindex runs
+func (g *reindexGuard) beginReindex() (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	if !g.ingestion.TryLock() {
+		return nil, fmt.Errorf("%w or another reindex is running", ErrIngestionRunning)
+	}
+	return g.ingestion.Unlock, nil
+}
+
+// newVectorDBProvider creates the store Reindex writes into; tests replace it to avoid real backends
+var newVectorDBProvider = vectordb.NewVectorDBProvider
+
+// Reindex re-embeds every chunk of the active collection with the current embedding
+// provider into newCollection, then switches the client to newCollection and returns the
+// number of chunks written. Chunks keep their IDs, and chunks already present in
+// newCollection are skipped, so an interrupted reindex is resumed by running it again.
+// The switch rebuilds the providers like Reload, which moves the L1 cache onto the new
+// index version; it is not persisted, so vectordb.collection must be updated to keep it
+// across restarts. The previous collection is left in place. Like ReindexCollection it
+// fails with ErrIngestionRunning while chunks are written, and ingestion fails with
+// ErrReindexRunning until it returns, so no chunk is written to the previous collection
+// after it was copied.
+func (r *RAGClient) Reindex(newCollection string, batchSize int) (int, error) {
+	newCollection = strings.TrimSpace(newCollection)
+	current, done := r.acquire()
+	defer done()
+	if newCollection == "" {
+		return 0, fmt.Errorf("new collection is required")
+	}
+	if newCollection == current.config.VectorDB.Collection {
+		return 0, fmt.Errorf("collection %s is already active", newCollection)
+	}
+	if batchSize <= 0 {
+		batchSize = REINDEX_BATCH_SIZE
+	}
+	release, err := current.reindexGuard.beginReindex()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	cfg := *current.config
+	cfg.VectorDB.Collection = newCollection
+	target, err := newVectorDBProvider(&cfg.VectorDB, cfg.Embedding.Dimensions)
+	if err != nil {
+		return 0, fmt.Errorf("create vector store for %s failed, err: %w", newCollection, err)
+	}
+
+	ctx := context.Background()
+	migrated, err := copyChunks(ctx, current.vectordbProvider, target, batchSize, func(docs []schema.Document) error {
+		return current.reembed(ctx, docs)
+	}, nil)
+	if err != nil {
+		return migrated, err
+	}
+
+	if err := r.Reload(&cfg); err != nil {
+		return migrated, err
+	}
+	api.LogInfof("rag: reindexed %d chunks into collection %s", migrated, newCollection)
+	return migrated, nil
+}
+
+// pendingDocs returns the docs whose IDs are not yet stored in target
+func pendingDocs(ctx context.Context, target vectordb.VectorStoreProvider, docs []schema.Document) ([]schema.Document, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	existing, err := target.QueryDocs(ctx, &schema.QueryOptions{IDs: ids, Limit: len(ids)})
+	if err != nil {
+		return nil, fmt.Errorf("query migrated chunks failed, err: %w", err)
+	}
+	done := make(map[string]bool, len(existing))
+	for _, doc := range existing {
+		done[doc.ID] = true
+	}
+	pending := make([]schema.Document, 0, len(docs))
+	for _, doc := range docs {
+		if !done[doc.ID] {
+			pending = append(pending, doc)
+		}
+	}
+	return pending, nil
+}
+
+// reembed replaces the dense and, when configured, sparse vectors of docs in place
+func (r *RAGClient) reembed(ctx context.Context, docs []schema.Document) error {
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.Content
+	}
+	vectors, err := embedding.GetEmbeddings(ctx, r.embeddingProvider, texts)
+	if err != nil {
+		return fmt.Errorf("create embedding failed, err: %w", err)
+	}
+	if len(vectors) != len(docs) {
+		return fmt.Errorf("create embedding failed, got %d vectors for %d chunks", len(vectors), len(docs))
+	}
+	for i := range docs {
+		docs[i].Vector = r.normalizeEmbedding(vectors[i])
+		if r.sparseEmbeddingProvider != nil {
+			sparse, err := r.sparseEmbeddingProvider.GetSparseEmbedding(ctx, docs[i].Content)
+			if err != nil {
+				return fmt.Errorf("create sparse embedding failed, err: %w", err)
+			}
+			docs[i].SparseVector = sparse
+		}
+	}
+	return nil
+}
+
+// ReindexCollection re-embeds every chunk of the active collection in place with the
+// current embedding provider, batchSize chunks at a time (<= 0 => REINDEX_BATCH_SIZE),
+// calling progress after each batch, and returns the number of chunks re-embedded. Chunk
+// IDs and metadata are kept. It fails with ErrIngestionRunning while chunks are written,
+// and ingestion fails with ErrReindexRunning until it returns. Cancelling ctx stops it
+// between batches; chunks already re-embedded keep their new vectors.
+//
+// When the stored vectors do not have the configured embedding dimension, the chunks are
+// re-embedded into a staging collection first, then the collection is recreated with the
+// new dimension and the staged chunks are copied back. A reindex interrupted after the
+// collection was recreated leaves it empty and is finished by running it again.
+func (r *RAGClient) ReindexCollection(ctx context.Context, batchSize int, progress func(ReindexProgress)) (int, error) {
+	release, err := r.reindexGuard.beginReindex()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return r.reindexCollection(ctx, batchSize, progress)
+}
+
+// StartReindexCollection runs ReindexCollection in the background and returns its initial
+// status; ReindexCollectionStatus reports its progress and CancelReindexCollection stops it.
+func (r *RAGClient) StartReindexCollection(batchSize int) (ReindexStatus, error) {
+	g := r.reindexGuard
+	if g == nil {
+		return ReindexStatus{}, fmt.Errorf("collection reindex is not supported by this client")
+	}
+	release, err := g.beginReindex()
+	if err != nil {
+		return g.snapshot(), err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	started := time.Now()
+	g.mu.Lock()
+	g.status = ReindexStatus{State: REINDEX_STATE_RUNNING, StartedAt: &started}
+	g.cancel = cancel
+	status := g.status
+	g.mu.Unlock()
+
+	go func() {
+		defer release()
+		defer cancel()
+		count, err := r.reindexCollection(ctx, batchSize, func(p ReindexProgress) {
+			g.mu.Lock()
+			g.status.ReindexProgress = p
+			g.mu.Unlock()
+		})
+		finished := time.Now()
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.status.Processed = count
+		g.status.FinishedAt = &finished
+		g.cancel = nil
+		switch {
+		case err == nil:
+			g.status.State = REINDEX_STATE_COMPLETED
+		case errors.Is(err, context.Canceled):
+			g.status.State = REINDEX_STATE_CANCELLED
+		default:
+			g.status.State = REINDEX_STATE_FAILED
+			g.status.Error = err.Error()
+		}
+		api.LogInfof("rag: collection reindex %s after %d chunks", g.status.State, count)
+	}()
+	return status, nil
+}
+
+// ReindexCollectionStatus returns the status of the last background reindex
+func (r *RAGClient) ReindexCollectionStatus() ReindexStatus {
+	if r.reindexGuard == nil {
+		return ReindexStatus{State: REINDEX_STATE_IDLE}
+	}
+	return r.reindexGuard.snapshot()
+}
+
+// CancelReindexCollection stops the running background reindex and reports whether one
+// was running
+func (r *RAGClient) CancelReindexCollection() bool {
+	g := r.reindexGuard
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cancel == nil {
+		return false
+	}
+	g.cancel()
+	return true
+}
+
+// snapshot returns a copy of the status
+func (g *reindexGuard) snapshot() ReindexStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status := g.status
+	if status.State == "" {
+		status.State = REINDEX_STATE_IDLE
+	}
+	return status
+}
+
+// reindexCollection implements ReindexCollection; the caller holds the ingestion lock
+func (r *RAGClient) reindexCollection(ctx context.Context, batchSize int, progress func(ReindexProgress)) (int, error) {
+	current, done := r.acquire()
+	defer done()
+	if batchSize <= 0 {
+		batchSize = REINDEX_BATCH_SIZE
+	}
+	if progress == nil {
+		progress = func(ReindexProgress) {}
+	}
+	store := current.vectordbProvider
+	dim := current.config.Embedding.Dimensions
+	defer current.InvalidateCache()
+
+	total, err := store.Count(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("count chunks failed, err: %w", err)
+	}
+	sample, err := store.QueryDocs(ctx, &schema.QueryOptions{Limit: 1})
+	if err != nil {
+		return 0, fmt.Errorf("read chunks failed, err: %w", err)
+	}
+	recreate := len(sample) > 0 && len(sample[0].Vector) > 0 && len(sample[0].Vector) != dim
+	if !recreate && total > 0 {
+		// Milvus updates by delete and insert, so page over the IDs listed up front
+		ids, err := listChunkIDs(ctx, store, batchSize)
+		if err != nil {
+			return 0, err
+		}
+		processed := 0
+		for start := 0; start < len(ids); start += batchSize {
+			if err := ctx.Err(); err != nil {
+				return processed, err
+			}
+			batch := ids[start:min(start+batchSize, len(ids))]
+			docs, err := store.QueryDocs(ctx, &schema.QueryOptions{IDs: batch, Limit: len(batch)})
+			if err != nil {
+				return processed, fmt.Errorf("read chunks failed, err: %w", err)
+			}
+			if err := current.reembed(ctx, docs); err != nil {
+				return processed, err
+			}
+			if err := store.UpdateDoc(ctx, docs); err != nil {
+				return processed, fmt.Errorf("update documents failed, err: %w", err)
+			}
+			processed += len(docs)
+			progress(ReindexProgress{Total: total, Processed: processed})
+			api.LogInfof("rag: reindexed %d/%d chunks", processed, total)
+		}
+		return processed, nil
+	}
+
+	// The dimension changed, or an interrupted run left the collection empty
+	cfg := *current.config
+	cfg.VectorDB.Collection += REINDEX_STAGING_SUFFIX
+	staging, err := newVectorDBProvider(&cfg.VectorDB, dim)
+	if err != nil {
+		return 0, fmt.Errorf("create staging vector store %s failed, err: %w", cfg.VectorDB.Collection, err)
+	}
+	processed, err := copyChunks(ctx, store, staging, batchSize, func(docs []schema.Document) error {
+		return current.reembed(ctx, docs)
+	}, func(n int) {
+		progress(ReindexProgress{Total: total, Processed: n, Recreated: recreate})
+	})
+	if err != nil {
+		return processed, err
+	}
+	staged, err := staging.Count(ctx, nil)
+	if err != nil {
+		return processed, fmt.Errorf("count staged chunks failed, err: %w", err)
+	}
+	if staged == 0 {
+		return processed, staging.DropCollection(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return processed, err
+	}
+	api.LogInfof("rag: recreating collection %s with dimension %d", current.config.VectorDB.Collection, dim)
+	if err := store.DropCollection(ctx); err != nil {
+		return processed, fmt.Errorf("drop collection failed, err: %w", err)
+	}
+	if err := store.CreateCollection(ctx, dim); err != nil {
+		return processed, fmt.Errorf("create collection failed, err: %w", err)
+	}
+	// Staged chunks already carry the new vectors; the copy back is not cancelled so an
+	// interrupted reindex does not leave a partly filled collection
+	if _, err := copyChunks(context.WithoutCancel(ctx), staging, store, batchSize, nil, nil); err != nil {
+		return processed, fmt.Errorf("copy staged chunks back failed, they are kept in %s, err: %w", cfg.VectorDB.Collection, err)
+	}
+	if err := staging.DropCollection(ctx); err != nil {
+		api.LogWarnf("rag: drop staging collection %s failed: %v", cfg.VectorDB.Collection, err)
+	}
+	api.LogInfof("rag: reindexed %d chunks and recreated collection %s", processed, current.config.VectorDB.Collection)
+	return processed, nil
+}
+
+// listChunkIDs returns the IDs of every chunk in store
+func listChunkIDs(ctx context.Context, store vectordb.VectorStoreProvider, pageSize int) ([]string, error) {
+	var ids []string
+	for offset := 0; ; offset += pageSize {
+		docs, err := store.QueryDocs(ctx, &schema.QueryOptions{Limit: pageSize, Offset: offset})
+		if err != nil {
+			return nil, fmt.Errorf("read chunks failed, err: %w", err)
+		}
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+		if len(docs) < pageSize {
+			return ids, nil
+		}
+	}
+}
+
+// copyChunks adds the chunks of source missing from target in batches, passing each batch
+// through transform first, and returns the number of chunks added. Chunks already in
+// target are skipped, so an interrupted copy is resumed by running it again.
+func copyChunks(
+	ctx context.Context,
+	source, target vectordb.VectorStoreProvider,
+	batchSize int,
+	transform func([]schema.Document) error,
+	progress func(int),
+) (int, error) {
+	copied := 0
+	for offset := 0; ; offset += batchSize {
+		if err := ctx.Err(); err != nil {
+			return copied, err
+		}
+		docs, err := source.QueryDocs(ctx, &schema.QueryOptions{Limit: batchSize, Offset: offset})
+		if err != nil {
+			return copied, fmt.Errorf("read chunks failed, err: %w", err)
+		}
+		pending, err := pendingDocs(ctx, target, docs)
+		if err != nil {
+			return copied, err
+		}
+		if len(pending) > 0 {
+			if transform != nil {
+				if err := transform(pending); err != nil {
+					return copied, err
+				}
+			}
+			if err := target.AddDoc(ctx, pending); err != nil {
+				return copied, fmt.Errorf("add documents failed, err: %w", err)
+			}
+			copied += len(pending)
+			if progress != nil {
+				progress(copied)
+			}
+		}
+		if len(docs) < batchSize {
+			return copied, nil
+		}
+	}
+}
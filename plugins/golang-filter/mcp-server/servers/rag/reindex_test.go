@@ -0,0 +1,302 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/vectordb"
+)
+
+// newReindexTestClient returns a client over a store of n chunks with stale vectors, and
+// routes the store and client built for the new collection to target
+func newReindexTestClient(t *testing.T, n int, target *memoryVectorStore) (*RAGClient, *memoryVectorStore) {
+	t.Helper()
+	client, source := newTestRAGClient(t, &config.Config{
+		RAG:      config.RAGConfig{TopK: 10},
+		VectorDB: config.VectorDBConfig{Collection: "v1"},
+	}, nil)
+	stale := make([]float32, 64)
+	stale[0] = 1
+	for i := 0; i < n; i++ {
+		source.docs = append(source.docs, schema.Document{
+			ID:        fmt.Sprintf("chunk-%d", i),
+			Content:   fmt.Sprintf("higress gateway document %d", i),
+			Metadata:  map[string]interface{}{"chunk_index": i},
+			Vector:    stale,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	originalStore, originalBuild := newVectorDBProvider, buildRAGClient
+	t.Cleanup(func() { newVectorDBProvider, buildRAGClient = originalStore, originalBuild })
+	newVectorDBProvider = func(cfg *config.VectorDBConfig, dim int) (vectordb.VectorStoreProvider, error) {
+		if cfg.Collection != "v2" || dim != 64 {
+			t.Errorf("new store for collection %s with dim %d, want v2 with dim 64", cfg.Collection, dim)
+		}
+		return target, nil
+	}
+	buildRAGClient = func(cfg *config.Config) (*RAGClient, error) {
+		next, _ := newTestRAGClient(t, cfg, nil)
+		next.vectordbProvider = target
+		return next, nil
+	}
+	return client, source
+}
+
+func TestRAGClient_Reindex(t *testing.T) {
+	target := &memoryVectorStore{}
+	client, source := newReindexTestClient(t, 5, target)
+
+	count, err := client.Reindex("v2", 2)
+	if err != nil {
+		t.Fatalf("Reindex() error = %v", err)
+	}
+	if count != 5 || len(target.docs) != 5 {
+		t.Fatalf("Reindex() = %d, target holds %d chunks, want 5", count, len(target.docs))
+	}
+	embedder := &MockEmbeddingProvider{Dim: 64}
+	for i, doc := range target.docs {
+		want, _ := embedder.GetEmbedding(context.Background(), doc.Content)
+		if doc.ID != source.docs[i].ID || !reflect.DeepEqual(doc.Vector, want) {
+			t.Errorf("chunk %d = %s with vector %v, want %s re-embedded", i, doc.ID, doc.Vector[:4], source.docs[i].ID)
+		}
+		if doc.Metadata["chunk_index"] != i {
+			t.Errorf("chunk %d metadata = %v, want it kept", i, doc.Metadata)
+		}
+	}
+
+	active := client.snapshot()
+	if active.config.VectorDB.Collection != "v2" || active.indexVersion != "v2" || active.vectordbProvider != target {
+		t.Errorf("active collection = %s, index version %s, want the client switched to v2", active.config.VectorDB.Collection, active.indexVersion)
+	}
+	results, err := client.SearchChunks("higress gateway document 3", 1, 0)
+	if err != nil || len(results) != 1 || results[0].Document.ID != "chunk-3" {
+		t.Errorf("SearchChunks() after reindex = %+v, %v, want chunk-3", results, err)
+	}
+}
+
+func TestRAGClient_ReindexResumes(t *testing.T) {
+	target := &memoryVectorStore{}
+	client, source := newReindexTestClient(t, 5, target)
+	// A previous run migrated the first two chunks before it was interrupted
+	target.docs = append(target.docs, source.docs[:2]...)
+	embedder := client.embeddingProvider.(*MockEmbeddingProvider)
+
+	count, err := client.Reindex("v2", 0)
+	if err != nil {
+		t.Fatalf("Reindex() error = %v", err)
+	}
+	if count != 3 || embedder.Calls != 3 {
+		t.Errorf("Reindex() = %d with %d embeddings, want only the 3 remaining chunks", count, embedder.Calls)
+	}
+	seen := make(map[string]int)
+	for _, doc := range target.docs {
+		seen[doc.ID]++
+	}
+	if len(seen) != 5 || len(target.docs) != 5 {
+		t.Errorf("target holds %d chunks with %d distinct IDs, want 5 without duplicates", len(target.docs), len(seen))
+	}
+}
+
+func TestRAGClient_ReindexRejectsActiveCollection(t *testing.T) {
+	target := &memoryVectorStore{}
+	client, _ := newReindexTestClient(t, 1, target)
+	for _, collection := range []string{"", " ", "v1"} {
+		if _, err := client.Reindex(collection, 10); err == nil {
+			t.Errorf("Reindex(%q) expected error", collection)
+		}
+	}
+	if len(target.docs) != 0 || client.snapshot().indexVersion != "v1" {
+		t.Error("rejected Reindex() must not write or switch collections")
+	}
+}
+
+func TestRAGClient_ReindexExcludesIngestion(t *testing.T) {
+	target := &memoryVectorStore{}
+	client, _ := newReindexTestClient(t, 1, target)
+
+	release, err := client.reindexGuard.beginIngestion()
+	if err != nil {
+		t.Fatalf("beginIngestion() error = %v", err)
+	}
+	if _, err := client.Reindex("v2", 10); !errors.Is(err, ErrIngestionRunning) {
+		t.Errorf("Reindex() during ingestion error = %v, want ErrIngestionRunning", err)
+	}
+	release()
+	if len(target.docs) != 0 || client.snapshot().indexVersion != "v1" {
+		t.Error("rejected Reindex() must not write or switch collections")
+	}
+
+	// Ingestion is rejected while the chunks are copied
+	newVectorDBProvider = func(cfg *config.VectorDBConfig, dim int) (vectordb.VectorStoreProvider, error) {
+		if _, err := client.CreateChunkFromText("new chunk", "t"); !errors.Is(err, ErrReindexRunning) {
+			t.Errorf("CreateChunkFromText() during reindex error = %v, want ErrReindexRunning", err)
+		}
+		return target, nil
+	}
+	if _, err := client.Reindex("v2", 10); err != nil {
+		t.Fatalf("Reindex() error = %v", err)
+	}
+	if _, err := client.CreateChunkFromText("new chunk", "t"); err != nil {
+		t.Errorf("CreateChunkFromText() after reindex error = %v", err)
+	}
+}
+
+// newCollectionReindexTestClient returns a client over a store of n chunks whose vectors
+// have staleDim dimensions, and routes the staging store to staging
+func newCollectionReindexTestClient(t *testing.T, n, staleDim int, staging *memoryVectorStore) (*RAGClient, *memoryVectorStore) {
+	t.Helper()
+	client, store := newTestRAGClient(t, &config.Config{
+		RAG:      config.RAGConfig{TopK: 10},
+		VectorDB: config.VectorDBConfig{Collection: "v1"},
+	}, nil)
+	for i := 0; i < n; i++ {
+		stale := make([]float32, staleDim)
+		stale[0] = 1
+		store.docs = append(store.docs, schema.Document{
+			ID:       fmt.Sprintf("chunk-%d", i),
+			Content:  fmt.Sprintf("higress gateway document %d", i),
+			Metadata: map[string]interface{}{"chunk_index": i},
+			Vector:   stale,
+		})
+	}
+	original := newVectorDBProvider
+	t.Cleanup(func() { newVectorDBProvider = original })
+	newVectorDBProvider = func(cfg *config.VectorDBConfig, dim int) (vectordb.VectorStoreProvider, error) {
+		if cfg.Collection != "v1"+REINDEX_STAGING_SUFFIX || dim != 64 {
+			t.Errorf("staging store for collection %s with dim %d, want v1_reindex with dim 64", cfg.Collection, dim)
+		}
+		return staging, nil
+	}
+	return client, store
+}
+
+// assertReembedded checks that store holds chunk-0..n-1 in order with fresh vectors
+func assertReembedded(t *testing.T, store *memoryVectorStore, n int) {
+	t.Helper()
+	if len(store.docs) != n {
+		t.Fatalf("store holds %d chunks, want %d", len(store.docs), n)
+	}
+	embedder := &MockEmbeddingProvider{Dim: 64}
+	for i, doc := range store.docs {
+		want, _ := embedder.GetEmbedding(context.Background(), doc.Content)
+		if doc.ID != fmt.Sprintf("chunk-%d", i) || doc.Metadata["chunk_index"] != i || !reflect.DeepEqual(doc.Vector, want) {
+			t.Errorf("chunk %d = %s %v with %d dims, want it re-embedded with its ID and metadata", i, doc.ID, doc.Metadata, len(doc.Vector))
+		}
+	}
+}
+
+func TestRAGClient_ReindexCollection(t *testing.T) {
+	staging := &memoryVectorStore{}
+	client, store := newCollectionReindexTestClient(t, 5, 64, staging)
+
+	var reports []ReindexProgress
+	count, err := client.ReindexCollection(context.Background(), 2, func(p ReindexProgress) {
+		reports = append(reports, p)
+	})
+	if err != nil {
+		t.Fatalf("ReindexCollection() error = %v", err)
+	}
+	if count != 5 {
+		t.Errorf("ReindexCollection() = %d, want 5", count)
+	}
+	assertReembedded(t, store, 5)
+	want := []ReindexProgress{{Total: 5, Processed: 2}, {Total: 5, Processed: 4}, {Total: 5, Processed: 5}}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("progress = %+v, want %+v", reports, want)
+	}
+}
+
+func TestRAGClient_ReindexCollectionRecreatesForNewDimension(t *testing.T) {
+	staging := &memoryVectorStore{}
+	client, store := newCollectionReindexTestClient(t, 3, 32, staging)
+
+	var last ReindexProgress
+	count, err := client.ReindexCollection(context.Background(), 2, func(p ReindexProgress) { last = p })
+	if err != nil {
+		t.Fatalf("ReindexCollection() error = %v", err)
+	}
+	if count != 3 || !last.Recreated || last.Processed != 3 {
+		t.Errorf("ReindexCollection() = %d with last progress %+v, want 3 chunks recreated", count, last)
+	}
+	assertReembedded(t, store, 3)
+	if len(staging.docs) != 0 {
+		t.Errorf("staging holds %d chunks, want it dropped", len(staging.docs))
+	}
+
+	// A run interrupted after the collection was recreated is finished by the next one
+	staging.docs = append(staging.docs, store.docs...)
+	store.docs = nil
+	if _, err := client.ReindexCollection(context.Background(), 2, nil); err != nil {
+		t.Fatalf("ReindexCollection() error = %v", err)
+	}
+	assertReembedded(t, store, 3)
+}
+
+func TestRAGClient_ReindexCollectionExcludesIngestion(t *testing.T) {
+	client, store := newCollectionReindexTestClient(t, 1, 64, &memoryVectorStore{})
+
+	release, err := client.reindexGuard.beginIngestion()
+	if err != nil {
+		t.Fatalf("beginIngestion() error = %v", err)
+	}
+	if _, err := client.ReindexCollection(context.Background(), 0, nil); !errors.Is(err, ErrIngestionRunning) {
+		t.Errorf("ReindexCollection() during ingestion error = %v, want ErrIngestionRunning", err)
+	}
+	release()
+
+	release, err = client.reindexGuard.beginReindex()
+	if err != nil {
+		t.Fatalf("beginReindex() error = %v", err)
+	}
+	if _, err := client.WithNamespace("team-a").CreateChunkFromText("new chunk", "t"); !errors.Is(err, ErrReindexRunning) {
+		t.Errorf("CreateChunkFromText() during reindex error = %v, want ErrReindexRunning", err)
+	}
+	if err := client.DeleteChunk("chunk-0"); !errors.Is(err, ErrReindexRunning) {
+		t.Errorf("DeleteChunk() during reindex error = %v, want ErrReindexRunning", err)
+	}
+	release()
+	if len(store.docs) != 1 {
+		t.Errorf("store holds %d chunks, want the rejected writes not applied", len(store.docs))
+	}
+	if _, err := client.CreateChunkFromText("new chunk", "t"); err != nil {
+		t.Errorf("CreateChunkFromText() after reindex error = %v", err)
+	}
+}
+
+func TestRAGClient_StartReindexCollection(t *testing.T) {
+	client, store := newCollectionReindexTestClient(t, 4, 64, &memoryVectorStore{})
+	if status := client.ReindexCollectionStatus(); status.State != REINDEX_STATE_IDLE {
+		t.Errorf("status before start = %+v, want idle", status)
+	}
+
+	status, err := client.StartReindexCollection(3)
+	if err != nil || status.State != REINDEX_STATE_RUNNING || status.StartedAt == nil {
+		t.Fatalf("StartReindexCollection() = %+v, %v, want running", status, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for status.State == REINDEX_STATE_RUNNING && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		status = client.ReindexCollectionStatus()
+	}
+	if status.State != REINDEX_STATE_COMPLETED || status.Processed != 4 || status.Total != 4 || status.FinishedAt == nil {
+		t.Fatalf("status = %+v, want 4 of 4 chunks completed", status)
+	}
+	assertReembedded(t, store, 4)
+	if client.CancelReindexCollection() {
+		t.Error("CancelReindexCollection() = true without a running reindex")
+	}
+
+	// A cancelled context stops the reindex before the next batch
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if count, err := client.ReindexCollection(ctx, 2, nil); !errors.Is(err, context.Canceled) || count != 0 {
+		t.Errorf("ReindexCollection() with cancelled context = %d, %v, want context.Canceled", count, err)
+	}
+}
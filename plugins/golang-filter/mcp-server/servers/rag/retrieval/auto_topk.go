@@ -0,0 +1,43 @@
+package retrieval
+
+import (
+	"math"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// DEFAULT_AUTO_TOP_K_GAP is the relative score drop that marks an elbow when the profile
+// sets no gap_threshold
+const DEFAULT_AUTO_TOP_K_GAP = 0.2
+
+// autoTopK returns the number of results to keep from results, ordered best first: the
+// rank within [MinK, MaxK] followed by the largest score drop relative to its own score.
+// It returns false when no drop reaches the gap threshold, i.e. the scores have no elbow.
+func autoTopK(results []schema.SearchResult, auto config.AutoTopKConfig, topK int) (int, bool) {
+	minK := max(auto.MinK, 1)
+	maxK := auto.MaxK
+	if maxK <= 0 {
+		maxK = topK
+	}
+	threshold := auto.GapThreshold
+	if threshold <= 0 {
+		threshold = DEFAULT_AUTO_TOP_K_GAP
+	}
+
+	best, bestGap := 0, 0.0
+	// Keeping k results cuts between results[k-1] and results[k]
+	for k := minK; k <= min(maxK, len(results)-1); k++ {
+		prev := results[k-1].Score
+		if prev == 0 {
+			continue
+		}
+		if gap := (prev - results[k].Score) / math.Abs(prev); gap > bestGap {
+			best, bestGap = k, gap
+		}
+	}
+	if best == 0 || bestGap < threshold {
+		return 0, false
+	}
+	return best, true
+}
@@ -0,0 +1,155 @@
+package retrieval
+
+import (
+	"context"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/fusion"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retriever"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// Stages of the min_results backfill recorded in metrics
+const (
+	BACKFILL_STAGE_THRESHOLD  = "threshold"
+	BACKFILL_STAGE_RETRIEVERS = "retrievers"
+)
+
+// backfill tops fused up to profile.MinResults. It first lowers the threshold step by step
+// over the results already retrieved, which also recovers results cut by auto TopK, and
+// then searches the backfill retrievers the profile does not use yet. Their results are
+// not filtered by the threshold. Results that passed the original cut stay first.
+func (p *defaultProvider) backfill(
+	ctx context.Context,
+	inputs []fusion.RetrieverResult,
+	raw []schema.SearchResult,
+	queries []string,
+	profile config.RetrievalProfile,
+	active []retriever.Retriever,
+	fused []schema.SearchResult,
+	m *metrics.RetrievalMetrics,
+) []schema.SearchResult {
+	floor := profile.MinResults
+	if floor <= 0 || len(fused) >= floor {
+		return fused
+	}
+	before := len(fused)
+	seen := make(map[string]bool, floor)
+	for _, doc := range fused {
+		seen[doc.Document.ID] = true
+	}
+	stage := ""
+
+	// Relax the threshold over the results already retrieved
+	candidates := p.relaxedFuse(ctx, inputs, raw, queries, profile, m)
+	threshold := profile.Threshold
+	for step := 0; ; step++ {
+		n := len(fused)
+		fused = appendUnseen(fused, candidates, seen, threshold, floor)
+		if len(fused) > n {
+			stage = BACKFILL_STAGE_THRESHOLD
+		}
+		if len(fused) >= floor || threshold <= 0 || (profile.Backfill.MaxSteps > 0 && step >= profile.Backfill.MaxSteps) {
+			break
+		}
+		threshold = max(threshold-profile.Backfill.ThresholdStep, 0)
+		if profile.Backfill.ThresholdStep <= 0 {
+			threshold = 0
+		}
+	}
+
+	// Search the backfill retrievers
+	if extra := p.backfillRetrievers(profile, active); len(fused) < floor && len(extra) > 0 {
+		if m != nil {
+			m.AddRetrievalPhase("backfill")
+		}
+		// HyDE seeds were already searched, and the extra fan-out should stay small
+		plain := profile
+		plain.HYDE.Enable = false
+		scratch := &metrics.RetrievalMetrics{}
+		extraInputs, extraRaw, _ := p.parallelRetrieve(ctx, queries, extra, plain, scratch)
+		if m != nil {
+			for _, stats := range scratch.RetrieverMetrics {
+				m.AddRetrieverStats(stats)
+			}
+		}
+		if len(extraRaw) > 0 {
+			allInputs := append(append([]fusion.RetrieverResult(nil), inputs...), extraInputs...)
+			allRaw := append(append([]schema.SearchResult(nil), raw...), extraRaw...)
+			candidates = p.relaxedFuse(ctx, allInputs, allRaw, queries, profile, m)
+			n := len(fused)
+			fused = appendUnseen(fused, candidates, seen, 0, floor)
+			if len(fused) > n {
+				stage = BACKFILL_STAGE_RETRIEVERS
+			}
+		}
+	}
+
+	if stage != "" {
+		api.LogInfof("retrieval: backfilled %d results to reach min_results=%d (stage %s)", len(fused)-before, floor, stage)
+		if m != nil {
+			m.RecordBackfill(stage, len(fused)-before)
+		}
+	}
+	return fused
+}
+
+// relaxedFuse fuses without threshold and TopK cut, recording the fusion into scratch
+// metrics and no trace so the metrics and traced inputs of the original fusion are kept
+func (p *defaultProvider) relaxedFuse(
+	ctx context.Context,
+	inputs []fusion.RetrieverResult,
+	raw []schema.SearchResult,
+	queries []string,
+	profile config.RetrievalProfile,
+	m *metrics.RetrievalMetrics,
+) []schema.SearchResult {
+	relaxed := profile
+	relaxed.Threshold = 0
+	relaxed.AutoTopK.Enable = false
+	relaxed.TopK = len(raw)
+	scratch := &metrics.RetrievalMetrics{}
+	if m != nil {
+		scratch.Query = m.Query
+	}
+	return p.fuse(WithFusionTrace(ctx, nil), inputs, raw, queries, relaxed, scratch)
+}
+
+// backfillRetrievers resolves profile.Backfill.Retrievers, skipping the active ones
+func (p *defaultProvider) backfillRetrievers(profile config.RetrievalProfile, active []retriever.Retriever) []retriever.Retriever {
+	used := make(map[string]bool, len(active))
+	for _, r := range active {
+		used[r.Type()] = true
+	}
+	extra := make([]retriever.Retriever, 0, len(profile.Backfill.Retrievers))
+	for _, key := range profile.Backfill.Retrievers {
+		r := p.findRetriever(key)
+		if r == nil {
+			api.LogWarnf("retrieval: backfill retriever %q not found", key)
+			continue
+		}
+		if !used[r.Type()] {
+			used[r.Type()] = true
+			extra = append(extra, r)
+		}
+	}
+	return extra
+}
+
+// appendUnseen appends the candidates scoring at least threshold that are not in seen
+// until results holds limit results
+func appendUnseen(results, candidates []schema.SearchResult, seen map[string]bool, threshold float64, limit int) []schema.SearchResult {
+	for _, doc := range candidates {
+		if len(results) >= limit {
+			break
+		}
+		if seen[doc.Document.ID] || doc.Score < threshold {
+			continue
+		}
+		seen[doc.Document.ID] = true
+		results = append(results, doc)
+	}
+	return results
+}
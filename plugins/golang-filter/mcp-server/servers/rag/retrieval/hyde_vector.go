@@ -0,0 +1,110 @@
+package retrieval
+
+import (
+	"context"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/fusion"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retriever"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// HYDE_RETRIEVER is the retriever key of the HyDE vector searches in fusion, so fusion
+// weights can be configured for it like for any retriever
+const HYDE_RETRIEVER = "hyde"
+
+// searchHyDEVectors searches the vectors of the hypothetical documents carried by ctx with
+// the first active retriever that searches by vector, and returns them as one fusion input
+// next to the query results. Without HyDE vectors or an active vector retriever it returns
+// nothing.
+func (p *defaultProvider) searchHyDEVectors(
+	ctx context.Context,
+	active []retriever.Retriever,
+	profile config.RetrievalProfile,
+	m *metrics.RetrievalMetrics,
+) ([]fusion.RetrieverResult, []schema.SearchResult) {
+	vectors := retriever.HyDEVectorsFromContext(ctx)
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+	var searcher retriever.VectorSearcher
+	for _, r := range active {
+		if s, ok := r.(retriever.VectorSearcher); ok {
+			searcher = s
+			break
+		}
+	}
+	if searcher == nil {
+		return nil, nil
+	}
+
+	topK := profile.PerRetrieverTopK
+	if budget, ok := p.variantTopK(profile, searcher); ok && budget > 0 {
+		topK = budget
+	}
+	if topK <= 0 {
+		topK = profile.TopK
+	}
+	if m != nil {
+		m.AddRetrievalPhase("hyde_vector")
+	}
+
+	var results []schema.SearchResult
+	searches := 0
+	start := time.Now()
+	for _, vector := range vectors {
+		docs, err := searcher.SearchVector(ctx, vector, topK)
+		if err != nil {
+			api.LogWarnf("retrieval: hyde vector search failed: %v", err)
+			continue
+		}
+		searches++
+		for i := range docs {
+			if docs[i].Document.Metadata == nil {
+				docs[i].Document.Metadata = make(map[string]interface{})
+			}
+			docs[i].Document.Metadata["retriever_type"] = HYDE_RETRIEVER
+		}
+		results = append(results, docs...)
+	}
+	if m != nil {
+		m.HyDEVectorSearches = searches
+		if searches > 0 {
+			stats := buildRetrieverStats(searcher, results, time.Since(start).Milliseconds())
+			stats.Type = HYDE_RETRIEVER
+			m.AddRetrieverStats(stats)
+			m.TotalRetrieved += len(results)
+		}
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	input := fusion.RetrieverResult{
+		Query:      HYDE_RETRIEVER,
+		Retriever:  HYDE_RETRIEVER,
+		Results:    results,
+		Attributes: map[string]any{"vectors": searches},
+	}
+	return []fusion.RetrieverResult{input}, results
+}
+
+// recordHyDEContribution records how many of the final results the HyDE searches retrieved
+func recordHyDEContribution(hyde, fused []schema.SearchResult, m *metrics.RetrievalMetrics) {
+	if m == nil || len(hyde) == 0 {
+		return
+	}
+	found := make(map[string]bool, len(hyde))
+	for _, doc := range hyde {
+		found[doc.Document.ID] = true
+	}
+	contributed := 0
+	for _, doc := range fused {
+		if found[doc.Document.ID] {
+			contributed++
+		}
+	}
+	m.HyDEContributed = contributed
+}
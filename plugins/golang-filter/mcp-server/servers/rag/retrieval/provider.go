@@ -2,7 +2,8 @@ package retrieval
 
 import (
 	"context"
-	"sort"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -10,15 +11,22 @@ import (
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/fusion"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/post"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retriever"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
 	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
 )
 
+// ErrRetrievalDegraded is returned by Retrieve when fewer retrievers succeeded than the
+// profile's MinSuccessfulRetrievers, so an outage is not mistaken for "no matches"
+var ErrRetrievalDegraded = errors.New("retrieval degraded")
+
 // Provider handles retrieval orchestration
 type Provider interface {
-	Retrieve(ctx context.Context, queries []string, profile config.RetrievalProfile, m *metrics.RetrievalMetrics) []schema.SearchResult
+	Retrieve(ctx context.Context, queries []string, profile config.RetrievalProfile, m *metrics.RetrievalMetrics) ([]schema.SearchResult, error)
 	SetFusionStrategy(strategy fusion.Strategy, params map[string]any)
+	SetReranker(reranker post.Reranker)
+	SetNearDuplicates(dedup *fusion.NearDuplicates)
 }
 
 // defaultProvider is the default implementation
@@ -29,6 +37,8 @@ type defaultProvider struct {
 	fusionStrategy fusion.Strategy
 	fusionParams   map[string]any
 	hyde           *HYDEClient
+	reranker       post.Reranker
+	nearDuplicates *fusion.NearDuplicates
 }
 
 // NewProvider creates a new retrieval provider
@@ -55,18 +65,30 @@ func (p *defaultProvider) SetFusionStrategy(strategy fusion.Strategy, params map
 	}
 }
 
-// Retrieve performs hybrid retrieval across multiple retrievers
-func (p *defaultProvider) Retrieve(ctx context.Context, queries []string, profile config.RetrievalProfile, m *metrics.RetrievalMetrics) []schema.SearchResult {
+// SetReranker sets the reranker used by cascade stage2 mode "rerank"
+func (p *defaultProvider) SetReranker(reranker post.Reranker) {
+	p.reranker = reranker
+}
+
+// SetNearDuplicates sets the step collapsing near-duplicate results before fusion; nil
+// disables it
+func (p *defaultProvider) SetNearDuplicates(dedup *fusion.NearDuplicates) {
+	p.nearDuplicates = dedup
+}
+
+// Retrieve performs hybrid retrieval across multiple retrievers. It fails with
+// ErrRetrievalDegraded when fewer retrievers succeed than the profile requires.
+func (p *defaultProvider) Retrieve(ctx context.Context, queries []string, profile config.RetrievalProfile, m *metrics.RetrievalMetrics) ([]schema.SearchResult, error) {
 	if len(p.retrievers) == 0 {
 		api.LogWarn("retrieval: no retrievers available")
-		return []schema.SearchResult{}
+		return []schema.SearchResult{}, nil
 	}
 
 	// Select active retrievers based on profile
 	activeRetrievers := p.selectRetrievers(profile)
 	if len(activeRetrievers) == 0 {
 		api.LogWarn("retrieval: no active retrievers for profile")
-		return []schema.SearchResult{}
+		return []schema.SearchResult{}, nil
 	}
 
 	// Record retriever types
@@ -88,14 +110,54 @@ func (p *defaultProvider) Retrieve(ctx context.Context, queries []string, profil
 		inputs, results, ok = p.runCascade(ctx, queries, profile, m)
 	}
 	if !ok {
-		inputs, results = p.parallelRetrieve(ctx, queries, activeRetrievers, profile, m)
+		var succeeded int
+		inputs, results, succeeded = p.parallelRetrieve(ctx, queries, activeRetrievers, profile, m)
+		if err := checkMinSuccessful(profile, succeeded, len(activeRetrievers)); err != nil {
+			api.LogWarnf("retrieval: %v", err)
+			if m != nil {
+				m.RetrievalDegraded = true
+			}
+			return nil, err
+		}
+	}
+
+	// HyDE vectors are fused as an input of their own next to the query results
+	hydeInputs, hydeResults := p.searchHyDEVectors(ctx, activeRetrievers, profile, m)
+	inputs = append(inputs, hydeInputs...)
+	results = append(results, hydeResults...)
+
+	// Per-retriever thresholds filter before fusion, the profile threshold after it
+	inputs, results = filterByRetrieverThresholds(inputs, results, profile.RetrieverThresholds, m)
+	hydeResults = filterByThreshold(hydeResults, profile.RetrieverThresholds)
+	trace := fusionTraceFrom(ctx)
+	if trace != nil {
+		trace.Retrieved = inputs
 	}
 
 	// Fusion
 	fused := p.fuse(ctx, inputs, results, queries, profile, m)
+	fused = p.backfill(ctx, inputs, results, queries, profile, activeRetrievers, fused, m)
+	recordHyDEContribution(hydeResults, fused, m)
+	if trace != nil {
+		trace.Output = fused
+	}
 
 	api.LogInfof("retrieval: total_results=%d fused=%d", len(results), len(fused))
-	return fused
+	return fused, nil
+}
+
+// checkMinSuccessful enforces profile.MinSuccessfulRetrievers, capped at the number of
+// active retrievers so a profile naming fewer retrievers than the minimum still works
+func checkMinSuccessful(profile config.RetrievalProfile, succeeded, active int) error {
+	required := profile.MinSuccessfulRetrievers
+	if required > active {
+		required = active
+	}
+	if required <= 0 || succeeded >= required {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d retrievers succeeded, profile %q requires %d",
+		ErrRetrievalDegraded, succeeded, active, profile.Name, required)
 }
 
 // selectRetrievers selects active retrievers based on profile
@@ -176,19 +238,7 @@ func (p *defaultProvider) runCascade(
 
 	seedQueries := make([]string, 0, 1)
 	seedQueries = append(seedQueries, queries[0])
-	if seeds := p.generateHYDESeeds(ctx, profile, queries[0]); len(seeds) > 0 {
-		if m != nil {
-			m.AddRetrievalPhase("hyde")
-		}
-		maxSeeds := profile.HYDE.MaxSeeds
-		if maxSeeds <= 0 {
-			maxSeeds = len(seeds)
-		}
-		if maxSeeds < len(seeds) {
-			seeds = seeds[:maxSeeds]
-		}
-		seedQueries = append(seedQueries, seeds...)
-	}
+	seedQueries = append(seedQueries, p.hydeSeeds(ctx, profile, queries[0], m)...)
 
 	if m != nil {
 		m.AddRetrievalPhase("cascade_stage1")
@@ -199,6 +249,9 @@ func (p *defaultProvider) runCascade(
 		docs, latency, err := p.executeSearch(ctx, stage1, q, stage1TopK)
 		if err != nil {
 			api.LogWarnf("retrieval: cascade stage1 %s query %q failed: %v", stage1.Type(), q, err)
+			if m != nil && errors.Is(err, retriever.ErrEmbeddingFailed) {
+				m.RecordEmbeddingError(err)
+			}
 			continue
 		}
 		if m != nil {
@@ -240,6 +293,13 @@ func (p *defaultProvider) runCascade(
 	}
 
 	stage2Cfg := profile.Cascade.Stage2
+	if strings.EqualFold(strings.TrimSpace(stage2Cfg.Mode), "rerank") {
+		var remaining time.Duration
+		if budgetDuration > 0 {
+			remaining = budgetDuration - elapsed
+		}
+		return p.rerankCascade(ctx, queries[0], stage1, stage1Results, stage2Cfg.TopK, remaining, m)
+	}
 	stage2 := p.findRetriever(stage2Cfg.Retriever)
 	stage2Results := []schema.SearchResult{}
 	if stage2 != nil {
@@ -263,6 +323,9 @@ func (p *defaultProvider) runCascade(
 		docs, latency, err := p.executeSearch(ctx, stage2, queries[0], stage2TopK)
 		if err != nil {
 			api.LogWarnf("retrieval: cascade stage2 %s failed: %v", stage2.Type(), err)
+			if m != nil && errors.Is(err, retriever.ErrEmbeddingFailed) {
+				m.RecordEmbeddingError(err)
+			}
 		} else {
 			if m != nil {
 				m.AddRetrieverStats(buildRetrieverStats(stage2, docs, latency))
@@ -298,22 +361,104 @@ func (p *defaultProvider) runCascade(
 	return inputs, all, true
 }
 
-// parallelRetrieve performs parallel retrieval across all queries and retrievers
+// rerankCascade runs cascade stage2 mode "rerank": instead of a second retriever, the
+// reranker reorders the stage1 candidates within the remaining latency budget (0 => none),
+// and the reranked list is fused as an input of its own next to stage1. Without a reranker,
+// or when reranking fails, only stage1 is fused.
+func (p *defaultProvider) rerankCascade(
+	ctx context.Context,
+	query string,
+	stage1 retriever.Retriever,
+	stage1Results []schema.SearchResult,
+	topN int,
+	remaining time.Duration,
+	m *metrics.RetrievalMetrics,
+) ([]fusion.RetrieverResult, []schema.SearchResult, bool) {
+	inputs := []fusion.RetrieverResult{
+		{
+			Query:      query,
+			Retriever:  stage1.Type(),
+			Results:    stage1Results,
+			Attributes: map[string]any{"cascade_stage": "stage1"},
+		},
+	}
+	if p.reranker == nil {
+		api.LogWarn("retrieval: cascade stage2 mode rerank has no reranker, fusing stage1 only")
+		return inputs, stage1Results, true
+	}
+	if m != nil {
+		m.AddRetrievalPhase("cascade_stage2")
+	}
+	if remaining > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, remaining)
+		defer cancel()
+	}
+	if topN <= 0 || topN > len(stage1Results) {
+		topN = len(stage1Results)
+	}
+
+	// Copy the metadata so that marking the candidates does not relabel the stage1 input
+	candidates := make([]schema.SearchResult, len(stage1Results))
+	for i, doc := range stage1Results {
+		metadata := make(map[string]any, len(doc.Document.Metadata)+1)
+		for k, v := range doc.Document.Metadata {
+			metadata[k] = v
+		}
+		metadata["cascade_stage"] = "stage2"
+		doc.Document.Metadata = metadata
+		candidates[i] = doc
+	}
+	reranked, err := p.reranker.Rerank(ctx, query, candidates, topN)
+	if err != nil {
+		api.LogWarnf("retrieval: cascade stage2 rerank failed, fusing stage1 only: %v", err)
+		return inputs, stage1Results, true
+	}
+	if len(reranked) == 0 {
+		return inputs, stage1Results, true
+	}
+
+	inputs = append(inputs, fusion.RetrieverResult{
+		Query:      query,
+		Retriever:  "rerank",
+		Results:    reranked,
+		Attributes: map[string]any{"cascade_stage": "stage2", "mode": "rerank"},
+	})
+	all := make([]schema.SearchResult, 0, len(stage1Results)+len(reranked))
+	all = append(all, stage1Results...)
+	all = append(all, reranked...)
+	return inputs, all, true
+}
+
+// parallelRetrieve performs parallel retrieval across all queries and retrievers. It also
+// returns how many retrievers answered at least one query without error.
 func (p *defaultProvider) parallelRetrieve(
 	ctx context.Context,
 	queries []string,
 	retrievers []retriever.Retriever,
 	profile config.RetrievalProfile,
 	m *metrics.RetrievalMetrics,
-) ([]fusion.RetrieverResult, []schema.SearchResult) {
+) ([]fusion.RetrieverResult, []schema.SearchResult, int) {
 	var (
-		mu      sync.Mutex
-		wg      sync.WaitGroup
-		allDocs []schema.SearchResult
-		grouped = make(map[string]fusion.RetrieverResult)
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		allDocs   []schema.SearchResult
+		grouped   = make(map[string]fusion.RetrieverResult)
+		succeeded = make(map[int]struct{})
 	)
 
-	// Control fan-out if MaxFanout is set
+	// HyDE seeds go right after the primary query so the fan-out cap keeps them
+	if len(queries) > 0 {
+		if seeds := p.hydeSeeds(ctx, profile, queries[0], m); len(seeds) > 0 {
+			expanded := make([]string, 0, len(queries)+len(seeds))
+			expanded = append(expanded, queries[0])
+			expanded = append(expanded, seeds...)
+			expanded = append(expanded, queries[1:]...)
+			queries = expanded
+		}
+	}
+
+	// MaxFanout is a hard cap: queries beyond it are dropped
 	fanout := len(queries) * len(retrievers)
 	if profile.MaxFanout > 0 && fanout > profile.MaxFanout {
 		// Limit concurrent operations (simple approach: limit queries)
@@ -333,11 +478,24 @@ func (p *defaultProvider) parallelRetrieve(
 		perRetrieverK = profile.TopK
 	}
 
+	// MaxConcurrency throttles instead: every search runs, at most N at a time
+	var slots chan struct{}
+	if profile.MaxConcurrency > 0 {
+		slots = make(chan struct{}, profile.MaxConcurrency)
+	}
+	inFlight, maxInFlight := 0, 0
+
 	for _, q := range queries {
-		for _, ret := range retrievers {
+		for idx, ret := range retrievers {
+			if slots != nil {
+				slots <- struct{}{}
+			}
 			wg.Add(1)
-			go func(query string, r retriever.Retriever) {
+			go func(query string, idx int, r retriever.Retriever) {
 				defer wg.Done()
+				if slots != nil {
+					defer func() { <-slots }()
+				}
 
 				topK := perRetrieverK
 				if budget, ok := p.variantTopK(profile, r); ok && budget > 0 {
@@ -350,12 +508,24 @@ func (p *defaultProvider) parallelRetrieve(
 					}
 				}
 
+				mu.Lock()
+				inFlight++
+				maxInFlight = max(maxInFlight, inFlight)
+				mu.Unlock()
 				start := time.Now()
 				docs, err := r.Search(ctx, query, topK)
 				latency := time.Since(start).Milliseconds()
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
 
 				if err != nil {
 					api.LogWarnf("retrieval: %s search failed for query %q: %v", r.Type(), query, err)
+					if m != nil && errors.Is(err, retriever.ErrEmbeddingFailed) {
+						mu.Lock()
+						m.RecordEmbeddingError(err)
+						mu.Unlock()
+					}
 					return
 				}
 
@@ -391,6 +561,7 @@ func (p *defaultProvider) parallelRetrieve(
 				}
 
 				mu.Lock()
+				succeeded[idx] = struct{}{}
 				allDocs = append(allDocs, docs...)
 				key := r.Type()
 				entry := grouped[key]
@@ -405,7 +576,7 @@ func (p *defaultProvider) parallelRetrieve(
 
 				api.LogInfof("retrieval: %s returned %d docs in %dms for query %q",
 					r.Type(), len(docs), latency, query)
-			}(q, ret)
+			}(q, idx, ret)
 		}
 	}
 
@@ -413,6 +584,7 @@ func (p *defaultProvider) parallelRetrieve(
 
 	if m != nil {
 		m.TotalRetrieved = len(allDocs)
+		m.MaxInFlightSearches = maxInFlight
 	}
 
 	inputs := make([]fusion.RetrieverResult, 0, len(grouped))
@@ -420,7 +592,7 @@ func (p *defaultProvider) parallelRetrieve(
 		inputs = append(inputs, item)
 	}
 
-	return inputs, allDocs
+	return inputs, allDocs, len(succeeded)
 }
 
 // fuse merges results using configured fusion strategy
@@ -444,16 +616,38 @@ func (p *defaultProvider) fuse(
 	}
 	params["profile_top_k"] = profile.TopK
 	if len(queries) > 0 {
+		// Key traffic rollout on the user's query rather than a pre-retrieve rewrite
 		params["query"] = queries[0]
+		if m != nil && m.Query != "" {
+			params["query"] = m.Query
+		}
 		if _, exists := params["query_id"]; !exists {
 			params["query_id"] = queries[0]
 		}
 	}
 
+	// Collapse identical content returned under different IDs (e.g. vector and web)
+	inputs = fusion.DedupByContent(inputs)
+	collapsed := 0
+	if p.nearDuplicates != nil {
+		deduped, n, err := p.nearDuplicates.Collapse(ctx, inputs)
+		if err != nil {
+			api.LogWarnf("retrieval: near-duplicate dedup failed (%v), fusing without it", err)
+		} else {
+			inputs, collapsed = deduped, n
+		}
+	}
+	if trace := fusionTraceFrom(ctx); trace != nil {
+		trace.Inputs = inputs
+	}
+
 	strategy := p.fusionStrategy
 	if strategy == nil {
 		strategy = fusion.NewRRFStrategy(p.rrfK)
 	}
+	if rollout, ok := strategy.(fusion.RolloutStrategy); ok && m != nil {
+		m.FusionArm = rollout.Arm(params)
+	}
 
 	fused, err := strategy.Fuse(ctx, inputs, params)
 	if err != nil {
@@ -474,9 +668,19 @@ func (p *defaultProvider) fuse(
 		fused = filtered
 	}
 
-	// Apply TopK
-	if len(fused) > profile.TopK {
-		fused = fused[:profile.TopK]
+	// Apply TopK, or cut at the elbow of the scores in auto mode
+	topK := profile.TopK
+	if profile.AutoTopK.Enable {
+		k, elbow := autoTopK(fused, profile.AutoTopK, profile.TopK)
+		if elbow {
+			topK = k
+		}
+		if m != nil {
+			m.RecordAutoTopK(topK, elbow)
+		}
+	}
+	if len(fused) > topK {
+		fused = fused[:topK]
 	}
 
 	if m != nil {
@@ -487,7 +691,8 @@ func (p *defaultProvider) fuse(
 				weightsVersion = version
 			}
 		}
-		m.RecordFusion(strategy.Name(), len(fused), 0, latencyMs, weightsVersion)
+		m.RecordFusion(strategy.Name(), len(fused), collapsed, latencyMs, weightsVersion)
+		m.RecordFusionParams(p.fusionParams)
 	}
 
 	return fused
@@ -534,9 +739,7 @@ func mapToSortedSlice(m map[string]schema.SearchResult) []schema.SearchResult {
 	for _, doc := range m {
 		out = append(out, doc)
 	}
-	sort.Slice(out, func(i, j int) bool {
-		return out[i].Score > out[j].Score
-	})
+	fusion.SortByScore(out)
 	return out
 }
 
@@ -590,6 +793,22 @@ func (p *defaultProvider) generateHYDESeeds(ctx context.Context, profile config.
 	return seeds
 }
 
+// hydeSeeds returns at most profile.HYDE.MaxSeeds seed queries for query and records
+// the hyde phase when any are produced.
+func (p *defaultProvider) hydeSeeds(ctx context.Context, profile config.RetrievalProfile, query string, m *metrics.RetrievalMetrics) []string {
+	seeds := p.generateHYDESeeds(ctx, profile, query)
+	if len(seeds) == 0 {
+		return nil
+	}
+	if maxSeeds := profile.HYDE.MaxSeeds; maxSeeds > 0 && len(seeds) > maxSeeds {
+		seeds = seeds[:maxSeeds]
+	}
+	if m != nil {
+		m.AddRetrievalPhase("hyde")
+	}
+	return seeds
+}
+
 func (p *defaultProvider) variantTopK(profile config.RetrievalProfile, r retriever.Retriever) (int, bool) {
 	if len(profile.VariantBudgets) == 0 {
 		return 0, false
@@ -0,0 +1,572 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/fusion"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retriever"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// mockCommonCAPI swallows envoy logs so provider code can run outside envoy
+type mockCommonCAPI struct{}
+
+func (m *mockCommonCAPI) Log(level api.LogType, message string) {}
+
+func (m *mockCommonCAPI) LogLevel() api.LogType { return api.Error }
+
+func TestMain(m *testing.M) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	m.Run()
+}
+
+// recordingRetriever returns one document per query and remembers every query it saw.
+type recordingRetriever struct {
+	typ     string
+	mu      sync.Mutex
+	queries []string
+}
+
+func (r *recordingRetriever) Type() string { return r.typ }
+
+func (r *recordingRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+	r.mu.Lock()
+	r.queries = append(r.queries, query)
+	r.mu.Unlock()
+	return []schema.SearchResult{{Document: schema.Document{ID: r.typ + ":" + query, Content: query}, Score: 1}}, nil
+}
+
+func (r *recordingRetriever) seen() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := append([]string(nil), r.queries...)
+	sort.Strings(out)
+	return out
+}
+
+func newHYDEServer(t *testing.T, seeds ...string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"seeds": seeds})
+	}))
+}
+
+func hasPhase(m *metrics.RetrievalMetrics, phase string) bool {
+	for _, p := range m.RetrievalPhases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParallelRetrieve_IssuesHYDESeeds(t *testing.T) {
+	server := newHYDEServer(t, "seed one", "seed two", "seed three")
+	defer server.Close()
+
+	vector := &recordingRetriever{typ: "vector"}
+	provider := NewProvider([]retriever.Retriever{vector}, map[string]retriever.Retriever{"vector": vector}, 60)
+	profile := config.RetrievalProfile{
+		TopK: 5,
+		HYDE: config.HYDEConfig{Enable: true, Provider: "http", Endpoint: server.URL, MaxSeeds: 2, TimeoutMs: 1000},
+	}
+	m := metrics.NewRetrievalMetrics()
+
+	results, err := provider.Retrieve(context.Background(), []string{"what is higress", "higress gateway"}, profile, m)
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+
+	want := []string{"higress gateway", "seed one", "seed two", "what is higress"}
+	got := vector.seen()
+	if len(got) != len(want) {
+		t.Fatalf("queries = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("queries = %v, want %v", got, want)
+		}
+	}
+	if !hasPhase(m, "hyde") {
+		t.Errorf("phases = %v, want hyde recorded", m.RetrievalPhases)
+	}
+	if len(results) == 0 {
+		t.Error("Retrieve() returned no fused results")
+	}
+}
+
+func TestParallelRetrieve_HYDESeedsSurviveFanoutCap(t *testing.T) {
+	server := newHYDEServer(t, "seed one")
+	defer server.Close()
+
+	vector := &recordingRetriever{typ: "vector"}
+	provider := NewProvider([]retriever.Retriever{vector}, map[string]retriever.Retriever{"vector": vector}, 60)
+	profile := config.RetrievalProfile{
+		TopK:      5,
+		MaxFanout: 2,
+		HYDE:      config.HYDEConfig{Enable: true, Provider: "http", Endpoint: server.URL, TimeoutMs: 1000},
+	}
+
+	provider.Retrieve(context.Background(), []string{"primary", "variant a", "variant b"}, profile, nil)
+
+	got := vector.seen()
+	if len(got) != 2 || got[0] != "primary" || got[1] != "seed one" {
+		t.Errorf("queries = %v, want primary query and its seed kept under the fan-out cap", got)
+	}
+}
+
+func TestParallelRetrieve_HYDEDisabled(t *testing.T) {
+	server := newHYDEServer(t, "seed one")
+	defer server.Close()
+
+	vector := &recordingRetriever{typ: "vector"}
+	provider := NewProvider([]retriever.Retriever{vector}, map[string]retriever.Retriever{"vector": vector}, 60)
+	profile := config.RetrievalProfile{
+		TopK: 5,
+		HYDE: config.HYDEConfig{Enable: false, Provider: "http", Endpoint: server.URL},
+	}
+	m := metrics.NewRetrievalMetrics()
+
+	provider.Retrieve(context.Background(), []string{"primary"}, profile, m)
+
+	if got := vector.seen(); len(got) != 1 || got[0] != "primary" {
+		t.Errorf("queries = %v, want only the primary query", got)
+	}
+	if hasPhase(m, "hyde") {
+		t.Errorf("phases = %v, want no hyde phase", m.RetrievalPhases)
+	}
+}
+
+func TestRetrieve_RecordsFusionArm(t *testing.T) {
+	vector := &recordingRetriever{typ: "vector"}
+	provider := NewProvider([]retriever.Retriever{vector}, map[string]retriever.Retriever{"vector": vector}, 60)
+	strategy, params, err := fusion.NewStrategy("learned", map[string]any{"weights_uri": "file:///nonexistent.json", "traffic_percent": 50, "traffic_salt": "exp"})
+	if err != nil {
+		t.Fatalf("NewStrategy() error = %v", err)
+	}
+	provider.SetFusionStrategy(strategy, params)
+	profile := config.RetrievalProfile{TopK: 5}
+
+	for _, query := range []string{"what is higress", "how to write a wasm plugin"} {
+		m := metrics.NewRetrievalMetrics()
+		m.Query = query
+		provider.Retrieve(context.Background(), []string{"rewritten: " + query}, profile, m)
+		if want := fusion.AssignArm(query, "exp", 50); m.FusionArm != want {
+			t.Errorf("fusion arm for %q = %q, want %q keyed on the user query", query, m.FusionArm, want)
+		}
+	}
+}
+
+// failingRetriever fails every search, like a backend that is down
+type failingRetriever struct{ typ string }
+
+func (r *failingRetriever) Type() string { return r.typ }
+
+func (r *failingRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestRetrieve_MinSuccessfulRetrievers(t *testing.T) {
+	vector := &recordingRetriever{typ: "vector"}
+	bm25 := &failingRetriever{typ: "bm25"}
+	web := &failingRetriever{typ: "web"}
+	retrievers := []retriever.Retriever{vector, bm25, web}
+	provider := NewProvider(retrievers, map[string]retriever.Retriever{"vector": vector, "bm25": bm25, "web": web}, 60)
+
+	tests := []struct {
+		name     string
+		min      int
+		degraded bool
+	}{
+		{name: "lenient by default", min: 0},
+		{name: "one of three is enough", min: 1},
+		{name: "most failed", min: 2, degraded: true},
+		{name: "minimum capped at active retrievers", min: 5, degraded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := metrics.NewRetrievalMetrics()
+			profile := config.RetrievalProfile{Name: "default", TopK: 5, MinSuccessfulRetrievers: tt.min}
+			results, err := provider.Retrieve(context.Background(), []string{"higress"}, profile, m)
+			if got := errors.Is(err, ErrRetrievalDegraded); got != tt.degraded {
+				t.Fatalf("Retrieve() error = %v, want degraded %v", err, tt.degraded)
+			}
+			if m.RetrievalDegraded != tt.degraded {
+				t.Errorf("metrics retrieval_degraded = %v, want %v", m.RetrievalDegraded, tt.degraded)
+			}
+			if !tt.degraded && len(results) == 0 {
+				t.Error("Retrieve() returned no results from the healthy retriever")
+			}
+		})
+	}
+}
+
+func TestRetrieve_AllRetrieversFailed(t *testing.T) {
+	bm25 := &failingRetriever{typ: "bm25"}
+	provider := NewProvider([]retriever.Retriever{bm25}, map[string]retriever.Retriever{"bm25": bm25}, 60)
+
+	results, err := provider.Retrieve(context.Background(), []string{"higress"}, config.RetrievalProfile{TopK: 5}, nil)
+	if err != nil || len(results) != 0 {
+		t.Errorf("Retrieve() = %v, %v, want empty results without a threshold", results, err)
+	}
+	_, err = provider.Retrieve(context.Background(), []string{"higress"}, config.RetrievalProfile{TopK: 5, MinSuccessfulRetrievers: 1}, nil)
+	if !errors.Is(err, ErrRetrievalDegraded) {
+		t.Errorf("Retrieve() error = %v, want ErrRetrievalDegraded", err)
+	}
+}
+
+// slowRetriever holds every search briefly and records the most searches it saw at once.
+type slowRetriever struct {
+	recordingRetriever
+	inFlight, maxInFlight atomic.Int32
+}
+
+func (r *slowRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+	n := r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	for cur := r.maxInFlight.Load(); n > cur && !r.maxInFlight.CompareAndSwap(cur, n); cur = r.maxInFlight.Load() {
+	}
+	time.Sleep(10 * time.Millisecond)
+	return r.recordingRetriever.Search(ctx, query, topK)
+}
+
+func TestParallelRetrieve_MaxConcurrency(t *testing.T) {
+	vector := &slowRetriever{recordingRetriever: recordingRetriever{typ: "vector"}}
+	provider := NewProvider([]retriever.Retriever{vector}, map[string]retriever.Retriever{"vector": vector}, 60)
+	profile := config.RetrievalProfile{TopK: 5, MaxConcurrency: 2}
+	m := metrics.NewRetrievalMetrics()
+
+	queries := []string{"q1", "q2", "q3", "q4", "q5"}
+	if _, err := provider.Retrieve(context.Background(), queries, profile, m); err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if got := vector.seen(); len(got) != len(queries) {
+		t.Errorf("queries = %v, want every query searched", got)
+	}
+	if n := vector.maxInFlight.Load(); n > 2 {
+		t.Errorf("%d searches ran at once, want at most 2", n)
+	}
+	if m.MaxInFlightSearches < 1 || m.MaxInFlightSearches > 2 {
+		t.Errorf("max_in_flight_searches = %d, want 1..2", m.MaxInFlightSearches)
+	}
+}
+
+// listRetriever returns the same documents for every query
+type listRetriever struct {
+	typ     string
+	results []schema.SearchResult
+}
+
+func (r *listRetriever) Type() string { return r.typ }
+
+func (r *listRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+	out := make([]schema.SearchResult, len(r.results))
+	copy(out, r.results)
+	return out, nil
+}
+
+// reversingReranker reverses the candidates and records whether it ran under a deadline
+type reversingReranker struct {
+	err         error
+	hadDeadline bool
+}
+
+func (r *reversingReranker) Rerank(ctx context.Context, query string, in []schema.SearchResult, topN int) ([]schema.SearchResult, error) {
+	_, r.hadDeadline = ctx.Deadline()
+	if r.err != nil {
+		return nil, r.err
+	}
+	out := make([]schema.SearchResult, 0, len(in))
+	for i := len(in) - 1; i >= 0 && len(out) < topN; i-- {
+		out = append(out, in[i])
+	}
+	return out, nil
+}
+
+func TestRunCascade_RerankMode(t *testing.T) {
+	bm25 := &listRetriever{typ: "bm25", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "a"}, Score: 0.9},
+		{Document: schema.Document{ID: "b"}, Score: 0.8},
+		{Document: schema.Document{ID: "c"}, Score: 0.7},
+	}}
+	profile := config.RetrievalProfile{TopK: 5, Cascade: config.CascadeConfig{
+		Enable:          true,
+		LatencyBudgetMs: 1000,
+		Stage1:          config.CascadeStageConfig{Retriever: "bm25"},
+		Stage2:          config.CascadeStageConfig{Mode: "rerank", TopK: 2},
+	}}
+	newProvider := func(reranker *reversingReranker) *defaultProvider {
+		p := NewProvider([]retriever.Retriever{bm25}, map[string]retriever.Retriever{"bm25": bm25}, 60).(*defaultProvider)
+		if reranker != nil {
+			p.SetReranker(reranker)
+		}
+		return p
+	}
+
+	reranker := &reversingReranker{}
+	m := metrics.NewRetrievalMetrics()
+	inputs, _, ok := newProvider(reranker).runCascade(context.Background(), []string{"higress"}, profile, m)
+	if !ok || len(inputs) != 2 {
+		t.Fatalf("runCascade() = %d inputs, ok %v, want stage1 and rerank", len(inputs), ok)
+	}
+	stage2 := inputs[1]
+	if stage2.Retriever != "rerank" || len(stage2.Results) != 2 || stage2.Results[0].Document.ID != "c" || stage2.Results[1].Document.ID != "b" {
+		t.Errorf("rerank input = %+v, want [c b] from the reranker", stage2)
+	}
+	if stage2.Results[0].Document.Metadata["cascade_stage"] != "stage2" || inputs[0].Results[0].Document.Metadata["cascade_stage"] != "stage1" {
+		t.Error("reranked results must be marked stage2 without relabeling the stage1 input")
+	}
+	if !reranker.hadDeadline {
+		t.Error("reranker ran without the remaining cascade latency budget")
+	}
+	if !hasPhase(m, "cascade_stage2") {
+		t.Error("cascade_stage2 phase not recorded")
+	}
+
+	for name, p := range map[string]*defaultProvider{
+		"no reranker":     newProvider(nil),
+		"reranker failed": newProvider(&reversingReranker{err: errors.New("timeout")}),
+	} {
+		inputs, results, ok := p.runCascade(context.Background(), []string{"higress"}, profile, nil)
+		if !ok || len(inputs) != 1 || len(results) != 3 {
+			t.Errorf("%s: runCascade() = %d inputs, %d results, ok %v, want stage1 only", name, len(inputs), len(results), ok)
+		}
+	}
+}
+
+// fixedRetriever returns the same results for every query
+type fixedRetriever struct {
+	typ     string
+	results []schema.SearchResult
+}
+
+func (r *fixedRetriever) Type() string { return r.typ }
+
+func (r *fixedRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+	return append([]schema.SearchResult(nil), r.results...), nil
+}
+
+func TestRetrieve_CollapsesNearDuplicates(t *testing.T) {
+	vector := &fixedRetriever{typ: "vector", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "guide#1", Content: "Install Higress with Helm."}, Score: 0.9},
+		{Document: schema.Document{ID: "faq#7", Content: "install higress with helm"}, Score: 0.8},
+		{Document: schema.Document{ID: "plugins#2", Content: "Plugins are written in Wasm."}, Score: 0.7},
+	}}
+	bm25 := &fixedRetriever{typ: "bm25", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "faq#7", Content: "install higress with helm"}, Score: 5},
+	}}
+	provider := NewProvider([]retriever.Retriever{vector, bm25}, map[string]retriever.Retriever{"vector": vector, "bm25": bm25}, 60)
+	provider.SetNearDuplicates(fusion.NewNearDuplicates(&config.DedupConfig{Enable: true}, nil))
+
+	m := metrics.NewRetrievalMetrics()
+	results, err := provider.Retrieve(context.Background(), []string{"install"}, config.RetrievalProfile{TopK: 5}, m)
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Document.ID != "faq#7" || results[1].Document.ID != "plugins#2" {
+		t.Fatalf("Retrieve() = %+v, want the near-duplicates fused into faq#7", results)
+	}
+	if results[0].Document.Metadata[fusion.MetadataCollapsedCount] != 1 || m.DeduplicationCount != 1 {
+		t.Errorf("collapsed_count = %v, metrics deduplication_count = %d, want 1", results[0].Document.Metadata[fusion.MetadataCollapsedCount], m.DeduplicationCount)
+	}
+}
+
+func scoredResults(scores ...float64) []schema.SearchResult {
+	results := make([]schema.SearchResult, len(scores))
+	for i, score := range scores {
+		results[i] = schema.SearchResult{Document: schema.Document{ID: string(rune('a' + i))}, Score: score}
+	}
+	return results
+}
+
+func TestAutoTopK(t *testing.T) {
+	tests := []struct {
+		name      string
+		scores    []float64
+		auto      config.AutoTopKConfig
+		wantK     int
+		wantElbow bool
+	}{
+		{"elbow after two", []float64{0.9, 0.85, 0.4, 0.38, 0.35}, config.AutoTopKConfig{}, 2, true},
+		{"largest of two drops", []float64{0.9, 0.6, 0.55, 0.1}, config.AutoTopKConfig{}, 3, true},
+		{"flat scores", []float64{0.9, 0.88, 0.85, 0.83}, config.AutoTopKConfig{}, 0, false},
+		{"min_k skips an earlier elbow", []float64{0.9, 0.3, 0.29, 0.1}, config.AutoTopKConfig{MinK: 2}, 3, true},
+		{"elbow beyond max_k", []float64{0.9, 0.88, 0.86, 0.2}, config.AutoTopKConfig{MaxK: 2}, 0, false},
+		{"gap below threshold", []float64{0.9, 0.7, 0.68}, config.AutoTopKConfig{GapThreshold: 0.5}, 0, false},
+		{"single result", []float64{0.9}, config.AutoTopKConfig{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, elbow := autoTopK(scoredResults(tt.scores...), tt.auto, 4)
+			if k != tt.wantK || elbow != tt.wantElbow {
+				t.Errorf("autoTopK() = %d, %v, want %d, %v", k, elbow, tt.wantK, tt.wantElbow)
+			}
+		})
+	}
+}
+
+func TestRetrieve_AutoTopK(t *testing.T) {
+	vector := &fixedRetriever{typ: "vector", results: scoredResults(0.92, 0.9, 0.88, 0.3, 0.28, 0.25)}
+	provider := NewProvider([]retriever.Retriever{vector}, map[string]retriever.Retriever{"vector": vector}, 60)
+	provider.SetFusionStrategy(fusion.NewWeightedStrategy(nil), nil)
+
+	profile := config.RetrievalProfile{TopK: 5, AutoTopK: config.AutoTopKConfig{Enable: true}}
+	m := metrics.NewRetrievalMetrics()
+	results, err := provider.Retrieve(context.Background(), []string{"q"}, profile, m)
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if len(results) != 3 || m.AutoTopK != 3 || !m.AutoTopKElbow {
+		t.Errorf("Retrieve() kept %d results, metrics auto_top_k = %d (elbow %v), want 3 at the elbow", len(results), m.AutoTopK, m.AutoTopKElbow)
+	}
+
+	// Without an elbow the profile's TopK applies
+	vector.results = scoredResults(0.92, 0.9, 0.88, 0.86, 0.84, 0.82)
+	m = metrics.NewRetrievalMetrics()
+	results, _ = provider.Retrieve(context.Background(), []string{"q"}, profile, m)
+	if len(results) != 5 || m.AutoTopK != 5 || m.AutoTopKElbow {
+		t.Errorf("Retrieve() kept %d results, metrics auto_top_k = %d (elbow %v), want the profile's 5", len(results), m.AutoTopK, m.AutoTopKElbow)
+	}
+}
+
+func TestRetrieve_MinResultsBackfill(t *testing.T) {
+	vector := &fixedRetriever{typ: "vector", results: scoredResults(0.9, 0.6, 0.45, 0.2)}
+	web := &fixedRetriever{typ: "web", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "web#1"}, Score: 0.1},
+	}}
+	provider := NewProvider([]retriever.Retriever{vector, web}, map[string]retriever.Retriever{"vector": vector, "web": web}, 60)
+	provider.SetFusionStrategy(fusion.NewWeightedStrategy(nil), nil)
+
+	ids := func(results []schema.SearchResult) string {
+		parts := make([]string, len(results))
+		for i, r := range results {
+			parts[i] = r.Document.ID
+		}
+		return strings.Join(parts, ",")
+	}
+	profile := config.RetrievalProfile{TopK: 5, Threshold: 0.8, Retrievers: []string{"vector"}, MinResults: 3,
+		Backfill: config.BackfillConfig{ThresholdStep: 0.2, MaxSteps: 2, Retrievers: []string{"web"}}}
+
+	// Two steps lower the threshold to 0.4, which admits b and c
+	m := metrics.NewRetrievalMetrics()
+	results, err := provider.Retrieve(context.Background(), []string{"q"}, profile, m)
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if got := ids(results); got != "a,b,c" || m.MinResultsBackfill != "threshold" || m.BackfilledResults != 2 || m.FusionResultCount != 1 {
+		t.Errorf("Retrieve() = %s, backfill %q (+%d), fusion count %d, want a,b,c via threshold (+2) with fusion count 1",
+			got, m.MinResultsBackfill, m.BackfilledResults, m.FusionResultCount)
+	}
+
+	// One step only reaches 0.6, so the web retriever fills the rest
+	profile.MinResults, profile.Backfill.MaxSteps = 4, 1
+	m = metrics.NewRetrievalMetrics()
+	if results, err = provider.Retrieve(context.Background(), []string{"q"}, profile, m); err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if got := ids(results); len(results) != 4 || got[:4] != "a,b," || m.MinResultsBackfill != "retrievers" || m.RetrieverMetrics["web"].ResultCount != 1 {
+		t.Errorf("Retrieve() = %s, backfill %q, retriever metrics %v, want a,b first and the web retriever searched",
+			got, m.MinResultsBackfill, m.RetrieverMetrics)
+	}
+
+	// Without a floor nothing is backfilled
+	profile.MinResults = 0
+	m = metrics.NewRetrievalMetrics()
+	if results, _ = provider.Retrieve(context.Background(), []string{"q"}, profile, m); len(results) != 1 || m.MinResultsBackfill != "" {
+		t.Errorf("Retrieve() = %s, backfill %q, want only a", ids(results), m.MinResultsBackfill)
+	}
+}
+
+// vectorSearcher returns its vector results for any HyDE vector
+type vectorSearcher struct {
+	fixedRetriever
+	vectorResults []schema.SearchResult
+	vectors       int
+}
+
+func (r *vectorSearcher) SearchVector(ctx context.Context, vector []float32, topK int) ([]schema.SearchResult, error) {
+	r.vectors++
+	return append([]schema.SearchResult(nil), r.vectorResults...), nil
+}
+
+func TestRetrieve_HyDEVectors(t *testing.T) {
+	vector := &vectorSearcher{
+		fixedRetriever: fixedRetriever{typ: "vector", results: []schema.SearchResult{
+			{Document: schema.Document{ID: "guide#1"}, Score: 0.9},
+		}},
+		vectorResults: []schema.SearchResult{
+			{Document: schema.Document{ID: "guide#1"}, Score: 0.8},
+			{Document: schema.Document{ID: "hyde#2"}, Score: 0.7},
+		},
+	}
+	provider := NewProvider([]retriever.Retriever{vector}, map[string]retriever.Retriever{"vector": vector}, 60)
+	profile := config.RetrievalProfile{TopK: 5}
+
+	m := metrics.NewRetrievalMetrics()
+	ctx := retriever.WithHyDEVectors(context.Background(), [][]float32{{1, 0}, {0, 1}})
+	results, err := provider.Retrieve(ctx, []string{"q"}, profile, m)
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if len(results) != 2 || vector.vectors != 2 {
+		t.Fatalf("Retrieve() = %+v after %d vector searches, want guide#1 and hyde#2 after 2", results, vector.vectors)
+	}
+	if m.HyDEVectorSearches != 2 || m.HyDEContributed != 2 || m.RetrieverMetrics[HYDE_RETRIEVER].ResultCount != 4 {
+		t.Errorf("metrics hyde searches %d, contributed %d, retriever metrics %v, want 2, 2 and 4 hyde results",
+			m.HyDEVectorSearches, m.HyDEContributed, m.RetrieverMetrics)
+	}
+
+	// Without HyDE vectors only the query is searched
+	vector.vectors = 0
+	m = metrics.NewRetrievalMetrics()
+	if results, _ = provider.Retrieve(context.Background(), []string{"q"}, profile, m); len(results) != 1 || vector.vectors != 0 || m.HyDEContributed != 0 {
+		t.Errorf("Retrieve() = %+v after %d vector searches, contributed %d, want only guide#1", results, vector.vectors, m.HyDEContributed)
+	}
+}
+
+func TestRetrieve_RetrieverThresholds(t *testing.T) {
+	vector := &fixedRetriever{typ: "vector", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "guide#1"}, Score: 0.9},
+		{Document: schema.Document{ID: "guide#2"}, Score: 0.5},
+	}}
+	web := &fixedRetriever{typ: "web", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "web#1"}, Score: 0},
+	}}
+	provider := NewProvider([]retriever.Retriever{vector, web}, map[string]retriever.Retriever{"vector": vector, "web": web}, 60)
+	profile := config.RetrievalProfile{TopK: 5, RetrieverThresholds: map[string]float64{"vector": 0.7, "web": 0}}
+
+	m := metrics.NewRetrievalMetrics()
+	results, err := provider.Retrieve(context.Background(), []string{"q"}, profile, m)
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	ids := make(map[string]bool, len(results))
+	for _, r := range results {
+		ids[r.Document.ID] = true
+	}
+	if len(results) != 2 || !ids["guide#1"] || !ids["web#1"] {
+		t.Errorf("Retrieve() = %+v, want guide#1 and web#1", results)
+	}
+	if m.RetrieverThresholdFiltered["vector"] != 1 || len(m.RetrieverThresholdFiltered) != 1 {
+		t.Errorf("retriever_threshold_filtered = %v, want 1 vector result", m.RetrieverThresholdFiltered)
+	}
+
+	// The profile threshold still gates the fused results
+	profile.Threshold = 1
+	if results, _ = provider.Retrieve(context.Background(), []string{"q"}, profile, metrics.NewRetrievalMetrics()); len(results) != 0 {
+		t.Errorf("Retrieve() = %+v, want no results over the profile threshold", results)
+	}
+}
@@ -0,0 +1,73 @@
+package retrieval
+
+import (
+	"strings"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/fusion"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// filterByRetrieverThresholds drops the results scoring below the threshold of their
+// retriever type from the fusion inputs and the raw results, recording per type how many
+// results of the inputs were dropped. Types without a threshold are kept as they are.
+func filterByRetrieverThresholds(
+	inputs []fusion.RetrieverResult,
+	raw []schema.SearchResult,
+	thresholds map[string]float64,
+	m *metrics.RetrievalMetrics,
+) ([]fusion.RetrieverResult, []schema.SearchResult) {
+	if len(thresholds) == 0 {
+		return inputs, raw
+	}
+	filtered := make([]fusion.RetrieverResult, 0, len(inputs))
+	for _, input := range inputs {
+		threshold, ok := retrieverThreshold(thresholds, input.Retriever)
+		if !ok {
+			filtered = append(filtered, input)
+			continue
+		}
+		kept := make([]schema.SearchResult, 0, len(input.Results))
+		for _, doc := range input.Results {
+			if doc.Score >= threshold {
+				kept = append(kept, doc)
+			}
+		}
+		if dropped := len(input.Results) - len(kept); dropped > 0 && m != nil {
+			m.RecordThresholdFiltered(input.Retriever, dropped)
+		}
+		input.Results = kept
+		filtered = append(filtered, input)
+	}
+	return filtered, filterByThreshold(raw, thresholds)
+}
+
+// filterByThreshold drops the results scoring below the threshold of the retriever type
+// in their metadata
+func filterByThreshold(docs []schema.SearchResult, thresholds map[string]float64) []schema.SearchResult {
+	if len(thresholds) == 0 || len(docs) == 0 {
+		return docs
+	}
+	kept := make([]schema.SearchResult, 0, len(docs))
+	for _, doc := range docs {
+		typ, _ := doc.Document.Metadata["retriever_type"].(string)
+		if threshold, ok := retrieverThreshold(thresholds, typ); ok && doc.Score < threshold {
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	return kept
+}
+
+// retrieverThreshold returns the threshold of a retriever type, matched case-insensitively
+func retrieverThreshold(thresholds map[string]float64, typ string) (float64, bool) {
+	if threshold, ok := thresholds[typ]; ok {
+		return threshold, true
+	}
+	for key, threshold := range thresholds {
+		if strings.EqualFold(key, typ) {
+			return threshold, true
+		}
+	}
+	return 0, false
+}
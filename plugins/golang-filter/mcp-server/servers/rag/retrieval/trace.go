@@ -0,0 +1,31 @@
+package retrieval
+
+import (
+	"context"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/fusion"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+type fusionTraceKey struct{}
+
+// FusionTrace captures the ranked lists around fusion for a single Retrieve call, for
+// explain traces.
+type FusionTrace struct {
+	// Retrieved are the per-retriever lists, after the per-retriever thresholds
+	Retrieved []fusion.RetrieverResult
+	// Inputs are the lists passed to the fusion strategy, after content dedup
+	Inputs []fusion.RetrieverResult
+	// Output is the fused list returned by Retrieve
+	Output []schema.SearchResult
+}
+
+// WithFusionTrace returns a context under which Retrieve records its ranked lists into t.
+func WithFusionTrace(ctx context.Context, t *FusionTrace) context.Context {
+	return context.WithValue(ctx, fusionTraceKey{}, t)
+}
+
+func fusionTraceFrom(ctx context.Context) *FusionTrace {
+	t, _ := ctx.Value(fusionTraceKey{}).(*FusionTrace)
+	return t
+}
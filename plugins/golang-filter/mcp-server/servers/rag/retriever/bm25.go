@@ -57,6 +57,22 @@ func (r *BM25Retriever) Search(ctx context.Context, query string, topK int) ([]s
             },
         },
     }
+    // Metadata filters (e.g. namespace) are applied as exact term filters on metadata.<key>,
+    // so those keys should be mapped as keyword fields in the index.
+    if filters := FiltersFromContext(ctx); len(filters) > 0 {
+        terms := make([]map[string]interface{}, 0, len(filters))
+        for key, value := range filters {
+            terms = append(terms, map[string]interface{}{
+                "term": map[string]interface{}{"metadata." + key: value},
+            })
+        }
+        q.Query = map[string]interface{}{
+            "bool": map[string]interface{}{
+                "must":   q.Query,
+                "filter": terms,
+            },
+        }
+    }
     bs, _ := json.Marshal(q)
     // Build URL: {endpoint}/{index}/_search
     u, err := url.Parse(r.Endpoint)
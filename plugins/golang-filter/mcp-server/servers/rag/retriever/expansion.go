@@ -0,0 +1,114 @@
+package retriever
+
+import (
+    "context"
+    "sort"
+    "sync"
+    "sync/atomic"
+
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/logger"
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+type queryVariantsKey struct{}
+
+type queryVariants struct {
+    variants  map[string][]string
+    maxFanout int
+    executed  atomic.Int64
+}
+
+// WithQueryVariants returns a context carrying rewritten variants of the retrieval queries,
+// keyed by query, for QueryExpansionRetriever. maxFanout caps the searches of one retriever
+// call, the query itself included; <= 0 leaves them uncapped.
+func WithQueryVariants(ctx context.Context, variants map[string][]string, maxFanout int) context.Context {
+    if len(variants) == 0 {
+        return ctx
+    }
+    return context.WithValue(ctx, queryVariantsKey{}, &queryVariants{variants: variants, maxFanout: maxFanout})
+}
+
+// ExecutedVariants returns how many variant searches QueryExpansionRetrievers issued under
+// ctx, across all retrievers and queries.
+func ExecutedVariants(ctx context.Context) int {
+    if qv, ok := ctx.Value(queryVariantsKey{}).(*queryVariants); ok {
+        return int(qv.executed.Load())
+    }
+    return 0
+}
+
+// QueryExpansionRetriever searches Base with a query and, in parallel, with each of the
+// query's variants carried by the context, then merges the results by document ID keeping
+// the highest score. Without variants for the query it is a plain Base search.
+type QueryExpansionRetriever struct {
+    Base Retriever
+}
+
+func (r *QueryExpansionRetriever) Type() string { return r.Base.Type() }
+
+func (r *QueryExpansionRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+    qv, _ := ctx.Value(queryVariantsKey{}).(*queryVariants)
+    if qv == nil || len(qv.variants[query]) == 0 {
+        return r.Base.Search(ctx, query, topK)
+    }
+    searches := append([]string{query}, qv.variants[query]...)
+    if qv.maxFanout > 0 && len(searches) > qv.maxFanout {
+        searches = searches[:qv.maxFanout]
+    }
+    if len(searches) == 1 {
+        return r.Base.Search(ctx, query, topK)
+    }
+
+    lists := make([][]schema.SearchResult, len(searches))
+    errs := make([]error, len(searches))
+    var wg sync.WaitGroup
+    for i, q := range searches {
+        wg.Add(1)
+        go func(i int, q string) {
+            defer wg.Done()
+            lists[i], errs[i] = r.Base.Search(ctx, q, topK)
+        }(i, q)
+    }
+    wg.Wait()
+    qv.executed.Add(int64(len(searches) - 1))
+
+    // Variants only add recall: the query's own search decides whether the call fails
+    if errs[0] != nil {
+        return nil, errs[0]
+    }
+    for i := 1; i < len(searches); i++ {
+        if errs[i] != nil {
+            logger.Warnf("retriever: %s variant %q failed: %v", r.Base.Type(), searches[i], errs[i])
+        }
+    }
+    return mergeMaxScore(lists, topK), nil
+}
+
+// mergeMaxScore deduplicates lists by document ID, keeping each document's highest-scored
+// hit, and returns at most topK results by descending score. Equal scores keep the order in
+// which the documents were first seen, so the query's own results come first.
+func mergeMaxScore(lists [][]schema.SearchResult, topK int) []schema.SearchResult {
+    merged := make([]schema.SearchResult, 0, len(lists[0]))
+    index := make(map[string]int)
+    for _, list := range lists {
+        for _, res := range list {
+            key := res.Document.ID
+            if key == "" {
+                key = res.Document.Content
+            }
+            if i, ok := index[key]; ok {
+                if res.Score > merged[i].Score {
+                    merged[i] = res
+                }
+                continue
+            }
+            index[key] = len(merged)
+            merged = append(merged, res)
+        }
+    }
+    sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+    if topK > 0 && len(merged) > topK {
+        merged = merged[:topK]
+    }
+    return merged
+}
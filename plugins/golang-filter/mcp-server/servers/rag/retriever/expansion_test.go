@@ -0,0 +1,108 @@
+package retriever
+
+import (
+    "context"
+    "errors"
+    "reflect"
+    "sort"
+    "sync"
+    "testing"
+
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// queryStubRetriever returns canned results per query and records the queries searched.
+type queryStubRetriever struct {
+    mu       sync.Mutex
+    results  map[string][]schema.SearchResult
+    errs     map[string]error
+    searched []string
+}
+
+func (s *queryStubRetriever) Type() string { return "stub" }
+
+func (s *queryStubRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+    s.mu.Lock()
+    s.searched = append(s.searched, query)
+    s.mu.Unlock()
+    return s.results[query], s.errs[query]
+}
+
+func hit(id string, score float64) schema.SearchResult {
+    return schema.SearchResult{Document: schema.Document{ID: id}, Score: score}
+}
+
+func resultIDs(results []schema.SearchResult) []string {
+    ids := make([]string, len(results))
+    for i, res := range results {
+        ids[i] = res.Document.ID
+    }
+    return ids
+}
+
+func TestQueryExpansionRetriever_Search(t *testing.T) {
+    base := &queryStubRetriever{results: map[string][]schema.SearchResult{
+        "gateway":         {hit("a", 0.9), hit("b", 0.5)},
+        "gateway ingress": {hit("b", 0.8), hit("c", 0.6)},
+        "gateway routing": {hit("d", 0.95)},
+    }}
+    r := &QueryExpansionRetriever{Base: base}
+    ctx := WithQueryVariants(context.Background(), map[string][]string{
+        "gateway": {"gateway ingress", "gateway routing"},
+    }, 0)
+
+    got, err := r.Search(ctx, "gateway", 3)
+    if err != nil {
+        t.Fatalf("Search() error = %v", err)
+    }
+    if ids := resultIDs(got); !reflect.DeepEqual(ids, []string{"d", "a", "b"}) {
+        t.Errorf("Search() = %v, want [d a b]", ids)
+    }
+    if got[2].Score != 0.8 {
+        t.Errorf("duplicate b score = %v, want the max 0.8", got[2].Score)
+    }
+    if n := ExecutedVariants(ctx); n != 2 {
+        t.Errorf("ExecutedVariants() = %d, want 2", n)
+    }
+
+    // Queries without variants, e.g. other sub-queries, are searched as is
+    base.searched = nil
+    if _, err := r.Search(ctx, "plugins", 3); err != nil || !reflect.DeepEqual(base.searched, []string{"plugins"}) {
+        t.Errorf("Search(plugins) searched %v, %v, want only the query", base.searched, err)
+    }
+}
+
+func TestQueryExpansionRetriever_MaxFanout(t *testing.T) {
+    base := &queryStubRetriever{}
+    r := &QueryExpansionRetriever{Base: base}
+    ctx := WithQueryVariants(context.Background(), map[string][]string{"q": {"v1", "v2", "v3"}}, 2)
+
+    if _, err := r.Search(ctx, "q", 5); err != nil {
+        t.Fatalf("Search() error = %v", err)
+    }
+    sort.Strings(base.searched)
+    if !reflect.DeepEqual(base.searched, []string{"q", "v1"}) {
+        t.Errorf("searched %v, want the query and the first variant", base.searched)
+    }
+    if n := ExecutedVariants(ctx); n != 1 {
+        t.Errorf("ExecutedVariants() = %d, want 1", n)
+    }
+}
+
+func TestQueryExpansionRetriever_Errors(t *testing.T) {
+    errDown := errors.New("down")
+    base := &queryStubRetriever{
+        results: map[string][]schema.SearchResult{"q": {hit("a", 0.4)}, "v2": {hit("b", 0.7)}},
+        errs:    map[string]error{"v1": errDown},
+    }
+    r := &QueryExpansionRetriever{Base: base}
+    ctx := WithQueryVariants(context.Background(), map[string][]string{"q": {"v1", "v2"}, "v1": {"q"}}, 0)
+
+    got, err := r.Search(ctx, "q", 5)
+    if err != nil || !reflect.DeepEqual(resultIDs(got), []string{"b", "a"}) {
+        t.Errorf("Search() = %v, %v, want the failed variant skipped", resultIDs(got), err)
+    }
+    if _, err := r.Search(ctx, "v1", 5); !errors.Is(err, errDown) {
+        t.Errorf("Search() error = %v, want the query's own error", err)
+    }
+}
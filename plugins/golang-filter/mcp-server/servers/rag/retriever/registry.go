@@ -0,0 +1,123 @@
+package retriever
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/httpx"
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/embedding"
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/vectordb"
+)
+
+// Deps carries shared components a retriever factory may depend on.
+type Deps struct {
+    Embed      embedding.Provider
+    Sparse     embedding.SparseProvider
+    Store      vectordb.VectorStoreProvider
+    HTTPClient *httpx.Client
+}
+
+// Factory builds a Retriever from its pipeline configuration.
+type Factory func(cfg config.RetrieverConfig, deps Deps) (Retriever, error)
+
+var (
+    registryMu sync.RWMutex
+    registry   = map[string]Factory{}
+)
+
+func init() {
+    Register("vector", newVectorRetriever)
+    Register("bm25", newBM25Retriever)
+    Register("web", newWebSearchRetriever)
+    Register("sparse", newSparseRetriever)
+}
+
+// Register makes a retriever type available to the pipeline. Registering an
+// existing type name replaces the previous factory.
+func Register(typeName string, factory Factory) {
+    key := strings.ToLower(strings.TrimSpace(typeName))
+    if key == "" || factory == nil {
+        return
+    }
+    registryMu.Lock()
+    registry[key] = factory
+    registryMu.Unlock()
+}
+
+// Lookup returns the factory registered for typeName.
+func Lookup(typeName string) (Factory, bool) {
+    registryMu.RLock()
+    f, ok := registry[strings.ToLower(strings.TrimSpace(typeName))]
+    registryMu.RUnlock()
+    return f, ok
+}
+
+// New creates a retriever for cfg using the registered factory of cfg.Type.
+func New(cfg config.RetrieverConfig, deps Deps) (Retriever, error) {
+    f, ok := Lookup(cfg.Type)
+    if !ok {
+        return nil, fmt.Errorf("unknown retriever type: %s", cfg.Type)
+    }
+    return f(cfg, deps)
+}
+
+func newVectorRetriever(cfg config.RetrieverConfig, deps Deps) (Retriever, error) {
+    if deps.Embed == nil || deps.Store == nil {
+        return nil, fmt.Errorf("vector retriever requires embedding provider and vector store")
+    }
+    r := &VectorRetriever{Embed: deps.Embed, Store: deps.Store, TopK: paramInt(cfg.Params, "top_k")}
+    if th := cfg.Params["threshold"]; th != "" {
+        if f, err := strconv.ParseFloat(th, 64); err == nil {
+            r.Threshold = f
+        }
+    }
+    return r, nil
+}
+
+func newSparseRetriever(cfg config.RetrieverConfig, deps Deps) (Retriever, error) {
+    if deps.Sparse == nil {
+        return nil, fmt.Errorf("sparse retriever requires an embedding of kind sparse")
+    }
+    store, ok := deps.Store.(vectordb.SparseSearcher)
+    if !ok {
+        return nil, fmt.Errorf("sparse retriever requires a vector store that supports sparse search")
+    }
+    r := &SparseRetriever{Embed: deps.Sparse, Store: store, TopK: paramInt(cfg.Params, "top_k")}
+    if th := cfg.Params["threshold"]; th != "" {
+        if f, err := strconv.ParseFloat(th, 64); err == nil {
+            r.Threshold = f
+        }
+    }
+    return r, nil
+}
+
+func newBM25Retriever(cfg config.RetrieverConfig, deps Deps) (Retriever, error) {
+    return &BM25Retriever{
+        Endpoint: cfg.Params["endpoint"],
+        Index:    cfg.Params["index"],
+        Client:   deps.HTTPClient,
+        MaxTopK:  paramInt(cfg.Params, "top_k"),
+    }, nil
+}
+
+func newWebSearchRetriever(cfg config.RetrieverConfig, deps Deps) (Retriever, error) {
+    return &WebSearchRetriever{
+        Provider: cfg.Provider,
+        Endpoint: cfg.Params["endpoint"],
+        APIKey:   cfg.Params["api_key"],
+        Client:   deps.HTTPClient,
+        MaxTopK:  paramInt(cfg.Params, "top_k"),
+    }, nil
+}
+
+func paramInt(params map[string]string, key string) int {
+    if v := params[key]; v != "" {
+        if n, err := strconv.Atoi(v); err == nil {
+            return n
+        }
+    }
+    return 0
+}
@@ -0,0 +1,81 @@
+package retriever
+
+import (
+    "context"
+    "testing"
+
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+type fakeRetriever struct {
+    prefix string
+}
+
+func (f *fakeRetriever) Type() string { return "fake" }
+
+func (f *fakeRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+    out := make([]schema.SearchResult, 0, topK)
+    for i := 0; i < topK; i++ {
+        out = append(out, schema.SearchResult{
+            Document: schema.Document{ID: f.prefix + query, Content: query},
+            Score:    1.0 / float64(i+1),
+        })
+    }
+    return out, nil
+}
+
+func TestRegistryBuiltins(t *testing.T) {
+    for _, typ := range []string{"vector", "bm25", "web"} {
+        if _, ok := Lookup(typ); !ok {
+            t.Errorf("builtin retriever %q not registered", typ)
+        }
+    }
+}
+
+func TestRegisterFakeRetriever(t *testing.T) {
+    Register("Fake", func(cfg config.RetrieverConfig, deps Deps) (Retriever, error) {
+        return &fakeRetriever{prefix: cfg.Params["prefix"]}, nil
+    })
+
+    r, err := New(config.RetrieverConfig{Type: "fake", Params: map[string]string{"prefix": "doc-"}}, Deps{})
+    if err != nil {
+        t.Fatalf("New() error = %v", err)
+    }
+    results, err := r.Search(context.Background(), "hello", 2)
+    if err != nil {
+        t.Fatalf("Search() error = %v", err)
+    }
+    if len(results) != 2 {
+        t.Fatalf("Search() returned %d results, want 2", len(results))
+    }
+    if results[0].Document.ID != "doc-hello" {
+        t.Errorf("Search() first ID = %q, want %q", results[0].Document.ID, "doc-hello")
+    }
+}
+
+func TestNewUnknownRetriever(t *testing.T) {
+    if _, err := New(config.RetrieverConfig{Type: "does-not-exist"}, Deps{}); err == nil {
+        t.Error("New() expected error for unknown type")
+    }
+}
+
+func TestNewVectorRetrieverRequiresDeps(t *testing.T) {
+    if _, err := New(config.RetrieverConfig{Type: "vector"}, Deps{}); err == nil {
+        t.Error("New() expected error when vector deps are missing")
+    }
+}
+
+func TestNewBM25RetrieverParams(t *testing.T) {
+    r, err := New(config.RetrieverConfig{Type: "bm25", Params: map[string]string{"endpoint": "http://es:9200", "index": "docs", "top_k": "7"}}, Deps{})
+    if err != nil {
+        t.Fatalf("New() error = %v", err)
+    }
+    bm, ok := r.(*BM25Retriever)
+    if !ok {
+        t.Fatalf("New() returned %T, want *BM25Retriever", r)
+    }
+    if bm.Endpoint != "http://es:9200" || bm.Index != "docs" || bm.MaxTopK != 7 {
+        t.Errorf("unexpected bm25 retriever: %+v", bm)
+    }
+}
@@ -14,3 +14,20 @@ type Retriever interface {
 
 // CandidateList is a utility alias for readability.
 type CandidateList []schema.SearchResult
+
+type filtersKey struct{}
+
+// WithFilters returns a context carrying metadata filters (e.g. namespace) that
+// retrievers backed by the knowledge base must apply to their searches.
+func WithFilters(ctx context.Context, filters map[string]interface{}) context.Context {
+    if len(filters) == 0 {
+        return ctx
+    }
+    return context.WithValue(ctx, filtersKey{}, filters)
+}
+
+// FiltersFromContext returns the metadata filters carried by ctx, or nil.
+func FiltersFromContext(ctx context.Context) map[string]interface{} {
+    filters, _ := ctx.Value(filtersKey{}).(map[string]interface{})
+    return filters
+}
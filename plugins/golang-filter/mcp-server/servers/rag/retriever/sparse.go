@@ -0,0 +1,37 @@
+package retriever
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/embedding"
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/vectordb"
+)
+
+// SparseRetriever implements Retriever using a learned sparse embedding and a store
+// that ranks documents by sparse inner product.
+type SparseRetriever struct {
+    Embed     embedding.SparseProvider
+    Store     vectordb.SparseSearcher
+    TopK      int
+    Threshold float64
+}
+
+func (r *SparseRetriever) Type() string { return "sparse" }
+
+func (r *SparseRetriever) Search(ctx context.Context, query string, topK int) ([]schema.SearchResult, error) {
+    if topK <= 0 {
+        if r.TopK > 0 {
+            topK = r.TopK
+        } else {
+            topK = 10
+        }
+    }
+    v, err := r.Embed.GetSparseEmbedding(ctx, query)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrEmbeddingFailed, err)
+    }
+    opts := &schema.SearchOptions{TopK: topK, Threshold: r.Threshold, Filters: FiltersFromContext(ctx)}
+    return r.Store.SearchSparse(ctx, v, opts)
+}
@@ -0,0 +1,80 @@
+package retriever
+
+import (
+    "context"
+    "sort"
+    "strings"
+    "testing"
+
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/vectordb"
+)
+
+// termSparseEmbedder maps each known term to a fixed vocabulary id.
+type termSparseEmbedder struct {
+    vocab map[string]uint32
+}
+
+func (e *termSparseEmbedder) GetProviderType() string { return "stub" }
+
+func (e *termSparseEmbedder) GetSparseEmbedding(ctx context.Context, text string) (schema.SparseVector, error) {
+    vec := schema.SparseVector{}
+    for term, id := range e.vocab {
+        if strings.Contains(text, term) {
+            vec[id] = 1
+        }
+    }
+    return vec, nil
+}
+
+// sparseStore ranks its documents by sparse inner product.
+type sparseStore struct {
+    vectordb.VectorStoreProvider
+    docs []schema.Document
+}
+
+func (s *sparseStore) SearchSparse(ctx context.Context, vector schema.SparseVector, options *schema.SearchOptions) ([]schema.SearchResult, error) {
+    results := make([]schema.SearchResult, 0, len(s.docs))
+    for _, doc := range s.docs {
+        if score := vector.Dot(doc.SparseVector); score > options.Threshold {
+            results = append(results, schema.SearchResult{Document: doc, Score: score})
+        }
+    }
+    sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+    if len(results) > options.TopK {
+        results = results[:options.TopK]
+    }
+    return results, nil
+}
+
+func TestSparseRetriever_Search(t *testing.T) {
+    store := &sparseStore{docs: []schema.Document{
+        {ID: "wasm", SparseVector: schema.SparseVector{1: 0.8, 2: 0.4}},
+        {ID: "milvus", SparseVector: schema.SparseVector{3: 0.9}},
+        {ID: "gateway", SparseVector: schema.SparseVector{2: 0.3}},
+    }}
+    embedder := &termSparseEmbedder{vocab: map[string]uint32{"wasm": 1, "plugin": 2, "vector": 3}}
+
+    r, err := New(config.RetrieverConfig{Type: "sparse"}, Deps{Sparse: embedder, Store: store})
+    if err != nil {
+        t.Fatalf("New() error = %v", err)
+    }
+    results, err := r.Search(context.Background(), "wasm plugin", 5)
+    if err != nil {
+        t.Fatalf("Search() error = %v", err)
+    }
+    if len(results) != 2 || results[0].Document.ID != "wasm" || results[1].Document.ID != "gateway" {
+        t.Errorf("Search() = %+v, want wasm then gateway", results)
+    }
+}
+
+func TestSparseRetriever_RequiresSparseStore(t *testing.T) {
+    embedder := &termSparseEmbedder{}
+    if _, err := New(config.RetrieverConfig{Type: "sparse"}, Deps{Sparse: embedder}); err == nil {
+        t.Error("New() expected error without a sparse-capable store")
+    }
+    if _, err := New(config.RetrieverConfig{Type: "sparse"}, Deps{Store: &sparseStore{}}); err == nil {
+        t.Error("New() expected error without a sparse embedding")
+    }
+}
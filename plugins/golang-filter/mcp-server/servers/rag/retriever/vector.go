@@ -2,12 +2,59 @@ package retriever
 
 import (
     "context"
+    "errors"
+    "fmt"
 
     "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/embedding"
     "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
     "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/vectordb"
 )
 
+// ErrEmbeddingFailed marks search failures caused by the embedding provider, so callers can
+// degrade to the remaining retrievers instead of failing the request.
+var ErrEmbeddingFailed = errors.New("embedding failed")
+
+type embedderKey struct{}
+
+// WithEmbedder returns a context under which VectorRetrievers embed queries with p instead
+// of their own provider, e.g. the embedding override of the selected retrieval profile.
+func WithEmbedder(ctx context.Context, p embedding.Provider) context.Context {
+    if p == nil {
+        return ctx
+    }
+    return context.WithValue(ctx, embedderKey{}, p)
+}
+
+// EmbedderFromContext returns the embedding provider carried by ctx, or nil.
+func EmbedderFromContext(ctx context.Context) embedding.Provider {
+    p, _ := ctx.Value(embedderKey{}).(embedding.Provider)
+    return p
+}
+
+type hydeVectorsKey struct{}
+
+// WithHyDEVectors returns a context carrying the embeddings of hypothetical documents
+// generated for the query (HyDE), which the retrieval pipeline searches as an extra input.
+func WithHyDEVectors(ctx context.Context, vectors [][]float32) context.Context {
+    if len(vectors) == 0 {
+        return ctx
+    }
+    return context.WithValue(ctx, hydeVectorsKey{}, vectors)
+}
+
+// HyDEVectorsFromContext returns the HyDE vectors carried by ctx, or nil.
+func HyDEVectorsFromContext(ctx context.Context) [][]float32 {
+    vectors, _ := ctx.Value(hydeVectorsKey{}).([][]float32)
+    return vectors
+}
+
+// VectorSearcher is implemented by retrievers that can search with an already embedded
+// vector instead of a query text.
+type VectorSearcher interface {
+    Retriever
+    SearchVector(ctx context.Context, vector []float32, topK int) ([]schema.SearchResult, error)
+}
+
 // VectorRetriever implements Retriever using embedding+vector store backend.
 type VectorRetriever struct {
     Embed   embedding.Provider
@@ -27,10 +74,26 @@ func (r *VectorRetriever) Search(ctx context.Context, query string, topK int) ([
             topK = 10
         }
     }
-    v, err := r.Embed.GetEmbedding(ctx, query)
+    embed := r.Embed
+    if p := EmbedderFromContext(ctx); p != nil {
+        embed = p
+    }
+    v, err := embed.GetEmbedding(ctx, query)
     if err != nil {
-        return nil, err
+        return nil, fmt.Errorf("%w: %v", ErrEmbeddingFailed, err)
+    }
+    return r.SearchVector(ctx, v, topK)
+}
+
+// SearchVector searches the store with vector, applying the filters carried by ctx.
+func (r *VectorRetriever) SearchVector(ctx context.Context, vector []float32, topK int) ([]schema.SearchResult, error) {
+    if topK <= 0 {
+        if r.TopK > 0 {
+            topK = r.TopK
+        } else {
+            topK = 10
+        }
     }
-    opts := &schema.SearchOptions{TopK: topK, Threshold: r.Threshold}
-    return r.Store.SearchDocs(ctx, v, opts)
+    opts := &schema.SearchOptions{TopK: topK, Threshold: r.Threshold, Filters: FiltersFromContext(ctx)}
+    return r.Store.SearchDocs(ctx, vector, opts)
 }
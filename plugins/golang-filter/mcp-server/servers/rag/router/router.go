@@ -25,6 +25,23 @@ type RoutingDecision struct {
 	VariantBudgets map[string]VariantBudget `json:"variant_budgets,omitempty"`
 }
 
+type queryLanguageKey struct{}
+
+// WithQueryLanguage attaches the detected language of the query, which rules with a
+// Language only match and the HTTP router forwards
+func WithQueryLanguage(ctx context.Context, language string) context.Context {
+	if language == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, queryLanguageKey{}, language)
+}
+
+// QueryLanguage returns the language attached by WithQueryLanguage, or ""
+func QueryLanguage(ctx context.Context) string {
+	language, _ := ctx.Value(queryLanguageKey{}).(string)
+	return language
+}
+
 // VariantBudget defines per-variant routing budgets.
 type VariantBudget struct {
 	Enable bool `json:"enable"`
@@ -57,37 +74,38 @@ func NewHTTPRouter(endpoint string, routerCfg *config.RouterConfig, httpCfg *con
 }
 
 type routeRequest struct {
-	Query string `json:"query"`
+	Query    string `json:"query"`
+	Language string `json:"language,omitempty"`
 }
 
 // Route calls external routing service
 func (r *HTTPRouter) Route(ctx context.Context, query string) (*RoutingDecision, error) {
-	req := routeRequest{Query: query}
+	req := routeRequest{Query: query, Language: QueryLanguage(ctx)}
 	body, _ := json.Marshal(req)
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Endpoint, bytes.NewReader(body))
 	if err != nil {
 		api.LogWarnf("router: failed to create request: %v", err)
-		return r.fallbackRuleBased(query), nil
+		return r.fallbackRuleBased(ctx, query), nil
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	resp, err := r.Client.Do(httpReq)
 	if err != nil {
 		api.LogWarnf("router: HTTP request failed: %v", err)
-		return r.fallbackRuleBased(query), nil
+		return r.fallbackRuleBased(ctx, query), nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		api.LogWarnf("router: unexpected status code: %d", resp.StatusCode)
-		return r.fallbackRuleBased(query), nil
+		return r.fallbackRuleBased(ctx, query), nil
 	}
 
 	var decision RoutingDecision
 	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
 		api.LogWarnf("router: failed to decode response: %v", err)
-		return r.fallbackRuleBased(query), nil
+		return r.fallbackRuleBased(ctx, query), nil
 	}
 
 	api.LogInfof("router: decision from HTTP service - web=%v vector=%v bm25=%v type=%s confidence=%.2f",
@@ -96,9 +114,9 @@ func (r *HTTPRouter) Route(ctx context.Context, query string) (*RoutingDecision,
 }
 
 // fallbackRuleBased provides rule-based routing as fallback
-func (r *HTTPRouter) fallbackRuleBased(query string) *RoutingDecision {
+func (r *HTTPRouter) fallbackRuleBased(ctx context.Context, query string) *RoutingDecision {
 	rb := NewRuleBasedRouter(r.rules)
-	decision, _ := rb.Route(context.Background(), query)
+	decision, _ := rb.Route(ctx, query)
 	return decision
 }
 
@@ -193,14 +211,14 @@ func (r *RuleBasedRouter) Route(ctx context.Context, query string) (*RoutingDeci
 		}
 	}
 
-	r.applyRules(decision)
+	r.applyRules(decision, QueryLanguage(ctx))
 
 	api.LogInfof("router: rule-based decision - web=%v vector=%v bm25=%v type=%s reason=%s",
 		decision.NeedWeb, decision.NeedVector, decision.NeedBM25, decision.QueryType, decision.Reason)
 	return decision, nil
 }
 
-func (r *RuleBasedRouter) applyRules(decision *RoutingDecision) {
+func (r *RuleBasedRouter) applyRules(decision *RoutingDecision, language string) {
 	if decision == nil || len(r.rules) == 0 {
 		return
 	}
@@ -208,6 +226,9 @@ func (r *RuleBasedRouter) applyRules(decision *RoutingDecision) {
 		if rule.Intent != "" && !strings.EqualFold(rule.Intent, decision.QueryType) {
 			continue
 		}
+		if rule.Language != "" && !strings.EqualFold(rule.Language, language) {
+			continue
+		}
 		if rule.Profile != "" {
 			decision.ProfileName = rule.Profile
 		} else if rule.Intent != "" {
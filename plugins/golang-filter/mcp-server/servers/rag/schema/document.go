@@ -5,15 +5,55 @@ import "time"
 const (
 	DEFAULT_KNOWLEDGE_COLLECTION = "knowledge"
 	DEFAULT_DOCUMENT_COLLECTION  = "document"
+
+	// METADATA_NAMESPACE is the metadata key that scopes a document to a knowledge-base namespace
+	METADATA_NAMESPACE = "namespace"
+	// METADATA_DOC_KEY is the metadata key holding the caller's stable key of the source
+	// document, shared by all of its chunks
+	METADATA_DOC_KEY = "doc_key"
+	// METADATA_DOC_HASH is the metadata key holding the hash of the source document's title
+	// and text, used to skip re-ingesting unchanged documents
+	METADATA_DOC_HASH = "doc_hash"
+	// METADATA_PARENT_ID is the metadata key linking a chunk to the parent section it was cut
+	// from when hierarchical chunking is enabled, shared by all chunks of the section
+	METADATA_PARENT_ID = "parent_id"
+	// METADATA_PARENT_CONTENT is the metadata key holding the text of the chunk's parent section
+	METADATA_PARENT_CONTENT = "parent_content"
 )
 
+// SparseVector is a learned sparse (SPLADE-style) embedding mapping vocabulary ids to weights
+type SparseVector map[uint32]float32
+
+// Dot returns the inner product of two sparse vectors
+func (v SparseVector) Dot(other SparseVector) float64 {
+	if len(other) < len(v) {
+		v, other = other, v
+	}
+	var sum float64
+	for idx, w := range v {
+		sum += float64(w) * float64(other[idx])
+	}
+	return sum
+}
+
 // Document represents a document with its vector embedding and metadata
 type Document struct {
-	ID        string                 `json:"id"`
-	Content   string                 `json:"content"`
-	Vector    []float32              `json:"-"`
-	Metadata  map[string]interface{} `json:"metadata"`
-	CreatedAt time.Time              `json:"created_at"`
+	ID           string                 `json:"id"`
+	Content      string                 `json:"content"`
+	Vector       []float32              `json:"-"`
+	SparseVector SparseVector           `json:"-"`
+	Metadata     map[string]interface{} `json:"metadata"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// Title returns the chunk title set at ingestion, falling back to the "title" metadata
+// carried by web and keyword search hits
+func (d Document) Title() string {
+	if title, ok := d.Metadata["chunk_title"].(string); ok && title != "" {
+		return title
+	}
+	title, _ := d.Metadata["title"].(string)
+	return title
 }
 
 // SearchResult represents a result from a vector search
@@ -43,4 +83,15 @@ type SearchOptions struct {
 	TopK      int                    `json:"top_k"`
 	Threshold float64                `json:"threshold"`
 	Filters   map[string]interface{} `json:"filters,omitempty"`
+	// IncludeVectors asks for Document.Vector in the results; providers omit it otherwise
+	IncludeVectors bool `json:"include_vectors,omitempty"`
+}
+
+// QueryOptions contains options for scalar (non-vector) document queries
+type QueryOptions struct {
+	IDs     []string               `json:"ids,omitempty"`
+	Filters map[string]interface{} `json:"filters,omitempty"`
+	Limit   int                    `json:"limit"`
+	// Offset skips that many matching documents, for paging through a collection
+	Offset int `json:"offset,omitempty"`
 }
@@ -1,12 +1,18 @@
 package rag
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	pre_retrieve "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/pre-retrieve"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-session/common"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
@@ -111,6 +117,15 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 			if chunkOverlap, exists := splitter["chunk_overlap"].(float64); exists {
 				c.config.RAG.Splitter.ChunkOverlap = int(chunkOverlap)
 			}
+			if language, exists := splitter["language"].(string); exists {
+				c.config.RAG.Splitter.Language = language
+			}
+			if extractor, exists := splitter["metadata_extractor"].(string); exists {
+				c.config.RAG.Splitter.MetadataExtractor = extractor
+			}
+			if parentChunkSize, exists := splitter["parent_chunk_size"].(float64); exists {
+				c.config.RAG.Splitter.ParentChunkSize = int(parentChunkSize)
+			}
 		}
 		if threshold, exists := ragConfig["threshold"].(float64); exists {
 			c.config.RAG.Threshold = threshold
@@ -118,6 +133,12 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 		if topK, exists := ragConfig["top_k"].(float64); exists {
 			c.config.RAG.TopK = int(topK)
 		}
+		if namespaceHeader, exists := ragConfig["namespace_header"].(string); exists {
+			c.config.RAG.NamespaceHeader = namespaceHeader
+		}
+		if noContextMode, exists := ragConfig["no_context_mode"].(string); exists {
+			c.config.RAG.NoContextMode = noContextMode
+		}
 	}
 
 	// Parse Embedding configuration
@@ -140,6 +161,16 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 		if dimensions, exists := embeddingConfig["dimensions"].(float64); exists {
 			c.config.Embedding.Dimensions = int(dimensions)
 		}
+		if inputType, exists := embeddingConfig["input_type"].(string); exists {
+			c.config.Embedding.InputType = inputType
+		}
+		if kind, exists := embeddingConfig["kind"].(string); exists {
+			c.config.Embedding.Kind = kind
+		}
+		if headers, exists := embeddingConfig["headers"].(map[string]any); exists {
+			c.config.Embedding.Headers = parseHeaders(headers)
+		}
+		c.config.Embedding.Cache = parseCacheLayerConfig(embeddingConfig["cache"])
 	}
 
 	// Parse llm configuration
@@ -162,6 +193,24 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 		if maxTokens, exists := llmConfig["max_tokens"].(float64); exists {
 			c.config.LLM.MaxTokens = int(maxTokens)
 		}
+		if promptTemplate, exists := llmConfig["prompt_template"].(string); exists {
+			c.config.LLM.PromptTemplate = promptTemplate
+		}
+		if headers, exists := llmConfig["headers"].(map[string]any); exists {
+			c.config.LLM.Headers = parseHeaders(headers)
+		}
+		if maxRetries, exists := llmConfig["max_retries"].(float64); exists {
+			c.config.LLM.MaxRetries = int(maxRetries)
+		}
+		if timeoutMs, exists := llmConfig["timeout_ms"].(float64); exists {
+			c.config.LLM.TimeoutMs = int(timeoutMs)
+		}
+		if maxContextTokens, exists := llmConfig["max_context_tokens"].(float64); exists {
+			c.config.LLM.MaxContextTokens = int(maxContextTokens)
+		}
+		if contextOverflow, exists := llmConfig["context_overflow"].(string); exists {
+			c.config.LLM.ContextOverflow = contextOverflow
+		}
 	}
 
 	// Parse VectorDB configuration
@@ -189,6 +238,15 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 		if password, exists := vectordbConfig["password"].(string); exists {
 			c.config.VectorDB.Password = password
 		}
+		if enableSparse, exists := vectordbConfig["enable_sparse"].(bool); exists {
+			c.config.VectorDB.EnableSparse = enableSparse
+		}
+		if pingOnStart, exists := vectordbConfig["ping_on_start"].(bool); exists {
+			c.config.VectorDB.PingOnStart = pingOnStart
+		}
+		if grpcPort, exists := vectordbConfig["grpc_port"].(float64); exists {
+			c.config.VectorDB.GRPCPort = int(grpcPort)
+		}
 
 		// Parse mapping here
 		if mapping, exists := vectordbConfig["mapping"].(map[string]any); exists {
@@ -235,6 +293,9 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 				if metricType, ok := search["metric_type"].(string); ok {
 					c.config.VectorDB.Mapping.Search.MetricType = metricType
 				}
+				if normalize, ok := search["normalize"].(bool); ok {
+					c.config.VectorDB.Mapping.Search.Normalize = normalize
+				}
 				// Parse search parameters
 				if params, ok := search["params"].(map[string]any); ok {
 					c.config.VectorDB.Mapping.Search.Params = params
@@ -262,6 +323,53 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 			pc.RRFK = int(v)
 		}
 
+		// fusion
+		if fu, ok := pipelineConfig["fusion"].(map[string]any); ok {
+			pc.Fusion = &config.FusionConfig{}
+			if s, ok := fu["strategy"].(string); ok {
+				pc.Fusion.Strategy = s
+			}
+			if p, ok := fu["params"].(map[string]any); ok {
+				pc.Fusion.Params = map[string]interface{}{}
+				for k, v := range p {
+					pc.Fusion.Params[k] = v
+				}
+			}
+			if b, ok := fu["enable_learned"].(bool); ok {
+				pc.Fusion.EnableLearned = b
+			}
+			if s, ok := fu["fallback"].(string); ok {
+				pc.Fusion.Fallback = s
+			}
+			if s, ok := fu["weights_uri"].(string); ok {
+				pc.Fusion.WeightsURI = s
+			}
+			if v, ok := fu["timeout_ms"].(float64); ok {
+				pc.Fusion.TimeoutMs = int(v)
+			}
+			if v, ok := fu["traffic_percent"].(float64); ok {
+				pc.Fusion.TrafficPercent = int(v)
+			}
+			if v, ok := fu["traffic_salt"].(string); ok {
+				pc.Fusion.TrafficSalt = v
+			}
+			if v, ok := fu["refresh_seconds"].(float64); ok {
+				pc.Fusion.RefreshSeconds = int(v)
+			}
+			if dd, ok := fu["dedup"].(map[string]any); ok {
+				pc.Fusion.Dedup = &config.DedupConfig{}
+				if v, ok := dd["enable"].(bool); ok {
+					pc.Fusion.Dedup.Enable = v
+				}
+				if v, ok := dd["method"].(string); ok {
+					pc.Fusion.Dedup.Method = v
+				}
+				if v, ok := dd["threshold"].(float64); ok {
+					pc.Fusion.Dedup.Threshold = v
+				}
+			}
+		}
+
 		// pre
 		if pre, ok := pipelineConfig["pre"].(map[string]any); ok {
 			pc.Pre = &config.PreConfig{}
@@ -297,6 +405,9 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 				if s, ok := svc["endpoint"].(string); ok {
 					pc.Pre.Service.Endpoint = s
 				}
+				if v, ok := svc["timeout_ms"].(float64); ok {
+					pc.Pre.Service.TimeoutMs = int(v)
+				}
 			}
 		}
 
@@ -319,6 +430,9 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 							}
 						}
 					}
+					if e, ok := m["embedding"].(map[string]any); ok {
+						rc.Embedding = parseEmbeddingOverride(e)
+					}
 					pc.Retrievers = append(pc.Retrievers, rc)
 				}
 			}
@@ -348,12 +462,23 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 					if v, ok := m["threshold"].(float64); ok {
 						prof.Threshold = v
 					}
+					if thresholds, ok := m["retriever_thresholds"].(map[string]any); ok {
+						prof.RetrieverThresholds = make(map[string]float64, len(thresholds))
+						for typ, v := range thresholds {
+							if f, ok := v.(float64); ok {
+								prof.RetrieverThresholds[typ] = f
+							}
+						}
+					}
 					if b, ok := m["use_web"].(bool); ok {
 						prof.UseWeb = b
 					}
 					if v, ok := m["max_fanout"].(float64); ok {
 						prof.MaxFanout = int(v)
 					}
+					if v, ok := m["max_concurrency"].(float64); ok {
+						prof.MaxConcurrency = int(v)
+					}
 					if v, ok := m["vector_gate"].(float64); ok {
 						prof.VectorGate = v
 					}
@@ -366,6 +491,57 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 					if v, ok := m["per_retriever_top_k"].(float64); ok {
 						prof.PerRetrieverTopK = int(v)
 					}
+					if v, ok := m["min_successful_retrievers"].(float64); ok {
+						prof.MinSuccessfulRetrievers = int(v)
+					}
+					if cascade, ok := m["cascade"].(map[string]any); ok {
+						prof.Cascade = parseCascadeConfig(cascade)
+					}
+					if auto, ok := m["auto_top_k"].(map[string]any); ok {
+						if b, ok := auto["enable"].(bool); ok {
+							prof.AutoTopK.Enable = b
+						}
+						if v, ok := auto["min_k"].(float64); ok {
+							prof.AutoTopK.MinK = int(v)
+						}
+						if v, ok := auto["max_k"].(float64); ok {
+							prof.AutoTopK.MaxK = int(v)
+						}
+						if v, ok := auto["gap_threshold"].(float64); ok {
+							prof.AutoTopK.GapThreshold = v
+						}
+					}
+					if v, ok := m["min_results"].(float64); ok {
+						prof.MinResults = int(v)
+					}
+					if b, ok := m["expand_parents"].(bool); ok {
+						prof.ExpandParents = b
+					}
+					if arr, ok := m["languages"].([]any); ok {
+						for _, v := range arr {
+							if s, ok := v.(string); ok {
+								prof.Languages = append(prof.Languages, s)
+							}
+						}
+					}
+					if backfill, ok := m["backfill"].(map[string]any); ok {
+						if v, ok := backfill["threshold_step"].(float64); ok {
+							prof.Backfill.ThresholdStep = v
+						}
+						if v, ok := backfill["max_steps"].(float64); ok {
+							prof.Backfill.MaxSteps = int(v)
+						}
+						if arr, ok := backfill["retrievers"].([]any); ok {
+							for _, v := range arr {
+								if s, ok := v.(string); ok {
+									prof.Backfill.Retrievers = append(prof.Backfill.Retrievers, s)
+								}
+							}
+						}
+					}
+					if e, ok := m["embedding"].(map[string]any); ok {
+						prof.Embedding = parseEmbeddingOverride(e)
+					}
 					pc.RetrievalProfiles = append(pc.RetrievalProfiles, prof)
 				}
 			}
@@ -397,6 +573,17 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 					pc.Post.Rerank.APIKey = s
 				}
 			}
+			if mmr, ok := post["mmr"].(map[string]any); ok {
+				if b, ok := mmr["enable"].(bool); ok {
+					pc.Post.MMR.Enable = b
+				}
+				if f, ok := mmr["lambda"].(float64); ok {
+					pc.Post.MMR.Lambda = f
+				}
+				if f, ok := mmr["top_n"].(float64); ok {
+					pc.Post.MMR.TopN = int(f)
+				}
+			}
 			if cmp, ok := post["compress"].(map[string]any); ok {
 				if b, ok := cmp["enable"].(bool); ok {
 					pc.Post.Compress.Enable = b
@@ -407,6 +594,18 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 				if f, ok := cmp["target_ratio"].(float64); ok {
 					pc.Post.Compress.TargetRatio = f
 				}
+				if s, ok := cmp["mode"].(string); ok {
+					pc.Post.Compress.Mode = s
+				}
+				if f, ok := cmp["concurrency"].(float64); ok {
+					pc.Post.Compress.Concurrency = int(f)
+				}
+				if f, ok := cmp["timeout_ms"].(float64); ok {
+					pc.Post.Compress.TimeoutMs = int(f)
+				}
+				if f, ok := cmp["max_tokens"].(float64); ok {
+					pc.Post.Compress.MaxTokens = int(f)
+				}
 			}
 		}
 
@@ -426,6 +625,23 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 				if f, ok := ev["incorrect"].(float64); ok {
 					pc.CRAG.Evaluator.Incorrect = f
 				}
+				if byIntent, ok := ev["thresholds_by_intent"].(map[string]any); ok {
+					pc.CRAG.Evaluator.ThresholdsByIntent = map[string]config.CRAGThresholds{}
+					for intent, v := range byIntent {
+						th, ok := v.(map[string]any)
+						if !ok {
+							continue
+						}
+						var t config.CRAGThresholds
+						if f, ok := th["correct"].(float64); ok {
+							t.Correct = f
+						}
+						if f, ok := th["incorrect"].(float64); ok {
+							t.Incorrect = f
+						}
+						pc.CRAG.Evaluator.ThresholdsByIntent[intent] = t
+					}
+				}
 			}
 			if b, ok := crag["strict"].(bool); ok {
 				pc.CRAG.Strict = b
@@ -436,6 +652,15 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 			if v, ok := crag["max_iters"].(float64); ok {
 				pc.CRAG.MaxIters = int(v)
 			}
+			if v, ok := crag["rewrite_variants"].(float64); ok {
+				pc.CRAG.RewriteVariants = int(v)
+			}
+			if v, ok := crag["max_web_calls"].(float64); ok {
+				pc.CRAG.MaxWebCalls = int(v)
+			}
+			if v, ok := crag["max_combined_results"].(float64); ok {
+				pc.CRAG.MaxCombinedResults = int(v)
+			}
 		}
 
 		// session
@@ -447,6 +672,15 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 			if v, ok := sess["ttl_seconds"].(float64); ok {
 				pc.Session.TTLSeconds = int(v)
 			}
+			if v, ok := sess["pool_size"].(float64); ok {
+				pc.Session.PoolSize = int(v)
+			}
+			if v, ok := sess["reconnect_backoff_ms"].(float64); ok {
+				pc.Session.ReconnectBackoffMs = int(v)
+			}
+			if v, ok := sess["max_tokens"].(float64); ok {
+				pc.Session.MaxTokens = int(v)
+			}
 			if r, ok := sess["redis"].(map[string]any); ok {
 				pc.Session.Redis = map[string]interface{}{}
 				for k, v := range r {
@@ -455,6 +689,30 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 			}
 		}
 
+		// retrieval caches
+		if cache, ok := pipelineConfig["cache"].(map[string]any); ok {
+			pc.Cache = &config.CacheConfig{
+				L1:     parseCacheLayerConfig(cache["l1"]),
+				L2:     parseCacheLayerConfig(cache["l2"]),
+				Gating: parseCacheLayerConfig(cache["gating"]),
+			}
+			if sem, ok := cache["semantic"].(map[string]any); ok {
+				pc.Cache.Semantic = &config.SemanticCacheConfig{}
+				if v, ok := sem["enable"].(bool); ok {
+					pc.Cache.Semantic.Enable = v
+				}
+				if v, ok := sem["threshold"].(float64); ok {
+					pc.Cache.Semantic.Threshold = v
+				}
+				if v, ok := sem["max_entries"].(float64); ok {
+					pc.Cache.Semantic.MaxEntries = int(v)
+				}
+				if v, ok := sem["cache_answer"].(bool); ok {
+					pc.Cache.Semantic.CacheAnswer = v
+				}
+			}
+		}
+
 		// http defaults
 		if httpCfg, ok := pipelineConfig["http"].(map[string]any); ok {
 			pc.HTTP = &config.HTTPClientConfig{}
@@ -485,6 +743,128 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 			}
 		}
 
+		// aggregated metrics endpoint
+		if m, ok := pipelineConfig["metrics"].(map[string]any); ok {
+			pc.Metrics = &config.MetricsConfig{}
+			if s, ok := m["addr"].(string); ok {
+				pc.Metrics.Addr = s
+			}
+			if v, ok := m["latency_window"].(float64); ok {
+				pc.Metrics.LatencyWindow = int(v)
+			}
+		}
+
+		// keyword extraction for the keyword reranker and sparse rewrites
+		if kw, ok := pipelineConfig["keywords"].(map[string]any); ok {
+			pc.Keywords = &config.KeywordConfig{}
+			if s, ok := kw["stopwords_file"].(string); ok {
+				pc.Keywords.StopwordsFile = s
+			}
+			if arr, ok := kw["stopwords"].([]any); ok {
+				for _, v := range arr {
+					if s, ok := v.(string); ok {
+						pc.Keywords.Stopwords = append(pc.Keywords.Stopwords, s)
+					}
+				}
+			}
+			if b, ok := kw["stem"].(bool); ok {
+				pc.Keywords.Stem = b
+			}
+		}
+
+		// prompt template overrides of the LLM stages
+		if pr, ok := pipelineConfig["prompts"].(map[string]any); ok {
+			pc.Prompts = &config.PromptsConfig{}
+			if s, ok := pr["dir"].(string); ok {
+				pc.Prompts.Dir = s
+			}
+			if m, ok := pr["templates"].(map[string]any); ok {
+				pc.Prompts.Templates = make(map[string]string, len(m))
+				for name, v := range m {
+					if s, ok := v.(string); ok {
+						pc.Prompts.Templates[name] = s
+					}
+				}
+			}
+		}
+
+		// query language detection and routing
+		if lang, ok := pipelineConfig["language"].(map[string]any); ok {
+			pc.Language = &config.LanguageConfig{}
+			if b, ok := lang["enable"].(bool); ok {
+				pc.Language.Enable = b
+			}
+			if s, ok := lang["fallback"].(string); ok {
+				pc.Language.Fallback = s
+			}
+		}
+
+		// per-stage deadlines
+		if timeouts, ok := pipelineConfig["timeouts"].(map[string]any); ok {
+			pc.Timeouts = &config.StageTimeoutsConfig{}
+			if v, ok := timeouts["retrieval_ms"].(float64); ok {
+				pc.Timeouts.RetrievalMs = int(v)
+			}
+			if v, ok := timeouts["rerank_ms"].(float64); ok {
+				pc.Timeouts.RerankMs = int(v)
+			}
+			if v, ok := timeouts["compress_ms"].(float64); ok {
+				pc.Timeouts.CompressMs = int(v)
+			}
+			if v, ok := timeouts["crag_ms"].(float64); ok {
+				pc.Timeouts.CRAGMs = int(v)
+			}
+		}
+
+		// pre-retrieve HyDE and translation
+		if pre, ok := pipelineConfig["pre_retrieve"].(map[string]any); ok {
+			pc.PreRetrieve = &config.PreRetrieveConfig{Provider: pre_retrieve.PROVIDER_TYPE_DEFAULT}
+			if s, ok := pre["provider"].(string); ok && s != "" {
+				pc.PreRetrieve.Provider = s
+			}
+			if hyde, ok := pre["hyde"].(map[string]any); ok {
+				h := &pc.PreRetrieve.HyDE
+				if b, ok := hyde["enabled"].(bool); ok {
+					h.Enabled = b
+				}
+				if v, ok := hyde["min_query_length"].(float64); ok {
+					h.MinQueryLength = int(v)
+				}
+				if v, ok := hyde["generated_doc_length"].(float64); ok {
+					h.GeneratedDocLength = int(v)
+				}
+				if b, ok := hyde["enable_perplexity_check"].(bool); ok {
+					h.EnablePerplexityCheck = b
+				}
+				if s, ok := hyde["perplexity_endpoint"].(string); ok {
+					h.PerplexityEndpoint = s
+				}
+				if v, ok := hyde["max_perplexity"].(float64); ok {
+					h.MaxPerplexity = v
+				}
+				if b, ok := hyde["enable_nli_guardrail"].(bool); ok {
+					h.EnableNLIGuardrail = b
+				}
+				if s, ok := hyde["nli_endpoint"].(string); ok {
+					h.NLIEndpoint = s
+				}
+				if v, ok := hyde["min_entailment"].(float64); ok {
+					h.MinEntailment = v
+				}
+				if v, ok := hyde["min_quality_score"].(float64); ok {
+					h.MinQualityScore = v
+				}
+			}
+			if tr, ok := pre["translation"].(map[string]any); ok {
+				if b, ok := tr["enabled"].(bool); ok {
+					pc.PreRetrieve.Translation.Enabled = b
+				}
+				if s, ok := tr["target_language"].(string); ok {
+					pc.PreRetrieve.Translation.TargetLanguage = s
+				}
+			}
+		}
+
 		c.config.Pipeline = pc
 	}
 
@@ -529,6 +909,112 @@ func (c *RAGConfig) ParseConfig(cfg map[string]any) error {
 	return nil
 }
 
+// parseCacheLayerConfig parses one layer of pipeline.cache; it returns nil when the layer is absent
+func parseCacheLayerConfig(raw any) *config.CacheLayerConfig {
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil
+	}
+	layer := &config.CacheLayerConfig{}
+	if v, ok := m["enable"].(bool); ok {
+		layer.Enable = v
+	}
+	if v, ok := m["max_entries"].(float64); ok {
+		layer.MaxEntries = int(v)
+	}
+	if v, ok := m["ttl_seconds"].(float64); ok {
+		layer.TTLSeconds = int(v)
+	}
+	if s, ok := m["store"].(string); ok {
+		layer.Store = s
+	}
+	if s, ok := m["mode"].(string); ok {
+		layer.Mode = s
+	}
+	if v, ok := m["cache_empty"].(bool); ok {
+		layer.CacheEmpty = v
+	}
+	if v, ok := m["empty_ttl_seconds"].(float64); ok {
+		layer.EmptyTTLSeconds = int(v)
+	}
+	if r, ok := m["redis"].(map[string]any); ok {
+		layer.Redis = map[string]interface{}{}
+		for k, v := range r {
+			layer.Redis[k] = v
+		}
+	}
+	return layer
+}
+
+// parseHeaders parses a map of header names to string values, skipping other values
+func parseHeaders(m map[string]any) map[string]string {
+	headers := make(map[string]string, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			headers[k] = s
+		}
+	}
+	return headers
+}
+
+// parseEmbeddingOverride parses the embedding of a retriever or a retrieval profile
+func parseEmbeddingOverride(m map[string]any) *config.EmbeddingConfig {
+	ec := &config.EmbeddingConfig{}
+	if s, ok := m["kind"].(string); ok {
+		ec.Kind = s
+	}
+	if s, ok := m["provider"].(string); ok {
+		ec.Provider = s
+	}
+	if s, ok := m["api_key"].(string); ok {
+		ec.APIKey = s
+	}
+	if s, ok := m["base_url"].(string); ok {
+		ec.BaseURL = s
+	}
+	if s, ok := m["model"].(string); ok {
+		ec.Model = s
+	}
+	if v, ok := m["dimensions"].(float64); ok {
+		ec.Dimensions = int(v)
+	}
+	if headers, ok := m["headers"].(map[string]any); ok {
+		ec.Headers = parseHeaders(headers)
+	}
+	return ec
+}
+
+// parseCascadeConfig parses the cascade of a retrieval profile
+func parseCascadeConfig(m map[string]any) config.CascadeConfig {
+	cascade := config.CascadeConfig{}
+	if v, ok := m["enable"].(bool); ok {
+		cascade.Enable = v
+	}
+	if v, ok := m["latency_budget_ms"].(float64); ok {
+		cascade.LatencyBudgetMs = int(v)
+	}
+	stage := func(raw any) config.CascadeStageConfig {
+		stageCfg := config.CascadeStageConfig{}
+		sm, ok := raw.(map[string]any)
+		if !ok {
+			return stageCfg
+		}
+		if s, ok := sm["retriever"].(string); ok {
+			stageCfg.Retriever = s
+		}
+		if v, ok := sm["top_k"].(float64); ok {
+			stageCfg.TopK = int(v)
+		}
+		if s, ok := sm["mode"].(string); ok {
+			stageCfg.Mode = s
+		}
+		return stageCfg
+	}
+	cascade.Stage1 = stage(m["stage1"])
+	cascade.Stage2 = stage(m["stage2"])
+	return cascade
+}
+
 func normalizeKey(s string) string {
 	if s == "" {
 		return s
@@ -543,6 +1029,41 @@ func normalizeKey(s string) string {
 	return strings.ToLower(string(b))
 }
 
+// metricsShutdownTimeout bounds how long a replaced metrics endpoint may take to finish
+// in-flight scrapes before it is closed
+const metricsShutdownTimeout = 2 * time.Second
+
+var (
+	metricsEndpointMu sync.Mutex
+	// metricsEndpoint serves the aggregator of the most recently built server
+	metricsEndpoint *metrics.Endpoint
+)
+
+// serveMetrics shuts down the metrics endpoint of a previously built server, so a rebuilt
+// server can listen on the same address, and serves aggregator on addr unless addr is empty.
+func serveMetrics(aggregator *metrics.Aggregator, addr string) {
+	metricsEndpointMu.Lock()
+	defer metricsEndpointMu.Unlock()
+	if metricsEndpoint != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+		if err := metricsEndpoint.Shutdown(ctx); err != nil {
+			api.LogWarnf("rag: shutting down the previous metrics endpoint: %v", err)
+		}
+		cancel()
+		metricsEndpoint = nil
+	}
+	if addr == "" {
+		return
+	}
+	endpoint, err := aggregator.Serve(addr)
+	if err != nil {
+		api.LogWarnf("rag: metrics endpoint disabled: %v", err)
+		return
+	}
+	metricsEndpoint = endpoint
+	api.LogInfof("rag: serving retrieval metrics on %s", addr)
+}
+
 func (c *RAGConfig) NewServer(serverName string) (*common.MCPServer, error) {
 	mcpServer := common.NewMCPServer(
 		serverName,
@@ -555,12 +1076,25 @@ func (c *RAGConfig) NewServer(serverName string) (*common.MCPServer, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create rag client failed, err: %w", err)
 	}
+	metricsAddr := ""
+	if pc := c.config.Pipeline; pc != nil && pc.Metrics != nil {
+		metricsAddr = pc.Metrics.Addr
+	}
+	serveMetrics(ragClient.MetricsAggregator(), metricsAddr)
 
 	// Knowledge Base Management Tools
 	mcpServer.AddTool(
 		mcp.NewToolWithRawSchema("create-chunks-from-text", "Process and segment input text into semantic chunks for knowledge base ingestion", GetCreateChunkFromTextSchema()),
 		HandleCreateChunkFromText(ragClient),
 	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("upsert-chunks-from-text", "Chunk and store a document under a stable key, replacing the chunks stored for that key before; unchanged documents are skipped", GetUpsertChunksFromTextSchema()),
+		HandleUpsertChunksFromText(ragClient),
+	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("ingest-from-url", "Fetch a web page, extract its readable text and ingest it into the knowledge base", GetCreateChunksFromURLSchema()),
+		HandleCreateChunksFromURL(ragClient),
+	)
 
 	// Chunk Management Tools
 	mcpServer.AddTool(
@@ -571,18 +1105,64 @@ func (c *RAGConfig) NewServer(serverName string) (*common.MCPServer, error) {
 		mcp.NewToolWithRawSchema("delete-chunk", "Remove a specific knowledge chunk from the database using its unique identifier", GetDeleteChunkSchema()),
 		HandleDeleteChunk(ragClient),
 	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("delete-chunks-by-filter", "Remove every knowledge chunk whose metadata matches the given filter, e.g. all chunks of one document", GetDeleteChunksByFilterSchema()),
+		HandleDeleteChunksByFilter(ragClient),
+	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("stats", "Report the number of knowledge chunks, distinct titles, embedding dimension and vector store details", GetStatsSchema()),
+		HandleStats(ragClient),
+	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("health", "Probe the vector store, embedding and LLM providers and report the status and latency of each", GetHealthSchema()),
+		HandleHealth(ragClient),
+	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("export-chunks", "Export all knowledge chunks with their metadata and vectors as JSONL for backup or migration", GetExportChunksSchema()),
+		HandleExportChunks(ragClient),
+	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("import-chunks", "Import knowledge chunks from JSONL produced by export-chunks, optionally re-embedding them", GetImportChunksSchema()),
+		HandleImportChunks(ragClient),
+	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("reindex", "Re-embed all knowledge chunks with the current embedding model into a new collection and switch to it until restart (update vectordb.collection to keep it); rerun to resume", GetReindexSchema()),
+		HandleReindex(ragClient),
+	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("reindex-collection", "Re-embed the active collection in place with the current embedding model in the background, recreating it when the dimension changed; start, check or cancel it", GetReindexCollectionSchema()),
+		HandleReindexCollection(ragClient),
+	)
 
 	// Semantic Search Tool
 	mcpServer.AddTool(
 		mcp.NewToolWithRawSchema("search-chunks", "Perform semantic search across knowledge chunks using natural language query", GetSearchSchema()),
 		HandleSearch(ragClient),
 	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("batch-search-chunks", "Perform semantic search for several queries in one call, embedding them in a batch; a failed query reports its error without failing the others", GetBatchSearchSchema()),
+		HandleBatchSearch(ragClient),
+	)
 
 	// Intelligent Q&A Tool
 	mcpServer.AddTool(
 		mcp.NewToolWithRawSchema("chat", "Answer user questions by retrieving relevant knowledge from the database and generating responses using RAG-enhanced LLM", GetChatSchema()),
 		HandleChat(ragClient),
 	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("chat-stream", "Answer user questions like chat, streaming the answer as progress notifications while the LLM generates it", GetChatStreamSchema()),
+		HandleChatStream(ragClient),
+	)
+
+	// Pipeline Explain Tool
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("explain", "Run the retrieval pipeline for a query without generating an answer and return a per-stage trace (profile, router, gating, retrievers, fusion, rerank, compression, CRAG). LLM-based pipeline stages such as pre-retrieve, compression and CRAG still call the LLM; the run is not recorded in metrics or gating feedback", GetExplainSchema()),
+		HandleExplain(ragClient),
+	)
+	mcpServer.AddTool(
+		mcp.NewToolWithRawSchema("explain-query", "Run the retrieval pipeline for a query and return its per-stage trace as JSON, without rendering the answer prompt or calling the LLM", GetExplainSchema()),
+		HandleExplainQuery(ragClient),
+	)
 
 	return mcpServer, nil
 }
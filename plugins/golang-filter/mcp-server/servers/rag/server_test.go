@@ -1,10 +1,15 @@
 package rag
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"testing"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
 	"gopkg.in/yaml.v3"
 )
 
@@ -94,3 +99,34 @@ func TestRAGConfig_ParseConfig(t *testing.T) {
 	t.Logf("config yaml: %s", string(yaml))
 	fmt.Printf("\n%s", string(yaml))
 }
+
+func TestServeMetrics_ReplacesEndpoint(t *testing.T) {
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	previous := metrics.NewAggregator(0)
+	serveMetrics(previous, addr)
+	// A rebuilt server takes over the same address with its own aggregator
+	rebuilt := metrics.NewAggregator(0)
+	rebuilt.Observe(&metrics.RetrievalMetrics{TotalLatencyMs: 5, Success: true})
+	serveMetrics(rebuilt, addr)
+	defer serveMetrics(nil, "")
+
+	resp, err := http.Get("http://" + addr + "/metrics/json")
+	if err != nil {
+		t.Fatalf("GET /metrics/json error = %v", err)
+	}
+	defer resp.Body.Close()
+	var snapshot metrics.AggregateSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		t.Fatalf("decode /metrics/json: %v", err)
+	}
+	if snapshot.QueryCount != 1 {
+		t.Errorf("query count = %d, want 1 from the rebuilt server's aggregator", snapshot.QueryCount)
+	}
+}
@@ -5,6 +5,7 @@ import (
     "sync"
     "time"
 
+    "github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
     "github.com/google/uuid"
 )
 
@@ -37,14 +38,23 @@ type SessionStore interface {
 
 // MemSessionStore manages sessions in memory.
 type MemSessionStore struct {
-    mu       sync.RWMutex
-    sessions map[string]*Session
+    mu        sync.RWMutex
+    sessions  map[string]*Session
+    maxTokens int
 }
 
 func NewMemSessionStore() *MemSessionStore {
     return &MemSessionStore{sessions: make(map[string]*Session)}
 }
 
+// NewMemSessionStoreWithTokenBudget creates a store that prunes each session's oldest
+// messages once the history exceeds maxTokens (0 disables pruning).
+func NewMemSessionStoreWithTokenBudget(maxTokens int) *MemSessionStore {
+    m := NewMemSessionStore()
+    m.maxTokens = maxTokens
+    return m
+}
+
 func (m *MemSessionStore) Create() *Session {
     s := &Session{ID: newID(), CreatedAt: time.Now(), Messages: []ChatMessage{}}
     m.mu.Lock()
@@ -104,11 +114,38 @@ func (m *MemSessionStore) AddMessage(id string, msg ChatMessage) bool {
     m.mu.Lock()
     s, ok := m.sessions[id]
     if ok {
-        s.Messages = append(s.Messages, msg)
+        s.Messages = pruneMessages(append(s.Messages, msg), m.maxTokens)
     }
     m.mu.Unlock()
     return ok
 }
 
+// messageTokenOverhead approximates the per-message tokens spent on role and framing.
+const messageTokenOverhead = 4
+
+// estimateTokens approximates the token count of text without a tokenizer.
+func estimateTokens(text string) int {
+    return llm.EstimateTokens(text)
+}
+
+func messageTokens(msg ChatMessage) int {
+    return estimateTokens(msg.Content) + messageTokenOverhead
+}
+
+// pruneMessages drops the oldest messages until the estimated tokens of the history fit
+// maxTokens. The newest message is always kept. maxTokens <= 0 disables pruning.
+func pruneMessages(msgs []ChatMessage, maxTokens int) []ChatMessage {
+    if maxTokens <= 0 || len(msgs) <= 1 { return msgs }
+    total := 0
+    for _, msg := range msgs { total += messageTokens(msg) }
+    start := 0
+    for total > maxTokens && start < len(msgs)-1 {
+        total -= messageTokens(msgs[start])
+        start++
+    }
+    if start == 0 { return msgs }
+    return append([]ChatMessage(nil), msgs[start:]...)
+}
+
 // newID creates a lightweight random id. Falls back to timestamp if UUID not available at build time.
 func newID() string { return uuid.New().String() }
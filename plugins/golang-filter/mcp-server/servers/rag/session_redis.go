@@ -11,25 +11,47 @@ import (
     "github.com/google/uuid"
 )
 
-// RedisSessionStore persists sessions in Redis via common.RedisClient.
+// RedisSessionStore persists sessions in Redis through one pooled common.RedisClient.
 // Data model:
 //  - key prefix+"session:"+id => JSON(Session) with TTL
 //  - key prefix+"index" => JSON array of IDs (best-effort index)
 type RedisSessionStore struct {
-    rc     *common.RedisClient
-    prefix string
-    ttl    time.Duration
+    pool      *redisPool
+    prefix    string
+    ttl       time.Duration
+    maxTokens int
 }
 
 func NewRedisSessionStore(cfg *config.SessionConfig) (*RedisSessionStore, error) {
+    rcfg, err := common.ParseRedisConfig(cfg.Redis)
+    if err != nil { return nil, err }
+    dial := redisDialer(rcfg, cfg.PoolSize, time.Duration(cfg.ReconnectBackoffMs)*time.Millisecond)
+    return newRedisSessionStore(cfg, dial), nil
+}
+
+func newRedisSessionStore(cfg *config.SessionConfig, dial func() (redisConn, error)) *RedisSessionStore {
     ttl := time.Duration(cfg.TTLSeconds) * time.Second
     if ttl <= 0 { ttl = 24 * time.Hour }
     prefix := "rag:sess:"
-    rcfg, err := common.ParseRedisConfig(cfg.Redis)
-    if err != nil { return nil, err }
-    rcli, err := common.NewRedisClient(rcfg)
-    if err != nil { return nil, err }
-    return &RedisSessionStore{rc: rcli, prefix: prefix, ttl: ttl}, nil
+    pool := newRedisPool(dial)
+    return &RedisSessionStore{pool: pool, prefix: prefix, ttl: ttl, maxTokens: cfg.MaxTokens}
+}
+
+// Close releases the pooled Redis connections.
+func (s *RedisSessionStore) Close() error { return s.pool.Close() }
+
+func (s *RedisSessionStore) eval(script string, keys []string, args []interface{}) (interface{}, error) {
+    var result interface{}
+    err := s.pool.do(func(c redisConn) error {
+        v, err := c.Eval(script, len(keys), keys, args)
+        result = v
+        return err
+    })
+    return result, err
+}
+
+func (s *RedisSessionStore) set(key, value string) error {
+    return s.pool.do(func(c redisConn) error { return c.Set(key, value, s.ttl) })
 }
 
 func (s *RedisSessionStore) idxKey() string { return s.prefix + "idx" }
@@ -52,9 +74,9 @@ redis.call('ZADD', idx_key, score, id)
 return 1`
     keys := []string{s.sessKey(id), s.idxKey()}
     args := []interface{}{string(b), int64(s.ttl / time.Second), time.Now().Unix(), id}
-    if _, err := s.rc.Eval(script, len(keys), keys, args); err != nil {
+    if _, err := s.eval(script, keys, args); err != nil {
         // fallback: best-effort set
-        _ = s.rc.Set(s.sessKey(id), string(b), s.ttl)
+        _ = s.set(s.sessKey(id), string(b))
     }
     return sess
 }
@@ -65,7 +87,7 @@ local sess_key = KEYS[1]
 if redis.call('EXISTS', sess_key) == 0 then return nil end
 return redis.call('HGETALL', sess_key)`
     keys := []string{s.sessKey(id)}
-    v, err := s.rc.Eval(script, len(keys), keys, nil)
+    v, err := s.eval(script, keys, nil)
     if err != nil || v == nil { return nil, false }
     m, ok := toHash(v)
     if !ok { return nil, false }
@@ -91,7 +113,7 @@ redis.call('ZREM', idx_key, id)
 return 1`
     keys := []string{s.sessKey(id), s.idxKey()}
     args := []interface{}{id}
-    if _, err := s.rc.Eval(script, len(keys), keys, args); err != nil {
+    if _, err := s.eval(script, keys, args); err != nil {
         return false
     }
     return true
@@ -104,7 +126,8 @@ func (s *RedisSessionStore) List() []*Session {
 func (s *RedisSessionStore) AddMessage(id string, msg ChatMessage) bool {
     st, ok := s.Get(id)
     if !ok || st == nil { return false }
-    st.Messages = append(st.Messages, msg)
+    // prune before persisting so stored histories stay within the token budget
+    st.Messages = pruneMessages(append(st.Messages, msg), s.maxTokens)
     msgs, _ := json.Marshal(st.Messages)
     script := `
 local sess_key = KEYS[1]
@@ -118,7 +141,7 @@ redis.call('ZADD', idx_key, now, redis.call('HGET', sess_key, 'id'))
 return 1`
     keys := []string{s.sessKey(id), s.idxKey()}
     args := []interface{}{string(msgs), int64(s.ttl / time.Second), time.Now().Unix()}
-    if _, err := s.rc.Eval(script, len(keys), keys, args); err != nil {
+    if _, err := s.eval(script, keys, args); err != nil {
         _ = fmt.Errorf("redis update failed: %v", err)
         return false
     }
@@ -137,7 +160,7 @@ local stop = tonumber(ARGV[2])
 return redis.call('ZREVRANGE', idx_key, start, stop)`
     keys := []string{s.idxKey()}
     args := []interface{}{offset, offset + limit - 1}
-    v, err := s.rc.Eval(script, len(keys), keys, args)
+    v, err := s.eval(script, keys, args)
     if err != nil { return []*Session{} }
     ids, ok := toSliceString(v)
     if !ok || len(ids) == 0 { return []*Session{} }
@@ -168,7 +191,7 @@ end
 return rem`
     keys := []string{s.idxKey()}
     args := []interface{}{s.prefix, max}
-    _, err := s.rc.Eval(script, len(keys), keys, args)
+    _, err := s.eval(script, keys, args)
     return err
 }
 
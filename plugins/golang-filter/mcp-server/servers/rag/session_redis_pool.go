@@ -0,0 +1,86 @@
+package rag
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-session/common"
+)
+
+const (
+	defaultRedisPoolSize         = 4
+	defaultRedisReconnectBackoff = 100 * time.Millisecond
+	maxRedisReconnectBackoff     = 2 * time.Second
+	// redisMaxRetries is how many times go-redis retries a command failing with a network error
+	redisMaxRetries = 2
+)
+
+// redisConn is the subset of common.RedisClient used by the Redis stores.
+type redisConn interface {
+	Eval(script string, numKeys int, keys []string, args []interface{}) (interface{}, error)
+	Set(key string, value string, expiration time.Duration) error
+	Close() error
+}
+
+// redisDialer returns a dial function opening a common.RedisClient whose go-redis pool
+// holds at most size connections (<= 0 => defaultRedisPoolSize) and retries commands
+// failing with a network error, backing off from backoff (<= 0 =>
+// defaultRedisReconnectBackoff) up to maxRedisReconnectBackoff.
+func redisDialer(cfg *common.RedisConfig, size int, backoff time.Duration) func() (redisConn, error) {
+	if size <= 0 {
+		size = defaultRedisPoolSize
+	}
+	if backoff <= 0 {
+		backoff = defaultRedisReconnectBackoff
+	}
+	cfg.SetPool(size, redisMaxRetries, backoff, maxRedisReconnectBackoff)
+	return func() (redisConn, error) { return common.NewRedisClient(cfg) }
+}
+
+// redisPool lazily opens the single client a store shares between its callers; the
+// connection pooling, bounding and reconnects happen in the client's go-redis pool. A
+// failed dial is retried on the next call.
+type redisPool struct {
+	dial func() (redisConn, error)
+
+	mu   sync.Mutex
+	conn redisConn
+}
+
+func newRedisPool(dial func() (redisConn, error)) *redisPool {
+	return &redisPool{dial: dial}
+}
+
+// do runs fn on the shared client, opening it on first use.
+func (p *redisPool) do(fn func(redisConn) error) error {
+	conn, err := p.get()
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (p *redisPool) get() (redisConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		conn, err := p.dial()
+		if err != nil {
+			return nil, err
+		}
+		p.conn = conn
+	}
+	return p.conn, nil
+}
+
+// Close closes the shared client, if it was opened.
+func (p *redisPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
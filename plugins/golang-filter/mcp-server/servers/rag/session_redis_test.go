@@ -0,0 +1,164 @@
+package rag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+)
+
+// fakeRedisConn answers every Eval with reply
+type fakeRedisConn struct {
+	mu     sync.Mutex
+	args   [][]interface{}
+	reply  interface{}
+	closed atomic.Bool
+	evals  atomic.Int32
+}
+
+func (f *fakeRedisConn) Eval(script string, numKeys int, keys []string, args []interface{}) (interface{}, error) {
+	f.evals.Add(1)
+	f.mu.Lock()
+	f.args = append(f.args, args)
+	f.mu.Unlock()
+	return f.reply, nil
+}
+
+func (f *fakeRedisConn) Set(key string, value string, expiration time.Duration) error { return nil }
+
+func (f *fakeRedisConn) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+type fakeRedisDialer struct {
+	mu    sync.Mutex
+	conns []*fakeRedisConn
+	reply interface{}
+	fail  int
+}
+
+func (d *fakeRedisDialer) dial() (redisConn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fail > 0 {
+		d.fail--
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	conn := &fakeRedisConn{reply: d.reply}
+	d.conns = append(d.conns, conn)
+	return conn, nil
+}
+
+func TestRedisPool_SharesOneClient(t *testing.T) {
+	dialer := &fakeRedisDialer{reply: int64(1)}
+	store := newRedisSessionStore(&config.SessionConfig{PoolSize: 2}, dialer.dial)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !store.Delete("s1") {
+				t.Error("Delete() failed")
+			}
+		}()
+	}
+	wg.Wait()
+	if len(dialer.conns) != 1 || dialer.conns[0].evals.Load() != 10 {
+		t.Fatalf("dialed %d clients, want one shared by every call", len(dialer.conns))
+	}
+
+	if err := store.Close(); err != nil || !dialer.conns[0].closed.Load() {
+		t.Errorf("Close() = %v, want the shared client closed", err)
+	}
+	if !store.Delete("s1") || len(dialer.conns) != 2 {
+		t.Error("a call after Close() should open a new client")
+	}
+}
+
+func TestRedisPool_RetriesFailedDial(t *testing.T) {
+	dialer := &fakeRedisDialer{reply: int64(1), fail: 1}
+	pool := newRedisPool(dialer.dial)
+	run := func() error {
+		return pool.do(func(c redisConn) error {
+			_, err := c.Eval("return 1", 0, nil, nil)
+			return err
+		})
+	}
+	if err := run(); err == nil {
+		t.Fatal("do() expected the dial error")
+	}
+	if err := run(); err != nil || len(dialer.conns) != 1 {
+		t.Errorf("do() = %v after %d dials, want the next call to dial again", err, len(dialer.conns))
+	}
+}
+
+func TestPruneMessages(t *testing.T) {
+	msgs := []ChatMessage{
+		{Role: "user", Content: strings.Repeat("a", 400)},
+		{Role: "assistant", Content: strings.Repeat("b", 400)},
+		{Role: "user", Content: "recent question"},
+	}
+	got := pruneMessages(msgs, 120)
+	if len(got) != 2 || got[0].Content != msgs[1].Content || got[1].Content != "recent question" {
+		t.Errorf("pruneMessages() kept %d messages, want the two most recent", len(got))
+	}
+	if got := pruneMessages(msgs, 0); len(got) != 3 {
+		t.Errorf("pruneMessages() with no budget kept %d, want 3", len(got))
+	}
+	if got := pruneMessages(msgs[:1], 10); len(got) != 1 {
+		t.Errorf("pruneMessages() dropped the only message")
+	}
+	if n := estimateTokens("检索增强生成"); n != 6 {
+		t.Errorf("estimateTokens(CJK) = %d, want 6", n)
+	}
+}
+
+func TestRedisSessionStore_AddMessagePrunesToTokenBudget(t *testing.T) {
+	history := []ChatMessage{
+		{Role: "user", Content: strings.Repeat("old ", 100)},
+		{Role: "assistant", Content: strings.Repeat("older answer ", 30)},
+		{Role: "user", Content: "what about plugins?"},
+	}
+	stored, _ := json.Marshal(history)
+	dialer := &fakeRedisDialer{reply: []interface{}{"id", "s1", "created_at", "1700000000", "messages", string(stored)}}
+	store := newRedisSessionStore(&config.SessionConfig{MaxTokens: 60}, dialer.dial)
+
+	if !store.AddMessage("s1", ChatMessage{Role: "assistant", Content: "Higress supports wasm plugins."}) {
+		t.Fatal("AddMessage() failed")
+	}
+	conn := dialer.conns[0]
+	conn.mu.Lock()
+	persisted := conn.args[len(conn.args)-1][0].(string)
+	conn.mu.Unlock()
+
+	var msgs []ChatMessage
+	if err := json.Unmarshal([]byte(persisted), &msgs); err != nil {
+		t.Fatalf("persisted messages are not JSON: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].Content != "what about plugins?" || msgs[1].Content != "Higress supports wasm plugins." {
+		t.Errorf("persisted %d messages %+v, want only the two most recent", len(msgs), msgs)
+	}
+}
+
+func TestMemSessionStore_AddMessagePrunesToTokenBudget(t *testing.T) {
+	store := NewMemSessionStoreWithTokenBudget(40)
+	sess := store.Create()
+	for i := 0; i < 10; i++ {
+		store.AddMessage(sess.ID, ChatMessage{Role: "user", Content: fmt.Sprintf("message number %d with some padding text", i)})
+	}
+	got, _ := store.Get(sess.ID)
+	if len(got.Messages) == 0 || len(got.Messages) >= 10 {
+		t.Fatalf("kept %d messages, want history pruned to the budget", len(got.Messages))
+	}
+	if last := got.Messages[len(got.Messages)-1].Content; !strings.Contains(last, "number 9") {
+		t.Errorf("last message = %q, want the most recent kept", last)
+	}
+}
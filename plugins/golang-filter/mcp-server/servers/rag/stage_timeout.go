@@ -0,0 +1,72 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// Pipeline stages bounded by pipeline.timeouts, as recorded in the stage_timeouts metrics
+const (
+	STAGE_RETRIEVAL = "retrieval"
+	STAGE_RERANK    = "rerank"
+	STAGE_COMPRESS  = "compress"
+	STAGE_CRAG      = "crag"
+)
+
+// stageTimeout returns the deadline configured for stage, or 0 when it has none
+func (r *RAGClient) stageTimeout(stage string) time.Duration {
+	if r.config.Pipeline == nil || r.config.Pipeline.Timeouts == nil {
+		return 0
+	}
+	t := r.config.Pipeline.Timeouts
+	var ms int
+	switch stage {
+	case STAGE_RETRIEVAL:
+		ms = t.RetrievalMs
+	case STAGE_RERANK:
+		ms = t.RerankMs
+	case STAGE_COMPRESS:
+		ms = t.CompressMs
+	case STAGE_CRAG:
+		ms = t.CRAGMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// runStage runs fn on results under the deadline timeout; a zero timeout runs it directly.
+// ok is false when the deadline passed, or ctx ended, before fn finished: the stage is then
+// skipped and fn, which may still be running, works on a copy of results so it cannot
+// touch them.
+func runStage[T any](ctx context.Context, timeout time.Duration, results []schema.SearchResult, fn func(context.Context, []schema.SearchResult) T) (value T, ok bool) {
+	if timeout <= 0 {
+		return fn(ctx, results), true
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan T, 1)
+	in := cloneResults(results)
+	go func() {
+		done <- fn(ctx, in)
+	}()
+	select {
+	case value = <-done:
+		// A stage giving up on the expired context timed out all the same
+		return value, ctx.Err() == nil
+	case <-ctx.Done():
+		return value, false
+	}
+}
+
+// stageTimeoutError describes a stage skipped at its deadline, for traces
+func stageTimeoutError(stage string, timeout time.Duration) error {
+	return fmt.Errorf("%s stage timed out after %v", stage, timeout)
+}
+
+// stageResults is the outcome of a stage transforming the results
+type stageResults struct {
+	results []schema.SearchResult
+	err     error
+}
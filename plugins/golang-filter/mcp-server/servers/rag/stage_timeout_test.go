@@ -0,0 +1,140 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/crag"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retriever"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// hangingReranker reverses the results once release is closed, ignoring its context.
+// returned is closed when it returns.
+type hangingReranker struct {
+	release  chan struct{}
+	returned chan struct{}
+}
+
+func (h *hangingReranker) Rerank(ctx context.Context, query string, in []schema.SearchResult, topN int) ([]schema.SearchResult, error) {
+	defer close(h.returned)
+	<-h.release
+	slices.Reverse(in)
+	return in, nil
+}
+
+// hangingEvaluator fails once release is closed, ignoring its context, so the abandoned
+// stage ends without running a corrective action. returned is closed when it returns.
+type hangingEvaluator struct {
+	release  chan struct{}
+	returned chan struct{}
+}
+
+func (h *hangingEvaluator) Evaluate(ctx context.Context, query string, contextText string) (float64, crag.Verdict, error) {
+	defer close(h.returned)
+	<-h.release
+	return 0, crag.VerdictIncorrect, errors.New("evaluation released")
+}
+
+func TestRunStage(t *testing.T) {
+	ctx := context.Background()
+	in := []schema.SearchResult{{Document: schema.Document{ID: "a", Content: "a"}}}
+
+	// Without a timeout the stage runs on the results themselves
+	out, ok := runStage(ctx, 0, in, func(ctx context.Context, in []schema.SearchResult) []schema.SearchResult {
+		in[0].Document.Content = "changed"
+		return in
+	})
+	if !ok || out[0].Document.Content != "changed" || in[0].Document.Content != "changed" {
+		t.Errorf("runStage() = %v, %v, want the stage run directly", out, ok)
+	}
+
+	// A stage past its deadline is abandoned and works on a copy
+	in[0].Document.Content = "a"
+	release := make(chan struct{})
+	done := make(chan struct{})
+	start := time.Now()
+	_, ok = runStage(ctx, 10*time.Millisecond, in, func(ctx context.Context, in []schema.SearchResult) []schema.SearchResult {
+		<-release
+		in[0].Document.Content = "changed"
+		close(done)
+		return in
+	})
+	if ok || time.Since(start) > time.Second {
+		t.Errorf("runStage() ok = %v after %v, want a timeout", ok, time.Since(start))
+	}
+	close(release)
+	<-done
+	if in[0].Document.Content != "a" {
+		t.Errorf("abandoned stage modified the results: %v", in)
+	}
+
+	// A stage giving up on the expired context timed out as well
+	_, ok = runStage(ctx, 10*time.Millisecond, in, func(ctx context.Context, in []schema.SearchResult) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if ok {
+		t.Error("runStage() of a stage failing on its deadline should report a timeout")
+	}
+}
+
+func TestRAGClient_StageTimeouts(t *testing.T) {
+	stub := &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+		{Document: schema.Document{ID: "routes", Content: "higress gateway routes traffic"}, Score: 2},
+		{Document: schema.Document{ID: "plugins", Content: "higress wasm plugins extend the proxy"}, Score: 1},
+	}}
+	retriever.Register("timeout_bm25", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return stub, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "timeout_bm25", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"bm25"}, TopK: 5, Threshold: 0.001},
+	}
+	pipeline.DefaultProfile = "default"
+	pipeline.EnablePost = true
+	pipeline.Post = &config.PostConfig{}
+	pipeline.Post.Rerank.Enable = true
+	pipeline.Post.Rerank.Provider = "keyword"
+	pipeline.EnableCRAG = true
+	pipeline.CRAG = &config.CRAGConfig{}
+	pipeline.CRAG.Evaluator.Provider = "llm"
+	pipeline.Timeouts = &config.StageTimeoutsConfig{RerankMs: 20, CRAGMs: 20}
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, &MockLLMProvider{})
+	release := make(chan struct{})
+	reranker := &hangingReranker{release: release, returned: make(chan struct{})}
+	evaluator := &hangingEvaluator{release: release, returned: make(chan struct{})}
+	client.reranker = reranker
+	client.evaluator = evaluator
+	// The abandoned stages keep running past the pipeline, so they must finish before the
+	// next test replaces the logging API they use
+	t.Cleanup(func() {
+		close(release)
+		<-reranker.returned
+		<-evaluator.returned
+	})
+
+	start := time.Now()
+	results, _, m, err := client.runEnhancedPipeline(context.Background(), "higress gateway", RequestOptions{}, nil)
+	if err != nil {
+		t.Fatalf("runEnhancedPipeline() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("pipeline took %v, want the hanging stages skipped at their deadlines", elapsed)
+	}
+	// Neither the reranked order nor the CRAG verdict made it into the results
+	if ids := resultIDs(results); len(ids) != 2 || ids[0] != "routes" || ids[1] != "plugins" {
+		t.Errorf("results = %v, want the fused results", ids)
+	}
+	if !slices.Equal(m.StageTimeouts, []string{STAGE_RERANK, STAGE_CRAG}) || m.CRAGVerdict != "" {
+		t.Errorf("stage timeouts = %v, verdict %q, want rerank and crag timed out", m.StageTimeouts, m.CRAGVerdict)
+	}
+	if s := client.MetricsAggregator().Snapshot(); s.StageTimeouts[STAGE_RERANK] != 1 || s.StageTimeouts[STAGE_CRAG] != 1 {
+		t.Errorf("aggregated stage timeouts = %v", s.StageTimeouts)
+	}
+}
@@ -0,0 +1,339 @@
+package textsplitter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Languages understood by the code splitter.
+const (
+	LanguageGo         = "go"
+	LanguagePython     = "python"
+	LanguageJava       = "java"
+	LanguageJavaScript = "javascript"
+	LanguageTypeScript = "typescript"
+)
+
+// Metadata keys the code splitter sets on each chunk.
+const (
+	MetadataLanguage = "language"
+	MetadataSymbol   = "symbol"
+)
+
+// codeLanguage describes how to find top-level declarations in one language.
+type codeLanguage struct {
+	// declarations match a line starting a top-level unit; the first group is the symbol
+	declarations  []*regexp.Regexp
+	lineComment   string
+	blockComments bool
+	// multilineQuotes are string delimiters that may span lines, longest first
+	multilineQuotes []string
+	// indentScoped languages only start a unit on an unindented line
+	indentScoped bool
+}
+
+var (
+	jsDeclarations = []*regexp.Regexp{
+		regexp.MustCompile(`^(?:export\s+(?:default\s+)?)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`),
+		regexp.MustCompile(`^(?:export\s+(?:default\s+)?)?class\s+([A-Za-z_$][\w$]*)`),
+		regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s+)?(?:function|\([^)]*\)\s*=>|[A-Za-z_$][\w$]*\s*=>)`),
+	}
+
+	codeLanguages = map[string]*codeLanguage{
+		LanguageGo: {
+			declarations: []*regexp.Regexp{
+				regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`),
+				regexp.MustCompile(`^type\s+([A-Za-z_]\w*)`),
+			},
+			lineComment:     "//",
+			blockComments:   true,
+			multilineQuotes: []string{"`"},
+		},
+		LanguagePython: {
+			declarations: []*regexp.Regexp{
+				regexp.MustCompile(`^(?:async\s+)?def\s+([A-Za-z_]\w*)`),
+				regexp.MustCompile(`^class\s+([A-Za-z_]\w*)`),
+			},
+			lineComment:     "#",
+			multilineQuotes: []string{`"""`, `'''`},
+			indentScoped:    true,
+		},
+		LanguageJava: {
+			declarations: []*regexp.Regexp{
+				regexp.MustCompile(`^(?:(?:public|protected|private|abstract|final|static|sealed|non-sealed|strictfp)\s+)*(?:class|interface|enum|record|@interface)\s+([A-Za-z_]\w*)`),
+			},
+			lineComment:     "//",
+			blockComments:   true,
+			multilineQuotes: []string{`"""`},
+		},
+		LanguageJavaScript: {
+			declarations:    jsDeclarations,
+			lineComment:     "//",
+			blockComments:   true,
+			multilineQuotes: []string{"`"},
+		},
+		LanguageTypeScript: {
+			declarations: append([]*regexp.Regexp{
+				regexp.MustCompile(`^(?:export\s+(?:default\s+)?)?(?:declare\s+)?abstract\s+class\s+([A-Za-z_$][\w$]*)`),
+				regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?(?:interface|type|enum|namespace)\s+([A-Za-z_$][\w$]*)`),
+			}, jsDeclarations...),
+			lineComment:     "//",
+			blockComments:   true,
+			multilineQuotes: []string{"`"},
+		},
+	}
+
+	languageAliases = map[string]string{
+		"golang": LanguageGo,
+		"py":     LanguagePython,
+		"js":     LanguageJavaScript,
+		"ts":     LanguageTypeScript,
+	}
+)
+
+// CodeSplitter is a text splitter for source code. It cuts only on top-level declaration
+// boundaries (functions, classes, types) so each unit stays in one chunk together with
+// its leading comments, and falls back to recursive splitting for units longer than
+// ChunkSize. Each chunk records the language and the declared symbol in its metadata.
+type CodeSplitter struct {
+	Language     string
+	ChunkSize    int
+	ChunkOverlap int
+	LenFunc      func(string) int
+
+	lang *codeLanguage
+}
+
+// NewCodeSplitter creates a code splitter for language. Only the chunk size, chunk
+// overlap and length function options are used.
+func NewCodeSplitter(language string, opts ...Option) (CodeSplitter, error) {
+	name := strings.ToLower(strings.TrimSpace(language))
+	if alias, ok := languageAliases[name]; ok {
+		name = alias
+	}
+	lang, ok := codeLanguages[name]
+	if !ok {
+		return CodeSplitter{}, fmt.Errorf("unsupported code splitter language: %q", language)
+	}
+
+	options := DefaultOptions()
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = _defaultTokenChunkSize
+	}
+	if options.ChunkOverlap < 0 || options.ChunkOverlap >= options.ChunkSize {
+		options.ChunkOverlap = 0
+	}
+
+	return CodeSplitter{
+		Language:     name,
+		ChunkSize:    options.ChunkSize,
+		ChunkOverlap: options.ChunkOverlap,
+		LenFunc:      options.LenFunc,
+		lang:         lang,
+	}, nil
+}
+
+// SplitText splits source code into chunks along top-level declarations.
+func (s CodeSplitter) SplitText(text string) ([]string, error) {
+	chunks, _, err := s.SplitTextWithMetadata(text)
+	return chunks, err
+}
+
+// SplitTextWithMetadata splits source code like SplitText and returns the language and
+// symbol of every chunk. Pieces of an oversized unit all carry the unit's symbol.
+func (s CodeSplitter) SplitTextWithMetadata(text string) ([]string, []map[string]any, error) {
+	fallback := NewRecursiveCharacter(
+		WithChunkSize(s.ChunkSize),
+		WithChunkOverlap(s.ChunkOverlap),
+		WithLenFunc(s.LenFunc),
+		WithSeparators([]string{"\n\n", "\n", " ", ""}),
+	)
+
+	chunks := make([]string, 0)
+	metadatas := make([]map[string]any, 0)
+	for _, unit := range s.lang.units(text) {
+		content := strings.TrimSpace(unit.text)
+		if content == "" {
+			continue
+		}
+		pieces := []string{content}
+		if s.LenFunc(content) > s.ChunkSize {
+			var err error
+			if pieces, err = fallback.SplitText(content); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, piece := range pieces {
+			metadata := map[string]any{MetadataLanguage: s.Language}
+			if unit.symbol != "" {
+				metadata[MetadataSymbol] = unit.symbol
+			}
+			chunks = append(chunks, piece)
+			metadatas = append(metadatas, metadata)
+		}
+	}
+	return chunks, metadatas, nil
+}
+
+// codeUnit is a top-level declaration with its leading comments, or the code before
+// the first declaration (package clause, imports), which has no symbol.
+type codeUnit struct {
+	text   string
+	symbol string
+}
+
+// scanState carries tokenizer state from one line to the next.
+type scanState struct {
+	depth   int
+	comment bool
+	quote   string
+}
+
+// units cuts text into top-level units. A unit starts on a declaration line seen outside
+// any brackets, comment or string; comment, annotation and decorator lines directly above
+// it move into the new unit.
+func (l *codeLanguage) units(text string) []codeUnit {
+	var (
+		units   []codeUnit
+		current []string
+		symbol  string
+		lead    int
+		st      scanState
+	)
+	flush := func(lines []string) {
+		if len(lines) > 0 {
+			units = append(units, codeUnit{text: strings.Join(lines, "\n"), symbol: symbol})
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		topLevel := st.depth == 0 && !st.comment && st.quote == ""
+		if topLevel && !(l.indentScoped && startsIndented(line)) {
+			if name, ok := l.declaration(line); ok {
+				head := current[len(current)-lead:]
+				if len(head) < len(current) {
+					flush(current[:len(current)-lead])
+					current = append([]string(nil), head...)
+				}
+				symbol = name
+				lead = 0
+				current = append(current, line)
+				l.scanLine(line, &st)
+				continue
+			}
+		}
+
+		current = append(current, line)
+		switch {
+		case strings.TrimSpace(line) == "":
+			lead = 0
+		case topLevel && l.isLeadLine(line), st.comment && st.depth == 0:
+			lead++
+		default:
+			lead = 0
+		}
+		l.scanLine(line, &st)
+	}
+	flush(current)
+	return units
+}
+
+// declaration reports whether line starts a top-level unit and returns its symbol.
+func (l *codeLanguage) declaration(line string) (string, bool) {
+	for _, re := range l.declarations {
+		if m := re.FindStringSubmatch(line); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// isLeadLine reports whether a top-level line belongs to the declaration below it.
+func (l *codeLanguage) isLeadLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, l.lineComment) ||
+		strings.HasPrefix(trimmed, "@") ||
+		(l.blockComments && strings.HasPrefix(trimmed, "/*"))
+}
+
+// scanLine advances st over line, tracking bracket depth while skipping comments and
+// string literals so brackets inside them are not counted.
+func (l *codeLanguage) scanLine(line string, st *scanState) {
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		switch {
+		case st.comment:
+			if strings.HasPrefix(rest, "*/") {
+				st.comment = false
+				i += 2
+				continue
+			}
+			i++
+		case st.quote != "":
+			if line[i] == '\\' && st.quote != "`" {
+				i += 2
+				continue
+			}
+			if strings.HasPrefix(rest, st.quote) {
+				i += len(st.quote)
+				st.quote = ""
+				continue
+			}
+			i++
+		case strings.HasPrefix(rest, l.lineComment):
+			return
+		case l.blockComments && strings.HasPrefix(rest, "/*"):
+			st.comment = true
+			i += 2
+		default:
+			if q := l.multilineQuote(rest); q != "" {
+				st.quote = q
+				i += len(q)
+				continue
+			}
+			switch line[i] {
+			case '"', '\'':
+				i = skipQuoted(line, i)
+				continue
+			case '{', '(', '[':
+				st.depth++
+			case '}', ')', ']':
+				if st.depth > 0 {
+					st.depth--
+				}
+			}
+			i++
+		}
+	}
+}
+
+func (l *codeLanguage) multilineQuote(s string) string {
+	for _, q := range l.multilineQuotes {
+		if strings.HasPrefix(s, q) {
+			return q
+		}
+	}
+	return ""
+}
+
+// skipQuoted returns the index just past the single-line string literal starting at i.
+func skipQuoted(line string, i int) int {
+	quote := line[i]
+	for j := i + 1; j < len(line); j++ {
+		switch line[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(line)
+}
+
+func startsIndented(line string) bool {
+	return line != "" && (line[0] == ' ' || line[0] == '\t')
+}
@@ -0,0 +1,132 @@
+package textsplitter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleGo = `// Package shapes computes areas.
+package shapes
+
+import (
+	"fmt"
+	"math"
+)
+
+// Circle is a round shape.
+type Circle struct {
+	Radius float64
+}
+
+// Area returns the area of the circle.
+// It ignores negative radii.
+func (c Circle) Area() float64 {
+	if c.Radius < 0 {
+		return 0
+	}
+	return math.Pi * c.Radius * c.Radius
+}
+
+func Describe(c Circle) string {
+	msg := "radius } not a brace"
+	raw := ` + "`{ raw\n}`" + `
+	/* { */
+	return fmt.Sprintf("%s %s %.2f", msg, raw, c.Area())
+}
+`
+
+const samplePython = `"""Shape helpers."""
+import math
+
+
+@dataclass
+class Circle:
+    radius: float
+
+    def area(self):
+        return math.pi * self.radius ** 2
+
+
+def describe(c):
+    doc = """
+def not_a_function():
+"""
+    return f"{doc} {c.area()}"
+
+
+async def fetch(url):
+    return await get(url)
+`
+
+func splitCode(t *testing.T, language, text string, chunkSize int) ([]string, []map[string]any) {
+	t.Helper()
+	splitter, err := NewCodeSplitter(language, WithChunkSize(chunkSize), WithChunkOverlap(0))
+	require.NoError(t, err)
+	chunks, metadatas, err := splitter.SplitTextWithMetadata(text)
+	require.NoError(t, err)
+	require.Len(t, metadatas, len(chunks))
+	return chunks, metadatas
+}
+
+func TestCodeSplitter_GoKeepsFunctionsIntact(t *testing.T) {
+	chunks, metadatas := splitCode(t, "go", sampleGo, 1000)
+
+	require.Len(t, chunks, 4)
+	assert.True(t, strings.HasPrefix(chunks[0], "// Package shapes"))
+	assert.NotContains(t, metadatas[0], MetadataSymbol)
+
+	symbols := []string{"Circle", "Area", "Describe"}
+	for i, symbol := range symbols {
+		assert.Equal(t, symbol, metadatas[i+1][MetadataSymbol])
+		assert.Equal(t, LanguageGo, metadatas[i+1][MetadataLanguage])
+	}
+	assert.Equal(t, "// Area returns the area of the circle.\n// It ignores negative radii.\nfunc (c Circle) Area() float64 {\n\tif c.Radius < 0 {\n\t\treturn 0\n\t}\n\treturn math.Pi * c.Radius * c.Radius\n}", chunks[2])
+	assert.True(t, strings.HasPrefix(chunks[3], "func Describe"))
+	assert.True(t, strings.HasSuffix(chunks[3], "c.Area())\n}"), "braces in strings and comments must not end the function early")
+}
+
+func TestCodeSplitter_PythonKeepsFunctionsIntact(t *testing.T) {
+	chunks, metadatas := splitCode(t, "py", samplePython, 1000)
+
+	require.Len(t, chunks, 4)
+	assert.Equal(t, `"""Shape helpers."""`+"\nimport math", chunks[0])
+	assert.Equal(t, "Circle", metadatas[1][MetadataSymbol])
+	assert.True(t, strings.HasPrefix(chunks[1], "@dataclass\nclass Circle:"))
+	assert.Contains(t, chunks[1], "return math.pi * self.radius ** 2")
+	assert.Equal(t, "describe", metadatas[2][MetadataSymbol])
+	assert.Contains(t, chunks[2], "def not_a_function():", "declarations inside strings must not start a unit")
+	assert.True(t, strings.HasSuffix(chunks[2], `return f"{doc} {c.area()}"`))
+	assert.Equal(t, "fetch", metadatas[3][MetadataSymbol])
+	assert.Equal(t, LanguagePython, metadatas[3][MetadataLanguage])
+}
+
+func TestCodeSplitter_OversizedUnitFallsBack(t *testing.T) {
+	chunks, metadatas := splitCode(t, "go", sampleGo, 60)
+
+	var areaPieces []string
+	for i, chunk := range chunks {
+		assert.LessOrEqual(t, len([]rune(chunk)), 60)
+		if metadatas[i][MetadataSymbol] == "Area" {
+			areaPieces = append(areaPieces, chunk)
+		}
+	}
+	require.Greater(t, len(areaPieces), 1)
+	assert.True(t, strings.HasPrefix(areaPieces[0], "// Area returns"))
+}
+
+func TestCodeSplitter_CreateDocumentsMergesMetadata(t *testing.T) {
+	splitter, err := NewTextSplitter(&config.SplitterConfig{Provider: "code", Language: "golang", ChunkSize: 1000})
+	require.NoError(t, err)
+
+	docs, err := CreateDocuments(splitter, []string{sampleGo}, []map[string]any{{"source": "shapes.go"}})
+	require.NoError(t, err)
+	require.Len(t, docs, 4)
+	assert.Equal(t, map[string]any{"source": "shapes.go", MetadataLanguage: LanguageGo, MetadataSymbol: "Area"}, docs[2].Metadata)
+
+	_, err = NewTextSplitter(&config.SplitterConfig{Provider: "code", Language: "cobol"})
+	assert.Error(t, err)
+}
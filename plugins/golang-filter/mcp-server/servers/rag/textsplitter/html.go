@@ -0,0 +1,112 @@
+package textsplitter
+
+import (
+	"strings"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/common/htmltext"
+)
+
+// Metadata keys the HTML splitter sets on each chunk.
+const (
+	MetadataHeadingPath = "heading_path"
+	MetadataLinks       = "links"
+)
+
+// headingPathSeparator joins the headings of MetadataHeadingPath
+const headingPathSeparator = " > "
+
+// HTMLSplitter is a text splitter for HTML pages. It drops scripts, styles and markup,
+// keeps the readable text of each section below a heading together, and splits sections
+// longer than ChunkSize recursively. Each chunk records its enclosing headings and the
+// targets of the links whose text it contains.
+type HTMLSplitter struct {
+	ChunkSize    int
+	ChunkOverlap int
+	LenFunc      func(string) int
+}
+
+// NewHTMLSplitter creates an HTML splitter. Only the chunk size, chunk overlap and length
+// function options are used.
+func NewHTMLSplitter(opts ...Option) HTMLSplitter {
+	options := DefaultOptions()
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = _defaultTokenChunkSize
+	}
+	if options.ChunkOverlap < 0 || options.ChunkOverlap >= options.ChunkSize {
+		options.ChunkOverlap = 0
+	}
+
+	return HTMLSplitter{
+		ChunkSize:    options.ChunkSize,
+		ChunkOverlap: options.ChunkOverlap,
+		LenFunc:      options.LenFunc,
+	}
+}
+
+// SplitText splits an HTML page into chunks of readable text.
+func (s HTMLSplitter) SplitText(text string) ([]string, error) {
+	chunks, _, err := s.SplitTextWithMetadata(text)
+	return chunks, err
+}
+
+// SplitTextWithMetadata splits an HTML page like SplitText and returns the heading path
+// and links of every chunk. Keys are omitted when a chunk has no heading or link.
+func (s HTMLSplitter) SplitTextWithMetadata(text string) ([]string, []map[string]any, error) {
+	_, sections, err := htmltext.ExtractSections(strings.NewReader(text))
+	if err != nil {
+		return nil, nil, err
+	}
+	fallback := NewRecursiveCharacter(
+		WithChunkSize(s.ChunkSize),
+		WithChunkOverlap(s.ChunkOverlap),
+		WithLenFunc(s.LenFunc),
+		WithSeparators([]string{"\n\n", "\n", ". ", "。", " ", ""}),
+	)
+
+	chunks := make([]string, 0)
+	metadatas := make([]map[string]any, 0)
+	for _, section := range sections {
+		paragraphs := make([]string, 0, len(section.Paragraphs))
+		for _, p := range section.Paragraphs {
+			paragraphs = append(paragraphs, p.Text)
+		}
+		content := strings.Join(paragraphs, "\n\n")
+		pieces := []string{content}
+		if s.LenFunc(content) > s.ChunkSize {
+			if pieces, err = fallback.SplitText(content); err != nil {
+				return nil, nil, err
+			}
+		}
+		for _, piece := range pieces {
+			metadata := map[string]any{}
+			if len(section.HeadingPath) > 0 {
+				metadata[MetadataHeadingPath] = strings.Join(section.HeadingPath, headingPathSeparator)
+			}
+			if links := linksIn(piece, section.Paragraphs); len(links) > 0 {
+				metadata[MetadataLinks] = links
+			}
+			chunks = append(chunks, piece)
+			metadatas = append(metadatas, metadata)
+		}
+	}
+	return chunks, metadatas, nil
+}
+
+// linksIn returns the distinct targets of the links whose text appears in chunk
+func linksIn(chunk string, paragraphs []htmltext.Paragraph) []string {
+	var links []string
+	seen := make(map[string]bool)
+	for _, p := range paragraphs {
+		for _, link := range p.Links {
+			if link.Text == "" || seen[link.Href] || !strings.Contains(chunk, link.Text) {
+				continue
+			}
+			seen[link.Href] = true
+			links = append(links, link.Href)
+		}
+	}
+	return links
+}
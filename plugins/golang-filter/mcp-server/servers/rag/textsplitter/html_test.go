@@ -0,0 +1,80 @@
+package textsplitter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleHTML = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Higress Docs</title>
+  <style>h1 { color: red; }</style>
+  <script>window.analytics = {};</script>
+</head>
+<body>
+<h1>Getting Started</h1>
+<p>Higress is a <b>cloud native</b> API gateway built on Envoy.</p>
+<h2>Installation</h2>
+<p>Install with <a href="https://higress.io/helm">Helm</a> or <a href="/docs/docker">Docker</a>.</p>
+<script>trackInstall();</script>
+<h2>Plugins</h2>
+<ul><li>Wasm plugins written in Go</li><li>Golang filters</li></ul>
+<p>Read the <a href="/docs/plugins">plugin guide</a>.</p>
+</body>
+</html>`
+
+func TestHTMLSplitter_KeepsHeadingsAndLinks(t *testing.T) {
+	splitter := NewHTMLSplitter(WithChunkSize(500), WithChunkOverlap(0))
+	chunks, metadatas, err := splitter.SplitTextWithMetadata(sampleHTML)
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+	require.Len(t, metadatas, 3)
+
+	assert.Equal(t, "Getting Started\n\nHigress is a cloud native API gateway built on Envoy.", chunks[0])
+	assert.Equal(t, map[string]any{MetadataHeadingPath: "Getting Started"}, metadatas[0])
+
+	assert.Equal(t, "Installation\n\nInstall with Helm or Docker.", chunks[1])
+	assert.Equal(t, "Getting Started > Installation", metadatas[1][MetadataHeadingPath])
+	assert.Equal(t, []string{"https://higress.io/helm", "/docs/docker"}, metadatas[1][MetadataLinks])
+
+	assert.Equal(t, "Getting Started > Plugins", metadatas[2][MetadataHeadingPath])
+	assert.Equal(t, []string{"/docs/plugins"}, metadatas[2][MetadataLinks])
+	for _, chunk := range chunks {
+		assert.NotContains(t, chunk, "<")
+		assert.NotContains(t, chunk, "analytics")
+		assert.NotContains(t, chunk, "trackInstall")
+		assert.NotContains(t, chunk, "color: red")
+	}
+}
+
+func TestHTMLSplitter_LongSectionKeepsMetadata(t *testing.T) {
+	body := strings.Repeat(`<p>Routing rules match requests by host and path. See <a href="/docs/routing">routing</a>.</p>`, 10)
+	splitter := NewHTMLSplitter(WithChunkSize(120), WithChunkOverlap(0))
+	chunks, metadatas, err := splitter.SplitTextWithMetadata("<h1>Routing</h1>" + body)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 1)
+	for i, chunk := range chunks {
+		assert.LessOrEqual(t, len([]rune(chunk)), 120)
+		assert.Equal(t, "Routing", metadatas[i][MetadataHeadingPath])
+		if strings.Contains(chunk, "routing.") {
+			assert.Equal(t, []string{"/docs/routing"}, metadatas[i][MetadataLinks])
+		}
+	}
+}
+
+func TestHTMLSplitter_CreateDocuments(t *testing.T) {
+	splitter, err := NewTextSplitter(&config.SplitterConfig{Provider: "html", ChunkSize: 500})
+	require.NoError(t, err)
+
+	docs, err := CreateDocuments(splitter, []string{sampleHTML}, []map[string]any{{"source": "docs.html"}})
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+	assert.Equal(t, "docs.html", docs[2].Metadata["source"])
+	assert.Equal(t, "Getting Started > Plugins", docs[2].Metadata[MetadataHeadingPath])
+	assert.True(t, strings.HasPrefix(docs[2].Content, "Plugins\n\nWasm plugins written in Go"))
+}
@@ -0,0 +1,184 @@
+package textsplitter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Metadata keys the markdown metadata extractor sets on each chunk.
+const (
+	MetadataHeading     = "heading"
+	MetadataSectionPath = "section_path"
+	MetadataCharOffset  = "char_offset"
+)
+
+// METADATA_EXTRACTOR_MARKDOWN is the SplitterConfig.MetadataExtractor tracking markdown headings
+const METADATA_EXTRACTOR_MARKDOWN = "markdown"
+
+// MetadataExtractor describes chunks by where they sit in the text they were split from,
+// e.g. the heading they fall under, so answers can cite their location.
+type MetadataExtractor interface {
+	// Extract returns the metadata of every chunk of text, in chunk order
+	Extract(text string, chunks []string) []map[string]any
+}
+
+func newMetadataExtractor(name string) (MetadataExtractor, error) {
+	switch name {
+	case METADATA_EXTRACTOR_MARKDOWN:
+		return MarkdownMetadataExtractor{}, nil
+	default:
+		return nil, fmt.Errorf("unknown metadata extractor: %s", name)
+	}
+}
+
+// ExtractingSplitter adds the metadata of an extractor to the chunks of a splitter. Keys
+// the splitter sets itself take precedence.
+type ExtractingSplitter struct {
+	Splitter  TextSplitter
+	Extractor MetadataExtractor
+}
+
+// SplitText splits text with the wrapped splitter.
+func (s ExtractingSplitter) SplitText(text string) ([]string, error) {
+	return s.Splitter.SplitText(text)
+}
+
+// SplitTextWithMetadata splits text with the wrapped splitter and extracts the metadata of
+// its chunks.
+func (s ExtractingSplitter) SplitTextWithMetadata(text string) ([]string, []map[string]any, error) {
+	chunks, metadatas, err := splitText(s.Splitter, text)
+	if err != nil {
+		return nil, nil, err
+	}
+	extracted := s.Extractor.Extract(text, chunks)
+	out := make([]map[string]any, len(chunks))
+	for i := range chunks {
+		out[i] = make(map[string]any)
+		if i < len(extracted) {
+			for k, v := range extracted[i] {
+				out[i][k] = v
+			}
+		}
+		if i < len(metadatas) {
+			for k, v := range metadatas[i] {
+				out[i][k] = v
+			}
+		}
+	}
+	return chunks, out, nil
+}
+
+// Unwrap returns the splitter that splits the text, looking through an ExtractingSplitter.
+func Unwrap(s TextSplitter) TextSplitter {
+	if extracting, ok := s.(ExtractingSplitter); ok {
+		return extracting.Splitter
+	}
+	return s
+}
+
+// markdownHeading matches an ATX heading: up to three spaces, 1-6 '#', then the text
+var markdownHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+
+// MarkdownMetadataExtractor tracks the stack of markdown headings. Each chunk records the
+// nearest heading above its start (MetadataHeading), the enclosing headings from the top
+// level down joined with " > " (MetadataSectionPath), and the character offset of its start
+// in the source text (MetadataCharOffset). Headings inside fenced code blocks are ignored;
+// keys are omitted when a chunk has no heading or cannot be located in the text.
+type MarkdownMetadataExtractor struct{}
+
+// markdownSection is the text from a heading up to the next one
+type markdownSection struct {
+	offset int
+	path   []string
+}
+
+// Extract implements MetadataExtractor.
+func (MarkdownMetadataExtractor) Extract(text string, chunks []string) []map[string]any {
+	sections := markdownSections(text)
+	metadatas := make([]map[string]any, len(chunks))
+	// Chunks come in order but may overlap, so each is searched from the previous one's start
+	cursor := 0
+	for i, chunk := range chunks {
+		metadata := map[string]any{}
+		metadatas[i] = metadata
+		offset := locateChunk(text, strings.TrimSpace(chunk), cursor)
+		if offset < 0 {
+			continue
+		}
+		cursor = offset
+		metadata[MetadataCharOffset] = utf8.RuneCountInString(text[:offset])
+		// The last section starting at or before the chunk encloses it
+		n := sort.Search(len(sections), func(j int) bool { return sections[j].offset > offset })
+		if n == 0 || len(sections[n-1].path) == 0 {
+			continue
+		}
+		path := sections[n-1].path
+		metadata[MetadataHeading] = path[len(path)-1]
+		metadata[MetadataSectionPath] = strings.Join(path, headingPathSeparator)
+	}
+	return metadatas
+}
+
+// markdownSections returns the sections of text in order, each with its heading path
+func markdownSections(text string) []markdownSection {
+	sections := make([]markdownSection, 0)
+	var levels []int
+	var path []string
+	fence := ""
+	offset := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		start := offset
+		offset += len(line)
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		m := markdownHeading.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil || strings.TrimSpace(m[2]) == "" {
+			continue
+		}
+		level := len(m[1])
+		for len(levels) > 0 && levels[len(levels)-1] >= level {
+			levels, path = levels[:len(levels)-1], path[:len(path)-1]
+		}
+		levels = append(levels, level)
+		path = append(path, strings.TrimSpace(m[2]))
+		sections = append(sections, markdownSection{offset: start, path: append([]string(nil), path...)})
+	}
+	return sections
+}
+
+// locateChunkPrefix is the number of leading bytes of a chunk searched for when the whole
+// chunk is not found, e.g. because the splitter rejoined its pieces differently
+const locateChunkPrefix = 64
+
+// locateChunk returns the byte offset of chunk in text at or after from, or -1
+func locateChunk(text, chunk string, from int) int {
+	if chunk == "" {
+		return -1
+	}
+	if i := strings.Index(text[from:], chunk); i >= 0 {
+		return from + i
+	}
+	prefix := chunk
+	if len(prefix) > locateChunkPrefix {
+		prefix = prefix[:locateChunkPrefix]
+		for !utf8.ValidString(prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if i := strings.Index(text[from:], prefix); i >= 0 {
+		return from + i
+	}
+	return -1
+}
@@ -0,0 +1,52 @@
+package textsplitter
+
+import (
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdownMetadataExtractor(t *testing.T) {
+	text := "Intro without heading.\n\n" +
+		"# 安装\n\nUse helm.\n\n" +
+		"## Helm\n\n```sh\n# not a heading\nhelm install higress\n```\n\n" +
+		"### Values ###\n\nSet replicas.\n\n" +
+		"# Plugins\n\nWasm plugins."
+	chunks := []string{
+		"Intro without heading.",
+		"# 安装\n\nUse helm.",
+		"helm install higress\n```",
+		"Set replicas.",
+		"Wasm plugins.",
+		"not in the text",
+	}
+	metadatas := MarkdownMetadataExtractor{}.Extract(text, chunks)
+	require.Len(t, metadatas, len(chunks))
+
+	assert.Equal(t, map[string]any{MetadataCharOffset: 0}, metadatas[0])
+	assert.Equal(t, map[string]any{MetadataHeading: "安装", MetadataSectionPath: "安装", MetadataCharOffset: 24}, metadatas[1])
+	assert.Equal(t, "Helm", metadatas[2][MetadataHeading])
+	assert.Equal(t, "安装 > Helm > Values", metadatas[3][MetadataSectionPath])
+	assert.Equal(t, "Plugins", metadatas[4][MetadataSectionPath])
+	assert.Equal(t, len([]rune(text))-len("Wasm plugins."), metadatas[4][MetadataCharOffset])
+	assert.Empty(t, metadatas[5])
+}
+
+func TestNewTextSplitter_MetadataExtractor(t *testing.T) {
+	splitter, err := NewTextSplitter(&config.SplitterConfig{Provider: "recursive", ChunkSize: 30, MetadataExtractor: METADATA_EXTRACTOR_MARKDOWN})
+	require.NoError(t, err)
+	assert.IsType(t, RecursiveCharacter{}, Unwrap(splitter))
+
+	docs, err := CreateDocuments(splitter, []string{"# Guide\n\nInstall Higress with helm.\n\n## Upgrade\n\nRun helm upgrade."}, []map[string]any{{"source": "guide.md"}})
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+	assert.Equal(t, "guide.md", docs[1].Metadata["source"])
+	assert.Equal(t, "Guide", docs[1].Metadata[MetadataSectionPath])
+	assert.Equal(t, "Guide > Upgrade", docs[2].Metadata[MetadataSectionPath])
+	assert.Equal(t, "Upgrade", docs[2].Metadata[MetadataHeading])
+
+	_, err = NewTextSplitter(&config.SplitterConfig{Provider: "recursive", MetadataExtractor: "pdf"})
+	assert.Error(t, err)
+}
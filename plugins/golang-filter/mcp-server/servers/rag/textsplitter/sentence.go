@@ -0,0 +1,152 @@
+package textsplitter
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+)
+
+// SentenceSplitter is a text splitter that never cuts a sentence in half. It segments text
+// on sentence boundaries (. ! ? followed by whitespace, 。！？ and line breaks), greedily
+// packs whole sentences into chunks of up to ChunkSize tokens, and starts every chunk with
+// the trailing sentences of the previous one that fit in ChunkOverlap tokens. A sentence
+// longer than ChunkSize is split recursively on clause separators.
+type SentenceSplitter struct {
+	ChunkSize    int
+	ChunkOverlap int
+	LenFunc      func(string) int
+}
+
+// NewSentenceSplitter creates a sentence splitter. Only the chunk size, chunk overlap and
+// length function options are used; lengths are counted with llm.EstimateTokens unless
+// WithLenFunc plugs in another tokenizer.
+func NewSentenceSplitter(opts ...Option) SentenceSplitter {
+	options := DefaultOptions()
+	options.LenFunc = llm.EstimateTokens
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = _defaultTokenChunkSize
+	}
+	if options.ChunkOverlap < 0 || options.ChunkOverlap >= options.ChunkSize {
+		options.ChunkOverlap = 0
+	}
+
+	return SentenceSplitter{
+		ChunkSize:    options.ChunkSize,
+		ChunkOverlap: options.ChunkOverlap,
+		LenFunc:      options.LenFunc,
+	}
+}
+
+// SplitText splits a text into chunks of whole sentences.
+func (s SentenceSplitter) SplitText(text string) ([]string, error) {
+	fallback := NewRecursiveCharacter(
+		WithChunkSize(s.ChunkSize),
+		WithChunkOverlap(s.ChunkOverlap),
+		WithLenFunc(s.LenFunc),
+		WithSeparators([]string{"；", ";", "，", ",", " ", ""}),
+	)
+
+	sentences := make([]string, 0)
+	for _, sentence := range splitSentences(text) {
+		if s.LenFunc(strings.TrimSpace(sentence)) <= s.ChunkSize {
+			sentences = append(sentences, sentence)
+			continue
+		}
+		pieces, err := fallback.SplitText(strings.TrimSpace(sentence))
+		if err != nil {
+			return nil, err
+		}
+		// The pieces stand in for sentences: put back the space that splitting on words removed
+		for _, piece := range pieces {
+			if r := []rune(piece); len(r) > 0 && !unicode.Is(unicode.Han, r[len(r)-1]) {
+				piece += " "
+			}
+			sentences = append(sentences, piece)
+		}
+	}
+
+	chunks := make([]string, 0)
+	var current []string
+	flush := func() {
+		if chunk := strings.TrimSpace(strings.Join(current, "")); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
+	for _, sentence := range sentences {
+		if strings.TrimSpace(sentence) == "" {
+			continue
+		}
+		if len(current) > 0 && s.LenFunc(strings.TrimSpace(strings.Join(current, "")+sentence)) > s.ChunkSize {
+			flush()
+			current = s.overlap(current)
+			// Drop overlap sentences until the new sentence fits next to them
+			for len(current) > 0 && s.LenFunc(strings.TrimSpace(strings.Join(current, "")+sentence)) > s.ChunkSize {
+				current = current[1:]
+			}
+		}
+		current = append(current, sentence)
+	}
+	flush()
+	return chunks, nil
+}
+
+// overlap returns the trailing sentences of chunk that fit together in ChunkOverlap tokens.
+func (s SentenceSplitter) overlap(chunk []string) []string {
+	start := len(chunk)
+	for start > 0 && s.LenFunc(strings.TrimSpace(strings.Join(chunk[start-1:], ""))) <= s.ChunkOverlap {
+		start--
+	}
+	return append([]string(nil), chunk[start:]...)
+}
+
+// splitSentences splits text into consecutive sentences, each keeping its closing quotes
+// and trailing whitespace, so that joining them yields text again. A sentence ends at
+// 。！？, at . ! ? followed by whitespace or the end of text, and at a line break.
+func splitSentences(text string) []string {
+	runes := []rune(text)
+	sentences := make([]string, 0)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		end := i + 1
+		switch r := runes[i]; {
+		case r == '\n':
+		case isCJKTerminator(r), isASCIITerminator(r):
+			// Repeated terminators and closing quotes or brackets belong to the sentence
+			for end < len(runes) && (isCJKTerminator(runes[end]) || isASCIITerminator(runes[end]) || isSentenceCloser(runes[end])) {
+				end++
+			}
+			// "3.14" or "higress.io" do not end a sentence
+			if isASCIITerminator(r) && end < len(runes) && !unicode.IsSpace(runes[end]) {
+				continue
+			}
+		default:
+			continue
+		}
+		for end < len(runes) && unicode.IsSpace(runes[end]) {
+			end++
+		}
+		sentences = append(sentences, string(runes[start:end]))
+		start = end
+		i = end - 1
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+	return sentences
+}
+
+func isCJKTerminator(r rune) bool {
+	return r == '。' || r == '！' || r == '？'
+}
+
+func isASCIITerminator(r rune) bool {
+	return r == '.' || r == '!' || r == '?'
+}
+
+func isSentenceCloser(r rune) bool {
+	return strings.ContainsRune("\"')]”’」』）】", r)
+}
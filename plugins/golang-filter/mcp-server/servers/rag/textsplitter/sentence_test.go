@@ -0,0 +1,84 @@
+package textsplitter
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countWords(text string) int { return len(strings.Fields(text)) }
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"english", "Higress is a gateway. Is it fast? Yes!", []string{"Higress is a gateway. ", "Is it fast? ", "Yes!"}},
+		{"chinese", "网关已发布。支持插件吗？支持！", []string{"网关已发布。", "支持插件吗？", "支持！"}},
+		{"numbers and domains", "版本 1.2 已发布。See higress.io for docs.", []string{"版本 1.2 已发布。", "See higress.io for docs."}},
+		{"closing quotes", "他说：“好。”然后离开了。He said \"ok.\" Then left.", []string{"他说：“好。”", "然后离开了。", "He said \"ok.\" ", "Then left."}},
+		{"line breaks", "## 安装\n使用 Helm 安装", []string{"## 安装\n", "使用 Helm 安装"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitSentences(tt.text)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.text, strings.Join(got, ""))
+		})
+	}
+}
+
+func TestSentenceSplitter_PacksWholeSentences(t *testing.T) {
+	splitter := NewSentenceSplitter(WithChunkSize(5), WithChunkOverlap(2), WithLenFunc(countWords))
+	chunks, err := splitter.SplitText("One two three. Four five! Six seven eight? Nine.")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"One two three. Four five!", "Four five! Six seven eight?", "Nine."}, chunks)
+}
+
+func TestSentenceSplitter_MixedLanguages(t *testing.T) {
+	text := "Higress 是一个云原生 API 网关。它基于 Envoy 构建，支持 Wasm 插件！" +
+		"Can plugins be written in Go? Yes, Go plugins are compiled to Wasm. " +
+		"插件可以热更新吗？可以，更新不会中断流量。Routes are configured with Ingress."
+	splitter := NewSentenceSplitter(WithChunkSize(24), WithChunkOverlap(0))
+	chunks, err := splitter.SplitText(text)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 1)
+
+	for _, chunk := range chunks {
+		assert.NotEmpty(t, strings.TrimSpace(chunk))
+		assert.LessOrEqual(t, llm.EstimateTokens(chunk), 24, chunk)
+		last := []rune(chunk)[len([]rune(chunk))-1]
+		assert.Truef(t, isCJKTerminator(last) || isASCIITerminator(last), "chunk %q ends mid-sentence", chunk)
+	}
+	stripSpace := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	assert.Equal(t, stripSpace(text), stripSpace(strings.Join(chunks, "")))
+}
+
+func TestSentenceSplitter_LongSentenceAndBlankText(t *testing.T) {
+	splitter, err := NewTextSplitter(&config.SplitterConfig{Provider: "sentence", ChunkSize: 3})
+	require.NoError(t, err)
+	sentences, ok := splitter.(SentenceSplitter)
+	require.True(t, ok)
+	sentences.LenFunc = countWords
+
+	chunks, err := sentences.SplitText("one two three four five six seven.")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one two three", "four five six", "seven."}, chunks)
+
+	chunks, err = sentences.SplitText("  \n\n \n")
+	require.NoError(t, err)
+	assert.Empty(t, chunks)
+}
@@ -40,17 +40,22 @@ func CreateDocuments(textSplitter TextSplitter, texts []string, metadatas []map[
 	documents := make([]schema.Document, 0)
 
 	for i := 0; i < len(texts); i++ {
-		chunks, err := textSplitter.SplitText(texts[i])
+		chunks, chunkMetadatas, err := splitText(textSplitter, texts[i])
 		if err != nil {
 			return nil, err
 		}
 
-		for _, chunk := range chunks {
+		for j, chunk := range chunks {
 			// Copy the document metadata
 			curMetadata := make(map[string]any, len(metadatas[i]))
 			for key, value := range metadatas[i] {
 				curMetadata[key] = value
 			}
+			if j < len(chunkMetadatas) {
+				for key, value := range chunkMetadatas[j] {
+					curMetadata[key] = value
+				}
+			}
 
 			documents = append(documents, schema.Document{
 				Content:  chunk,
@@ -62,6 +67,15 @@ func CreateDocuments(textSplitter TextSplitter, texts []string, metadatas []map[
 	return documents, nil
 }
 
+// splitText splits text, returning per-chunk metadata when the splitter provides it.
+func splitText(textSplitter TextSplitter, text string) ([]string, []map[string]any, error) {
+	if ms, ok := textSplitter.(MetadataSplitter); ok {
+		return ms.SplitTextWithMetadata(text)
+	}
+	chunks, err := textSplitter.SplitText(text)
+	return chunks, nil, err
+}
+
 // joinDocs comines two documents with the separator used to split them.
 func joinDocs(docs []string, separator string) string {
 	return strings.TrimSpace(strings.Join(docs, separator))
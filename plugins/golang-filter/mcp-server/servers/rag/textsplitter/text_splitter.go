@@ -11,6 +11,14 @@ type TextSplitter interface {
 	SplitText(text string) ([]string, error)
 }
 
+// MetadataSplitter is implemented by splitters that also describe each chunk, such as the
+// code splitter recording language and symbol. CreateDocuments merges the per-chunk
+// metadata into the metadata of the source text.
+type MetadataSplitter interface {
+	TextSplitter
+	SplitTextWithMetadata(text string) ([]string, []map[string]any, error)
+}
+
 type NoSplitterCharacter struct {
 }
 
@@ -19,9 +27,39 @@ func (s NoSplitterCharacter) SplitText(text string) ([]string, error) {
 }
 
 func NewTextSplitter(cfg *config.SplitterConfig) (TextSplitter, error) {
+	splitter, err := newBaseSplitter(cfg)
+	if err != nil || cfg.MetadataExtractor == "" {
+		return splitter, err
+	}
+	extractor, err := newMetadataExtractor(cfg.MetadataExtractor)
+	if err != nil {
+		return nil, err
+	}
+	return ExtractingSplitter{Splitter: splitter, Extractor: extractor}, nil
+}
+
+// NewParentSplitter returns the splitter cutting text into the parent sections of
+// hierarchical chunking, or nil when cfg.ParentChunkSize is not set
+func NewParentSplitter(cfg *config.SplitterConfig) TextSplitter {
+	if cfg.ParentChunkSize <= 0 {
+		return nil
+	}
+	return NewRecursiveCharacter(WithChunkSize(cfg.ParentChunkSize), WithChunkOverlap(0), WithSeparators(recursiveSeparators))
+}
+
+// recursiveSeparators are tried in order by the recursive splitter
+var recursiveSeparators = []string{"\n\n", "\n", ".", "。", "?", "!", "；"}
+
+func newBaseSplitter(cfg *config.SplitterConfig) (TextSplitter, error) {
 	switch cfg.Provider {
 	case "recursive":
-		return NewRecursiveCharacter(WithChunkSize(cfg.ChunkSize), WithChunkOverlap(cfg.ChunkOverlap), WithSeparators([]string{"\n\n", "\n", ".", "。", "?", "!", "；"})), nil
+		return NewRecursiveCharacter(WithChunkSize(cfg.ChunkSize), WithChunkOverlap(cfg.ChunkOverlap), WithSeparators(recursiveSeparators)), nil
+	case "code":
+		return NewCodeSplitter(cfg.Language, WithChunkSize(cfg.ChunkSize), WithChunkOverlap(cfg.ChunkOverlap))
+	case "html":
+		return NewHTMLSplitter(WithChunkSize(cfg.ChunkSize), WithChunkOverlap(cfg.ChunkOverlap)), nil
+	case "sentence":
+		return NewSentenceSplitter(WithChunkSize(cfg.ChunkSize), WithChunkOverlap(cfg.ChunkOverlap)), nil
 	case "nosplitter":
 		return NoSplitterCharacter{}, nil
 	default:
@@ -3,9 +3,13 @@ package rag
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-session/common"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
@@ -22,7 +26,7 @@ func HandleCreateChunkFromText(ragClient *RAGClient) common.ToolHandlerFunc {
 			return nil, fmt.Errorf("invalid title argument")
 		}
 		// Create knowledge chunks
-		docs, err := ragClient.CreateChunkFromText(text, title)
+		docs, err := withRequestNamespace(ctx, ragClient, arguments).CreateChunkFromText(text, title)
 		if err != nil {
 			return nil, fmt.Errorf("create chunk failed, err: %w", err)
 		}
@@ -37,10 +41,69 @@ func HandleCreateChunkFromText(ragClient *RAGClient) common.ToolHandlerFunc {
 	}
 }
 
+// HandleUpsertChunksFromText handles replacing the knowledge chunks of a document identified by its key
+func HandleUpsertChunksFromText(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := request.Params.Arguments
+		text, ok1 := arguments["text"].(string)
+		title, ok2 := arguments["title"].(string)
+		docKey, ok3 := arguments["doc_key"].(string)
+		if !ok1 {
+			return nil, fmt.Errorf("invalid text argument")
+		}
+		if !ok2 {
+			return nil, fmt.Errorf("invalid title argument")
+		}
+		if !ok3 {
+			return nil, fmt.Errorf("invalid doc_key argument")
+		}
+		upserted, err := withRequestNamespace(ctx, ragClient, arguments).UpsertChunksFromText(text, title, docKey)
+		if err != nil {
+			return nil, fmt.Errorf("upsert chunks failed, err: %w", err)
+		}
+
+		message := fmt.Sprintf("chunks of %s replaced %d old chunks", docKey, upserted.Replaced)
+		if upserted.Unchanged {
+			message = fmt.Sprintf("chunks of %s unchanged", docKey)
+		}
+		result := map[string]interface{}{
+			"success": true,
+			"message": message,
+			"data":    upserted,
+		}
+
+		return buildCallToolResult(result)
+	}
+}
+
+// HandleCreateChunksFromURL handles fetching a web page and ingesting it as knowledge chunks
+func HandleCreateChunksFromURL(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := request.Params.Arguments
+		url, ok := arguments["url"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid url argument")
+		}
+		title, _ := arguments["title"].(string)
+		docs, err := withRequestNamespace(ctx, ragClient, arguments).CreateChunksFromURL(url, title)
+		if err != nil {
+			return nil, fmt.Errorf("ingest from url failed, err: %w", err)
+		}
+
+		result := map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("chunks created from url: %s", url),
+			"data":    docs,
+		}
+
+		return buildCallToolResult(result)
+	}
+}
+
 // HandleListChunks handles the listing of knowledge chunks
 func HandleListChunks(ragClient *RAGClient) common.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		chunks, err := ragClient.ListChunks()
+		chunks, err := withRequestNamespace(ctx, ragClient, request.Params.Arguments).ListChunks()
 		if err != nil {
 			return nil, fmt.Errorf("list chunks failed, err: %w", err)
 		}
@@ -57,7 +120,7 @@ func HandleDeleteChunk(ragClient *RAGClient) common.ToolHandlerFunc {
 			return nil, fmt.Errorf("invalid id argument")
 		}
 
-		if err := ragClient.DeleteChunk(id); err != nil {
+		if err := withRequestNamespace(ctx, ragClient, arguments).DeleteChunk(id); err != nil {
 			return nil, fmt.Errorf("delete chunk failed, err: %w", err)
 		}
 
@@ -70,6 +133,155 @@ func HandleDeleteChunk(ragClient *RAGClient) common.ToolHandlerFunc {
 	}
 }
 
+// HandleDeleteChunksByFilter handles the deletion of every chunk matching a metadata filter
+func HandleDeleteChunksByFilter(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := request.Params.Arguments
+		filter, ok := arguments["filter"].(map[string]interface{})
+		if !ok || len(filter) == 0 {
+			return nil, fmt.Errorf("invalid filter argument")
+		}
+
+		deleted, err := withRequestNamespace(ctx, ragClient, arguments).DeleteChunksByFilter(filter)
+		if err != nil {
+			return nil, fmt.Errorf("delete chunks failed, err: %w", err)
+		}
+
+		result := map[string]interface{}{
+			"success": true,
+			"deleted": deleted,
+			"message": fmt.Sprintf("%d chunks deleted", deleted),
+		}
+
+		return buildCallToolResult(result)
+	}
+}
+
+// HandleStats handles reporting knowledge base statistics
+func HandleStats(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		stats, err := withRequestNamespace(ctx, ragClient, request.Params.Arguments).Stats()
+		if err != nil {
+			return nil, fmt.Errorf("get stats failed, err: %w", err)
+		}
+		return buildCallToolResult(stats)
+	}
+}
+
+// HandleHealth handles probing the vector store, embedding and LLM providers
+func HandleHealth(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return buildCallToolResult(ragClient.Health(ctx))
+	}
+}
+
+// HandleExportChunks handles exporting knowledge chunks as JSONL for backup or migration
+func HandleExportChunks(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var buf strings.Builder
+		if err := withRequestNamespace(ctx, ragClient, request.Params.Arguments).ExportChunks(&buf); err != nil {
+			return nil, fmt.Errorf("export chunks failed, err: %w", err)
+		}
+		count := strings.Count(buf.String(), "\n")
+
+		result := map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("chunks exported, count: %d", count),
+			"count":   count,
+			"data":    buf.String(),
+		}
+
+		return buildCallToolResult(result)
+	}
+}
+
+// HandleReindexCollection handles starting, inspecting and cancelling the background
+// in-place re-embedding of the active collection
+func HandleReindexCollection(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := request.Params.Arguments
+		action, _ := arguments["action"].(string)
+		result := map[string]interface{}{"success": true}
+		switch strings.TrimSpace(action) {
+		case "start":
+			batchSize := 0
+			if size, ok := arguments["batch_size"].(float64); ok {
+				batchSize = int(size)
+			}
+			status, err := ragClient.StartReindexCollection(batchSize)
+			if err != nil {
+				return nil, fmt.Errorf("start reindex failed, err: %w", err)
+			}
+			result["message"] = "collection reindex started"
+			result["status"] = status
+		case "", "status":
+			result["status"] = ragClient.ReindexCollectionStatus()
+		case "cancel":
+			cancelled := ragClient.CancelReindexCollection()
+			result["cancelled"] = cancelled
+			result["status"] = ragClient.ReindexCollectionStatus()
+		default:
+			return nil, fmt.Errorf("invalid action argument: %s", action)
+		}
+
+		return buildCallToolResult(result)
+	}
+}
+
+// HandleImportChunks handles importing knowledge chunks from JSONL produced by export-chunks
+func HandleImportChunks(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := request.Params.Arguments
+		data, ok := arguments["data"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid data argument")
+		}
+		reembed, _ := arguments["reembed"].(bool)
+
+		count, err := withRequestNamespace(ctx, ragClient, arguments).ImportChunks(strings.NewReader(data), reembed)
+		if err != nil {
+			return nil, fmt.Errorf("import chunks failed after %d chunks, err: %w", count, err)
+		}
+
+		result := map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("chunks imported, count: %d", count),
+			"count":   count,
+		}
+
+		return buildCallToolResult(result)
+	}
+}
+
+// HandleReindex handles re-embedding every chunk into a new collection and switching to it
+func HandleReindex(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := request.Params.Arguments
+		collection, ok := arguments["collection"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid collection argument")
+		}
+		batchSize := 0
+		if size, ok := arguments["batch_size"].(float64); ok {
+			batchSize = int(size)
+		}
+
+		count, err := ragClient.Reindex(collection, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("reindex failed after %d chunks, err: %w", count, err)
+		}
+
+		result := map[string]interface{}{
+			"success":    true,
+			"message":    fmt.Sprintf("chunks reindexed, count: %d", count),
+			"count":      count,
+			"collection": collection,
+		}
+
+		return buildCallToolResult(result)
+	}
+}
+
 // HandleCreateSession handles the creation of a chat session
 func HandleCreateSession(ragClient *RAGClient) common.ToolHandlerFunc {
     return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -136,21 +348,58 @@ func HandleSearch(ragClient *RAGClient) common.ToolHandlerFunc {
 		if !ok {
 			return nil, fmt.Errorf("invalid query argument")
 		}
-		topK, ok := arguments["topk"].(int)
-		if !ok {
-			topK = ragClient.config.RAG.TopK
+		opts := requestOptionsFromArguments(arguments)
+		searchResult, err := withRequestNamespace(ctx, ragClient, arguments).SearchChunksPipeline(query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("search chunks failed, err: %w", err)
+		}
+		if opts.IncludeVectors {
+			return buildCallToolResult(withVectors(searchResult))
 		}
+		return buildCallToolResult(searchResult)
+	}
+}
+
+// batchSearchResult is the outcome of one query of a batch search
+type batchSearchResult struct {
+	Query   string                `json:"query"`
+	Results []schema.SearchResult `json:"results"`
+	Error   string                `json:"error,omitempty"`
+}
 
-		threshold, ok := arguments["threshold"].(float64)
+// HandleBatchSearch handles semantic search of several queries in one call
+func HandleBatchSearch(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := request.Params.Arguments
+		items, ok := arguments["queries"].([]interface{})
 		if !ok {
-			threshold = ragClient.config.RAG.Threshold
+			return nil, fmt.Errorf("invalid queries argument")
 		}
-
-		searchResult, err := ragClient.SearchChunks(query, int(topK), threshold)
-		if err != nil {
-			return nil, fmt.Errorf("search chunks failed, err: %w", err)
+		queries := make([]string, len(items))
+		for i, item := range items {
+			if queries[i], ok = item.(string); !ok {
+				return nil, fmt.Errorf("invalid queries argument: item %d is not a string", i)
+			}
 		}
-		return buildCallToolResult(searchResult)
+		client := withRequestNamespace(ctx, ragClient, arguments)
+		cfg := client.snapshot().config.RAG
+		opts := requestOptionsFromArguments(arguments)
+		results, err := client.BatchSearchChunks(queries, opts.topK(cfg.TopK), opts.threshold(cfg.Threshold))
+		var batchErr *BatchSearchError
+		if err != nil && !errors.As(err, &batchErr) {
+			return nil, fmt.Errorf("batch search chunks failed, err: %w", err)
+		}
+		out := make([]batchSearchResult, len(queries))
+		for i, query := range queries {
+			out[i] = batchSearchResult{Query: query, Results: results[i]}
+			if out[i].Results == nil {
+				out[i].Results = []schema.SearchResult{}
+			}
+			if batchErr != nil && batchErr.Errors[i] != nil {
+				out[i].Error = batchErr.Errors[i].Error()
+			}
+		}
+		return buildCallToolResult(out)
 	}
 }
 
@@ -163,19 +412,203 @@ func HandleChat(ragClient *RAGClient) common.ToolHandlerFunc {
 			return nil, fmt.Errorf("invalid query argument")
 		}
 		// check llm provider
-		if ragClient.llmProvider == nil {
+		if ragClient.LLMProvider() == nil {
 			return nil, fmt.Errorf("llm provider is empty, please check the llm configuration")
 		}
 		// Generate response using RAGClient's LLM
-		reply, err := ragClient.Chat(query)
+		client, done := withRequestNamespace(ctx, ragClient, arguments).acquire()
+		defer done()
+		reply, m, err := client.chat(query, requestOptionsFromArguments(arguments))
+		if result, ok := noContextResult(err); ok {
+			return result, nil
+		}
 		if err != nil {
 			return nil, fmt.Errorf("chat failed, err: %w", err)
 		}
+		if includeMetrics, _ := arguments["include_metrics"].(bool); includeMetrics && m != nil {
+			reply.Metrics = m.Summary()
+		}
 
 		return buildCallToolResult(reply)
 	}
 }
 
+// HandleChatStream handles chat with the answer streamed as it is generated. When the
+// client passes a progress token, every chunk is sent as a notifications/progress message
+// carrying the chunk text; the result holds the complete answer either way, and generation
+// failing midway fails the call rather than returning the truncated answer.
+func HandleChatStream(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := request.Params.Arguments
+		query, ok := arguments["query"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid query argument")
+		}
+		if ragClient.LLMProvider() == nil {
+			return nil, fmt.Errorf("llm provider is empty, please check the llm configuration")
+		}
+		client, done := withRequestNamespace(ctx, ragClient, arguments).acquire()
+		defer done()
+		chunks, err := client.chatStream(ctx, query, requestOptionsFromArguments(arguments))
+		if result, ok := noContextResult(err); ok {
+			return result, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chat failed, err: %w", err)
+		}
+
+		var progressToken mcp.ProgressToken
+		if request.Params.Meta != nil {
+			progressToken = request.Params.Meta.ProgressToken
+		}
+		server := common.ServerFromContext(ctx)
+		var answer strings.Builder
+		sent := 0
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				return nil, fmt.Errorf("chat failed, err: %w", chunk.Err)
+			}
+			answer.WriteString(chunk.Text)
+			if progressToken == nil || server == nil {
+				continue
+			}
+			sent++
+			if err := server.SendNotificationToClient("notifications/progress", map[string]interface{}{
+				"progressToken": progressToken,
+				"progress":      sent,
+				"message":       chunk.Text,
+			}); err != nil {
+				api.LogDebugf("rag: send chat stream chunk failed, err: %v", err)
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("chat failed, err: %w", err)
+		}
+		if answer.Len() == 0 {
+			return nil, fmt.Errorf("chat failed, the llm returned no answer")
+		}
+
+		return buildCallToolResult(map[string]interface{}{"answer": answer.String()})
+	}
+}
+
+// HandleExplain handles dry-run pipeline explanation without generating an answer
+func HandleExplain(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := request.Params.Arguments
+		query, ok := arguments["query"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid query argument")
+		}
+		trace, err := withRequestNamespace(ctx, ragClient, arguments).ExplainChat(query)
+		if err != nil {
+			return nil, fmt.Errorf("explain failed, err: %w", err)
+		}
+		return buildCallToolResult(trace)
+	}
+}
+
+// HandleExplainQuery handles retrieval-only pipeline diagnostics: the trace of the query
+// without the answer prompt
+func HandleExplainQuery(ragClient *RAGClient) common.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments := request.Params.Arguments
+		query, ok := arguments["query"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid query argument")
+		}
+		trace, err := withRequestNamespace(ctx, ragClient, arguments).Explain(query)
+		if err != nil {
+			return nil, fmt.Errorf("explain query failed, err: %w", err)
+		}
+		return buildCallToolResult(trace)
+	}
+}
+
+// noContextResult turns a NoContextError into a tool error result, so the client is told
+// there is no grounded answer rather than that the call failed
+func noContextResult(err error) (*mcp.CallToolResult, bool) {
+	var noContext *NoContextError
+	if !errors.As(err, &noContext) {
+		return nil, false
+	}
+	return mcp.NewToolResultError(noContext.Error()), true
+}
+
+// withRequestNamespace scopes the client to the namespace of the request: the value of the
+// rag.namespace_header request header when configured and present, which tool arguments
+// cannot override, and the optional namespace tool argument otherwise
+func withRequestNamespace(ctx context.Context, ragClient *RAGClient, arguments map[string]interface{}) *RAGClient {
+	if name := ragClient.snapshot().config.RAG.NamespaceHeader; name != "" {
+		if namespace := strings.TrimSpace(common.RequestHeaderFromContext(ctx).Get(name)); namespace != "" {
+			return ragClient.WithNamespace(namespace)
+		}
+	}
+	if namespace, ok := arguments["namespace"].(string); ok {
+		return ragClient.WithNamespace(namespace)
+	}
+	return ragClient
+}
+
+// requestOptionsFromArguments reads the optional top_k, threshold and profile overrides.
+// JSON numbers arrive as float64; the legacy topk argument is still accepted.
+func requestOptionsFromArguments(arguments map[string]interface{}) RequestOptions {
+	var opts RequestOptions
+	for _, key := range []string{"top_k", "topk"} {
+		if topK, ok := intArgument(arguments, key); ok {
+			opts.TopK = topK
+			break
+		}
+	}
+	if threshold, ok := arguments["threshold"].(float64); ok {
+		opts.Threshold = &threshold
+	}
+	if profile, ok := arguments["profile"].(string); ok {
+		opts.Profile = strings.TrimSpace(profile)
+	}
+	if filter, ok := arguments["filter"].(map[string]interface{}); ok && len(filter) > 0 {
+		opts.Filter = filter
+	}
+	opts.Citations, _ = arguments["citations"].(bool)
+	opts.IncludeVectors, _ = arguments["include_vectors"].(bool)
+	if fields, ok := arguments["metadata_fields"].([]interface{}); ok {
+		opts.MetadataFields = make([]string, 0, len(fields))
+		for _, field := range fields {
+			if s, ok := field.(string); ok {
+				opts.MetadataFields = append(opts.MetadataFields, s)
+			}
+		}
+	}
+	return opts
+}
+
+// searchResultWithVector is a search result that also serializes the document vector,
+// which schema.Document leaves out of JSON
+type searchResultWithVector struct {
+	schema.SearchResult
+	Vector []float32 `json:"vector,omitempty"`
+}
+
+// withVectors wraps results so that their vectors are serialized
+func withVectors(results []schema.SearchResult) []searchResultWithVector {
+	out := make([]searchResultWithVector, 0, len(results))
+	for _, result := range results {
+		out = append(out, searchResultWithVector{SearchResult: result, Vector: result.Document.Vector})
+	}
+	return out
+}
+
+// intArgument reads an integer tool argument
+func intArgument(arguments map[string]interface{}, key string) (int, bool) {
+	switch v := arguments[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	}
+	return 0, false
+}
+
 // buildCallToolResult builds the call tool result
 func buildCallToolResult(results any) (*mcp.CallToolResult, error) {
 	jsonData, err := json.Marshal(results)
@@ -207,12 +640,64 @@ func GetCreateChunkFromTextSchema() json.RawMessage {
 			"title": {
 				"type": "string",
 				"description": "The title of text content"
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
 			}
 		},
 		"required": ["text", "title"]
 	}`)
 }
 
+// GetUpsertChunksFromTextSchema returns the schema for upsert chunks from text tool
+func GetUpsertChunksFromTextSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"text": {
+				"type": "string",
+				"description": "The full text content of the document"
+			},
+			"title": {
+				"type": "string",
+				"description": "The title of text content"
+			},
+			"doc_key": {
+				"type": "string",
+				"description": "A stable key of the document, e.g. its path or URL; chunks stored before under the same key are replaced"
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
+			}
+		},
+		"required": ["text", "title", "doc_key"]
+	}`)
+}
+
+// GetCreateChunksFromURLSchema returns the schema for ingest from url tool
+func GetCreateChunksFromURLSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"url": {
+				"type": "string",
+				"description": "The http(s) URL of the web page to ingest"
+			},
+			"title": {
+				"type": "string",
+				"description": "The title of the content (optional, defaults to the page title)"
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
+			}
+		},
+		"required": ["url"]
+	}`)
+}
+
 // GetListKnowledgeSchema returns the schema for list knowledge tool
 func GetListKnowledgeSchema() json.RawMessage {
 	return json.RawMessage(`{
@@ -253,7 +738,12 @@ func GetDeleteKnowledgeSchema() json.RawMessage {
 func GetListChunksSchema() json.RawMessage {
 	return json.RawMessage(`{
 		"type": "object",
-		"properties": {}
+		"properties": {
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
+			}
+		}
 	}`)
 }
 
@@ -265,12 +755,127 @@ func GetDeleteChunkSchema() json.RawMessage {
 			"id": {
 				"type": "string",
 				"description": "The chunk ID to delete"
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
 			}
 		},
 		"required": ["id"]
 	}`)
 }
 
+// GetDeleteChunksByFilterSchema returns the schema for delete chunks by filter tool
+func GetDeleteChunksByFilterSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"filter": {
+				"type": "object",
+				"description": "Metadata key/value pairs a chunk must all match to be deleted, e.g. {\"chunk_title\": \"foo\"}; values must be strings, numbers or booleans",
+				"minProperties": 1
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
+			}
+		},
+		"required": ["filter"]
+	}`)
+}
+
+// GetStatsSchema returns the schema for stats tool
+func GetStatsSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to report on (optional)"
+			}
+		}
+	}`)
+}
+
+// GetHealthSchema returns the schema for health tool
+func GetHealthSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {}
+	}`)
+}
+
+// GetExportChunksSchema returns the schema for export chunks tool
+func GetExportChunksSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to export (optional)"
+			}
+		}
+	}`)
+}
+
+// GetImportChunksSchema returns the schema for import chunks tool
+func GetImportChunksSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"data": {
+				"type": "string",
+				"description": "JSONL produced by export-chunks, one chunk per line"
+			},
+			"reembed": {
+				"type": "boolean",
+				"description": "Recompute embeddings with the current embedding provider instead of keeping the exported vectors (optional, default false)"
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to import into (optional)"
+			}
+		},
+		"required": ["data"]
+	}`)
+}
+
+// GetReindexSchema returns the schema for reindex tool
+func GetReindexSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"collection": {
+				"type": "string",
+				"description": "The new collection to re-embed all chunks into; it becomes the active collection when the reindex completes"
+			},
+			"batch_size": {
+				"type": "integer",
+				"description": "Number of chunks re-embedded and written per batch (optional, default 100)"
+			}
+		},
+		"required": ["collection"]
+	}`)
+}
+
+// GetReindexCollectionSchema returns the schema for reindex collection tool
+func GetReindexCollectionSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"action": {
+				"type": "string",
+				"enum": ["start", "status", "cancel"],
+				"description": "start re-embeds the active collection in the background, status reports its progress and cancel stops it (optional, default status)"
+			},
+			"batch_size": {
+				"type": "integer",
+				"description": "Number of chunks re-embedded and written per batch when starting (optional, default 100)"
+			}
+		}
+	}`)
+}
+
 // GetCreateSessionSchema returns the schema for create session tool
 func GetCreateSessionSchema() json.RawMessage {
 	return json.RawMessage(`{
@@ -327,19 +932,67 @@ func GetSearchSchema() json.RawMessage {
 				"type": "string",
 				"description": "The search query"
 			},
-			"topk": {
+			"top_k": {
                 "type": "integer",
-                "description": "The number of top results to return (optional, default 10)"
+                "description": "The number of top results to return (optional, defaults to the configured value, max 100)"
             },
             "threshold": {
                 "type": "number",
-                "description": "The relevance score threshold for filtering results (optional, default 0.5)"
-            }
+                "description": "The relevance score threshold for filtering results (optional, defaults to the configured value, range [0, 1])"
+            },
+            "profile": {
+                "type": "string",
+                "description": "The retrieval profile to use for this request (optional, requires pipeline)"
+            },
+			"filter": {
+				"type": "object",
+				"description": "Only return chunks whose metadata matches every key/value pair, e.g. {\"chunk_title\": \"faq\", \"tenant_id\": \"t1\"}; values must be strings, numbers or booleans. Filtered searches query the vector store directly, skipping the retrieval pipeline, and cannot be combined with profile (optional)"
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
+			},
+			"include_vectors": {
+				"type": "boolean",
+				"description": "Include the embedding vector of each chunk as a \"vector\" array of floats (optional, default false). Each vector adds one number per embedding dimension, e.g. 1536 floats or roughly 15-30KB of JSON per result, so enable it only when the vectors are processed downstream; web results carry no vector"
+			},
+			"metadata_fields": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "Only return these metadata keys of each result, e.g. [\"chunk_title\", \"url\"], to reduce the response size; the stored chunks are unchanged (optional, defaults to all keys)"
+			}
 		},
 		"required": ["query"]
 	}`)
 }
 
+// GetBatchSearchSchema returns the schema for batch search tool
+func GetBatchSearchSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"queries": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "The search queries, at most 50; results are returned in the same order"
+			},
+			"top_k": {
+				"type": "integer",
+				"description": "The number of top results to return per query (optional, defaults to the configured value, max 100)"
+			},
+			"threshold": {
+				"type": "number",
+				"description": "The relevance score threshold for filtering results (optional, defaults to the configured value, range [0, 1])"
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
+			}
+		},
+		"required": ["queries"]
+	}`)
+}
+
 // GetChatSchema returns the schema for chat tool
 func GetChatSchema() json.RawMessage {
 	return json.RawMessage(`{
@@ -348,6 +1001,83 @@ func GetChatSchema() json.RawMessage {
 			"query": {
 				"type": "string",
 				"description": "User query"
+			},
+			"top_k": {
+				"type": "integer",
+				"description": "The number of documents to retrieve as context (optional, max 100)"
+			},
+			"threshold": {
+				"type": "number",
+				"description": "The relevance score threshold for retrieved documents (optional, range [0, 1])"
+			},
+			"profile": {
+				"type": "string",
+				"description": "The retrieval profile to use for this request (optional, requires pipeline)"
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
+			},
+			"include_metrics": {
+				"type": "boolean",
+				"description": "Include a compact pipeline metrics summary in the response (optional, requires pipeline)"
+			},
+			"citations": {
+				"type": "boolean",
+				"description": "Ask for inline citation markers such as [1] and return the cited chunks (index, chunk_id, title, score, snippet) in citations; markers citing no retrieved chunk are removed (optional)"
+			},
+			"metadata_fields": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "Only return these metadata keys of each source, e.g. [\"chunk_title\", \"url\"], to reduce the response size; the stored chunks are unchanged (optional, defaults to all keys)"
+			}
+		},
+		"required": ["query"]
+	}`)
+}
+
+// GetChatStreamSchema returns the schema for chat stream tool
+func GetChatStreamSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"query": {
+				"type": "string",
+				"description": "User query"
+			},
+			"top_k": {
+				"type": "integer",
+				"description": "The number of documents to retrieve as context (optional, max 100)"
+			},
+			"threshold": {
+				"type": "number",
+				"description": "The relevance score threshold for retrieved documents (optional, range [0, 1])"
+			},
+			"profile": {
+				"type": "string",
+				"description": "The retrieval profile to use for this request (optional, requires pipeline)"
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
+			}
+		},
+		"required": ["query"]
+	}`)
+}
+
+// GetExplainSchema returns the schema for explain tool
+func GetExplainSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"query": {
+				"type": "string",
+				"description": "User query to trace through the retrieval pipeline"
+			},
+			"namespace": {
+				"type": "string",
+				"description": "The knowledge-base namespace to operate in (optional)"
 			}
 		},
 		"required": ["query"]
@@ -0,0 +1,349 @@
+package rag
+
+import (
+	"sort"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/fusion"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/gating"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/metrics"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retrieval"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/router"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// PipelineTrace is a structured view of what each pipeline stage did for a single query.
+// It is built from the data captured in metrics.RetrievalMetrics, plus per-document
+// details (rerank ordering, compression ratios) that metrics do not keep.
+type PipelineTrace struct {
+	QueryID        string                    `json:"query_id"`
+	Query          string                    `json:"query"`
+	Profile        TraceProfile              `json:"profile"`
+	Router         *TraceRouter              `json:"router,omitempty"`
+	Gating         *TraceGating              `json:"gating,omitempty"`
+	PreRetrieve    *TracePreRetrieve         `json:"pre_retrieve,omitempty"`
+	Retrievers     []TraceRetriever          `json:"retrievers"`
+	Fusion         TraceFusion               `json:"fusion"`
+	Rerank         *TraceRerank              `json:"rerank,omitempty"`
+	MMR            *TraceRerank              `json:"mmr,omitempty"`
+	Compression    *TraceCompression         `json:"compression,omitempty"`
+	CRAG           *TraceCRAG                `json:"crag,omitempty"`
+	Results        []TraceResult             `json:"results"`
+	EmbeddingError string                    `json:"embedding_error,omitempty"`
+	Prompt         string                    `json:"prompt,omitempty"`
+	Metrics        *metrics.RetrievalMetrics `json:"-"`
+
+	// lists holds the ranked lists recorded by the retrieval provider
+	lists retrieval.FusionTrace
+}
+
+// TraceProfile describes the selected retrieval profile.
+type TraceProfile struct {
+	Name           string         `json:"name"`
+	Source         string         `json:"source"`
+	Retrievers     []string       `json:"retrievers,omitempty"`
+	VariantBudgets map[string]int `json:"variant_budgets,omitempty"`
+	TopK           int            `json:"top_k"`
+	Threshold      float64        `json:"threshold"`
+}
+
+// TraceRouter describes the router decision.
+type TraceRouter struct {
+	Provider   string         `json:"provider,omitempty"`
+	Profile    string         `json:"profile,omitempty"`
+	QueryType  string         `json:"query_type,omitempty"`
+	Confidence float64        `json:"confidence,omitempty"`
+	Reason     string         `json:"reason,omitempty"`
+	Variants   map[string]int `json:"variants,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// TraceGating describes the vector gating decision.
+type TraceGating struct {
+	TopScore      float64  `json:"top_score"`
+	SuppressWeb   bool     `json:"suppress_web"`
+	ForceWeb      bool     `json:"force_web"`
+	Reason        string   `json:"reason"`
+	Decisions     []string `json:"decisions,omitempty"`
+	VectorGate    float64  `json:"vector_gate,omitempty"`
+	VectorLowGate float64  `json:"vector_low_gate,omitempty"`
+}
+
+// TracePreRetrieve describes the queries produced by the pre-retrieve stage.
+type TracePreRetrieve struct {
+	AlignedQuery string   `json:"aligned_query,omitempty"`
+	Queries      []string `json:"queries"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// TraceRetriever describes a single retriever's contribution.
+type TraceRetriever struct {
+	Name        string        `json:"name"`
+	ResultCount int           `json:"result_count"`
+	LatencyMs   int64         `json:"latency_ms"`
+	TopScore    float64       `json:"top_score"`
+	AvgScore    float64       `json:"avg_score"`
+	Results     []TraceRanked `json:"results"`
+}
+
+// TraceFusion describes the fusion stage.
+type TraceFusion struct {
+	Strategy       string         `json:"strategy"`
+	WeightsVersion string         `json:"weights_version,omitempty"`
+	Params         map[string]any `json:"params,omitempty"`
+	ResultCount    int            `json:"result_count"`
+	LatencyMs      int64          `json:"latency_ms"`
+	Inputs         []TraceList    `json:"inputs"`
+	Output         []TraceRanked  `json:"output"`
+}
+
+// TraceList is one ranked list passed to fusion.
+type TraceList struct {
+	Retriever string        `json:"retriever"`
+	Results   []TraceRanked `json:"results"`
+}
+
+// TraceRanked is a document of a ranked list.
+type TraceRanked struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// TraceRerank describes how reranking, or the MMR step, changed the ordering.
+type TraceRerank struct {
+	Before []string     `json:"before"`
+	After  []string     `json:"after"`
+	Deltas []TraceDelta `json:"deltas"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// TraceDelta is the rank movement of one document; Before is -1 for documents
+// that were not in the input list.
+type TraceDelta struct {
+	ID     string `json:"id"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+	Delta  int    `json:"delta"`
+}
+
+// TraceCompression describes per-document compression.
+type TraceCompression struct {
+	OriginalLength   int                `json:"original_length"`
+	CompressedLength int                `json:"compressed_length"`
+	Ratio            float64            `json:"ratio"`
+	Documents        []TraceCompressDoc `json:"documents"`
+	Error            string             `json:"error,omitempty"`
+}
+
+// TraceCompressDoc holds compression numbers for one document.
+type TraceCompressDoc struct {
+	ID               string  `json:"id"`
+	OriginalLength   int     `json:"original_length"`
+	CompressedLength int     `json:"compressed_length"`
+	Ratio            float64 `json:"ratio"`
+}
+
+// TraceCRAG describes the CRAG evaluation.
+type TraceCRAG struct {
+	Verdict string  `json:"verdict"`
+	Score   float64 `json:"score"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// TraceResult is a final ranked document.
+type TraceResult struct {
+	ID      string  `json:"id"`
+	Score   float64 `json:"score"`
+	Title   string  `json:"title,omitempty"`
+	Preview string  `json:"preview"`
+}
+
+const tracePreviewLength = 200
+
+func newPipelineTrace(query string) *PipelineTrace {
+	return &PipelineTrace{Query: query, Retrievers: []TraceRetriever{}, Results: []TraceResult{}}
+}
+
+func (t *PipelineTrace) recordRouter(provider string, decision *router.RoutingDecision, err error) {
+	t.Router = &TraceRouter{Provider: provider}
+	if err != nil {
+		t.Router.Error = err.Error()
+		return
+	}
+	if decision == nil {
+		return
+	}
+	t.Router.Profile = decision.ProfileName
+	t.Router.QueryType = decision.QueryType
+	t.Router.Confidence = decision.Confidence
+	t.Router.Reason = decision.Reason
+	if len(decision.VariantBudgets) > 0 {
+		t.Router.Variants = make(map[string]int, len(decision.VariantBudgets))
+		for k, v := range decision.VariantBudgets {
+			t.Router.Variants[k] = v.TopK
+		}
+	}
+}
+
+func (t *PipelineTrace) recordGating(decision gating.Decision, gate, lowGate float64) {
+	t.Gating = &TraceGating{
+		TopScore:      decision.TopScore,
+		SuppressWeb:   decision.ShouldSuppressWeb,
+		ForceWeb:      decision.ShouldForceWeb,
+		Reason:        decision.Reason,
+		VectorGate:    gate,
+		VectorLowGate: lowGate,
+	}
+}
+
+func (t *PipelineTrace) recordRerank(before, after []schema.SearchResult, err error) {
+	t.Rerank = rankChanges(before, after, err)
+}
+
+func (t *PipelineTrace) recordMMR(before, after []schema.SearchResult, err error) {
+	t.MMR = rankChanges(before, after, err)
+}
+
+// rankChanges describes how after reorders before
+func rankChanges(before, after []schema.SearchResult, err error) *TraceRerank {
+	changes := &TraceRerank{Before: resultIDs(before), After: resultIDs(after), Deltas: []TraceDelta{}}
+	if err != nil {
+		changes.Error = err.Error()
+	}
+	positions := make(map[string]int, len(before))
+	for i, id := range changes.Before {
+		if _, ok := positions[id]; !ok {
+			positions[id] = i
+		}
+	}
+	for i, id := range changes.After {
+		prev, ok := positions[id]
+		if !ok {
+			prev = -1
+		}
+		delta := 0
+		if prev >= 0 {
+			delta = prev - i
+		}
+		changes.Deltas = append(changes.Deltas, TraceDelta{ID: id, Before: prev, After: i, Delta: delta})
+	}
+	return changes
+}
+
+func (t *PipelineTrace) recordCompression(before, after []schema.SearchResult, err error) {
+	t.Compression = &TraceCompression{Documents: []TraceCompressDoc{}}
+	if err != nil {
+		t.Compression.Error = err.Error()
+	}
+	originals := make(map[string]int, len(before))
+	for _, res := range before {
+		originals[res.Document.ID] = len(res.Document.Content)
+	}
+	for _, res := range after {
+		orig, ok := originals[res.Document.ID]
+		if !ok {
+			orig = len(res.Document.Content)
+		}
+		compressed := len(res.Document.Content)
+		t.Compression.OriginalLength += orig
+		t.Compression.CompressedLength += compressed
+		t.Compression.Documents = append(t.Compression.Documents, TraceCompressDoc{
+			ID:               res.Document.ID,
+			OriginalLength:   orig,
+			CompressedLength: compressed,
+			Ratio:            lengthRatio(orig, compressed),
+		})
+	}
+	t.Compression.Ratio = lengthRatio(t.Compression.OriginalLength, t.Compression.CompressedLength)
+}
+
+// finish copies the stage data captured in metrics and the final results into the trace.
+func (t *PipelineTrace) finish(m *metrics.RetrievalMetrics, results []schema.SearchResult) {
+	t.Metrics = m
+	if m != nil {
+		t.QueryID = m.QueryID
+		t.Profile.Name = m.ProfileName
+		t.Profile.Source = m.ProfileSource
+		if t.Gating != nil {
+			t.Gating.Decisions = append([]string(nil), m.GatingDecisions...)
+		}
+		t.EmbeddingError = m.EmbeddingError
+		names := make([]string, 0, len(m.RetrieverMetrics))
+		for name := range m.RetrieverMetrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			stats := m.RetrieverMetrics[name]
+			t.Retrievers = append(t.Retrievers, TraceRetriever{
+				Name:        name,
+				ResultCount: stats.ResultCount,
+				LatencyMs:   stats.LatencyMs,
+				TopScore:    stats.TopScore,
+				AvgScore:    stats.AvgScore,
+				Results:     retrieverList(t.lists.Retrieved, name),
+			})
+		}
+		t.Fusion = TraceFusion{
+			Strategy:       m.FusionStrategy,
+			WeightsVersion: m.FusionWeightsVersion,
+			Params:         m.FusionParams,
+			ResultCount:    m.FusionResultCount,
+			LatencyMs:      m.FusionLatencyMs,
+			Inputs:         make([]TraceList, 0, len(t.lists.Inputs)),
+			Output:         rankedList(t.lists.Output),
+		}
+		for _, input := range t.lists.Inputs {
+			t.Fusion.Inputs = append(t.Fusion.Inputs, TraceList{Retriever: input.Retriever, Results: rankedList(input.Results)})
+		}
+		sort.SliceStable(t.Fusion.Inputs, func(i, j int) bool {
+			return t.Fusion.Inputs[i].Retriever < t.Fusion.Inputs[j].Retriever
+		})
+		if m.CRAGEnabled && t.CRAG != nil {
+			t.CRAG.Verdict = m.CRAGVerdict
+		}
+	}
+	t.Results = make([]TraceResult, 0, len(results))
+	for _, res := range results {
+		title := res.Document.Title()
+		preview := res.Document.Content
+		if runes := []rune(preview); len(runes) > tracePreviewLength {
+			preview = string(runes[:tracePreviewLength])
+		}
+		t.Results = append(t.Results, TraceResult{ID: res.Document.ID, Score: res.Score, Title: title, Preview: preview})
+	}
+}
+
+// retrieverList returns the results of the lists retrieved by name, as ranked by the retriever
+func retrieverList(lists []fusion.RetrieverResult, name string) []TraceRanked {
+	ranked := []TraceRanked{}
+	for _, list := range lists {
+		if list.Retriever == name {
+			ranked = append(ranked, rankedList(list.Results)...)
+		}
+	}
+	return ranked
+}
+
+func rankedList(results []schema.SearchResult) []TraceRanked {
+	ranked := make([]TraceRanked, len(results))
+	for i, res := range results {
+		ranked[i] = TraceRanked{ID: res.Document.ID, Score: res.Score}
+	}
+	return ranked
+}
+
+func resultIDs(results []schema.SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, res := range results {
+		ids[i] = res.Document.ID
+	}
+	return ids
+}
+
+// lengthRatio returns compressed/original, or 1 when original is empty.
+func lengthRatio(original, compressed int) float64 {
+	if original == 0 {
+		return 1
+	}
+	return float64(compressed) / float64(original)
+}
@@ -0,0 +1,404 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/retriever"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func newExplainTestClient(t *testing.T) (*RAGClient, *MockLLMProvider) {
+	t.Helper()
+	retriever.Register("trace_stub", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return &stubRetriever{typ: "bm25", results: []schema.SearchResult{
+			{Document: schema.Document{ID: "kw-1", Content: "Higress gateway keyword match about plugins and routing rules"}, Score: 7.5},
+			{Document: schema.Document{ID: "doc-2", Content: "Higress supports wasm plugins written in Go"}, Score: 3.1},
+		}}, nil
+	})
+
+	pipeline := config.DefaultPipeline()
+	pipeline.EnablePost = true
+	pipeline.EnableCRAG = true
+	pipeline.Retrievers = []config.RetrieverConfig{
+		{Type: "trace_stub", Params: map[string]string{"name": "bm25"}},
+	}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{
+		{Name: "default", Retrievers: []string{"vector", "bm25"}, TopK: 5, Threshold: 0.001, VectorGate: 0.99, VectorLowGate: 0.01},
+	}
+	pipeline.DefaultProfile = "default"
+	pipeline.Router = &config.RouterConfig{Enable: true, Provider: "rule"}
+	pipeline.Post = &config.PostConfig{}
+	pipeline.Post.Rerank.Enable = true
+	pipeline.Post.Rerank.Provider = "keyword"
+	pipeline.Post.Compress.Enable = true
+	pipeline.Post.Compress.Method = "truncate"
+	pipeline.Post.Compress.TargetRatio = 0.5
+	pipeline.CRAG = &config.CRAGConfig{}
+	pipeline.CRAG.Evaluator.Provider = "llm"
+
+	llmProvider := &MockLLMProvider{Respond: func(prompt string) (string, error) {
+		if strings.Contains(prompt, "Rate how relevant") {
+			return "0.9", nil
+		}
+		return "refined", nil
+	}}
+	client, _ := newTestRAGClient(t, &config.Config{
+		RAG:      config.RAGConfig{TopK: 5},
+		VectorDB: config.VectorDBConfig{Collection: "trace"},
+		Pipeline: pipeline,
+	}, llmProvider)
+
+	for _, text := range []string{
+		"Higress is a cloud native API gateway based on Envoy",
+		"Higress plugins can be written in Go, Rust or JavaScript and compiled to wasm",
+		"Milvus is a vector database used for similarity search",
+	} {
+		if _, err := client.CreateChunkFromText(text, "higress-docs"); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+	return client, llmProvider
+}
+
+func TestRAGClient_ExplainChat(t *testing.T) {
+	client, llmProvider := newExplainTestClient(t)
+
+	trace, err := client.ExplainChat("what is higress gateway plugins?")
+	if err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+
+	if trace.QueryID == "" {
+		t.Error("trace query_id is empty")
+	}
+	if trace.Profile.Name != "default" || trace.Profile.Source != "router" {
+		t.Errorf("profile = %+v, want default selected by router", trace.Profile)
+	}
+	if trace.Router == nil || trace.Router.QueryType != "open-ended" || trace.Router.Provider != "rule" {
+		t.Errorf("router = %+v, want rule-based open-ended decision", trace.Router)
+	}
+	if trace.Gating == nil || trace.Gating.TopScore <= 0 || trace.Gating.Reason == "" || len(trace.Gating.Decisions) == 0 {
+		t.Errorf("gating = %+v, want populated preflight decision", trace.Gating)
+	}
+
+	counts := map[string]int{}
+	for _, ret := range trace.Retrievers {
+		counts[ret.Name] = ret.ResultCount
+	}
+	if counts["vector"] == 0 || counts["bm25"] == 0 || counts["vector_preflight"] == 0 {
+		t.Errorf("retrievers = %+v, want vector, bm25 and vector_preflight counts", trace.Retrievers)
+	}
+	if trace.Fusion.Strategy != "rrf" || trace.Fusion.ResultCount == 0 {
+		t.Errorf("fusion = %+v, want rrf with results", trace.Fusion)
+	}
+	for _, ret := range trace.Retrievers {
+		if ret.Name == "bm25" && (len(ret.Results) != 2 || ret.Results[0] != (TraceRanked{ID: "kw-1", Score: 7.5})) {
+			t.Errorf("bm25 results = %+v, want the stub's ranked list", ret.Results)
+		}
+	}
+	inputs := map[string]int{}
+	for _, input := range trace.Fusion.Inputs {
+		inputs[input.Retriever] = len(input.Results)
+	}
+	if inputs["vector"] == 0 || inputs["bm25"] != 2 {
+		t.Errorf("fusion inputs = %+v, want the vector and bm25 lists", trace.Fusion.Inputs)
+	}
+	if len(trace.Fusion.Output) != trace.Fusion.ResultCount || trace.Fusion.Output[0].Score <= 0 {
+		t.Errorf("fusion output = %+v, want %d fused results", trace.Fusion.Output, trace.Fusion.ResultCount)
+	}
+	if trace.Rerank == nil || len(trace.Rerank.Before) == 0 || len(trace.Rerank.Deltas) != len(trace.Rerank.After) {
+		t.Errorf("rerank = %+v, want ordering deltas", trace.Rerank)
+	}
+	if trace.Compression == nil || len(trace.Compression.Documents) == 0 || trace.Compression.Ratio >= 1 {
+		t.Errorf("compression = %+v, want per-document ratios below 1", trace.Compression)
+	}
+	if trace.CRAG == nil || trace.CRAG.Verdict != "correct" || trace.CRAG.Score != 0.9 {
+		t.Errorf("crag = %+v, want correct verdict with score 0.9", trace.CRAG)
+	}
+	if len(trace.Results) == 0 || trace.Prompt == "" {
+		t.Errorf("results = %d prompt = %q, want final results and prompt", len(trace.Results), trace.Prompt)
+	}
+
+	for _, prompt := range llmProvider.Prompts {
+		if strings.Contains(prompt, "{contexts}") || strings.HasPrefix(prompt, trace.Prompt) {
+			t.Errorf("ExplainChat() must not call the LLM for answer generation")
+		}
+	}
+}
+
+func TestRAGClient_ExplainChatLeavesNoFeedback(t *testing.T) {
+	client, _ := newExplainTestClient(t)
+	client.config.Pipeline.Feedback = &config.FeedbackConfig{}
+	if err := client.initPipeline(); err != nil {
+		t.Fatalf("initPipeline() error = %v", err)
+	}
+
+	if _, err := client.ExplainChat("what is higress?"); err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+	if trend := client.feedbackManager.GetTrend("default", 0); trend.Total != 0 {
+		t.Errorf("feedback trend = %+v, want explain verdicts left out", trend)
+	}
+	if s := client.MetricsAggregator().Snapshot(); s.QueryCount != 0 {
+		t.Errorf("aggregated %d queries, want explain runs left out", s.QueryCount)
+	}
+
+	// Regular requests still feed both
+	if _, err := client.ChatWithSources("what is higress?", RequestOptions{}); err != nil {
+		t.Fatalf("ChatWithSources() error = %v", err)
+	}
+	if trend := client.feedbackManager.GetTrend("default", 0); trend.Total != 1 {
+		t.Errorf("feedback trend = %+v, want the chat verdict", trend)
+	}
+	if s := client.MetricsAggregator().Snapshot(); s.QueryCount != 1 {
+		t.Errorf("aggregated %d queries, want the chat request", s.QueryCount)
+	}
+}
+
+func TestRAGClient_ExplainChatBypassesCache(t *testing.T) {
+	client, _ := newExplainTestClient(t)
+	client.config.Pipeline.Cache = &config.CacheConfig{L1: &config.CacheLayerConfig{Enable: true}}
+	if err := client.initPipeline(); err != nil {
+		t.Fatalf("initPipeline() error = %v", err)
+	}
+
+	if _, err := client.ExplainChat("what is higress?"); err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+	trace, err := client.ExplainChat("what is higress?")
+	if err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+	if len(trace.Retrievers) == 0 {
+		t.Error("second ExplainChat() should execute retrieval instead of serving from cache")
+	}
+}
+
+func TestRAGClient_ExplainChatBaseline(t *testing.T) {
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}}, nil)
+	if _, err := client.CreateChunkFromText("Higress is an API gateway", "intro"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+	trace, err := client.ExplainChat("higress gateway")
+	if err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+	if trace.Profile.Source != "baseline" || len(trace.Results) != 1 || trace.Results[0].Title != "intro" {
+		t.Errorf("trace = %+v, want baseline result titled intro", trace)
+	}
+}
+
+func TestHandleExplain(t *testing.T) {
+	client, _ := newExplainTestClient(t)
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"query": "what is higress?"}
+
+	result, err := HandleExplain(client)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("HandleExplain() error = %v", err)
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+		t.Fatalf("explain output is not JSON: %v", err)
+	}
+	for _, key := range []string{"profile", "retrievers", "fusion", "results"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("explain output missing %q", key)
+		}
+	}
+}
+
+func TestHandleExplainQuery(t *testing.T) {
+	client, _ := newExplainTestClient(t)
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"query": "what is higress gateway plugins?"}
+
+	result, err := HandleExplainQuery(client)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("HandleExplainQuery() error = %v", err)
+	}
+	var trace PipelineTrace
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &trace); err != nil {
+		t.Fatalf("explain-query output is not JSON: %v", err)
+	}
+	if trace.Prompt != "" {
+		t.Errorf("explain-query trace has a prompt, want retrieval only")
+	}
+	if trace.Profile.Name == "" || len(trace.Retrievers) == 0 || len(trace.Results) == 0 {
+		t.Errorf("trace = %+v, want profile, retrievers and results", trace)
+	}
+}
+
+func TestRAGClient_CRAGThresholdsByIntent(t *testing.T) {
+	client, _ := newExplainTestClient(t)
+	client.config.Pipeline.CRAG.Evaluator.ThresholdsByIntent = map[string]config.CRAGThresholds{
+		"open-ended": {Correct: 0.95},
+	}
+	if err := client.initPipeline(); err != nil {
+		t.Fatalf("initPipeline() error = %v", err)
+	}
+
+	trace, err := client.ExplainChat("what is higress gateway plugins?")
+	if err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+	if trace.CRAG == nil || trace.CRAG.Score != 0.9 || trace.CRAG.Verdict != "ambiguous" {
+		t.Errorf("crag = %+v, want score 0.9 judged ambiguous under the open-ended threshold", trace.CRAG)
+	}
+}
+
+func TestRAGClient_PreServiceSubQueries(t *testing.T) {
+	var unavailable atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unavailable.Load() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"decomposition": {"tasks": [{"query_text": "higress plugins"}, {"query_text": "envoy filters"}]}}`))
+	}))
+	defer server.Close()
+
+	pipeline := config.DefaultPipeline()
+	pipeline.EnablePre = true
+	pipeline.Pre = &config.PreConfig{}
+	pipeline.Pre.Service.Provider = "http"
+	pipeline.Pre.Service.Endpoint = server.URL
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 3}, Pipeline: pipeline}, nil)
+
+	trace, err := client.ExplainChat("compare higress and envoy plugins")
+	if err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+	if trace.PreRetrieve == nil || strings.Join(trace.PreRetrieve.Queries, "|") != "higress plugins|envoy filters" {
+		t.Errorf("pre-retrieve = %+v, want the service decomposition as sub-queries", trace.PreRetrieve)
+	}
+
+	unavailable.Store(true)
+	trace, err = client.ExplainChat("compare higress and envoy plugins")
+	if err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+	// The local default pre-retrieve takes over and keeps the original query
+	if trace.PreRetrieve == nil || strings.Join(trace.PreRetrieve.Queries, "|") != "compare higress and envoy plugins" {
+		t.Errorf("pre-retrieve = %+v, want the default processor used when the service fails", trace.PreRetrieve)
+	}
+}
+
+func TestRAGClient_ChatWithSourcesKeepsChunkTitles(t *testing.T) {
+	chunks := map[string]string{
+		"Higress is a cloud native API gateway based on Envoy":                          "intro",
+		"Higress plugins can be written in Go, Rust or JavaScript and compiled to wasm": "plugins",
+	}
+	// The keyword backend returns the same chunks without chunk_title and with higher
+	// scores, so content de-duplication picks its copies as canonical documents.
+	retriever.Register("untitled_keyword", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		var results []schema.SearchResult
+		for text := range chunks {
+			results = append(results, schema.SearchResult{Document: schema.Document{ID: "kw:" + text, Content: text, Metadata: map[string]interface{}{"source": "es"}}, Score: 9})
+		}
+		return &stubRetriever{typ: "bm25", results: results}, nil
+	})
+
+	pipeline := config.DefaultPipeline()
+	pipeline.EnablePost = true
+	pipeline.EnableCRAG = true
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "untitled_keyword", Params: map[string]string{"name": "bm25"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{{Name: "default", Retrievers: []string{"vector", "bm25"}, TopK: 5, Threshold: 0.001}}
+	pipeline.DefaultProfile = "default"
+	pipeline.Post = &config.PostConfig{}
+	pipeline.Post.Rerank.Enable = true
+	pipeline.Post.Rerank.Provider = "keyword"
+	pipeline.Post.Compress.Enable = true
+	pipeline.Post.Compress.Method = "summary"
+	pipeline.CRAG = &config.CRAGConfig{}
+	pipeline.CRAG.Evaluator.Provider = "llm"
+	llmProvider := &MockLLMProvider{Respond: func(prompt string) (string, error) {
+		if strings.Contains(prompt, "Rate how relevant") {
+			return "0.9", nil
+		}
+		return "condensed", nil
+	}}
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, llmProvider)
+	for text, title := range chunks {
+		if _, err := client.CreateChunkFromText(text, title); err != nil {
+			t.Fatalf("CreateChunkFromText() error = %v", err)
+		}
+	}
+
+	resp, err := client.ChatWithSources("how are higress plugins written?", RequestOptions{})
+	if err != nil {
+		t.Fatalf("ChatWithSources() error = %v", err)
+	}
+	if len(resp.Sources) != len(chunks) {
+		t.Fatalf("sources = %d, want %d", len(resp.Sources), len(chunks))
+	}
+	for _, src := range resp.Sources {
+		if src.Document.Content != "condensed" {
+			t.Errorf("source content = %q, want the compressed text", src.Document.Content)
+		}
+		if title := src.Document.Title(); title != "intro" && title != "plugins" {
+			t.Errorf("source %s title = %q, want the ingested chunk_title", src.Document.ID, title)
+		}
+	}
+}
+
+func TestRAGClient_MetricsRecordGatingAndAppliedProfile(t *testing.T) {
+	retriever.Register("gated_web", func(cfg config.RetrieverConfig, deps retriever.Deps) (retriever.Retriever, error) {
+		return &stubRetriever{typ: "web", results: []schema.SearchResult{
+			{Document: schema.Document{ID: "web-1", Content: "Higress on the web"}, Score: 1},
+		}}, nil
+	})
+	pipeline := config.DefaultPipeline()
+	pipeline.Retrievers = []config.RetrieverConfig{{Type: "gated_web", Params: map[string]string{"name": "web"}}}
+	pipeline.RetrievalProfiles = []config.RetrievalProfile{{
+		Name:           "default",
+		Retrievers:     []string{"vector", "web"},
+		TopK:           5,
+		Threshold:      0.001,
+		VectorGate:     0.01,
+		VariantBudgets: map[string]int{"vector": 4},
+	}}
+	pipeline.DefaultProfile = "default"
+	client, _ := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}, Pipeline: pipeline}, nil)
+	if _, err := client.CreateChunkFromText("Higress is a cloud native API gateway", "intro"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+
+	_, _, m, err := client.runEnhancedPipeline(context.Background(), "higress gateway", RequestOptions{}, nil)
+	if err != nil {
+		t.Fatalf("runEnhancedPipeline() error = %v", err)
+	}
+	if m.GatingTopScore <= 0 || len(m.GatingDecisions) == 0 || !strings.HasPrefix(m.GatingDecisions[0], "suppress_web") {
+		t.Errorf("gating top score = %v decisions = %v, want web suppressed with the preflight score", m.GatingTopScore, m.GatingDecisions)
+	}
+	if len(m.AppliedRetrievers) != 1 || m.AppliedRetrievers[0] != "vector" || m.AppliedVariantBudgets["vector"] != 4 {
+		t.Errorf("applied retrievers = %v budgets = %v, want web removed by gating", m.AppliedRetrievers, m.AppliedVariantBudgets)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("metrics are not JSON: %v", err)
+	}
+	for _, key := range []string{`"gating_top_score"`, `"applied_retrievers":["vector"]`, `"applied_variant_budgets":{"vector":4}`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("metrics JSON %s missing %s", data, key)
+		}
+	}
+
+	trace, err := client.ExplainChat("higress gateway")
+	if err != nil {
+		t.Fatalf("ExplainChat() error = %v", err)
+	}
+	if trace.Profile.VariantBudgets["vector"] != 4 || len(trace.Profile.Retrievers) != 1 {
+		t.Errorf("trace profile = %+v, want the applied retrievers and budgets", trace.Profile)
+	}
+}
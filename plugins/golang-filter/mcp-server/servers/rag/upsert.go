@@ -0,0 +1,109 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+// UPSERT_PAGE_SIZE is the number of existing chunks read from the store per upsert query
+const UPSERT_PAGE_SIZE = 500
+
+// UpsertResult describes the outcome of UpsertChunksFromText
+type UpsertResult struct {
+	DocKey    string            `json:"doc_key"`
+	Unchanged bool              `json:"unchanged"`
+	Replaced  int               `json:"replaced"`
+	Chunks    []schema.Document `json:"chunks"`
+}
+
+// UpsertChunksFromText stores text as the chunks of the document identified by docKey,
+// replacing the chunks stored for it before. Every chunk records docKey and a hash of
+// title and text in its metadata; when the stored chunks already carry the same hash the
+// call is a no-op. The new chunks are written before the old ones are deleted, so the
+// document stays searchable throughout and a failed upsert leaves the old chunks in place.
+// If deleting the old chunks fails, rerunning the upsert removes them.
+func (r *RAGClient) UpsertChunksFromText(text string, title string, docKey string) (UpsertResult, error) {
+	r, done := r.acquire()
+	defer done()
+	docKey = strings.TrimSpace(docKey)
+	if docKey == "" {
+		return UpsertResult{}, fmt.Errorf("doc_key is required")
+	}
+	release, err := r.reindexGuard.beginIngestion()
+	if err != nil {
+		return UpsertResult{}, err
+	}
+	defer release()
+	ctx := context.Background()
+	hash := documentHash(title, text)
+
+	existing, err := r.chunksOfDocument(ctx, docKey)
+	if err != nil {
+		return UpsertResult{}, err
+	}
+	if len(existing) > 0 && allHaveHash(existing, hash) {
+		return UpsertResult{DocKey: docKey, Unchanged: true, Chunks: existing}, nil
+	}
+
+	docs, err := r.createChunks(text, title, map[string]any{
+		schema.METADATA_DOC_KEY:  docKey,
+		schema.METADATA_DOC_HASH: hash,
+	})
+	if err != nil {
+		return UpsertResult{}, err
+	}
+	if len(existing) > 0 {
+		ids := make([]string, len(existing))
+		for i, doc := range existing {
+			ids[i] = doc.ID
+		}
+		if err := r.vectordbProvider.DeleteDocs(ctx, ids); err != nil {
+			return UpsertResult{}, fmt.Errorf("delete replaced chunks failed, err: %w", err)
+		}
+		r.InvalidateCache()
+	}
+	return UpsertResult{DocKey: docKey, Replaced: len(existing), Chunks: docs}, nil
+}
+
+// chunksOfDocument returns every chunk stored for docKey in the client's namespace
+func (r *RAGClient) chunksOfDocument(ctx context.Context, docKey string) ([]schema.Document, error) {
+	filters := map[string]interface{}{schema.METADATA_DOC_KEY: docKey}
+	for key, value := range r.namespaceFilters() {
+		filters[key] = value
+	}
+	var chunks []schema.Document
+	for {
+		docs, err := r.vectordbProvider.QueryDocs(ctx, &schema.QueryOptions{
+			Filters: filters,
+			Limit:   UPSERT_PAGE_SIZE,
+			Offset:  len(chunks),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list chunks of %s failed, err: %w", docKey, err)
+		}
+		chunks = append(chunks, docs...)
+		if len(docs) < UPSERT_PAGE_SIZE {
+			return chunks, nil
+		}
+	}
+}
+
+// documentHash identifies the content of a document for UpsertChunksFromText
+func documentHash(title string, text string) string {
+	sum := sha256.Sum256([]byte(title + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+func allHaveHash(docs []schema.Document, hash string) bool {
+	for _, doc := range docs {
+		if h, _ := doc.Metadata[schema.METADATA_DOC_HASH].(string); h != hash {
+			return false
+		}
+	}
+	return true
+}
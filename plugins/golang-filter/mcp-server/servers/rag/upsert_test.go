@@ -0,0 +1,64 @@
+package rag
+
+import (
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+func TestRAGClient_UpsertChunksFromText(t *testing.T) {
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}}, nil)
+	if _, err := client.CreateChunkFromText("unrelated chunk", "other"); err != nil {
+		t.Fatalf("CreateChunkFromText() error = %v", err)
+	}
+
+	first, err := client.UpsertChunksFromText("Higress v1 routes traffic", "guide", "docs/guide.md")
+	if err != nil {
+		t.Fatalf("UpsertChunksFromText() error = %v", err)
+	}
+	if first.Unchanged || first.Replaced != 0 || len(first.Chunks) != 1 {
+		t.Fatalf("UpsertChunksFromText() = %+v, want one new chunk", first)
+	}
+	if meta := first.Chunks[0].Metadata; meta[schema.METADATA_DOC_KEY] != "docs/guide.md" || meta[schema.METADATA_DOC_HASH] == "" {
+		t.Errorf("chunk metadata = %v, want doc_key and doc_hash", meta)
+	}
+
+	again, err := client.UpsertChunksFromText("Higress v1 routes traffic", "guide", "docs/guide.md")
+	if err != nil || !again.Unchanged || again.Chunks[0].ID != first.Chunks[0].ID {
+		t.Errorf("UpsertChunksFromText() with identical content = %+v, %v, want a no-op", again, err)
+	}
+
+	updated, err := client.UpsertChunksFromText("Higress v2 routes traffic and runs Wasm plugins", "guide", "docs/guide.md")
+	if err != nil {
+		t.Fatalf("UpsertChunksFromText() error = %v", err)
+	}
+	if updated.Unchanged || updated.Replaced != 1 {
+		t.Errorf("UpsertChunksFromText() = %+v, want the old chunk replaced", updated)
+	}
+	contents := make(map[string]bool)
+	for _, doc := range store.docs {
+		contents[doc.Content] = true
+	}
+	if len(store.docs) != 2 || !contents["unrelated chunk"] || !contents["Higress v2 routes traffic and runs Wasm plugins"] {
+		t.Errorf("store = %v, want the unrelated chunk and the updated document only", contents)
+	}
+}
+
+func TestRAGClient_UpsertChunksFromTextScopedToNamespace(t *testing.T) {
+	client, store := newTestRAGClient(t, &config.Config{RAG: config.RAGConfig{TopK: 5}}, nil)
+	if _, err := client.WithNamespace("team-a").UpsertChunksFromText("team a notes", "notes", "notes.md"); err != nil {
+		t.Fatalf("UpsertChunksFromText() error = %v", err)
+	}
+	upserted, err := client.WithNamespace("team-b").UpsertChunksFromText("team b notes", "notes", "notes.md")
+	if err != nil || upserted.Replaced != 0 {
+		t.Errorf("UpsertChunksFromText() = %+v, %v, want other namespaces left alone", upserted, err)
+	}
+	if len(store.docs) != 2 {
+		t.Errorf("store holds %d chunks, want one per namespace", len(store.docs))
+	}
+
+	if _, err := client.UpsertChunksFromText("text", "title", " "); err == nil {
+		t.Error("UpsertChunksFromText() expected error without doc_key")
+	}
+}
@@ -18,6 +18,9 @@ var (
 	ErrUnsupportedOperation = errors.New("unsupported operation")
 )
 
+// SPARSE_VECTOR_FIELD is the standard name of the field holding Document.SparseVector
+const SPARSE_VECTOR_FIELD = "sparse_vector"
+
 // VectorDBMapper interface for vector database mapping
 type VectorDBMapper interface {
 	// ParseMapping parses the mapping configuration
@@ -78,36 +81,7 @@ func (m *DefaultVectorDBMapper) ParseMapping(provider string, cfg config.Mapping
 	m.rawFieldMap = make(map[string]*config.FieldMapping)
 	// fill default field mappings
 	if len(cfg.Fields) == 0 {
-		defaultFields := []config.FieldMapping{
-			{
-				StandardName: "id",
-				RawName:      "id",
-				Properties: map[string]interface{}{
-					"max_length": 256,
-					"auto_id":    false,
-				},
-			},
-			{
-				StandardName: "content",
-				RawName:      "content",
-				Properties: map[string]interface{}{
-					"max_length": 8192,
-				},
-			},
-			{
-				StandardName: "vector",
-				RawName:      "vector",
-			},
-			{
-				StandardName: "metadata",
-				RawName:      "metadata",
-			},
-			{
-				StandardName: "created_at",
-				RawName:      "created_at",
-			},
-		}
-		cfg.Fields = defaultFields
+		cfg.Fields = defaultFieldMappings()
 	}
 
 	// Parse field mappings
@@ -129,6 +103,56 @@ func (m *DefaultVectorDBMapper) ParseMapping(provider string, cfg config.Mapping
 	return nil
 }
 
+// defaultFieldMappings returns the fields used when the mapping configures none
+func defaultFieldMappings() []config.FieldMapping {
+	return []config.FieldMapping{
+		{
+			StandardName: "id",
+			RawName:      "id",
+			Properties: map[string]interface{}{
+				"max_length": 256,
+				"auto_id":    false,
+			},
+		},
+		{
+			StandardName: "content",
+			RawName:      "content",
+			Properties: map[string]interface{}{
+				"max_length": 8192,
+			},
+		},
+		{
+			StandardName: "vector",
+			RawName:      "vector",
+		},
+		{
+			StandardName: "metadata",
+			RawName:      "metadata",
+		},
+		{
+			StandardName: "created_at",
+			RawName:      "created_at",
+		},
+	}
+}
+
+// withSparseVectorField returns mapping with a sparse_vector field appended to its fields,
+// or to the default fields when it configures none, unless it already maps one
+func withSparseVectorField(mapping config.MappingConfig) config.MappingConfig {
+	fields := mapping.Fields
+	if len(fields) == 0 {
+		fields = defaultFieldMappings()
+	}
+	for _, field := range fields {
+		if field.StandardName == SPARSE_VECTOR_FIELD {
+			return mapping
+		}
+	}
+	mapping.Fields = append(append([]config.FieldMapping(nil), fields...),
+		config.FieldMapping{StandardName: SPARSE_VECTOR_FIELD, RawName: SPARSE_VECTOR_FIELD})
+	return mapping
+}
+
 // GetIndexConfig gets the index configuration
 func (m *DefaultVectorDBMapper) GetIndexConfig() (config.IndexConfig, error) {
 	return m.mappingConfig.Index, nil
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +18,9 @@ import (
 const (
 	MILVUS_DUMMY_DIM     = 8
 	MILVUS_PROVIDER_TYPE = "milvus"
+	MILVUS_COUNT_FIELD   = "count(*)"
+	// MILVUS_SPARSE_INDEX_NAME names the inverted index built on the sparse vector field
+	MILVUS_SPARSE_INDEX_NAME = "sparse_vector_index"
 )
 
 // MilvusProviderInitializer initializes the Milvus vector store provider
@@ -83,6 +88,8 @@ type MilvusProvider struct {
 	collection string
 	mapper     VectorDBMapper
 	dimensions int
+	// sparse is set by vectordb.enable_sparse; the collection then has a sparse vector field
+	sparse bool
 }
 
 // NewMilvusProvider creates a new instance of MilvusProvider
@@ -103,7 +110,11 @@ func NewMilvusProvider(cfg *config.VectorDBConfig, dimensions int) (VectorStoreP
 		return nil, fmt.Errorf("failed to create milvus client: %w", err)
 	}
 
-	mapper, err := NewDefaultVectorDBMapper(MILVUS_PROVIDER_TYPE, cfg.Mapping)
+	mapping := cfg.Mapping
+	if cfg.EnableSparse {
+		mapping = withSparseVectorField(mapping)
+	}
+	mapper, err := NewDefaultVectorDBMapper(MILVUS_PROVIDER_TYPE, mapping)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create default vector db mapper: %w", err)
 	}
@@ -114,6 +125,7 @@ func NewMilvusProvider(cfg *config.VectorDBConfig, dimensions int) (VectorStoreP
 		collection: cfg.Collection,
 		mapper:     mapper,
 		dimensions: dimensions,
+		sparse:     cfg.EnableSparse,
 	}
 	ctx := context.Background()
 	if err := provider.CreateCollection(ctx, dimensions); err != nil {
@@ -161,6 +173,11 @@ func (m *MilvusProvider) buildSchema() (*entity.Schema, error) {
 				WithDataType(entity.FieldTypeFloatVector).
 				WithDim(int64(m.dimensions))
 			schema.WithField(fieldEntity)
+		case SPARSE_VECTOR_FIELD:
+			fieldEntity = entity.NewField().
+				WithName(field.RawName).
+				WithDataType(entity.FieldTypeSparseVector)
+			schema.WithField(fieldEntity)
 		case "metadata":
 			fieldEntity = entity.NewField().
 				WithName(field.RawName).
@@ -395,6 +412,21 @@ func (m *MilvusProvider) CreateCollection(ctx context.Context, dim int) error {
 		if err != nil {
 			return fmt.Errorf("failed to create vector index: %w", err)
 		}
+		if m.sparse {
+			sparseIndex, err := entity.NewIndexSparseInverted(entity.IP, 0)
+			if err != nil {
+				return fmt.Errorf("failed to create sparse vector index: %w", err)
+			}
+			sparseField, _ := m.mapper.GetRawField(SPARSE_VECTOR_FIELD)
+			err = m.client.CreateIndex(ctx, m.collection, sparseField.RawName, sparseIndex, false, client.WithIndexName(MILVUS_SPARSE_INDEX_NAME))
+			if err != nil {
+				return fmt.Errorf("failed to create sparse vector index: %w", err)
+			}
+		}
+	} else if m.sparse {
+		if err := m.checkSparseField(ctx); err != nil {
+			return err
+		}
 	}
 	// Load collection
 	err = m.client.LoadCollection(ctx, m.collection, false)
@@ -404,6 +436,26 @@ func (m *MilvusProvider) CreateCollection(ctx context.Context, dim int) error {
 	return nil
 }
 
+// checkSparseField verifies that an existing collection has the sparse vector field, since
+// enable_sparse cannot add a field to a collection created without it
+func (m *MilvusProvider) checkSparseField(ctx context.Context) error {
+	coll, err := m.client.DescribeCollection(ctx, m.collection)
+	if err != nil {
+		return fmt.Errorf("failed to describe %s collection: %w", m.collection, err)
+	}
+	sparseField, _ := m.mapper.GetRawField(SPARSE_VECTOR_FIELD)
+	for _, field := range coll.Schema.Fields {
+		if field.Name == sparseField.RawName {
+			if field.DataType != entity.FieldTypeSparseVector {
+				return fmt.Errorf("collection %s field %s is not a sparse vector field", m.collection, field.Name)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("collection %s has no sparse vector field %s; enable_sparse requires a collection created with it",
+		m.collection, sparseField.RawName)
+}
+
 // DropCollection removes the collection from the database
 func (m *MilvusProvider) DropCollection(ctx context.Context) error {
 	// Check if collection exists
@@ -463,6 +515,14 @@ func (m *MilvusProvider) AddDoc(ctx context.Context, docs []schema.Document) err
 				vectors[i] = doc.Vector
 			}
 			columns = append(columns, entity.NewColumnFloatVector(field.RawName, len(vectors[0]), vectors))
+		case SPARSE_VECTOR_FIELD:
+			vectors := make([]entity.SparseEmbedding, len(docs))
+			for i, doc := range docs {
+				if vectors[i], err = toSparseEmbedding(doc.SparseVector); err != nil {
+					return fmt.Errorf("invalid sparse vector for doc %s: %w", doc.ID, err)
+				}
+			}
+			columns = append(columns, entity.NewColumnSparseVectors(field.RawName, vectors))
 		case "metadata":
 			// Handle JSON type fields (like metadata)
 			values := make([][]byte, len(docs))
@@ -644,13 +704,16 @@ func (m *MilvusProvider) SearchDocs(ctx context.Context, vector []float32, optio
 		return nil, fmt.Errorf("failed to build search param: %w", err)
 	}
 
-	outputFields, _ := m.mapper.GetRawAllFieldNames()
+	outputFields := m.searchOutputFields(options.IncludeVectors)
 	vectorField, _ := m.mapper.GetVectorField()
 	searchConfig, _ := m.mapper.GetSearchConfig()
 	metricType := m.GetMetricType(searchConfig.MetricType)
 
 	// Build filter expression
-	expr := ""
+	expr, err := m.buildFilterExpr(nil, options.Filters)
+	if err != nil {
+		return nil, err
+	}
 	searchResults, err := m.client.Search(
 		ctx,
 		m.collection,
@@ -667,8 +730,78 @@ func (m *MilvusProvider) SearchDocs(ctx context.Context, vector []float32, optio
 	if err != nil {
 		return nil, fmt.Errorf("failed to search documents: %w", err)
 	}
+	return m.parseSearchResults(searchResults), nil
+}
+
+// SearchSparse ranks documents by the inner product of their sparse vectors with vector.
+// It requires vectordb.enable_sparse.
+func (m *MilvusProvider) SearchSparse(ctx context.Context, vector schema.SparseVector, options *schema.SearchOptions) ([]schema.SearchResult, error) {
+	if !m.sparse {
+		return nil, fmt.Errorf("%w: sparse search requires vectordb.enable_sparse", ErrUnsupportedOperation)
+	}
+	if options == nil {
+		options = &schema.SearchOptions{TopK: 10}
+	}
+	query, err := toSparseEmbedding(vector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sparse query vector: %w", err)
+	}
+	sp, err := entity.NewIndexSparseInvertedSearchParam(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sparse search param: %w", err)
+	}
+	expr, err := m.buildFilterExpr(nil, options.Filters)
+	if err != nil {
+		return nil, err
+	}
+	outputFields := m.searchOutputFields(options.IncludeVectors)
+	sparseField, _ := m.mapper.GetRawField(SPARSE_VECTOR_FIELD)
+	searchResults, err := m.client.Search(
+		ctx,
+		m.collection,
+		[]string{},
+		expr,
+		outputFields,
+		[]entity.Vector{query},
+		sparseField.RawName,
+		entity.IP, // sparse vectors only support inner product
+		options.TopK,
+		sp,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search sparse vectors: %w", err)
+	}
+	return m.parseSearchResults(searchResults), nil
+}
 
-	// Parse results
+// searchOutputFields returns the fields searches return. The sparse vector is never
+// returned, the dense vector only when includeVectors is set since it dominates the payload.
+func (m *MilvusProvider) searchOutputFields(includeVectors bool) []string {
+	rawNames, _ := m.mapper.GetRawAllFieldNames()
+	fields := make([]string, 0, len(rawNames))
+	for _, name := range rawNames {
+		field, err := m.mapper.GetField(name)
+		if err == nil && (field.StandardName == SPARSE_VECTOR_FIELD || (field.StandardName == "vector" && !includeVectors)) {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// toSparseEmbedding converts a sparse vector to the Milvus representation
+func toSparseEmbedding(vector schema.SparseVector) (entity.SparseEmbedding, error) {
+	positions := make([]uint32, 0, len(vector))
+	values := make([]float32, 0, len(vector))
+	for position, value := range vector {
+		positions = append(positions, position)
+		values = append(values, value)
+	}
+	return entity.NewSliceSparseEmbedding(positions, values)
+}
+
+// parseSearchResults converts Milvus search results to documents with their scores
+func (m *MilvusProvider) parseSearchResults(searchResults []client.SearchResult) []schema.SearchResult {
 	var results []schema.SearchResult
 	for _, result := range searchResults {
 		for i := 0; i < result.ResultCount; i++ {
@@ -676,6 +809,7 @@ func (m *MilvusProvider) SearchDocs(ctx context.Context, vector []float32, optio
 			score := result.Scores[i]
 			// Get field data
 			var content string
+			var vector []float32
 			var metadata map[string]interface{}
 			for _, field := range result.Fields {
 				fieldMapping, err := m.mapper.GetField(field.Name())
@@ -692,6 +826,10 @@ func (m *MilvusProvider) SearchDocs(ctx context.Context, vector []float32, optio
 							}
 						}
 					}
+				case "vector":
+					if vecCol, ok := field.(*entity.ColumnFloatVector); ok && i < len(vecCol.Data()) {
+						vector = vecCol.Data()[i]
+					}
 				case "metadata":
 					if metaCol, ok := field.(*entity.ColumnJSONBytes); ok {
 						if metaVal, err := metaCol.Get(i); err == nil {
@@ -708,6 +846,7 @@ func (m *MilvusProvider) SearchDocs(ctx context.Context, vector []float32, optio
 				Document: schema.Document{
 					ID:       fmt.Sprintf("%s", id),
 					Content:  content,
+					Vector:   vector,
 					Metadata: metadata,
 				},
 				Score: float64(score),
@@ -715,7 +854,7 @@ func (m *MilvusProvider) SearchDocs(ctx context.Context, vector []float32, optio
 			results = append(results, searchResult)
 		}
 	}
-	return results, nil
+	return results
 }
 
 // DeleteDocs deletes multiple documents by their IDs
@@ -751,8 +890,23 @@ func (m *MilvusProvider) DeleteDocs(ctx context.Context, ids []string) error {
 
 // ListDocs retrieves all documents with optional limit
 func (m *MilvusProvider) ListDocs(ctx context.Context, limit int) ([]schema.Document, error) {
+	return m.QueryDocs(ctx, &schema.QueryOptions{Limit: limit})
+}
+
+// QueryDocs retrieves documents matching the given IDs and metadata filters
+func (m *MilvusProvider) QueryDocs(ctx context.Context, options *schema.QueryOptions) ([]schema.Document, error) {
+	if options == nil {
+		options = &schema.QueryOptions{}
+	}
 	// Build query expression
-	expr := ""
+	expr, err := m.buildFilterExpr(options.IDs, options.Filters)
+	if err != nil {
+		return nil, err
+	}
+	queryOptions := []client.SearchQueryOptionFunc{client.WithOffset(int64(options.Offset))}
+	if options.Limit > 0 {
+		queryOptions = append(queryOptions, client.WithLimit(int64(options.Limit)))
+	}
 	// Query all relevant documents
 	outputFields, _ := m.mapper.GetRawAllFieldNames()
 	queryResult, err := m.client.Query(
@@ -761,7 +915,7 @@ func (m *MilvusProvider) ListDocs(ctx context.Context, limit int) ([]schema.Docu
 		[]string{}, // partitions
 		expr,       // filter condition
 		outputFields,
-		client.WithOffset(0), client.WithLimit(int64(limit)),
+		queryOptions...,
 	)
 
 	if err != nil {
@@ -780,6 +934,7 @@ func (m *MilvusProvider) ListDocs(ctx context.Context, limit int) ([]schema.Docu
 		var (
 			id        string
 			content   string
+			vector    []float32
 			metadata  map[string]interface{}
 			createdAt int64
 		)
@@ -799,6 +954,10 @@ func (m *MilvusProvider) ListDocs(ctx context.Context, limit int) ([]schema.Docu
 				if v, err := col.(*entity.ColumnVarChar).Get(i); err == nil {
 					content = v.(string)
 				}
+			case "vector":
+				if vecCol, ok := col.(*entity.ColumnFloatVector); ok && i < len(vecCol.Data()) {
+					vector = vecCol.Data()[i]
+				}
 			case "metadata":
 				if v, err := col.(*entity.ColumnJSONBytes).Get(i); err == nil {
 					if bytes, ok := v.([]byte); ok {
@@ -815,6 +974,7 @@ func (m *MilvusProvider) ListDocs(ctx context.Context, limit int) ([]schema.Docu
 		doc := schema.Document{
 			ID:        id,
 			Content:   content,
+			Vector:    vector,
 			Metadata:  metadata,
 			CreatedAt: time.UnixMilli(createdAt),
 		}
@@ -823,6 +983,102 @@ func (m *MilvusProvider) ListDocs(ctx context.Context, limit int) ([]schema.Docu
 	return documents, nil
 }
 
+// Count returns the number of documents matching the metadata filters
+func (m *MilvusProvider) Count(ctx context.Context, filters map[string]interface{}) (int64, error) {
+	expr, err := m.buildFilterExpr(nil, filters)
+	if err != nil {
+		return 0, err
+	}
+	queryResult, err := m.client.Query(ctx, m.collection, []string{}, expr, []string{MILVUS_COUNT_FIELD})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+	countCol, ok := queryResult.GetColumn(MILVUS_COUNT_FIELD).(*entity.ColumnInt64)
+	if !ok || countCol.Len() == 0 {
+		return 0, fmt.Errorf("failed to count documents: missing %s in query result", MILVUS_COUNT_FIELD)
+	}
+	return countCol.ValueByIdx(0)
+}
+
+// DeleteByFilter deletes the documents matching the metadata filters with a single
+// filter expression; the matches are counted first since Milvus does not report them
+func (m *MilvusProvider) DeleteByFilter(ctx context.Context, filters map[string]interface{}) (int64, error) {
+	if len(filters) == 0 {
+		return 0, errEmptyFilter
+	}
+	count, err := m.Count(ctx, filters)
+	if err != nil || count == 0 {
+		return 0, err
+	}
+	expr, err := m.buildFilterExpr(nil, filters)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.client.Delete(ctx, m.collection, "", expr); err != nil {
+		return 0, fmt.Errorf("failed to delete documents: %w", err)
+	}
+	if err := m.client.Flush(ctx, m.collection, false); err != nil {
+		return 0, fmt.Errorf("failed to flush collection after delete: %w", err)
+	}
+	return count, nil
+}
+
+// buildFilterExpr builds a boolean expression matching any of ids and every metadata filter.
+// Filter values are compared against keys of the JSON metadata field.
+func (m *MilvusProvider) buildFilterExpr(ids []string, filters map[string]interface{}) (string, error) {
+	clauses := make([]string, 0, len(filters)+1)
+	if len(ids) > 0 {
+		idField, _ := m.mapper.GetIDField()
+		quotedIDs := make([]string, len(ids))
+		for i, id := range ids {
+			quotedIDs[i] = strconv.Quote(id)
+		}
+		clauses = append(clauses, fmt.Sprintf("%s in [%s]", idField.RawName, strings.Join(quotedIDs, ",")))
+	}
+	if len(filters) > 0 {
+		metadataField, err := m.mapper.GetRawField("metadata")
+		if err != nil {
+			return "", fmt.Errorf("metadata filters require a metadata field: %w", err)
+		}
+		keys := make([]string, 0, len(filters))
+		for key := range filters {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, err := formatFilterValue(filters[key])
+			if err != nil {
+				return "", fmt.Errorf("invalid filter %s: %w", key, err)
+			}
+			clauses = append(clauses, fmt.Sprintf("%s[%s] == %s", metadataField.RawName, strconv.Quote(key), value))
+		}
+	}
+	return strings.Join(clauses, " && "), nil
+}
+
+// formatFilterValue renders a scalar filter value as a Milvus expression literal
+func formatFilterValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int32, int64, float32, float64:
+		return fmt.Sprintf("%v", v), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+// Ping describes the collection, which fails when Milvus is unreachable or the collection
+// is missing
+func (m *MilvusProvider) Ping(ctx context.Context) error {
+	if _, err := m.client.DescribeCollection(ctx, m.collection); err != nil {
+		return fmt.Errorf("failed to describe %s collection: %w", m.collection, err)
+	}
+	return nil
+}
+
 // GetProviderType returns the provider type identifier
 func (m *MilvusProvider) GetProviderType() string {
 	return MILVUS_PROVIDER_TYPE
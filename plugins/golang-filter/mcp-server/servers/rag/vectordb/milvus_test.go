@@ -1,9 +1,14 @@
 package vectordb
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 )
 
 func TestNewMilvusProvider(t *testing.T) {
@@ -25,3 +30,121 @@ func getMilvusProvider() (VectorStoreProvider, error) {
 	provider, err := NewMilvusProvider(cfg, 128)
 	return provider, err
 }
+
+func TestMilvusProvider_BuildFilterExpr(t *testing.T) {
+	mapper, err := NewDefaultVectorDBMapper(MILVUS_PROVIDER_TYPE, config.MappingConfig{})
+	if err != nil {
+		t.Fatalf("NewDefaultVectorDBMapper() error = %v", err)
+	}
+	provider := &MilvusProvider{mapper: mapper}
+
+	tests := []struct {
+		name    string
+		ids     []string
+		filters map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", want: ""},
+		{name: "ids", ids: []string{"a-1", "b-2"}, want: `id in ["a-1","b-2"]`},
+		{
+			name:    "namespace",
+			filters: map[string]interface{}{"namespace": "team-a"},
+			want:    `metadata["namespace"] == "team-a"`,
+		},
+		{
+			name:    "ids and sorted filters",
+			ids:     []string{"a-1"},
+			filters: map[string]interface{}{"namespace": `te"am`, "chunk_index": 2},
+			want:    `id in ["a-1"] && metadata["chunk_index"] == 2 && metadata["namespace"] == "te\"am"`,
+		},
+		{name: "unsupported value", filters: map[string]interface{}{"tags": []string{"x"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := provider.buildFilterExpr(tt.ids, tt.filters)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildFilterExpr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("buildFilterExpr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMilvusProvider_SparseField(t *testing.T) {
+	mapper, err := NewDefaultVectorDBMapper(MILVUS_PROVIDER_TYPE, withSparseVectorField(config.MappingConfig{}))
+	if err != nil {
+		t.Fatalf("NewDefaultVectorDBMapper() error = %v", err)
+	}
+	provider := &MilvusProvider{mapper: mapper, collection: "c", dimensions: 8, sparse: true}
+	collSchema, err := provider.buildSchema()
+	if err != nil {
+		t.Fatalf("buildSchema() error = %v", err)
+	}
+	var types []entity.FieldType
+	for _, field := range collSchema.Fields {
+		types = append(types, field.DataType)
+	}
+	want := []entity.FieldType{entity.FieldTypeVarChar, entity.FieldTypeVarChar, entity.FieldTypeFloatVector,
+		entity.FieldTypeJSON, entity.FieldTypeInt64, entity.FieldTypeSparseVector}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("schema field types = %v, want the default fields and a sparse vector", types)
+	}
+
+	// A mapping that already maps the field is kept as is
+	mapping := withSparseVectorField(config.MappingConfig{})
+	mapping.Fields[5].RawName = "splade"
+	if got := withSparseVectorField(mapping); len(got.Fields) != 6 || got.Fields[5].RawName != "splade" {
+		t.Errorf("withSparseVectorField() = %+v, want the mapped field kept", got.Fields)
+	}
+}
+
+func TestMilvusProvider_SearchOutputFields(t *testing.T) {
+	mapper, err := NewDefaultVectorDBMapper(MILVUS_PROVIDER_TYPE, withSparseVectorField(config.MappingConfig{}))
+	if err != nil {
+		t.Fatalf("NewDefaultVectorDBMapper() error = %v", err)
+	}
+	provider := &MilvusProvider{mapper: mapper}
+	vectorField, _ := mapper.GetVectorField()
+	for _, includeVectors := range []bool{false, true} {
+		fields := provider.searchOutputFields(includeVectors)
+		hasVector := false
+		for _, name := range fields {
+			hasVector = hasVector || name == vectorField.RawName
+			if name == SPARSE_VECTOR_FIELD {
+				t.Errorf("searchOutputFields(%v) = %v, want no sparse vector", includeVectors, fields)
+			}
+		}
+		if hasVector != includeVectors || len(fields) < 4 {
+			t.Errorf("searchOutputFields(%v) = %v", includeVectors, fields)
+		}
+	}
+}
+
+func TestMilvusProvider_SearchSparseRequiresEnableSparse(t *testing.T) {
+	mapper, _ := NewDefaultVectorDBMapper(MILVUS_PROVIDER_TYPE, config.MappingConfig{})
+	provider := &MilvusProvider{mapper: mapper}
+	if _, err := provider.SearchSparse(context.Background(), schema.SparseVector{1: 0.5}, nil); !errors.Is(err, ErrUnsupportedOperation) {
+		t.Errorf("SearchSparse() error = %v, want ErrUnsupportedOperation", err)
+	}
+}
+
+func TestToSparseEmbedding(t *testing.T) {
+	got, err := toSparseEmbedding(schema.SparseVector{42: 0.5, 7: 1.25})
+	if err != nil {
+		t.Fatalf("toSparseEmbedding() error = %v", err)
+	}
+	if got.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", got.Len())
+	}
+	for i, want := range []struct {
+		pos   uint32
+		value float32
+	}{{7, 1.25}, {42, 0.5}} {
+		if pos, value, ok := got.Get(i); !ok || pos != want.pos || value != want.value {
+			t.Errorf("Get(%d) = %d, %v, want %d, %v in position order", i, pos, value, want.pos, want.value)
+		}
+	}
+}
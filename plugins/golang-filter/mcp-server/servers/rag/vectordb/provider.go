@@ -2,6 +2,7 @@ package vectordb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
@@ -45,10 +46,138 @@ type VectorStoreProvider interface {
 	// ListDocs lists documents in the vector store
 	ListDocs(ctx context.Context, limit int) ([]schema.Document, error)
 
+	// QueryDocs lists documents matching the given IDs and metadata filters
+	QueryDocs(ctx context.Context, options *schema.QueryOptions) ([]schema.Document, error)
+
+	// Count returns the number of documents matching the metadata filters
+	Count(ctx context.Context, filters map[string]interface{}) (int64, error)
+
+	// DeleteByFilter deletes every document matching the metadata filters and returns the
+	// number of documents deleted. Empty filters are rejected.
+	DeleteByFilter(ctx context.Context, filters map[string]interface{}) (int64, error)
+
+	// Ping checks that the store is reachable and the collection exists
+	Ping(ctx context.Context) error
+
 	// GetProviderType returns the type of the vector store provider
 	GetProviderType() string
 }
 
+// SparseSearcher is implemented by vector stores that index Document.SparseVector and
+// can rank documents by sparse inner product
+type SparseSearcher interface {
+	// SearchSparse searches for documents whose sparse vectors best match the query vector
+	SearchSparse(ctx context.Context, vector schema.SparseVector, options *schema.SearchOptions) ([]schema.SearchResult, error)
+}
+
+// DELETE_BY_FILTER_PAGE_SIZE is the number of documents DeleteMatching reads per page
+const DELETE_BY_FILTER_PAGE_SIZE = 500
+
+// errEmptyFilter rejects a DeleteByFilter that would delete the whole collection
+var errEmptyFilter = errors.New("delete by filter requires at least one filter")
+
+// DeleteMatching implements DeleteByFilter for stores that cannot filter server-side: it
+// pages through every document, matches metadata in Go and deletes the matches by ID.
+func DeleteMatching(ctx context.Context, p VectorStoreProvider, filters map[string]interface{}) (int64, error) {
+	if len(filters) == 0 {
+		return 0, errEmptyFilter
+	}
+	var ids []string
+	for offset := 0; ; offset += DELETE_BY_FILTER_PAGE_SIZE {
+		docs, err := p.QueryDocs(ctx, &schema.QueryOptions{Limit: DELETE_BY_FILTER_PAGE_SIZE, Offset: offset})
+		if err != nil {
+			return 0, fmt.Errorf("failed to list documents: %w", err)
+		}
+		for _, doc := range docs {
+			if MatchesFilters(doc.Metadata, filters) {
+				ids = append(ids, doc.ID)
+			}
+		}
+		if len(docs) < DELETE_BY_FILTER_PAGE_SIZE {
+			break
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := p.DeleteDocs(ctx, ids); err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+// SEARCH_FILTER_OVERFETCH is the factor SearchMatching widens TopK by before filtering
+const SEARCH_FILTER_OVERFETCH = 5
+
+// SearchMatching implements filtered SearchDocs for stores that cannot filter server-side:
+// search runs without filters for SEARCH_FILTER_OVERFETCH times TopK results, and the
+// results whose metadata matches options.Filters are kept up to TopK. Matches ranked
+// beyond the over-fetched results are missed.
+func SearchMatching(ctx context.Context, search func(context.Context, *schema.SearchOptions) ([]schema.SearchResult, error), options *schema.SearchOptions) ([]schema.SearchResult, error) {
+	if len(options.Filters) == 0 {
+		return search(ctx, options)
+	}
+	widened := *options
+	widened.Filters = nil
+	if widened.TopK > 0 {
+		widened.TopK *= SEARCH_FILTER_OVERFETCH
+	}
+	results, err := search(ctx, &widened)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]schema.SearchResult, 0, len(results))
+	for _, result := range results {
+		if MatchesFilters(result.Document.Metadata, options.Filters) {
+			matched = append(matched, result)
+		}
+		if options.TopK > 0 && len(matched) == options.TopK {
+			break
+		}
+	}
+	return matched, nil
+}
+
+// MatchesFilters reports whether metadata holds every filter value. Numbers compare by
+// value, since metadata decoded from JSON carries float64 where the filter may carry int.
+func MatchesFilters(metadata map[string]interface{}, filters map[string]interface{}) bool {
+	for key, want := range filters {
+		got, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		gotNumber, gotIsNumber := toFloat64(got)
+		wantNumber, wantIsNumber := toFloat64(want)
+		switch {
+		case gotIsNumber || wantIsNumber:
+			if !gotIsNumber || !wantIsNumber || gotNumber != wantNumber {
+				return false
+			}
+		case got != want:
+			return false
+		}
+	}
+	return true
+}
+
+// toFloat64 converts a numeric metadata value to float64
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
 // VectorDBProviderInitializer defines the interface for vector database provider initializers
 type VectorDBProviderInitializer interface {
 	// CreateProvider creates a new vector database provider instance
@@ -57,7 +186,8 @@ type VectorDBProviderInitializer interface {
 
 var (
 	vectorDBProviderInitializers = map[string]VectorDBProviderInitializer{
-		PROVIDER_TYPE_MILVUS: &milvusProviderInitializer{},
+		PROVIDER_TYPE_MILVUS:   &milvusProviderInitializer{},
+		PROVIDER_TYPE_WEAVIATE: &weaviateProviderInitializer{},
 	}
 )
 
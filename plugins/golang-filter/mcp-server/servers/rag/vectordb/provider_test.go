@@ -0,0 +1,69 @@
+package vectordb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+)
+
+func TestMatchesFilters(t *testing.T) {
+	metadata := map[string]interface{}{"chunk_title": "foo", "chunk_index": float64(2), "public": true}
+	cases := []struct {
+		filters map[string]interface{}
+		want    bool
+	}{
+		{nil, true},
+		{map[string]interface{}{"chunk_title": "foo"}, true},
+		{map[string]interface{}{"chunk_title": "foo", "chunk_index": 2}, true},
+		{map[string]interface{}{"public": true, "chunk_index": int64(2)}, true},
+		{map[string]interface{}{"chunk_title": "bar"}, false},
+		{map[string]interface{}{"chunk_index": "2"}, false},
+		{map[string]interface{}{"chunk_index": 3}, false},
+		{map[string]interface{}{"missing": "foo"}, false},
+	}
+	for _, c := range cases {
+		if got := MatchesFilters(metadata, c.filters); got != c.want {
+			t.Errorf("MatchesFilters(%v) = %v, want %v", c.filters, got, c.want)
+		}
+	}
+}
+
+func TestSearchMatching(t *testing.T) {
+	// Every third document belongs to tenant t1
+	var all []schema.SearchResult
+	for i := 0; i < 20; i++ {
+		tenant := "t2"
+		if i%3 == 0 {
+			tenant = "t1"
+		}
+		all = append(all, schema.SearchResult{
+			Document: schema.Document{ID: fmt.Sprintf("doc-%d", i), Metadata: map[string]interface{}{"tenant_id": tenant}},
+			Score:    1 - float64(i)/100,
+		})
+	}
+	var requested *schema.SearchOptions
+	search := func(ctx context.Context, options *schema.SearchOptions) ([]schema.SearchResult, error) {
+		requested = options
+		if options.TopK < len(all) {
+			return all[:options.TopK], nil
+		}
+		return all, nil
+	}
+
+	results, err := SearchMatching(context.Background(), search, &schema.SearchOptions{TopK: 3, Filters: map[string]interface{}{"tenant_id": "t1"}})
+	if err != nil {
+		t.Fatalf("SearchMatching() error = %v", err)
+	}
+	if requested.TopK != 3*SEARCH_FILTER_OVERFETCH || requested.Filters != nil {
+		t.Errorf("search options = %+v, want an unfiltered over-fetch", requested)
+	}
+	if len(results) != 3 || results[0].Document.ID != "doc-0" || results[1].Document.ID != "doc-3" || results[2].Document.ID != "doc-6" {
+		t.Errorf("SearchMatching() = %+v, want the top 3 t1 documents", results)
+	}
+
+	if results, _ := SearchMatching(context.Background(), search, &schema.SearchOptions{TopK: 2}); len(results) != 2 || requested.TopK != 2 {
+		t.Errorf("unfiltered SearchMatching() = %d results with TopK %d, want a plain search", len(results), requested.TopK)
+	}
+}
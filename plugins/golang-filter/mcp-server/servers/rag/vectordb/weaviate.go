@@ -0,0 +1,1067 @@
+package vectordb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+	"github.com/google/uuid"
+)
+
+const (
+	WEAVIATE_PROVIDER_TYPE   = "weaviate"
+	WEAVIATE_DEFAULT_PORT    = 8080
+	WEAVIATE_DEFAULT_TIMEOUT = 30 * time.Second
+	// WEAVIATE_BATCH_SIZE bounds the objects sent in one batch insert or delete request
+	WEAVIATE_BATCH_SIZE = 100
+	// WEAVIATE_PAGE_SIZE is the page size used when listing without a limit
+	WEAVIATE_PAGE_SIZE = 100
+)
+
+// Weaviate data types used by the class schema
+const (
+	weaviateTypeText    = "text"
+	weaviateTypeInt     = "int"
+	weaviateTypeNumber  = "number"
+	weaviateTypeBoolean = "boolean"
+)
+
+// weaviateReservedProperties are property names Weaviate does not allow in a class schema
+var weaviateReservedProperties = map[string]bool{"id": true, "_id": true, "_additional": true}
+
+// weaviateStandardFields are the standard fields stored as class properties; vectors are
+// stored on the object itself
+var weaviateStandardFields = []string{"id", "content", "metadata", "created_at"}
+
+// errNoMatch reports a filter that no stored object can satisfy, such as a metadata key
+// that was never written
+var errNoMatch = errors.New("filter matches no documents")
+
+// weaviateProviderInitializer initializes the Weaviate vector store provider
+type weaviateProviderInitializer struct{}
+
+// InitConfig initializes the configuration with default values if not set
+func (w *weaviateProviderInitializer) InitConfig(cfg *config.VectorDBConfig) error {
+	if cfg.Provider != WEAVIATE_PROVIDER_TYPE {
+		return fmt.Errorf("provider type mismatch: expected %s, got %s", WEAVIATE_PROVIDER_TYPE, cfg.Provider)
+	}
+	if cfg.Host == "" {
+		cfg.Host = "localhost"
+	}
+	if cfg.Port == 0 {
+		cfg.Port = WEAVIATE_DEFAULT_PORT
+	}
+	if cfg.Collection == "" {
+		cfg.Collection = schema.DEFAULT_DOCUMENT_COLLECTION
+	}
+	return nil
+}
+
+// ValidateConfig validates the configuration parameters
+func (w *weaviateProviderInitializer) ValidateConfig(cfg *config.VectorDBConfig) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("weaviate host is required")
+	}
+	if cfg.Port <= 0 {
+		return fmt.Errorf("weaviate port must be positive")
+	}
+	if cfg.GRPCPort < 0 {
+		return fmt.Errorf("weaviate grpc_port must not be negative")
+	}
+	if cfg.Collection == "" {
+		return fmt.Errorf("weaviate collection is required")
+	}
+	return nil
+}
+
+// CreateProvider creates a new Weaviate vector store provider instance
+func (w *weaviateProviderInitializer) CreateProvider(cfg *config.VectorDBConfig, dim int) (VectorStoreProvider, error) {
+	if err := w.InitConfig(cfg); err != nil {
+		return nil, err
+	}
+	if err := w.ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return NewWeaviateProvider(cfg, dim)
+}
+
+// WeaviateProvider implements the vector store provider interface for Weaviate through its
+// REST and GraphQL APIs, optionally sending batch inserts through the gRPC API. The
+// collection maps to a class with the vectorizer disabled, so vectors are always supplied
+// by the caller. Scalar metadata values are also written to
+// "<metadata>_<key>" properties, which is what metadata filters compare against.
+type WeaviateProvider struct {
+	client     *http.Client
+	baseURL    string
+	apiKey     string
+	config     *config.VectorDBConfig
+	class      string
+	mapper     VectorDBMapper
+	dimensions int
+	// grpcBatcher inserts batches when a gRPC port is configured, nil otherwise
+	grpcBatcher *weaviateGRPCBatcher
+
+	mu sync.RWMutex
+	// properties maps every property of the class to its Weaviate data type
+	properties map[string]string
+}
+
+// NewWeaviateProvider creates a new instance of WeaviateProvider and creates the class when missing
+func NewWeaviateProvider(cfg *config.VectorDBConfig, dimensions int) (VectorStoreProvider, error) {
+	provider, err := newWeaviateProvider(cfg, dimensions)
+	if err != nil {
+		return nil, err
+	}
+	if err := provider.CreateCollection(context.Background(), dimensions); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+func newWeaviateProvider(cfg *config.VectorDBConfig, dimensions int) (*WeaviateProvider, error) {
+	mapper, err := NewDefaultVectorDBMapper(WEAVIATE_PROVIDER_TYPE, cfg.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create default vector db mapper: %w", err)
+	}
+	baseURL := strings.TrimSuffix(cfg.Host, "/")
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "http://" + baseURL
+	}
+	var batcher *weaviateGRPCBatcher
+	if cfg.GRPCPort > 0 {
+		if batcher, err = newWeaviateGRPCBatcher(cfg.Host, cfg.GRPCPort, cfg.Password); err != nil {
+			return nil, err
+		}
+	}
+	return &WeaviateProvider{
+		client:      &http.Client{Timeout: WEAVIATE_DEFAULT_TIMEOUT},
+		baseURL:     fmt.Sprintf("%s:%d", baseURL, cfg.Port),
+		apiKey:      cfg.Password,
+		config:      cfg,
+		class:       weaviateClassName(cfg.Collection),
+		mapper:      mapper,
+		dimensions:  dimensions,
+		grpcBatcher: batcher,
+		properties:  make(map[string]string),
+	}, nil
+}
+
+// Close closes the gRPC connection used for batch inserts, if any
+func (w *WeaviateProvider) Close() error {
+	if w.grpcBatcher != nil {
+		return w.grpcBatcher.conn.Close()
+	}
+	return nil
+}
+
+// weaviateClass is the class definition accepted by the schema endpoints
+type weaviateClass struct {
+	Class             string                 `json:"class"`
+	Description       string                 `json:"description,omitempty"`
+	Vectorizer        string                 `json:"vectorizer,omitempty"`
+	VectorIndexType   string                 `json:"vectorIndexType,omitempty"`
+	VectorIndexConfig map[string]interface{} `json:"vectorIndexConfig,omitempty"`
+	Properties        []weaviateProperty     `json:"properties"`
+}
+
+type weaviateProperty struct {
+	Name            string   `json:"name"`
+	DataType        []string `json:"dataType"`
+	Tokenization    string   `json:"tokenization,omitempty"`
+	IndexFilterable *bool    `json:"indexFilterable,omitempty"`
+	IndexSearchable *bool    `json:"indexSearchable,omitempty"`
+}
+
+// weaviateClassName turns a collection name into a valid class name, which must start
+// with an upper-case letter and contain only letters, digits and underscores
+func weaviateClassName(collection string) string {
+	name := sanitizeWeaviateName(collection)
+	if name == "" || !isASCIILetter(name[0]) {
+		name = "C" + name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// weaviatePropertyName turns a raw field name into a valid property name. Names Weaviate
+// reserves, such as "id", get a "doc_" prefix.
+func weaviatePropertyName(raw string) string {
+	name := sanitizeWeaviateName(raw)
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || weaviateReservedProperties[name] {
+		name = "doc_" + name
+	}
+	return name
+}
+
+// weaviateMetadataProperty returns the property holding the flattened metadata key
+func weaviateMetadataProperty(metadataField, key string) string {
+	return weaviatePropertyName(metadataField) + "_" + sanitizeWeaviateName(key)
+}
+
+func sanitizeWeaviateName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if isASCIILetter(c) || (c >= '0' && c <= '9') || c == '_' {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// weaviateDistance maps the configured metric type to a Weaviate distance metric.
+// Weaviate's default, cosine, is used when no metric is configured.
+func weaviateDistance(metricType string) (string, error) {
+	switch strings.ToUpper(metricType) {
+	case "", "COSINE":
+		return "cosine", nil
+	case "L2":
+		return "l2-squared", nil
+	case "IP":
+		return "dot", nil
+	case "HAMMING":
+		return "hamming", nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidMetricType, metricType)
+	}
+}
+
+// weaviateScore converts a Weaviate distance into a score. Cosine and dot distances are
+// turned back into similarities, so higher is better as with Milvus; L2 and hamming
+// distances are returned unchanged.
+func weaviateScore(distanceMetric string, distance float64) float64 {
+	switch distanceMetric {
+	case "cosine":
+		return 1 - distance
+	case "dot":
+		return -distance
+	default:
+		return distance
+	}
+}
+
+// intParam reads an integer parameter that may have been decoded from JSON as a float
+func intParam(params map[string]interface{}, key string) (int, bool) {
+	switch v := params[key].(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+// propertyFields returns the mapped fields stored as class properties
+func (w *WeaviateProvider) propertyFields() []config.FieldMapping {
+	fields := make([]config.FieldMapping, 0, len(weaviateStandardFields))
+	for _, name := range weaviateStandardFields {
+		if field, err := w.mapper.GetRawField(name); err == nil {
+			fields = append(fields, *field)
+		}
+	}
+	return fields
+}
+
+// buildClass maps the field mappings, index and search configuration to a class definition
+func (w *WeaviateProvider) buildClass() (*weaviateClass, error) {
+	indexConfig, _ := w.mapper.GetIndexConfig()
+	searchConfig, _ := w.mapper.GetSearchConfig()
+	distance, err := weaviateDistance(searchConfig.MetricType)
+	if err != nil {
+		return nil, err
+	}
+	vectorIndexConfig := map[string]interface{}{"distance": distance}
+	indexType := strings.ToLower(indexConfig.IndexType)
+	switch indexType {
+	case "", "hnsw":
+		indexType = "hnsw"
+		if m, ok := intParam(indexConfig.Params, "M"); ok {
+			vectorIndexConfig["maxConnections"] = m
+		}
+		if efConstruction, ok := intParam(indexConfig.Params, "efConstruction"); ok {
+			vectorIndexConfig["efConstruction"] = efConstruction
+		}
+		if ef, ok := intParam(searchConfig.Params, "ef"); ok {
+			vectorIndexConfig["ef"] = ef
+		}
+	case "flat":
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIndexType, indexConfig.IndexType)
+	}
+
+	disabled := false
+	class := &weaviateClass{
+		Class:             w.class,
+		Description:       "Knowledge document collection",
+		Vectorizer:        "none",
+		VectorIndexType:   indexType,
+		VectorIndexConfig: vectorIndexConfig,
+	}
+	for _, field := range w.propertyFields() {
+		name := weaviatePropertyName(field.RawName)
+		switch field.StandardName {
+		case "id":
+			class.Properties = append(class.Properties, weaviateProperty{
+				Name: name, DataType: []string{weaviateTypeText}, Tokenization: "field", IndexSearchable: &disabled,
+			})
+		case "content":
+			class.Properties = append(class.Properties, weaviateProperty{
+				Name: name, DataType: []string{weaviateTypeText}, Tokenization: "word",
+			})
+		case "metadata":
+			// The JSON document is only returned, never compared; filters use the flattened properties
+			class.Properties = append(class.Properties, weaviateProperty{
+				Name: name, DataType: []string{weaviateTypeText}, IndexFilterable: &disabled, IndexSearchable: &disabled,
+			})
+		case "created_at":
+			class.Properties = append(class.Properties, weaviateProperty{
+				Name: name, DataType: []string{weaviateTypeInt},
+			})
+		}
+	}
+	return class, nil
+}
+
+// weaviateStatusError is returned for a response outside the 2xx range
+type weaviateStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *weaviateStatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
+func isWeaviateNotFound(err error) bool {
+	var statusErr *weaviateStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// do sends body as JSON to path and decodes a JSON reply into out when out is not nil
+func (w *WeaviateProvider) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, w.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if w.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.apiKey)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &weaviateStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// graphQL runs a GraphQL query and returns its data object
+func (w *WeaviateProvider) graphQL(ctx context.Context, query string) (map[string]interface{}, error) {
+	var resp struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := w.do(ctx, http.MethodPost, "/v1/graphql", map[string]string{"query": query}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Errors) > 0 {
+		messages := make([]string, len(resp.Errors))
+		for i, e := range resp.Errors {
+			messages[i] = e.Message
+		}
+		return nil, errors.New(strings.Join(messages, "; "))
+	}
+	return resp.Data, nil
+}
+
+// CreateCollection creates the class when it does not exist and loads its properties
+func (w *WeaviateProvider) CreateCollection(ctx context.Context, dim int) error {
+	var existing weaviateClass
+	err := w.do(ctx, http.MethodGet, "/v1/schema/"+w.class, nil, &existing)
+	if err == nil {
+		w.setProperties(existing.Properties)
+		return nil
+	}
+	if !isWeaviateNotFound(err) {
+		return fmt.Errorf("failed to check %s class existence: %w", w.class, err)
+	}
+
+	api.LogInfof("rag: weaviate creating class %s", w.class)
+	class, err := w.buildClass()
+	if err != nil {
+		return fmt.Errorf("failed to build class: %w", err)
+	}
+	if err := w.do(ctx, http.MethodPost, "/v1/schema", class, nil); err != nil {
+		return fmt.Errorf("failed to create class: %w", err)
+	}
+	w.setProperties(class.Properties)
+	return nil
+}
+
+func (w *WeaviateProvider) setProperties(properties []weaviateProperty) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.properties = make(map[string]string, len(properties))
+	for _, property := range properties {
+		if len(property.DataType) > 0 {
+			w.properties[property.Name] = property.DataType[0]
+		}
+	}
+}
+
+func (w *WeaviateProvider) propertyType(name string) (string, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	dataType, ok := w.properties[name]
+	return dataType, ok
+}
+
+// reloadProperties refreshes the property cache, picking up properties added by other instances
+func (w *WeaviateProvider) reloadProperties(ctx context.Context) error {
+	var class weaviateClass
+	if err := w.do(ctx, http.MethodGet, "/v1/schema/"+w.class, nil, &class); err != nil {
+		return fmt.Errorf("failed to load %s class: %w", w.class, err)
+	}
+	w.setProperties(class.Properties)
+	return nil
+}
+
+// DropCollection removes the class and all its objects
+func (w *WeaviateProvider) DropCollection(ctx context.Context) error {
+	if err := w.do(ctx, http.MethodDelete, "/v1/schema/"+w.class, nil, nil); err != nil {
+		if isWeaviateNotFound(err) {
+			return fmt.Errorf("collection %s does not exist", w.class)
+		}
+		return fmt.Errorf("failed to drop collection: %w", err)
+	}
+	w.setProperties(nil)
+	return nil
+}
+
+// weaviateDataType returns the property type for a scalar metadata value, or "" for values
+// that are not flattened
+func weaviateDataType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return weaviateTypeText
+	case bool:
+		return weaviateTypeBoolean
+	case int, int32, int64, float32, float64:
+		return weaviateTypeNumber
+	default:
+		return ""
+	}
+}
+
+// ensureMetadataProperties adds a property for every scalar metadata key of docs that the
+// class does not have yet
+func (w *WeaviateProvider) ensureMetadataProperties(ctx context.Context, docs []schema.Document) error {
+	metadataField, err := w.mapper.GetRawField("metadata")
+	if err != nil {
+		return nil
+	}
+	missing := make(map[string]string)
+	for _, doc := range docs {
+		for key, value := range doc.Metadata {
+			dataType := weaviateDataType(value)
+			if dataType == "" {
+				continue
+			}
+			name := weaviateMetadataProperty(metadataField.RawName, key)
+			// The first document writing a key decides its type
+			if _, seen := missing[name]; seen {
+				continue
+			}
+			if _, ok := w.propertyType(name); !ok {
+				missing[name] = dataType
+			}
+		}
+	}
+	names := make([]string, 0, len(missing))
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	disabled := false
+	for _, name := range names {
+		property := weaviateProperty{Name: name, DataType: []string{missing[name]}}
+		if missing[name] == weaviateTypeText {
+			property.Tokenization = "field"
+			property.IndexSearchable = &disabled
+		}
+		if err := w.do(ctx, http.MethodPost, "/v1/schema/"+w.class+"/properties", property, nil); err != nil {
+			// Another instance may have added it first; trust the schema if it has the property now
+			if reloadErr := w.reloadProperties(ctx); reloadErr == nil {
+				if _, ok := w.propertyType(name); ok {
+					continue
+				}
+			}
+			return fmt.Errorf("failed to add property %s: %w", name, err)
+		}
+		w.mu.Lock()
+		w.properties[name] = missing[name]
+		w.mu.Unlock()
+	}
+	return nil
+}
+
+// weaviateObjectID derives a stable object UUID from the document ID, so writing the same
+// document twice replaces it
+func weaviateObjectID(docID string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(docID)).String()
+}
+
+// buildObject maps a document to a batch object
+func (w *WeaviateProvider) buildObject(doc schema.Document) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+	for _, field := range w.propertyFields() {
+		name := weaviatePropertyName(field.RawName)
+		switch field.StandardName {
+		case "id":
+			properties[name] = doc.ID
+		case "content":
+			properties[name] = doc.Content
+		case "metadata":
+			metadataBytes, err := json.Marshal(doc.Metadata)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal metadata for doc %s: %w", doc.ID, err)
+			}
+			properties[name] = string(metadataBytes)
+			for key, value := range doc.Metadata {
+				dataType := weaviateDataType(value)
+				property := weaviateMetadataProperty(field.RawName, key)
+				// A key first written with another type keeps that type; the value stays in the JSON only
+				if existing, ok := w.propertyType(property); ok && existing == dataType {
+					properties[property] = value
+				}
+			}
+		case "created_at":
+			properties[name] = doc.CreatedAt.UnixMilli()
+		}
+	}
+	return map[string]interface{}{
+		"class":      w.class,
+		"id":         weaviateObjectID(doc.ID),
+		"properties": properties,
+		"vector":     doc.Vector,
+	}, nil
+}
+
+// AddDoc adds documents to the vector database. Documents whose ID already exists are replaced.
+func (w *WeaviateProvider) AddDoc(ctx context.Context, docs []schema.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	idField, _ := w.mapper.GetIDField()
+	if idField.IsAutoID() {
+		docs = append([]schema.Document(nil), docs...)
+		for i := range docs {
+			if docs[i].ID == "" {
+				docs[i].ID = uuid.New().String()
+			}
+		}
+	}
+	if err := w.ensureMetadataProperties(ctx, docs); err != nil {
+		return err
+	}
+	for start := 0; start < len(docs); start += WEAVIATE_BATCH_SIZE {
+		end := start + WEAVIATE_BATCH_SIZE
+		if end > len(docs) {
+			end = len(docs)
+		}
+		objects := make([]map[string]interface{}, 0, end-start)
+		for _, doc := range docs[start:end] {
+			object, err := w.buildObject(doc)
+			if err != nil {
+				return err
+			}
+			objects = append(objects, object)
+		}
+		if w.grpcBatcher != nil {
+			if err := w.grpcBatcher.batchObjects(ctx, objects); err != nil {
+				return fmt.Errorf("failed to insert documents: %w", err)
+			}
+			continue
+		}
+		var results []struct {
+			ID     string `json:"id"`
+			Result struct {
+				Errors *struct {
+					Error []struct {
+						Message string `json:"message"`
+					} `json:"error"`
+				} `json:"errors"`
+			} `json:"result"`
+		}
+		if err := w.do(ctx, http.MethodPost, "/v1/batch/objects", map[string]interface{}{"objects": objects}, &results); err != nil {
+			return fmt.Errorf("failed to insert documents: %w", err)
+		}
+		for _, result := range results {
+			if result.Result.Errors != nil && len(result.Result.Errors.Error) > 0 {
+				return fmt.Errorf("failed to insert document %s: %s", result.ID, result.Result.Errors.Error[0].Message)
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteDoc deletes a document by its ID
+func (w *WeaviateProvider) DeleteDoc(ctx context.Context, id string) error {
+	if err := w.DeleteDocs(ctx, []string{id}); err != nil {
+		return fmt.Errorf("failed to delete documents for id %s: %w", id, err)
+	}
+	return nil
+}
+
+// UpdateDoc replaces documents; objects are keyed by document ID, so this is an upsert
+func (w *WeaviateProvider) UpdateDoc(ctx context.Context, docs []schema.Document) error {
+	if err := w.AddDoc(ctx, docs); err != nil {
+		return fmt.Errorf("failed to add new documents: %w", err)
+	}
+	return nil
+}
+
+// DeleteDocs deletes multiple documents by their IDs
+func (w *WeaviateProvider) DeleteDocs(ctx context.Context, ids []string) error {
+	for start := 0; start < len(ids); start += WEAVIATE_BATCH_SIZE {
+		end := start + WEAVIATE_BATCH_SIZE
+		if end > len(ids) {
+			end = len(ids)
+		}
+		where, err := w.buildWhere(ctx, ids[start:end], nil)
+		if err != nil {
+			return err
+		}
+		body := map[string]interface{}{
+			"match":  map[string]interface{}{"class": w.class, "where": where},
+			"output": "minimal",
+		}
+		if err := w.do(ctx, http.MethodDelete, "/v1/batch/objects", body, nil); err != nil {
+			return fmt.Errorf("failed to delete documents: %w", err)
+		}
+	}
+	return nil
+}
+
+// weaviateBatchDeleteResults is the summary Weaviate returns for a batch delete
+type weaviateBatchDeleteResults struct {
+	Matches    int64 `json:"matches"`
+	Limit      int64 `json:"limit"`
+	Successful int64 `json:"successful"`
+	Failed     int64 `json:"failed"`
+}
+
+// DeleteByFilter deletes the documents matching the metadata filters with batch deletes.
+// A batch delete removes at most the server's query limit, so it is repeated until every
+// match is gone.
+func (w *WeaviateProvider) DeleteByFilter(ctx context.Context, filters map[string]interface{}) (int64, error) {
+	if len(filters) == 0 {
+		return 0, errEmptyFilter
+	}
+	where, err := w.buildWhere(ctx, nil, filters)
+	if errors.Is(err, errNoMatch) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	body := map[string]interface{}{
+		"match":  map[string]interface{}{"class": w.class, "where": where},
+		"output": "minimal",
+	}
+	var deleted int64
+	for {
+		var resp struct {
+			Results weaviateBatchDeleteResults `json:"results"`
+		}
+		if err := w.do(ctx, http.MethodDelete, "/v1/batch/objects", body, &resp); err != nil {
+			return deleted, fmt.Errorf("failed to delete documents: %w", err)
+		}
+		deleted += resp.Results.Successful
+		if resp.Results.Failed > 0 {
+			return deleted, fmt.Errorf("failed to delete %d documents", resp.Results.Failed)
+		}
+		if resp.Results.Successful == 0 || resp.Results.Matches <= resp.Results.Limit {
+			return deleted, nil
+		}
+	}
+}
+
+// weaviateWhere is a Weaviate filter. It is sent as JSON to the batch endpoints and
+// rendered as a GraphQL argument for queries.
+type weaviateWhere struct {
+	Operator     string          `json:"operator"`
+	Path         []string        `json:"path,omitempty"`
+	Operands     []weaviateWhere `json:"operands,omitempty"`
+	ValueText    *string         `json:"valueText,omitempty"`
+	ValueNumber  *float64        `json:"valueNumber,omitempty"`
+	ValueBoolean *bool           `json:"valueBoolean,omitempty"`
+}
+
+// graphQL renders the filter as a GraphQL input object
+func (f weaviateWhere) graphQL() string {
+	parts := []string{"operator: " + f.Operator}
+	if len(f.Path) > 0 {
+		path, _ := json.Marshal(f.Path)
+		parts = append(parts, "path: "+string(path))
+	}
+	if len(f.Operands) > 0 {
+		operands := make([]string, len(f.Operands))
+		for i, operand := range f.Operands {
+			operands[i] = operand.graphQL()
+		}
+		parts = append(parts, "operands: ["+strings.Join(operands, ", ")+"]")
+	}
+	switch {
+	case f.ValueText != nil:
+		text, _ := json.Marshal(*f.ValueText)
+		parts = append(parts, "valueText: "+string(text))
+	case f.ValueNumber != nil:
+		parts = append(parts, "valueNumber: "+strconv.FormatFloat(*f.ValueNumber, 'f', -1, 64))
+	case f.ValueBoolean != nil:
+		parts = append(parts, "valueBoolean: "+strconv.FormatBool(*f.ValueBoolean))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// weaviateEqual builds an Equal filter on a scalar value
+func weaviateEqual(property string, value interface{}) (weaviateWhere, error) {
+	where := weaviateWhere{Operator: "Equal", Path: []string{property}}
+	switch v := value.(type) {
+	case string:
+		where.ValueText = &v
+	case bool:
+		where.ValueBoolean = &v
+	case int:
+		n := float64(v)
+		where.ValueNumber = &n
+	case int32:
+		n := float64(v)
+		where.ValueNumber = &n
+	case int64:
+		n := float64(v)
+		where.ValueNumber = &n
+	case float32:
+		n := float64(v)
+		where.ValueNumber = &n
+	case float64:
+		where.ValueNumber = &v
+	default:
+		return where, fmt.Errorf("unsupported value type %T", value)
+	}
+	return where, nil
+}
+
+// buildWhere builds a filter matching any of ids and every metadata filter, or nil when
+// there is nothing to filter on. It returns errNoMatch when a filter key was never written.
+func (w *WeaviateProvider) buildWhere(ctx context.Context, ids []string, filters map[string]interface{}) (*weaviateWhere, error) {
+	clauses := make([]weaviateWhere, 0, len(filters)+1)
+	if len(ids) > 0 {
+		idField, _ := w.mapper.GetIDField()
+		idProperty := weaviatePropertyName(idField.RawName)
+		operands := make([]weaviateWhere, len(ids))
+		for i, id := range ids {
+			operands[i], _ = weaviateEqual(idProperty, id)
+		}
+		if len(operands) == 1 {
+			clauses = append(clauses, operands[0])
+		} else {
+			clauses = append(clauses, weaviateWhere{Operator: "Or", Operands: operands})
+		}
+	}
+	if len(filters) > 0 {
+		metadataField, err := w.mapper.GetRawField("metadata")
+		if err != nil {
+			return nil, fmt.Errorf("metadata filters require a metadata field: %w", err)
+		}
+		keys := make([]string, 0, len(filters))
+		for key := range filters {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		reloaded := false
+		for _, key := range keys {
+			clause, err := weaviateEqual(weaviateMetadataProperty(metadataField.RawName, key), filters[key])
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter %s: %w", key, err)
+			}
+			dataType, ok := w.propertyType(clause.Path[0])
+			if !ok && !reloaded {
+				reloaded = true
+				if err := w.reloadProperties(ctx); err != nil {
+					return nil, err
+				}
+				dataType, ok = w.propertyType(clause.Path[0])
+			}
+			if !ok || dataType != weaviateDataType(filters[key]) {
+				return nil, errNoMatch
+			}
+			clauses = append(clauses, clause)
+		}
+	}
+	switch len(clauses) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &clauses[0], nil
+	default:
+		return &weaviateWhere{Operator: "And", Operands: clauses}, nil
+	}
+}
+
+// outputProperties returns the GraphQL selection of the mapped properties
+func (w *WeaviateProvider) outputProperties() string {
+	fields := w.propertyFields()
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		names = append(names, weaviatePropertyName(field.RawName))
+	}
+	return strings.Join(names, " ")
+}
+
+// getObjects runs a Get query with the given arguments and returns the matched objects
+func (w *WeaviateProvider) getObjects(ctx context.Context, arguments []string, additional string) ([]map[string]interface{}, error) {
+	query := fmt.Sprintf("{ Get { %s(%s) { %s _additional { %s } } } }",
+		w.class, strings.Join(arguments, ", "), w.outputProperties(), additional)
+	data, err := w.graphQL(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	get, _ := data["Get"].(map[string]interface{})
+	items, _ := get[w.class].([]interface{})
+	objects := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if object, ok := item.(map[string]interface{}); ok {
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+// toDocument maps a Get result object back to a document
+func (w *WeaviateProvider) toDocument(object map[string]interface{}) schema.Document {
+	var doc schema.Document
+	for _, field := range w.propertyFields() {
+		value := object[weaviatePropertyName(field.RawName)]
+		switch field.StandardName {
+		case "id":
+			doc.ID, _ = value.(string)
+		case "content":
+			doc.Content, _ = value.(string)
+		case "metadata":
+			if text, ok := value.(string); ok {
+				if err := json.Unmarshal([]byte(text), &doc.Metadata); err != nil {
+					doc.Metadata = make(map[string]interface{})
+				}
+			}
+		case "created_at":
+			if number, ok := value.(json.Number); ok {
+				if millis, err := number.Int64(); err == nil {
+					doc.CreatedAt = time.UnixMilli(millis)
+				}
+			}
+		}
+	}
+	if additional, ok := object["_additional"].(map[string]interface{}); ok {
+		if values, ok := additional["vector"].([]interface{}); ok {
+			doc.Vector = make([]float32, 0, len(values))
+			for _, v := range values {
+				if number, ok := v.(json.Number); ok {
+					f, _ := number.Float64()
+					doc.Vector = append(doc.Vector, float32(f))
+				}
+			}
+		}
+	}
+	return doc
+}
+
+// SearchDocs performs similarity search for documents with a nearVector query
+func (w *WeaviateProvider) SearchDocs(ctx context.Context, vector []float32, options *schema.SearchOptions) ([]schema.SearchResult, error) {
+	if options == nil {
+		options = &schema.SearchOptions{TopK: 10}
+	}
+	where, err := w.buildWhere(ctx, nil, options.Filters)
+	if errors.Is(err, errNoMatch) {
+		return []schema.SearchResult{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	searchConfig, _ := w.mapper.GetSearchConfig()
+	distance, err := weaviateDistance(searchConfig.MetricType)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, len(vector))
+	for i, v := range vector {
+		values[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	arguments := []string{
+		"nearVector: {vector: [" + strings.Join(values, ", ") + "]}",
+		"limit: " + strconv.Itoa(options.TopK),
+	}
+	if where != nil {
+		arguments = append(arguments, "where: "+where.graphQL())
+	}
+	additional := "distance"
+	if options.IncludeVectors {
+		additional += " vector"
+	}
+	objects, err := w.getObjects(ctx, arguments, additional)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search documents: %w", err)
+	}
+
+	results := make([]schema.SearchResult, 0, len(objects))
+	for _, object := range objects {
+		var d float64
+		if additional, ok := object["_additional"].(map[string]interface{}); ok {
+			if number, ok := additional["distance"].(json.Number); ok {
+				d, _ = number.Float64()
+			}
+		}
+		doc := w.toDocument(object)
+		results = append(results, schema.SearchResult{
+			Document: schema.Document{ID: doc.ID, Content: doc.Content, Vector: doc.Vector, Metadata: doc.Metadata},
+			Score:    weaviateScore(distance, d),
+		})
+	}
+	return results, nil
+}
+
+// ListDocs retrieves all documents with optional limit
+func (w *WeaviateProvider) ListDocs(ctx context.Context, limit int) ([]schema.Document, error) {
+	return w.QueryDocs(ctx, &schema.QueryOptions{Limit: limit})
+}
+
+// QueryDocs retrieves documents matching the given IDs and metadata filters. Without a
+// limit it pages through every match.
+func (w *WeaviateProvider) QueryDocs(ctx context.Context, options *schema.QueryOptions) ([]schema.Document, error) {
+	if options == nil {
+		options = &schema.QueryOptions{}
+	}
+	where, err := w.buildWhere(ctx, options.IDs, options.Filters)
+	if errors.Is(err, errNoMatch) {
+		return []schema.Document{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([]schema.Document, 0)
+	offset := options.Offset
+	for {
+		pageSize := WEAVIATE_PAGE_SIZE
+		if options.Limit > 0 {
+			pageSize = options.Limit - len(documents)
+		}
+		arguments := []string{"limit: " + strconv.Itoa(pageSize), "offset: " + strconv.Itoa(offset)}
+		if where != nil {
+			arguments = append(arguments, "where: "+where.graphQL())
+		}
+		objects, err := w.getObjects(ctx, arguments, "id vector")
+		if err != nil {
+			return nil, fmt.Errorf("failed to query documents: %w", err)
+		}
+		for _, object := range objects {
+			documents = append(documents, w.toDocument(object))
+		}
+		offset += len(objects)
+		if len(objects) < pageSize || (options.Limit > 0 && len(documents) >= options.Limit) {
+			return documents, nil
+		}
+	}
+}
+
+// Count returns the number of documents matching the metadata filters
+func (w *WeaviateProvider) Count(ctx context.Context, filters map[string]interface{}) (int64, error) {
+	where, err := w.buildWhere(ctx, nil, filters)
+	if errors.Is(err, errNoMatch) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	arguments := ""
+	if where != nil {
+		arguments = "(where: " + where.graphQL() + ")"
+	}
+	data, err := w.graphQL(ctx, fmt.Sprintf("{ Aggregate { %s%s { meta { count } } } }", w.class, arguments))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+	aggregate, _ := data["Aggregate"].(map[string]interface{})
+	groups, _ := aggregate[w.class].([]interface{})
+	if len(groups) == 0 {
+		return 0, nil
+	}
+	group, _ := groups[0].(map[string]interface{})
+	meta, _ := group["meta"].(map[string]interface{})
+	count, ok := meta["count"].(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("failed to count documents: missing count in aggregate result")
+	}
+	return count.Int64()
+}
+
+// Ping reads the class definition, which fails when Weaviate is unreachable or the class
+// is missing
+func (w *WeaviateProvider) Ping(ctx context.Context) error {
+	if err := w.do(ctx, http.MethodGet, "/v1/schema/"+w.class, nil, nil); err != nil {
+		return fmt.Errorf("failed to get %s class: %w", w.class, err)
+	}
+	return nil
+}
+
+// GetProviderType returns the provider type identifier
+func (w *WeaviateProvider) GetProviderType() string {
+	return WEAVIATE_PROVIDER_TYPE
+}
@@ -0,0 +1,177 @@
+package vectordb
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeWeaviateGRPC records the batch requests sent to a fake Weaviate gRPC API
+type fakeWeaviateGRPC struct {
+	mu       sync.Mutex
+	methods  []string
+	auth     []string
+	requests [][]byte
+	// reply is returned for every batch request
+	reply []byte
+}
+
+func (f *fakeWeaviateGRPC) handle(_ interface{}, stream grpc.ServerStream) error {
+	var request []byte
+	if err := stream.RecvMsg(&request); err != nil {
+		return err
+	}
+	method, _ := grpc.MethodFromServerStream(stream)
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	f.mu.Lock()
+	f.methods = append(f.methods, method)
+	f.auth = append(f.auth, strings.Join(md.Get("authorization"), ","))
+	f.requests = append(f.requests, request)
+	reply := f.reply
+	f.mu.Unlock()
+	return stream.SendMsg(&reply)
+}
+
+// startFakeWeaviateGRPC points the batch inserts of provider at a fake gRPC API
+func startFakeWeaviateGRPC(t *testing.T, provider *WeaviateProvider) *fakeWeaviateGRPC {
+	t.Helper()
+	fake := &fakeWeaviateGRPC{}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := grpc.NewServer(grpc.ForceServerCodec(weaviateRawCodec{}), grpc.UnknownServiceHandler(fake.handle))
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	batcher, err := newWeaviateGRPCBatcher("127.0.0.1", listener.Addr().(*net.TCPAddr).Port, "secret")
+	if err != nil {
+		t.Fatalf("newWeaviateGRPCBatcher() error = %v", err)
+	}
+	t.Cleanup(func() { _ = batcher.conn.Close() })
+	provider.grpcBatcher = batcher
+	return fake
+}
+
+// decodedBatchObject holds the fields of a weaviate.v1.BatchObject written by encodeWeaviateBatch
+type decodedBatchObject struct {
+	uuid       string
+	collection string
+	properties map[string]interface{}
+	vector     []float32
+}
+
+func decodeBatchRequest(t *testing.T, request []byte) []decodedBatchObject {
+	t.Helper()
+	objects := make([]decodedBatchObject, 0)
+	for _, encoded := range bytesFields(t, request)[weaviateBatchRequestObjects] {
+		fields := bytesFields(t, encoded)
+		object := decodedBatchObject{
+			uuid:       string(fields[weaviateObjectUUID][0]),
+			collection: string(fields[weaviateObjectCollection][0]),
+		}
+		nonRef := &structpb.Struct{}
+		properties := bytesFields(t, fields[weaviateObjectProperties][0])[weaviatePropertiesNonRef][0]
+		if err := proto.Unmarshal(properties, nonRef); err != nil {
+			t.Fatalf("unmarshal properties: %v", err)
+		}
+		object.properties = nonRef.AsMap()
+		if vector := fields[weaviateObjectVectorBytes]; len(vector) > 0 {
+			for i := 0; i+4 <= len(vector[0]); i += 4 {
+				object.vector = append(object.vector, math.Float32frombits(binary.LittleEndian.Uint32(vector[0][i:])))
+			}
+		}
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+// bytesFields groups the length-delimited fields of a message by field number
+func bytesFields(t *testing.T, message []byte) map[protowire.Number][][]byte {
+	t.Helper()
+	fields := make(map[protowire.Number][][]byte)
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 || typ != protowire.BytesType {
+			t.Fatalf("unexpected field %d of type %d", num, typ)
+		}
+		message = message[n:]
+		value, n := protowire.ConsumeBytes(message)
+		if n < 0 {
+			t.Fatalf("malformed field %d", num)
+		}
+		fields[num] = append(fields[num], value)
+		message = message[n:]
+	}
+	return fields
+}
+
+func TestWeaviateProvider_AddDocGRPC(t *testing.T) {
+	fake, provider := startFakeWeaviate(t)
+	grpcFake := startFakeWeaviateGRPC(t, provider)
+
+	docs := make([]schema.Document, 0, WEAVIATE_BATCH_SIZE+1)
+	for i := 0; i < WEAVIATE_BATCH_SIZE+1; i++ {
+		docs = append(docs, schema.Document{ID: string(rune('a' + i%26)), Content: "alpha", Vector: []float32{1, 0.5, 0, -2},
+			Metadata: map[string]interface{}{"namespace": "team-a"}})
+	}
+	if err := provider.AddDoc(context.Background(), docs); err != nil {
+		t.Fatalf("AddDoc() error = %v", err)
+	}
+	if len(fake.objects) != 0 {
+		t.Errorf("REST batch objects = %d, want 0", len(fake.objects))
+	}
+	if len(grpcFake.requests) != 2 {
+		t.Fatalf("gRPC batch requests = %d, want 2", len(grpcFake.requests))
+	}
+	if grpcFake.methods[0] != weaviateBatchObjectsMethod || grpcFake.auth[0] != "Bearer secret" {
+		t.Errorf("method = %q, authorization = %q", grpcFake.methods[0], grpcFake.auth[0])
+	}
+
+	objects := decodeBatchRequest(t, grpcFake.requests[0])
+	if len(objects) != WEAVIATE_BATCH_SIZE || len(decodeBatchRequest(t, grpcFake.requests[1])) != 1 {
+		t.Fatalf("batch sizes = %d, %d", len(objects), len(decodeBatchRequest(t, grpcFake.requests[1])))
+	}
+	first := objects[0]
+	if first.uuid != weaviateObjectID("a") || first.collection != "Knowledge_test" {
+		t.Errorf("object = %+v", first)
+	}
+	if first.properties["doc_id"] != "a" || first.properties["content"] != "alpha" || first.properties["metadata_namespace"] != "team-a" {
+		t.Errorf("properties = %v", first.properties)
+	}
+	if len(first.vector) != 4 || first.vector[1] != 0.5 || first.vector[3] != -2 {
+		t.Errorf("vector = %v", first.vector)
+	}
+}
+
+func TestWeaviateProvider_AddDocGRPCError(t *testing.T) {
+	_, provider := startFakeWeaviate(t)
+	grpcFake := startFakeWeaviateGRPC(t, provider)
+
+	var batchError []byte
+	batchError = protowire.AppendTag(batchError, weaviateBatchErrorIndex, protowire.VarintType)
+	batchError = protowire.AppendVarint(batchError, 1)
+	batchError = protowire.AppendTag(batchError, weaviateBatchErrorDescription, protowire.BytesType)
+	batchError = protowire.AppendString(batchError, "vector lengths don't match")
+	grpcFake.reply = protowire.AppendTag(nil, weaviateBatchReplyErrors, protowire.BytesType)
+	grpcFake.reply = protowire.AppendBytes(grpcFake.reply, batchError)
+
+	err := provider.AddDoc(context.Background(), []schema.Document{
+		{ID: "a", Content: "alpha", Vector: []float32{1, 0, 0, 0}},
+		{ID: "b", Content: "beta", Vector: []float32{0, 1, 0}},
+	})
+	if err == nil || !strings.Contains(err.Error(), weaviateObjectID("b")) || !strings.Contains(err.Error(), "vector lengths") {
+		t.Errorf("AddDoc() error = %v, want the error of document b", err)
+	}
+}
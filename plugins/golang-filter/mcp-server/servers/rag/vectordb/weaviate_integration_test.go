@@ -0,0 +1,108 @@
+//go:build integration
+
+package vectordb
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// Run with a local Weaviate, for example:
+//
+//	docker run -p 8080:8080 cr.weaviate.io/semitechnologies/weaviate
+//	go test -tags integration -run Weaviate ./vectordb
+//
+// WEAVIATE_HOST and WEAVIATE_PORT override the default localhost:8080.
+func newIntegrationWeaviateProvider(t *testing.T) VectorStoreProvider {
+	t.Helper()
+	cfg := &config.VectorDBConfig{
+		Provider:   PROVIDER_TYPE_WEAVIATE,
+		Host:       os.Getenv("WEAVIATE_HOST"),
+		Collection: "rag_integration_" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		Password:   os.Getenv("WEAVIATE_API_KEY"),
+	}
+	if port, err := strconv.Atoi(os.Getenv("WEAVIATE_PORT")); err == nil {
+		cfg.Port = port
+	}
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	provider, err := NewVectorDBProvider(cfg, 4)
+	if err != nil {
+		t.Skipf("weaviate is not reachable: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = provider.DropCollection(context.Background())
+	})
+	return provider
+}
+
+func TestWeaviateIntegration_CRUD(t *testing.T) {
+	provider := newIntegrationWeaviateProvider(t)
+	ctx := context.Background()
+
+	docs := []schema.Document{
+		{ID: "a", Content: "alpha", Vector: []float32{1, 0, 0, 0}, CreatedAt: time.Now(),
+			Metadata: map[string]interface{}{"namespace": "team-a", "chunk_index": float64(0)}},
+		{ID: "b", Content: "beta", Vector: []float32{0, 1, 0, 0}, CreatedAt: time.Now(),
+			Metadata: map[string]interface{}{"namespace": "team-b", "chunk_index": float64(1)}},
+		{ID: "c", Content: "gamma", Vector: []float32{0.9, 0.1, 0, 0}, CreatedAt: time.Now(),
+			Metadata: map[string]interface{}{"namespace": "team-a", "chunk_index": float64(2)}},
+	}
+	if err := provider.AddDoc(ctx, docs); err != nil {
+		t.Fatalf("AddDoc() error = %v", err)
+	}
+
+	results, err := provider.SearchDocs(ctx, []float32{1, 0, 0, 0}, &schema.SearchOptions{TopK: 3})
+	if err != nil {
+		t.Fatalf("SearchDocs() error = %v", err)
+	}
+	if len(results) != 3 || results[0].Document.ID != "a" || results[0].Score < results[1].Score {
+		t.Errorf("SearchDocs() = %+v", results)
+	}
+
+	results, err = provider.SearchDocs(ctx, []float32{0, 1, 0, 0}, &schema.SearchOptions{
+		TopK:    3,
+		Filters: map[string]interface{}{"namespace": "team-a"},
+	})
+	if err != nil {
+		t.Fatalf("SearchDocs() with filter error = %v", err)
+	}
+	for _, result := range results {
+		if result.Document.Metadata["namespace"] != "team-a" {
+			t.Errorf("filtered result from namespace %v", result.Document.Metadata["namespace"])
+		}
+	}
+
+	count, err := provider.Count(ctx, map[string]interface{}{"namespace": "team-a"})
+	if err != nil || count != 2 {
+		t.Errorf("Count() = %d, %v, want 2", count, err)
+	}
+
+	listed, err := provider.ListDocs(ctx, 0)
+	if err != nil || len(listed) != 3 {
+		t.Errorf("ListDocs() = %d docs, %v, want 3", len(listed), err)
+	}
+
+	docs[0].Content = "alpha v2"
+	if err := provider.UpdateDoc(ctx, docs[:1]); err != nil {
+		t.Fatalf("UpdateDoc() error = %v", err)
+	}
+	updated, err := provider.QueryDocs(ctx, &schema.QueryOptions{IDs: []string{"a"}})
+	if err != nil || len(updated) != 1 || updated[0].Content != "alpha v2" {
+		t.Errorf("QueryDocs() after update = %+v, %v", updated, err)
+	}
+
+	if err := provider.DeleteDocs(ctx, []string{"a", "b"}); err != nil {
+		t.Fatalf("DeleteDocs() error = %v", err)
+	}
+	count, err = provider.Count(ctx, nil)
+	if err != nil || count != 1 {
+		t.Errorf("Count() after delete = %d, %v, want 1", count, err)
+	}
+}
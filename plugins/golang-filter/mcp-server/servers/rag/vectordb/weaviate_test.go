@@ -0,0 +1,440 @@
+package vectordb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/config"
+	"github.com/alibaba/higress/plugins/golang-filter/mcp-server/servers/rag/schema"
+	"github.com/envoyproxy/envoy/contrib/golang/common/go/api"
+)
+
+// mockCommonCAPI swallows envoy logs so provider code can run outside envoy
+type mockCommonCAPI struct{}
+
+func (m *mockCommonCAPI) Log(level api.LogType, message string) {}
+
+func (m *mockCommonCAPI) LogLevel() api.LogType { return api.Error }
+
+func newTestWeaviateProvider(t *testing.T, mapping config.MappingConfig) *WeaviateProvider {
+	t.Helper()
+	provider, err := newWeaviateProvider(&config.VectorDBConfig{
+		Provider:   WEAVIATE_PROVIDER_TYPE,
+		Host:       "localhost",
+		Port:       WEAVIATE_DEFAULT_PORT,
+		Collection: "knowledge-test",
+		Mapping:    mapping,
+	}, 4)
+	if err != nil {
+		t.Fatalf("newWeaviateProvider() error = %v", err)
+	}
+	return provider
+}
+
+func TestWeaviateClassName(t *testing.T) {
+	tests := map[string]string{
+		"document":       "Document",
+		"knowledge-test": "Knowledge_test",
+		"Docs_v2":        "Docs_v2",
+		"2024_docs":      "C2024_docs",
+	}
+	for collection, want := range tests {
+		if got := weaviateClassName(collection); got != want {
+			t.Errorf("weaviateClassName(%q) = %q, want %q", collection, got, want)
+		}
+	}
+	if got := weaviatePropertyName("id"); got != "doc_id" {
+		t.Errorf("weaviatePropertyName(id) = %q, want doc_id", got)
+	}
+	if got := weaviateMetadataProperty("metadata", "source.url"); got != "metadata_source_url" {
+		t.Errorf("weaviateMetadataProperty() = %q, want metadata_source_url", got)
+	}
+}
+
+func TestWeaviateDistance(t *testing.T) {
+	tests := map[string]string{"": "cosine", "cosine": "cosine", "L2": "l2-squared", "IP": "dot", "HAMMING": "hamming"}
+	for metric, want := range tests {
+		got, err := weaviateDistance(metric)
+		if err != nil || got != want {
+			t.Errorf("weaviateDistance(%q) = %q, %v, want %q", metric, got, err, want)
+		}
+	}
+	if _, err := weaviateDistance("JACCARD"); err == nil {
+		t.Error("weaviateDistance(JACCARD) expected error")
+	}
+	if got := weaviateScore("cosine", 0.25); got != 0.75 {
+		t.Errorf("weaviateScore(cosine) = %v, want 0.75", got)
+	}
+	if got := weaviateScore("dot", -3); got != 3 {
+		t.Errorf("weaviateScore(dot) = %v, want 3", got)
+	}
+	if got := weaviateScore("l2-squared", 2); got != 2 {
+		t.Errorf("weaviateScore(l2-squared) = %v, want 2", got)
+	}
+}
+
+func TestWeaviateProvider_BuildClass(t *testing.T) {
+	provider := newTestWeaviateProvider(t, config.MappingConfig{
+		Fields: []config.FieldMapping{
+			{StandardName: "id", RawName: "id"},
+			{StandardName: "content", RawName: "text"},
+			{StandardName: "vector", RawName: "embedding"},
+			{StandardName: "metadata", RawName: "meta"},
+			{StandardName: "created_at", RawName: "created"},
+		},
+		Index:  config.IndexConfig{IndexType: "HNSW", Params: map[string]interface{}{"M": float64(16), "efConstruction": float64(128)}},
+		Search: config.SearchConfig{MetricType: "IP", Params: map[string]interface{}{"ef": float64(64)}},
+	})
+
+	class, err := provider.buildClass()
+	if err != nil {
+		t.Fatalf("buildClass() error = %v", err)
+	}
+	if class.Class != "Knowledge_test" || class.Vectorizer != "none" || class.VectorIndexType != "hnsw" {
+		t.Errorf("buildClass() = %+v", class)
+	}
+	wantIndex := map[string]interface{}{"distance": "dot", "maxConnections": 16, "efConstruction": 128, "ef": 64}
+	if !reflect.DeepEqual(class.VectorIndexConfig, wantIndex) {
+		t.Errorf("VectorIndexConfig = %v, want %v", class.VectorIndexConfig, wantIndex)
+	}
+	got := make(map[string]string)
+	for _, property := range class.Properties {
+		got[property.Name] = property.DataType[0]
+	}
+	want := map[string]string{"doc_id": "text", "text": "text", "meta": "text", "created": "int"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("properties = %v, want %v", got, want)
+	}
+
+	provider = newTestWeaviateProvider(t, config.MappingConfig{Index: config.IndexConfig{IndexType: "IVF_FLAT"}})
+	if _, err := provider.buildClass(); err == nil {
+		t.Error("buildClass() expected error for unsupported index type")
+	}
+}
+
+func TestWeaviateWhere_GraphQL(t *testing.T) {
+	provider := newTestWeaviateProvider(t, config.MappingConfig{})
+	provider.properties = map[string]string{"metadata_namespace": "text", "metadata_chunk_index": "number"}
+
+	where, err := provider.buildWhere(context.Background(), []string{"a-1", "b-2"}, map[string]interface{}{"namespace": `te"am`, "chunk_index": 2})
+	if err != nil {
+		t.Fatalf("buildWhere() error = %v", err)
+	}
+	want := `{operator: And, operands: [` +
+		`{operator: Or, operands: [{operator: Equal, path: ["doc_id"], valueText: "a-1"}, {operator: Equal, path: ["doc_id"], valueText: "b-2"}]}, ` +
+		`{operator: Equal, path: ["metadata_chunk_index"], valueNumber: 2}, ` +
+		`{operator: Equal, path: ["metadata_namespace"], valueText: "te\"am"}]}`
+	if got := where.graphQL(); got != want {
+		t.Errorf("graphQL() =\n%s\nwant\n%s", got, want)
+	}
+
+	if where, err := provider.buildWhere(context.Background(), nil, nil); where != nil || err != nil {
+		t.Errorf("buildWhere(empty) = %v, %v, want nil", where, err)
+	}
+	if _, err := provider.buildWhere(context.Background(), nil, map[string]interface{}{"tags": []string{"x"}}); err == nil {
+		t.Error("buildWhere() expected error for unsupported value")
+	}
+	if _, err := provider.buildWhere(context.Background(), nil, map[string]interface{}{"chunk_index": "2"}); err != errNoMatch {
+		t.Errorf("buildWhere() with mismatched type error = %v, want errNoMatch", err)
+	}
+}
+
+// fakeWeaviate records the requests it receives and answers GraphQL queries with a canned reply
+type fakeWeaviate struct {
+	mu      sync.Mutex
+	classes map[string]*weaviateClass
+	objects []map[string]interface{}
+	queries []string
+	deletes []json.RawMessage
+	// deleteReplies answer batch deletes in order; a delete after the last one gets no body
+	deleteReplies []string
+	graphQL       string
+	apiKeys       []string
+}
+
+func (f *fakeWeaviate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.apiKeys = append(f.apiKeys, r.Header.Get("Authorization"))
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/schema/"):
+		class, ok := f.classes[strings.TrimPrefix(r.URL.Path, "/v1/schema/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(class)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/schema":
+		var class weaviateClass
+		_ = json.Unmarshal(body, &class)
+		f.classes[class.Class] = &class
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/properties"):
+		var property weaviateProperty
+		_ = json.Unmarshal(body, &property)
+		class := f.classes[strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/schema/"), "/properties")]
+		class.Properties = append(class.Properties, property)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/batch/objects":
+		var batch struct {
+			Objects []map[string]interface{} `json:"objects"`
+		}
+		_ = json.Unmarshal(body, &batch)
+		f.objects = append(f.objects, batch.Objects...)
+		_, _ = w.Write([]byte("[]"))
+	case r.Method == http.MethodDelete && r.URL.Path == "/v1/batch/objects":
+		f.deletes = append(f.deletes, body)
+		if len(f.deleteReplies) > 0 {
+			_, _ = w.Write([]byte(f.deleteReplies[0]))
+			f.deleteReplies = f.deleteReplies[1:]
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/graphql":
+		var query struct {
+			Query string `json:"query"`
+		}
+		_ = json.Unmarshal(body, &query)
+		f.queries = append(f.queries, query.Query)
+		_, _ = w.Write([]byte(f.graphQL))
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func startFakeWeaviate(t *testing.T) (*fakeWeaviate, *WeaviateProvider) {
+	t.Helper()
+	fake := &fakeWeaviate{classes: make(map[string]*weaviateClass)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	provider := newTestWeaviateProvider(t, config.MappingConfig{})
+	provider.baseURL = server.URL
+	provider.apiKey = "secret"
+	api.SetCommonCAPI(&mockCommonCAPI{})
+	if err := provider.CreateCollection(context.Background(), 4); err != nil {
+		t.Fatalf("CreateCollection() error = %v", err)
+	}
+	return fake, provider
+}
+
+func TestWeaviateProvider_CreateCollectionAndAddDoc(t *testing.T) {
+	fake, provider := startFakeWeaviate(t)
+	if _, ok := fake.classes["Knowledge_test"]; !ok {
+		t.Fatalf("class was not created: %v", fake.classes)
+	}
+	if fake.apiKeys[0] != "Bearer secret" {
+		t.Errorf("Authorization = %q, want Bearer secret", fake.apiKeys[0])
+	}
+
+	created := time.UnixMilli(1700000000000)
+	docs := []schema.Document{
+		{ID: "a", Content: "alpha", Vector: []float32{1, 0, 0, 0}, CreatedAt: created,
+			Metadata: map[string]interface{}{"namespace": "team-a", "chunk_index": 1, "tags": []string{"x"}}},
+		{ID: "b", Content: "beta", Vector: []float32{0, 1, 0, 0}, CreatedAt: created,
+			Metadata: map[string]interface{}{"namespace": 7}},
+	}
+	if err := provider.AddDoc(context.Background(), docs); err != nil {
+		t.Fatalf("AddDoc() error = %v", err)
+	}
+
+	types := make(map[string]string)
+	for _, property := range fake.classes["Knowledge_test"].Properties {
+		types[property.Name] = property.DataType[0]
+	}
+	if types["metadata_namespace"] != "text" || types["metadata_chunk_index"] != "number" {
+		t.Errorf("metadata properties = %v", types)
+	}
+	if _, ok := types["metadata_tags"]; ok {
+		t.Error("non-scalar metadata must not be flattened")
+	}
+
+	if len(fake.objects) != 2 {
+		t.Fatalf("batch objects = %d, want 2", len(fake.objects))
+	}
+	first := fake.objects[0]
+	if first["id"] != weaviateObjectID("a") || first["class"] != "Knowledge_test" {
+		t.Errorf("object = %v", first)
+	}
+	properties := first["properties"].(map[string]interface{})
+	if properties["doc_id"] != "a" || properties["content"] != "alpha" || properties["metadata_namespace"] != "team-a" ||
+		properties["created_at"] != float64(created.UnixMilli()) {
+		t.Errorf("properties = %v", properties)
+	}
+	second := fake.objects[1]["properties"].(map[string]interface{})
+	if _, ok := second["metadata_namespace"]; ok {
+		t.Error("a value whose type differs from the property must only be kept in the JSON metadata")
+	}
+}
+
+func TestWeaviateProvider_SearchDocs(t *testing.T) {
+	fake, provider := startFakeWeaviate(t)
+	provider.properties["metadata_namespace"] = "text"
+	fake.graphQL = `{"data":{"Get":{"Knowledge_test":[
+		{"doc_id":"a","content":"alpha","metadata":"{\"namespace\":\"team-a\"}","created_at":1700000000000,"_additional":{"distance":0.2}},
+		{"doc_id":"b","content":"beta","metadata":"{}","created_at":1700000000000,"_additional":{"distance":0.5}}
+	]}}}`
+
+	results, err := provider.SearchDocs(context.Background(), []float32{1, 0.5, 0, 0}, &schema.SearchOptions{
+		TopK:    2,
+		Filters: map[string]interface{}{"namespace": "team-a"},
+	})
+	if err != nil {
+		t.Fatalf("SearchDocs() error = %v", err)
+	}
+	query := fake.queries[0]
+	for _, part := range []string{
+		"Knowledge_test(nearVector: {vector: [1, 0.5, 0, 0]}, limit: 2",
+		`where: {operator: Equal, path: ["metadata_namespace"], valueText: "team-a"}`,
+		"_additional { distance }",
+	} {
+		if !strings.Contains(query, part) {
+			t.Errorf("query %q does not contain %q", query, part)
+		}
+	}
+	if len(results) != 2 || results[0].Document.ID != "a" || results[0].Document.Metadata["namespace"] != "team-a" {
+		t.Fatalf("results = %+v", results)
+	}
+	if results[0].Score < 0.79 || results[0].Score > 0.81 || results[1].Score != 0.5 {
+		t.Errorf("scores = %v, %v, want 0.8, 0.5", results[0].Score, results[1].Score)
+	}
+	if results[0].Document.Vector != nil {
+		t.Errorf("vector = %v, want none without include_vectors", results[0].Document.Vector)
+	}
+
+	// A filter on a key that was never written matches nothing without querying
+	results, err = provider.SearchDocs(context.Background(), []float32{1, 0, 0, 0}, &schema.SearchOptions{
+		TopK:    2,
+		Filters: map[string]interface{}{"knowledge_id": "k1"},
+	})
+	if err != nil || len(results) != 0 || len(fake.queries) != 1 {
+		t.Errorf("SearchDocs() with unknown filter = %v, %v, queries %d", results, err, len(fake.queries))
+	}
+}
+
+func TestWeaviateProvider_SearchDocsIncludeVectors(t *testing.T) {
+	fake, provider := startFakeWeaviate(t)
+	fake.graphQL = `{"data":{"Get":{"Knowledge_test":[
+		{"doc_id":"a","content":"alpha","metadata":"{}","created_at":1700000000000,"_additional":{"distance":0.2,"vector":[1,0.5,0,0]}}
+	]}}}`
+
+	results, err := provider.SearchDocs(context.Background(), []float32{1, 0.5, 0, 0}, &schema.SearchOptions{TopK: 1, IncludeVectors: true})
+	if err != nil {
+		t.Fatalf("SearchDocs() error = %v", err)
+	}
+	if !strings.Contains(fake.queries[0], "_additional { distance vector }") {
+		t.Errorf("query %q does not ask for the vector", fake.queries[0])
+	}
+	if len(results) != 1 || len(results[0].Document.Vector) != 4 || results[0].Document.Vector[1] != 0.5 {
+		t.Errorf("results = %+v, want the vector", results)
+	}
+}
+
+func TestWeaviateProvider_QueryCountAndDelete(t *testing.T) {
+	fake, provider := startFakeWeaviate(t)
+	fake.graphQL = `{"data":{"Get":{"Knowledge_test":[
+		{"doc_id":"a","content":"alpha","metadata":"{}","created_at":1700000000000,"_additional":{"id":"x","vector":[1,0,0,0]}}
+	]}}}`
+
+	docs, err := provider.QueryDocs(context.Background(), &schema.QueryOptions{IDs: []string{"a"}, Limit: 5, Offset: 10})
+	if err != nil {
+		t.Fatalf("QueryDocs() error = %v", err)
+	}
+	if len(docs) != 1 || docs[0].ID != "a" || !reflect.DeepEqual(docs[0].Vector, []float32{1, 0, 0, 0}) ||
+		docs[0].CreatedAt.UnixMilli() != 1700000000000 {
+		t.Errorf("docs = %+v", docs)
+	}
+	if !strings.Contains(fake.queries[0], `limit: 5, offset: 10, where: {operator: Equal, path: ["doc_id"], valueText: "a"}`) {
+		t.Errorf("query = %q", fake.queries[0])
+	}
+
+	fake.graphQL = `{"data":{"Aggregate":{"Knowledge_test":[{"meta":{"count":42}}]}}}`
+	count, err := provider.Count(context.Background(), nil)
+	if err != nil || count != 42 {
+		t.Errorf("Count() = %d, %v, want 42", count, err)
+	}
+
+	fake.graphQL = `{"errors":[{"message":"boom"}]}`
+	if _, err := provider.Count(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Count() error = %v, want GraphQL error", err)
+	}
+
+	if err := provider.DeleteDocs(context.Background(), []string{"a", "b"}); err != nil {
+		t.Fatalf("DeleteDocs() error = %v", err)
+	}
+	var deleted struct {
+		Match struct {
+			Class string        `json:"class"`
+			Where weaviateWhere `json:"where"`
+		} `json:"match"`
+	}
+	if err := json.Unmarshal(fake.deletes[0], &deleted); err != nil {
+		t.Fatalf("decode delete body: %v", err)
+	}
+	if deleted.Match.Class != "Knowledge_test" || deleted.Match.Where.Operator != "Or" || len(deleted.Match.Where.Operands) != 2 {
+		t.Errorf("delete match = %+v", deleted.Match)
+	}
+}
+
+func TestWeaviateProvider_DeleteByFilter(t *testing.T) {
+	fake, provider := startFakeWeaviate(t)
+	provider.properties["metadata_chunk_title"] = "text"
+	// The server deletes at most its query limit per request, so the delete is repeated
+	fake.deleteReplies = []string{
+		`{"results":{"matches":3,"limit":2,"successful":2,"failed":0}}`,
+		`{"results":{"matches":1,"limit":2,"successful":1,"failed":0}}`,
+	}
+
+	deleted, err := provider.DeleteByFilter(context.Background(), map[string]interface{}{"chunk_title": "foo"})
+	if err != nil || deleted != 3 {
+		t.Fatalf("DeleteByFilter() = %d, %v, want 3", deleted, err)
+	}
+	if len(fake.deletes) != 2 {
+		t.Fatalf("batch deletes = %d, want 2", len(fake.deletes))
+	}
+	var body struct {
+		Match struct {
+			Class string        `json:"class"`
+			Where weaviateWhere `json:"where"`
+		} `json:"match"`
+	}
+	if err := json.Unmarshal(fake.deletes[0], &body); err != nil {
+		t.Fatalf("decode delete body: %v", err)
+	}
+	if body.Match.Class != "Knowledge_test" || body.Match.Where.Operator != "Equal" ||
+		!reflect.DeepEqual(body.Match.Where.Path, []string{"metadata_chunk_title"}) || *body.Match.Where.ValueText != "foo" {
+		t.Errorf("delete match = %+v", body.Match)
+	}
+
+	fake.deleteReplies = []string{`{"results":{"matches":2,"limit":10000,"successful":1,"failed":1}}`}
+	if deleted, err := provider.DeleteByFilter(context.Background(), map[string]interface{}{"chunk_title": "foo"}); err == nil || deleted != 1 {
+		t.Errorf("DeleteByFilter() with a failed object = %d, %v, want 1 and an error", deleted, err)
+	}
+	if _, err := provider.DeleteByFilter(context.Background(), nil); err == nil {
+		t.Error("DeleteByFilter(nil) expected error")
+	}
+
+	// A filter on a key that was never written deletes nothing without a request
+	deleted, err = provider.DeleteByFilter(context.Background(), map[string]interface{}{"knowledge_id": "k1"})
+	if err != nil || deleted != 0 || len(fake.deletes) != 3 {
+		t.Errorf("DeleteByFilter() with unknown filter = %d, %v, deletes %d", deleted, err, len(fake.deletes))
+	}
+}
+
+func TestWeaviateProvider_Ping(t *testing.T) {
+	fake, provider := startFakeWeaviate(t)
+	if err := provider.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	fake.mu.Lock()
+	delete(fake.classes, "Knowledge_test")
+	fake.mu.Unlock()
+	if err := provider.Ping(context.Background()); !isWeaviateNotFound(err) {
+		t.Errorf("Ping() error = %v, want the missing class reported", err)
+	}
+}
@@ -15,6 +15,36 @@ type RedisConfig struct {
 	password string
 	db       int
 	secret   string // Encryption key
+
+	// Connection pool settings, zero values keep the go-redis defaults
+	poolSize        int
+	maxRetries      int
+	minRetryBackoff time.Duration
+	maxRetryBackoff time.Duration
+}
+
+// SetPool bounds the connections of the client's pool to poolSize and retries commands
+// failing with a network error maxRetries times, waiting between minRetryBackoff and
+// maxRetryBackoff between attempts. Zero values keep the go-redis defaults.
+func (c *RedisConfig) SetPool(poolSize, maxRetries int, minRetryBackoff, maxRetryBackoff time.Duration) {
+	c.poolSize = poolSize
+	c.maxRetries = maxRetries
+	c.minRetryBackoff = minRetryBackoff
+	c.maxRetryBackoff = maxRetryBackoff
+}
+
+// options returns the go-redis options of the config
+func (c *RedisConfig) options() *redis.Options {
+	return &redis.Options{
+		Addr:            c.address,
+		Username:        c.username,
+		Password:        c.password,
+		DB:              c.db,
+		PoolSize:        c.poolSize,
+		MaxRetries:      c.maxRetries,
+		MinRetryBackoff: c.minRetryBackoff,
+		MaxRetryBackoff: c.maxRetryBackoff,
+	}
 }
 
 // ParseRedisConfig parses Redis configuration from a map
@@ -62,12 +92,7 @@ type RedisClient struct {
 
 // NewRedisClient creates a new RedisClient instance and establishes a connection to the Redis server
 func NewRedisClient(config *RedisConfig) (*RedisClient, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     config.address,
-		Username: config.username,
-		Password: config.password,
-		DB:       config.db,
-	})
+	client := redis.NewClient(config.options())
 
 	// Ping the Redis server to check the connection
 	pong, err := client.Ping(context.Background()).Result()
@@ -128,6 +153,11 @@ func (r *RedisClient) checkConnection() error {
 	return err
 }
 
+// Ping checks whether the Redis server is reachable
+func (r *RedisClient) Ping() error {
+	return r.checkConnection()
+}
+
 // reconnect attempts to establish a new connection to Redis
 func (r *RedisClient) reconnect() error {
 	// Close the old client
@@ -136,12 +166,7 @@ func (r *RedisClient) reconnect() error {
 	}
 
 	// Create new client
-	r.client = redis.NewClient(&redis.Options{
-		Addr:     r.config.address,
-		Username: r.config.username,
-		Password: r.config.password,
-		DB:       r.config.db,
-	})
+	r.client = redis.NewClient(r.config.options())
 
 	// Test the new connection
 	if err := r.checkConnection(); err != nil {
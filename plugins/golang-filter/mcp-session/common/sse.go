@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -18,6 +19,22 @@ func GetSSEChannelName(sessionID string) string {
 	return fmt.Sprintf("mcp-server-sse:%s", sessionID)
 }
 
+// requestHeaderKey is the context key for storing the headers of the HTTP request
+type requestHeaderKey struct{}
+
+// WithRequestHeader returns a context carrying the headers of the HTTP request a message
+// arrived in
+func WithRequestHeader(ctx context.Context, header http.Header) context.Context {
+	return context.WithValue(ctx, requestHeaderKey{}, header)
+}
+
+// RequestHeaderFromContext returns the headers of the HTTP request a message arrived in,
+// or nil outside of a request
+func RequestHeaderFromContext(ctx context.Context) http.Header {
+	header, _ := ctx.Value(requestHeaderKey{}).(http.Header)
+	return header
+}
+
 // SSEServer implements a Server-Sent Events (SSE) based MCP server.
 // It provides real-time communication capabilities over HTTP using the SSE protocol.
 type SSEServer struct {
@@ -196,7 +213,7 @@ func (s *SSEServer) HandleMessage(w http.ResponseWriter, r *http.Request, body j
 	// }
 
 	// Set the client context in the server before handling the message
-	ctx := s.server.WithContext(r.Context(), NotificationContext{
+	ctx := s.server.WithContext(WithRequestHeader(r.Context(), r.Header), NotificationContext{
 		ClientID:  sessionID,
 		SessionID: sessionID,
 	})